@@ -18,6 +18,7 @@ package rockyardkv
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/aalhour/rockyardkv/internal/compaction"
 	"github.com/aalhour/rockyardkv/internal/manifest"
@@ -25,8 +26,18 @@ import (
 )
 
 // backgroundWork handles background tasks like compaction.
+//
+// Flushes and compactions run as jobs in the Env's thread pools: flushes in
+// the HIGH pool and compactions in the LOW pool, so a busy compaction pool
+// cannot delay a flush. The number of jobs of each kind this DB keeps
+// scheduled at once is bounded by MaxBackgroundFlushes and
+// MaxBackgroundCompactions.
+//
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_compaction_flush.cc
+// (MaybeScheduleFlushOrCompaction)
 type backgroundWork struct {
-	db *dbImpl
+	db  *dbImpl
+	env Env
 
 	// Compaction picker
 	picker compaction.CompactionPicker
@@ -37,41 +48,67 @@ type backgroundWork struct {
 	// Rate limiter for background I/O (optional)
 	rateLimiter RateLimiter
 
-	// Channels for coordination
-	compactionCh   chan struct{}
-	flushCh        chan struct{}
-	shutdownCh     chan struct{}
-	backgroundDone sync.WaitGroup
+	// Closed by stop, so that jobs stop waiting for rate limiter quota
+	stopCh chan struct{}
+
+	// Compactions currently executing, used to reject conflicting picks.
+	// Protected by db.mu.
+	inProgress map[*compaction.Compaction]struct{}
 
 	// State
-	mu                sync.Mutex
-	compactionRunning bool
-	flushRunning      bool
-	backgroundErrors  int
-	paused            bool
-	pauseCond         *sync.Cond
+	mu                  sync.Mutex
+	maxFlushes          int
+	maxCompactions      int
+	flushRequested      bool // a flush was requested but not yet scheduled
+	compactionRequested bool // a compaction was requested but not yet scheduled
+	flushScheduled      int  // flush jobs scheduled or running
+	compactionScheduled int  // compaction jobs scheduled or running
+	runningFlushes      int
+	runningCompactions  int
+	shuttingDown        bool
+	backgroundErrors    int
+	paused              bool
+	jobsDone            *sync.Cond
 }
 
 // newBackgroundWork creates a new background work handler.
 func newBackgroundWork(db *dbImpl, opts *Options) *backgroundWork {
-	picker := createCompactionPicker(opts)
+	picker := createCompactionPicker(opts, db.env)
 	maxSub := opts.MaxSubcompactions
 	if maxSub <= 0 {
 		maxSub = 1
 	}
 	bg := &backgroundWork{
 		db:                db,
+		env:               db.env,
 		picker:            picker,
 		maxSubcompactions: maxSub,
 		rateLimiter:       opts.RateLimiter,
-		compactionCh:      make(chan struct{}, 1),
-		flushCh:           make(chan struct{}, 1),
-		shutdownCh:        make(chan struct{}),
+		inProgress:        make(map[*compaction.Compaction]struct{}),
+		stopCh:            make(chan struct{}),
 	}
-	bg.pauseCond = sync.NewCond(&bg.mu)
+	bg.maxFlushes, bg.maxCompactions = bgJobLimits(
+		opts.MaxBackgroundJobs, opts.MaxBackgroundFlushes, opts.MaxBackgroundCompactions)
+	bg.jobsDone = sync.NewCond(&bg.mu)
 	return bg
 }
 
+// bgJobLimits splits the background job budget between flushes and
+// compactions. Explicit flush or compaction limits take precedence over
+// maxJobs; otherwise a quarter of the jobs go to flushes.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_compaction_flush.cc GetBGJobLimits()
+func bgJobLimits(maxJobs, maxFlushes, maxCompactions int) (flushes, compactions int) {
+	if maxFlushes > 0 || maxCompactions > 0 {
+		return max(1, maxFlushes), max(1, maxCompactions)
+	}
+	if maxJobs <= 0 {
+		maxJobs = 2
+	}
+	flushes = max(1, maxJobs/4)
+	compactions = max(1, maxJobs-flushes)
+	return flushes, compactions
+}
+
 // compactionFilterAdapter adapts db.CompactionFilter to compaction.Filter.
 type compactionFilterAdapter struct {
 	filter CompactionFilter
@@ -104,7 +141,8 @@ func (a *mergeOperatorAdapter) FullMerge(key []byte, existingValue []byte, opera
 }
 
 // createCompactionPicker creates the appropriate picker based on options.
-func createCompactionPicker(opts *Options) compaction.CompactionPicker {
+// Time-based pickers (FIFO TTL) read the current time from clock.
+func createCompactionPicker(opts *Options, clock SystemClock) compaction.CompactionPicker {
 	switch opts.CompactionStyle {
 	case CompactionStyleUniversal:
 		var uopts *compaction.UniversalCompactionOptions
@@ -128,39 +166,77 @@ func createCompactionPicker(opts *Options) compaction.CompactionPicker {
 				AllowCompaction:   opts.FIFOCompactionOptions.AllowCompaction,
 			}
 		}
-		return compaction.NewFIFOCompactionPicker(fopts)
+		picker := compaction.NewFIFOCompactionPicker(fopts)
+		if clock != nil {
+			picker.SetClock(func() time.Time { return clockNow(clock) })
+		}
+		return picker
 
 	default:
 		// Default to leveled compaction
 		picker := compaction.DefaultLeveledCompactionPicker()
+		if opts.NumLevels > 0 {
+			picker.NumLevels = opts.NumLevels
+		}
 		if opts.Level0FileNumCompactionTrigger > 0 {
 			picker.L0CompactionTrigger = opts.Level0FileNumCompactionTrigger
 		}
 		if opts.MaxBytesForLevelBase > 0 {
 			picker.MaxBytesForLevelBase = uint64(opts.MaxBytesForLevelBase)
 		}
+		if opts.MaxBytesForLevelMultiplier > 0 {
+			picker.MaxBytesForLevelMulti = opts.MaxBytesForLevelMultiplier
+		}
+		if opts.TargetFileSizeBase > 0 {
+			picker.TargetFileSizeBase = uint64(opts.TargetFileSizeBase)
+		}
+		if opts.TargetFileSizeMultiplier > 0 {
+			picker.TargetFileSizeMulti = float64(opts.TargetFileSizeMultiplier)
+		}
+		picker.MaxCompactionBytes = opts.MaxCompactionBytes
 		return picker
 	}
 }
 
-// Start starts the background workers.
+// Start makes sure the Env's thread pools can run this DB's jobs.
 func (bg *backgroundWork) start() {
-	bg.backgroundDone.Add(1)
-	go bg.backgroundLoop()
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	bg.env.IncBackgroundThreadsIfNeeded(bg.maxFlushes, PriorityHigh)
+	bg.env.IncBackgroundThreadsIfNeeded(bg.maxCompactions, PriorityLow)
+}
+
+// setJobLimits changes the number of flush and compaction jobs this DB may
+// run at once. Pools are grown as needed; lowering a limit lets running jobs
+// finish and only holds back new ones.
+func (bg *backgroundWork) setJobLimits(flushes, compactions int) {
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	bg.maxFlushes = flushes
+	bg.maxCompactions = compactions
+	bg.env.IncBackgroundThreadsIfNeeded(flushes, PriorityHigh)
+	bg.env.IncBackgroundThreadsIfNeeded(compactions, PriorityLow)
+	// New slots may be free; let a compaction job look for work.
+	bg.compactionRequested = true
+	bg.maybeScheduleLocked()
 }
 
-// Stop stops the background workers and waits for them to finish.
+// Stop stops scheduling new background jobs and waits for scheduled ones to finish.
 func (bg *backgroundWork) stop() {
 	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	if !bg.shuttingDown {
+		close(bg.stopCh)
+	}
+	bg.shuttingDown = true
 	bg.paused = false
-	bg.pauseCond.Broadcast()
-	bg.mu.Unlock()
-
-	close(bg.shutdownCh)
-	bg.backgroundDone.Wait()
+	for bg.flushScheduled > 0 || bg.compactionScheduled > 0 {
+		bg.jobsDone.Wait()
+	}
 }
 
 // Pause pauses all background work.
+// Running jobs finish; no new jobs start until resume.
 // Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc PauseBackgroundWork()
 func (bg *backgroundWork) pause() {
 	bg.mu.Lock()
@@ -174,7 +250,7 @@ func (bg *backgroundWork) resume() {
 	bg.mu.Lock()
 	defer bg.mu.Unlock()
 	bg.paused = false
-	bg.pauseCond.Broadcast()
+	bg.maybeScheduleLocked()
 }
 
 // IsPaused returns true if background work is paused.
@@ -184,76 +260,99 @@ func (bg *backgroundWork) isPaused() bool {
 	return bg.paused
 }
 
-// WaitIfPaused waits if background work is paused.
-// Call this before performing background operations.
-func (bg *backgroundWork) waitIfPaused() {
-	bg.mu.Lock()
-	for bg.paused {
-		bg.pauseCond.Wait()
-	}
-	bg.mu.Unlock()
-}
-
 // MaybeScheduleCompaction signals that compaction may be needed.
 func (bg *backgroundWork) maybeScheduleCompaction() {
-	select {
-	case bg.compactionCh <- struct{}{}:
-	default:
-		// Already signaled
-	}
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	bg.compactionRequested = true
+	bg.maybeScheduleLocked()
 }
 
 // MaybeScheduleFlush signals that flush may be needed.
 func (bg *backgroundWork) maybeScheduleFlush() {
-	select {
-	case bg.flushCh <- struct{}{}:
-	default:
-		// Already signaled
-	}
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	bg.flushRequested = true
+	bg.maybeScheduleLocked()
 }
 
-// backgroundLoop is the main background worker loop.
-func (bg *backgroundWork) backgroundLoop() {
-	defer bg.backgroundDone.Done()
-
-	for {
-		select {
-		case <-bg.shutdownCh:
-			return
-
-		case <-bg.flushCh:
-			bg.doFlushWork()
-
-		case <-bg.compactionCh:
-			bg.doCompactionWork()
-		}
+// maybeScheduleLocked hands requested work to the Env's thread pools,
+// respecting the per-DB flush and compaction limits.
+// REQUIRES: bg.mu is held.
+func (bg *backgroundWork) maybeScheduleLocked() {
+	if bg.shuttingDown || bg.paused {
+		return
+	}
+	if bg.flushRequested && bg.flushScheduled < bg.maxFlushes {
+		bg.flushRequested = false
+		bg.flushScheduled++
+		bg.env.Schedule(bg.backgroundCallFlush, PriorityHigh)
+	}
+	if bg.compactionRequested && bg.compactionScheduled < bg.maxCompactions {
+		bg.compactionRequested = false
+		bg.compactionScheduled++
+		bg.env.Schedule(bg.backgroundCallCompaction, PriorityLow)
 	}
 }
 
-// doFlushWork performs background flush if needed.
-func (bg *backgroundWork) doFlushWork() {
-	// Whitebox [synctest]: barrier at background flush start
-	_ = testutil.SP(testutil.SPBGFlushStart)
+// backgroundCallFlush is the HIGH pool entry point for a flush job.
+func (bg *backgroundWork) backgroundCallFlush() {
+	bg.mu.Lock()
+	if bg.shuttingDown || bg.paused {
+		// Keep the request so resume can reschedule it.
+		bg.flushRequested = bg.flushRequested || !bg.shuttingDown
+		bg.finishJobLocked(&bg.flushScheduled)
+		bg.mu.Unlock()
+		return
+	}
+	bg.runningFlushes++
+	bg.mu.Unlock()
 
-	bg.waitIfPaused()
+	bg.doFlushWork()
+
+	bg.mu.Lock()
+	bg.runningFlushes--
+	bg.finishJobLocked(&bg.flushScheduled)
+	bg.mu.Unlock()
+}
 
+// backgroundCallCompaction is the LOW pool entry point for a compaction job.
+func (bg *backgroundWork) backgroundCallCompaction() {
 	bg.mu.Lock()
-	if bg.flushRunning {
+	if bg.shuttingDown || bg.paused {
+		bg.compactionRequested = bg.compactionRequested || !bg.shuttingDown
+		bg.finishJobLocked(&bg.compactionScheduled)
 		bg.mu.Unlock()
 		return
 	}
-	bg.flushRunning = true
+	bg.runningCompactions++
 	bg.mu.Unlock()
 
-	defer func() {
-		bg.mu.Lock()
-		bg.flushRunning = false
-		bg.mu.Unlock()
-	}()
+	bg.doCompactionWork()
+
+	bg.mu.Lock()
+	bg.runningCompactions--
+	bg.finishJobLocked(&bg.compactionScheduled)
+	bg.mu.Unlock()
+}
+
+// finishJobLocked retires a scheduled job, schedules any work that was
+// waiting for a free slot, and wakes stop().
+// REQUIRES: bg.mu is held.
+func (bg *backgroundWork) finishJobLocked(scheduled *int) {
+	*scheduled--
+	bg.maybeScheduleLocked()
+	bg.jobsDone.Broadcast()
+}
+
+// doFlushWork performs background flush if needed.
+func (bg *backgroundWork) doFlushWork() {
+	// Whitebox [synctest]: barrier at background flush start
+	_ = testutil.SP(testutil.SPBGFlushStart)
 
 	// Check if flush is needed
 	bg.db.mu.Lock()
-	needsFlush := bg.db.imm != nil
+	needsFlush := len(bg.db.imm) >= bg.db.minWriteBufferNumberToMerge()
 	bg.db.mu.Unlock()
 
 	if !needsFlush {
@@ -263,8 +362,8 @@ func (bg *backgroundWork) doFlushWork() {
 	// Whitebox [synctest]: barrier before flush execution
 	_ = testutil.SP(testutil.SPBGFlushExecute)
 
-	// Perform flush
-	err := bg.db.Flush(nil)
+	// Perform flush of the immutable memtable
+	err := bg.db.doFlush()
 	if err != nil {
 		// Record background error for I/O failures
 		bg.db.SetBackgroundError(err)
@@ -283,24 +382,13 @@ func (bg *backgroundWork) doCompactionWork() {
 	// Whitebox [synctest]: barrier at background compaction start
 	_ = testutil.SP(testutil.SPBGCompactionStart)
 
-	bg.waitIfPaused()
-
-	bg.mu.Lock()
-	if bg.compactionRunning {
-		bg.mu.Unlock()
+	// Get current version. No new compactions start once a background
+	// error is set, so a failing compaction is not retried forever.
+	bg.db.mu.RLock()
+	if bg.db.backgroundError != nil {
+		bg.db.mu.RUnlock()
 		return
 	}
-	bg.compactionRunning = true
-	bg.mu.Unlock()
-
-	defer func() {
-		bg.mu.Lock()
-		bg.compactionRunning = false
-		bg.mu.Unlock()
-	}()
-
-	// Get current version
-	bg.db.mu.RLock()
 	v := bg.db.versions.Current()
 	if v != nil {
 		v.Ref()
@@ -320,12 +408,13 @@ func (bg *backgroundWork) doCompactionWork() {
 	// Pick compaction
 	bg.db.mu.Lock()
 	c := bg.picker.PickCompaction(v)
-	if c == nil {
+	if c == nil || bg.conflictsWithRunningLocked(c) {
 		bg.db.mu.Unlock()
 		return
 	}
 	// Mark files as being compacted (under lock to prevent concurrent pick of same files)
 	c.MarkFilesBeingCompacted(true)
+	bg.inProgress[c] = struct{}{}
 	bg.db.mu.Unlock()
 
 	// Whitebox [synctest]: barrier after compaction picked
@@ -335,9 +424,13 @@ func (bg *backgroundWork) doCompactionWork() {
 	defer func() {
 		bg.db.mu.Lock()
 		c.MarkFilesBeingCompacted(false)
+		delete(bg.inProgress, c)
 		bg.db.mu.Unlock()
 	}()
 
+	// Another job may be able to pick a non-conflicting compaction in parallel
+	bg.maybeScheduleCompaction()
+
 	// Whitebox [synctest]: barrier before compaction execution
 	_ = testutil.SP(testutil.SPBGCompactionExecute)
 
@@ -360,11 +453,86 @@ func (bg *backgroundWork) doCompactionWork() {
 	bg.maybeScheduleCompaction()
 }
 
-// executeCompaction runs a compaction job.
+// conflictsWithRunningLocked reports whether c would touch the same key range
+// on the same level as a compaction that is already executing. Only one L0
+// compaction may run at a time, because L0 files overlap each other.
+// REQUIRES: db.mu is held.
+func (bg *backgroundWork) conflictsWithRunningLocked(c *compaction.Compaction) bool {
+	for running := range bg.inProgress {
+		if c.StartLevel() == 0 && running.StartLevel() == 0 {
+			return true
+		}
+		if !bg.sharesLevel(c, running) {
+			continue
+		}
+		if bg.db.cmp.Compare(extractUserKey(c.SmallestKey), extractUserKey(running.LargestKey)) <= 0 &&
+			bg.db.cmp.Compare(extractUserKey(running.SmallestKey), extractUserKey(c.LargestKey)) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesLevel reports whether a and b read or write any common level.
+func (bg *backgroundWork) sharesLevel(a, b *compaction.Compaction) bool {
+	levels := func(c *compaction.Compaction) map[int]bool {
+		m := map[int]bool{c.OutputLevel: true}
+		for _, in := range c.Inputs {
+			m[in.Level] = true
+		}
+		return m
+	}
+	bLevels := levels(b)
+	for level := range levels(a) {
+		if level >= 0 && bLevels[level] {
+			return true
+		}
+	}
+	return false
+}
+
+// compactionResult is what a compaction job wrote.
+type compactionResult struct {
+	outputFiles      []*manifest.FileMetaData
+	numInputRecords  uint64
+	numOutputRecords uint64
+}
+
+// executeCompaction runs a compaction job, notifying Options.Listeners
+// before and after.
 func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
+	listeners := bg.db.options.Listeners
+	if len(listeners) == 0 {
+		_, err := bg.runCompaction(c)
+		return err
+	}
+
+	info := bg.db.newCompactionJobInfo(c)
+	for _, l := range listeners {
+		begin := *info
+		l.OnCompactionBegin(&begin)
+	}
+	start := bg.db.env.NowMicros()
+	result, err := bg.runCompaction(c)
+	info.Elapsed = time.Duration(bg.db.env.NowMicros()-start) * time.Microsecond
+	info.Status = err
+	if result != nil {
+		info.setOutputs(bg.db, c, result)
+	}
+	for _, l := range listeners {
+		l.OnCompactionCompleted(info)
+	}
+	return err
+}
+
+// runCompaction runs a compaction job and installs its outputs.
+func (bg *backgroundWork) runCompaction(c *compaction.Compaction) (*compactionResult, error) {
 	// Handle FIFO deletion compaction (no merge, just delete files)
 	if c.IsDeletionCompaction {
-		return bg.executeDeletionCompaction(c)
+		if err := bg.executeDeletionCompaction(c); err != nil {
+			return nil, err
+		}
+		return &compactionResult{}, nil
 	}
 
 	bg.db.mu.Lock()
@@ -372,6 +540,14 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 	fs := bg.db.fs
 	tableCache := bg.db.tableCache
 	versions := bg.db.versions
+	compressionType := bg.db.options.Compression
+	skipFilters := bg.db.optimizeFiltersForHits(c) && c.IsBottommostLevel(versions.Current())
+	cfID, cfName := compactionColumnFamily(c), DefaultColumnFamilyName
+	if cfd := bg.db.columnFamilies.getByID(cfID); cfd != nil {
+		cfName = cfd.name
+	}
+	blobGC := bg.db.blobGCOptions()
+	snapshots := bg.db.snapshotSequences()
 
 	// Verify all input files still exist before proceeding
 	for _, input := range c.Inputs {
@@ -379,12 +555,15 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 			path := fmt.Sprintf("%s/%06d.sst", dbPath, f.FD.GetNumber())
 			if !fs.Exists(path) {
 				bg.db.mu.Unlock()
-				return fmt.Errorf("input file %d no longer exists", f.FD.GetNumber())
+				return nil, fmt.Errorf("input file %d no longer exists", f.FD.GetNumber())
 			}
 		}
 	}
 	bg.db.mu.Unlock()
 
+	bg.db.logger.Infof("[compact] compacting %d files from L%d to L%d (reason: %v)",
+		c.NumInputFiles(), c.StartLevel(), c.OutputLevel, c.Reason)
+
 	// File number generator
 	nextFileNum := func() uint64 {
 		return versions.NextFileNumber()
@@ -392,13 +571,13 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 
 	// Create and run the compaction job
 	// Use parallel compaction if MaxSubcompactions > 1 and job is large enough
-	var outputFiles []*manifest.FileMetaData
+	result := &compactionResult{}
 	var err error
 
 	// Create rate limiter adapter if configured
 	var rl compaction.RateLimiter
 	if bg.rateLimiter != nil {
-		rl = &rateLimiterAdapter{limiter: bg.rateLimiter}
+		rl = &rateLimiterAdapter{limiter: bg.rateLimiter, stop: bg.stopCh}
 	}
 
 	// Get compaction filter from database options
@@ -424,7 +603,9 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 		mergeOp = &mergeOperatorAdapter{op: bg.db.options.MergeOperator}
 	}
 
-	if bg.maxSubcompactions > 1 && c.NumInputFiles() >= 4 {
+	// Blob relocation is only done by the single-threaded job
+	var blobFiles []uint64
+	if bg.maxSubcompactions > 1 && c.NumInputFiles() >= 4 && blobGC == nil {
 		// Use parallel compaction for larger jobs
 		parallelJob := compaction.NewParallelCompactionJob(
 			c, dbPath, fs, tableCache, nextFileNum, bg.maxSubcompactions,
@@ -433,7 +614,21 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 		if mergeOp != nil {
 			parallelJob.SetMergeOperator(mergeOp)
 		}
-		outputFiles, err = parallelJob.Run()
+		parallelJob.SetVerifyChecksums(bg.db.options.VerifyChecksumsInCompaction)
+		parallelJob.SetCompression(compressionType)
+		parallelJob.SetColumnFamily(cfID, cfName)
+		parallelJob.SetFilterBitsPerKey(bg.db.options.BloomFilterBitsPerKey)
+		parallelJob.SetVerifyCompression(bg.db.options.VerifyCompression)
+		parallelJob.SetSkipFilters(skipFilters)
+		if pe := bg.db.options.PrefixExtractor; pe != nil {
+			parallelJob.SetPrefixExtractor(pe.Name(), filterPrefix(pe))
+		}
+		parallelJob.SetUserComparator(bg.db.comparator.Compare)
+		parallelJob.SetBlobResolver(bg.db.resolveBlobIndex)
+		parallelJob.SetSnapshots(snapshots)
+		result.outputFiles, err = parallelJob.Run()
+		stats := parallelJob.GetStats()
+		result.numInputRecords, result.numOutputRecords = stats.NumInputRecords, stats.NumOutputRecords
 	} else {
 		// Use single-threaded compaction with rate limiter
 		job := compaction.NewCompactionJobWithRateLimiter(
@@ -445,10 +640,27 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 		if mergeOp != nil {
 			job.SetMergeOperator(mergeOp)
 		}
-		outputFiles, err = job.Run()
+		job.SetVerifyChecksums(bg.db.options.VerifyChecksumsInCompaction)
+		job.SetCompression(compressionType)
+		job.SetColumnFamily(cfID, cfName)
+		job.SetFilterBitsPerKey(bg.db.options.BloomFilterBitsPerKey)
+		job.SetVerifyCompression(bg.db.options.VerifyCompression)
+		job.SetSkipFilters(skipFilters)
+		if pe := bg.db.options.PrefixExtractor; pe != nil {
+			job.SetPrefixExtractor(pe.Name(), filterPrefix(pe))
+		}
+		job.SetUserComparator(bg.db.comparator.Compare)
+		job.SetBlobResolver(bg.db.resolveBlobIndex)
+		job.SetSnapshots(snapshots)
+		if blobGC != nil {
+			job.SetBlobGC(*blobGC)
+		}
+		result.outputFiles, err = job.Run()
+		result.numInputRecords, result.numOutputRecords = job.RecordStats()
+		blobFiles = job.BlobFiles()
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Whitebox [crashtest]: crash after SST write — output exists, manifest not updated
@@ -466,12 +678,16 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 
 	err = versions.LogAndApply(c.Edit)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Recalculate write stall condition after compaction
 	bg.db.recalculateWriteStall()
 
+	// Track the blob files written by relocation, and drop the ones it freed
+	bg.db.registerBlobFiles(blobFiles)
+	bg.db.deleteObsoleteBlobFiles()
+
 	// Evict input files from table cache
 	for _, input := range c.Inputs {
 		for _, f := range input.Files {
@@ -479,11 +695,31 @@ func (bg *backgroundWork) executeCompaction(c *compaction.Compaction) error {
 		}
 	}
 
-	// Compaction complete
-	// TODO: Add proper statistics/metrics tracking instead of logging
-	_ = len(outputFiles)
+	bg.db.logger.Infof("[compact] compacted %d files to %d files at L%d",
+		c.NumInputFiles(), len(result.outputFiles), c.OutputLevel)
 
-	return nil
+	return result, nil
+}
+
+// compactionColumnFamily returns the ID of the column family that c
+// compacts.
+func compactionColumnFamily(c *compaction.Compaction) uint32 {
+	if len(c.Inputs) > 0 && len(c.Inputs[0].Files) > 0 {
+		return c.Inputs[0].Files[0].ColumnFamilyID
+	}
+	return DefaultColumnFamilyID
+}
+
+// optimizeFiltersForHits returns the OptimizeFiltersForHits option of the
+// column family that c compacts.
+// REQUIRES: db.mu is held.
+func (db *dbImpl) optimizeFiltersForHits(c *compaction.Compaction) bool {
+	cfID := compactionColumnFamily(c)
+	if cfID == DefaultColumnFamilyID {
+		return db.options.OptimizeFiltersForHits
+	}
+	cfd := db.columnFamilies.getByID(cfID)
+	return cfd != nil && cfd.options.OptimizeFiltersForHits
 }
 
 // executeDeletionCompaction handles FIFO-style deletion compaction.
@@ -519,39 +755,36 @@ func (bg *backgroundWork) executeDeletionCompaction(c *compaction.Compaction) er
 func (bg *backgroundWork) isCompactionPending() bool {
 	bg.mu.Lock()
 	defer bg.mu.Unlock()
+	return bg.compactionRequested || bg.compactionScheduled > bg.runningCompactions
+}
 
-	// Check if there's pending work
-	select {
-	case <-bg.compactionCh:
-		// Put it back
-		select {
-		case bg.compactionCh <- struct{}{}:
-		default:
-		}
+// hasPendingWork returns true if any flush or compaction is running or
+// waiting to run. Requests held back by a pause count only when running.
+func (bg *backgroundWork) hasPendingWork() bool {
+	bg.mu.Lock()
+	defer bg.mu.Unlock()
+	if bg.runningFlushes > 0 || bg.runningCompactions > 0 {
 		return true
-	default:
+	}
+	if bg.paused {
 		return false
 	}
+	return bg.flushRequested || bg.compactionRequested ||
+		bg.flushScheduled > 0 || bg.compactionScheduled > 0
 }
 
 // NumRunningFlushes returns the number of currently running flush operations.
 func (bg *backgroundWork) numRunningFlushes() int {
 	bg.mu.Lock()
 	defer bg.mu.Unlock()
-	if bg.flushRunning {
-		return 1
-	}
-	return 0
+	return bg.runningFlushes
 }
 
 // NumRunningCompactions returns the number of currently running compaction operations.
 func (bg *backgroundWork) numRunningCompactions() int {
 	bg.mu.Lock()
 	defer bg.mu.Unlock()
-	if bg.compactionRunning {
-		return 1
-	}
-	return 0
+	return bg.runningCompactions
 }
 
 // NumBackgroundErrors returns the number of background errors that have occurred.
@@ -569,13 +802,22 @@ func (bg *backgroundWork) incrementBackgroundErrors() {
 }
 
 // rateLimiterAdapter adapts the db.RateLimiter interface to compaction.RateLimiter.
+// A GenericRateLimiter shared by several DBs keeps serving the others when
+// one closes, so instead of shutting the limiter down, the requests of a
+// closing DB give up waiting once stop is closed.
 type rateLimiterAdapter struct {
 	limiter RateLimiter
+	stop    <-chan struct{}
 }
 
 // Request implements compaction.RateLimiter.
 func (a *rateLimiterAdapter) Request(bytes int64, priority int) {
-	if a.limiter != nil {
-		a.limiter.Request(bytes, IOPriority(priority))
+	if a.limiter == nil {
+		return
+	}
+	if rl, ok := a.limiter.(*GenericRateLimiter); ok {
+		rl.request(bytes, IOPriority(priority), a.stop)
+		return
 	}
+	a.limiter.Request(bytes, IOPriority(priority))
 }
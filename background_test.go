@@ -4,8 +4,14 @@ package rockyardkv
 
 import (
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/aalhour/rockyardkv/internal/compaction"
+	"github.com/aalhour/rockyardkv/internal/version"
 )
 
 // TestBackgroundCompactionTrigger tests that compaction is triggered after flush.
@@ -365,3 +371,343 @@ func TestBackgroundWorkScheduling(t *testing.T) {
 
 	// These calls should not cause any errors
 }
+
+// blockingCompactionFilter blocks the first Filter call until release is closed.
+type blockingCompactionFilter struct {
+	BaseCompactionFilter
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (f *blockingCompactionFilter) Filter(level int, key, oldValue []byte) (CompactionFilterDecision, []byte) {
+	f.once.Do(func() {
+		close(f.started)
+		<-f.release
+	})
+	return FilterKeep, nil
+}
+
+// TestFlushNotBlockedByCompaction verifies that flushes run in their own
+// thread pool and complete while the only compaction slot is busy.
+func TestFlushNotBlockedByCompaction(t *testing.T) {
+	filter := &blockingCompactionFilter{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Env = NewMockEnv(time.Now())
+	opts.MaxBackgroundFlushes = 1
+	opts.MaxBackgroundCompactions = 1
+	opts.Level0FileNumCompactionTrigger = 4
+	opts.CompactionFilter = filter
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+	defer close(filter.release)
+
+	for i := range 4 {
+		if err := database.Put(nil, []byte{byte('a' + i)}, []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	select {
+	case <-filter.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("compaction did not start")
+	}
+	if got, _ := database.GetProperty(PropertyNumRunningCompactions); got != "1" {
+		t.Fatalf("running compactions = %s, want 1", got)
+	}
+
+	// The compaction is stuck; a background flush must still make progress.
+	if err := database.Put(nil, []byte("z"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	start := time.Now()
+	if err := database.Flush(&FlushOptions{Wait: false}); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for {
+		if got, _ := database.GetProperty(PropertyNumImmutableMemTable); got == "0" {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("flush did not complete while compaction was running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, _ := database.GetProperty(PropertyNumRunningCompactions); got != "1" {
+		t.Errorf("running compactions after flush = %s, want 1", got)
+	}
+}
+
+func TestBgJobLimits(t *testing.T) {
+	tests := []struct {
+		jobs, flushes, compactions   int
+		wantFlushes, wantCompactions int
+	}{
+		{2, -1, -1, 1, 1},
+		{4, -1, -1, 1, 3},
+		{8, -1, -1, 2, 6},
+		{16, -1, -1, 4, 12},
+		{0, -1, -1, 1, 1},
+		{8, 3, -1, 3, 1},
+		{8, -1, 5, 1, 5},
+		{8, 2, 4, 2, 4},
+	}
+	for _, tt := range tests {
+		flushes, compactions := bgJobLimits(tt.jobs, tt.flushes, tt.compactions)
+		if flushes != tt.wantFlushes || compactions != tt.wantCompactions {
+			t.Errorf("bgJobLimits(%d, %d, %d) = (%d, %d), want (%d, %d)",
+				tt.jobs, tt.flushes, tt.compactions, flushes, compactions, tt.wantFlushes, tt.wantCompactions)
+		}
+	}
+}
+
+// compactionGate blocks compactions at their first key until released and
+// counts how many are blocked at once.
+type compactionGate struct {
+	release chan struct{}
+	opened  bool // protected by gatedFilterFactory.mu
+	active  atomic.Int32
+	peak    atomic.Int32
+}
+
+func (g *compactionGate) enter() {
+	n := g.active.Add(1)
+	for {
+		p := g.peak.Load()
+		if n <= p || g.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	<-g.release
+	g.active.Add(-1)
+}
+
+// gatedFilterFactory hands each compaction a filter bound to the current gate.
+type gatedFilterFactory struct {
+	mu   sync.Mutex
+	gate *compactionGate
+}
+
+func (f *gatedFilterFactory) Name() string { return "gatedFilterFactory" }
+
+func (f *gatedFilterFactory) CreateCompactionFilter(CompactionFilterContext) CompactionFilter {
+	return &gatedFilter{gate: f.current()}
+}
+
+func (f *gatedFilterFactory) current() *compactionGate {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gate
+}
+
+// reset releases compactions blocked on the current gate and installs a new one.
+func (f *gatedFilterFactory) reset(open bool) *compactionGate {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.gate != nil && !f.gate.opened {
+		close(f.gate.release)
+	}
+	f.gate = &compactionGate{release: make(chan struct{}), opened: open}
+	if open {
+		close(f.gate.release)
+	}
+	return f.gate
+}
+
+type gatedFilter struct {
+	BaseCompactionFilter
+	gate *compactionGate
+	once sync.Once
+}
+
+func (f *gatedFilter) Filter(level int, key, oldValue []byte) (CompactionFilterDecision, []byte) {
+	f.once.Do(f.gate.enter)
+	return FilterKeep, nil
+}
+
+// waitForActive waits until exactly want compactions are blocked on gate.
+func waitForActive(t *testing.T, gate *compactionGate, want int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for gate.active.Load() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("concurrent compactions = %d, want %d", gate.active.Load(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestSetMaxBackgroundJobsAdjustsConcurrency verifies that changing
+// max_background_jobs at runtime changes how many compactions run at once.
+func TestSetMaxBackgroundJobsAdjustsConcurrency(t *testing.T) {
+	factory := &gatedFilterFactory{}
+	factory.reset(true)
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Env = NewMockEnv(time.Now())
+	opts.MaxBackgroundJobs = 2 // 1 flush, 1 compaction
+	opts.Level0FileNumCompactionTrigger = 2
+	opts.CompactionFilterFactory = factory
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+	impl := database.(*dbImpl)
+
+	// Build L1 out of disjoint files: every pair of flushes covers its own
+	// key prefix and is compacted into a separate L1 file.
+	const numRanges = 12
+	for r := range numRanges {
+		for f := range 2 {
+			for i := range 10 {
+				key := fmt.Appendf(nil, "r%02d-%d-%02d", r, f, i)
+				if err := database.Put(nil, key, []byte("value")); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+			}
+			if err := database.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+		if err := database.WaitForCompact(nil); err != nil {
+			t.Fatalf("WaitForCompact failed: %v", err)
+		}
+	}
+	if got, _ := database.GetProperty(PropertyNumFilesAtLevelPrefix + "1"); got != fmt.Sprint(numRanges) {
+		t.Fatalf("L1 files = %s, want %d", got, numRanges)
+	}
+
+	// Shrink the L1 target so every L1 file wants to move down, and block
+	// compactions so their concurrency can be observed.
+	impl.bgWork.picker.(*compaction.LeveledCompactionPicker).MaxBytesForLevelBase = 1
+	gate := factory.reset(false)
+	defer func() { factory.reset(true) }()
+	impl.bgWork.maybeScheduleCompaction()
+
+	waitForActive(t, gate, 1)
+	time.Sleep(50 * time.Millisecond)
+	if got := gate.peak.Load(); got != 1 {
+		t.Fatalf("concurrent compactions with max_background_jobs=2 = %d, want 1", got)
+	}
+
+	// 8 jobs leave room for 6 compactions.
+	if err := database.SetDBOptions(map[string]string{"max_background_jobs": "8"}); err != nil {
+		t.Fatalf("SetDBOptions failed: %v", err)
+	}
+	waitForActive(t, gate, 6)
+
+	// Lowering the limit lets running compactions finish but admits only one
+	// at a time afterwards.
+	if err := database.SetDBOptions(map[string]string{"max_background_jobs": "2"}); err != nil {
+		t.Fatalf("SetDBOptions failed: %v", err)
+	}
+	gate = factory.reset(false)
+	waitForActive(t, gate, 1)
+	time.Sleep(50 * time.Millisecond)
+	if got := gate.peak.Load(); got != 1 {
+		t.Errorf("concurrent compactions after lowering max_background_jobs = %d, want 1", got)
+	}
+
+	if err := database.SetDBOptions(map[string]string{"max_background_jobs": "0"}); err == nil {
+		t.Error("SetDBOptions accepted max_background_jobs=0")
+	}
+}
+
+// recordingPicker records the compactions picked by the wrapped picker.
+type recordingPicker struct {
+	compaction.CompactionPicker
+
+	mu     sync.Mutex
+	picked []*compaction.Compaction
+}
+
+func (p *recordingPicker) PickCompaction(v *version.Version) *compaction.Compaction {
+	c := p.CompactionPicker.PickCompaction(v)
+	if c != nil {
+		p.mu.Lock()
+		p.picked = append(p.picked, c)
+		p.mu.Unlock()
+	}
+	return c
+}
+
+// TestMaxCompactionBytesSplitsCompactions verifies that a small
+// MaxCompactionBytes turns what would be a single L0 compaction into several
+// smaller ones that together preserve the latest value of every key.
+func TestMaxCompactionBytesSplitsCompactions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 4
+	opts.MaxCompactionBytes = 1 // Only the oldest L0 file fits
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+	impl := database.(*dbImpl)
+
+	if err := database.WaitForCompact(nil); err != nil {
+		t.Fatalf("WaitForCompact failed: %v", err)
+	}
+	picker := &recordingPicker{CompactionPicker: impl.bgWork.picker}
+	impl.bgWork.mu.Lock()
+	impl.bgWork.picker = picker
+	impl.bgWork.mu.Unlock()
+
+	// Every flush overwrites the same keys, so all L0 files overlap
+	const numFlushes = 8
+	for f := range numFlushes {
+		for i := range 50 {
+			key := fmt.Appendf(nil, "key%03d", i)
+			if err := database.Put(nil, key, fmt.Appendf(nil, "value%d", f)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	if err := database.WaitForCompact(nil); err != nil {
+		t.Fatalf("WaitForCompact failed: %v", err)
+	}
+
+	picker.mu.Lock()
+	picked := picker.picked
+	picker.mu.Unlock()
+	if len(picked) < 2 {
+		t.Fatalf("compactions = %d, want at least 2", len(picked))
+	}
+	for i, c := range picked {
+		if c.StartLevel() == 0 && len(c.Inputs[0].Files) != 1 {
+			t.Errorf("compaction %d: L0 inputs = %d, want 1", i, len(c.Inputs[0].Files))
+		}
+	}
+
+	for i := range 50 {
+		key := fmt.Appendf(nil, "key%03d", i)
+		got, err := database.Get(nil, key)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", key, err)
+		}
+		if want := fmt.Sprintf("value%d", numFlushes-1); string(got) != want {
+			t.Errorf("Get(%s) = %s, want %s", key, got, want)
+		}
+	}
+}
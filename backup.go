@@ -8,6 +8,7 @@ package rockyardkv
 // - Incremental backups (shared SST files between backups)
 // - Backup listing and deletion
 // - Restore to a new location
+// - Checksum verification of backed-up files
 //
 // Reference: RocksDB v10.7.5 utilities/backup/backup_engine.cc
 
@@ -15,11 +16,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"time"
+
+	"github.com/aalhour/rockyardkv/internal/checksum"
 )
 
 // BackupEngine manages database backups.
@@ -45,6 +49,10 @@ type backupMeta struct {
 	LogFiles     []string `json:"log_files"`
 	SequenceNum  uint64   `json:"sequence_num"`
 	TotalSize    int64    `json:"total_size"`
+
+	// FileChecksums holds the crc32c of every backed-up file by name.
+	// Backups created before checksums were recorded have none.
+	FileChecksums map[string]uint32 `json:"file_checksums,omitempty"`
 }
 
 // CreateBackupEngine creates a BackupEngine for the given database.
@@ -88,6 +96,11 @@ func (be *BackupEngine) CreateNewBackup() (*BackupInfo, error) {
 
 	be.db.logger.Infof("[backup] creating backup %d", backupID)
 
+	// Write buffered WAL records so that the copied WAL holds every write
+	if err := be.db.FlushWAL(false); err != nil {
+		return nil, fmt.Errorf("db: failed to flush WAL: %w", err)
+	}
+
 	// Hold lock to get consistent state
 	be.db.mu.Lock()
 
@@ -116,6 +129,7 @@ func (be *BackupEngine) CreateNewBackup() (*BackupInfo, error) {
 
 	sharedDir := filepath.Join(be.backupDir, "shared")
 	var totalSize int64
+	checksums := make(map[string]uint32)
 
 	// Copy/link SST files to shared directory
 	for _, sst := range sstFiles {
@@ -130,6 +144,14 @@ func (be *BackupEngine) CreateNewBackup() (*BackupInfo, error) {
 			}
 		}
 
+		// Checksum the source, so that a shared copy that has already
+		// rotted is not recorded as intact
+		crc, err := fileChecksum(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("db: failed to checksum SST file %s: %w", sst, err)
+		}
+		checksums[sst] = crc
+
 		// Count size
 		if info, err := os.Stat(dstPath); err == nil {
 			totalSize += info.Size()
@@ -148,6 +170,11 @@ func (be *BackupEngine) CreateNewBackup() (*BackupInfo, error) {
 	if err := copyFile(srcManifest, dstManifest); err != nil {
 		return nil, fmt.Errorf("db: failed to backup MANIFEST: %w", err)
 	}
+	crc, err := fileChecksum(dstManifest)
+	if err != nil {
+		return nil, fmt.Errorf("db: failed to checksum MANIFEST: %w", err)
+	}
+	checksums[manifestFile] = crc
 	if info, err := os.Stat(dstManifest); err == nil {
 		totalSize += info.Size()
 	}
@@ -160,6 +187,11 @@ func (be *BackupEngine) CreateNewBackup() (*BackupInfo, error) {
 		if err := copyFile(srcLog, dstLog); err != nil {
 			return nil, fmt.Errorf("db: failed to backup WAL: %w", err)
 		}
+		crc, err := fileChecksum(dstLog)
+		if err != nil {
+			return nil, fmt.Errorf("db: failed to checksum WAL: %w", err)
+		}
+		checksums[logFile] = crc
 		logFiles = append(logFiles, logFile)
 		if info, err := os.Stat(dstLog); err == nil {
 			totalSize += info.Size()
@@ -168,13 +200,14 @@ func (be *BackupEngine) CreateNewBackup() (*BackupInfo, error) {
 
 	// Create backup metadata
 	meta := &backupMeta{
-		ID:           backupID,
-		Timestamp:    time.Now().Unix(),
-		Files:        sstFiles,
-		ManifestFile: manifestFile,
-		LogFiles:     logFiles,
-		SequenceNum:  seqNum,
-		TotalSize:    totalSize,
+		ID:            backupID,
+		Timestamp:     time.Now().Unix(),
+		Files:         sstFiles,
+		ManifestFile:  manifestFile,
+		LogFiles:      logFiles,
+		SequenceNum:   seqNum,
+		TotalSize:     totalSize,
+		FileChecksums: checksums,
 	}
 
 	// Write metadata file
@@ -256,16 +289,9 @@ func (be *BackupEngine) RestoreDBFromBackup(backupID uint32, restoreDir string)
 		return err
 	}
 
-	// Read backup metadata
-	metaPath := filepath.Join(be.backupDir, "meta", fmt.Sprintf("backup_%d.json", backupID))
-	data, err := os.ReadFile(metaPath)
+	meta, err := be.readBackupMeta(backupID)
 	if err != nil {
-		return fmt.Errorf("db: backup %d not found: %w", backupID, err)
-	}
-
-	var meta backupMeta
-	if err := json.Unmarshal(data, &meta); err != nil {
-		return fmt.Errorf("db: failed to parse backup metadata: %w", err)
+		return err
 	}
 
 	// Create restore directory
@@ -328,6 +354,81 @@ func (be *BackupEngine) RestoreDBFromBackup(backupID uint32, restoreDir string)
 	return nil
 }
 
+// VerifyBackup checks that every file of a backup is present and still has
+// the checksum recorded when the backup was created, without restoring it.
+// A file that has changed is reported as ErrCorruption.
+//
+// Reference: RocksDB v10.7.5 utilities/backup/backup_engine.cc (VerifyBackup)
+func (be *BackupEngine) VerifyBackup(backupID uint32) error {
+	meta, err := be.readBackupMeta(backupID)
+	if err != nil {
+		return err
+	}
+
+	sharedDir := filepath.Join(be.backupDir, "shared")
+	backupMetaDir := filepath.Join(be.backupDir, "meta", fmt.Sprintf("%d", backupID))
+
+	var names, paths []string
+	for _, sst := range meta.Files {
+		names = append(names, sst)
+		paths = append(paths, filepath.Join(sharedDir, sst))
+	}
+	for _, name := range append([]string{meta.ManifestFile}, meta.LogFiles...) {
+		names = append(names, name)
+		paths = append(paths, filepath.Join(backupMetaDir, name))
+	}
+
+	for i, name := range names {
+		crc, err := fileChecksum(paths[i])
+		if err != nil {
+			return fmt.Errorf("db: backup %d: failed to read %s: %w", backupID, name, err)
+		}
+		want, ok := meta.FileChecksums[name]
+		if ok && crc != want {
+			return fmt.Errorf("%w: backup %d: %s has checksum %08x, want %08x",
+				ErrCorruption, backupID, name, crc, want)
+		}
+	}
+	return nil
+}
+
+// readBackupMeta reads the metadata of a backup.
+func (be *BackupEngine) readBackupMeta(backupID uint32) (*backupMeta, error) {
+	metaPath := filepath.Join(be.backupDir, "meta", fmt.Sprintf("backup_%d.json", backupID))
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, fmt.Errorf("db: backup %d not found: %w", backupID, err)
+	}
+
+	var meta backupMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("db: failed to parse backup metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// fileChecksum returns the crc32c of the contents of a file.
+func fileChecksum(path string) (uint32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var crc uint32
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		crc = checksum.Extend(crc, buf[:n])
+		if err == io.EOF {
+			return crc, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
 // DeleteBackup deletes a backup.
 func (be *BackupEngine) DeleteBackup(backupID uint32) error {
 	// Read backup metadata to know which files to check
@@ -3,6 +3,7 @@ package rockyardkv_test
 // backup_test.go implements tests for backup.
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -383,3 +384,65 @@ func TestBackupEngineIncrementalBackup(t *testing.T) {
 		}
 	}
 }
+
+func TestBackupEngineVerifyBackup(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "db")
+	backupPath := filepath.Join(dir, "backups")
+
+	opts := rockyardkv.DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := rockyardkv.Open(dbPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	for i := range 100 {
+		key := fmt.Appendf(nil, "key%03d", i)
+		if err := database.Put(nil, key, []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	backupEngine, err := rockyardkv.CreateBackupEngine(database, backupPath)
+	if err != nil {
+		t.Fatalf("CreateBackupEngine failed: %v", err)
+	}
+	defer backupEngine.Close()
+
+	info, err := backupEngine.CreateNewBackup()
+	if err != nil {
+		t.Fatalf("CreateNewBackup failed: %v", err)
+	}
+
+	// An intact backup passes
+	if err := backupEngine.VerifyBackup(info.ID); err != nil {
+		t.Fatalf("VerifyBackup of intact backup failed: %v", err)
+	}
+	if err := backupEngine.VerifyBackup(info.ID + 1); err == nil {
+		t.Error("VerifyBackup of a missing backup succeeded")
+	}
+
+	// Flip one byte of a backed-up SST
+	ssts, err := filepath.Glob(filepath.Join(backupPath, "shared", "*.sst"))
+	if err != nil || len(ssts) == 0 {
+		t.Fatalf("no backed-up SST files: %v", err)
+	}
+	data, err := os.ReadFile(ssts[0])
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(ssts[0], data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := backupEngine.VerifyBackup(info.ID); !errors.Is(err, rockyardkv.ErrCorruption) {
+		t.Errorf("VerifyBackup of corrupted backup = %v, want ErrCorruption", err)
+	}
+}
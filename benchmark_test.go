@@ -5,7 +5,11 @@ package rockyardkv
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aalhour/rockyardkv/vfs"
 )
 
 // =============================================================================
@@ -40,6 +44,50 @@ func BenchmarkDBPutSequential(b *testing.B) {
 	b.ReportMetric(float64(b.N), "ops")
 }
 
+// BenchmarkSortedBulkLoad compares loading sorted keys with the skiplist
+// and the vector memtable, including the flush.
+func BenchmarkSortedBulkLoad(b *testing.B) {
+	const n = 100000
+	value := make([]byte, 100)
+	for _, bm := range []struct {
+		name    string
+		factory MemTableRepFactory
+	}{
+		{"skiplist", nil},
+		{"vector", VectorMemTableFactory{}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				opts := DefaultOptions()
+				opts.CreateIfMissing = true
+				opts.MemtableFactory = bm.factory
+				db, err := Open(b.TempDir(), opts)
+				if err != nil {
+					b.Fatalf("Open() error = %v", err)
+				}
+				wo := DefaultWriteOptions()
+				wo.DisableWAL = true
+				b.StartTimer()
+
+				for i := range n {
+					if err := db.Put(wo, fmt.Appendf(nil, "key%016d", i), value); err != nil {
+						b.Fatalf("Put error: %v", err)
+					}
+				}
+				if err := db.Flush(nil); err != nil {
+					b.Fatalf("Flush error: %v", err)
+				}
+
+				b.StopTimer()
+				db.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/key")
+		})
+	}
+}
+
 func BenchmarkDBPutRandom(b *testing.B) {
 	dir := b.TempDir()
 	opts := DefaultOptions()
@@ -664,3 +712,246 @@ func BenchmarkTransactionConflict(b *testing.B) {
 	})
 	b.StopTimer()
 }
+
+// BenchmarkPessimisticTransactionStripes runs concurrent pessimistic
+// transactions that each lock a few random keys of many, with the lock
+// manager sharded into 1 and 16 stripes.
+func BenchmarkPessimisticTransactionStripes(b *testing.B) {
+	for _, stripes := range []int{1, 16} {
+		b.Run(fmt.Sprintf("stripes=%d", stripes), func(b *testing.B) {
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			txnDBOpts := DefaultTransactionDBOptions()
+			txnDBOpts.NumStripes = stripes
+			txnDB, err := OpenTransactionDB(b.TempDir(), opts, txnDBOpts)
+			if err != nil {
+				b.Fatalf("OpenTransactionDB() error = %v", err)
+			}
+			defer txnDB.Close()
+
+			const numKeys = 100000
+			keys := make([][]byte, numKeys)
+			for i := range keys {
+				keys[i] = fmt.Appendf(nil, "key%016d", i)
+			}
+			txnOpts := DefaultPessimisticTransactionOptions()
+			txnOpts.SetSnapshot = false
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					txn := txnDB.BeginTransaction(txnOpts, nil)
+					for range 4 {
+						_, _ = txn.GetForUpdate(keys[rng.Intn(numKeys)], true)
+					}
+					_ = txn.Rollback()
+				}
+			})
+			b.StopTimer()
+		})
+	}
+}
+
+// =============================================================================
+// Size Approximation Benchmarks
+// =============================================================================
+
+// BenchmarkGetApproximateSizes samples many ranges across files whose readers
+// do not all fit in the table cache. "first" drops the cached size indexes
+// before every call, "repeated" reuses them; index-reads/op counts the index
+// blocks read from disk per call.
+func BenchmarkGetApproximateSizes(b *testing.B) {
+	dir := b.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	opts.MaxOpenFiles = 2
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		b.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+	impl := db.(*dbImpl)
+
+	// Every flushed file spans the whole key space, so ranges cut into them
+	const numFiles = 8
+	value := make([]byte, 100)
+	for f := range numFiles {
+		for i := f; i < 8000; i += numFiles {
+			if err := db.Put(nil, fmt.Appendf(nil, "key%06d", i), value); err != nil {
+				b.Fatalf("Put error: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			b.Fatalf("Flush error: %v", err)
+		}
+	}
+
+	ranges := make([]Range, 64)
+	for i := range ranges {
+		ranges[i] = Range{
+			Start: fmt.Appendf(nil, "key%06d", i*120),
+			Limit: fmt.Appendf(nil, "key%06d", i*120+60),
+		}
+	}
+
+	for _, cold := range []bool{true, false} {
+		name := "repeated"
+		if cold {
+			name = "first"
+		}
+		b.Run(name, func(b *testing.B) {
+			SetPerfLevel(PerfLevelEnableCount)
+			defer SetPerfLevel(PerfLevelDisable)
+			ResetPerfContext()
+
+			for b.Loop() {
+				if cold {
+					b.StopTimer()
+					v := impl.versions.Current()
+					for level := range v.NumLevels() {
+						for _, f := range v.Files(level) {
+							impl.tableCache.Evict(f.FD.GetNumber())
+						}
+					}
+					b.StartTimer()
+				}
+				if _, err := db.GetApproximateSizes(ranges, SizeApproximationIncludeFiles); err != nil {
+					b.Fatalf("GetApproximateSizes error: %v", err)
+				}
+			}
+			b.ReportMetric(float64(GetPerfContext().IndexBlockReadCount)/float64(b.N), "index-reads/op")
+		})
+	}
+}
+
+// =============================================================================
+// Async IO Benchmarks
+// =============================================================================
+
+// slowReadFS adds a fixed latency to every read of an SST file, standing in
+// for remote or otherwise slow storage.
+type slowReadFS struct {
+	vfs.FS
+	latency time.Duration
+}
+
+func (fs *slowReadFS) OpenRandomAccess(name string) (vfs.RandomAccessFile, error) {
+	f, err := fs.FS.OpenRandomAccess(name)
+	if err != nil || !strings.HasSuffix(name, ".sst") {
+		return f, err
+	}
+	return &slowReadFile{RandomAccessFile: f, latency: fs.latency}, nil
+}
+
+type slowReadFile struct {
+	vfs.RandomAccessFile
+	latency time.Duration
+}
+
+func (f *slowReadFile) ReadAt(p []byte, off int64) (int, error) {
+	time.Sleep(f.latency)
+	return f.RandomAccessFile.ReadAt(p, off)
+}
+
+// BenchmarkIteratorSeekAsyncIO seeks across 8 overlapping L0 files on
+// storage with 200µs reads. With AsyncIO the files' block reads overlap, so
+// a seek costs about one read instead of one per file.
+func BenchmarkIteratorSeekAsyncIO(b *testing.B) {
+	dir := b.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 16 // keep the files in L0
+	opts.FS = &slowReadFS{FS: vfs.Default(), latency: 200 * time.Microsecond}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		b.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	const numFiles = 8
+	value := make([]byte, 100)
+	for f := range numFiles {
+		for i := f; i < 8000; i += numFiles {
+			if err := db.Put(nil, fmt.Appendf(nil, "key%06d", i), value); err != nil {
+				b.Fatalf("Put error: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			b.Fatalf("Flush error: %v", err)
+		}
+	}
+
+	for _, asyncIO := range []bool{false, true} {
+		b.Run(fmt.Sprintf("async=%v", asyncIO), func(b *testing.B) {
+			readOpts := DefaultReadOptions()
+			readOpts.FillCache = false
+			readOpts.AsyncIO = asyncIO
+			iter := db.NewIterator(readOpts)
+			defer iter.Close()
+
+			i := 0
+			for b.Loop() {
+				iter.Seek(fmt.Appendf(nil, "key%06d", (i*7919)%8000))
+				if !iter.Valid() {
+					b.Fatalf("Seek found no key: %v", iter.Error())
+				}
+				i++
+			}
+		})
+	}
+}
+
+// BenchmarkMultiGetAsyncIO looks up 64 keys spread across 8 overlapping L0
+// files on storage with 200µs reads. With AsyncIO the files are searched
+// concurrently instead of one key and file after the other.
+func BenchmarkMultiGetAsyncIO(b *testing.B) {
+	dir := b.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 16 // keep the files in L0
+	opts.FS = &slowReadFS{FS: vfs.Default(), latency: 200 * time.Microsecond}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		b.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	const numFiles = 8
+	value := make([]byte, 100)
+	for f := range numFiles {
+		for i := f; i < 8000; i += numFiles {
+			if err := db.Put(nil, fmt.Appendf(nil, "key%06d", i), value); err != nil {
+				b.Fatalf("Put error: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			b.Fatalf("Flush error: %v", err)
+		}
+	}
+
+	keys := make([][]byte, 64)
+	for i := range keys {
+		keys[i] = fmt.Appendf(nil, "key%06d", (i*7919)%8000)
+	}
+
+	for _, asyncIO := range []bool{false, true} {
+		b.Run(fmt.Sprintf("async=%v", asyncIO), func(b *testing.B) {
+			readOpts := DefaultReadOptions()
+			readOpts.FillCache = false
+			readOpts.AsyncIO = asyncIO
+			for b.Loop() {
+				_, errs := db.MultiGet(readOpts, keys)
+				for _, err := range errs {
+					if err != nil {
+						b.Fatalf("MultiGet error: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package rockyardkv
+
+// blobdb_test.go implements tests for integrated BlobDB.
+//
+// Reference: RocksDB v10.7.5
+//   - db/blob/db_blob_basic_test.cc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/table"
+)
+
+func TestIntegratedBlobDB(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.EnableBlobFiles = true
+	opts.MinBlobSize = 100
+	opts.BlobFileSize = 4096
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	const numKeys = 50
+	largeValue := func(i int) []byte { return bytes.Repeat(fmt.Appendf(nil, "%03d", i), 100) }
+	smallValue := func(i int) []byte { return fmt.Appendf(nil, "small%03d", i) }
+
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "large%03d", i), largeValue(i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := db.Put(nil, fmt.Appendf(nil, "small%03d", i), smallValue(i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// Large values must be separated, rolling over several blob files
+	blobFiles, _ := filepath.Glob(filepath.Join(dir, "*.blob"))
+	if len(blobFiles) < 2 {
+		t.Errorf("Expected several blob files, got %d", len(blobFiles))
+	}
+
+	// Only large values are replaced by blob references in the SST
+	impl := db.(*dbImpl)
+	files := db.GetLiveFilesMetaData()
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 SST, got %d", len(files))
+	}
+	file, err := os.Open(impl.sstFilePath(files[0].FileNumber))
+	if err != nil {
+		t.Fatalf("Failed to open SST: %v", err)
+	}
+	stat, _ := file.Stat()
+	reader, err := table.Open(&compatFileWrapper{f: file, size: stat.Size()}, table.ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+	iter := reader.NewIterator()
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		userKey := dbformat.ExtractUserKey(iter.Key())
+		valueType := dbformat.ExtractValueType(iter.Key())
+		wantType := dbformat.TypeValue
+		if bytes.HasPrefix(userKey, []byte("large")) {
+			wantType = dbformat.TypeBlobIndex
+		}
+		if valueType != wantType {
+			t.Errorf("Key %q: value type = %v, want %v", userKey, valueType, wantType)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("SST iteration failed: %v", err)
+	}
+	_ = file.Close()
+
+	verify := func(db DB) {
+		t.Helper()
+		for i := range numKeys {
+			got, err := db.Get(nil, fmt.Appendf(nil, "large%03d", i))
+			if err != nil || !bytes.Equal(got, largeValue(i)) {
+				t.Fatalf("Get(large%03d) = %d bytes, %v", i, len(got), err)
+			}
+			got, err = db.Get(nil, fmt.Appendf(nil, "small%03d", i))
+			if err != nil || !bytes.Equal(got, smallValue(i)) {
+				t.Fatalf("Get(small%03d) = %q, %v", i, got, err)
+			}
+		}
+
+		it := db.NewIterator(nil)
+		defer it.Close()
+		count := 0
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			var i int
+			if _, err := fmt.Sscanf(string(it.Key()[5:]), "%03d", &i); err != nil {
+				t.Fatalf("Unexpected key %q", it.Key())
+			}
+			want := smallValue(i)
+			if bytes.HasPrefix(it.Key(), []byte("large")) {
+				want = largeValue(i)
+			}
+			if !bytes.Equal(it.Value(), want) {
+				t.Fatalf("Iterator value for %q = %d bytes, want %d", it.Key(), len(it.Value()), len(want))
+			}
+			count++
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("Iterator error: %v", err)
+		}
+		if count != 2*numKeys {
+			t.Fatalf("Iterator returned %d entries, want %d", count, 2*numKeys)
+		}
+	}
+	verify(db)
+
+	// Compaction keeps the blob references intact
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+	verify(db)
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	verify(db)
+}
+
+func TestBlobGarbageCollection(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	opts.EnableBlobFiles = true
+	opts.MinBlobSize = 100
+	opts.EnableBlobGarbageCollection = true
+	opts.BlobGarbageCollectionAgeCutoff = 0.5
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	const numKeys = 50
+	key := func(i int) []byte { return fmt.Appendf(nil, "key%03d", i) }
+	value := func(round, i int) []byte { return bytes.Repeat(fmt.Appendf(nil, "%d-%03d;", round, i), 40) }
+
+	// Round 0 writes every key; rounds 1 and 2 overwrite the first half.
+	// A snapshot keeps the round 0 values alive.
+	want := make(map[int][]byte)
+	var snap *Snapshot
+	for round := range 3 {
+		if round == 1 {
+			snap = db.GetSnapshot()
+		}
+		n := numKeys
+		if round > 0 {
+			n = numKeys / 2
+		}
+		for i := range n {
+			if err := db.Put(nil, key(i), value(round, i)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			want[i] = value(round, i)
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	// blobFiles returns the blob files on disk, oldest first, and their
+	// total size
+	blobFiles := func() ([]string, int64) {
+		t.Helper()
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.blob"))
+		slices.Sort(paths)
+		var total int64
+		for _, p := range paths {
+			info, err := os.Stat(p)
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+			total += info.Size()
+		}
+		return paths, total
+	}
+	before, bytesBefore := blobFiles()
+	if len(before) != 3 {
+		t.Fatalf("Expected 3 blob files before compaction, got %d", len(before))
+	}
+
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	// The oldest file's live blobs were relocated, so it and the file whose
+	// blobs were all overwritten hold no live blobs and were deleted
+	after, bytesAfter := blobFiles()
+	for _, old := range before[:2] {
+		if slices.Contains(after, old) {
+			t.Errorf("Blob file %s was not garbage collected", filepath.Base(old))
+		}
+	}
+	if len(after) >= len(before) {
+		t.Errorf("Expected fewer blob files after GC: %d before, %d after", len(before), len(after))
+	}
+	if bytesAfter >= bytesBefore {
+		t.Errorf("Blob bytes did not shrink: %d before, %d after", bytesBefore, bytesAfter)
+	}
+
+	verify := func(db DB) {
+		t.Helper()
+		for i := range numKeys {
+			got, err := db.Get(nil, key(i))
+			if err != nil || !bytes.Equal(got, want[i]) {
+				t.Fatalf("Get(%s) = %d bytes, %v", key(i), len(got), err)
+			}
+		}
+	}
+	verify(db)
+
+	readOpts := DefaultReadOptions()
+	readOpts.Snapshot = snap
+	for i := range numKeys {
+		got, err := db.Get(readOpts, key(i))
+		if err != nil || !bytes.Equal(got, value(0, i)) {
+			t.Fatalf("Get(%s) at snapshot = %d bytes, %v", key(i), len(got), err)
+		}
+	}
+	db.ReleaseSnapshot(snap)
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	verify(db)
+}
+
+func TestBlobCache(t *testing.T) {
+	dir := t.TempDir()
+	blobCache := NewLRUCache(1 << 20)
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.EnableBlobFiles = true
+	opts.MinBlobSize = 100
+	opts.BlobCache = blobCache
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	value := bytes.Repeat([]byte("blob"), 256)
+	if err := db.Put(nil, []byte("key"), value); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	got, err := db.Get(nil, []byte("key"))
+	if err != nil || !bytes.Equal(got, value) {
+		t.Fatalf("First Get = %d bytes, %v", len(got), err)
+	}
+	if hits, misses := blobCache.GetHitCount(), blobCache.GetMissCount(); hits != 0 || misses != 1 {
+		t.Fatalf("After first Get: hits=%d misses=%d, want 0 and 1", hits, misses)
+	}
+	got[0] = 'X' // The cached copy must not change
+
+	// Close the blob file reader and remove the file, so the second read
+	// can only be served by the blob cache
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.blob"))
+	if len(paths) != 1 {
+		t.Fatalf("Expected 1 blob file, got %d", len(paths))
+	}
+	impl := db.(*dbImpl)
+	for _, f := range impl.blobGC.Files() {
+		impl.blobCache.Evict(f)
+	}
+	if err := os.Remove(paths[0]); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	got, err = db.Get(nil, []byte("key"))
+	if err != nil || !bytes.Equal(got, value) {
+		t.Fatalf("Second Get = %d bytes, %v", len(got), err)
+	}
+	if hits := blobCache.GetHitCount(); hits != 1 {
+		t.Errorf("After second Get: hits=%d, want 1", hits)
+	}
+}
+
+func TestBlobPrefetch(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.EnableBlobFiles = true
+	opts.MinBlobSize = 100
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	const numKeys = 200
+	key := func(i int) []byte { return fmt.Appendf(nil, "key%03d", i) }
+	value := func(i int) []byte { return bytes.Repeat(fmt.Appendf(nil, "%03d", i), 100) }
+	for i := range numKeys {
+		if err := db.Put(nil, key(i), value(i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	SetPerfLevel(PerfLevelEnableCount)
+	defer SetPerfLevel(PerfLevelDisable)
+
+	// scanBlobReads scans every key forward, checking the values, and
+	// returns the number of blob file reads issued
+	scanBlobReads := func(prefetchSize uint64) uint64 {
+		t.Helper()
+		readOpts := DefaultReadOptions()
+		readOpts.BlobPrefetchSize = prefetchSize
+
+		ResetIOStatsContext()
+		it := db.NewIterator(readOpts)
+		defer it.Close()
+		i := 0
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			if !bytes.Equal(it.Key(), key(i)) || !bytes.Equal(it.Value(), value(i)) {
+				t.Fatalf("Entry %d = %q with %d bytes, want %q", i, it.Key(), len(it.Value()), key(i))
+			}
+			i++
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("Iterator error: %v", err)
+		}
+		if i != numKeys {
+			t.Fatalf("Iterator returned %d entries, want %d", i, numKeys)
+		}
+		return GetIOStatsContext().BlobReadCount
+	}
+
+	withoutPrefetch := scanBlobReads(0)
+	if withoutPrefetch != numKeys {
+		t.Errorf("Without prefetch: %d blob reads, want %d", withoutPrefetch, numKeys)
+	}
+	withPrefetch := scanBlobReads(64 << 10)
+	if withPrefetch == 0 || withPrefetch >= withoutPrefetch/10 {
+		t.Errorf("With prefetch: %d blob reads, want far fewer than %d", withPrefetch, withoutPrefetch)
+	}
+}
@@ -0,0 +1,31 @@
+package rockyardkv
+
+// cache.go exposes the LRU cache that can be handed to the DB through
+// Options.BlobCache.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/cache.h
+//   - cache/lru_cache.h
+
+import (
+	"github.com/aalhour/rockyardkv/internal/cache"
+)
+
+// Cache is the interface of caches the DB can use. Entries are charged
+// against a capacity in bytes.
+type Cache = cache.Cache
+
+// CacheKey identifies a cache entry by file number and offset.
+type CacheKey = cache.CacheKey
+
+// CacheHandle is a reference to a cache entry, valid until released.
+type CacheHandle = cache.Handle
+
+// LRUCache is a thread-safe Cache that evicts the least recently used
+// entries once its capacity is reached.
+type LRUCache = cache.LRUCache
+
+// NewLRUCache creates an LRU cache holding up to capacity bytes.
+func NewLRUCache(capacity uint64) *LRUCache {
+	return cache.NewLRUCache(capacity)
+}
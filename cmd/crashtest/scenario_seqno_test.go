@@ -95,7 +95,6 @@ func TestScenario_FlushRecoveryNoSequenceReuse(t *testing.T) {
 	// Phase 3: Recover and write new data
 	{
 		database := openDB(t, dir)
-		defer database.Close()
 
 		// Write new keys after recovery
 		opts := rockyardkv.DefaultWriteOptions()
@@ -112,6 +111,7 @@ func TestScenario_FlushRecoveryNoSequenceReuse(t *testing.T) {
 		if err := database.Flush(nil); err != nil {
 			t.Fatalf("Flush after recovery failed: %v", err)
 		}
+		database.Close()
 	}
 
 	// Phase 4: Verify no sequence reuse by checking all keys read consistently
@@ -95,6 +95,7 @@ func main() {
 
 		// Range operations
 		{"Range Deletion Persistence", testRangeDeletion},
+		{"Atomic Range-Delete Batch", testRangeDeleteBatch},
 		{"SST Ingestion Persistence", testSSTIngestion},
 		{"Merge Operator Persistence", testMergeOperator},
 
@@ -754,6 +755,64 @@ func testRangeDeletion(path string, keys, values [][]byte) error {
 	return nil
 }
 
+// testRangeDeleteBatch tests that a WriteBatch combining a range deletion
+// with point writes applies atomically and persists across restart.
+func testRangeDeleteBatch(path string, keys, values [][]byte) error {
+	opts := rockyardkv.DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := rockyardkv.Open(path, opts)
+	if err != nil {
+		return err
+	}
+	for i := range 100 {
+		if err := database.Put(nil, keys[i], values[i]); err != nil {
+			database.Close()
+			return fmt.Errorf("put %d failed: %w", i, err)
+		}
+	}
+
+	// Delete [25, 75) and rewrite key 50 in one batch. The Put after the
+	// range deletion survives it; the Delete removes key 90.
+	wb := rockyardkv.NewWriteBatch()
+	wb.DeleteRange(keys[25], keys[75])
+	wb.Put(keys[50], values[50])
+	wb.Delete(keys[90])
+	if err := database.Write(&rockyardkv.WriteOptions{Sync: true}, wb); err != nil {
+		database.Close()
+		return fmt.Errorf("batch write failed: %w", err)
+	}
+	log("  Session 1: Batch with DeleteRange [key25, key75), Put key50, Delete key90")
+	database.Close()
+
+	// Session 2: the whole batch is recovered from the WAL
+	database, err = rockyardkv.Open(path, opts)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	for i := range 100 {
+		deleted := (i >= 25 && i < 75 && i != 50) || i == 90
+		val, err := database.Get(nil, keys[i])
+		if deleted {
+			if !errors.Is(err, rockyardkv.ErrNotFound) {
+				return fmt.Errorf("key %d should be deleted, got err=%w", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("key %d should exist: %w", i, err)
+		}
+		if !bytes.Equal(val, values[i]) {
+			return fmt.Errorf("key %d value mismatch", i)
+		}
+	}
+	log("  Session 2: Batch recovered atomically")
+
+	return nil
+}
+
 // Test 10: SST Ingestion Persistence
 // Tests that externally created SST files are ingested and persist correctly
 func testSSTIngestion(path string, keys, values [][]byte) error {
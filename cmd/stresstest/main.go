@@ -102,7 +102,7 @@ var (
 	syncWrites        = flag.Bool("sync", false, "Sync writes to disk")
 	blockSize         = flag.Int("block-size", 4096, "SST block size in bytes")
 	writeBufferSize   = flag.Int("write-buffer-size", 4*1024*1024, "Write buffer (memtable) size in bytes")
-	_                 = flag.Int("max-open-files", -1, "Max open files (-1 for unlimited)") // Reserved for future use
+	maxOpenFiles      = flag.Int("max-open-files", -1, "Max open files (-1 for unlimited)")
 	bloomBits         = flag.Int("bloom-bits", 10, "Bloom filter bits per key (0 to disable)")
 	numColumnFamilies = flag.Int("column-families", 1, "Number of column families")
 
@@ -499,12 +499,12 @@ func main() {
 		if *allowDBAhead {
 			opts := rockyardkv.DefaultOptions()
 			opts.CreateIfMissing = false
-			if db, err := rockyardkv.OpenForReadOnly(filepath.Join(testDir, "db"), opts, false); err == nil {
+			if db, cleanup, err := openBaseReadOnlyView(filepath.Join(testDir, "db"), opts); err == nil {
 				baseReadOnlyDB = db
 				if *verbose {
 					fmt.Println("📦 Base read-only view enabled for DB-ahead delete verification")
 				}
-				defer baseReadOnlyDB.Close()
+				defer cleanup()
 			} else if *verbose {
 				fmt.Printf("⚠️  Base read-only open failed (skipping DB-ahead delete check): %v\n", err)
 			}
@@ -911,12 +911,12 @@ func runStressTest(dbPath string, expected *testutil.ExpectedStateV2, stats *Sta
 	if *allowDBAhead {
 		opts := rockyardkv.DefaultOptions()
 		opts.CreateIfMissing = false
-		if db, err := rockyardkv.OpenForReadOnly(filepath.Join(holder.path, "db"), opts, false); err == nil {
+		if db, cleanup, err := openBaseReadOnlyView(filepath.Join(holder.path, "db"), opts); err == nil {
 			baseReadOnlyDB = db
 			if *verbose {
 				fmt.Println("📦 Base read-only view enabled for DB-ahead delete verification")
 			}
-			defer baseReadOnlyDB.Close()
+			defer cleanup()
 		} else if *verbose {
 			fmt.Printf("⚠️  Base read-only open failed (skipping DB-ahead delete check): %v\n", err)
 		}
@@ -940,6 +940,7 @@ func openDB(path string) (rockyardkv.DB, []rockyardkv.ColumnFamilyHandle, error)
 	opts := rockyardkv.DefaultOptions()
 	opts.CreateIfMissing = true
 	opts.WriteBufferSize = 4 * 1024 * 1024 // 4MB
+	opts.MaxOpenFiles = *maxOpenFiles
 	// Add a merge operator for stress testing
 	opts.MergeOperator = &rockyardkv.StringAppendOperator{Delimiter: ","}
 
@@ -2434,6 +2435,48 @@ func runFlusher(holder *dbHolder, expected *testutil.ExpectedStateV2, stats *Sta
 	}
 }
 
+// openBaseReadOnlyView opens a read-only view of the flushed (SST) state of
+// the DB at dbDir. OpenForReadOnly replays un-flushed WAL writes, so the view
+// is opened on a copy of the directory that leaves the WAL files out. The
+// returned cleanup closes the view and removes the copy.
+func openBaseReadOnlyView(dbDir string, opts *rockyardkv.Options) (rockyardkv.DB, func(), error) {
+	viewDir, err := os.MkdirTemp("", "stresstest-base-view-")
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := os.ReadDir(dbDir)
+	if err != nil {
+		_ = os.RemoveAll(viewDir)
+		return nil, nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".log") || e.Name() == "LOCK" {
+			continue
+		}
+		src, dst := filepath.Join(dbDir, e.Name()), filepath.Join(viewDir, e.Name())
+		if err := os.Link(src, dst); err != nil {
+			data, err := os.ReadFile(src)
+			if err == nil {
+				err = os.WriteFile(dst, data, 0644)
+			}
+			if err != nil {
+				_ = os.RemoveAll(viewDir)
+				return nil, nil, err
+			}
+		}
+	}
+	db, err := rockyardkv.OpenForReadOnly(viewDir, opts, false)
+	if err != nil {
+		_ = os.RemoveAll(viewDir)
+		return nil, nil, err
+	}
+	cleanup := func() {
+		_ = db.Close()
+		_ = os.RemoveAll(viewDir)
+	}
+	return db, cleanup, nil
+}
+
 // verifyAll performs final verification with per-key locking
 func verifyAll(database rockyardkv.DB, expected *testutil.ExpectedStateV2, stats *Stats, baseReadOnlyDB rockyardkv.DB) error {
 	verified := 0
@@ -97,11 +97,11 @@ func TestVerifyAll_AllowsDBAheadDeleteWhenBaseHasKey(t *testing.T) {
 	}
 	t.Cleanup(func() { _ = writeDB.Close() })
 
-	baseDB, err := rockyardkv.OpenForReadOnly(dbDir, opts, false)
+	baseDB, cleanup, err := openBaseReadOnlyView(dbDir, opts)
 	if err != nil {
 		t.Fatalf("open (base readonly): %v", err)
 	}
-	t.Cleanup(func() { _ = baseDB.Close() })
+	t.Cleanup(cleanup)
 
 	if err := verifyAll(writeDB, expected, &Stats{}, baseDB); err != nil {
 		t.Fatalf("verifyAll: %v", err)
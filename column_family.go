@@ -59,6 +59,43 @@ type ColumnFamilyOptions struct {
 	// WriteBufferSize is the amount of data to build up in memory
 	// before converting to a sorted on-disk file.
 	WriteBufferSize int
+
+	// MemtablePrefixBloomSizeRatio sizes the memtable bloom filter as a
+	// fraction of WriteBufferSize (0 = no filter). See
+	// Options.MemtablePrefixBloomSizeRatio.
+	MemtablePrefixBloomSizeRatio float64
+
+	// MemtableWholeKeyFiltering adds whole keys to the memtable bloom filter.
+	MemtableWholeKeyFiltering bool
+
+	// OptimizeFiltersForHits omits filter blocks from the SST files written
+	// to the bottommost level. See Options.OptimizeFiltersForHits.
+	OptimizeFiltersForHits bool
+
+	// MaxWriteBufferSizeToMaintain is the total size of flushed memtables to
+	// keep in memory for reads. See Options.MaxWriteBufferSizeToMaintain.
+	MaxWriteBufferSizeToMaintain int64
+
+	// MinWriteBufferNumberToMerge is the number of immutable memtables merged
+	// into each flushed L0 file. See Options.MinWriteBufferNumberToMerge.
+	MinWriteBufferNumberToMerge int
+
+	// MaxSuccessiveMerges bounds the merge operands of a key in the active
+	// memtable. See Options.MaxSuccessiveMerges.
+	MaxSuccessiveMerges int
+
+	// Level0FileNumCompactionTrigger is the number of L0 files of the column
+	// family that FlushAndWaitForL0 waits to drop below. 0 uses
+	// Options.Level0FileNumCompactionTrigger. Compactions are still picked
+	// by the database's trigger.
+	Level0FileNumCompactionTrigger int
+
+	// DisableWAL keeps writes to the column family out of the WAL, for data
+	// that can be regenerated. The other records of a batch are still
+	// logged. Writes not yet flushed are lost in a crash; Close flushes the
+	// column family, so they survive a clean reopen. Unlike
+	// WriteOptions.DisableWAL, it applies to every write.
+	DisableWAL bool
 }
 
 // DefaultColumnFamilyOptions returns default options for a column family.
@@ -92,19 +129,32 @@ type columnFamilyData struct {
 
 // newColumnFamilyData creates a new column family data.
 func newColumnFamilyData(id uint32, name string, opts ColumnFamilyOptions, db *dbImpl) *columnFamilyData {
-	var cmp memtable.Comparator
-	if opts.Comparator != nil {
-		cmp = memtable.Comparator(opts.Comparator.Compare)
-	}
-
-	return &columnFamilyData{
+	cfd := &columnFamilyData{
 		id:      id,
 		name:    name,
 		options: opts,
-		mem:     memtable.NewMemTable(cmp),
 		refs:    1,
 		db:      db,
 	}
+	cfd.mem = cfd.newMemTable()
+	return cfd
+}
+
+// newMemTable creates a memtable for the column family.
+func (cfd *columnFamilyData) newMemTable() *memtable.MemTable {
+	var cmp memtable.Comparator
+	if cfd.options.Comparator != nil {
+		cmp = memtable.Comparator(cfd.options.Comparator.Compare)
+	}
+
+	dbOpts := &Options{}
+	if cfd.db != nil && cfd.db.options != nil {
+		dbOpts = cfd.db.options
+	}
+	opts := cfd.options
+	memOpts := memtableOptions(dbOpts.PrefixExtractor, opts.WriteBufferSize, opts.MemtablePrefixBloomSizeRatio, opts.MemtableWholeKeyFiltering)
+	setMemTableRep(&memOpts, dbOpts)
+	return memtable.NewMemTableWithOptions(cmp, memOpts)
 }
 
 // ref increments the reference count.
@@ -157,6 +207,9 @@ type columnFamilySet struct {
 	// The default column family
 	defaultCF *columnFamilyData
 
+	// Number of column families with DisableWAL set
+	walDisabled int
+
 	// Parent database
 	db *dbImpl
 }
@@ -213,6 +266,9 @@ func (cfs *columnFamilySet) create(name string, opts ColumnFamilyOptions) (*colu
 	cfd := newColumnFamilyData(id, name, opts, cfs.db)
 	cfs.byName[name] = cfd
 	cfs.byID[id] = cfd
+	if opts.DisableWAL {
+		cfs.walDisabled++
+	}
 
 	return cfd, nil
 }
@@ -233,6 +289,9 @@ func (cfs *columnFamilySet) drop(cfd *columnFamilyData) error {
 	cfd.dropped.Store(true)
 	delete(cfs.byName, cfd.name)
 	delete(cfs.byID, cfd.id)
+	if cfd.options.DisableWAL {
+		cfs.walDisabled--
+	}
 	cfd.unref()
 
 	return nil
@@ -285,6 +344,9 @@ func (cfs *columnFamilySet) createWithID(id uint32, name string, opts ColumnFami
 	cfd := newColumnFamilyData(id, name, opts, cfs.db)
 	cfs.byName[name] = cfd
 	cfs.byID[id] = cfd
+	if opts.DisableWAL {
+		cfs.walDisabled++
+	}
 
 	// Update nextID if necessary
 	if id >= cfs.nextCFID {
@@ -294,6 +356,13 @@ func (cfs *columnFamilySet) createWithID(id uint32, name string, opts ColumnFami
 	return cfd, nil
 }
 
+// hasWALDisabled reports whether a column family has DisableWAL set.
+func (cfs *columnFamilySet) hasWALDisabled() bool {
+	cfs.mu.RLock()
+	defer cfs.mu.RUnlock()
+	return cfs.walDisabled > 0
+}
+
 // forEach calls the given function for each column family.
 func (cfs *columnFamilySet) forEach(fn func(*columnFamilyData)) {
 	cfs.mu.RLock()
@@ -402,3 +402,134 @@ func TestColumnFamilySetCoverage(t *testing.T) {
 		}
 	})
 }
+
+// TestWriteBatchMultiCF verifies that a batch writing to several column
+// families is applied as a whole and survives a reopen.
+func TestWriteBatchMultiCF(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	users, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "users")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily(users) failed: %v", err)
+	}
+	orders, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "orders")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily(orders) failed: %v", err)
+	}
+
+	seqBefore := database.GetLatestSequenceNumber()
+	wb := NewWriteBatch()
+	wb.PutCF(users.ID(), []byte("user:1"), []byte("alice"))
+	wb.PutCF(orders.ID(), []byte("order:1"), []byte("user:1"))
+	wb.Put([]byte("last_order"), []byte("order:1"))
+	if err := database.Write(nil, wb); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got, want := database.GetLatestSequenceNumber(), seqBefore+3; got != want {
+		t.Errorf("sequence after Write = %d, want %d", got, want)
+	}
+
+	check := func(database DB, users, orders ColumnFamilyHandle) {
+		t.Helper()
+		for _, tt := range []struct {
+			cf         ColumnFamilyHandle
+			key, value string
+		}{
+			{users, "user:1", "alice"},
+			{orders, "order:1", "user:1"},
+			{database.DefaultColumnFamily(), "last_order", "order:1"},
+		} {
+			got, err := database.GetCF(nil, tt.cf, []byte(tt.key))
+			if err != nil || string(got) != tt.value {
+				t.Errorf("GetCF(%s, %s) = %q, %v, want %q", tt.cf.Name(), tt.key, got, err, tt.value)
+			}
+		}
+		// Each record landed in its own column family only
+		if _, err := database.GetCF(nil, orders, []byte("user:1")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetCF(orders, user:1) = %v, want ErrNotFound", err)
+		}
+	}
+	check(database, users, orders)
+
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer database.Close()
+	users, orders = database.GetColumnFamily("users"), database.GetColumnFamily("orders")
+	if users == nil || orders == nil {
+		t.Fatalf("column families missing after reopen: %v", database.ListColumnFamilies())
+	}
+	check(database, users, orders)
+}
+
+// TestWriteBatchMultiCFMissingFamily verifies that a batch naming a dropped
+// column family fails without applying any of its records.
+func TestWriteBatchMultiCFMissingFamily(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	users, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "users")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily(users) failed: %v", err)
+	}
+	dropped, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "dropped")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily(dropped) failed: %v", err)
+	}
+	if err := database.DropColumnFamily(dropped); err != nil {
+		t.Fatalf("DropColumnFamily failed: %v", err)
+	}
+
+	seqBefore := database.GetLatestSequenceNumber()
+	wb := NewWriteBatch()
+	wb.Put([]byte("key"), []byte("value"))
+	wb.PutCF(users.ID(), []byte("user:1"), []byte("alice"))
+	wb.PutCF(dropped.ID(), []byte("key"), []byte("value"))
+	if err := database.Write(nil, wb); !errors.Is(err, ErrColumnFamilyNotFound) {
+		t.Fatalf("Write = %v, want ErrColumnFamilyNotFound", err)
+	}
+	if got := database.GetLatestSequenceNumber(); got != seqBefore {
+		t.Errorf("sequence after failed Write = %d, want %d", got, seqBefore)
+	}
+
+	check := func(database DB, users ColumnFamilyHandle) {
+		t.Helper()
+		if _, err := database.Get(nil, []byte("key")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(key) = %v, want ErrNotFound", err)
+		}
+		if _, err := database.GetCF(nil, users, []byte("user:1")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetCF(users, user:1) = %v, want ErrNotFound", err)
+		}
+	}
+	check(database, users)
+
+	// The failed batch never reached the WAL
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer database.Close()
+	users = database.GetColumnFamily("users")
+	if users == nil {
+		t.Fatalf("column family users missing after reopen: %v", database.ListColumnFamilies())
+	}
+	check(database, users)
+}
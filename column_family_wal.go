@@ -0,0 +1,161 @@
+package rockyardkv
+
+// column_family_wal.go implements ColumnFamilyOptions.DisableWAL.
+//
+// The records of a batch for WAL-disabled column families are left out of the
+// batch's WAL record. Recovery assigns the records of a WAL record consecutive
+// sequence numbers from the record's sequence, so the logged records are
+// replayed with lower sequence numbers than they were written with. This is
+// safe: their order is kept, and they stay below the sequence numbers of the
+// next batch, whose WAL record carries its own sequence.
+
+import (
+	"errors"
+
+	"github.com/aalhour/rockyardkv/internal/batch"
+)
+
+// flushWALDisabled flushes the memtables of the column families with
+// DisableWAL set, whose writes a reopen would otherwise lose.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc (CancelAllBackgroundWork)
+func (db *dbImpl) flushWALDisabled() {
+	if !db.columnFamilies.hasWALDisabled() {
+		return
+	}
+	var cfds []*columnFamilyData
+	db.columnFamilies.forEach(func(cfd *columnFamilyData) {
+		if cfd.options.DisableWAL {
+			cfds = append(cfds, cfd)
+		}
+	})
+	for _, cfd := range cfds {
+		if err := db.flushColumnFamily(cfd); err != nil && !errors.Is(err, ErrDBClosed) {
+			db.logger.Warnf("[db] flush of WAL-disabled column family %q failed: %v", cfd.name, err)
+		}
+	}
+}
+
+// walData returns the data to log for b: b's data, or a copy without the
+// records for WAL-disabled column families. It returns nil if nothing is
+// left to log.
+// REQUIRES: db.mu is held.
+func (db *dbImpl) walData(b *batch.WriteBatch) []byte {
+	if !db.columnFamilies.hasWALDisabled() {
+		return b.Data()
+	}
+	filter := &walFilter{db: db, target: batch.New()}
+	if err := b.Iterate(filter); err != nil || !filter.skipped {
+		return b.Data()
+	}
+	if filter.target.Count() == 0 && b.Count() > 0 {
+		return nil
+	}
+	filter.target.SetSequence(b.Sequence())
+	return filter.target.Data()
+}
+
+// walFilter copies the records of a batch to target, except those for
+// column families with DisableWAL set.
+type walFilter struct {
+	db      *dbImpl
+	target  *batch.WriteBatch
+	skipped bool
+}
+
+// skip reports whether the records for cfID are left out of the WAL.
+func (f *walFilter) skip(cfID uint32) bool {
+	if cfID == DefaultColumnFamilyID {
+		return false
+	}
+	cfd := f.db.columnFamilies.getByID(cfID)
+	if cfd == nil || !cfd.options.DisableWAL {
+		return false
+	}
+	f.skipped = true
+	return true
+}
+
+func (f *walFilter) Put(key, value []byte) error { return f.PutCF(DefaultColumnFamilyID, key, value) }
+func (f *walFilter) Delete(key []byte) error     { return f.DeleteCF(DefaultColumnFamilyID, key) }
+func (f *walFilter) SingleDelete(key []byte) error {
+	return f.SingleDeleteCF(DefaultColumnFamilyID, key)
+}
+func (f *walFilter) Merge(key, value []byte) error {
+	return f.MergeCF(DefaultColumnFamilyID, key, value)
+}
+func (f *walFilter) DeleteRange(startKey, endKey []byte) error {
+	return f.DeleteRangeCF(DefaultColumnFamilyID, startKey, endKey)
+}
+func (f *walFilter) PutEntity(key, entity []byte) error {
+	return f.PutEntityCF(DefaultColumnFamilyID, key, entity)
+}
+func (f *walFilter) LogData(blob []byte) { f.target.PutLogData(blob) }
+
+func (f *walFilter) PutCF(cfID uint32, key, value []byte) error {
+	if !f.skip(cfID) {
+		f.target.PutCF(cfID, key, value)
+	}
+	return nil
+}
+
+func (f *walFilter) DeleteCF(cfID uint32, key []byte) error {
+	if !f.skip(cfID) {
+		f.target.DeleteCF(cfID, key)
+	}
+	return nil
+}
+
+func (f *walFilter) SingleDeleteCF(cfID uint32, key []byte) error {
+	if !f.skip(cfID) {
+		f.target.SingleDeleteCF(cfID, key)
+	}
+	return nil
+}
+
+func (f *walFilter) MergeCF(cfID uint32, key, value []byte) error {
+	if !f.skip(cfID) {
+		f.target.MergeCF(cfID, key, value)
+	}
+	return nil
+}
+
+func (f *walFilter) DeleteRangeCF(cfID uint32, startKey, endKey []byte) error {
+	if !f.skip(cfID) {
+		f.target.DeleteRangeCF(cfID, startKey, endKey)
+	}
+	return nil
+}
+
+func (f *walFilter) PutEntityCF(cfID uint32, key, entity []byte) error {
+	if !f.skip(cfID) {
+		f.target.PutEntityCF(cfID, key, entity)
+	}
+	return nil
+}
+
+// The 2PC markers are kept, so that recovery restores prepared transactions.
+
+func (f *walFilter) MarkBeginPrepare(_ bool) error {
+	f.target.MarkBeginPrepare()
+	return nil
+}
+
+func (f *walFilter) MarkBeginPersistedPrepare() error {
+	f.target.MarkBeginPersistedPrepare()
+	return nil
+}
+
+func (f *walFilter) MarkEndPrepare(xid []byte) error {
+	f.target.MarkEndPrepare(xid)
+	return nil
+}
+
+func (f *walFilter) MarkCommit(xid []byte) error {
+	f.target.MarkCommit(xid)
+	return nil
+}
+
+func (f *walFilter) MarkRollback(xid []byte) error {
+	f.target.MarkRollback(xid)
+	return nil
+}
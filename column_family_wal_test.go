@@ -0,0 +1,165 @@
+package rockyardkv
+
+// column_family_wal_test.go implements tests for ColumnFamilyOptions.DisableWAL.
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+// TestColumnFamilyDisableWAL verifies that after a crash a WAL-disabled
+// column family has lost its writes while the other column families,
+// including records written in the same batches, are recovered.
+func TestColumnFamilyDisableWAL(t *testing.T) {
+	dir := t.TempDir()
+	faultFS := vfs.NewFaultInjectionFS(vfs.Default())
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.FS = faultFS
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	durable, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "durable")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily(durable) failed: %v", err)
+	}
+	cacheOpts := DefaultColumnFamilyOptions()
+	cacheOpts.DisableWAL = true
+	cache, err := database.CreateColumnFamily(cacheOpts, "cache")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily(cache) failed: %v", err)
+	}
+
+	sync := &WriteOptions{Sync: true}
+	for i := range 10 {
+		key := fmt.Appendf(nil, "key%02d", i)
+		if err := database.PutCF(sync, cache, key, []byte("cached")); err != nil {
+			t.Fatalf("PutCF(cache) failed: %v", err)
+		}
+		if err := database.PutCF(sync, durable, key, []byte("durable")); err != nil {
+			t.Fatalf("PutCF(durable) failed: %v", err)
+		}
+	}
+	// A batch mixing both: only the durable records are logged, and they
+	// keep their order
+	wb := NewWriteBatch()
+	wb.PutCF(durable.ID(), []byte("mixed"), []byte("first"))
+	wb.PutCF(cache.ID(), []byte("mixed"), []byte("cached"))
+	wb.Put([]byte("mixed"), []byte("default"))
+	wb.PutCF(durable.ID(), []byte("mixed"), []byte("second"))
+	if err := database.Write(sync, wb); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if v, err := database.GetCF(nil, cache, []byte("mixed")); err != nil || string(v) != "cached" {
+		t.Errorf("GetCF(cache, mixed) = %q, %v before crash, want \"cached\"", v, err)
+	}
+
+	database = crashAndReopen(t, database, faultFS, dir, opts)
+	defer database.Close()
+	durable, cache = database.GetColumnFamily("durable"), database.GetColumnFamily("cache")
+	if durable == nil || cache == nil {
+		t.Fatalf("column families missing after reopen: %v", database.ListColumnFamilies())
+	}
+
+	for i := range 10 {
+		key := fmt.Appendf(nil, "key%02d", i)
+		if v, err := database.GetCF(nil, durable, key); err != nil || string(v) != "durable" {
+			t.Errorf("GetCF(durable, %s) = %q, %v, want \"durable\"", key, v, err)
+		}
+		if _, err := database.GetCF(nil, cache, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetCF(cache, %s) = %v, want ErrNotFound", key, err)
+		}
+	}
+	if v, err := database.GetCF(nil, durable, []byte("mixed")); err != nil || string(v) != "second" {
+		t.Errorf("GetCF(durable, mixed) = %q, %v, want \"second\"", v, err)
+	}
+	if v, err := database.Get(nil, []byte("mixed")); err != nil || string(v) != "default" {
+		t.Errorf("Get(mixed) = %q, %v, want \"default\"", v, err)
+	}
+	if _, err := database.GetCF(nil, cache, []byte("mixed")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCF(cache, mixed) = %v, want ErrNotFound", err)
+	}
+
+	// Writes after recovery must not reuse the sequence numbers of the
+	// recovered records
+	if err := database.PutCF(nil, durable, []byte("mixed"), []byte("third")); err != nil {
+		t.Fatalf("PutCF(durable) failed: %v", err)
+	}
+	if v, err := database.GetCF(nil, durable, []byte("mixed")); err != nil || string(v) != "third" {
+		t.Errorf("GetCF(durable, mixed) = %q, %v after recovery, want \"third\"", v, err)
+	}
+}
+
+// TestColumnFamilyDisableWALOnlyRecords verifies that a batch whose records
+// all go to WAL-disabled column families writes nothing to the WAL.
+func TestColumnFamilyDisableWALOnlyRecords(t *testing.T) {
+	opts := DefaultOptions()
+	database, cleanup := createTestDB(t, opts)
+	defer cleanup()
+
+	cacheOpts := DefaultColumnFamilyOptions()
+	cacheOpts.DisableWAL = true
+	cache, err := database.CreateColumnFamily(cacheOpts, "cache")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	before := database.GetWriteStats().WALBytes
+	if err := database.PutCF(nil, cache, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+	if after := database.GetWriteStats().WALBytes; after != before {
+		t.Errorf("WALBytes = %d after a write to a WAL-disabled column family, want %d", after, before)
+	}
+	if v, err := database.GetCF(nil, cache, []byte("key")); err != nil || string(v) != "value" {
+		t.Errorf("GetCF(cache, key) = %q, %v, want \"value\"", v, err)
+	}
+}
+
+// TestColumnFamilyDisableWALCleanReopen verifies that Close flushes a
+// WAL-disabled column family, so its writes survive a clean reopen.
+func TestColumnFamilyDisableWALCleanReopen(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	cacheOpts := DefaultColumnFamilyOptions()
+	cacheOpts.DisableWAL = true
+	cache, err := database.CreateColumnFamily(cacheOpts, "cache")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+	for i := range 10 {
+		if err := database.PutCF(nil, cache, fmt.Appendf(nil, "key%02d", i), []byte("cached")); err != nil {
+			t.Fatalf("PutCF failed: %v", err)
+		}
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer database.Close()
+	cache = database.GetColumnFamily("cache")
+	if cache == nil {
+		t.Fatalf("column family missing after reopen: %v", database.ListColumnFamilies())
+	}
+	for i := range 10 {
+		key := fmt.Appendf(nil, "key%02d", i)
+		if v, err := database.GetCF(nil, cache, key); err != nil || string(v) != "cached" {
+			t.Errorf("GetCF(cache, %s) = %q, %v after reopen, want \"cached\"", key, v, err)
+		}
+	}
+}
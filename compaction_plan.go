@@ -0,0 +1,136 @@
+package rockyardkv
+
+// compaction_plan.go implements PlanCompaction, a dry run of CompactRange.
+//
+// CompactRange compacts the files overlapping the range one level at a time,
+// from L0 down, each compaction also rewriting the overlapping files of its
+// output level. The plan replays those steps on a copy of the current file
+// layout, in which the output of each step becomes a single file of the
+// output level covering the keys of its inputs.
+
+import (
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/manifest"
+)
+
+// CompactionPlan describes the compactions CompactRange would run.
+type CompactionPlan struct {
+	// InputFiles lists the SST files the compactions would rewrite, with
+	// the levels they are in now.
+	InputFiles []LiveFileMetaData
+
+	// InputSize is the total size of InputFiles in bytes.
+	InputSize uint64
+
+	// OutputLevel is the level the compacted data would end up in, or -1
+	// if there is nothing to compact.
+	OutputLevel int
+
+	// EstimatedOutputSize is the estimated total size of the output files
+	// in bytes. It assumes that compaction drops no entries, so it is an
+	// upper bound when the range holds overwritten or deleted keys.
+	EstimatedOutputSize uint64
+}
+
+// plannedFile is a file of the simulated file layout: an existing SST file
+// and its level, or the output of an earlier step of the plan (meta == nil).
+type plannedFile struct {
+	meta     *manifest.FileMetaData
+	level    int
+	smallest []byte
+	largest  []byte
+}
+
+// PlanCompaction returns what CompactRange(opts, start, end) would compact
+// in a column family. Files being compacted in the background are left out,
+// as CompactRange skips them. The memtable, which CompactRange flushes
+// first, is not part of the plan.
+func (db *dbImpl) PlanCompaction(cf ColumnFamilyHandle, opts *CompactRangeOptions, start, end []byte) (*CompactionPlan, error) {
+	if opts == nil {
+		opts = &CompactRangeOptions{}
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &CompactionPlan{OutputLevel: -1}
+	v := db.versions.Current()
+	if v == nil {
+		return plan, nil
+	}
+
+	numLevels := v.NumLevels()
+	levels := make([][]plannedFile, numLevels)
+	for level := range numLevels {
+		for _, f := range v.Files(level) {
+			if f.ColumnFamilyID == cfd.id && !f.BeingCompacted {
+				levels[level] = append(levels[level], plannedFile{meta: f, level: level, smallest: f.Smallest, largest: f.Largest})
+			}
+		}
+	}
+
+	for level := range numLevels - 1 {
+		var inputs, rest []plannedFile
+		for _, f := range levels[level] {
+			if compactRangeOverlaps(f.smallest, f.largest, start, end) {
+				inputs = append(inputs, f)
+			} else {
+				rest = append(rest, f)
+			}
+		}
+		if len(inputs) == 0 {
+			continue
+		}
+		levels[level] = rest
+
+		// The compaction also rewrites the files of the output level that
+		// overlap the keys of its inputs
+		outputLevel := compactRangeOutputLevel(level, numLevels, opts)
+		smallest, largest := plannedKeyRange(inputs)
+		rest = nil
+		for _, f := range levels[outputLevel] {
+			if dbformat.CompareInternalKeys(f.largest, smallest) >= 0 &&
+				dbformat.CompareInternalKeys(f.smallest, largest) <= 0 {
+				inputs = append(inputs, f)
+			} else {
+				rest = append(rest, f)
+			}
+		}
+
+		for _, f := range inputs {
+			if f.meta != nil {
+				plan.InputFiles = append(plan.InputFiles, db.liveFileMetaData(f.level, f.meta, cfd.name))
+				plan.InputSize += f.meta.FD.FileSize
+			}
+		}
+		output := plannedFile{}
+		output.smallest, output.largest = plannedKeyRange(inputs)
+		levels[outputLevel] = append(rest, output)
+		plan.OutputLevel = outputLevel
+	}
+
+	// Every input ends up in the output of the last compaction
+	plan.EstimatedOutputSize = plan.InputSize
+	return plan, nil
+}
+
+// plannedKeyRange returns the smallest and largest keys of files.
+func plannedKeyRange(files []plannedFile) (smallest, largest []byte) {
+	for _, f := range files {
+		if smallest == nil || dbformat.CompareInternalKeys(f.smallest, smallest) < 0 {
+			smallest = f.smallest
+		}
+		if largest == nil || dbformat.CompareInternalKeys(f.largest, largest) > 0 {
+			largest = f.largest
+		}
+	}
+	return smallest, largest
+}
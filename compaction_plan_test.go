@@ -0,0 +1,108 @@
+package rockyardkv
+
+// compaction_plan_test.go implements tests for PlanCompaction.
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestPlanCompaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	writeFile := func(prefixes ...string) {
+		t.Helper()
+		for _, prefix := range prefixes {
+			for i := range 100 {
+				if err := database.Put(nil, fmt.Appendf(nil, "%s%03d", prefix, i), []byte("value")); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+			}
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	liveFiles := func() map[uint64]int {
+		t.Helper()
+		files := make(map[uint64]int)
+		for _, f := range database.GetLiveFilesMetaData() {
+			files[f.FileNumber] = f.Level
+		}
+		return files
+	}
+
+	// A bottommost file spanning the key space, under three L0 files
+	writeFile("a", "e")
+	if err := database.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+	writeFile("b")
+	writeFile("c")
+	writeFile("d")
+
+	before := liveFiles()
+	plan, err := database.PlanCompaction(nil, nil, []byte("c"), []byte("d"))
+	if err != nil {
+		t.Fatalf("PlanCompaction failed: %v", err)
+	}
+
+	// The plan compacts the L0 file of the range into the bottommost file
+	var planned []uint64
+	var plannedSize uint64
+	for _, f := range plan.InputFiles {
+		if f.Level != before[f.FileNumber] {
+			t.Errorf("planned file %d at L%d, want L%d", f.FileNumber, f.Level, before[f.FileNumber])
+		}
+		planned = append(planned, f.FileNumber)
+		plannedSize += f.Size
+	}
+	if len(planned) != 2 {
+		t.Errorf("planned input files %v, want one L0 and one bottommost file", planned)
+	}
+	if plan.InputSize != plannedSize || plan.EstimatedOutputSize != plannedSize {
+		t.Errorf("InputSize = %d, EstimatedOutputSize = %d, want %d each",
+			plan.InputSize, plan.EstimatedOutputSize, plannedSize)
+	}
+	if want := opts.NumLevels - 1; plan.OutputLevel != want {
+		t.Errorf("OutputLevel = %d, want %d", plan.OutputLevel, want)
+	}
+
+	// CompactRange consumes exactly the planned files
+	if err := database.CompactRange(nil, []byte("c"), []byte("d")); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+	after := liveFiles()
+	var consumed []uint64
+	for num := range before {
+		if _, ok := after[num]; !ok {
+			consumed = append(consumed, num)
+		}
+	}
+	slices.Sort(planned)
+	slices.Sort(consumed)
+	if !slices.Equal(planned, consumed) {
+		t.Errorf("CompactRange consumed files %v, planned %v (live files %v)",
+			consumed, planned, slices.Sorted(maps.Keys(before)))
+	}
+
+	// Nothing is left to compact in the range
+	plan, err = database.PlanCompaction(nil, nil, []byte("c"), []byte("d"))
+	if err != nil {
+		t.Fatalf("PlanCompaction failed: %v", err)
+	}
+	if len(plan.InputFiles) != 0 || plan.OutputLevel != -1 {
+		t.Errorf("plan after compaction has %d input files and output level %d, want none and -1",
+			len(plan.InputFiles), plan.OutputLevel)
+	}
+}
@@ -0,0 +1,148 @@
+package rockyardkv
+
+// consistency.go implements CheckConsistency, an on-demand self-check of the
+// invariants of the current version.
+//
+// Reference: RocksDB v10.7.5
+//   - db/db_impl/db_impl.cc (DBImpl::CheckConsistency)
+//   - db/version_builder.cc (VersionBuilder::Rep::CheckConsistencyDetails)
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/manifest"
+	"github.com/aalhour/rockyardkv/internal/version"
+)
+
+// CheckConsistency validates the invariants of the current version of the
+// database, complementing the external expected-state checks of stress
+// tests and `ldb checkconsistency`:
+//   - every SST file exists with the size recorded in the MANIFEST
+//   - files within each non-L0 level have non-overlapping key ranges
+//   - overlapping L0 files have sequence number ranges ordered by age
+//   - the first and last keys of each SST file lie within its recorded
+//     smallest and largest keys, and match them when the file holds no
+//     range tombstones; their sequence numbers are not above the last
+//     sequence number
+//
+// The contents of a file are only sampled at its first and last keys, so
+// the check reads two blocks per file. Any violation is reported as
+// ErrCorruption.
+func (db *dbImpl) CheckConsistency() error {
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return ErrDBClosed
+	}
+	v := db.versions.Current()
+	if v != nil {
+		v.Ref()
+	}
+	db.mu.RUnlock()
+	if v == nil {
+		return nil
+	}
+	defer v.Unref()
+
+	if err := db.checkVersionFiles(v); err != nil {
+		return err
+	}
+	if err := db.checkLevelRanges(v); err != nil {
+		return err
+	}
+	if err := db.checkL0Seqnos(v); err != nil {
+		return err
+	}
+	lastSequence := db.versions.LastSequence()
+	for level := range v.NumLevels() {
+		for _, f := range v.Files(level) {
+			if err := db.checkFileBounds(level, f, lastSequence); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkL0Seqnos verifies that of two overlapping L0 files of a column
+// family, the newer one holds only newer sequence numbers, so reads that
+// search L0 from newest to oldest find the latest version of a key first.
+// Files without recorded sequence numbers are skipped.
+func (db *dbImpl) checkL0Seqnos(v *version.Version) error {
+	// Files(0) is ordered oldest first
+	files := v.Files(0)
+	for i, older := range files {
+		if older.FD.SmallestSeqno > older.FD.LargestSeqno {
+			continue
+		}
+		for _, newer := range files[i+1:] {
+			if newer.ColumnFamilyID != older.ColumnFamilyID || newer.FD.SmallestSeqno > newer.FD.LargestSeqno {
+				continue
+			}
+			if db.cmp.Compare(extractUserKey(newer.Largest), extractUserKey(older.Smallest)) < 0 ||
+				db.cmp.Compare(extractUserKey(newer.Smallest), extractUserKey(older.Largest)) > 0 {
+				continue
+			}
+			if newer.FD.SmallestSeqno <= older.FD.LargestSeqno {
+				return fmt.Errorf("%w: level 0 file %s (seqnos %d-%d) overlaps older file %s (seqnos %d-%d) without newer sequence numbers",
+					ErrCorruption,
+					sstFileName(newer.FD.GetNumber()), newer.FD.SmallestSeqno, newer.FD.LargestSeqno,
+					sstFileName(older.FD.GetNumber()), older.FD.SmallestSeqno, older.FD.LargestSeqno)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFileBounds compares the first and last keys of an SST file with the
+// smallest and largest keys recorded for it. User keys are compared, as the
+// recorded keys of ingested files carry the global sequence number instead
+// of the sequence numbers in the file. Range tombstones can extend the
+// recorded range beyond the point keys.
+func (db *dbImpl) checkFileBounds(level int, f *manifest.FileMetaData, lastSequence uint64) error {
+	number := f.FD.GetNumber()
+	reader, err := db.tableCache.Get(number, db.sstFilePath(number))
+	if err != nil {
+		return fmt.Errorf("%w: open sst file %s (level %d): %w", ErrCorruption, sstFileName(number), level, err)
+	}
+	defer db.tableCache.Release(number)
+
+	iter := reader.NewIterator()
+	iter.SeekToFirst()
+	if !iter.Valid() {
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("%w: read sst file %s (level %d): %w", ErrCorruption, sstFileName(number), level, err)
+		}
+		// Only range tombstones
+		return nil
+	}
+	first := bytes.Clone(iter.Key())
+	iter.SeekToLast()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("%w: read sst file %s (level %d): %w", ErrCorruption, sstFileName(number), level, err)
+	}
+	last := bytes.Clone(iter.Key())
+
+	smallest, largest := extractUserKey(f.Smallest), extractUserKey(f.Largest)
+	firstKey, lastKey := extractUserKey(first), extractUserKey(last)
+	lowerCmp, upperCmp := db.cmp.Compare(firstKey, smallest), db.cmp.Compare(lastKey, largest)
+	if reader.HasRangeTombstones() {
+		if lowerCmp < 0 || upperCmp > 0 {
+			return fmt.Errorf("%w: sst file %s (level %d) holds keys %q to %q outside its recorded range %q to %q",
+				ErrCorruption, sstFileName(number), level, firstKey, lastKey, smallest, largest)
+		}
+	} else if lowerCmp != 0 || upperCmp != 0 {
+		return fmt.Errorf("%w: sst file %s (level %d) holds keys %q to %q, MANIFEST records %q to %q",
+			ErrCorruption, sstFileName(number), level, firstKey, lastKey, smallest, largest)
+	}
+
+	for _, key := range [][]byte{first, last} {
+		if seq := uint64(dbformat.ExtractSequenceNumber(key)); seq > lastSequence {
+			return fmt.Errorf("%w: sst file %s (level %d) holds sequence number %d above the last sequence number %d",
+				ErrCorruption, sstFileName(number), level, seq, lastSequence)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package rockyardkv
+
+// consistency_test.go implements tests for CheckConsistency.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/manifest"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+	db := database.(*dbImpl)
+
+	// Overlapping L0 files, one with a range tombstone, compacted into L1,
+	// then more L0 files on top
+	for round := range 4 {
+		for i := range 50 {
+			key := fmt.Appendf(nil, "key%03d", i*4+round)
+			if err := db.Put(nil, key, fmt.Appendf(nil, "value%d", round)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if round == 1 {
+			if err := db.DeleteRange(nil, []byte("key050"), []byte("key060")); err != nil {
+				t.Fatalf("DeleteRange failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if round == 2 {
+			if err := db.CompactRange(nil, nil, nil); err != nil {
+				t.Fatalf("CompactRange failed: %v", err)
+			}
+		}
+	}
+	if err := db.CheckConsistency(); err != nil {
+		t.Fatalf("CheckConsistency on a healthy DB = %v", err)
+	}
+
+	// Register a copy of an L1 file as a second L1 file with the same range
+	v := db.versions.Current()
+	var original *manifest.FileMetaData
+	level := 1
+	for ; level < v.NumLevels(); level++ {
+		if files := v.Files(level); len(files) > 0 {
+			original = files[0]
+			break
+		}
+	}
+	if original == nil {
+		t.Fatal("no file below L0 after CompactRange")
+	}
+	data, err := os.ReadFile(db.sstFilePath(original.FD.GetNumber()))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	copyNumber := db.versions.NextFileNumber()
+	if err := os.WriteFile(db.sstFilePath(copyNumber), data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	duplicate := manifest.NewFileMetaData()
+	duplicate.FD = manifest.NewFileDescriptor(copyNumber, 0, original.FD.FileSize)
+	duplicate.Smallest = original.Smallest
+	duplicate.Largest = original.Largest
+	edit := manifest.NewVersionEdit()
+	edit.AddFile(level, duplicate)
+	db.mu.Lock()
+	err = db.versions.LogAndApply(edit)
+	db.mu.Unlock()
+	if err != nil {
+		t.Fatalf("LogAndApply failed: %v", err)
+	}
+
+	err = db.CheckConsistency()
+	if !errors.Is(err, ErrCorruption) {
+		t.Fatalf("CheckConsistency with overlapping files = %v, want ErrCorruption", err)
+	}
+	if !strings.Contains(err.Error(), "overlapping ranges") {
+		t.Errorf("CheckConsistency error = %v, want overlapping ranges reported", err)
+	}
+}
@@ -8,12 +8,18 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aalhour/rockyardkv/internal/batch"
+	"github.com/aalhour/rockyardkv/internal/blob"
 	"github.com/aalhour/rockyardkv/internal/compaction"
 	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/logging"
@@ -24,6 +30,7 @@ import (
 	"github.com/aalhour/rockyardkv/internal/testutil"
 	"github.com/aalhour/rockyardkv/internal/version"
 	"github.com/aalhour/rockyardkv/internal/wal"
+	"github.com/aalhour/rockyardkv/internal/wide"
 	"github.com/aalhour/rockyardkv/vfs"
 )
 
@@ -37,6 +44,8 @@ var (
 	ErrCorruption          = errors.New("db: corruption detected")
 	ErrInvalidOptions      = errors.New("db: invalid options")
 	ErrBackgroundError     = errors.New("db: unrecoverable background error")
+	ErrDBLocked            = errors.New("db: database is locked")
+	ErrNotSupported        = errors.New("db: not supported")
 	ErrFatal               = logging.ErrFatal // Re-export for convenience
 )
 
@@ -86,21 +95,64 @@ type DB interface {
 	// MergeCF applies a merge operation for the given key in the specified column family.
 	MergeCF(opts *WriteOptions, cf ColumnFamilyHandle, key, value []byte) error
 
+	// PutEntity sets the wide-column entity for the given key in the specified
+	// column family (nil = default). The column with the empty name is the
+	// default column, which Get returns. Column names must be unique.
+	PutEntity(opts *WriteOptions, cf ColumnFamilyHandle, key []byte, columns []WideColumn) error
+
+	// GetEntity retrieves the columns of the given key in the specified column
+	// family (nil = default), ordered by name. A plain value is returned as a
+	// single default column. Returns ErrNotFound if the key does not exist.
+	GetEntity(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([]WideColumn, error)
+
+	// MultiGetEntity retrieves the columns of several keys in the specified
+	// column family (nil = default) from one consistent view of the database.
+	// Results and errors are in the same order as keys; a missing key has nil
+	// columns and ErrNotFound.
+	MultiGetEntity(opts *ReadOptions, cf ColumnFamilyHandle, keys [][]byte) ([][]WideColumn, []error)
+
 	// Write applies a batch of operations atomically.
 	Write(opts *WriteOptions, batch *WriteBatch) error
 
+	// CompareAndSwap sets key to newValue only if its current value equals
+	// expected, where a nil expected means the key must not exist. Returns
+	// false, without writing, if the current value differs.
+	CompareAndSwap(opts *WriteOptions, key, expected, newValue []byte) (bool, error)
+
 	// NewIterator creates an iterator over the default column family.
 	NewIterator(opts *ReadOptions) Iterator
 
 	// NewIteratorCF creates an iterator over the specified column family.
 	NewIteratorCF(opts *ReadOptions, cf ColumnFamilyHandle) Iterator
 
+	// NewIteratorWithBatch creates an iterator over the default column
+	// family with the writes staged in wbwi applied on top.
+	// Reference: RocksDB v10.7.5 include/rocksdb/utilities/write_batch_with_index.h (NewIteratorWithBase)
+	NewIteratorWithBatch(opts *ReadOptions, wbwi *WriteBatchWithIndex) Iterator
+
+	// ScanRange calls fn with a copy of each entry in [begin, end) of the
+	// default column family, stopping at the first error fn returns.
+	ScanRange(opts *ReadOptions, begin, end []byte, fn func(key, value []byte) error) error
+
+	// Paginate returns a function that reads [begin, end) of the default
+	// column family in successive pages of about pageBytes on disk each,
+	// returning an empty page once the range is exhausted.
+	Paginate(opts *ReadOptions, begin, end []byte, pageBytes int) func() ([]KeyValue, error)
+
 	// GetSnapshot creates a new snapshot of the database.
 	GetSnapshot() *Snapshot
 
 	// ReleaseSnapshot releases a previously acquired snapshot.
 	ReleaseSnapshot(s *Snapshot)
 
+	// GetSnapshotCount returns the number of snapshots that have not been
+	// released.
+	GetSnapshotCount() int
+
+	// GetAliveSnapshotSequences returns the sequence numbers of the
+	// snapshots that have not been released, oldest first.
+	GetAliveSnapshotSequences() []uint64
+
 	// Flush flushes the memtable to disk.
 	Flush(opts *FlushOptions) error
 
@@ -110,9 +162,17 @@ type DB interface {
 	// GetProperty returns the value of a database property.
 	GetProperty(name string) (string, bool)
 
+	// GetPropertyCF returns the value of a property of a column family,
+	// as GetProperty does for the default column family.
+	GetPropertyCF(cf ColumnFamilyHandle, name string) (string, bool)
+
 	// CreateColumnFamily creates a new column family.
 	CreateColumnFamily(opts ColumnFamilyOptions, name string) (ColumnFamilyHandle, error)
 
+	// CreateColumnFamilyWithImport creates a new column family populated with
+	// SST files exported by Checkpoint.ExportColumnFamily.
+	CreateColumnFamilyWithImport(opts ColumnFamilyOptions, name string, importOpts ImportColumnFamilyOptions, metadata *ExportImportFilesMetaData) (ColumnFamilyHandle, error)
+
 	// DropColumnFamily drops the specified column family.
 	DropColumnFamily(cf ColumnFamilyHandle) error
 
@@ -129,12 +189,21 @@ type DB interface {
 	// If start and end are nil, the entire database is compacted.
 	CompactRange(opts *CompactRangeOptions, start, end []byte) error
 
+	// PlanCompaction returns what CompactRange would do for the same range
+	// and options in a column family, without compacting anything.
+	// A nil cf selects the default column family.
+	PlanCompaction(cf ColumnFamilyHandle, opts *CompactRangeOptions, start, end []byte) (*CompactionPlan, error)
+
 	// BeginTransaction begins a new optimistic transaction.
 	BeginTransaction(opts TransactionOptions, writeOpts *WriteOptions) Transaction
 
 	// IngestExternalFile loads external SST files into the database.
 	IngestExternalFile(paths []string, opts IngestExternalFileOptions) error
 
+	// IngestExternalFiles loads external SST files into one or more column
+	// families atomically: either every file is ingested or none is.
+	IngestExternalFiles(args []IngestExternalFileArg) error
+
 	// SyncWAL syncs the current WAL to disk, ensuring all data is durable.
 	// This is more expensive than FlushWAL(false) but provides stronger durability.
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1782-1789
@@ -159,6 +228,23 @@ type DB interface {
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1892-1897
 	GetLiveFilesMetaData() []LiveFileMetaData
 
+	// GetLiveFilesStorageInfo returns the files needed to copy the database
+	// as of one point in time, with how much of each to copy.
+	// Reference: RocksDB v10.7.5 include/rocksdb/db.h GetLiveFilesStorageInfo
+	GetLiveFilesStorageInfo(opts LiveFilesStorageInfoOptions) ([]LiveFileStorageInfo, error)
+
+	// CheckConsistency validates the invariants of the current version:
+	// file sizes, non-overlapping non-L0 levels, L0 sequence number order,
+	// and the recorded key range of each SST file against its contents.
+	// Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc (DBImpl::CheckConsistency)
+	CheckConsistency() error
+
+	// GetColumnFamilyMetaData returns the SST files of a column family
+	// grouped by level, with per-level and total sizes.
+	// A nil cf selects the default column family.
+	// Reference: RocksDB v10.7.5 include/rocksdb/db.h GetColumnFamilyMetaData
+	GetColumnFamilyMetaData(cf ColumnFamilyHandle) (*ColumnFamilyMetaData, error)
+
 	// DisableFileDeletions prevents file deletions. Call EnableFileDeletions when done.
 	// This is useful for making consistent backups.
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h
@@ -187,9 +273,31 @@ type DB interface {
 	NewIterators(opts *ReadOptions, cfs []ColumnFamilyHandle) ([]Iterator, error)
 
 	// GetApproximateSizes returns the approximate sizes of key ranges.
+	// A nil or empty Start or Limit leaves that side of a range open, so the
+	// zero Range covers the whole default column family.
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1533-1565
 	GetApproximateSizes(ranges []Range, flags SizeApproximationFlags) ([]uint64, error)
 
+	// GetApproximateSizesWithOptions is GetApproximateSizes driven by
+	// SizeApproximationOptions, e.g. to account for range tombstones.
+	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1533-1565
+	GetApproximateSizesWithOptions(opts *SizeApproximationOptions, ranges []Range) ([]uint64, error)
+
+	// GetApproximateSizesAtSnapshot returns the approximate on-disk sizes of
+	// key ranges of a column family as of a snapshot: SST files all of
+	// whose entries are newer than the snapshot are left out. A nil snapshot
+	// counts all files.
+	GetApproximateSizesAtSnapshot(snap *Snapshot, cf ColumnFamilyHandle, ranges []Range) ([]uint64, error)
+
+	// GetApproximateSplitKey returns a key dividing [begin, end) of a column
+	// family into two halves of approximately equal on-disk size.
+	GetApproximateSplitKey(cf ColumnFamilyHandle, begin, end []byte) ([]byte, error)
+
+	// GetApproximateKeyCount returns the approximate number of keys of a
+	// column family in each of the given ranges, estimated from SST table
+	// properties and index blocks.
+	GetApproximateKeyCount(cf ColumnFamilyHandle, ranges []Range) ([]uint64, error)
+
 	// GetOptions returns a copy of the current database options.
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1741-1748
 	GetOptions() Options
@@ -210,14 +318,47 @@ type DB interface {
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1366-1368
 	GetIntProperty(name string) (uint64, bool)
 
+	// GetIntPropertyCF returns an integer property value of a column family.
+	GetIntPropertyCF(cf ColumnFamilyHandle, name string) (uint64, bool)
+
 	// GetMapProperty returns a map property value.
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1370-1372
 	GetMapProperty(name string) (map[string]string, bool)
 
+	// GetWriteStats returns cumulative statistics of the write path, such as
+	// how many write groups the writes were batched into.
+	GetWriteStats() WriteStats
+
+	// GetBloomFilterStats returns the SST bloom filter counters of point
+	// lookups collected by Options.Statistics, and the false-positive rate
+	// they imply.
+	GetBloomFilterStats() BloomStats
+
+	// WriteMetrics writes Options.Statistics and the integer properties of
+	// the database to w in the Prometheus text exposition format.
+	WriteMetrics(w io.Writer) error
+
+	// GetActiveMemTableUsage returns the size of the active memtable of a
+	// column family and its fill ratio relative to WriteBufferSize, which
+	// reaches 1.0 when the memtable is due for a flush.
+	// A nil cf selects the default column family.
+	GetActiveMemTableUsage(cf ColumnFamilyHandle) (bytes uint64, ratio float64)
+
+	// WarmBlockCache reads the SST data blocks holding the keys of a column
+	// family in [begin, end) into Options.BlockCache, so that reads of the
+	// range hit the cache right after Open. A nil begin or end leaves that
+	// side of the range unbounded, and a nil cf selects the default column
+	// family. Does nothing without a block cache.
+	WarmBlockCache(cf ColumnFamilyHandle, begin, end []byte) error
+
 	// WaitForCompact waits for all compactions to complete.
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1705-1708
 	WaitForCompact(opts *WaitForCompactOptions) error
 
+	// FlushAndWaitForL0 flushes the column family's memtable and waits until
+	// the column family has fewer L0 files than Level0FileNumCompactionTrigger.
+	FlushAndWaitForL0(cf ColumnFamilyHandle) error
+
 	// LockWAL locks the WAL, preventing new writes.
 	// Reference: RocksDB v10.7.5 include/rocksdb/db.h lines 1791-1800
 	LockWAL() error
@@ -238,6 +379,10 @@ type ReplicationDB interface {
 
 	// GetSortedWalFiles returns WAL files sorted by log number.
 	GetSortedWalFiles() ([]WalFile, error)
+
+	// PurgeObsoleteWALFiles deletes the archived WAL files past
+	// Options.WALTTLSeconds or Options.WALSizeLimitMB right away.
+	PurgeObsoleteWALFiles() error
 }
 
 // WriteStallController exposes write-stall release for shutdown/unblocking.
@@ -268,12 +413,34 @@ type SecondaryDB interface {
 	// TryCatchUpWithPrimary refreshes the secondary view by reading new MANIFEST records.
 	TryCatchUpWithPrimary() error
 
+	// StartFollow starts catching up with the primary every interval in the
+	// background. Catch-up errors are delivered on FollowErrors.
+	StartFollow(interval time.Duration) error
+
+	// StopFollow stops background catch-up started by StartFollow.
+	StopFollow()
+
+	// FollowErrors returns the channel that receives background catch-up errors.
+	FollowErrors() <-chan error
+
 	// NewCheckpoint returns a checkpoint helper bound to the same underlying DB.
 	NewCheckpoint() *Checkpoint
 }
 
-// Open opens the database at the specified path.
+// Open opens the database at the specified path. It fails with
+// ErrNotSupported if the WAL holds two-phase commit transactions, which only
+// OpenTransactionDB or OpenWritePreparedTxnDB can resolve.
 func Open(path string, opts *Options) (DB, error) {
+	db, err := openDB(path, opts, prepareRefuse)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// openDB opens the database at path, replaying the prepare sections of
+// two-phase commit transactions in the WAL as prepares says.
+func openDB(path string, opts *Options, prepares prepareReplay) (*dbImpl, error) {
 	// This file contains sync points and kill points for whitebox
 	// testing. See docs/testing/README.md for usage
 
@@ -283,6 +450,12 @@ func Open(path string, opts *Options) (DB, error) {
 	if opts == nil {
 		opts = DefaultOptions()
 	}
+	if err := validateNumLevels(opts); err != nil {
+		return nil, err
+	}
+	if err := validateMemTableSkipList(opts); err != nil {
+		return nil, err
+	}
 
 	// Use default filesystem if not specified
 	fs := opts.FS
@@ -290,6 +463,12 @@ func Open(path string, opts *Options) (DB, error) {
 		fs = vfs.Default()
 	}
 
+	// Use default env if not specified
+	env := opts.Env
+	if env == nil {
+		env = DefaultEnv()
+	}
+
 	// Use default comparator if not specified
 	comparator := opts.Comparator
 	if comparator == nil {
@@ -314,27 +493,57 @@ func Open(path string, opts *Options) (DB, error) {
 		}
 	}
 
+	// Take the LOCK file so no other process can open the same directory.
+	// Reference: RocksDB v10.7.5 db/db_impl/db_impl_open.cc (LockFile)
+	dbLock, err := fs.Lock(filepath.Join(path, lockFileName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrDBLocked, path, err)
+	}
+
 	// Logger configuration: db.logger is NEVER nil after Open().
-	// If opts.Logger is nil or typed-nil, we use a default WARN logger.
+	// If opts.Logger is nil or typed-nil, we log to the LOG file, or to a
+	// default WARN logger if LOG cannot be created.
 	// This allows all components to call db.logger.Infof(...) without nil checks.
-	logger := logging.OrDefault(opts.Logger)
+	// Reference: RocksDB v10.7.5 db/db_impl/db_impl_open.cc (CreateLoggerFromOptions)
+	var infoLog *logging.FileLogger
+	if logging.IsNil(opts.Logger) {
+		infoLog, _ = logging.NewFileLogger(fs, path, logging.FileLoggerOptions{
+			MaxFileSize: opts.MaxLogFileSize,
+			TimeToRoll:  opts.LogFileTimeToRoll,
+			KeepFileNum: opts.KeepLogFileNum,
+		}, logging.LevelInfo)
+	}
+	var logger Logger
+	if infoLog != nil {
+		logger = infoLog
+	} else {
+		logger = logging.OrDefault(opts.Logger)
+	}
 
 	// Create the DB implementation
 	db := &dbImpl{
 		name:            path,
 		options:         opts,
 		fs:              fs,
+		env:             env,
 		comparator:      comparator,
 		cmp:             comparator,
 		shutdownCh:      make(chan struct{}),
-		tableCache:      table.NewTableCache(fs, table.DefaultTableCacheOptions()),
-		writeController: newWriteController(),
+		tableCache:      table.NewTableCache(fs, tableCacheOptions(opts)),
+		blobCache:       blob.NewCache(fs, path, blobCacheOptions(opts)),
+		blobGC:          newBlobGarbageCollector(fs, path, opts),
+		writeController: newWriteController(env),
 		logger:          logger,
+		infoLog:         infoLog,
+		openMicros:      env.NowMicros(),
+		dbLock:          dbLock,
+
+		prepares: prepares,
 	}
 
 	// Wire FatalHandler: when Fatalf is called, set background error to stop writes.
 	// This implements RocksDB-style "stopped" state instead of Pebble-style os.Exit(1).
-	if dl, ok := logger.(*logging.DefaultLogger); ok {
+	if dl, ok := logger.(interface{ SetFatalHandler(logging.FatalHandler) }); ok {
 		dl.SetFatalHandler(func(msg string) {
 			db.SetBackgroundError(fmt.Errorf("%w: %s", logging.ErrFatal, msg))
 		})
@@ -351,7 +560,7 @@ func Open(path string, opts *Options) (DB, error) {
 		DBName:              path,
 		FS:                  fs,
 		MaxManifestFileSize: 1024 * 1024 * 1024, // 1GB
-		NumLevels:           version.MaxNumLevels,
+		NumLevels:           opts.NumLevels,
 		Logger:              logger, // Pass through for MANIFEST logging
 	}
 	db.versions = version.NewVersionSet(vsOpts)
@@ -360,21 +569,36 @@ func Open(path string, opts *Options) (DB, error) {
 	if exists {
 		// Recover from existing database
 		if err := db.recover(); err != nil {
+			_ = dbLock.Close()
+			db.closeInfoLog()
 			return nil, err
 		}
+		if opts.PinTopLevelIndexAndFilter {
+			db.warmTableCache()
+		}
 		db.logger.Infof("[db] opened database at %s", path)
 	} else {
 		// Create new database
 		if err := db.create(); err != nil {
+			_ = dbLock.Close()
+			db.closeInfoLog()
 			return nil, err
 		}
 		db.logger.Infof("[db] created new database at %s", path)
 	}
+	db.visibleSeq.Store(db.seq)
+
+	// A rate limiter built without a clock follows the DB's Env
+	if rl, ok := opts.RateLimiter.(*GenericRateLimiter); ok {
+		rl.adoptClock(env)
+	}
 
 	// Start background workers
 	db.bgWork = newBackgroundWork(db, opts)
 	db.bgWork.start()
 
+	db.startStatsDump()
+
 	// Check if compaction is needed after recovery
 	db.bgWork.maybeScheduleCompaction()
 
@@ -384,6 +608,141 @@ func Open(path string, opts *Options) (DB, error) {
 	return db, nil
 }
 
+// validateNumLevels checks that opts.NumLevels is supported by the
+// compaction style.
+func validateNumLevels(opts *Options) error {
+	if opts.NumLevels < 0 || opts.NumLevels > version.MaxNumLevels {
+		return fmt.Errorf("%w: num_levels %d is not between 1 and %d",
+			ErrInvalidOptions, opts.NumLevels, version.MaxNumLevels)
+	}
+	if opts.NumLevels == 1 && opts.CompactionStyle != CompactionStyleFIFO {
+		return fmt.Errorf("%w: num_levels must be at least 2 unless compaction style is FIFO",
+			ErrInvalidOptions)
+	}
+	return nil
+}
+
+// validateMemTableSkipList checks the memtable skiplist parameters; 0
+// selects the default.
+func validateMemTableSkipList(opts *Options) error {
+	if bf := opts.MemTableSkipListBranchingFactor; bf < 0 || bf == 1 {
+		return fmt.Errorf("%w: memtable skiplist branching factor %d is less than 2",
+			ErrInvalidOptions, bf)
+	}
+	if h := opts.MemTableSkipListMaxHeight; h < 0 || h > memtable.MaxPossibleHeight {
+		return fmt.Errorf("%w: memtable skiplist max height %d is not between 1 and %d",
+			ErrInvalidOptions, h, memtable.MaxPossibleHeight)
+	}
+	return nil
+}
+
+// tableCacheOptions derives the table cache configuration from opts.
+// MaxOpenFiles of -1 keeps every table reader open.
+func tableCacheOptions(opts *Options) table.TableCacheOptions {
+	tcOpts := table.DefaultTableCacheOptions()
+	tcOpts.MaxOpenFiles = opts.MaxOpenFiles
+	tcOpts.BlockCache = opts.BlockCache
+	tcOpts.VerifyBlockCacheEntries = opts.VerifyBlockCacheEntries
+	return tcOpts
+}
+
+// memtableOptions derives the memtable configuration of a column family
+// from its write buffer size and memtable bloom filter settings.
+func memtableOptions(prefixExtractor PrefixExtractor, writeBufferSize int, bloomSizeRatio float64, wholeKeyFiltering bool) memtable.Options {
+	var opts memtable.Options
+	if bloomSizeRatio <= 0 || writeBufferSize <= 0 {
+		return opts
+	}
+	bloomBits := float64(writeBufferSize) * min(bloomSizeRatio, 0.25) * 8
+	opts.BloomBits = uint32(min(bloomBits, math.MaxUint32))
+	opts.WholeKeyFiltering = wholeKeyFiltering
+	opts.Prefix = filterPrefix(prefixExtractor)
+	return opts
+}
+
+// newMemTable creates a memtable for the default column family.
+func (db *dbImpl) newMemTable() *memtable.MemTable {
+	var memCmp memtable.Comparator
+	if db.comparator != nil {
+		memCmp = db.comparator.Compare
+	}
+	o := db.options
+	memOpts := memtableOptions(o.PrefixExtractor, o.WriteBufferSize, o.MemtablePrefixBloomSizeRatio, o.MemtableWholeKeyFiltering)
+	setMemTableRep(&memOpts, o)
+	return memtable.NewMemTableWithOptions(memCmp, memOpts)
+}
+
+// setMemTableRep configures the data structure that holds the entries of a
+// memtable from opts.
+func setMemTableRep(memOpts *memtable.Options, opts *Options) {
+	memOpts.SkipListMaxHeight = opts.MemTableSkipListMaxHeight
+	memOpts.SkipListBranchingFactor = opts.MemTableSkipListBranchingFactor
+	if opts.MemtableFactory != nil {
+		memOpts.NewRep = opts.MemtableFactory.newRep
+	}
+}
+
+// blobCacheOptions derives the blob file and value cache configuration
+// from opts.
+func blobCacheOptions(opts *Options) blob.CacheOptions {
+	bcOpts := blob.DefaultCacheOptions()
+	bcOpts.ValueCache = opts.BlobCache
+	return bcOpts
+}
+
+// warmTableCache opens the table readers of L0 and the base level in
+// parallel, loading their index and filter blocks, and keeps them pinned in
+// the table cache so the first reads after Open do not load them. At most
+// MaxOpenFiles readers are warmed. Files that fail to open are logged and
+// left for the read path to report.
+//
+// Reference: RocksDB v10.7.5 db/version_builder.cc (LoadTableHandlers)
+func (db *dbImpl) warmTableCache() {
+	v := db.versions.Current()
+	if v == nil {
+		return
+	}
+	files := slices.Clone(v.Files(0))
+	for level := 1; level < v.NumLevels(); level++ {
+		if base := v.Files(level); len(base) > 0 {
+			files = append(files, base...)
+			break
+		}
+	}
+	// Warmed readers stay pinned, so warm no more of them than the table
+	// cache holds
+	if limit := db.options.MaxOpenFiles; limit > 0 {
+		files = files[:min(len(files), max(0, limit-db.tableCache.Size()))]
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	work := make(chan *manifest.FileMetaData, len(files))
+	for _, f := range files {
+		work <- f
+	}
+	close(work)
+
+	var wg sync.WaitGroup
+	for range min(max(1, db.options.MaxFileOpeningThreads), len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range work {
+				// The reference is never released, which pins the reader
+				// until the file is evicted after compaction or the DB closes.
+				fileNum := f.FD.GetNumber()
+				if _, err := db.tableCache.Get(fileNum, db.sstFilePath(fileNum)); err != nil {
+					db.logger.Warnf("[db] failed to warm table %d: %v", fileNum, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	db.logger.Debugf("[db] warmed %d table readers", len(files))
+}
+
 // dbImpl is the concrete implementation of the DB interface.
 type dbImpl struct {
 	// Database path
@@ -392,6 +751,7 @@ type dbImpl struct {
 	// Configuration
 	options    *Options
 	fs         vfs.FS
+	env        Env
 	comparator Comparator
 	cmp        Comparator // Alias for comparator
 
@@ -405,11 +765,37 @@ type dbImpl struct {
 	logFile       vfs.WritableFile
 	logFileNumber uint64
 	logWriter     *wal.Writer
+	walBuffer     *walBuffer // Set with Options.ManualWalFlush
 
 	// MemTable (for default column family - kept for backward compatibility)
 	mem *memtable.MemTable
-	imm *memtable.MemTable // Immutable memtable being flushed
-	seq uint64             // Current sequence number
+	imm []*memtable.MemTable // Immutable memtables awaiting flush, newest first
+	seq uint64               // Current sequence number
+
+	// visibleSeq is a sequence number at most which every write is readable.
+	// A group takes its sequence numbers, advancing seq, before it releases
+	// db.mu to insert its batches, so a write numbered at most seq may still
+	// be missing from the memtables. A group publishes seq once inserted, and
+	// the next group publishes it again on entry, which takes in sequence
+	// numbers that ingestion assigned in between.
+	visibleSeq atomic.Uint64
+
+	// Flushed memtables kept for reads, newest first
+	// (see Options.MaxWriteBufferSizeToMaintain)
+	immHistory []*memtable.MemTable
+
+	// Groups concurrent writes, and counts what they write. With
+	// Options.TwoWriteQueues, WAL-only writes use nonMemWriteThread.
+	writeThread       writeThread
+	nonMemWriteThread writeThread
+	writeStats        writeStats
+
+	// logMu serializes WAL appends between the two write queues. Close
+	// holds it, along with mu, to close the WAL.
+	logMu sync.Mutex
+
+	// casMu serializes CompareAndSwap calls with each other
+	casMu sync.Mutex
 
 	// Column Families
 	columnFamilies *columnFamilySet
@@ -417,8 +803,14 @@ type dbImpl struct {
 	// Table cache for SST files
 	tableCache *table.TableCache
 
+	// Cache of open blob file readers, for values separated by EnableBlobFiles
+	blobCache *blob.Cache
+
+	// Tracks the blob files of installed SSTs and deletes obsolete ones
+	blobGC *blob.GarbageCollector
+
 	// Snapshots (linked list)
-	snapshots    *Snapshot
+	snapshots    *snapshotEntry
 	snapshotLock sync.Mutex
 
 	// Background work (compaction, flush)
@@ -435,12 +827,53 @@ type dbImpl struct {
 	// Condition variable for waiting on immutable memtable flush
 	immCond *sync.Cond
 
+	// flushMu serializes flush jobs so a background flush and a synchronous
+	// Flush never write the same immutable memtable twice.
+	flushMu sync.Mutex
+
+	// recoveredMem is the memtable that the WAL files replayed at open were
+	// recovered into. Flushing it makes those files obsolete.
+	// Only tracked when WAL recycling or archival is enabled.
+	recoveredMem *memtable.MemTable
+
+	// prepares says how recovery replays the prepare sections of the WAL;
+	// see openDB. recoveredPrepared maps the name of each prepared
+	// transaction left unresolved to its writes, with prepareAfterCommit.
+	prepares          prepareReplay
+	recoveredPrepared map[string]*recoveredPrepare
+
+	// minLogWithPrep, if set, returns the oldest WAL file holding a prepared
+	// transaction that is neither committed nor rolled back, or 0 if there
+	// is none. Such a file must stay live until the transaction is resolved.
+	// Reference: RocksDB v10.7.5 db/logs_with_prep_tracker.h
+	minLogWithPrep func() uint64
+
+	// walArchiveMu serializes moves into and purges of the WAL archive.
+	walArchiveMu sync.Mutex
+
 	// Logger for warnings and info
 	logger Logger
 
+	// openMicros is when the database was opened, for the uptime in
+	// rocksdb.stats.
+	openMicros uint64
+
+	// statsDumpStop and statsDumpDone stop the StatsDumpPeriodSec goroutine.
+	// Both are nil when the dumps are disabled.
+	statsDumpStop chan struct{}
+	statsDumpDone chan struct{}
+
+	// infoLog is the LOG file logger that Open created because
+	// Options.Logger was nil. It is closed with the database.
+	infoLog *logging.FileLogger
+
 	// Track if WAL-disabled warning has been logged (to avoid spam)
 	walDisabledWarned bool
 
+	// dbLock holds the LOCK file for the lifetime of a read-write open.
+	// Read-only and secondary instances leave it nil.
+	dbLock io.Closer
+
 	// Shutdown
 	closed     bool
 	shutdownCh chan struct{}
@@ -458,24 +891,13 @@ func (db *dbImpl) create() error {
 
 	// Create WAL
 	logNumber := db.versions.NextFileNumber()
-	logPath := db.logFilePath(logNumber)
-
-	logFile, err := db.fs.Create(logPath)
-	if err != nil {
+	if err := db.createLogFile(logNumber, nil); err != nil {
 		return err
 	}
-
-	db.logFile = logFile
-	db.logFileNumber = logNumber
-	db.logWriter = wal.NewWriter(logFile, logNumber, false /* not recyclable */)
 	db.logger.Debugf("[wal] created WAL file %d", logNumber)
 
 	// Create memtable with the configured comparator
-	var memCmp memtable.Comparator
-	if db.comparator != nil {
-		memCmp = db.comparator.Compare
-	}
-	db.mem = memtable.NewMemTable(memCmp)
+	db.mem = db.newMemTable()
 	db.seq = 0
 
 	// Log the WAL creation in MANIFEST
@@ -515,6 +937,13 @@ func (db *dbImpl) recover() error {
 	// Update the next CF ID based on what was in the MANIFEST
 	db.columnFamilies.setNextID(maxCF + 1)
 
+	// Cross-check the recovered MANIFEST against the files on disk.
+	if db.options.ParanoidChecks {
+		if err := db.checkConsistency(); err != nil {
+			return err
+		}
+	}
+
 	// CRITICAL: Delete orphaned SST files before WAL replay.
 	// Orphaned SSTs can contain sequences that aren't in the MANIFEST's LastSequence,
 	// leading to sequence reuse and internal key collisions after recovery.
@@ -527,18 +956,24 @@ func (db *dbImpl) recover() error {
 		return fmt.Errorf("WAL replay failed: %w", err)
 	}
 
-	// Create a new WAL for new writes
-	logNumber := db.versions.NextFileNumber()
-	logPath := db.logFilePath(logNumber)
+	if err := db.loadBlobFiles(); err != nil {
+		return fmt.Errorf("failed to load blob files: %w", err)
+	}
 
-	logFile, err := db.fs.Create(logPath)
-	if err != nil {
+	// Create a new WAL for new writes, reusing an obsolete one if recycling,
+	// or archive obsolete ones if archival is enabled
+	var recycle []uint64
+	switch {
+	case db.recycleLogs():
+		recycle = db.collectObsoleteLogs()
+	case db.walArchivalEnabled():
+		db.archiveObsoleteLogs()
+		db.purgeArchivedLogs()
+	}
+	logNumber := db.versions.NextFileNumber()
+	if err := db.createLogFile(logNumber, recycle); err != nil {
 		return err
 	}
-
-	db.logFile = logFile
-	db.logFileNumber = logNumber
-	db.logWriter = wal.NewWriter(logFile, logNumber, false /* not recyclable */)
 	db.logger.Debugf("[wal] created WAL file %d (post-recovery)", logNumber)
 
 	// Record NextFileNumber to prevent file number reuse, but do NOT update
@@ -550,6 +985,9 @@ func (db *dbImpl) recover() error {
 		// Only update NextFileNumber, NOT LogNumber
 		// LogNumber stays at the old value so older logs are replayed
 	}
+	if db.recycleLogs() || db.walArchivalEnabled() {
+		db.trackRecoveredLogs(edit, logNumber)
+	}
 	if err := db.versions.LogAndApply(edit); err != nil {
 		return err
 	}
@@ -584,13 +1022,27 @@ func (db *dbImpl) Get(opts *ReadOptions, key []byte) ([]byte, error) {
 }
 
 // GetCF retrieves the value for the given key from the specified column family.
+// For a wide-column entity it returns the value of the default column.
 func (db *dbImpl) GetCF(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([]byte, error) {
+	value, valueType, err := db.getImpl(opts, cf, key)
+	if err != nil {
+		return nil, err
+	}
+	if valueType == dbformat.TypeWideColumnEntity {
+		return defaultColumnValue(value)
+	}
+	return value, nil
+}
+
+// getImpl looks up the newest visible value of a key. The returned value is
+// owned by the caller; valueType is TypeValue or TypeWideColumnEntity.
+func (db *dbImpl) getImpl(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([]byte, dbformat.ValueType, error) {
 	// Whitebox [synctest]: barrier at Get start
 	_ = testutil.SP(testutil.SPDBGet)
 
 	cfd, err := db.getColumnFamilyData(cf)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if opts == nil {
@@ -600,7 +1052,7 @@ func (db *dbImpl) GetCF(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([
 	db.mu.RLock()
 	if db.closed {
 		db.mu.RUnlock()
-		return nil, ErrDBClosed
+		return nil, 0, ErrDBClosed
 	}
 
 	// Determine the snapshot sequence to use
@@ -610,17 +1062,35 @@ func (db *dbImpl) GetCF(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([
 	} else {
 		snapshot = db.seq
 	}
+	db.mu.RUnlock()
+
+	return db.getAtSequence(cfd, key, snapshot)
+}
+
+// getAtSequence returns the value of key in a column family as of sequence
+// number snapshot, with merge operands applied.
+func (db *dbImpl) getAtSequence(cfd *columnFamilyData, key []byte, snapshot uint64) ([]byte, dbformat.ValueType, error) {
+	return db.getAtSequenceWith(cfd, key, snapshot, db.getFromFile)
+}
+
+// getAtSequenceWith is getAtSequence with the SST file lookups done by
+// getFile.
+func (db *dbImpl) getAtSequenceWith(cfd *columnFamilyData, key []byte, snapshot uint64, getFile fileGetter) ([]byte, dbformat.ValueType, error) {
+	db.mu.RLock()
 
 	// Check memtable first (use column family's memtable if available)
-	var mem, imm *memtable.MemTable
+	var mem *memtable.MemTable
+	var imms []*memtable.MemTable
 	if cfd.id == DefaultColumnFamilyID {
 		mem = db.mem
-		imm = db.imm
+		imms = append(imms, db.imm...)
+		// Flushed memtables still hold the newest data below db.imm
+		imms = append(imms, db.immHistory...)
 	} else {
 		cfd.memMu.RLock()
 		mem = cfd.mem
 		if len(cfd.imm) > 0 {
-			imm = cfd.imm[0] // Check first immutable memtable
+			imms = append(imms, cfd.imm[0]) // Check first immutable memtable
 		}
 		cfd.memMu.RUnlock()
 	}
@@ -631,45 +1101,45 @@ func (db *dbImpl) GetCF(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([
 
 	// Lookup in memtable (with merge support)
 	if mem != nil {
-		baseValue, memOperands, foundBase, deleted := mem.CollectMergeOperands(key, dbformat.SequenceNumber(snapshot))
+		baseValue, baseType, memOperands, foundBase, deleted := mem.CollectMergeOperands(key, dbformat.SequenceNumber(snapshot))
 		if deleted {
 			// Key was deleted - if we have merge operands, apply them with nil base
 			if len(memOperands) > 0 {
-				return db.applyMerge(key, nil, memOperands)
+				return db.mergeEntry(key, nil, dbformat.TypeValue, memOperands)
 			}
-			return nil, ErrNotFound
+			return nil, 0, ErrNotFound
 		}
 		if foundBase {
 			// Found a value - if we have merge operands, apply them
 			if len(memOperands) > 0 {
-				return db.applyMerge(key, baseValue, memOperands)
+				return db.mergeEntry(key, baseValue, baseType, memOperands)
 			}
 			// IMPORTANT: Copy the value to prevent aliasing with memtable internal data.
 			// Users may modify the returned slice, and we must not corrupt internal state.
 			// Reference: RocksDB uses PinnableSlice::PinSelf() which copies the data.
-			return copySlice(baseValue), nil
+			return copySlice(baseValue), baseType, nil
 		}
 		// Collect any merge operands found
 		mergeOperands = append(mergeOperands, memOperands...)
 	}
 
-	// Lookup in immutable memtable (with merge support)
-	if imm != nil {
-		baseValue, immOperands, foundBase, deleted := imm.CollectMergeOperands(key, dbformat.SequenceNumber(snapshot))
+	// Lookup in immutable memtables, newest first (with merge support)
+	for _, imm := range imms {
+		baseValue, baseType, immOperands, foundBase, deleted := imm.CollectMergeOperands(key, dbformat.SequenceNumber(snapshot))
 		if deleted {
 			if len(mergeOperands) > 0 || len(immOperands) > 0 {
 				allOperands := append(mergeOperands, immOperands...)
-				return db.applyMerge(key, nil, allOperands)
+				return db.mergeEntry(key, nil, dbformat.TypeValue, allOperands)
 			}
-			return nil, ErrNotFound
+			return nil, 0, ErrNotFound
 		}
 		if foundBase {
 			allOperands := append(mergeOperands, immOperands...)
 			if len(allOperands) > 0 {
-				return db.applyMerge(key, baseValue, allOperands)
+				return db.mergeEntry(key, baseValue, baseType, allOperands)
 			}
 			// IMPORTANT: Copy the value to prevent aliasing with memtable internal data.
-			return copySlice(baseValue), nil
+			return copySlice(baseValue), baseType, nil
 		}
 		// Collect any merge operands found
 		mergeOperands = append(mergeOperands, immOperands...)
@@ -685,34 +1155,39 @@ func (db *dbImpl) GetCF(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([
 
 	if current != nil {
 		defer current.Unref()
-		value, err := db.getFromVersionWithMerge(current, key, dbformat.SequenceNumber(snapshot), mergeOperands, cfd.id)
+		value, valueType, err := db.getFromVersionWithMerge(current, key, dbformat.SequenceNumber(snapshot), mergeOperands, cfd.id, getFile)
 		if err == nil {
-			return value, nil
+			return value, valueType, nil
 		}
 		if !errors.Is(err, ErrNotFound) {
 			// Log corruption errors - critical for debugging silent data corruption
 			if errors.Is(err, table.ErrChecksumMismatch) {
 				db.logger.Errorf("[corruption] checksum mismatch reading SST file for key %x: %v", key, err)
 			}
-			return nil, err
+			return nil, 0, err
 		}
 	}
 
 	// If we only have merge operands but no base value was found, apply merge with nil base
 	if len(mergeOperands) > 0 {
-		return db.applyMerge(key, nil, mergeOperands)
+		return db.mergeEntry(key, nil, dbformat.TypeValue, mergeOperands)
 	}
 
-	return nil, ErrNotFound
+	return nil, 0, ErrNotFound
 }
 
 // MultiGet retrieves multiple values for the given keys.
 // Returns a slice of values in the same order as keys.
 // If a key doesn't exist, the corresponding value is nil and error is ErrNotFound.
+// With ReadOptions.AsyncIO the SST files are read concurrently; see
+// multiget_async.go.
 func (db *dbImpl) MultiGet(opts *ReadOptions, keys [][]byte) ([][]byte, []error) {
 	if len(keys) == 0 {
 		return nil, nil
 	}
+	if opts != nil && opts.AsyncIO && len(keys) > 1 {
+		return db.multiGetAsync(opts, keys)
+	}
 
 	values := make([][]byte, len(keys))
 	errors := make([]error, len(keys))
@@ -731,14 +1206,18 @@ func (db *dbImpl) MultiGet(opts *ReadOptions, keys [][]byte) ([][]byte, []error)
 // getFromVersion searches for a key in the SST files of a version.
 // It also handles merge operands by collecting them and applying the merge operator.
 // Reserved for future use - currently getFromVersionWithMerge is used directly.
-func (db *dbImpl) getFromVersion(v *version.Version, key []byte, seq dbformat.SequenceNumber, cfID uint32) ([]byte, error) { //nolint:unused // reserved for future use
-	return db.getFromVersionWithMerge(v, key, seq, nil, cfID)
+func (db *dbImpl) getFromVersion(v *version.Version, key []byte, seq dbformat.SequenceNumber, cfID uint32) ([]byte, dbformat.ValueType, error) { //nolint:unused // reserved for future use
+	return db.getFromVersionWithMerge(v, key, seq, nil, cfID, db.getFromFile)
 }
 
+// fileGetter looks up a key in one SST file; see getFromFile.
+type fileGetter func(f *manifest.FileMetaData, key []byte, seq dbformat.SequenceNumber, rangeDelAgg *rangedel.RangeDelAggregator) ([]byte, bool, bool, dbformat.ValueType, dbformat.SequenceNumber, error)
+
 // getFromVersionWithMerge searches for a key in SST files and handles merge operands.
 // mergeOperands contains any merge operands already collected from memtable.
 // cfID specifies which column family to search in (for CF isolation).
-func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq dbformat.SequenceNumber, mergeOperands [][]byte, cfID uint32) ([]byte, error) {
+// getFile looks up the key in each file that may hold it.
+func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq dbformat.SequenceNumber, mergeOperands [][]byte, cfID uint32, getFile fileGetter) ([]byte, dbformat.ValueType, error) {
 	// Create a range deletion aggregator to track tombstones across files.
 	// The upperBound is the snapshot sequence - tombstones with seq > upperBound are invisible.
 	rangeDelAgg := rangedel.NewRangeDelAggregator(seq)
@@ -748,6 +1227,7 @@ func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq db
 	// For L1+, files are sorted and non-overlapping, so we can binary search
 
 	var existingValue []byte
+	existingType := dbformat.TypeValue
 	foundBase := false
 
 	// Search L0 files (newest first)
@@ -768,20 +1248,20 @@ func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq db
 		}
 
 		// Key might be in this file, search it
-		value, found, deleted, isMerge, foundSeq, err := db.getFromFile(f, key, seq, rangeDelAgg)
+		value, found, deleted, valueType, foundSeq, err := getFile(f, key, seq, rangeDelAgg)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		if found {
 			// Check if the found value is covered by a range tombstone
 			if deleted || rangeDelAgg.ShouldDelete(key, foundSeq) {
 				// Base is deleted - apply merge with nil base
 				if len(mergeOperands) > 0 {
-					return db.applyMerge(key, nil, mergeOperands)
+					return db.mergeEntry(key, nil, dbformat.TypeValue, mergeOperands)
 				}
-				return nil, ErrNotFound
+				return nil, 0, ErrNotFound
 			}
-			if isMerge {
+			if valueType == dbformat.TypeMerge {
 				// Collect this merge operand and continue searching
 				mergeOperands = append(mergeOperands, value)
 				continue
@@ -789,6 +1269,7 @@ func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq db
 			// Found a value - this is the base
 			foundBase = true
 			existingValue = value
+			existingType = valueType
 			break
 		}
 	}
@@ -824,20 +1305,20 @@ func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq db
 				}
 
 				// Key might be in this file
-				value, found, deleted, isMerge, foundSeq, err := db.getFromFile(f, key, seq, rangeDelAgg)
+				value, found, deleted, valueType, foundSeq, err := getFile(f, key, seq, rangeDelAgg)
 				if err != nil {
-					return nil, err
+					return nil, 0, err
 				}
 				if found {
 					// Check if the found value is covered by a range tombstone
 					if deleted || rangeDelAgg.ShouldDelete(key, foundSeq) {
 						// Base is deleted - apply merge with nil base
 						if len(mergeOperands) > 0 {
-							return db.applyMerge(key, nil, mergeOperands)
+							return db.mergeEntry(key, nil, dbformat.TypeValue, mergeOperands)
 						}
-						return nil, ErrNotFound
+						return nil, 0, ErrNotFound
 					}
-					if isMerge {
+					if valueType == dbformat.TypeMerge {
 						// Collect this merge operand and continue searching
 						mergeOperands = append(mergeOperands, value)
 						continue
@@ -845,6 +1326,7 @@ func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq db
 					// Found a value - this is the base
 					foundBase = true
 					existingValue = value
+					existingType = valueType
 					break
 				}
 			}
@@ -856,37 +1338,54 @@ func (db *dbImpl) getFromVersionWithMerge(v *version.Version, key []byte, seq db
 
 	// Apply merge if we have operands
 	if len(mergeOperands) > 0 {
-		return db.applyMerge(key, existingValue, mergeOperands)
+		return db.mergeEntry(key, existingValue, existingType, mergeOperands)
 	}
 
 	if foundBase {
 		// IMPORTANT: Copy the value to prevent aliasing with cached block data.
 		// SST block data is cached and shared; users must not modify returned values.
-		return copySlice(existingValue), nil
+		return copySlice(existingValue), existingType, nil
 	}
 
-	return nil, ErrNotFound
+	return nil, 0, ErrNotFound
 }
 
 // applyMerge applies the merge operator to resolve merge operands.
 // operands are in newest-first order, so we reverse them for FullMerge.
 func (db *dbImpl) applyMerge(key []byte, existingValue []byte, operands [][]byte) ([]byte, error) {
-	if db.options.MergeOperator == nil {
-		return nil, ErrMergeOperatorNotSet
-	}
+	return fullMergeOperands(db.options.MergeOperator, key, existingValue, operands)
+}
 
-	// Reverse operands to get oldest-first order for FullMerge
-	reversed := make([][]byte, len(operands))
-	for i, op := range operands {
-		reversed[len(operands)-1-i] = op
+// mergeEntry applies merge operands on top of a base value of the given
+// type. The operands of a wide-column entity are applied to its default
+// column, keeping the other columns.
+// Reference: RocksDB v10.7.5 db/merge_helper.cc (MergeHelper::TimedFullMerge)
+func (db *dbImpl) mergeEntry(key []byte, base []byte, baseType dbformat.ValueType, operands [][]byte) ([]byte, dbformat.ValueType, error) {
+	if baseType != dbformat.TypeWideColumnEntity {
+		value, err := db.applyMerge(key, base, operands)
+		return value, dbformat.TypeValue, err
 	}
 
-	result, ok := db.options.MergeOperator.FullMerge(key, existingValue, reversed)
-	if !ok {
-		return nil, fmt.Errorf("merge operator failed for key %q", key)
+	columns, err := wide.Deserialize(base)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrCorruption, err)
 	}
-
-	return result, nil
+	var defaultValue []byte
+	if len(columns) > 0 && len(columns[0].Name) == 0 {
+		defaultValue = columns[0].Value
+	} else {
+		columns = append([]wide.Column{{Name: []byte{}}}, columns...)
+	}
+	merged, err := db.applyMerge(key, defaultValue, operands)
+	if err != nil {
+		return nil, 0, err
+	}
+	columns[0].Value = merged
+	entity, err := wide.Serialize(columns)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entity, dbformat.TypeWideColumnEntity, nil
 }
 
 // copySlice creates a copy of a byte slice to prevent aliasing with internal buffers.
@@ -911,14 +1410,16 @@ func extractUserKey(internalKey []byte) []byte {
 
 // getFromFile searches for a key in a single SST file.
 // It also loads range tombstones from the file and adds them to the aggregator.
-// Returns: value, found, deleted, isMerge, foundSeqNum, error
-func (db *dbImpl) getFromFile(f *manifest.FileMetaData, key []byte, seq dbformat.SequenceNumber, rangeDelAgg *rangedel.RangeDelAggregator) ([]byte, bool, bool, bool, dbformat.SequenceNumber, error) {
+// Returns: value, found, deleted, valueType, foundSeqNum, error
+// valueType is TypeMerge for a merge operand, otherwise TypeValue or
+// TypeWideColumnEntity, with blob references already resolved.
+func (db *dbImpl) getFromFile(f *manifest.FileMetaData, key []byte, seq dbformat.SequenceNumber, rangeDelAgg *rangedel.RangeDelAggregator) ([]byte, bool, bool, dbformat.ValueType, dbformat.SequenceNumber, error) {
 	fileNum := f.FD.GetNumber()
 	path := db.sstFilePath(fileNum)
 
 	reader, err := db.tableCache.Get(fileNum, path)
 	if err != nil {
-		return nil, false, false, false, 0, err
+		return nil, false, false, 0, 0, err
 	}
 	defer db.tableCache.Release(fileNum)
 
@@ -934,6 +1435,18 @@ func (db *dbImpl) getFromFile(f *manifest.FileMetaData, key []byte, seq dbformat
 		}
 	}
 
+	// A filter that rules the key out saves reading the data block
+	hasFilter := reader.WholeKeyFiltering()
+	if hasFilter {
+		if !reader.KeyMayMatch(key) {
+			db.recordTick(TickerBloomFilterUseful, 1)
+			return nil, false, false, 0, 0, nil
+		}
+		db.recordTick(TickerBloomFilterFullPositive, 1)
+	}
+
+	reader.RecordRead()
+
 	// Create seek key: userKey + seq for this lookup
 	seekKey := makeInternalKey(key, uint64(seq), dbformat.ValueTypeForSeek)
 
@@ -941,14 +1454,17 @@ func (db *dbImpl) getFromFile(f *manifest.FileMetaData, key []byte, seq dbformat
 	iter.Seek(seekKey)
 
 	if !iter.Valid() {
-		return nil, false, false, false, 0, nil
+		return nil, false, false, 0, 0, iter.Error()
 	}
 
 	// Check if we found the right key
 	foundKey := iter.Key()
 	foundUserKey := extractUserKey(foundKey)
 	if db.cmp.Compare(foundUserKey, key) != 0 {
-		return nil, false, false, false, 0, nil
+		return nil, false, false, 0, 0, nil
+	}
+	if hasFilter {
+		db.recordTick(TickerBloomFilterFullTruePositive, 1)
 	}
 
 	// Extract sequence number and value type from internal key
@@ -956,14 +1472,26 @@ func (db *dbImpl) getFromFile(f *manifest.FileMetaData, key []byte, seq dbformat
 	valueType := extractValueType(foundKey)
 
 	if valueType == dbformat.TypeDeletion || valueType == dbformat.TypeSingleDeletion {
-		return nil, true, true, false, foundSeq, nil
+		return nil, true, true, valueType, foundSeq, nil
 	}
 
 	if valueType == dbformat.TypeMerge {
-		return iter.Value(), true, false, true, foundSeq, nil
+		return iter.Value(), true, false, valueType, foundSeq, nil
+	}
+
+	if valueType == dbformat.TypeBlobIndex {
+		value, err := db.resolveBlobIndex(iter.Value())
+		if err != nil {
+			return nil, false, false, 0, 0, err
+		}
+		return value, true, false, dbformat.TypeValue, foundSeq, nil
 	}
 
-	return iter.Value(), true, false, false, foundSeq, nil
+	if valueType == dbformat.TypeWideColumnEntity {
+		return iter.Value(), true, false, valueType, foundSeq, nil
+	}
+
+	return iter.Value(), true, false, dbformat.TypeValue, foundSeq, nil
 }
 
 // makeInternalKey constructs an internal key from user key, sequence, and type.
@@ -1108,6 +1636,14 @@ func (db *dbImpl) MergeCF(opts *WriteOptions, cf ColumnFamilyHandle, key, value
 
 // Write applies a batch of operations atomically.
 func (db *dbImpl) Write(opts *WriteOptions, wb *WriteBatch) error {
+	return db.writeWithCallback(opts, wb, nil)
+}
+
+// writeWithCallback applies wb like Write, but first runs callback with db.mu
+// held, before sequence numbers are assigned. If callback returns an error the
+// batch is not written and that error is returned.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_write.cc WriteWithCallback
+func (db *dbImpl) writeWithCallback(opts *WriteOptions, wb *WriteBatch, callback func() error) error {
 	// Whitebox [synctest]: barrier at Write start
 	_ = testutil.SP(testutil.SPDBWrite)
 
@@ -1118,30 +1654,89 @@ func (db *dbImpl) Write(opts *WriteOptions, wb *WriteBatch) error {
 	// Get the internal batch for low-level operations
 	internal := wb.internalBatch()
 
+	// Verify per-entry protection before the batch reaches the WAL
+	if err := protectWriteBatch(opts, internal); err != nil {
+		return err
+	}
+
 	// Check write stall condition and wait if needed
 	writeSize := len(internal.Data())
-	db.writeController.maybeStallWrite(writeSize)
+	if db.writeController.maybeStallWrite(writeSize) {
+		db.writeStats.stalledWrites.Add(1)
+	}
+
+	// Join a write group; unless w leads it, its leader writes the batch
+	w := newWriter(internal, opts, callback)
+	if db.options.TwoWriteQueues && callback == nil && internal.Count() == 0 {
+		db.nonMemWriteThread.write(w, db.writeNonMemGroup)
+	} else {
+		db.writeThread.write(w, db.writeGroup)
+	}
+
+	// Whitebox [synctest]: barrier at Write complete
+	_ = testutil.SP(testutil.SPDBWriteComplete)
+
+	return w.err
+}
+
+// writeGroup writes the batches of a write group, setting each writer's err.
+// The WAL records of the group are synced once, if the leader asks for it.
+func (db *dbImpl) writeGroup(group []*writer) {
+	leader := group[0]
+	fail := func(err error) {
+		for _, w := range group {
+			w.err = err
+		}
+	}
 
 	db.mu.Lock()
 	if db.closed {
 		db.mu.Unlock()
-		return ErrDBClosed
+		fail(ErrDBClosed)
+		return
 	}
 	// Check for unrecoverable background error
 	if db.backgroundError != nil {
 		err := fmt.Errorf("%w: %w", ErrBackgroundError, db.backgroundError)
 		db.mu.Unlock()
-		return err
+		fail(err)
+		return
+	}
+	// Earlier groups are in the memtables
+	db.visibleSeq.Store(db.seq)
+	if leader.callback != nil {
+		if err := leader.callback(); err != nil {
+			db.mu.Unlock()
+			fail(err)
+			return
+		}
+	}
+
+	// A batch naming a missing column family fails as a whole, before any of
+	// its records reach the WAL or a memtable
+	valid := group[:0:0]
+	for _, w := range group {
+		if err := w.batch.Iterate(&columnFamilyChecker{db: db}); err != nil {
+			w.err = err
+			continue
+		}
+		valid = append(valid, w)
+	}
+	if len(valid) == 0 {
+		db.mu.Unlock()
+		return
 	}
+	group = valid
 
 	// Assign sequence numbers
-	count := internal.Count()
-	firstSeq := db.seq + 1
-	internal.SetSequence(firstSeq)
-	db.seq += uint64(count)
+	for _, w := range group {
+		w.batch.SetSequence(db.seq + 1)
+		db.seq += uint64(w.batch.Count())
+	}
+	lastSeq := db.seq
 
 	// Write to WAL (unless disabled)
-	if opts.DisableWAL {
+	if leader.opts.DisableWAL {
 		// Warn once about data loss risk
 		if !db.walDisabledWarned {
 			db.walDisabledWarned = true
@@ -1151,17 +1746,28 @@ func (db *dbImpl) Write(opts *WriteOptions, wb *WriteBatch) error {
 		// Whitebox [synctest]: barrier before WAL write
 		_ = testutil.SP(testutil.SPDBWriteWAL)
 
-		data := internal.Data()
-		if _, err := db.logWriter.AddRecord(data); err != nil {
-			db.mu.Unlock()
-			return err
+		db.logMu.Lock()
+		for _, w := range group {
+			data := db.walData(w.batch)
+			if data == nil {
+				continue
+			}
+			if _, err := db.logWriter.AddRecord(data); err != nil {
+				db.logMu.Unlock()
+				db.mu.Unlock()
+				fail(err)
+				return
+			}
+			db.writeStats.walBytes.Add(uint64(len(data)))
 		}
+		db.logMu.Unlock()
 
 		// Sync if requested
-		if opts.Sync && db.logWriter != nil {
+		if leader.opts.Sync && db.logWriter != nil {
 			if err := db.logWriter.Sync(); err != nil {
 				db.mu.Unlock()
-				return err
+				fail(err)
+				return
 			}
 		}
 
@@ -1173,35 +1779,270 @@ func (db *dbImpl) Write(opts *WriteOptions, wb *WriteBatch) error {
 	_ = testutil.SP(testutil.SPDBWriteMemtable)
 
 	// Capture memtable reference while holding lock to avoid race with Flush
-	seq := firstSeq
 	mem := db.mem
-	handler := &memtableInserter{
-		db:         db,
-		sequence:   seq,
-		defaultMem: mem,
-	}
 	db.mu.Unlock()
 
-	// Iterate through the batch and apply to memtables
-	if err := internal.Iterate(handler); err != nil {
-		return err
+	// Iterate through the batches and apply them to memtables
+	for _, w := range group {
+		handler := &memtableInserter{
+			db:         db,
+			sequence:   w.batch.Sequence(),
+			defaultMem: mem,
+			prot:       w.batch.NewProtectionVerifier(),
+		}
+		w.err = w.batch.Iterate(handler)
+		if w.err != nil {
+			// The batch is in the WAL but only partly in the memtable
+			db.SetBackgroundError(w.err)
+		}
+		db.writeStats.memtableBytes.Add(uint64(w.batch.Size()))
 	}
+	db.visibleSeq.Store(lastSeq)
+	db.writeStats.writes.Add(uint64(len(group)))
+	db.writeStats.groups.Add(1)
 
 	// Whitebox [synctest]: barrier after memtable insert
 	_ = testutil.SP(testutil.SPDBWriteMemtableComplete)
+}
 
-	// Whitebox [synctest]: barrier at Write complete
-	_ = testutil.SP(testutil.SPDBWriteComplete)
+// writeNonMemGroup writes a group of batches without memtable entries from
+// the second write queue of Options.TwoWriteQueues. The batches only append
+// to the WAL, so db.mu is held just to read the state: the appends are
+// ordered with those of the main queue by logMu.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_write.cc (WriteImplWALOnly)
+func (db *dbImpl) writeNonMemGroup(group []*writer) {
+	leader := group[0]
+	fail := func(err error) {
+		for _, w := range group {
+			w.err = err
+		}
+	}
 
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		fail(ErrDBClosed)
+		return
+	}
+	if db.backgroundError != nil {
+		err := fmt.Errorf("%w: %w", ErrBackgroundError, db.backgroundError)
+		db.mu.RUnlock()
+		fail(err)
+		return
+	}
+	seq := db.seq
+	db.mu.RUnlock()
+
+	if !leader.opts.DisableWAL {
+		db.logMu.Lock()
+		logWriter := db.logWriter
+		if logWriter == nil {
+			db.logMu.Unlock()
+			fail(ErrDBClosed)
+			return
+		}
+		for _, w := range group {
+			// The batches take no sequence numbers of their own
+			w.batch.SetSequence(seq + 1)
+			data := w.batch.Data()
+			if _, err := logWriter.AddRecord(data); err != nil {
+				db.logMu.Unlock()
+				fail(err)
+				return
+			}
+			db.writeStats.walBytes.Add(uint64(len(data)))
+		}
+		db.logMu.Unlock()
+
+		if leader.opts.Sync {
+			if err := logWriter.Sync(); err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+	db.writeStats.writes.Add(uint64(len(group)))
+	db.writeStats.groups.Add(1)
+}
+
+// writeWALOnly appends b to the WAL without applying it to a memtable, as
+// the prepare section of a transaction is written. b takes no sequence
+// numbers; the transaction's writes get theirs when it commits.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_write.cc (WriteImplWALOnly)
+func (db *dbImpl) writeWALOnly(opts *WriteOptions, b *batch.WriteBatch) error {
+	if opts == nil {
+		opts = DefaultWriteOptions()
+	}
+	if opts.DisableWAL {
+		return ErrTransactionWALDisabled
+	}
+	w := newWriter(b, opts, nil)
+	db.nonMemWriteThread.write(w, db.writeNonMemGroup)
+	return w.err
+}
+
+// errCASRetry reports that key was written between a CompareAndSwap read and
+// its write, so the comparison must be redone.
+var errCASRetry = errors.New("db: compare-and-swap retry")
+
+// CompareAndSwap sets key to newValue only if its current value equals
+// expected, where a nil expected means the key must not exist.
+//
+// The current value is read at the last sequence number whose write is in
+// the memtables, and the write is committed only if key has not been written
+// since; otherwise the read and comparison are retried. Writes to other keys
+// do not cause a retry. Reading at db.seq instead could miss a write
+// numbered before it but still being inserted, which the check for later
+// writes would miss too.
+func (db *dbImpl) CompareAndSwap(opts *WriteOptions, key, expected, newValue []byte) (bool, error) {
+	db.casMu.Lock()
+	defer db.casMu.Unlock()
+
+	for {
+		readSeq := db.visibleSeq.Load()
+
+		current, err := db.Get(&ReadOptions{Snapshot: &Snapshot{sequence: readSeq}}, key)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			if expected != nil {
+				return false, nil
+			}
+		case err != nil:
+			return false, err
+		case expected == nil || !bytes.Equal(current, expected):
+			return false, nil
+		}
+
+		wb := NewWriteBatch()
+		wb.Put(key, newValue)
+		err = db.writeWithCallback(opts, wb, func() error {
+			if db.keyWrittenSince(key, dbformat.SequenceNumber(readSeq)) {
+				return errCASRetry
+			}
+			return nil
+		})
+		if errors.Is(err, errCASRetry) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// keyWrittenSince reports whether key in the default column family may have
+// been written, or covered by a range deletion, after seq. Entries newer than
+// seq are in the memtables unless a flush has run since; a file holding
+// them that overlaps key counts as a write, so that no SST is read here.
+// REQUIRES: db.mu is held.
+func (db *dbImpl) keyWrittenSince(key []byte, seq dbformat.SequenceNumber) bool {
+	if dbformat.SequenceNumber(db.seq) == seq {
+		return false
+	}
+	for _, mem := range append([]*memtable.MemTable{db.mem}, db.imm...) {
+		if mem == nil {
+			continue
+		}
+		if latest, ok := latestSeqInMemTable(mem, key); ok && latest > seq {
+			return true
+		}
+		for _, t := range mem.GetRangeTombstones().All() {
+			if t.SequenceNum > seq && t.Contains(key) {
+				return true
+			}
+		}
+	}
+
+	current := db.versions.Current()
+	if current == nil {
+		return false
+	}
+	for level := range current.NumLevels() {
+		for _, f := range current.Files(level) {
+			if f.ColumnFamilyID == DefaultColumnFamilyID &&
+				dbformat.SequenceNumber(f.FD.LargestSeqno) > seq &&
+				db.cmp.Compare(key, extractUserKey(f.Smallest)) >= 0 &&
+				db.cmp.Compare(key, extractUserKey(f.Largest)) <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// protectWriteBatch verifies the entries of b if it was protected before
+// Write, then protects b if opts asks for it. A batch protected only here
+// has nothing to verify yet: its entries are checked as the memtable
+// inserter applies them, after the batch was logged.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_write.cc (WriteImpl)
+func protectWriteBatch(opts *WriteOptions, b *batch.WriteBatch) error {
+	if err := b.VerifyProtectionInfo(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruption, err)
+	}
+	if opts.ProtectionBytesPerKey != 0 {
+		if err := b.UpdateProtectionInfo(opts.ProtectionBytesPerKey); err != nil {
+			if errors.Is(err, batch.ErrUnsupportedProtectionBytes) {
+				return fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+			}
+			return fmt.Errorf("%w: %w", ErrCorruption, err)
+		}
+	}
 	return nil
 }
 
+// columnFamilyChecker is a batch handler that fails on the first record for a
+// column family that does not exist.
+// REQUIRES: db.mu is held, so the column family cannot be dropped before the
+// batch is applied.
+type columnFamilyChecker struct {
+	db *dbImpl
+}
+
+func (c *columnFamilyChecker) check(cfID uint32) error {
+	if cfID == DefaultColumnFamilyID || c.db.columnFamilies.getByID(cfID) != nil {
+		return nil
+	}
+	return fmt.Errorf("%w: id %d in write batch", ErrColumnFamilyNotFound, cfID)
+}
+
+func (c *columnFamilyChecker) Put(_, _ []byte) error                      { return nil }
+func (c *columnFamilyChecker) Delete(_ []byte) error                      { return nil }
+func (c *columnFamilyChecker) SingleDelete(_ []byte) error                { return nil }
+func (c *columnFamilyChecker) Merge(_, _ []byte) error                    { return nil }
+func (c *columnFamilyChecker) DeleteRange(_, _ []byte) error              { return nil }
+func (c *columnFamilyChecker) LogData(_ []byte)                           {}
+func (c *columnFamilyChecker) PutEntity(_, _ []byte) error                { return nil }
+func (c *columnFamilyChecker) PutCF(cfID uint32, _, _ []byte) error       { return c.check(cfID) }
+func (c *columnFamilyChecker) DeleteCF(cfID uint32, _ []byte) error       { return c.check(cfID) }
+func (c *columnFamilyChecker) SingleDeleteCF(cfID uint32, _ []byte) error { return c.check(cfID) }
+func (c *columnFamilyChecker) MergeCF(cfID uint32, _, _ []byte) error     { return c.check(cfID) }
+func (c *columnFamilyChecker) DeleteRangeCF(cfID uint32, _, _ []byte) error {
+	return c.check(cfID)
+}
+func (c *columnFamilyChecker) PutEntityCF(cfID uint32, _, _ []byte) error { return c.check(cfID) }
+
 // memtableInserter applies batch operations to a memtable.
 type memtableInserter struct {
 	db         *dbImpl
 	sequence   uint64
 	defaultMem *memtable.MemTable // Captured at write time to avoid race with flush
 	lockHeld   bool               // True if caller already holds db.mu (e.g., during recovery)
+
+	// prot verifies each entry before it is inserted; nil if the batch is
+	// unprotected
+	prot *batch.ProtectionVerifier
+}
+
+// verify checks the protection info of the entry about to be inserted.
+func (m *memtableInserter) verify(tag byte, cfID uint32, key, value []byte) error {
+	if m.prot == nil {
+		return nil
+	}
+	if err := m.prot.Verify(tag, cfID, key, value); err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruption, err)
+	}
+	return nil
 }
 
 func (m *memtableInserter) getMemtable(cfID uint32) *memtable.MemTable {
@@ -1226,17 +2067,37 @@ func (m *memtableInserter) Put(key, value []byte) error {
 }
 
 func (m *memtableInserter) PutCF(cfID uint32, key, value []byte) error {
+	if err := m.verify(batch.TypeValue, cfID, key, value); err != nil {
+		return err
+	}
 	mem := m.getMemtable(cfID)
 	mem.Add(dbformat.SequenceNumber(m.sequence), dbformat.TypeValue, key, value)
 	m.sequence++
 	return nil
 }
 
+func (m *memtableInserter) PutEntity(key, entity []byte) error {
+	return m.PutEntityCF(DefaultColumnFamilyID, key, entity)
+}
+
+func (m *memtableInserter) PutEntityCF(cfID uint32, key, entity []byte) error {
+	if err := m.verify(batch.TypeWideColumnEntity, cfID, key, entity); err != nil {
+		return err
+	}
+	mem := m.getMemtable(cfID)
+	mem.Add(dbformat.SequenceNumber(m.sequence), dbformat.TypeWideColumnEntity, key, entity)
+	m.sequence++
+	return nil
+}
+
 func (m *memtableInserter) Delete(key []byte) error {
 	return m.DeleteCF(DefaultColumnFamilyID, key)
 }
 
 func (m *memtableInserter) DeleteCF(cfID uint32, key []byte) error {
+	if err := m.verify(batch.TypeDeletion, cfID, key, nil); err != nil {
+		return err
+	}
 	mem := m.getMemtable(cfID)
 	mem.Add(dbformat.SequenceNumber(m.sequence), dbformat.TypeDeletion, key, nil)
 	m.sequence++
@@ -1248,6 +2109,9 @@ func (m *memtableInserter) SingleDelete(key []byte) error {
 }
 
 func (m *memtableInserter) SingleDeleteCF(cfID uint32, key []byte) error {
+	if err := m.verify(batch.TypeSingleDeletion, cfID, key, nil); err != nil {
+		return err
+	}
 	mem := m.getMemtable(cfID)
 	mem.Add(dbformat.SequenceNumber(m.sequence), dbformat.TypeSingleDeletion, key, nil)
 	m.sequence++
@@ -1259,17 +2123,63 @@ func (m *memtableInserter) Merge(key, value []byte) error {
 }
 
 func (m *memtableInserter) MergeCF(cfID uint32, key, value []byte) error {
+	if err := m.verify(batch.TypeMerge, cfID, key, value); err != nil {
+		return err
+	}
 	mem := m.getMemtable(cfID)
-	mem.Add(dbformat.SequenceNumber(m.sequence), dbformat.TypeMerge, key, value)
+	if merged, typ, ok := m.collapseMerge(cfID, mem, key, value); ok {
+		mem.Add(dbformat.SequenceNumber(m.sequence), typ, key, merged)
+	} else {
+		mem.Add(dbformat.SequenceNumber(m.sequence), dbformat.TypeMerge, key, value)
+	}
 	m.sequence++
 	return nil
 }
 
+// collapseMerge applies the merge operand to the current value of key once
+// the memtable holds MaxSuccessiveMerges operands on top of it, so that the
+// result can be written in place of the operand. It reports false to add the
+// operand as is: below the limit, during recovery, or if the merge fails.
+// Reference: RocksDB v10.7.5 db/write_batch.cc (MemTableInserter::MergeCF)
+func (m *memtableInserter) collapseMerge(cfID uint32, mem *memtable.MemTable, key, operand []byte) ([]byte, dbformat.ValueType, bool) {
+	if m.lockHeld || m.db.options.MergeOperator == nil {
+		return nil, 0, false
+	}
+	cfd := m.db.columnFamilies.getByID(cfID)
+	if cfd == nil {
+		return nil, 0, false
+	}
+	limit := m.db.options.MaxSuccessiveMerges
+	if cfID != DefaultColumnFamilyID {
+		limit = cfd.options.MaxSuccessiveMerges
+	}
+	if limit <= 0 || mem.CountSuccessiveMergeEntries(key) < limit {
+		return nil, 0, false
+	}
+
+	// Everything written before this operand is visible at the previous
+	// sequence number, including earlier entries of the same write group
+	base, baseType, err := m.db.getAtSequence(cfd, key, m.sequence-1)
+	if errors.Is(err, ErrNotFound) {
+		base, baseType = nil, dbformat.TypeValue
+	} else if err != nil {
+		return nil, 0, false
+	}
+	merged, typ, err := m.db.mergeEntry(key, base, baseType, [][]byte{operand})
+	if err != nil {
+		return nil, 0, false
+	}
+	return merged, typ, true
+}
+
 func (m *memtableInserter) DeleteRange(startKey, endKey []byte) error {
 	return m.DeleteRangeCF(DefaultColumnFamilyID, startKey, endKey)
 }
 
 func (m *memtableInserter) DeleteRangeCF(cfID uint32, startKey, endKey []byte) error {
+	if err := m.verify(batch.TypeRangeDeletion, cfID, startKey, endKey); err != nil {
+		return err
+	}
 	mem := m.getMemtable(cfID)
 	mem.AddRangeTombstone(dbformat.SequenceNumber(m.sequence), startKey, endKey)
 	m.sequence++
@@ -1304,7 +2214,7 @@ func (db *dbImpl) NewIteratorCF(opts *ReadOptions, cf ColumnFamilyHandle) Iterat
 		// Note: The iterator owns this snapshot and should release it on Close
 	}
 
-	iter := newDBIteratorCF(db, cfd, snapshot)
+	iter := newDBIteratorCF(db, cfd, snapshot, opts.ReadaheadSize, opts.FillCache)
 
 	// Set up prefix seek options
 	iter.prefixExtractor = db.options.PrefixExtractor
@@ -1312,6 +2222,13 @@ func (db *dbImpl) NewIteratorCF(opts *ReadOptions, cf ColumnFamilyHandle) Iterat
 	iter.iterateLowerBound = opts.IterateLowerBound
 	iter.prefixSameAsStart = opts.PrefixSameAsStart
 	iter.totalOrderSeek = opts.TotalOrderSeek
+	iter.asyncIO = opts.AsyncIO
+	if opts.BlobPrefetchSize > 0 && db.blobCache != nil {
+		iter.blobPrefetcher = db.blobCache.NewPrefetcher(opts.BlobPrefetchSize)
+	}
+	if opts.VerifyIteratorOrder {
+		iter.enableOrderVerification()
+	}
 
 	return iter
 }
@@ -1326,11 +2243,12 @@ func (db *dbImpl) GetSnapshot() *Snapshot {
 
 	db.snapshotLock.Lock()
 	// Add to linked list
-	s.next = db.snapshots
+	e := s.entry
+	e.next = db.snapshots
 	if db.snapshots != nil {
-		db.snapshots.prev = s
+		db.snapshots.prev = e
 	}
-	db.snapshots = s
+	db.snapshots = e
 	db.snapshotLock.Unlock()
 
 	return s
@@ -1343,17 +2261,21 @@ func (db *dbImpl) ReleaseSnapshot(s *Snapshot) {
 
 // releaseSnapshot is called when a snapshot's reference count reaches zero.
 func (db *dbImpl) releaseSnapshot(s *Snapshot) {
+	e := s.entry
+	if e == nil {
+		return
+	}
 	db.snapshotLock.Lock()
 	defer db.snapshotLock.Unlock()
 
 	// Remove from linked list
-	if s.prev != nil {
-		s.prev.next = s.next
+	if e.prev != nil {
+		e.prev.next = e.next
 	} else {
-		db.snapshots = s.next
+		db.snapshots = e.next
 	}
-	if s.next != nil {
-		s.next.prev = s.prev
+	if e.next != nil {
+		e.next.prev = e.prev
 	}
 }
 
@@ -1375,9 +2297,9 @@ func (db *dbImpl) Flush(opts *FlushOptions) error {
 		return err
 	}
 
-	// Wait for any existing immutable memtable to be flushed
+	// Wait for room for another immutable memtable
 	// This prevents "immutable memtable already exists" spam during stress tests
-	for db.imm != nil {
+	for len(db.imm) >= db.maxImmutableMemTables() {
 		// Check for shutdown or background error while waiting
 		if db.closed {
 			db.mu.Unlock()
@@ -1392,8 +2314,8 @@ func (db *dbImpl) Flush(opts *FlushOptions) error {
 		db.immCond.Wait()
 	}
 
-	// Skip if memtable is empty
-	if db.mem.Empty() {
+	// Skip if there is nothing to flush
+	if db.mem.Empty() && len(db.imm) == 0 {
 		db.mu.Unlock()
 		return nil
 	}
@@ -1404,28 +2326,27 @@ func (db *dbImpl) Flush(opts *FlushOptions) error {
 	// Therefore, we do NOT set nextLogNumber - we can't advance LogNumber until
 	// we actually create a new WAL (on DB open/recovery).
 	// Reference: RocksDB v10.7.5 db/db_impl/db_impl_write.cc:2722 (for WAL rotation)
-	db.imm = db.mem
-	// Don't set nextLogNumber - same WAL is used for new memtable
-	var memCmp memtable.Comparator
-	if db.comparator != nil {
-		memCmp = db.comparator.Compare
-	}
-	db.mem = memtable.NewMemTable(memCmp)
+	if !db.mem.Empty() {
+		db.imm = append([]*memtable.MemTable{db.mem}, db.imm...)
+		// Don't set nextLogNumber - same WAL is used for new memtable
+		db.mem = db.newMemTable()
 
-	// Recalculate write stall condition (may now be stalled due to imm)
-	db.recalculateWriteStall()
+		// Recalculate write stall condition (may now be stalled due to imm)
+		db.recalculateWriteStall()
+	}
 	db.mu.Unlock()
 
+	// Without Wait, hand the flush to the HIGH priority pool and return.
+	if !opts.Wait && db.bgWork != nil {
+		db.bgWork.maybeScheduleFlush()
+		return nil
+	}
+
 	// Perform the flush synchronously
 	if err := db.doFlush(); err != nil {
 		return err
 	}
 
-	// Wait for completion if requested
-	if opts.Wait {
-		// Already done synchronously above
-	}
-
 	// Trigger compaction check after flush
 	if db.bgWork != nil {
 		db.bgWork.maybeScheduleCompaction()
@@ -1469,6 +2390,7 @@ func (db *dbImpl) FlushWAL(sync bool) error {
 		return ErrDBClosed
 	}
 	logFile := db.logFile
+	walBuffer := db.walBuffer
 	db.mu.RUnlock()
 
 	if logFile == nil {
@@ -1478,8 +2400,13 @@ func (db *dbImpl) FlushWAL(sync bool) error {
 	// In RocksDB, FlushWAL with sync=false just writes buffered data
 	// to the OS (no fsync). With sync=true, it also calls SyncWAL.
 	//
-	// Our implementation always syncs when writing to the WAL (no buffering),
-	// so FlushWAL(false) is a no-op and FlushWAL(true) syncs.
+	// Records are only buffered with Options.ManualWalFlush; otherwise each
+	// one is written to the file as it is added and FlushWAL(false) is a no-op.
+	if walBuffer != nil {
+		if err := walBuffer.Flush(); err != nil {
+			return err
+		}
+	}
 	if sync {
 		return db.SyncWAL()
 	}
@@ -1497,6 +2424,9 @@ func (db *dbImpl) GetLatestSequenceNumber() uint64 {
 
 // Close closes the database, releasing all resources.
 func (db *dbImpl) Close() error {
+	// Persist the writes that no WAL will replay
+	db.flushWALDisabled()
+
 	db.mu.Lock()
 	if db.closed {
 		db.mu.Unlock()
@@ -1507,6 +2437,7 @@ func (db *dbImpl) Close() error {
 	db.mu.Unlock()
 
 	// Stop background workers first (outside mutex to avoid deadlock)
+	db.stopStatsDump()
 	if db.bgWork != nil {
 		db.bgWork.stop()
 	}
@@ -1517,26 +2448,55 @@ func (db *dbImpl) Close() error {
 	// Signal shutdown
 	close(db.shutdownCh)
 
-	// Close WAL
+	// Close WAL, writing any records still buffered
 	if db.logFile != nil {
+		db.logMu.Lock()
+		if db.walBuffer != nil {
+			_ = db.walBuffer.Flush()
+			db.walBuffer = nil
+		}
 		_ = db.logFile.Close()
 		db.logFile = nil
 		db.logWriter = nil
+		db.logMu.Unlock()
 	}
 
 	// Close table cache
 	if db.tableCache != nil {
 		_ = db.tableCache.Close()
 	}
+	if db.blobCache != nil {
+		_ = db.blobCache.Close()
+	}
 
 	// Close version set
 	if db.versions != nil {
 		_ = db.versions.Close()
 	}
 
+	db.closeInfoLog()
+
+	// Release the LOCK file last, once nothing else touches the directory
+	if db.dbLock != nil {
+		_ = db.dbLock.Close()
+		db.dbLock = nil
+	}
+
 	return nil
 }
 
+// closeInfoLog flushes the info logger and closes it if Open created it.
+// A logger passed in Options may be shared with other DBs and stays open.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc (CloseHelper, own_info_log_)
+func (db *dbImpl) closeInfoLog() {
+	if s, ok := db.logger.(interface{ Sync() error }); ok {
+		_ = s.Sync()
+	}
+	if db.infoLog != nil {
+		_ = db.infoLog.Close()
+	}
+}
+
 // SetBackgroundError sets an unrecoverable background error.
 // This is called when I/O errors occur in background operations (flush, compaction).
 // Once set, new write operations will fail with this error.
@@ -1612,17 +2572,37 @@ const (
 	PropertyNumLiveVersions           = "rocksdb.num-live-versions"
 	PropertyCurrentSuperVersionNumber = "rocksdb.current-super-version-number"
 	PropertyNumColumnFamilies         = "rocksdb.num-column-families"
+
+	// Statistics dumps
+	PropertyStats   = "rocksdb.stats"
+	PropertyCFStats = "rocksdb.cfstats"
+
+	// Per-file read counts and key ranges (GetMapProperty only)
+	PropertySSTables = "rocksdb.sstables"
 )
 
 // GetProperty returns the value of a database property.
 // Returns the property value and true if the property exists, otherwise ("", false).
 func (db *dbImpl) GetProperty(name string) (string, bool) {
+	return db.GetPropertyCF(nil, name)
+}
+
+// GetPropertyCF returns the value of a property of a column family.
+// Properties of the whole database, such as the number of snapshots, have
+// the same value for every column family.
+// Reference: RocksDB v10.7.5 include/rocksdb/db.h (GetProperty with column family)
+func (db *dbImpl) GetPropertyCF(cf ColumnFamilyHandle, name string) (string, bool) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	if db.closed {
 		return "", false
 	}
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return "", false
+	}
+	mem, imm := db.memtablesOf(cfd)
 
 	// Handle level-specific properties (rocksdb.num-files-at-level<N>)
 	if after, ok := strings.CutPrefix(name, PropertyNumFilesAtLevelPrefix); ok {
@@ -1635,49 +2615,46 @@ func (db *dbImpl) GetProperty(name string) (string, bool) {
 		if v == nil {
 			return "0", true
 		}
-		files := v.Files(level)
-		return strconv.Itoa(len(files)), true
+		return strconv.Itoa(len(cfFiles(v, level, cfd.id))), true
 	}
 
 	switch name {
 	// Memtable properties
 	case PropertyNumImmutableMemTable:
-		count := 0
-		if db.imm != nil {
-			count = 1
-		}
-		return strconv.Itoa(count), true
+		return strconv.Itoa(len(imm)), true
 
 	case PropertyNumImmutableMemTableFlushed:
-		// We don't track this separately; return 0
-		return "0", true
+		if cfd.id != DefaultColumnFamilyID {
+			return "0", true
+		}
+		return strconv.Itoa(len(db.immHistory)), true
 
 	case PropertyMemTableFlushPending:
 		pending := 0
-		if db.imm != nil {
+		if len(imm) >= db.minWriteBufferNumberToMerge() {
 			pending = 1
 		}
 		return strconv.Itoa(pending), true
 
 	case PropertyCurSizeActiveMemTable:
-		if db.mem != nil {
-			return strconv.FormatUint(uint64(db.mem.ApproximateMemoryUsage()), 10), true
+		if mem != nil {
+			return strconv.FormatUint(uint64(mem.ApproximateMemoryUsage()), 10), true
 		}
 		return "0", true
 
 	case PropertyCurSizeAllMemTables:
 		size := uint64(0)
-		if db.mem != nil {
-			size += uint64(db.mem.ApproximateMemoryUsage())
+		if mem != nil {
+			size += uint64(mem.ApproximateMemoryUsage())
 		}
-		if db.imm != nil {
-			size += uint64(db.imm.ApproximateMemoryUsage())
+		for _, m := range imm {
+			size += uint64(m.ApproximateMemoryUsage())
 		}
 		return strconv.FormatUint(size, 10), true
 
 	case PropertyNumEntriesActiveMemTable:
-		if db.mem != nil {
-			return strconv.FormatInt(db.mem.Count(), 10), true
+		if mem != nil {
+			return strconv.FormatInt(mem.Count(), 10), true
 		}
 		return "0", true
 
@@ -1706,7 +2683,13 @@ func (db *dbImpl) GetProperty(name string) (string, bool) {
 
 	// Level stats
 	case PropertyLevelStats:
-		return db.getLevelStats(), true
+		return db.getLevelStats(cfd.id), true
+
+	case PropertyCFStats:
+		return db.getCFStats(cfd), true
+
+	case PropertyStats:
+		return db.getStats(), true
 
 	// Snapshot properties
 	case PropertyNumSnapshots:
@@ -1721,16 +2704,16 @@ func (db *dbImpl) GetProperty(name string) (string, bool) {
 
 	// Key estimates
 	case PropertyEstimateNumKeys:
-		estimate := db.estimateNumKeys()
+		estimate := db.estimateNumKeys(cfd.id, mem, imm)
 		return strconv.FormatUint(estimate, 10), true
 
 	// File size properties
 	case PropertyTotalSstFilesSize, PropertyLiveSstFilesSize:
-		size := db.getTotalSstFilesSize()
+		size := db.getTotalSstFilesSize(cfd.id)
 		return strconv.FormatUint(size, 10), true
 
 	case PropertyEstimateLiveDataSize:
-		size := db.getTotalSstFilesSize()
+		size := db.getTotalSstFilesSize(cfd.id)
 		return strconv.FormatUint(size, 10), true
 
 	// Background errors
@@ -1749,7 +2732,7 @@ func (db *dbImpl) GetProperty(name string) (string, bool) {
 
 	case PropertyCurrentSuperVersionNumber:
 		if db.versions != nil {
-			return strconv.FormatUint(db.versions.CurrentVersionNumber(), 10), true
+			return strconv.FormatUint(db.versions.ColumnFamilyVersionNumber(cfd.id), 10), true
 		}
 		return "0", true
 
@@ -1761,8 +2744,29 @@ func (db *dbImpl) GetProperty(name string) (string, bool) {
 	}
 }
 
-// getLevelStats returns a formatted string with level statistics.
-func (db *dbImpl) getLevelStats() string {
+// memtablesOf returns the active and immutable memtables of a column family.
+// REQUIRES: db.mu is held.
+func (db *dbImpl) memtablesOf(cfd *columnFamilyData) (*memtable.MemTable, []*memtable.MemTable) {
+	if cfd.id == DefaultColumnFamilyID {
+		return db.mem, db.imm
+	}
+	return cfd.mem, cfd.imm
+}
+
+// cfFiles returns the files of a column family at a level.
+func cfFiles(v *version.Version, level int, cfID uint32) []*manifest.FileMetaData {
+	var files []*manifest.FileMetaData
+	for _, f := range v.Files(level) {
+		if f.ColumnFamilyID == cfID {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// getLevelStats returns a formatted string with the level statistics of a
+// column family.
+func (db *dbImpl) getLevelStats(cfID uint32) string {
 	v := db.versions.Current()
 	if v == nil {
 		return "Level Files Size(MB)\n"
@@ -1770,8 +2774,8 @@ func (db *dbImpl) getLevelStats() string {
 
 	var sb strings.Builder
 	sb.WriteString("Level Files Size(MB)\n")
-	for level := range 7 {
-		files := v.Files(level)
+	for level := range v.NumLevels() {
+		files := cfFiles(v, level, cfID)
 		var totalSize uint64
 		for _, f := range files {
 			totalSize += f.FD.FileSize
@@ -1782,6 +2786,62 @@ func (db *dbImpl) getLevelStats() string {
 	return sb.String()
 }
 
+// getCFStats returns the level and compaction statistics of a column family.
+// REQUIRES: db.mu is held.
+// Reference: RocksDB v10.7.5 db/internal_stats.cc (DumpCFStats)
+func (db *dbImpl) getCFStats(cfd *columnFamilyData) string {
+	mem, imm := db.memtablesOf(cfd)
+	var memEntries int64
+	if mem != nil {
+		memEntries = mem.Count()
+	}
+	pending, running, flushes := 0, 0, 0
+	if db.bgWork != nil {
+		if db.bgWork.isCompactionPending() {
+			pending = 1
+		}
+		running = db.bgWork.numRunningCompactions()
+		flushes = db.bgWork.numRunningFlushes()
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "** Compaction Stats [%s] **\n", cfd.name)
+	sb.WriteString(db.getLevelStats(cfd.id))
+	fmt.Fprintf(&sb, "Compaction pending: %d, running compactions: %d, running flushes: %d\n",
+		pending, running, flushes)
+	fmt.Fprintf(&sb, "Memtables: active entries: %d, immutable: %d\n", memEntries, len(imm))
+	return sb.String()
+}
+
+// getStats returns the database statistics followed by the statistics of
+// every column family.
+// REQUIRES: db.mu is held.
+// Reference: RocksDB v10.7.5 db/internal_stats.cc (HandleStats)
+func (db *dbImpl) getStats() string {
+	uptime := float64(db.env.NowMicros()-db.openMicros) / 1e6
+	backgroundErrors := 0
+	if db.bgWork != nil {
+		backgroundErrors = db.bgWork.numBackgroundErrors()
+	}
+
+	var sb strings.Builder
+	sb.WriteString("** DB Stats **\n")
+	fmt.Fprintf(&sb, "Uptime(secs): %.1f\n", uptime)
+	fmt.Fprintf(&sb, "Snapshots: %d\n", db.countSnapshots())
+	fmt.Fprintf(&sb, "Background errors: %d\n", backgroundErrors)
+
+	var cfds []*columnFamilyData
+	db.columnFamilies.forEach(func(cfd *columnFamilyData) {
+		cfds = append(cfds, cfd)
+	})
+	slices.SortFunc(cfds, func(a, b *columnFamilyData) int { return int(a.id) - int(b.id) })
+	for _, cfd := range cfds {
+		sb.WriteString("\n")
+		sb.WriteString(db.getCFStats(cfd))
+	}
+	return sb.String()
+}
+
 // countSnapshots counts the number of active snapshots.
 func (db *dbImpl) countSnapshots() int {
 	db.snapshotLock.Lock()
@@ -1794,6 +2854,18 @@ func (db *dbImpl) countSnapshots() int {
 	return count
 }
 
+// snapshotSequences returns the sequence numbers of the active snapshots.
+func (db *dbImpl) snapshotSequences() []dbformat.SequenceNumber {
+	db.snapshotLock.Lock()
+	defer db.snapshotLock.Unlock()
+
+	var seqs []dbformat.SequenceNumber
+	for s := db.snapshots; s != nil; s = s.next {
+		seqs = append(seqs, dbformat.SequenceNumber(s.sequence))
+	}
+	return seqs
+}
+
 // getOldestSnapshotTime returns the creation time of the oldest snapshot (Unix timestamp).
 func (db *dbImpl) getOldestSnapshotTime() int64 {
 	db.snapshotLock.Lock()
@@ -1814,23 +2886,23 @@ func (db *dbImpl) getOldestSnapshotTime() int64 {
 }
 
 // estimateNumKeys estimates the total number of keys in the database.
-func (db *dbImpl) estimateNumKeys() uint64 {
+func (db *dbImpl) estimateNumKeys(cfID uint32, mem *memtable.MemTable, imm []*memtable.MemTable) uint64 {
 	var estimate uint64
 
 	// Count keys in memtables
-	if db.mem != nil {
-		estimate += uint64(db.mem.Count())
+	if mem != nil {
+		estimate += uint64(mem.Count())
 	}
-	if db.imm != nil {
-		estimate += uint64(db.imm.Count())
+	for _, m := range imm {
+		estimate += uint64(m.Count())
 	}
 
 	// Estimate keys from SST files based on file size
 	// Assume average key-value pair is ~100 bytes
 	v := db.versions.Current()
 	if v != nil {
-		for level := range 7 {
-			for _, f := range v.Files(level) {
+		for level := range v.NumLevels() {
+			for _, f := range cfFiles(v, level, cfID) {
 				// Rough estimate: 1 entry per 100 bytes
 				estimate += f.FD.FileSize / 100
 			}
@@ -1840,16 +2912,17 @@ func (db *dbImpl) estimateNumKeys() uint64 {
 	return estimate
 }
 
-// getTotalSstFilesSize returns the total size of all SST files.
-func (db *dbImpl) getTotalSstFilesSize() uint64 {
+// getTotalSstFilesSize returns the total size of the SST files of a column
+// family.
+func (db *dbImpl) getTotalSstFilesSize(cfID uint32) uint64 {
 	v := db.versions.Current()
 	if v == nil {
 		return 0
 	}
 
 	var totalSize uint64
-	for level := range 7 {
-		for _, f := range v.Files(level) {
+	for level := range v.NumLevels() {
+		for _, f := range cfFiles(v, level, cfID) {
 			totalSize += f.FD.FileSize
 		}
 	}
@@ -1977,7 +3050,7 @@ func (db *dbImpl) CompactRange(opts *CompactRangeOptions, start, end []byte) err
 	defer v.Unref()
 
 	// Compact each level from L0 down to the bottommost level
-	for level := range 6 {
+	for level := range v.NumLevels() - 1 {
 		if err := db.compactLevel(v, level, start, end, opts); err != nil {
 			return err
 		}
@@ -2014,14 +3087,9 @@ func (db *dbImpl) compactLevel(v *version.Version, level int, start, end []byte,
 		if f.BeingCompacted {
 			continue
 		}
-		// Check overlap
-		if len(start) > 0 && bytes.Compare(f.Largest, start) < 0 {
-			continue // File is entirely before start
-		}
-		if len(end) > 0 && bytes.Compare(f.Smallest, end) >= 0 {
-			continue // File is entirely after or at end
+		if compactRangeOverlaps(f.Smallest, f.Largest, start, end) {
+			overlappingFiles = append(overlappingFiles, f)
 		}
-		overlappingFiles = append(overlappingFiles, f)
 	}
 	db.mu.Unlock()
 
@@ -2030,10 +3098,7 @@ func (db *dbImpl) compactLevel(v *version.Version, level int, start, end []byte,
 	}
 
 	// Create a manual compaction
-	outputLevel := level + 1
-	if opts.ChangeLevel && opts.TargetLevel > outputLevel {
-		outputLevel = opts.TargetLevel
-	}
+	outputLevel := compactRangeOutputLevel(level, v.NumLevels(), opts)
 
 	input := &compaction.CompactionInputFiles{
 		Level: level,
@@ -2071,6 +3136,10 @@ func (db *dbImpl) compactLevel(v *version.Version, level int, start, end []byte,
 
 	c := compaction.NewCompaction(inputs, outputLevel)
 	c.Reason = compaction.CompactionReasonManualCompaction
+	if picker, ok := db.bgWork.picker.(*compaction.LeveledCompactionPicker); ok {
+		c.MaxOutputFileSize = picker.TargetFileSizeForLevel(outputLevel)
+		picker.SetGrandparents(v, c)
+	}
 
 	// Mark files as being compacted
 	db.mu.Lock()
@@ -2087,6 +3156,28 @@ func (db *dbImpl) compactLevel(v *version.Version, level int, start, end []byte,
 	return db.bgWork.executeCompaction(c)
 }
 
+// compactRangeOverlaps reports whether a file with the given smallest and
+// largest keys overlaps the range [start, end) of CompactRange.
+func compactRangeOverlaps(smallest, largest, start, end []byte) bool {
+	if len(start) > 0 && bytes.Compare(largest, start) < 0 {
+		return false // File is entirely before start
+	}
+	if len(end) > 0 && bytes.Compare(smallest, end) >= 0 {
+		return false // File is entirely after or at end
+	}
+	return true
+}
+
+// compactRangeOutputLevel returns the level CompactRange compacts the files
+// of level into.
+func compactRangeOutputLevel(level, numLevels int, opts *CompactRangeOptions) int {
+	outputLevel := level + 1
+	if opts.ChangeLevel && opts.TargetLevel > outputLevel {
+		outputLevel = min(opts.TargetLevel, numLevels-1)
+	}
+	return outputLevel
+}
+
 // BeginTransaction begins a new optimistic transaction.
 func (db *dbImpl) BeginTransaction(opts TransactionOptions, writeOpts *WriteOptions) Transaction {
 	if writeOpts == nil {
@@ -2100,6 +3191,37 @@ func (db *dbImpl) logFilePath(number uint64) string {
 	return filepath.Join(db.name, logFileName(number))
 }
 
+// createLogFile creates the WAL file for logNumber and makes it the current
+// WAL. The oldest of the recycle files is reused if there is one. With
+// Options.ManualWalFlush the WAL writer appends to a walBuffer.
+func (db *dbImpl) createLogFile(logNumber uint64, recycle []uint64) error {
+	logPath := db.logFilePath(logNumber)
+
+	var logFile vfs.WritableFile
+	var err error
+	if len(recycle) > 0 {
+		logFile, err = db.fs.ReuseWritableFile(db.logFilePath(recycle[0]), logPath)
+		if err == nil {
+			db.logger.Debugf("[wal] reusing WAL file %d as %d", recycle[0], logNumber)
+		}
+	} else {
+		logFile, err = db.fs.Create(logPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	db.logFile = logFile
+	db.logFileNumber = logNumber
+	var dest io.Writer = logFile
+	if db.options.ManualWalFlush {
+		db.walBuffer = &walBuffer{file: logFile}
+		dest = db.walBuffer
+	}
+	db.logWriter = wal.NewWriter(dest, logNumber, db.recycleLogs())
+	return nil
+}
+
 // logFileName returns the filename for a log file.
 func logFileName(number uint64) string {
 	return fmt.Sprintf("%06d.log", number)
@@ -2109,10 +3231,7 @@ func logFileName(number uint64) string {
 // REQUIRES: db.mu is held.
 func (db *dbImpl) recalculateWriteStall() {
 	// Count unflushed memtables
-	numUnflushed := 1 // Current memtable
-	if db.imm != nil {
-		numUnflushed++
-	}
+	numUnflushed := 1 + len(db.imm) // Current and immutable memtables
 
 	// Count L0 files
 	numL0Files := 0
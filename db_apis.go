@@ -9,13 +9,19 @@ package rockyardkv
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/aalhour/rockyardkv/internal/compaction"
 	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/manifest"
 	"github.com/aalhour/rockyardkv/internal/memtable"
+	"github.com/aalhour/rockyardkv/internal/options"
+	"github.com/aalhour/rockyardkv/internal/rangedel"
+	"github.com/aalhour/rockyardkv/internal/table"
 	"github.com/aalhour/rockyardkv/internal/version"
 	"github.com/aalhour/rockyardkv/vfs"
 )
@@ -38,6 +44,9 @@ const (
 	SizeApproximationIncludeMemtables SizeApproximationFlags = 1 << 0
 	// SizeApproximationIncludeFiles includes SST file sizes.
 	SizeApproximationIncludeFiles SizeApproximationFlags = 1 << 1
+	// SizeApproximationIncludeBlobFiles adds the blob file bytes attributed
+	// to the SST data in the range.
+	SizeApproximationIncludeBlobFiles SizeApproximationFlags = 1 << 2
 )
 
 // SizeApproximationOptions controls size approximation behavior.
@@ -45,6 +54,17 @@ const (
 type SizeApproximationOptions struct {
 	IncludeMemtables bool
 	IncludeFiles     bool
+
+	// AccountForRangeTombstones subtracts the estimated bytes of SST data
+	// covered by newer range tombstones inside the query range, so ranges
+	// cleared with DeleteRange no longer report their pre-deletion size.
+	AccountForRangeTombstones bool
+
+	// IncludeBlobFiles adds a share of the blob files to the file sizes, so
+	// ranges whose values were separated into blob files report their full
+	// storage footprint. The share is the total size of the blob files
+	// scaled by the fraction of the SST bytes that fall in the range.
+	IncludeBlobFiles bool
 }
 
 // WaitForCompactOptions controls WaitForCompact behavior.
@@ -79,7 +99,7 @@ func (db *dbImpl) KeyMayExist(opts *ReadOptions, key []byte, value *[]byte) (may
 	// Check bloom filter in memtable
 	db.mu.RLock()
 	mem := db.mem
-	imm := db.imm
+	imms := db.imm
 	v := db.versions.Current()
 	if v != nil {
 		v.Ref()
@@ -100,8 +120,8 @@ func (db *dbImpl) KeyMayExist(opts *ReadOptions, key []byte, value *[]byte) (may
 		}
 	}
 
-	// Check immutable memtable
-	if imm != nil {
+	// Check immutable memtables, newest first
+	for _, imm := range imms {
 		val, found, deleted := imm.Get(key, dbformat.MaxSequenceNumber)
 		if found && !deleted {
 			if value != nil {
@@ -178,10 +198,8 @@ func (db *dbImpl) WaitForCompact(opts *WaitForCompactOptions) error {
 		// Check background work state
 		var isRunning, isPaused bool
 		if db.bgWork != nil {
-			db.bgWork.mu.Lock()
-			isRunning = db.bgWork.compactionRunning || db.bgWork.flushRunning
-			isPaused = db.bgWork.paused
-			db.bgWork.mu.Unlock()
+			isRunning = db.bgWork.hasPendingWork()
+			isPaused = db.bgWork.isPaused()
 		}
 
 		if !isRunning {
@@ -206,11 +224,105 @@ func (db *dbImpl) WaitForCompact(opts *WaitForCompactOptions) error {
 	return nil
 }
 
-// GetApproximateSizes returns the approximate sizes of key ranges.
+// FlushAndWaitForL0 flushes the column family's memtable and then waits
+// until the column family has fewer L0 files than its
+// Level0FileNumCompactionTrigger, so reads no longer pay for an L0 backlog.
+// Useful before switching a DB to a read-heavy phase. Returns an error if
+// L0 is over the trigger and no compaction can bring it down (e.g.
+// background work is paused). A nil cf selects the default column family.
+func (db *dbImpl) FlushAndWaitForL0(cf ColumnFamilyHandle) error {
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return err
+	}
+
+	if cfd.id == DefaultColumnFamilyID {
+		err = db.Flush(DefaultFlushOptions())
+	} else {
+		err = db.flushColumnFamily(cfd)
+	}
+	if err != nil {
+		return err
+	}
+
+	trigger := db.level0FileNumCompactionTrigger(cfd)
+
+	// A compaction requested after the last change in L0 that ran without
+	// lowering it means the picker has nothing to do.
+	requested := false
+	lastCount := -1
+	for {
+		db.mu.RLock()
+		closed, bgErr := db.closed, db.backgroundError
+		count := 0
+		if v := db.versions.Current(); v != nil {
+			for _, f := range v.Files(0) {
+				if f.ColumnFamilyID == cfd.id {
+					count++
+				}
+			}
+		}
+		db.mu.RUnlock()
+
+		switch {
+		case closed:
+			return ErrDBClosed
+		case bgErr != nil:
+			return fmt.Errorf("%w: %w", ErrBackgroundError, bgErr)
+		case count < trigger:
+			return nil
+		case db.bgWork == nil:
+			return fmt.Errorf("db: %d L0 files, trigger is %d, and background compaction is not running", count, trigger)
+		}
+
+		if count != lastCount {
+			requested, lastCount = false, count
+		}
+		if !db.bgWork.hasPendingWork() {
+			if requested {
+				return fmt.Errorf("db: %d L0 files, trigger is %d, and no compaction is pending", count, trigger)
+			}
+			db.bgWork.maybeScheduleCompaction()
+			requested = true
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// level0FileNumCompactionTrigger returns the Level0FileNumCompactionTrigger
+// of cfd, or the database's if the column family does not set one.
+func (db *dbImpl) level0FileNumCompactionTrigger(cfd *columnFamilyData) int {
+	trigger := db.options.Level0FileNumCompactionTrigger
+	if cfd.id != DefaultColumnFamilyID && cfd.options.Level0FileNumCompactionTrigger > 0 {
+		trigger = cfd.options.Level0FileNumCompactionTrigger
+	}
+	if trigger <= 0 {
+		trigger = compaction.DefaultLeveledCompactionPicker().L0CompactionTrigger
+	}
+	return trigger
+}
+
+// GetApproximateSizes returns the approximate sizes of key ranges of the
+// default column family. A nil or empty Start or Limit leaves that side of a
+// range open, so the zero Range reports the size of the whole column family.
 // Reference: RocksDB v10.7.5
 //   - include/rocksdb/db.h lines 1533-1565
 //   - db/db_impl/db_impl.cc dbImpl::GetApproximateSizes
 func (db *dbImpl) GetApproximateSizes(ranges []Range, flags SizeApproximationFlags) ([]uint64, error) {
+	return db.GetApproximateSizesWithOptions(&SizeApproximationOptions{
+		IncludeMemtables: (flags & SizeApproximationIncludeMemtables) != 0,
+		IncludeFiles:     (flags & SizeApproximationIncludeFiles) != 0,
+		IncludeBlobFiles: (flags & SizeApproximationIncludeBlobFiles) != 0,
+	}, ranges)
+}
+
+// GetApproximateSizesWithOptions returns the approximate sizes of key ranges
+// using the given SizeApproximationOptions.
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/db.h lines 1533-1565
+//   - db/db_impl/db_impl.cc dbImpl::GetApproximateSizes
+func (db *dbImpl) GetApproximateSizesWithOptions(opts *SizeApproximationOptions, ranges []Range) ([]uint64, error) {
 	db.mu.RLock()
 	if db.closed {
 		db.mu.RUnlock()
@@ -218,8 +330,11 @@ func (db *dbImpl) GetApproximateSizes(ranges []Range, flags SizeApproximationFla
 	}
 	db.mu.RUnlock()
 
-	includeMemtables := (flags & SizeApproximationIncludeMemtables) != 0
-	includeFiles := (flags & SizeApproximationIncludeFiles) != 0
+	if opts == nil {
+		opts = &SizeApproximationOptions{}
+	}
+	includeMemtables := opts.IncludeMemtables
+	includeFiles := opts.IncludeFiles
 
 	// Default to including files if nothing specified
 	if !includeMemtables && !includeFiles {
@@ -233,34 +348,67 @@ func (db *dbImpl) GetApproximateSizes(ranges []Range, flags SizeApproximationFla
 	if v != nil {
 		v.Ref()
 	}
-	mem := db.mem
-	imm := db.imm
+	mems := append([]*memtable.MemTable{db.mem}, db.imm...)
 	db.mu.RUnlock()
 
 	if v != nil {
 		defer v.Unref()
 	}
 
+	// Gather every visible range tombstone once; they are clipped per range below.
+	var tombstones []*rangedel.RangeTombstone
+	if includeFiles && opts.AccountForRangeTombstones && v != nil {
+		tombstones = db.collectRangeTombstones(v, mems)
+	}
+
+	// Blob bytes are attributed to ranges in proportion to their SST bytes
+	var blobBytes, sstBytes uint64
+	if includeFiles && opts.IncludeBlobFiles && v != nil {
+		blobBytes = db.blobGC.TotalBytes()
+		for level := range v.NumLevels() {
+			for _, f := range cfFiles(v, level, DefaultColumnFamilyID) {
+				sstBytes += f.FD.FileSize
+			}
+		}
+	}
+
 	for i, r := range ranges {
 		var size uint64
 
+		// An empty bound is open like a nil one
+		if len(r.Start) == 0 {
+			r.Start = nil
+		}
+		if len(r.Limit) == 0 {
+			r.Limit = nil
+		}
+
 		// Estimate memtable size
 		if includeMemtables {
-			size += estimateMemtableRangeSizeFromMem(mem, r.Start, r.Limit)
-			size += estimateMemtableRangeSizeFromMem(imm, r.Start, r.Limit)
+			for _, mem := range mems {
+				size += estimateMemtableRangeSizeFromMem(mem, r.Start, r.Limit)
+			}
 		}
 
 		// Estimate SST file sizes
 		if includeFiles && v != nil {
+			var rangeSSTBytes uint64
 			for level := range v.NumLevels() {
-				files := v.Files(level)
-				for _, f := range files {
+				for _, f := range cfFiles(v, level, DefaultColumnFamilyID) {
 					if rangesOverlap(r.Start, r.Limit, f.Smallest, f.Largest, db.comparator) {
 						// Estimate portion of file in range
-						size += f.FD.FileSize
+						fileSize := db.estimateFileRangeBytes(f, r)
+						if len(tombstones) > 0 {
+							fileSize -= min(fileSize, db.estimateRangeDeletedBytes(f, r, tombstones))
+						}
+						rangeSSTBytes += fileSize
 					}
 				}
 			}
+			size += rangeSSTBytes
+			if blobBytes > 0 && sstBytes > 0 {
+				size += uint64(float64(blobBytes) * float64(min(rangeSSTBytes, sstBytes)) / float64(sstBytes))
+			}
 		}
 
 		sizes[i] = size
@@ -269,32 +417,460 @@ func (db *dbImpl) GetApproximateSizes(ranges []Range, flags SizeApproximationFla
 	return sizes, nil
 }
 
+// GetApproximateSizesAtSnapshot returns the approximate on-disk sizes of key
+// ranges of a column family, counting only the SST files that hold entries
+// visible to snap. Files that also hold newer entries, such as the outputs
+// of compactions run after the snapshot, are counted whole; data that was
+// still in a memtable when the snapshot was taken is counted once flushed.
+func (db *dbImpl) GetApproximateSizesAtSnapshot(snap *Snapshot, cf ColumnFamilyHandle, ranges []Range) ([]uint64, error) {
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, ErrDBClosed
+	}
+	v := db.versions.Current()
+	if v != nil {
+		v.Ref()
+	}
+	db.mu.RUnlock()
+
+	sizes := make([]uint64, len(ranges))
+	if v == nil {
+		return sizes, nil
+	}
+	defer v.Unref()
+
+	for level := range v.NumLevels() {
+		for _, f := range cfFiles(v, level, cfd.id) {
+			if snap != nil && uint64(f.FD.SmallestSeqno) > snap.Sequence() {
+				continue
+			}
+			for i, r := range ranges {
+				// An empty bound is open like a nil one
+				if len(r.Start) == 0 {
+					r.Start = nil
+				}
+				if len(r.Limit) == 0 {
+					r.Limit = nil
+				}
+				if rangesOverlap(r.Start, r.Limit, f.Smallest, f.Largest, db.comparator) {
+					sizes[i] += db.estimateFileRangeBytes(f, r)
+				}
+			}
+		}
+	}
+	return sizes, nil
+}
+
+// collectRangeTombstones returns the range tombstones from the memtables and
+// all SST files in v. Files that cannot be opened are skipped; the result is
+// only used for estimation.
+func (db *dbImpl) collectRangeTombstones(v *version.Version, mems []*memtable.MemTable) []*rangedel.RangeTombstone {
+	var tombstones []*rangedel.RangeTombstone
+	for _, m := range mems {
+		if m != nil && m.HasRangeTombstones() {
+			tombstones = append(tombstones, m.GetFragmentedRangeTombstones().All()...)
+		}
+	}
+
+	for level := range v.NumLevels() {
+		for _, f := range v.Files(level) {
+			fileNum := f.FD.GetNumber()
+			reader, err := db.tableCache.Get(fileNum, db.sstFilePath(fileNum))
+			if err != nil {
+				continue
+			}
+			if reader.HasRangeTombstones() {
+				if list, err := reader.GetRangeTombstoneList(); err == nil {
+					tombstones = append(tombstones, list.All()...)
+				}
+			}
+			db.tableCache.Release(fileNum)
+		}
+	}
+	return tombstones
+}
+
+// estimateRangeDeletedBytes estimates how many bytes of file f within r are
+// covered by tombstones newer than every entry in the file. Overlapping
+// tombstones are merged first so no byte is subtracted twice.
+func (db *dbImpl) estimateRangeDeletedBytes(f *manifest.FileMetaData, r Range, tombstones []*rangedel.RangeTombstone) uint64 {
+	// Clip each applicable tombstone to the query range and the file bounds.
+	fileStart := extractUserKey(f.Smallest)
+	fileEnd := extractUserKey(f.Largest)
+	var spans []Range
+	for _, t := range tombstones {
+		if uint64(t.SequenceNum) <= uint64(f.FD.LargestSeqno) {
+			continue
+		}
+		start, limit := t.StartKey, t.EndKey
+		if r.Start != nil && db.comparator.Compare(start, r.Start) < 0 {
+			start = r.Start
+		}
+		if r.Limit != nil && db.comparator.Compare(limit, r.Limit) > 0 {
+			limit = r.Limit
+		}
+		if db.comparator.Compare(start, fileStart) < 0 {
+			start = fileStart
+		}
+		if db.comparator.Compare(start, limit) >= 0 || db.comparator.Compare(start, fileEnd) > 0 {
+			continue
+		}
+		spans = append(spans, Range{Start: start, Limit: limit})
+	}
+	if len(spans) == 0 {
+		return 0
+	}
+
+	slices.SortFunc(spans, func(a, b Range) int {
+		return db.comparator.Compare(a.Start, b.Start)
+	})
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if db.comparator.Compare(s.Start, last.Limit) <= 0 {
+			if db.comparator.Compare(s.Limit, last.Limit) > 0 {
+				last.Limit = s.Limit
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	fileNum := f.FD.GetNumber()
+	index, err := db.tableCache.SizeIndex(fileNum, db.sstFilePath(fileNum), dbformat.NewInternalKeyComparator(db.comparator.Compare))
+	if err != nil {
+		return 0
+	}
+
+	var covered uint64
+	for _, s := range merged {
+		covered += index.ApproximateSize(internalSeekKey(s.Start), internalSeekKey(s.Limit))
+	}
+	return min(covered, f.FD.FileSize)
+}
+
+// estimateFileRangeBytes estimates how many bytes of file f fall within r.
+// Files entirely inside r count in full; for the others the offsets of r's
+// bounds are looked up in the file's size index, which the table cache keeps
+// with the open reader so that repeated estimates do not decode the index
+// block again.
+func (db *dbImpl) estimateFileRangeBytes(f *manifest.FileMetaData, r Range) uint64 {
+	startsBefore := r.Start == nil || db.comparator.Compare(r.Start, extractUserKey(f.Smallest)) <= 0
+	endsAfter := r.Limit == nil || db.comparator.Compare(r.Limit, extractUserKey(f.Largest)) > 0
+	if startsBefore && endsAfter {
+		return f.FD.FileSize
+	}
+
+	fileNum := f.FD.GetNumber()
+	index, err := db.tableCache.SizeIndex(fileNum, db.sstFilePath(fileNum), dbformat.NewInternalKeyComparator(db.comparator.Compare))
+	if err != nil {
+		return f.FD.FileSize
+	}
+
+	var startOff uint64
+	if !startsBefore {
+		startOff = index.OffsetOf(internalSeekKey(r.Start))
+	}
+	limitOff := f.FD.FileSize
+	if !endsAfter {
+		limitOff = index.OffsetOf(internalSeekKey(r.Limit))
+	}
+	if limitOff > startOff {
+		return limitOff - startOff
+	}
+	return 0
+}
+
+// internalSeekKey returns the internal key that sorts before every entry of userKey.
+func internalSeekKey(userKey []byte) []byte {
+	return makeInternalKey(userKey, uint64(dbformat.MaxSequenceNumber), dbformat.ValueTypeForSeek)
+}
+
+// ErrRangeTooSmallToSplit is returned by GetApproximateSplitKey when the range
+// does not span enough SST data blocks to be divided.
+var ErrRangeTooSmallToSplit = errors.New("db: range too small to split")
+
+// splitSample is one data block's contribution to a split key estimate.
+type splitSample struct {
+	key  []byte // user key of the block's index separator
+	size uint64
+}
+
+// GetApproximateSplitKey returns a key that divides [begin, end) of a column
+// family into two parts of approximately equal on-disk size, for sharding
+// layers that want to split a range in the middle. nil begin or end means the
+// range is unbounded on that side.
+//
+// The estimate weighs each SST data block overlapping the range by its size,
+// using only index blocks; memtable data is not considered. The returned key
+// is a block separator, so it lies strictly inside the range but need not be
+// a key that exists in the database. ErrRangeTooSmallToSplit is returned if
+// the range overlaps fewer than two data blocks.
+func (db *dbImpl) GetApproximateSplitKey(cf ColumnFamilyHandle, begin, end []byte) ([]byte, error) {
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, ErrDBClosed
+	}
+	v := db.versions.Current()
+	if v != nil {
+		v.Ref()
+	}
+	db.mu.RUnlock()
+
+	if v == nil {
+		return nil, ErrRangeTooSmallToSplit
+	}
+	defer v.Unref()
+
+	inRange := func(key []byte) bool {
+		return (begin == nil || db.comparator.Compare(key, begin) > 0) &&
+			(end == nil || db.comparator.Compare(key, end) < 0)
+	}
+
+	samples, total, err := db.blockSizeSamples(v, cfd.id, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) < 2 {
+		return nil, ErrRangeTooSmallToSplit
+	}
+
+	var cumulative uint64
+	for _, s := range samples {
+		cumulative += s.size
+		if cumulative*2 >= total && inRange(s.key) {
+			return s.key, nil
+		}
+	}
+	return nil, ErrRangeTooSmallToSplit
+}
+
+// blockSizeSamples returns the SST data blocks of column family cfID in v
+// that overlap [begin, end), sorted by index separator, and their total
+// size. It reads only index blocks.
+func (db *dbImpl) blockSizeSamples(v *version.Version, cfID uint32, begin, end []byte) ([]splitSample, uint64, error) {
+	var samples []splitSample
+	var total uint64
+	for level := range v.NumLevels() {
+		for _, f := range v.Files(level) {
+			if f.ColumnFamilyID != cfID {
+				continue
+			}
+			if (end != nil && db.comparator.Compare(extractUserKey(f.Smallest), end) >= 0) ||
+				(begin != nil && db.comparator.Compare(extractUserKey(f.Largest), begin) < 0) {
+				continue
+			}
+			fileNum := f.FD.GetNumber()
+			reader, err := db.tableCache.Get(fileNum, db.sstFilePath(fileNum))
+			if err != nil {
+				return nil, 0, err
+			}
+			entries, err := reader.IndexEntries()
+			db.tableCache.Release(fileNum)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			// Block i holds the keys in (separator[i-1], separator[i]]
+			lower := extractUserKey(f.Smallest)
+			for _, e := range entries {
+				upper := extractUserKey(e.Key)
+				if (begin == nil || db.comparator.Compare(upper, begin) >= 0) &&
+					(end == nil || db.comparator.Compare(lower, end) < 0) {
+					samples = append(samples, splitSample{key: upper, size: e.Handle.Size})
+					total += e.Handle.Size
+				}
+				lower = upper
+			}
+		}
+	}
+	slices.SortFunc(samples, func(a, b splitSample) int {
+		return db.comparator.Compare(a.key, b.key)
+	})
+	return samples, total, nil
+}
+
+// GetApproximateKeyCount returns the approximate number of keys of a column
+// family in each of the given ranges, for sharding layers that balance by key
+// count rather than by size. A nil cf selects the default column family.
+//
+// The estimate uses the table properties and index blocks of the SST files:
+// each data block is assumed to hold an equal share of its file's entries,
+// and is counted in the range holding its index separator. Entries count
+// every version and tombstone of a key; memtable data is not considered.
+func (db *dbImpl) GetApproximateKeyCount(cf ColumnFamilyHandle, ranges []Range) ([]uint64, error) {
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, ErrDBClosed
+	}
+	v := db.versions.Current()
+	if v != nil {
+		v.Ref()
+	}
+	db.mu.RUnlock()
+
+	counts := make([]uint64, len(ranges))
+	if v == nil {
+		return counts, nil
+	}
+	defer v.Unref()
+
+	for level := range v.NumLevels() {
+		for _, f := range v.Files(level) {
+			if f.ColumnFamilyID != cfd.id {
+				continue
+			}
+			if err := db.addFileKeyCounts(f, ranges, counts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return counts, nil
+}
+
+// addFileKeyCounts adds the estimated number of entries of an SST file in
+// each range to counts.
+func (db *dbImpl) addFileKeyCounts(f *manifest.FileMetaData, ranges []Range, counts []uint64) error {
+	smallest, largest := extractUserKey(f.Smallest), extractUserKey(f.Largest)
+	inRange := func(r Range, key []byte) bool {
+		return (r.Start == nil || db.comparator.Compare(key, r.Start) >= 0) &&
+			(r.Limit == nil || db.comparator.Compare(key, r.Limit) < 0)
+	}
+
+	fileNum := f.FD.GetNumber()
+	reader, err := db.tableCache.Get(fileNum, db.sstFilePath(fileNum))
+	if err != nil {
+		return err
+	}
+	defer db.tableCache.Release(fileNum)
+	props, err := reader.Properties()
+	if err != nil {
+		return err
+	}
+
+	var entries []table.IndexEntry
+	for i, r := range ranges {
+		if (r.Limit != nil && db.comparator.Compare(smallest, r.Limit) >= 0) ||
+			(r.Start != nil && db.comparator.Compare(largest, r.Start) < 0) {
+			continue
+		}
+		if inRange(r, smallest) && inRange(r, largest) {
+			counts[i] += props.NumEntries
+			continue
+		}
+
+		if entries == nil {
+			if entries, err = reader.IndexEntries(); err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				return nil
+			}
+		}
+		var blocks uint64
+		for _, e := range entries {
+			if inRange(r, extractUserKey(e.Key)) {
+				blocks++
+			}
+		}
+		counts[i] += props.NumEntries * blocks / uint64(len(entries))
+	}
+	return nil
+}
+
 // GetApproximateMemTableStats returns approximate memtable statistics for a range.
 // Reference: RocksDB v10.7.5
 //   - include/rocksdb/db.h lines 1556-1564
 func (db *dbImpl) GetApproximateMemTableStats(r Range) (count, size uint64) {
 	db.mu.RLock()
-	mem := db.mem
-	imm := db.imm
+	mems := append([]*memtable.MemTable{db.mem}, db.imm...)
 	db.mu.RUnlock()
 
-	if mem != nil {
-		count += uint64(mem.Count())
-		size += estimateMemtableRangeSizeFromMem(mem, r.Start, r.Limit)
-	}
-	if imm != nil {
-		count += uint64(imm.Count())
-		size += estimateMemtableRangeSizeFromMem(imm, r.Start, r.Limit)
+	for _, mem := range mems {
+		if mem != nil {
+			count += uint64(mem.Count())
+			size += estimateMemtableRangeSizeFromMem(mem, r.Start, r.Limit)
+		}
 	}
 
 	return count, size
 }
 
+// GetActiveMemTableUsage returns the size of the active memtable of a column
+// family and its fill ratio relative to the column family's WriteBufferSize.
+// An invalid column family reports no usage.
+// Reference: RocksDB v10.7.5
+//   - db/memtable.h (MemTable::ShouldFlush)
+func (db *dbImpl) GetActiveMemTableUsage(cf ColumnFamilyHandle) (bytes uint64, ratio float64) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return 0, 0
+	}
+	mem, writeBufferSize := cfd.mem, cfd.options.WriteBufferSize
+	if cfd.id == DefaultColumnFamilyID {
+		mem, writeBufferSize = db.mem, db.options.WriteBufferSize
+	}
+	if mem == nil {
+		return 0, 0
+	}
+
+	bytes = uint64(max(mem.ApproximateMemoryUsage(), 0))
+	if writeBufferSize > 0 {
+		ratio = float64(bytes) / float64(writeBufferSize)
+	}
+	return bytes, ratio
+}
+
+// WarmBlockCache reads the SST data blocks holding the keys of [begin, end)
+// into the block cache by scanning the range.
+func (db *dbImpl) WarmBlockCache(cf ColumnFamilyHandle, begin, end []byte) error {
+	if db.options.BlockCache == nil {
+		return nil
+	}
+
+	iter := db.NewIteratorCF(&ReadOptions{
+		FillCache:         true,
+		TotalOrderSeek:    true,
+		IterateUpperBound: end,
+	}, cf)
+	defer iter.Close()
+
+	if begin == nil {
+		iter.SeekToFirst()
+	} else {
+		iter.Seek(begin)
+	}
+	for ; iter.Valid(); iter.Next() {
+	}
+	return iter.Error()
+}
+
 // NumberLevels returns the number of levels in the LSM tree.
 // Reference: RocksDB v10.7.5
 //   - include/rocksdb/db.h lines 1710-1712
 func (db *dbImpl) NumberLevels() int {
-	return version.MaxNumLevels
+	return db.versions.NumLevels()
 }
 
 // Level0StopWriteTrigger returns the number of L0 files that triggers write stop.
@@ -355,9 +931,39 @@ func (db *dbImpl) SetOptions(newOptions map[string]string) error {
 				return fmt.Errorf("invalid max_write_buffer_number: %w", err)
 			}
 			db.options.MaxWriteBufferNumber = num
+		case "memtable_prefix_bloom_size_ratio":
+			// Applies to memtables created after the change
+			ratio, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("invalid memtable_prefix_bloom_size_ratio: %w", err)
+			}
+			db.options.MemtablePrefixBloomSizeRatio = ratio
+		case "memtable_whole_key_filtering":
+			db.options.MemtableWholeKeyFiltering = v == "true" || v == "1"
 		case "disable_auto_compactions":
 			disabled := v == "true" || v == "1"
 			db.options.DisableAutoCompactions = disabled
+		case "compression":
+			// Applies to SSTs written by later flushes and compactions;
+			// existing SSTs keep the compression they were written with.
+			c := options.StringToCompressionType(v)
+			if c == NoCompression && v != "kNoCompression" {
+				return fmt.Errorf("invalid compression: %q", v)
+			}
+			db.options.Compression = c
+		case "max_background_jobs":
+			jobs, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid max_background_jobs: %w", err)
+			}
+			if jobs <= 0 {
+				return fmt.Errorf("invalid max_background_jobs: %d", jobs)
+			}
+			db.options.MaxBackgroundJobs = jobs
+			if db.bgWork != nil {
+				db.bgWork.setJobLimits(bgJobLimits(
+					jobs, db.options.MaxBackgroundFlushes, db.options.MaxBackgroundCompactions))
+			}
 		default:
 			// Unknown option - ignore for flexibility
 		}
@@ -377,7 +983,14 @@ func (db *dbImpl) SetDBOptions(newOptions map[string]string) error {
 // Reference: RocksDB v10.7.5
 //   - include/rocksdb/db.h lines 1366-1368
 func (db *dbImpl) GetIntProperty(name string) (uint64, bool) {
-	strVal, ok := db.GetProperty(name)
+	return db.GetIntPropertyCF(nil, name)
+}
+
+// GetIntPropertyCF returns an integer property value of a column family.
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/db.h (GetIntProperty with column family)
+func (db *dbImpl) GetIntPropertyCF(cf ColumnFamilyHandle, name string) (uint64, bool) {
+	strVal, ok := db.GetPropertyCF(cf, name)
 	if !ok {
 		return 0, false
 	}
@@ -404,11 +1017,49 @@ func (db *dbImpl) GetMapProperty(name string) (map[string]string, bool) {
 		result["uptime"] = "0"
 		result["cumulative.writes"] = "0"
 		return result, true
+	case PropertySSTables:
+		return db.sstablesProperty()
 	default:
 		return nil, false
 	}
 }
 
+// sstablesProperty returns the rocksdb.sstables map property. For each live
+// SST file, "<file>.level", "<file>.reads", "<file>.smallest" and
+// "<file>.largest" give its level, the number of point lookups that read it,
+// and the range of user keys it holds, where <file> is the file name, such
+// as 000012.sst. Read counts start at zero when the database is opened.
+func (db *dbImpl) sstablesProperty() (map[string]string, bool) {
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, false
+	}
+	v := db.versions.Current()
+	if v != nil {
+		v.Ref()
+	}
+	db.mu.RUnlock()
+
+	result := make(map[string]string)
+	if v == nil {
+		return result, true
+	}
+	defer v.Unref()
+
+	for level := range v.NumLevels() {
+		for _, f := range v.Files(level) {
+			number := f.FD.GetNumber()
+			name := sstFileName(number)
+			result[name+".level"] = strconv.Itoa(level)
+			result[name+".reads"] = strconv.FormatUint(db.tableCache.NumReads(number), 10)
+			result[name+".smallest"] = string(extractUserKey(f.Smallest))
+			result[name+".largest"] = string(extractUserKey(f.Largest))
+		}
+	}
+	return result, true
+}
+
 // NewIterators creates iterators for multiple column families.
 // Reference: RocksDB v10.7.5
 //   - include/rocksdb/db.h lines 1066-1069
@@ -427,6 +1078,150 @@ func (db *dbImpl) NewIterators(opts *ReadOptions, cfs []ColumnFamilyHandle) ([]I
 	return iters, nil
 }
 
+// ScanRange calls fn for each entry in [begin, end) of the default column
+// family, in key order. A nil begin starts at the first key and a nil end
+// scans to the last. fn receives copies of the key and value, which it may
+// keep or modify. The scan stops at, and returns, the first error from fn.
+func (db *dbImpl) ScanRange(opts *ReadOptions, begin, end []byte, fn func(key, value []byte) error) error {
+	scanOpts := DefaultReadOptions()
+	if opts != nil {
+		o := *opts
+		scanOpts = &o
+	}
+	if end != nil {
+		scanOpts.IterateUpperBound = end
+	}
+
+	iter := db.NewIterator(scanOpts)
+	defer iter.Close()
+
+	if begin != nil {
+		iter.Seek(begin)
+	} else {
+		iter.SeekToFirst()
+	}
+	for ; iter.Valid(); iter.Next() {
+		key := append([]byte(nil), iter.Key()...)
+		value := append([]byte(nil), iter.Value()...)
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// KeyValue is a key and its value, as returned by Paginate.
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// Paginate returns a function that reads [begin, end) of the default column
+// family one page at a time, in key order. A nil begin starts at the first
+// key and a nil end reads to the last. Once the range is exhausted it
+// returns an empty page.
+//
+// Page boundaries come from the approximate-size index of the SST files, as
+// in GetApproximateSplitKey: a page ends with the data block at which the
+// on-disk size of the blocks from the page's first key reaches pageBytes,
+// so it is located without reading the entries ahead. A page also stops
+// once its keys and values add up to at least pageBytes, whichever comes
+// first, since data not yet in SST files, such as memtable writes, is not
+// in that index. A page thus exceeds pageBytes by less than one entry.
+//
+// No iterator is held between calls: each page seeks to where the previous
+// one stopped. Set opts.Snapshot for pages that form a consistent view;
+// otherwise each page sees the writes made before it was read.
+func (db *dbImpl) Paginate(opts *ReadOptions, begin, end []byte, pageBytes int) func() ([]KeyValue, error) {
+	next := begin
+	done := false
+	return func() ([]KeyValue, error) {
+		if pageBytes <= 0 {
+			return nil, fmt.Errorf("%w: pageBytes must be positive, got %d", ErrInvalidOptions, pageBytes)
+		}
+		if done {
+			return nil, nil
+		}
+
+		last, err := db.pageLastKey(next, end, uint64(pageBytes))
+		if err != nil {
+			return nil, err
+		}
+
+		pageOpts := DefaultReadOptions()
+		if opts != nil {
+			o := *opts
+			pageOpts = &o
+		}
+		if end != nil {
+			pageOpts.IterateUpperBound = end
+		}
+		iter := db.NewIterator(pageOpts)
+		defer iter.Close()
+
+		if next != nil {
+			iter.Seek(next)
+		} else {
+			iter.SeekToFirst()
+		}
+		var page []KeyValue
+		size := 0
+		for ; iter.Valid() && size < pageBytes; iter.Next() {
+			// A page holds at least one entry, even if the block is all tombstones
+			if last != nil && len(page) > 0 && db.comparator.Compare(iter.Key(), last) > 0 {
+				break
+			}
+			kv := KeyValue{
+				Key:   append([]byte(nil), iter.Key()...),
+				Value: append([]byte(nil), iter.Value()...),
+			}
+			page = append(page, kv)
+			size += len(kv.Key) + len(kv.Value)
+		}
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+		if iter.Valid() {
+			next = append([]byte(nil), iter.Key()...)
+		} else {
+			done = true
+		}
+		return page, nil
+	}
+}
+
+// pageLastKey returns the index separator of the SST data block at which the
+// blocks overlapping [begin, end) reach pageBytes, or nil if they hold less.
+func (db *dbImpl) pageLastKey(begin, end []byte, pageBytes uint64) ([]byte, error) {
+	db.mu.RLock()
+	if db.closed {
+		db.mu.RUnlock()
+		return nil, ErrDBClosed
+	}
+	v := db.versions.Current()
+	if v != nil {
+		v.Ref()
+	}
+	db.mu.RUnlock()
+	if v == nil {
+		return nil, nil
+	}
+	defer v.Unref()
+
+	samples, _, err := db.blockSizeSamples(v, DefaultColumnFamilyID, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	var cumulative uint64
+	for _, s := range samples {
+		cumulative += s.size
+		if cumulative >= pageBytes {
+			return s.key, nil
+		}
+	}
+	return nil, nil
+}
+
 // Resume resumes the database after an error.
 // Reference: RocksDB v10.7.5
 //   - include/rocksdb/db.h lines 476-482
@@ -6,10 +6,17 @@ package rockyardkv
 //   - include/rocksdb/db.h
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/aalhour/rockyardkv/internal/table"
 )
 
 func TestKeyMayExist(t *testing.T) {
@@ -79,6 +86,178 @@ func TestWaitForCompact(t *testing.T) {
 	}
 }
 
+func TestFlushAndWaitForL0(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 4
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	numL0 := func() int {
+		v, ok := db.GetProperty("rocksdb.num-files-at-level0")
+		if !ok {
+			t.Fatal("num-files-at-level0 not available")
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			t.Fatalf("num-files-at-level0 = %q: %v", v, err)
+		}
+		return n
+	}
+
+	// Build up an L0 backlog past the trigger while compaction can't run
+	if err := db.PauseBackgroundWork(); err != nil {
+		t.Fatalf("PauseBackgroundWork failed: %v", err)
+	}
+	for i := range 6 {
+		for j := range 50 {
+			key := fmt.Appendf(nil, "key%03d", j)
+			if err := db.Put(nil, key, fmt.Appendf(nil, "value%d", i)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	if n := numL0(); n < 4 {
+		t.Fatalf("num-files-at-level0 = %d before compaction, want at least 4", n)
+	}
+
+	// With compaction paused, L0 can't drain
+	if err := db.FlushAndWaitForL0(nil); err == nil {
+		t.Error("FlushAndWaitForL0 succeeded while background work was paused")
+	}
+
+	if err := db.ContinueBackgroundWork(); err != nil {
+		t.Fatalf("ContinueBackgroundWork failed: %v", err)
+	}
+	if err := db.Put(nil, []byte("key999"), []byte("last")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := db.FlushAndWaitForL0(nil); err != nil {
+		t.Fatalf("FlushAndWaitForL0 failed: %v", err)
+	}
+	if n := numL0(); n >= 4 {
+		t.Errorf("num-files-at-level0 = %d, want below trigger 4", n)
+	}
+
+	// Nothing was lost to the compaction
+	for j := range 50 {
+		key := fmt.Appendf(nil, "key%03d", j)
+		if v, err := db.Get(nil, key); err != nil || string(v) != "value5" {
+			t.Fatalf("Get(%s) = %q, %v, want value5", key, v, err)
+		}
+	}
+	if v, err := db.Get(nil, []byte("key999")); err != nil || string(v) != "last" {
+		t.Errorf("Get(key999) = %q, %v, want last", v, err)
+	}
+}
+
+func TestFlushAndWaitForL0ColumnFamily(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	cf, err := db.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+	for j := range 50 {
+		if err := db.PutCF(nil, cf, fmt.Appendf(nil, "key%03d", j), []byte("cf-value")); err != nil {
+			t.Fatalf("PutCF failed: %v", err)
+		}
+	}
+	if err := db.Put(nil, []byte("default-key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Only the named column family is flushed
+	if err := db.FlushAndWaitForL0(cf); err != nil {
+		t.Fatalf("FlushAndWaitForL0 failed: %v", err)
+	}
+	if n, ok := db.GetIntPropertyCF(cf, "rocksdb.num-files-at-level0"); !ok || n != 1 {
+		t.Errorf("cf1 num-files-at-level0 = %d, %v, want 1", n, ok)
+	}
+	if n, ok := db.GetIntProperty("rocksdb.num-files-at-level0"); !ok || n != 0 {
+		t.Errorf("default num-files-at-level0 = %d, %v, want 0", n, ok)
+	}
+	if n, ok := db.GetIntPropertyCF(cf, "rocksdb.num-entries-active-mem-table"); !ok || n != 0 {
+		t.Errorf("cf1 num-entries-active-mem-table = %d, %v, want 0", n, ok)
+	}
+	for j := range 50 {
+		key := fmt.Appendf(nil, "key%03d", j)
+		if v, err := db.GetCF(nil, cf, key); err != nil || string(v) != "cf-value" {
+			t.Fatalf("GetCF(%s) = %q, %v, want cf-value", key, v, err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The flushed file is in the MANIFEST
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	handle := db.GetColumnFamily("cf1")
+	if handle == nil {
+		t.Fatal("cf1 missing after reopen")
+	}
+	if n, ok := db.GetIntPropertyCF(handle, "rocksdb.num-files-at-level0"); !ok || n != 1 {
+		t.Errorf("cf1 num-files-at-level0 after reopen = %d, %v, want 1", n, ok)
+	}
+	if v, err := db.GetCF(nil, handle, []byte("key010")); err != nil || string(v) != "cf-value" {
+		t.Errorf("GetCF(key010) after reopen = %q, %v, want cf-value", v, err)
+	}
+}
+
+func TestFlushAndWaitForL0ColumnFamilyTrigger(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 4
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	cfOpts := DefaultColumnFamilyOptions()
+	cfOpts.Level0FileNumCompactionTrigger = 8
+	cf, err := db.CreateColumnFamily(cfOpts, "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	// Past the database's trigger but below the column family's, there is
+	// nothing to wait for even though compaction can't run
+	if err := db.PauseBackgroundWork(); err != nil {
+		t.Fatalf("PauseBackgroundWork failed: %v", err)
+	}
+	for i := range 6 {
+		if err := db.PutCF(nil, cf, []byte("key"), fmt.Appendf(nil, "value%d", i)); err != nil {
+			t.Fatalf("PutCF failed: %v", err)
+		}
+		if err := db.FlushAndWaitForL0(cf); err != nil {
+			t.Fatalf("FlushAndWaitForL0 #%d failed: %v", i, err)
+		}
+	}
+	if n, ok := db.GetIntPropertyCF(cf, "rocksdb.num-files-at-level0"); !ok || n != 6 {
+		t.Errorf("cf1 num-files-at-level0 = %d, %v, want 6", n, ok)
+	}
+	if err := db.ContinueBackgroundWork(); err != nil {
+		t.Fatalf("ContinueBackgroundWork failed: %v", err)
+	}
+}
+
 func TestGetApproximateSizes(t *testing.T) {
 	dir := t.TempDir()
 	opts := DefaultOptions()
@@ -99,56 +278,734 @@ func TestGetApproximateSizes(t *testing.T) {
 		}
 	}
 
-	// Get approximate sizes
-	ranges := []Range{
-		{Start: []byte("a"), Limit: []byte("m")},
-		{Start: []byte("m"), Limit: []byte("z")},
-	}
-	sizes, err := impl.GetApproximateSizes(ranges, SizeApproximationIncludeMemtables|SizeApproximationIncludeFiles)
+	// Get approximate sizes
+	ranges := []Range{
+		{Start: []byte("a"), Limit: []byte("m")},
+		{Start: []byte("m"), Limit: []byte("z")},
+	}
+	sizes, err := impl.GetApproximateSizes(ranges, SizeApproximationIncludeMemtables|SizeApproximationIncludeFiles)
+	if err != nil {
+		t.Fatalf("GetApproximateSizes failed: %v", err)
+	}
+
+	if len(sizes) != 2 {
+		t.Errorf("Expected 2 sizes, got %d", len(sizes))
+	}
+
+	// Sizes should be non-zero since we have data
+	t.Logf("Range sizes: %v", sizes)
+}
+
+func TestGetApproximateSizesWholeColumnFamily(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for round := range 3 {
+		for i := range 500 {
+			key := fmt.Appendf(nil, "key%d-%04d", round, i)
+			if err := db.Put(nil, key, []byte(strings.Repeat("v", 50))); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		// The last round stays in the memtable
+		if round < 2 {
+			if err := db.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+
+	var sstSize uint64
+	for _, f := range db.GetLiveFilesMetaData() {
+		sstSize += f.Size
+	}
+	memSize, _ := db.GetActiveMemTableUsage(nil)
+	if sstSize == 0 || memSize == 0 {
+		t.Fatalf("SST size = %d, memtable usage = %d, want both non-zero", sstSize, memSize)
+	}
+
+	flags := SizeApproximationIncludeFiles | SizeApproximationIncludeMemtables
+	for _, r := range []Range{{}, {Start: []byte{}, Limit: []byte{}}} {
+		sizes, err := db.GetApproximateSizes([]Range{r}, flags)
+		if err != nil {
+			t.Fatalf("GetApproximateSizes failed: %v", err)
+		}
+		if want := sstSize + memSize; sizes[0] != want {
+			t.Errorf("size of %q-%q = %d, want SST size %d + memtable usage %d", r.Start, r.Limit, sizes[0], sstSize, memSize)
+		}
+
+		files, err := db.GetApproximateSizes([]Range{r}, SizeApproximationIncludeFiles)
+		if err != nil {
+			t.Fatalf("GetApproximateSizes failed: %v", err)
+		}
+		if files[0] != sstSize {
+			t.Errorf("file size of %q-%q = %d, want %d", r.Start, r.Limit, files[0], sstSize)
+		}
+	}
+}
+
+func TestGetApproximateSizesAtSnapshot(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 8 // keep the files in L0
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	write := func(round int) {
+		t.Helper()
+		for i := range 500 {
+			key := fmt.Appendf(nil, "key%04d-%d", i, round)
+			if err := db.Put(nil, key, []byte(strings.Repeat("v", 50))); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	write(0)
+	ranges := []Range{{}, {Start: []byte("key0100"), Limit: []byte("key0200")}}
+	before, err := db.GetApproximateSizes(ranges, SizeApproximationIncludeFiles)
+	if err != nil {
+		t.Fatalf("GetApproximateSizes failed: %v", err)
+	}
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	write(1)
+	write(2)
+
+	after, err := db.GetApproximateSizes(ranges, SizeApproximationIncludeFiles)
+	if err != nil {
+		t.Fatalf("GetApproximateSizes failed: %v", err)
+	}
+	atSnap, err := db.GetApproximateSizesAtSnapshot(snap, nil, ranges)
+	if err != nil {
+		t.Fatalf("GetApproximateSizesAtSnapshot failed: %v", err)
+	}
+	current, err := db.GetApproximateSizesAtSnapshot(nil, nil, ranges)
+	if err != nil {
+		t.Fatalf("GetApproximateSizesAtSnapshot(nil) failed: %v", err)
+	}
+	for i, r := range ranges {
+		if before[i] == 0 || after[i] <= before[i] {
+			t.Fatalf("size of %q-%q = %d before growth, %d after, want growth", r.Start, r.Limit, before[i], after[i])
+		}
+		if atSnap[i] != before[i] {
+			t.Errorf("size of %q-%q at snapshot = %d, want %d", r.Start, r.Limit, atSnap[i], before[i])
+		}
+		if current[i] != after[i] {
+			t.Errorf("size of %q-%q without snapshot = %d, want %d", r.Start, r.Limit, current[i], after[i])
+		}
+	}
+}
+
+func TestGetColumnFamilyMetaData(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	cf, err := db.CreateColumnFamily(DefaultColumnFamilyOptions(), "other")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	for round := range 2 {
+		for i := range 10 {
+			key := fmt.Appendf(nil, "key%d-%d", round, i)
+			if err := db.Put(nil, key, []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	meta, err := db.GetColumnFamilyMetaData(nil)
+	if err != nil {
+		t.Fatalf("GetColumnFamilyMetaData failed: %v", err)
+	}
+	if meta.Name != DefaultColumnFamilyName {
+		t.Errorf("Name = %q, want %q", meta.Name, DefaultColumnFamilyName)
+	}
+	if len(meta.Levels) != db.(*dbImpl).NumberLevels() {
+		t.Errorf("Levels = %d, want %d", len(meta.Levels), db.(*dbImpl).NumberLevels())
+	}
+	if meta.FileCount != 2 || len(meta.Levels[0].Files) != 2 {
+		t.Errorf("FileCount = %d, L0 files = %d, want 2 and 2", meta.FileCount, len(meta.Levels[0].Files))
+	}
+	if meta.Size == 0 || meta.Size != meta.Levels[0].Size {
+		t.Errorf("Size = %d, L0 size = %d, want equal and non-zero", meta.Size, meta.Levels[0].Size)
+	}
+
+	// Files of the default column family are not listed for another one
+	meta, err = db.GetColumnFamilyMetaData(cf)
+	if err != nil {
+		t.Fatalf("GetColumnFamilyMetaData(other) failed: %v", err)
+	}
+	if meta.Name != "other" || meta.FileCount != 0 || meta.Size != 0 {
+		t.Errorf("other = {%q, %d files, %d bytes}, want no files", meta.Name, meta.FileCount, meta.Size)
+	}
+}
+
+func TestGetLiveFilesStorageInfo(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	put := func(prefix string) {
+		t.Helper()
+		for i := range 100 {
+			if err := db.Put(nil, fmt.Appendf(nil, "%s%03d", prefix, i), []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+	}
+	put("flushed")
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	put("unflushed")
+
+	// A WAL size threshold that is not reached skips the flush
+	files, err := db.GetLiveFilesStorageInfo(LiveFilesStorageInfoOptions{WALSizeForFlush: 1 << 40})
+	if err != nil {
+		t.Fatalf("GetLiveFilesStorageInfo failed: %v", err)
+	}
+	byType := make(map[FileType][]LiveFileStorageInfo)
+	for _, f := range files {
+		if f.Directory != dir {
+			t.Errorf("%s: Directory = %q, want %q", f.RelativeFilename, f.Directory, dir)
+		}
+		byType[f.FileType] = append(byType[f.FileType], f)
+	}
+
+	// SSTs are copied whole, so they can be hard linked
+	if n := len(byType[FileTypeTable]); n != 1 {
+		t.Fatalf("%d SST files, want 1", n)
+	}
+	for _, f := range byType[FileTypeTable] {
+		info, err := os.Stat(filepath.Join(dir, f.RelativeFilename))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if f.TrimToSize || f.ReplacementContents != "" || f.Size != uint64(info.Size()) {
+			t.Errorf("SST %+v, want the whole %d-byte file", f, info.Size())
+		}
+	}
+
+	// The MANIFEST and the WAL are copied up to their current size
+	for _, typ := range []FileType{FileTypeDescriptor, FileTypeWAL} {
+		if n := len(byType[typ]); n != 1 {
+			t.Fatalf("%d files of type %d, want 1", n, typ)
+		}
+		f := byType[typ][0]
+		info, err := os.Stat(filepath.Join(dir, f.RelativeFilename))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if !f.TrimToSize || f.Size == 0 || f.Size != uint64(info.Size()) {
+			t.Errorf("%+v, want trimmed to the %d-byte file", f, info.Size())
+		}
+	}
+	manifest := byType[FileTypeDescriptor][0]
+	if !strings.HasPrefix(manifest.RelativeFilename, "MANIFEST-") {
+		t.Errorf("descriptor = %q, want a MANIFEST", manifest.RelativeFilename)
+	}
+	if got := byType[FileTypeCurrent]; len(got) != 1 || got[0].ReplacementContents != manifest.RelativeFilename+"\n" {
+		t.Errorf("CURRENT = %+v, want contents naming %s", got, manifest.RelativeFilename)
+	}
+
+	// Writes after the call grow the WAL past the listed size
+	put("later")
+
+	// A copy built from the list holds every write made before the call
+	copyDir := t.TempDir()
+	for _, f := range files {
+		dst := filepath.Join(copyDir, f.RelativeFilename)
+		if f.ReplacementContents != "" {
+			if err := os.WriteFile(dst, []byte(f.ReplacementContents), 0644); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(f.Directory, f.RelativeFilename))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if uint64(len(data)) < f.Size {
+			t.Fatalf("%s has %d bytes, want at least %d", f.RelativeFilename, len(data), f.Size)
+		}
+		if err := os.WriteFile(dst, data[:f.Size], 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	copyDB, err := Open(copyDir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open copy failed: %v", err)
+	}
+	defer copyDB.Close()
+	for _, key := range []string{"flushed000", "unflushed099"} {
+		if _, err := copyDB.Get(nil, []byte(key)); err != nil {
+			t.Errorf("copy Get(%s) failed: %v", key, err)
+		}
+	}
+	if _, err := copyDB.Get(nil, []byte("later000")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("copy Get(later000) = %v, want ErrNotFound", err)
+	}
+
+	// By default the memtable is flushed first
+	files, err = db.GetLiveFilesStorageInfo(LiveFilesStorageInfoOptions{})
+	if err != nil {
+		t.Fatalf("GetLiveFilesStorageInfo failed: %v", err)
+	}
+	var ssts int
+	for _, f := range files {
+		if f.FileType == FileTypeTable {
+			ssts++
+		}
+	}
+	if ssts != 2 {
+		t.Errorf("%d SST files after the flush, want 2", ssts)
+	}
+}
+
+func TestGetApproximateSizesAccountForRangeTombstones(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := range 2000 {
+		key := fmt.Appendf(nil, "key%05d", i)
+		value := fmt.Appendf(nil, "value-%05d-%s", i, strings.Repeat(strconv.Itoa(i), 20))
+		if err := db.Put(nil, key, value); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	deleted := Range{Start: []byte("key00000"), Limit: []byte("key01000")}
+	ranges := []Range{deleted}
+	accounted := &SizeApproximationOptions{IncludeFiles: true, AccountForRangeTombstones: true}
+
+	before, err := db.GetApproximateSizesWithOptions(accounted, ranges)
+	if err != nil {
+		t.Fatalf("GetApproximateSizesWithOptions failed: %v", err)
+	}
+
+	if err := db.DeleteRange(nil, deleted.Start, deleted.Limit); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		plain, err := db.GetApproximateSizesWithOptions(&SizeApproximationOptions{IncludeFiles: true}, ranges)
+		if err != nil {
+			t.Fatalf("%s: GetApproximateSizesWithOptions failed: %v", stage, err)
+		}
+		after, err := db.GetApproximateSizesWithOptions(accounted, ranges)
+		if err != nil {
+			t.Fatalf("%s: GetApproximateSizesWithOptions failed: %v", stage, err)
+		}
+		if plain[0] < before[0] {
+			t.Errorf("%s: size without flag = %d, want >= %d", stage, plain[0], before[0])
+		}
+		if after[0]*3 > before[0]*2 {
+			t.Errorf("%s: size with flag = %d, want well below %d", stage, after[0], before[0])
+		}
+	}
+
+	// Tombstone still in the memtable.
+	check("memtable")
+
+	// Tombstone flushed into its own SST.
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	check("flushed")
+}
+
+func TestGetApproximateSizesIncludeBlobFiles(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.EnableBlobFiles = true
+	opts.MinBlobSize = 100
+
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// Enough keys for the SST to span several data blocks
+	const numKeys = 1000
+	const valueSize = 1 << 10
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "blob%04d", i), bytes.Repeat([]byte{byte(i)}, valueSize)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	ranges := []Range{
+		{},
+		{Start: []byte("blob"), Limit: []byte("blob0500")},
+		{Start: []byte("zzz")},
+	}
+	without, err := db.GetApproximateSizes(ranges, SizeApproximationIncludeFiles)
+	if err != nil {
+		t.Fatalf("GetApproximateSizes failed: %v", err)
+	}
+	with, err := db.GetApproximateSizes(ranges, SizeApproximationIncludeFiles|SizeApproximationIncludeBlobFiles)
+	if err != nil {
+		t.Fatalf("GetApproximateSizes with blob files failed: %v", err)
+	}
+
+	// The whole key space holds every blob file byte
+	if blobBytes := with[0] - without[0]; blobBytes < numKeys*valueSize || blobBytes > 2*numKeys*valueSize {
+		t.Errorf("whole range adds %d blob bytes, want about %d", blobBytes, numKeys*valueSize)
+	}
+	// Half the SST bytes: about half the blob bytes
+	if blobBytes := with[1] - without[1]; blobBytes < numKeys/4*valueSize || blobBytes > 3*numKeys/4*valueSize {
+		t.Errorf("half range adds %d blob bytes, want about %d", blobBytes, numKeys/2*valueSize)
+	}
+	// No SST bytes, no blob bytes
+	if with[2] != without[2] {
+		t.Errorf("empty range size = %d with blob files, %d without, want equal", with[2], without[2])
+	}
+}
+
+func TestGetApproximateSplitKey(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// Uniformly sized entries spread over two flushed files.
+	const numKeys = 4000
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if err := db.Put(nil, key, []byte(strings.Repeat("v", 100))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i == numKeys/3 {
+			if err := db.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	keyIndex := func(key []byte) int {
+		t.Helper()
+		n, err := strconv.Atoi(strings.TrimPrefix(string(key), "key"))
+		if err != nil {
+			t.Fatalf("unexpected split key %q", key)
+		}
+		return n
+	}
+	const tolerance = numKeys / 20
+
+	split, err := db.GetApproximateSplitKey(nil, nil, nil)
+	if err != nil {
+		t.Fatalf("GetApproximateSplitKey failed: %v", err)
+	}
+	if n := keyIndex(split); n < numKeys/2-tolerance || n > numKeys/2+tolerance {
+		t.Errorf("split key of whole range = %q, want near key%05d", split, numKeys/2)
+	}
+
+	begin, end := []byte("key01000"), []byte("key02000")
+	split, err = db.GetApproximateSplitKey(nil, begin, end)
+	if err != nil {
+		t.Fatalf("GetApproximateSplitKey failed: %v", err)
+	}
+	if bytes.Compare(split, begin) <= 0 || bytes.Compare(split, end) >= 0 {
+		t.Fatalf("split key %q outside [%q, %q)", split, begin, end)
+	}
+	if n := keyIndex(split); n < 1500-tolerance || n > 1500+tolerance {
+		t.Errorf("split key of [key01000, key02000) = %q, want near key01500", split)
+	}
+
+	// A range within a single data block cannot be split.
+	if _, err := db.GetApproximateSplitKey(nil, []byte("key01000"), []byte("key01002")); !errors.Is(err, ErrRangeTooSmallToSplit) {
+		t.Errorf("tiny range: expected ErrRangeTooSmallToSplit, got %v", err)
+	}
+	if _, err := db.GetApproximateSplitKey(nil, []byte("zzz"), nil); !errors.Is(err, ErrRangeTooSmallToSplit) {
+		t.Errorf("empty range: expected ErrRangeTooSmallToSplit, got %v", err)
+	}
+}
+
+func TestGetApproximateKeyCount(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// Uniformly populated keys spread over two flushed files.
+	const numKeys = 4000
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%05d", i), []byte(strings.Repeat("v", 100))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i == numKeys/2 {
+			if err := db.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	key := func(i int) []byte { return fmt.Appendf(nil, "key%05d", i) }
+	ranges := []Range{
+		{Start: nil, Limit: nil},
+		{Start: key(0), Limit: key(400)},
+		{Start: key(400), Limit: key(1200)},
+		{Start: key(1200), Limit: key(2800)},
+		{Start: key(2800), Limit: key(4000)},
+		{Start: []byte("zzz"), Limit: nil},
+	}
+	counts, err := db.GetApproximateKeyCount(nil, ranges)
+	if err != nil {
+		t.Fatalf("GetApproximateKeyCount failed: %v", err)
+	}
+
+	// The estimates are proportional to the widths of the ranges
+	if counts[0] != numKeys {
+		t.Errorf("count of the whole key space = %d, want %d", counts[0], numKeys)
+	}
+	const tolerance = numKeys / 40
+	for i, r := range ranges[1:5] {
+		lo, _ := strconv.Atoi(strings.TrimPrefix(string(r.Start), "key"))
+		hi, _ := strconv.Atoi(strings.TrimPrefix(string(r.Limit), "key"))
+		if got, want := int(counts[i+1]), hi-lo; got < want-tolerance || got > want+tolerance {
+			t.Errorf("count of [%s, %s) = %d, want %d ± %d", r.Start, r.Limit, got, want, tolerance)
+		}
+	}
+	if counts[5] != 0 {
+		t.Errorf("count past the last key = %d, want 0", counts[5])
+	}
+
+	if _, err := db.GetApproximateKeyCount(&columnFamilyHandle{}, ranges); !errors.Is(err, ErrInvalidColumnFamilyHandle) {
+		t.Errorf("invalid column family: got %v, want ErrInvalidColumnFamilyHandle", err)
+	}
+}
+
+func TestGetApproximateMemTableStats(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	impl := db.(*dbImpl)
+
+	// Write some data
+	for i := range 100 {
+		key := []byte(string(rune('a' + i%26)))
+		if err := db.Put(nil, key, []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Get memtable stats
+	r := Range{Start: nil, Limit: nil}
+	count, size := impl.GetApproximateMemTableStats(r)
+
+	if count == 0 {
+		t.Error("Expected non-zero count")
+	}
+	if size == 0 {
+		t.Error("Expected non-zero size")
+	}
+
+	t.Logf("MemTable stats: count=%d, size=%d", count, size)
+}
+
+func TestGetActiveMemTableUsage(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.WriteBufferSize = 64 * 1024
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// The ratio climbs toward 1.0 as the memtable fills up
+	value := make([]byte, 1000)
+	var lastRatio float64
+	for i := 0; lastRatio < 0.9; i++ {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%04d", i), value); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		bytes, ratio := db.GetActiveMemTableUsage(nil)
+		if ratio <= lastRatio {
+			t.Fatalf("ratio after %d writes = %v, want above %v", i+1, ratio, lastRatio)
+		}
+		if want := float64(bytes) / float64(opts.WriteBufferSize); ratio != want {
+			t.Fatalf("ratio = %v, want %d bytes / %d = %v", ratio, bytes, opts.WriteBufferSize, want)
+		}
+		lastRatio = ratio
+	}
+	if lastRatio > 1 {
+		t.Errorf("ratio = %v, want at most 1 just past 0.9", lastRatio)
+	}
+
+	// A flush switches to an empty memtable
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if _, ratio := db.GetActiveMemTableUsage(nil); ratio >= 0.1 {
+		t.Errorf("ratio after flush = %v, want close to 0", ratio)
+	}
+}
+
+func TestGetActiveMemTableUsageColumnFamily(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	cfOpts := DefaultColumnFamilyOptions()
+	cfOpts.WriteBufferSize = 16 * 1024
+	cf, err := db.CreateColumnFamily(cfOpts, "cf")
 	if err != nil {
-		t.Fatalf("GetApproximateSizes failed: %v", err)
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+	if err := db.PutCF(nil, cf, []byte("key"), make([]byte, 4000)); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
 	}
 
-	if len(sizes) != 2 {
-		t.Errorf("Expected 2 sizes, got %d", len(sizes))
+	// The ratio is relative to the column family's own write buffer size
+	bytes, ratio := db.GetActiveMemTableUsage(cf)
+	if bytes < 4000 {
+		t.Errorf("bytes = %d, want at least 4000", bytes)
+	}
+	if want := float64(bytes) / float64(cfOpts.WriteBufferSize); ratio != want {
+		t.Errorf("ratio = %v, want %v", ratio, want)
+	}
+	if bytes, _ := db.GetActiveMemTableUsage(nil); bytes >= 4000 {
+		t.Errorf("default column family bytes = %d, want the write in cf only", bytes)
 	}
 
-	// Sizes should be non-zero since we have data
-	t.Logf("Range sizes: %v", sizes)
+	if err := db.DropColumnFamily(cf); err != nil {
+		t.Fatalf("DropColumnFamily failed: %v", err)
+	}
+	if bytes, ratio := db.GetActiveMemTableUsage(cf); bytes != 0 || ratio != 0 {
+		t.Errorf("dropped column family usage = (%d, %v), want (0, 0)", bytes, ratio)
+	}
 }
 
-func TestGetApproximateMemTableStats(t *testing.T) {
+func TestWarmBlockCache(t *testing.T) {
 	dir := t.TempDir()
 	opts := DefaultOptions()
 	opts.CreateIfMissing = true
+	opts.BlockSize = 1024
 	db, err := Open(dir, opts)
 	if err != nil {
 		t.Fatalf("Open failed: %v", err)
 	}
-	defer db.Close()
-
-	impl := db.(*dbImpl)
-
-	// Write some data
-	for i := range 100 {
-		key := []byte(string(rune('a' + i%26)))
-		if err := db.Put(nil, key, []byte("value")); err != nil {
+	for i := range 1000 {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%04d", i), make([]byte, 100)); err != nil {
 			t.Fatalf("Put failed: %v", err)
 		}
 	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	db.Close()
 
-	// Get memtable stats
-	r := Range{Start: nil, Limit: nil}
-	count, size := impl.GetApproximateMemTableStats(r)
+	// Warm a range of a cold cache after a restart
+	blockCache := NewLRUCache(16 << 20)
+	opts.BlockCache = blockCache
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	// Flush what was replayed from the WAL, so reads go to the SST files
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := db.WarmBlockCache(nil, []byte("key0200"), []byte("key0400")); err != nil {
+		t.Fatalf("WarmBlockCache failed: %v", err)
+	}
+	if blockCache.GetOccupancyCount() == 0 {
+		t.Fatal("WarmBlockCache cached no blocks")
+	}
 
-	if count == 0 {
-		t.Error("Expected non-zero count")
+	// Reads of the range hit the cache
+	hits, misses := blockCache.GetHitCount(), blockCache.GetMissCount()
+	for i := 200; i < 400; i++ {
+		if _, err := db.Get(nil, fmt.Appendf(nil, "key%04d", i)); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
 	}
-	if size == 0 {
-		t.Error("Expected non-zero size")
+	if got := blockCache.GetMissCount() - misses; got != 0 {
+		t.Errorf("reads of the warmed range missed the cache %d times, want 0", got)
+	}
+	if got := blockCache.GetHitCount() - hits; got < 200 {
+		t.Errorf("reads of the warmed range hit the cache %d times, want at least 200", got)
 	}
 
-	t.Logf("MemTable stats: count=%d, size=%d", count, size)
+	// Reads outside of it do not
+	misses = blockCache.GetMissCount()
+	if _, err := db.Get(nil, []byte("key0900")); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if blockCache.GetMissCount() == misses {
+		t.Error("read outside the warmed range hit the cache, want a miss")
+	}
 }
 
 func TestNumberLevels(t *testing.T) {
@@ -271,6 +1128,167 @@ func TestSetOptions(t *testing.T) {
 	}
 }
 
+func TestSetOptionsCompression(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	impl := db.(*dbImpl)
+	sstCompression := func(fileNum uint64) string {
+		t.Helper()
+		return sstCompressionName(t, impl, fileNum)
+	}
+
+	// writeAndFlush writes compressible values under prefix and returns the
+	// number of the SST the flush produced
+	writeAndFlush := func(prefix string) uint64 {
+		t.Helper()
+		for i := range 200 {
+			key := fmt.Appendf(nil, "%s%04d", prefix, i)
+			if err := db.Put(nil, key, bytes.Repeat([]byte(prefix), 100)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		before := make(map[uint64]bool)
+		for _, f := range db.GetLiveFilesMetaData() {
+			before[f.FileNumber] = true
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		for _, f := range db.GetLiveFilesMetaData() {
+			if !before[f.FileNumber] {
+				return f.FileNumber
+			}
+		}
+		t.Fatal("Flush produced no SST")
+		return 0
+	}
+
+	oldFile := writeAndFlush("old")
+	if got := sstCompression(oldFile); got != NoCompression.String() {
+		t.Fatalf("SST before SetOptions compressed with %s, want %s", got, NoCompression)
+	}
+
+	if err := impl.SetOptions(map[string]string{"compression": "bogus"}); err == nil {
+		t.Error("SetOptions accepted an unknown compression type")
+	}
+	if err := impl.SetOptions(map[string]string{"compression": "kSnappyCompression"}); err != nil {
+		t.Fatalf("SetOptions failed: %v", err)
+	}
+	if got := impl.GetOptions().Compression; got != SnappyCompression {
+		t.Errorf("Compression = %s, want %s", got, SnappyCompression)
+	}
+
+	newFile := writeAndFlush("new")
+	if got := sstCompression(newFile); got != SnappyCompression.String() {
+		t.Errorf("SST after SetOptions compressed with %s, want %s", got, SnappyCompression)
+	}
+	if got := sstCompression(oldFile); got != NoCompression.String() {
+		t.Errorf("old SST now reports %s, want %s", got, NoCompression)
+	}
+
+	// Both SSTs read back correctly from disk
+	impl.tableCache.Close()
+	for _, prefix := range []string{"old", "new"} {
+		for i := range 200 {
+			key := fmt.Appendf(nil, "%s%04d", prefix, i)
+			v, err := db.Get(nil, key)
+			if err != nil {
+				t.Fatalf("Get(%s) failed: %v", key, err)
+			}
+			if !bytes.Equal(v, bytes.Repeat([]byte(prefix), 100)) {
+				t.Fatalf("Get(%s) returned wrong value", key)
+			}
+		}
+	}
+
+	// Compaction rewrites the old data with the new algorithm
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+	for _, f := range db.GetLiveFilesMetaData() {
+		if got := sstCompression(f.FileNumber); got != SnappyCompression.String() {
+			t.Errorf("compaction output %s compressed with %s, want %s", f.Name, got, SnappyCompression)
+		}
+	}
+}
+
+// TestSetOptionsCompressionParallelCompaction checks that a compression set
+// at runtime reaches the outputs of a parallel compaction whose inputs all
+// span the same keys, so that it runs as a single job.
+func TestSetOptionsCompressionParallelCompaction(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MaxSubcompactions = 4
+	opts.Level0FileNumCompactionTrigger = 4
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	impl := db.(*dbImpl)
+	if err := impl.SetOptions(map[string]string{"compression": "kSnappyCompression"}); err != nil {
+		t.Fatalf("SetOptions failed: %v", err)
+	}
+
+	// The fourth flush triggers an L0 compaction of all four files
+	for round := range 4 {
+		for i := range 200 {
+			key := fmt.Appendf(nil, "key%04d", i)
+			if err := db.Put(nil, key, bytes.Repeat([]byte{'a' + byte(round)}, 100)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	if err := db.WaitForCompact(&WaitForCompactOptions{Timeout: 10 * time.Second}); err != nil {
+		t.Fatalf("WaitForCompact failed: %v", err)
+	}
+
+	files := db.GetLiveFilesMetaData()
+	if len(files) == 0 {
+		t.Fatal("no live files after compaction")
+	}
+	for _, f := range files {
+		if f.Level == 0 {
+			t.Errorf("%s still in L0 after compaction", f.Name)
+		}
+		if got := sstCompressionName(t, impl, f.FileNumber); got != SnappyCompression.String() {
+			t.Errorf("compaction output %s compressed with %s, want %s", f.Name, got, SnappyCompression)
+		}
+	}
+}
+
+// sstCompressionName returns the compression recorded in an SST's properties.
+func sstCompressionName(t *testing.T, impl *dbImpl, fileNum uint64) string {
+	t.Helper()
+	file, err := os.Open(impl.sstFilePath(fileNum))
+	if err != nil {
+		t.Fatalf("Failed to open SST: %v", err)
+	}
+	defer file.Close()
+	stat, _ := file.Stat()
+	reader, err := table.Open(&compatFileWrapper{f: file, size: stat.Size()}, table.ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+	props, err := reader.Properties()
+	if err != nil {
+		t.Fatalf("Properties failed: %v", err)
+	}
+	return props.CompressionName
+}
+
 func TestGetIntProperty(t *testing.T) {
 	dir := t.TempDir()
 	opts := DefaultOptions()
@@ -507,3 +1525,308 @@ func TestDBImplImplementsExtendedAPI(t *testing.T) {
 func cleanup(path string) {
 	os.RemoveAll(path)
 }
+
+func TestScanRange(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := range 10 {
+		key := fmt.Appendf(nil, "key%d", i)
+		if err := db.Put(nil, key, fmt.Appendf(nil, "value%d", i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Bounds are [begin, end); fn scribbles over the slices it is given
+	var keys, values [][]byte
+	err = db.ScanRange(nil, []byte("key2"), []byte("key6"), func(key, value []byte) error {
+		keys = append(keys, key)
+		values = append(values, value)
+		for i := range key {
+			key[i] = 'x'
+		}
+		for i := range value {
+			value[i] = 'y'
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanRange failed: %v", err)
+	}
+	if len(keys) != 4 {
+		t.Fatalf("ScanRange visited %d entries, want 4", len(keys))
+	}
+	for i, key := range keys {
+		if !bytes.Equal(key, bytes.Repeat([]byte("x"), len("key0"))) {
+			t.Errorf("entry %d key = %q, want the caller's own modification", i, key)
+		}
+		if !bytes.Equal(values[i], bytes.Repeat([]byte("y"), len("value0"))) {
+			t.Errorf("entry %d value = %q, want the caller's own modification", i, values[i])
+		}
+	}
+
+	// The modifications must not reach the DB or later entries
+	var seen []string
+	err = db.ScanRange(nil, nil, nil, func(key, value []byte) error {
+		if want := "value" + strings.TrimPrefix(string(key), "key"); string(value) != want {
+			t.Errorf("ScanRange(%s) value = %q, want %q", key, value, want)
+		}
+		seen = append(seen, string(key))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanRange failed: %v", err)
+	}
+	if len(seen) != 10 || seen[0] != "key0" || seen[9] != "key9" {
+		t.Errorf("ScanRange(nil, nil) = %v, want key0..key9", seen)
+	}
+
+	// The first fn error stops the scan
+	errStop := errors.New("stop")
+	calls := 0
+	err = db.ScanRange(nil, []byte("key5"), nil, func(key, value []byte) error {
+		calls++
+		if string(key) == "key6" {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("ScanRange error = %v, want %v", err, errStop)
+	}
+	if calls != 2 {
+		t.Errorf("fn called %d times, want 2", calls)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// Most keys in an SST file, the last ones in the memtable
+	const numKeys = 2000
+	const numFlushed = 1500
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%05d", i), value); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i == numFlushed-1 {
+			if err := db.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+	entryBytes := len("key00000") + len(value)
+
+	const pageBytes = 16 * 1024
+	const blockSize = 4096
+	next := db.Paginate(nil, []byte("key00100"), []byte("key01900"), pageBytes)
+	var pages [][]KeyValue
+	seen := 0
+	for {
+		page, err := next()
+		if err != nil {
+			t.Fatalf("page %d failed: %v", len(pages), err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, kv := range page {
+			if want := fmt.Sprintf("key%05d", 100+seen); string(kv.Key) != want {
+				t.Fatalf("page %d: key = %s, want %s", len(pages), kv.Key, want)
+			}
+			seen++
+		}
+		if seen > 1800 {
+			t.Fatalf("read %d keys, want 1800", seen)
+		}
+		pages = append(pages, page)
+	}
+	if seen != 1800 {
+		t.Errorf("read %d keys, want 1800", seen)
+	}
+	if page, err := next(); err != nil || len(page) != 0 {
+		t.Errorf("next() after the last page = %d entries, %v, want an empty page", len(page), err)
+	}
+
+	for i, page := range pages[:len(pages)-1] {
+		// Every page stays within pageBytes by less than one entry
+		size := 0
+		for _, kv := range page {
+			size += len(kv.Key) + len(kv.Value)
+		}
+		if size >= pageBytes+entryBytes {
+			t.Errorf("page %d: %d bytes, want less than %d", i, size, pageBytes+entryBytes)
+		}
+		if size >= pageBytes {
+			continue
+		}
+
+		// A smaller page of flushed keys ends at the data block that
+		// reaches pageBytes on disk
+		first, following := page[0].Key, pages[i+1][0].Key
+		if bytes.Compare(following, fmt.Appendf(nil, "key%05d", numFlushed)) > 0 {
+			t.Errorf("page %d: %d bytes of memtable keys, want at least %d", i, size, pageBytes)
+			continue
+		}
+		sizes, err := db.GetApproximateSizes([]Range{{Start: first, Limit: following}}, SizeApproximationIncludeFiles)
+		if err != nil {
+			t.Fatalf("GetApproximateSizes failed: %v", err)
+		}
+		if i > 0 && (sizes[0] < pageBytes || sizes[0] >= pageBytes+2*blockSize) {
+			t.Errorf("page %d: %d bytes on disk, want [%d, %d)", i, sizes[0], pageBytes, pageBytes+2*blockSize)
+		}
+	}
+}
+
+func TestPaginateUnflushedOverwrites(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// Small values on disk, overwritten by large ones in the memtable
+	const numKeys = 5000
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%05d", i), fmt.Appendf(nil, "%d", i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	value := bytes.Repeat([]byte("v"), 200)
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%05d", i), value); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	entryBytes := len("key00000") + len(value)
+
+	const pageBytes = 16 * 1024
+	next := db.Paginate(nil, nil, nil, pageBytes)
+	seen := 0
+	for {
+		page, err := next()
+		if err != nil {
+			t.Fatalf("next() failed: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		seen += len(page)
+		if size := len(page) * entryBytes; size >= pageBytes+entryBytes {
+			t.Errorf("page of %d bytes, want less than %d", size, pageBytes+entryBytes)
+		}
+	}
+	if seen != numKeys {
+		t.Errorf("read %d keys, want %d", seen, numKeys)
+	}
+}
+
+func TestPaginateSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, key := range []string{"a", "c", "e"} {
+		if err := db.Put(nil, []byte(key), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	snapshot := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snapshot)
+
+	// One entry per page; writes between pages are hidden by the snapshot
+	next := db.Paginate(&ReadOptions{Snapshot: snapshot}, nil, nil, 1)
+	var keys []string
+	for {
+		page, err := next()
+		if err != nil {
+			t.Fatalf("next failed: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		if len(page) != 1 {
+			t.Fatalf("page has %d entries, want 1", len(page))
+		}
+		keys = append(keys, string(page[0].Key))
+		if err := db.Put(nil, []byte(string(page[0].Key)+"x"), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if got := strings.Join(keys, ","); got != "a,c,e" {
+		t.Errorf("paged keys = %s, want a,c,e", got)
+	}
+
+	if _, err := db.Paginate(nil, nil, nil, 0)(); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Paginate with pageBytes 0 = %v, want ErrInvalidOptions", err)
+	}
+}
+
+func TestVerifyBlockCacheEntries(t *testing.T) {
+	opts := DefaultOptions()
+	blockCache := NewLRUCache(16 << 20)
+	opts.BlockCache = blockCache
+	opts.VerifyBlockCacheEntries = true
+	db, cleanup := createTestDB(t, opts)
+	defer cleanup()
+
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// The first read caches the block, the second one is served from it
+	for range 2 {
+		value, err := db.Get(nil, []byte("key"))
+		if err != nil || string(value) != "value" {
+			t.Fatalf("Get = (%q, %v), want (\"value\", nil)", value, err)
+		}
+	}
+
+	// Corrupt the cached block in memory
+	files := db.(*dbImpl).versions.Current().Files(0)
+	if len(files) != 1 {
+		t.Fatalf("got %d L0 files, want 1", len(files))
+	}
+	h := blockCache.Lookup(CacheKey{FileNumber: files[0].FD.GetNumber(), BlockOffset: 0})
+	if h == nil {
+		t.Fatal("data block is not in the block cache")
+	}
+	h.Value()[0] ^= 0xff
+	blockCache.Release(h)
+
+	if _, err := db.Get(nil, []byte("key")); !errors.Is(err, table.ErrChecksumMismatch) {
+		t.Errorf("Get of a corrupted cached block = %v, want ErrChecksumMismatch", err)
+	}
+}
@@ -9,7 +9,12 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
 )
 
 // =============================================================================
@@ -102,6 +107,159 @@ func TestRepeatedOpenClose(t *testing.T) {
 	}
 }
 
+// syncLogger is a captureLogger that counts Sync calls.
+type syncLogger struct {
+	captureLogger
+	syncs atomic.Int64
+}
+
+func (l *syncLogger) Sync() error {
+	l.syncs.Add(1)
+	return nil
+}
+
+// openFDs returns the number of open file descriptors of the process.
+func openFDs(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot count file descriptors: %v", err)
+	}
+	return len(entries)
+}
+
+func TestRepeatedOpenCloseReleasesResources(t *testing.T) {
+	const cycles = 20
+	logger := &syncLogger{}
+	limiter := NewGenericRateLimiter(nil)
+
+	cycle := func(dir string, opts *Options, i int) {
+		db, err := Open(dir, opts)
+		if err != nil {
+			t.Fatalf("Open %d error: %v", i, err)
+		}
+		for j := range 100 {
+			if err := db.Put(nil, fmt.Appendf(nil, "key_%d_%d", i, j), make([]byte, 100)); err != nil {
+				t.Fatalf("Put error: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush error: %v", err)
+		}
+		if err := db.CompactRange(nil, nil, nil); err != nil {
+			t.Fatalf("CompactRange error: %v", err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close %d error: %v", i, err)
+		}
+	}
+
+	// A shared logger is flushed by every Close, the LOG file of an
+	// unshared one is closed
+	shared := DefaultOptions()
+	shared.CreateIfMissing = true
+	shared.Logger = logger
+	shared.RateLimiter = limiter
+	owned := DefaultOptions()
+	owned.CreateIfMissing = true
+	owned.RateLimiter = limiter
+	sharedDir, ownedDir := t.TempDir(), t.TempDir()
+
+	cycle(sharedDir, shared, 0)
+	cycle(ownedDir, owned, 0)
+	fds := openFDs(t)
+	for i := 1; i <= cycles; i++ {
+		cycle(sharedDir, shared, i)
+		cycle(ownedDir, owned, i)
+	}
+
+	if got := openFDs(t); got > fds {
+		t.Errorf("%d file descriptors open after %d open/close cycles, want at most %d", got, cycles, fds)
+	}
+	if got := logger.syncs.Load(); got != cycles+1 {
+		t.Errorf("shared logger synced %d times, want %d", got, cycles+1)
+	}
+	if logger.contains("error") {
+		t.Errorf("shared logger logged an error: %v", logger.messages)
+	}
+}
+
+func TestCloseCancelsRateLimiterRequests(t *testing.T) {
+	// A limiter far too slow for the compaction to finish in the test
+	limiter := NewGenericRateLimiter(&RateLimiterOptions{BytesPerSecond: 1024})
+	opts := DefaultOptions()
+	opts.RateLimiter = limiter
+	opts.Level0FileNumCompactionTrigger = 2
+	db, cleanup := createTestDB(t, opts)
+	defer cleanup()
+
+	for i := range 2 {
+		for j := range 100 {
+			if err := db.Put(nil, fmt.Appendf(nil, "key_%d_%d", j, i), make([]byte, 1000)); err != nil {
+				t.Fatalf("Put error: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush error: %v", err)
+		}
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for limiter.GetTotalRequests(IOPriorityLow) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("compaction did not request rate limiter quota")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Close took %v waiting for the rate limiter", elapsed)
+	}
+
+	// The limiter keeps serving its other users
+	limiter.Request(1, IOPriorityLow)
+}
+
+func TestOpenLocked(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	// A second read-write open of the same directory must be refused.
+	if second, err := Open(dir, opts); !errors.Is(err, ErrDBLocked) {
+		if second != nil {
+			second.Close()
+		}
+		t.Fatalf("second Open error = %v, want ErrDBLocked", err)
+	}
+
+	// Read-only opens do not take the lock.
+	roDB, err := OpenForReadOnly(dir, opts, false)
+	if err != nil {
+		t.Fatalf("OpenForReadOnly while open error: %v", err)
+	}
+	roDB.Close()
+
+	// Closing releases the lock.
+	db.Close()
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open after Close error: %v", err)
+	}
+	db.Close()
+}
+
 // =============================================================================
 // Put/Get/Delete Tests
 // =============================================================================
@@ -503,3 +661,149 @@ func TestKeyOrdering(t *testing.T) {
 		}
 	}
 }
+
+// TestMemTableSkipListOptions checks that the memtable skiplist parameters
+// are validated and leave the key order unchanged.
+func TestMemTableSkipListOptions(t *testing.T) {
+	for _, tt := range []struct{ maxHeight, branchingFactor int }{
+		{-1, 0}, {33, 0}, {0, -1}, {0, 1},
+	} {
+		opts := DefaultOptions()
+		opts.CreateIfMissing = true
+		opts.MemTableSkipListMaxHeight = tt.maxHeight
+		opts.MemTableSkipListBranchingFactor = tt.branchingFactor
+		if _, err := Open(t.TempDir(), opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Open(max height %d, branching factor %d): err = %v, want ErrInvalidOptions",
+				tt.maxHeight, tt.branchingFactor, err)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MemTableSkipListMaxHeight = 4
+	opts.MemTableSkipListBranchingFactor = 16
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	const n = 1000
+	for i := range n {
+		key := fmt.Appendf(nil, "key%04d", (i*7)%n)
+		if err := db.Put(nil, key, key); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	iter := db.NewIterator(nil)
+	defer iter.Close()
+	i := 0
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		if want := fmt.Sprintf("key%04d", i); string(iter.Key()) != want {
+			t.Fatalf("Position %d: got %s, want %s", i, iter.Key(), want)
+		}
+		i++
+	}
+	if i != n {
+		t.Errorf("Iterated %d keys, want %d", i, n)
+	}
+}
+
+// =============================================================================
+// CompareAndSwap Tests
+// =============================================================================
+
+func TestCompareAndSwap(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	key := []byte("key")
+
+	// nil expected: the key must not exist
+	if ok, err := db.CompareAndSwap(nil, key, nil, []byte("v1")); err != nil || !ok {
+		t.Fatalf("CompareAndSwap(absent) = %v, %v, want true", ok, err)
+	}
+	if ok, err := db.CompareAndSwap(nil, key, nil, []byte("v2")); err != nil || ok {
+		t.Fatalf("CompareAndSwap(nil, existing) = %v, %v, want false", ok, err)
+	}
+
+	// Mismatched expected value leaves the key alone
+	if ok, err := db.CompareAndSwap(nil, key, []byte("wrong"), []byte("v2")); err != nil || ok {
+		t.Fatalf("CompareAndSwap(wrong) = %v, %v, want false", ok, err)
+	}
+	if v, _ := db.Get(nil, key); string(v) != "v1" {
+		t.Fatalf("Get = %q, want v1", v)
+	}
+
+	if ok, err := db.CompareAndSwap(nil, key, []byte("v1"), []byte("v2")); err != nil || !ok {
+		t.Fatalf("CompareAndSwap(v1) = %v, %v, want true", ok, err)
+	}
+	if v, _ := db.Get(nil, key); string(v) != "v2" {
+		t.Fatalf("Get = %q, want v2", v)
+	}
+
+	// A deleted key counts as absent
+	if err := db.Delete(nil, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ok, err := db.CompareAndSwap(nil, key, []byte("v2"), []byte("v3")); err != nil || ok {
+		t.Fatalf("CompareAndSwap(deleted) = %v, %v, want false", ok, err)
+	}
+	if ok, err := db.CompareAndSwap(nil, key, nil, []byte("v3")); err != nil || !ok {
+		t.Fatalf("CompareAndSwap(nil, deleted) = %v, %v, want true", ok, err)
+	}
+}
+
+func TestCompareAndSwapIgnoresOtherKeys(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+	db := database.(*dbImpl)
+
+	key := []byte("key")
+	if err := db.Put(nil, key, []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	writtenSince := func(seq uint64) bool {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		return db.keyWrittenSince(key, dbformat.SequenceNumber(seq))
+	}
+
+	// Writes to other keys do not count
+	readSeq := db.GetLatestSequenceNumber()
+	if err := db.Put(nil, []byte("other"), []byte("v")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if writtenSince(readSeq) {
+		t.Error("a write to another key counted as a write to key")
+	}
+
+	// Writes to the key do, including range deletions covering it
+	if err := db.Put(nil, key, []byte("v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if !writtenSince(readSeq) {
+		t.Error("a Put of key did not count")
+	}
+	readSeq = db.GetLatestSequenceNumber()
+	if err := db.DeleteRange(nil, []byte("a"), []byte("z")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if !writtenSince(readSeq) {
+		t.Error("a range deletion covering key did not count")
+	}
+}
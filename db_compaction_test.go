@@ -9,7 +9,17 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aalhour/rockyardkv/internal/block"
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/table"
+	"github.com/aalhour/rockyardkv/internal/version"
 )
 
 // =============================================================================
@@ -743,6 +753,60 @@ func TestCompactionWithMergeOperator(t *testing.T) {
 	}
 }
 
+// TestCompactionMergeIntoEntity verifies merge operands over a wide-column
+// entity survive compaction, applied to its default column.
+func TestCompactionMergeIntoEntity(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	key := []byte("entity_key")
+	columns := []WideColumn{
+		{Name: DefaultWideColumnName, Value: []byte("a")},
+		{Name: []byte("name"), Value: []byte("Alice")},
+	}
+	if err := db.PutEntity(nil, nil, key, columns); err != nil {
+		t.Fatalf("PutEntity failed: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := db.Merge(nil, key, []byte("b")); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	got, err := db.GetEntity(nil, nil, key)
+	if err != nil {
+		t.Fatalf("GetEntity failed: %v", err)
+	}
+	want := []WideColumn{
+		{Name: DefaultWideColumnName, Value: []byte("a,b")},
+		{Name: []byte("name"), Value: []byte("Alice")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetEntity returned %d columns, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i].Name, want[i].Name) || !bytes.Equal(got[i].Value, want[i].Value) {
+			t.Errorf("Column %d = %q:%q, want %q:%q", i, got[i].Name, got[i].Value, want[i].Name, want[i].Value)
+		}
+	}
+}
+
 // TestL0CompactionBugIssue44a is a regression test from LevelDB.
 // It tests a specific sequence of operations that caused a bug.
 //
@@ -866,3 +930,580 @@ func TestCompactionEmptyKeyValue(t *testing.T) {
 		t.Errorf("empty_value key = %s, %v; want now_has_value", val, err)
 	}
 }
+
+// =============================================================================
+// Checksum Verification Tests
+// =============================================================================
+
+// TestCompactionFailsOnCorruptInputBlock verifies that a corrupted data block
+// in a compaction input aborts the compaction with a background error instead
+// of producing an output file.
+func TestCompactionFailsOnCorruptInputBlock(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.VerifyChecksumsInCompaction = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	writeL0File := func(round int) {
+		t.Helper()
+		for i := range 20 {
+			key := fmt.Appendf(nil, "key_%d_%03d", round, i)
+			if err := db.Put(nil, key, bytes.Repeat([]byte("v"), 100)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	// One flush short of the L0 compaction trigger
+	for round := range opts.Level0FileNumCompactionTrigger - 1 {
+		writeL0File(round)
+	}
+
+	// Corrupt the first data block of the oldest SST
+	ssts := listSSTFiles(t, dir)
+	if len(ssts) == 0 {
+		t.Fatal("no SST files after flush")
+	}
+	path := filepath.Join(dir, ssts[0])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[10] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// The next flush triggers a compaction that reads the corrupt block
+	writeL0File(opts.Level0FileNumCompactionTrigger - 1)
+	before := listSSTFiles(t, dir)
+
+	impl := db.(*dbImpl)
+	deadline := time.Now().Add(5 * time.Second)
+	for impl.GetBackgroundError() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("compaction of a corrupt input did not fail")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := impl.GetBackgroundError(); !errors.Is(err, table.ErrChecksumMismatch) {
+		t.Errorf("background error = %v, want checksum mismatch", err)
+	}
+
+	if err := db.WaitForCompact(nil); err != nil {
+		t.Fatalf("WaitForCompact failed: %v", err)
+	}
+	if after := listSSTFiles(t, dir); !slices.Equal(after, before) {
+		t.Errorf("SST files after failed compaction = %v, want %v", after, before)
+	}
+	if err := db.Put(nil, []byte("key"), []byte("value")); !errors.Is(err, ErrBackgroundError) {
+		t.Errorf("Put after failed compaction err = %v, want ErrBackgroundError", err)
+	}
+}
+
+// listSSTFiles returns the sorted names of the SST files in dir.
+func listSSTFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".sst") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TestCompactionTargetFileSizeMultiplier verifies that compaction cuts output
+// files at TargetFileSizeBase * TargetFileSizeMultiplier^(level-1), without
+// letting range tombstones make the files of a level overlap.
+//
+// Reference: db/db_compaction_test.cc - target_file_size_multiplier
+func TestCompactionTargetFileSizeMultiplier(t *testing.T) {
+	const base = 64 * 1024
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.TargetFileSizeBase = base
+	opts.TargetFileSizeMultiplier = 2
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+	impl := database.(*dbImpl)
+
+	const numKeys = 2000
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if err := database.Put(nil, key, bytes.Repeat([]byte{byte(i)}, 1000)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.DeleteRange(nil, []byte("key00500"), []byte("key00600")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// compactLevel moves one level down at a time: L0 -> L1, then L1 -> L2
+	levelFileSizes := func(level int) []uint64 {
+		t.Helper()
+		impl.mu.RLock()
+		v := impl.versions.Current()
+		v.Ref()
+		impl.mu.RUnlock()
+		defer v.Unref()
+
+		if err := impl.compactLevel(v, level-1, nil, nil, &CompactRangeOptions{}); err != nil {
+			t.Fatalf("compactLevel(%d) failed: %v", level-1, err)
+		}
+
+		impl.mu.RLock()
+		defer impl.mu.RUnlock()
+		files := impl.versions.Current().Files(level)
+		var sizes []uint64
+		for i, f := range files {
+			sizes = append(sizes, f.FD.FileSize)
+			if i > 0 && dbformat.CompareInternalKeys(files[i-1].Largest, f.Smallest) >= 0 {
+				t.Errorf("L%d files %d and %d overlap", level, i-1, i)
+			}
+		}
+		return sizes
+	}
+
+	averages := make(map[int]uint64)
+	for _, tc := range []struct {
+		level  int
+		target uint64
+	}{
+		{1, base},
+		{2, 2 * base},
+	} {
+		sizes := levelFileSizes(tc.level)
+		if len(sizes) < 2 {
+			t.Fatalf("L%d files = %d, want several", tc.level, len(sizes))
+		}
+		// Every file but the last is cut close to the target
+		full := sizes[:len(sizes)-1]
+		var total uint64
+		for i, size := range full {
+			if size < tc.target*3/4 || size > tc.target*5/4 {
+				t.Errorf("L%d file %d size = %d, want about %d", tc.level, i, size, tc.target)
+			}
+			total += size
+		}
+		averages[tc.level] = total / uint64(len(full))
+	}
+	if ratio := float64(averages[2]) / float64(averages[1]); ratio < 1.8 || ratio > 2.2 {
+		t.Errorf("L2/L1 file size ratio = %.2f, want about 2", ratio)
+	}
+
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		_, err := database.Get(nil, key)
+		if deleted := i >= 500 && i < 600; deleted != errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) = %v, want deleted=%v", key, err, deleted)
+		}
+	}
+}
+
+// TestCompactionLevelCapacities verifies that leveled compaction keeps every
+// level within MaxBytesForLevelBase * MaxBytesForLevelMultiplier^(level-1)
+// and pushes the rest of the data to deeper levels.
+//
+// Reference: db/db_compaction_test.cc - max_bytes_for_level_multiplier
+func TestCompactionLevelCapacities(t *testing.T) {
+	const base = 32 * 1024
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MaxBytesForLevelBase = base
+	opts.MaxBytesForLevelMultiplier = 10
+	opts.TargetFileSizeBase = 16 * 1024
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	const numKeys = 1500
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if err := database.Put(nil, key, bytes.Repeat([]byte{byte(i)}, 1000)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i%100 == 99 {
+			if err := database.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+	if err := database.WaitForCompact(nil); err != nil {
+		t.Fatalf("WaitForCompact failed: %v", err)
+	}
+
+	meta, err := database.GetColumnFamilyMetaData(nil)
+	if err != nil {
+		t.Fatalf("GetColumnFamilyMetaData failed: %v", err)
+	}
+
+	// Levels 1 and 2 hold at most 32KB and 320KB; the rest lies deeper
+	capacity := uint64(base)
+	var deeper uint64
+	for _, level := range meta.Levels[1:] {
+		switch {
+		case level.Level <= 2:
+			if level.Size > capacity {
+				t.Errorf("L%d size = %d, want at most %d", level.Level, level.Size, capacity)
+			}
+			capacity *= 10
+		default:
+			deeper += level.Size
+		}
+	}
+	if deeper == 0 {
+		t.Error("Expected data below L2")
+	}
+
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if _, err := database.Get(nil, key); err != nil {
+			t.Errorf("Get(%s) failed: %v", key, err)
+		}
+	}
+}
+
+func TestCompactionNumLevels(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.NumLevels = 3
+	opts.MaxBytesForLevelBase = 16 * 1024
+	opts.TargetFileSizeBase = 8 * 1024
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	const numKeys = 1000
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if err := database.Put(nil, key, bytes.Repeat([]byte{byte(i)}, 1000)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i%100 == 99 {
+			if err := database.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+	if err := database.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	meta, err := database.GetColumnFamilyMetaData(nil)
+	if err != nil {
+		t.Fatalf("GetColumnFamilyMetaData failed: %v", err)
+	}
+	if len(meta.Levels) != 3 {
+		t.Fatalf("len(Levels) = %d, want 3", len(meta.Levels))
+	}
+	if meta.Levels[2].Size == 0 {
+		t.Error("Expected data in the bottommost level L2")
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Files at L2 cannot be opened with only two levels
+	opts.NumLevels = 2
+	if _, err := Open(dir, opts); !errors.Is(err, version.ErrTooManyLevels) {
+		t.Fatalf("Open with 2 levels: err = %v, want ErrTooManyLevels", err)
+	}
+
+	opts.NumLevels = 3
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer database.Close()
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if _, err := database.Get(nil, key); err != nil {
+			t.Errorf("Get(%s) failed: %v", key, err)
+		}
+	}
+}
+
+func TestOpenInvalidNumLevels(t *testing.T) {
+	for _, tc := range []struct {
+		numLevels int
+		style     CompactionStyle
+	}{
+		{-1, CompactionStyleLevel},
+		{version.MaxNumLevels + 1, CompactionStyleLevel},
+		{1, CompactionStyleLevel},
+		{1, CompactionStyleUniversal},
+	} {
+		opts := DefaultOptions()
+		opts.CreateIfMissing = true
+		opts.NumLevels = tc.numLevels
+		opts.CompactionStyle = tc.style
+		if _, err := Open(t.TempDir(), opts); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Open(num_levels=%d, style=%d): err = %v, want ErrInvalidOptions",
+				tc.numLevels, tc.style, err)
+		}
+	}
+}
+
+// sstFilterSize returns the size of the filter block of an SST file.
+func sstFilterSize(t *testing.T, path string) uint64 {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open SST: %v", err)
+	}
+	defer file.Close()
+
+	stat, _ := file.Stat()
+	reader, err := table.Open(&compatFileWrapper{f: file, size: stat.Size()}, table.ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+	props, err := reader.Properties()
+	if err != nil {
+		t.Fatalf("Properties failed: %v", err)
+	}
+	return props.FilterSize
+}
+
+func TestCompactionOptimizeFiltersForHits(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.OptimizeFiltersForHits = true
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	const numKeys = 300
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if err := database.Put(nil, key, fmt.Appendf(nil, "value%d", i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i%100 == 99 {
+			if err := database.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+
+	// Flushed files are not bottommost and keep their filters
+	files := database.GetLiveFilesMetaData()
+	if len(files) == 0 {
+		t.Fatal("Expected flushed files")
+	}
+	for _, f := range files {
+		if size := sstFilterSize(t, filepath.Join(f.Directory, f.Name)); size == 0 {
+			t.Errorf("L%d file %s has no filter", f.Level, f.Name)
+		}
+	}
+
+	if err := database.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	bottommost := database.(*dbImpl).NumberLevels() - 1
+	files = database.GetLiveFilesMetaData()
+	if len(files) == 0 {
+		t.Fatal("Expected compacted files")
+	}
+	for _, f := range files {
+		if f.Level != bottommost {
+			t.Errorf("File %s at L%d, want L%d", f.Name, f.Level, bottommost)
+		}
+		if size := sstFilterSize(t, filepath.Join(f.Directory, f.Name)); size != 0 {
+			t.Errorf("Bottommost file %s has a %d-byte filter", f.Name, size)
+		}
+	}
+
+	// Lookups fall back to the index
+	for i := range numKeys {
+		key := fmt.Appendf(nil, "key%05d", i)
+		value, err := database.Get(nil, key)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", key, err)
+		}
+		if want := fmt.Sprintf("value%d", i); string(value) != want {
+			t.Errorf("Get(%s) = %q, want %q", key, value, want)
+		}
+	}
+	if _, err := database.Get(nil, []byte("missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestCompactionOptimizeFiltersForHitsKeepsChecksums verifies that the
+// filter-less bottommost files of OptimizeFiltersForHits still carry block
+// checksums, so a corrupted data block is detected on read rather than
+// returned as data.
+func TestCompactionOptimizeFiltersForHitsKeepsChecksums(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.OptimizeFiltersForHits = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	// Without the WAL, reads after reopening come from the SST alone
+	writeOpts := DefaultWriteOptions()
+	writeOpts.DisableWAL = true
+	const numKeys = 100
+	for i := range numKeys {
+		if err := database.Put(writeOpts, fmt.Appendf(nil, "key%05d", i), bytes.Repeat([]byte("v"), 100)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := database.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+	files := database.GetLiveFilesMetaData()
+	if len(files) != 1 || files[0].Level != database.(*dbImpl).NumberLevels()-1 {
+		t.Fatalf("live files = %+v, want one bottommost file", files)
+	}
+	path := filepath.Join(files[0].Directory, files[0].Name)
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Every block of the file verifies against its checksum
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open SST: %v", err)
+	}
+	stat, _ := file.Stat()
+	reader, err := table.Open(&compatFileWrapper{f: file, size: stat.Size()}, table.ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+	if props, err := reader.Properties(); err != nil || props.FilterSize != 0 {
+		t.Errorf("bottommost file filter size = %d (err %v), want no filter", props.FilterSize, err)
+	}
+	if ct := reader.Footer().ChecksumType; ct == block.ChecksumTypeNone {
+		t.Errorf("bottommost file checksum type = %v, want block checksums", ct)
+	}
+	iter := reader.NewIteratorWithOptions(table.IteratorOptions{VerifyChecksums: true})
+	n := 0
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		n++
+	}
+	if err := iter.Error(); err != nil || n != numKeys {
+		t.Errorf("verified scan read %d keys (err %v), want %d", n, err, numKeys)
+	}
+	reader.Close()
+	file.Close()
+
+	// Corrupt the first data block
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[10] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer database.Close()
+	if _, err := database.Get(nil, []byte("key00000")); !errors.Is(err, table.ErrChecksumMismatch) {
+		t.Errorf("Get from a corrupted block err = %v, want checksum mismatch", err)
+	}
+	scan := database.NewIterator(nil)
+	defer scan.Close()
+	for scan.SeekToFirst(); scan.Valid(); scan.Next() {
+	}
+	if err := scan.Status(); err == nil {
+		t.Error("scan over a corrupted block succeeded, want an error")
+	}
+}
+
+// TestParallelCompactionSameBoundsKeepsSnapshot compacts L0 files that all
+// span the same keys, which leaves a parallel compaction nothing to split,
+// and checks that a snapshot still reads its versions afterwards.
+func TestParallelCompactionSameBoundsKeepsSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MaxSubcompactions = 4
+	opts.Level0FileNumCompactionTrigger = 10
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	var snap *Snapshot
+	for round := range 4 {
+		for _, key := range []string{"a", "m", "z"} {
+			if err := db.Put(nil, []byte(key), fmt.Appendf(nil, "v%d", round)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if round == 0 {
+			snap = db.GetSnapshot()
+		}
+	}
+	defer db.ReleaseSnapshot(snap)
+
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	snapOpts := DefaultReadOptions()
+	snapOpts.Snapshot = snap
+	for _, key := range []string{"a", "m", "z"} {
+		val, err := db.Get(snapOpts, []byte(key))
+		if err != nil || string(val) != "v0" {
+			t.Errorf("Get(%s) at snapshot = %q, %v; want v0", key, val, err)
+		}
+		val, err = db.Get(nil, []byte(key))
+		if err != nil || string(val) != "v3" {
+			t.Errorf("Get(%s) = %q, %v; want v3", key, val, err)
+		}
+	}
+}
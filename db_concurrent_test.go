@@ -443,3 +443,102 @@ func TestGetRaceWithFlush(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestConcurrentCompareAndSwap(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	key := []byte("counter")
+	if err := db.Put(nil, key, []byte("0")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	for round := range 100 {
+		expected := fmt.Appendf(nil, "%d", round)
+		next := fmt.Appendf(nil, "%d", round+1)
+
+		// Two racers try to move the same value forward; only one may win
+		var wg sync.WaitGroup
+		var wins atomic.Int32
+		start := make(chan struct{})
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				ok, err := db.CompareAndSwap(nil, key, expected, next)
+				if err != nil {
+					t.Errorf("CompareAndSwap failed: %v", err)
+				}
+				if ok {
+					wins.Add(1)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if n := wins.Load(); n != 1 {
+			t.Fatalf("round %d: %d racers succeeded, want exactly 1", round, n)
+		}
+	}
+
+	if v, _ := db.Get(nil, key); string(v) != "100" {
+		t.Errorf("Get = %q, want 100", v)
+	}
+}
+
+// TestConcurrentCompareAndSwapWithPut races a batch writing a key against a
+// CompareAndSwap expecting the key to be absent. Whichever runs first, the
+// batch's value is the last: a CompareAndSwap after the batch must fail, so
+// its value may only be overwritten by the batch. The batch writes the key
+// last, after enough other keys that its memtable insert is still running
+// when the CompareAndSwap reads.
+func TestConcurrentCompareAndSwapWithPut(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for round := range 100 {
+		key := fmt.Appendf(nil, "key%04d", round)
+		wb := NewWriteBatch()
+		for i := range 1000 {
+			wb.Put(fmt.Appendf(nil, "filler%04d-%04d", round, i), []byte("v"))
+		}
+		wb.Put(key, []byte("put"))
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		wg.Go(func() {
+			<-start
+			if err := db.Write(nil, wb); err != nil {
+				t.Errorf("Write failed: %v", err)
+			}
+		})
+		wg.Go(func() {
+			<-start
+			if _, err := db.CompareAndSwap(nil, key, nil, []byte("cas")); err != nil {
+				t.Errorf("CompareAndSwap failed: %v", err)
+			}
+		})
+		close(start)
+		wg.Wait()
+
+		if v, err := db.Get(nil, key); err != nil || string(v) != "put" {
+			t.Fatalf("round %d: Get = %q, %v; want the batch's value", round, v, err)
+		}
+	}
+}
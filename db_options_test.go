@@ -9,7 +9,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+
+	"github.com/aalhour/rockyardkv/vfs"
 )
 
 // =============================================================================
@@ -302,6 +308,215 @@ func TestOptionsParanoidChecks(t *testing.T) {
 	}
 }
 
+func TestOptionsParanoidChecksMissingSST(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	db.Close()
+
+	ssts := listSSTFiles(t, dir)
+	if len(ssts) != 1 {
+		t.Fatalf("SST files = %v, want exactly one", ssts)
+	}
+	if err := os.Remove(filepath.Join(dir, ssts[0])); err != nil {
+		t.Fatalf("Remove error: %v", err)
+	}
+
+	opts.CreateIfMissing = false
+	db, err = Open(dir, opts)
+	if err == nil {
+		db.Close()
+		t.Fatal("Open should fail when a MANIFEST-referenced SST is missing")
+	}
+	if !errors.Is(err, ErrCorruption) {
+		t.Errorf("Open error = %v, want ErrCorruption", err)
+	}
+	if !strings.Contains(err.Error(), ssts[0]) {
+		t.Errorf("Open error %q should name missing file %s", err, ssts[0])
+	}
+}
+
+// =============================================================================
+// MaxOpenFiles Tests
+// =============================================================================
+
+// fdCountingFS tracks how many SST files are open for random access.
+type fdCountingFS struct {
+	vfs.FS
+	open    atomic.Int64
+	maxOpen atomic.Int64
+	opens   atomic.Int64
+}
+
+func (fs *fdCountingFS) OpenRandomAccess(name string) (vfs.RandomAccessFile, error) {
+	f, err := fs.FS.OpenRandomAccess(name)
+	if err != nil || !strings.HasSuffix(name, ".sst") {
+		return f, err
+	}
+	fs.opens.Add(1)
+	n := fs.open.Add(1)
+	for {
+		peak := fs.maxOpen.Load()
+		if n <= peak || fs.maxOpen.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	return &fdCountingFile{RandomAccessFile: f, fs: fs}, nil
+}
+
+type fdCountingFile struct {
+	vfs.RandomAccessFile
+	fs     *fdCountingFS
+	closed bool
+}
+
+func (f *fdCountingFile) Close() error {
+	if !f.closed {
+		f.closed = true
+		f.fs.open.Add(-1)
+	}
+	return f.RandomAccessFile.Close()
+}
+
+func TestOptionsMaxOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 1000
+	opts.Level0SlowdownWritesTrigger = 1000
+	opts.Level0StopWritesTrigger = 1000
+
+	const numFiles = 20
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	// Skip the WAL so that after reopening, every read is served from SSTs.
+	wo := DefaultWriteOptions()
+	wo.DisableWAL = true
+	for i := range numFiles {
+		key := fmt.Sprintf("key%03d", i)
+		if err := db.Put(wo, []byte(key), []byte("value_"+key)); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush error: %v", err)
+		}
+	}
+	db.Close()
+
+	fs := &fdCountingFS{FS: vfs.Default()}
+	opts.FS = fs
+	opts.MaxOpenFiles = 5
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer db.Close()
+
+	// Read every key twice so evicted readers must be reopened.
+	for range 2 {
+		for i := range numFiles {
+			key := fmt.Sprintf("key%03d", i)
+			got, err := db.Get(nil, []byte(key))
+			if err != nil {
+				t.Fatalf("Get(%s) error: %v", key, err)
+			}
+			if string(got) != "value_"+key {
+				t.Fatalf("Get(%s) = %q, want %q", key, got, "value_"+key)
+			}
+		}
+	}
+
+	if peak := fs.maxOpen.Load(); peak > int64(opts.MaxOpenFiles) {
+		t.Errorf("peak open SST files = %d, want <= %d", peak, opts.MaxOpenFiles)
+	}
+	if open := fs.open.Load(); open > int64(opts.MaxOpenFiles) {
+		t.Errorf("open SST files = %d, want <= %d", open, opts.MaxOpenFiles)
+	}
+	if opens := fs.opens.Load(); opens <= numFiles {
+		t.Errorf("SST opens = %d, want > %d (evicted readers reopened)", opens, numFiles)
+	}
+}
+
+// =============================================================================
+// PinTopLevelIndexAndFilter Tests
+// =============================================================================
+
+func TestOptionsPinTopLevelIndexAndFilter(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Level0FileNumCompactionTrigger = 1000
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	// Skip the WAL so that after reopening, reads are served from SSTs.
+	wo := DefaultWriteOptions()
+	wo.DisableWAL = true
+	for i := range 4 {
+		key := fmt.Sprintf("key%d", i)
+		if err := db.Put(wo, []byte(key), []byte("value")); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush error: %v", err)
+		}
+	}
+	db.Close()
+
+	SetPerfLevel(PerfLevelEnableCount)
+	defer SetPerfLevel(PerfLevelDisable)
+
+	firstGetIndexReads := func(pin bool) uint64 {
+		t.Helper()
+		opts.PinTopLevelIndexAndFilter = pin
+		db, err := Open(dir, opts)
+		if err != nil {
+			t.Fatalf("reopen error: %v", err)
+		}
+		defer db.Close()
+
+		ResetPerfContext()
+		got, err := db.Get(nil, []byte("key0"))
+		if err != nil || string(got) != "value" {
+			t.Fatalf("Get(key0) = %q, %v; want value", got, err)
+		}
+		return GetPerfContext().IndexBlockReadCount
+	}
+
+	if reads := firstGetIndexReads(false); reads == 0 {
+		t.Errorf("without warming, first Get read %d index blocks, want > 0", reads)
+	}
+	if reads := firstGetIndexReads(true); reads != 0 {
+		t.Errorf("with warming, first Get read %d index blocks, want 0", reads)
+	}
+
+	// Warming stops at the table cache's capacity
+	opts.MaxOpenFiles = 2
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer db.Close()
+	if n := db.(*dbImpl).tableCache.Size(); n > 2 {
+		t.Errorf("warmed %d table readers, want at most MaxOpenFiles=2", n)
+	}
+}
+
 // =============================================================================
 // VerifyChecksums Tests
 // =============================================================================
@@ -9,6 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/table"
 )
 
 func TestDeleteRangeBasic(t *testing.T) {
@@ -462,6 +465,92 @@ func TestDeleteRangeCF(t *testing.T) {
 	}
 }
 
+func TestWriteBatchDeleteRangeAtomic(t *testing.T) {
+	want := map[string]string{
+		"key00": "old",
+		"key01": "old",
+		"key05": "new",
+		"key07": "old",
+		"key09": "old",
+		"key10": "new",
+	}
+	verify := func(t *testing.T, db DB) {
+		t.Helper()
+		for i := range 11 {
+			key := fmt.Sprintf("key%02d", i)
+			val, err := db.Get(nil, []byte(key))
+			if v, ok := want[key]; ok {
+				if err != nil || string(val) != v {
+					t.Errorf("Get(%s) = %q, %v, want %q", key, val, err, v)
+				}
+			} else if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(%s) = %q, %v, want ErrNotFound", key, val, err)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		flush bool
+	}{
+		{"WALReplay", false},
+		{"Flushed", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+
+			db, err := Open(dir, opts)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			for i := range 10 {
+				if err := db.Put(nil, fmt.Appendf(nil, "key%02d", i), []byte("old")); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+			}
+			snap := db.GetSnapshot()
+
+			// Entries apply in batch order: the range tombstone covers the
+			// Put before it but not the one after it
+			wb := NewWriteBatch()
+			wb.Put([]byte("key04"), []byte("covered"))
+			wb.DeleteRange([]byte("key02"), []byte("key07"))
+			wb.Put([]byte("key05"), []byte("new"))
+			wb.Delete([]byte("key08"))
+			wb.Put([]byte("key10"), []byte("new"))
+			if err := db.Write(&WriteOptions{Sync: true}, wb); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			verify(t, db)
+
+			// A snapshot taken before the batch sees none of it
+			for i := range 10 {
+				key := fmt.Appendf(nil, "key%02d", i)
+				if val, err := db.Get(&ReadOptions{Snapshot: snap}, key); err != nil || string(val) != "old" {
+					t.Errorf("Get(%s) at snapshot = %q, %v, want \"old\"", key, val, err)
+				}
+			}
+			db.ReleaseSnapshot(snap)
+
+			if tc.flush {
+				if err := db.Flush(nil); err != nil {
+					t.Fatalf("Flush failed: %v", err)
+				}
+			}
+			db.Close()
+
+			db, err = Open(dir, opts)
+			if err != nil {
+				t.Fatalf("Failed to reopen database: %v", err)
+			}
+			defer db.Close()
+			verify(t, db)
+		})
+	}
+}
+
 func TestDeleteRangeFileGeneration(t *testing.T) {
 	dir := t.TempDir()
 
@@ -1045,3 +1134,127 @@ func TestDeleteRangeIteratorCrossSST(t *testing.T) {
 		t.Errorf("After reopen: Got %d keys, want %d: %v", len(keysAfterReopen), len(expectedKeys), keysAfterReopen)
 	}
 }
+
+// checkRangeDelMetaBlock opens an SST file and verifies that its range
+// tombstones live in the "rocksdb.range_del" meta-block rather than inline
+// with point data, returning the number of tombstones it holds.
+func checkRangeDelMetaBlock(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open SST: %v", err)
+	}
+	defer file.Close()
+
+	stat, _ := file.Stat()
+	reader, err := table.Open(&compatFileWrapper{f: file, size: stat.Size()}, table.ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+
+	found := false
+	for _, name := range reader.MetaBlockNames() {
+		if name == "rocksdb.range_del" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("%s: meta blocks %v, missing rocksdb.range_del", filepath.Base(path), reader.MetaBlockNames())
+	}
+
+	iter := reader.NewIterator()
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		if dbformat.ExtractValueType(iter.Key()) == dbformat.TypeRangeDeletion {
+			t.Errorf("%s: range deletion stored inline at %q", filepath.Base(path), iter.Key())
+		}
+	}
+
+	tombstones, err := reader.GetRangeTombstoneList()
+	if err != nil {
+		t.Fatalf("GetRangeTombstoneList failed: %v", err)
+	}
+	props, err := reader.Properties()
+	if err != nil {
+		t.Fatalf("Properties failed: %v", err)
+	}
+	if props.NumRangeDeletions != uint64(tombstones.Len()) {
+		t.Errorf("%s: NumRangeDeletions = %d, want %d", filepath.Base(path), props.NumRangeDeletions, tombstones.Len())
+	}
+	return tombstones.Len()
+}
+
+// TestDeleteRangeStoredInMetaBlock tests that range tombstones are written to
+// the range deletion meta-block on flush and carried through compaction.
+func TestDeleteRangeStoredInMetaBlock(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	writeOpts := &WriteOptions{Sync: false}
+	for i := range 10 {
+		if err := db.Put(writeOpts, fmt.Appendf(nil, "k%02d", i), []byte("v")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// The tombstone is wider than the data and is the only entry in its memtable
+	if err := db.DeleteRange(writeOpts, []byte("a"), []byte("z")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	tombstoneFiles := 0
+	for _, f := range db.GetLiveFilesMetaData() {
+		if !bytes.Equal(dbformat.ExtractUserKey(f.SmallestKey), []byte("a")) {
+			continue
+		}
+		tombstoneFiles++
+		if largest := dbformat.ExtractUserKey(f.LargestKey); !bytes.Equal(largest, []byte("z")) {
+			t.Errorf("tombstone file largest key = %q, want %q", largest, "z")
+		}
+		if n := checkRangeDelMetaBlock(t, filepath.Join(dir, f.Name)); n != 1 {
+			t.Errorf("flushed file holds %d tombstones, want 1", n)
+		}
+	}
+	if tombstoneFiles != 1 {
+		t.Fatalf("found %d files bounded by the tombstone, want 1", tombstoneFiles)
+	}
+
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	live := db.GetLiveFilesMetaData()
+	if len(live) != 1 {
+		t.Fatalf("expected 1 live file after compaction, got %d", len(live))
+	}
+	if n := checkRangeDelMetaBlock(t, filepath.Join(dir, live[0].Name)); n != 1 {
+		t.Errorf("compacted file holds %d tombstones, want 1", n)
+	}
+	db.Close()
+
+	db, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to reopen: %v", err)
+	}
+	defer db.Close()
+
+	for i := range 10 {
+		key := fmt.Appendf(nil, "k%02d", i)
+		if _, err := db.Get(nil, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) = %v, want ErrNotFound", key, err)
+		}
+	}
+}
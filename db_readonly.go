@@ -9,8 +9,8 @@ package rockyardkv
 import (
 	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/aalhour/rockyardkv/internal/blob"
 	"github.com/aalhour/rockyardkv/internal/logging"
 	"github.com/aalhour/rockyardkv/internal/table"
 	"github.com/aalhour/rockyardkv/internal/version"
@@ -20,6 +20,10 @@ import (
 // ErrReadOnly is returned when attempting a write operation on a read-only database.
 var ErrReadOnly = errors.New("db: database is opened in read-only mode")
 
+// ErrWALFileExists is returned by OpenForReadOnly when errorIfWALExists is set
+// and the database has WAL files holding un-flushed writes.
+var ErrWALFileExists = errors.New("db: WAL file with un-flushed data exists")
+
 // dbImplReadOnly is a read-only view of the database.
 // It wraps dbImpl and disables all write operations.
 type dbImplReadOnly struct {
@@ -27,8 +31,13 @@ type dbImplReadOnly struct {
 }
 
 // OpenForReadOnly opens a database in read-only mode.
-// If errorIfWALExists is true, an error is returned if there are WAL files
-// that would need to be replayed (indicating unclean shutdown).
+//
+// If errorIfWALExists is true, opening fails with ErrWALFileExists when a WAL
+// holds writes not yet flushed to SST files, so that a successful open
+// guarantees a view made only of durable SST files. Otherwise those WALs are
+// replayed into a read-only memtable and their writes are visible, except
+// those of two-phase commit transactions not committed. WAL files are never
+// modified either way.
 func OpenForReadOnly(path string, opts *Options, errorIfWALExists bool) (ReadOnlyDB, error) {
 	if opts == nil {
 		opts = DefaultOptions()
@@ -39,25 +48,17 @@ func OpenForReadOnly(path string, opts *Options, errorIfWALExists bool) (ReadOnl
 		fs = vfs.Default()
 	}
 
+	// Use default env if not specified
+	env := opts.Env
+	if env == nil {
+		env = DefaultEnv()
+	}
+
 	// Verify the database directory exists
 	if !fs.Exists(path) {
 		return nil, fmt.Errorf("db: database at %q does not exist", path)
 	}
 
-	// Check for WAL files if requested
-	if errorIfWALExists {
-		// List files and check for .log files
-		files, err := fs.ListDir(path)
-		if err != nil {
-			return nil, fmt.Errorf("db: failed to list directory: %w", err)
-		}
-		for _, f := range files {
-			if strings.HasSuffix(f, ".log") {
-				return nil, fmt.Errorf("db: WAL files exist, database was not cleanly shut down")
-			}
-		}
-	}
-
 	// Setup comparator
 	cmp := opts.Comparator
 	if cmp == nil {
@@ -73,11 +74,13 @@ func OpenForReadOnly(path string, opts *Options, errorIfWALExists bool) (ReadOnl
 		name:            path,
 		options:         opts,
 		fs:              fs,
+		env:             env,
 		comparator:      cmp,
 		cmp:             cmp,
 		shutdownCh:      make(chan struct{}),
-		tableCache:      table.NewTableCache(fs, table.DefaultTableCacheOptions()),
-		writeController: newWriteController(),
+		tableCache:      table.NewTableCache(fs, tableCacheOptions(opts)),
+		blobCache:       blob.NewCache(fs, path, blobCacheOptions(opts)),
+		writeController: newWriteController(env),
 		logger:          logger,
 	}
 
@@ -97,19 +100,31 @@ func OpenForReadOnly(path string, opts *Options, errorIfWALExists bool) (ReadOnl
 		DBName:              path,
 		FS:                  fs,
 		MaxManifestFileSize: 1024 * 1024 * 1024, // 1GB
-		NumLevels:           version.MaxNumLevels,
+		NumLevels:           opts.NumLevels,
 		Logger:              db.logger, // Pass through for MANIFEST logging
 	}
 	db.versions = version.NewVersionSet(vsOpts)
 
-	// Recover from existing database (read-only - no WAL replay)
+	// Recover from existing database
 	if err := db.versions.Recover(); err != nil {
 		_ = db.tableCache.Close()
 		return nil, fmt.Errorf("db: failed to recover: %w", err)
 	}
 
-	// Set sequence number to max for reads
-	db.seq = ^uint64(0) >> 1 // MaxSequenceNumber
+	// Replay un-flushed WAL records into a read-only memtable. The WAL is not
+	// rotated on flush, so it may also hold records the MANIFEST already
+	// covers; only records past LastSequence are un-flushed.
+	mem, maxSeq, err := db.replayWALReadOnly()
+	if err != nil {
+		_ = db.tableCache.Close()
+		return nil, fmt.Errorf("db: failed to replay WAL: %w", err)
+	}
+	if errorIfWALExists && maxSeq > db.versions.LastSequence() {
+		_ = db.tableCache.Close()
+		return nil, ErrWALFileExists
+	}
+	db.mem = mem
+	db.seq = maxSeq
 
 	// Return the read-only wrapper
 	return &dbImplReadOnly{dbImpl: db}, nil
@@ -120,6 +135,11 @@ func (db *dbImplReadOnly) Put(opts *WriteOptions, key, value []byte) error {
 	return ErrReadOnly
 }
 
+// PutEntity is not supported in read-only mode.
+func (db *dbImplReadOnly) PutEntity(opts *WriteOptions, cf ColumnFamilyHandle, key []byte, columns []WideColumn) error {
+	return ErrReadOnly
+}
+
 // Delete is not supported in read-only mode.
 func (db *dbImplReadOnly) Delete(opts *WriteOptions, key []byte) error {
 	return ErrReadOnly
@@ -145,11 +165,21 @@ func (db *dbImplReadOnly) Write(opts *WriteOptions, b *WriteBatch) error {
 	return ErrReadOnly
 }
 
+// CompareAndSwap is not supported in read-only mode.
+func (db *dbImplReadOnly) CompareAndSwap(opts *WriteOptions, key, expected, newValue []byte) (bool, error) {
+	return false, ErrReadOnly
+}
+
 // Flush is not supported in read-only mode.
 func (db *dbImplReadOnly) Flush(opts *FlushOptions) error {
 	return ErrReadOnly
 }
 
+// FlushAndWaitForL0 is not supported in read-only mode.
+func (db *dbImplReadOnly) FlushAndWaitForL0(cf ColumnFamilyHandle) error {
+	return ErrReadOnly
+}
+
 // CompactRange is not supported in read-only mode.
 func (db *dbImplReadOnly) CompactRange(opts *CompactRangeOptions, start, end []byte) error {
 	return ErrReadOnly
@@ -160,6 +190,11 @@ func (db *dbImplReadOnly) CreateColumnFamily(opts ColumnFamilyOptions, name stri
 	return nil, ErrReadOnly
 }
 
+// CreateColumnFamilyWithImport is not supported in read-only mode.
+func (db *dbImplReadOnly) CreateColumnFamilyWithImport(opts ColumnFamilyOptions, name string, importOpts ImportColumnFamilyOptions, metadata *ExportImportFilesMetaData) (ColumnFamilyHandle, error) {
+	return nil, ErrReadOnly
+}
+
 // DropColumnFamily is not supported in read-only mode.
 func (db *dbImplReadOnly) DropColumnFamily(handle ColumnFamilyHandle) error {
 	return ErrReadOnly
@@ -190,6 +225,11 @@ func (db *dbImplReadOnly) IngestExternalFile(paths []string, opts IngestExternal
 	return ErrReadOnly
 }
 
+// IngestExternalFiles is not supported in read-only mode.
+func (db *dbImplReadOnly) IngestExternalFiles(args []IngestExternalFileArg) error {
+	return ErrReadOnly
+}
+
 // SyncWAL is not supported in read-only mode.
 func (db *dbImplReadOnly) SyncWAL() error {
 	return ErrReadOnly
@@ -202,10 +242,8 @@ func (db *dbImplReadOnly) FlushWAL(sync bool) error {
 
 // GetLatestSequenceNumber returns the sequence number of the most recent transaction.
 func (db *dbImplReadOnly) GetLatestSequenceNumber() uint64 {
-	if db.versions == nil {
-		return 0
-	}
-	return db.versions.LastSequence()
+	// Includes records replayed from the WAL
+	return db.seq
 }
 
 // GetLiveFiles returns a list of all files in the database.
@@ -227,6 +265,15 @@ func (db *dbImplReadOnly) GetLiveFilesMetaData() []LiveFileMetaData {
 	return db.dbImpl.GetLiveFilesMetaData()
 }
 
+// GetLiveFilesStorageInfo returns the files needed to copy the database.
+// The memtable is never flushed in read-only mode.
+func (db *dbImplReadOnly) GetLiveFilesStorageInfo(opts LiveFilesStorageInfoOptions) ([]LiveFileStorageInfo, error) {
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+	return db.dbImpl.getLiveFilesStorageInfo(opts, false)
+}
+
 // DisableFileDeletions is a no-op in read-only mode.
 func (db *dbImplReadOnly) DisableFileDeletions() error {
 	return nil
@@ -267,6 +314,9 @@ func (db *dbImplReadOnly) Close() error {
 	if db.tableCache != nil {
 		_ = db.tableCache.Close()
 	}
+	if db.blobCache != nil {
+		_ = db.blobCache.Close()
+	}
 
 	return nil
 }
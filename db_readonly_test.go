@@ -4,7 +4,6 @@ package rockyardkv
 
 import (
 	"errors"
-	"os"
 	"slices"
 	"testing"
 )
@@ -164,7 +163,7 @@ func TestOpenForReadOnlyNonExistent(t *testing.T) {
 	}
 }
 
-// TestOpenForReadOnlyWithWAL tests errorIfWALExists flag.
+// TestOpenForReadOnlyWithWAL tests both branches of the errorIfWALExists flag.
 func TestOpenForReadOnlyWithWAL(t *testing.T) {
 	dir := t.TempDir()
 
@@ -177,39 +176,92 @@ func TestOpenForReadOnlyWithWAL(t *testing.T) {
 		t.Fatalf("Failed to open db: %v", err)
 	}
 
+	if err := db1.Put(nil, []byte("flushed"), []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := db1.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
 	// Write data but DON'T flush (leaves data in WAL)
 	if err := db1.Put(nil, []byte("key"), []byte("value")); err != nil {
 		t.Fatalf("Put failed: %v", err)
 	}
+	if err := db1.Delete(nil, []byte("flushed")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	wantSeq := db1.GetLatestSequenceNumber()
 
 	// Close without flush - this should leave WAL files
 	if err := db1.Close(); err != nil {
 		t.Fatalf("Close failed: %v", err)
 	}
 
-	// Check if WAL exists
-	files, _ := os.ReadDir(dir)
-	hasWAL := false
-	for _, f := range files {
-		if len(f.Name()) > 4 && f.Name()[len(f.Name())-4:] == ".log" {
-			hasWAL = true
-			break
-		}
+	// Opening with errorIfWALExists=true should fail
+	if _, err := OpenForReadOnly(dir, opts, true); !errors.Is(err, ErrWALFileExists) {
+		t.Fatalf("OpenForReadOnly with errorIfWALExists=true: expected ErrWALFileExists, got %v", err)
 	}
 
-	if hasWAL {
-		// If WAL exists, opening with errorIfWALExists=true should fail
-		_, err := OpenForReadOnly(dir, opts, true)
-		if err == nil {
-			t.Error("Expected error when WAL exists and errorIfWALExists=true")
-		}
+	// Opening with errorIfWALExists=false should replay the WAL
+	roDB, err := OpenForReadOnly(dir, opts, false)
+	if err != nil {
+		t.Fatalf("OpenForReadOnly with errorIfWALExists=false failed: %v", err)
+	}
+	defer roDB.Close()
 
-		// But opening with errorIfWALExists=false should succeed
-		roDB, err := OpenForReadOnly(dir, opts, false)
-		if err != nil {
-			t.Fatalf("OpenForReadOnly with errorIfWALExists=false failed: %v", err)
-		}
-		roDB.Close()
+	val, err := roDB.Get(nil, []byte("key"))
+	if err != nil || string(val) != "value" {
+		t.Errorf("Get key = %q, %v; want %q", val, err, "value")
+	}
+	if _, err := roDB.Get(nil, []byte("flushed")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get flushed: expected ErrNotFound after WAL delete, got %v", err)
+	}
+	if got := roDB.GetLatestSequenceNumber(); got != wantSeq {
+		t.Errorf("GetLatestSequenceNumber = %d, want %d", got, wantSeq)
+	}
+
+	iter := roDB.NewIterator(nil)
+	defer iter.Close()
+	var keys []string
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	if len(keys) != 1 || keys[0] != "key" {
+		t.Errorf("iterator keys = %v, want [key]", keys)
+	}
+}
+
+// TestOpenForReadOnlyErrorIfWALExistsAfterFlush tests that a WAL holding no
+// un-flushed data does not fail errorIfWALExists.
+func TestOpenForReadOnlyErrorIfWALExistsAfterFlush(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db1, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	if err := db1.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := db1.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := db1.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	roDB, err := OpenForReadOnly(dir, opts, true)
+	if err != nil {
+		t.Fatalf("OpenForReadOnly with errorIfWALExists=true failed: %v", err)
+	}
+	defer roDB.Close()
+
+	val, err := roDB.Get(nil, []byte("key"))
+	if err != nil || string(val) != "value" {
+		t.Errorf("Get key = %q, %v; want %q", val, err, "value")
 	}
 }
 
@@ -12,7 +12,9 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/aalhour/rockyardkv/internal/blob"
 	"github.com/aalhour/rockyardkv/internal/logging"
 	"github.com/aalhour/rockyardkv/internal/table"
 	"github.com/aalhour/rockyardkv/internal/version"
@@ -32,12 +34,25 @@ type dbImplSecondary struct {
 
 	// Mutex for catching up
 	catchupMu sync.Mutex
+
+	// Auto-follow state, guarded by followMu
+	followMu     sync.Mutex
+	followStopCh chan struct{}
+	followDoneCh chan struct{}
+
+	// followErrCh receives errors from background catch-ups
+	followErrCh chan error
 }
 
+// followErrChanSize bounds how many background catch-up errors are buffered
+// before further errors are dropped.
+const followErrChanSize = 16
+
 // OpenAsSecondary opens a database as a secondary instance.
 // The secondary can read data from the primary but cannot write.
 // primaryPath is the path to the primary database directory.
 // secondaryPath is an optional path for the secondary's local state.
+// Writes of two-phase commit transactions show once they are committed.
 func OpenAsSecondary(primaryPath, secondaryPath string, opts *Options) (SecondaryDB, error) {
 	if opts == nil {
 		opts = DefaultOptions()
@@ -48,6 +63,12 @@ func OpenAsSecondary(primaryPath, secondaryPath string, opts *Options) (Secondar
 		fs = vfs.Default()
 	}
 
+	// Use default env if not specified
+	env := opts.Env
+	if env == nil {
+		env = DefaultEnv()
+	}
+
 	// Verify the primary database exists
 	if !fs.Exists(primaryPath) {
 		return nil, fmt.Errorf("db: primary database at %q does not exist", primaryPath)
@@ -68,11 +89,13 @@ func OpenAsSecondary(primaryPath, secondaryPath string, opts *Options) (Secondar
 		name:            primaryPath,
 		options:         opts,
 		fs:              fs,
+		env:             env,
 		comparator:      cmp,
 		cmp:             cmp,
 		shutdownCh:      make(chan struct{}),
-		tableCache:      table.NewTableCache(fs, table.DefaultTableCacheOptions()),
-		writeController: newWriteController(),
+		tableCache:      table.NewTableCache(fs, tableCacheOptions(opts)),
+		blobCache:       blob.NewCache(fs, primaryPath, blobCacheOptions(opts)),
+		writeController: newWriteController(env),
 		logger:          logger,
 	}
 
@@ -92,7 +115,7 @@ func OpenAsSecondary(primaryPath, secondaryPath string, opts *Options) (Secondar
 		DBName:              primaryPath,
 		FS:                  fs,
 		MaxManifestFileSize: 1024 * 1024 * 1024,
-		NumLevels:           version.MaxNumLevels,
+		NumLevels:           opts.NumLevels,
 		Logger:              db.logger, // Pass through for MANIFEST logging
 	}
 	db.versions = version.NewVersionSet(vsOpts)
@@ -111,7 +134,7 @@ func OpenAsSecondary(primaryPath, secondaryPath string, opts *Options) (Secondar
 		id:      DefaultColumnFamilyID,
 		name:    DefaultColumnFamilyName,
 		options: DefaultColumnFamilyOptions(),
-		// No memtable for secondary - reads go directly to SST files
+		// Un-flushed data is read from db.mem, the shadow memtable filled by tailWAL
 	}
 	db.columnFamilies.byID[DefaultColumnFamilyID] = defaultCF
 	db.columnFamilies.byName[DefaultColumnFamilyName] = defaultCF
@@ -120,13 +143,21 @@ func OpenAsSecondary(primaryPath, secondaryPath string, opts *Options) (Secondar
 		dbImpl:        db,
 		primaryPath:   primaryPath,
 		secondaryPath: secondaryPath,
+		followErrCh:   make(chan error, followErrChanSize),
+	}
+
+	// Tail the primary's live WALs so un-flushed writes are visible
+	if err := secondary.tailWAL(); err != nil {
+		_ = db.tableCache.Close()
+		return nil, fmt.Errorf("db: failed to tail WAL: %w", err)
 	}
 
 	return secondary, nil
 }
 
 // TryCatchUpWithPrimary attempts to catch up with the primary database.
-// It reads new records from the MANIFEST and applies them.
+// It reads new records from the MANIFEST and applies them, then tails the
+// primary's live WALs into a shadow memtable so un-flushed writes are visible.
 func (db *dbImplSecondary) TryCatchUpWithPrimary() error {
 	db.catchupMu.Lock()
 	defer db.catchupMu.Unlock()
@@ -138,17 +169,122 @@ func (db *dbImplSecondary) TryCatchUpWithPrimary() error {
 		return fmt.Errorf("db: failed to catch up: %w", err)
 	}
 
-	// Update the sequence number from the recovered version
-	db.seq = db.versions.LastSequence()
+	if err := db.tailWAL(); err != nil {
+		return fmt.Errorf("db: failed to catch up: %w", err)
+	}
+
+	return nil
+}
+
+// tailWAL rebuilds the secondary's read-only shadow memtable from the
+// primary's WAL files that the MANIFEST does not yet cover, and advances the
+// visible sequence number past the last record read.
+//
+// The primary may be appending to its active WAL concurrently, so a torn or
+// partially written record at the tail ends the replay of that file instead
+// of failing the catch-up; the rest is picked up by the next catch-up.
+//
+// Like the MANIFEST catch-up this re-reads the live WALs from the start
+// rather than from the last read position.
+//
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_secondary.cc
+// (DBImplSecondary::FindAndRecoverLogFiles, RecoverLogFiles)
+func (db *dbImplSecondary) tailWAL() error {
+	mem, maxSeq, err := db.replayWALReadOnly()
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	db.mem = mem
+	db.seq = maxSeq
+	db.mu.Unlock()
+
+	return nil
+}
+
+// StartFollow starts a background goroutine that calls TryCatchUpWithPrimary
+// every interval, so the secondary stays fresh without caller polling.
+// Catch-up errors do not stop following; they are delivered on FollowErrors.
+// It returns an error if the instance is already following or closed.
+func (db *dbImplSecondary) StartFollow(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("db: follow interval must be positive, got %v", interval)
+	}
+
+	db.followMu.Lock()
+	defer db.followMu.Unlock()
+
+	if db.closed {
+		return ErrDBClosed
+	}
+	if db.followStopCh != nil {
+		return fmt.Errorf("db: secondary is already following the primary")
+	}
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	db.followStopCh = stopCh
+	db.followDoneCh = doneCh
 
+	go db.followLoop(interval, stopCh, doneCh)
 	return nil
 }
 
+// StopFollow stops the background goroutine started by StartFollow and waits
+// for it to exit. It is a no-op if the instance is not following.
+func (db *dbImplSecondary) StopFollow() {
+	db.followMu.Lock()
+	stopCh, doneCh := db.followStopCh, db.followDoneCh
+	db.followStopCh, db.followDoneCh = nil, nil
+	db.followMu.Unlock()
+
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	<-doneCh
+}
+
+// FollowErrors returns the channel on which background catch-up errors are
+// delivered. Errors are dropped when the channel's buffer is full.
+func (db *dbImplSecondary) FollowErrors() <-chan error {
+	return db.followErrCh
+}
+
+// followLoop periodically catches up with the primary until stopCh is closed.
+func (db *dbImplSecondary) followLoop(interval time.Duration, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := db.TryCatchUpWithPrimary(); err != nil {
+				db.logger.Warnf("[secondary] auto-follow catch-up failed: %v", err)
+				select {
+				case db.followErrCh <- err:
+				default:
+				}
+			}
+		}
+	}
+}
+
 // Put is not supported in secondary mode.
 func (db *dbImplSecondary) Put(opts *WriteOptions, key, value []byte) error {
 	return ErrReadOnly
 }
 
+// PutEntity is not supported in secondary mode.
+func (db *dbImplSecondary) PutEntity(opts *WriteOptions, cf ColumnFamilyHandle, key []byte, columns []WideColumn) error {
+	return ErrReadOnly
+}
+
 // Delete is not supported in secondary mode.
 func (db *dbImplSecondary) Delete(opts *WriteOptions, key []byte) error {
 	return ErrReadOnly
@@ -174,11 +310,21 @@ func (db *dbImplSecondary) Write(opts *WriteOptions, b *WriteBatch) error {
 	return ErrReadOnly
 }
 
+// CompareAndSwap is not supported in secondary mode.
+func (db *dbImplSecondary) CompareAndSwap(opts *WriteOptions, key, expected, newValue []byte) (bool, error) {
+	return false, ErrReadOnly
+}
+
 // Flush is not supported in secondary mode.
 func (db *dbImplSecondary) Flush(opts *FlushOptions) error {
 	return ErrReadOnly
 }
 
+// FlushAndWaitForL0 is not supported in secondary mode.
+func (db *dbImplSecondary) FlushAndWaitForL0(cf ColumnFamilyHandle) error {
+	return ErrReadOnly
+}
+
 // CompactRange is not supported in secondary mode.
 func (db *dbImplSecondary) CompactRange(opts *CompactRangeOptions, start, end []byte) error {
 	return ErrReadOnly
@@ -189,6 +335,11 @@ func (db *dbImplSecondary) CreateColumnFamily(opts ColumnFamilyOptions, name str
 	return nil, ErrReadOnly
 }
 
+// CreateColumnFamilyWithImport is not supported in secondary mode.
+func (db *dbImplSecondary) CreateColumnFamilyWithImport(opts ColumnFamilyOptions, name string, importOpts ImportColumnFamilyOptions, metadata *ExportImportFilesMetaData) (ColumnFamilyHandle, error) {
+	return nil, ErrReadOnly
+}
+
 // DropColumnFamily is not supported in secondary mode.
 func (db *dbImplSecondary) DropColumnFamily(handle ColumnFamilyHandle) error {
 	return ErrReadOnly
@@ -219,6 +370,11 @@ func (db *dbImplSecondary) IngestExternalFile(paths []string, opts IngestExterna
 	return ErrReadOnly
 }
 
+// IngestExternalFiles is not supported in secondary mode.
+func (db *dbImplSecondary) IngestExternalFiles(args []IngestExternalFileArg) error {
+	return ErrReadOnly
+}
+
 // SyncWAL is not supported in secondary mode.
 func (db *dbImplSecondary) SyncWAL() error {
 	return ErrReadOnly
@@ -231,10 +387,10 @@ func (db *dbImplSecondary) FlushWAL(sync bool) error {
 
 // GetLatestSequenceNumber returns the sequence number of the most recent transaction.
 func (db *dbImplSecondary) GetLatestSequenceNumber() uint64 {
-	if db.versions == nil {
-		return 0
-	}
-	return db.versions.LastSequence()
+	// Includes records tailed from the primary's WAL
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.seq
 }
 
 // GetLiveFiles returns a list of all files in the database.
@@ -256,6 +412,15 @@ func (db *dbImplSecondary) GetLiveFilesMetaData() []LiveFileMetaData {
 	return db.dbImpl.GetLiveFilesMetaData()
 }
 
+// GetLiveFilesStorageInfo returns the files needed to copy the database.
+// The memtable is never flushed in secondary mode.
+func (db *dbImplSecondary) GetLiveFilesStorageInfo(opts LiveFilesStorageInfoOptions) ([]LiveFileStorageInfo, error) {
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+	return db.dbImpl.getLiveFilesStorageInfo(opts, false)
+}
+
 // DisableFileDeletions is a no-op in secondary mode.
 func (db *dbImplSecondary) DisableFileDeletions() error {
 	return nil
@@ -288,6 +453,8 @@ func (db *dbImplSecondary) NewCheckpoint() *Checkpoint {
 
 // Close closes the secondary database.
 func (db *dbImplSecondary) Close() error {
+	db.StopFollow()
+
 	if db.closed {
 		return ErrDBClosed
 	}
@@ -296,6 +463,9 @@ func (db *dbImplSecondary) Close() error {
 	if db.tableCache != nil {
 		_ = db.tableCache.Close()
 	}
+	if db.blobCache != nil {
+		_ = db.blobCache.Close()
+	}
 
 	return nil
 }
@@ -5,6 +5,7 @@ package rockyardkv
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 // TestOpenAsSecondaryBasic tests basic secondary instance operations.
@@ -349,3 +350,196 @@ func TestOpenAsSecondaryMultiGet(t *testing.T) {
 		}
 	}
 }
+
+// TestOpenAsSecondaryAutoFollow tests that auto-follow makes primary writes
+// visible on the secondary without explicit catch-up calls.
+func TestOpenAsSecondaryAutoFollow(t *testing.T) {
+	primaryDir := t.TempDir()
+	secondaryDir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	primary, err := Open(primaryDir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	secondary, err := OpenAsSecondary(primaryDir, secondaryDir, opts)
+	if err != nil {
+		t.Fatalf("OpenAsSecondary failed: %v", err)
+	}
+	defer secondary.Close()
+
+	const interval = 20 * time.Millisecond
+	if err := secondary.StartFollow(interval); err != nil {
+		t.Fatalf("StartFollow failed: %v", err)
+	}
+	if err := secondary.StartFollow(interval); err == nil {
+		t.Error("second StartFollow should fail while already following")
+	}
+
+	if err := primary.Put(nil, []byte("key1"), []byte("value1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := primary.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	deadline := time.Now().Add(50 * interval)
+	for {
+		val, err := secondary.Get(nil, []byte("key1"))
+		if err == nil {
+			if string(val) != "value1" {
+				t.Fatalf("key1 value = %q, want %q", val, "value1")
+			}
+			break
+		}
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Secondary Get key1 failed: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("key1 not visible on secondary with auto-follow enabled")
+		}
+		time.Sleep(interval / 4)
+	}
+
+	select {
+	case err := <-secondary.FollowErrors():
+		t.Errorf("unexpected follow error: %v", err)
+	default:
+	}
+
+	// After StopFollow, new writes are not picked up automatically.
+	secondary.StopFollow()
+	secondary.StopFollow()
+
+	if err := primary.Put(nil, []byte("key2"), []byte("value2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := primary.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	time.Sleep(5 * interval)
+	if _, err := secondary.Get(nil, []byte("key2")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get key2 after StopFollow: expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestOpenAsSecondaryAutoFollowStop tests that StopFollow returns without
+// waiting out a long follow interval, and that no catch-up ran before it.
+func TestOpenAsSecondaryAutoFollowStop(t *testing.T) {
+	primaryDir := t.TempDir()
+	secondaryDir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	primary, err := Open(primaryDir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	secondary, err := OpenAsSecondary(primaryDir, secondaryDir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("OpenAsSecondary failed: %v", err)
+	}
+	defer secondary.Close()
+
+	if err := primary.Put(nil, []byte("key1"), []byte("value1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := primary.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := secondary.StartFollow(time.Hour); err != nil {
+		t.Fatalf("StartFollow failed: %v", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		secondary.StopFollow()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StopFollow waited for the follow interval")
+	}
+	if _, err := secondary.Get(nil, []byte("key1")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get key1 before the first interval: expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestOpenAsSecondaryTailsWAL tests that un-flushed primary writes become
+// visible on the secondary after a catch-up by tailing the primary's WAL.
+func TestOpenAsSecondaryTailsWAL(t *testing.T) {
+	primaryDir := t.TempDir()
+	secondaryDir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	primary, err := Open(primaryDir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open primary: %v", err)
+	}
+	defer primary.Close()
+
+	if err := primary.Put(nil, []byte("flushed"), []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := primary.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	// Written to the WAL only; present when the secondary opens.
+	if err := primary.Put(nil, []byte("unflushed1"), []byte("v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	secondary, err := OpenAsSecondary(primaryDir, secondaryDir, opts)
+	if err != nil {
+		t.Fatalf("OpenAsSecondary failed: %v", err)
+	}
+	defer secondary.Close()
+
+	for key, want := range map[string]string{"flushed": "v1", "unflushed1": "v2"} {
+		val, err := secondary.Get(nil, []byte(key))
+		if err != nil || string(val) != want {
+			t.Errorf("Get %s = %q, %v; want %q", key, val, err, want)
+		}
+	}
+
+	// New un-flushed writes, including an overwrite and a delete.
+	if err := primary.Put(nil, []byte("unflushed2"), []byte("v3")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := primary.Put(nil, []byte("flushed"), []byte("v4")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := primary.Delete(nil, []byte("unflushed1")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := secondary.Get(nil, []byte("unflushed2")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get unflushed2 before catch-up: expected ErrNotFound, got %v", err)
+	}
+
+	if err := secondary.TryCatchUpWithPrimary(); err != nil {
+		t.Fatalf("TryCatchUpWithPrimary failed: %v", err)
+	}
+
+	for key, want := range map[string]string{"flushed": "v4", "unflushed2": "v3"} {
+		val, err := secondary.Get(nil, []byte(key))
+		if err != nil || string(val) != want {
+			t.Errorf("Get %s after catch-up = %q, %v; want %q", key, val, err, want)
+		}
+	}
+	if _, err := secondary.Get(nil, []byte("unflushed1")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get unflushed1 after catch-up: expected ErrNotFound, got %v", err)
+	}
+	if got, want := secondary.GetLatestSequenceNumber(), primary.GetLatestSequenceNumber(); got != want {
+		t.Errorf("secondary sequence = %d, want %d", got, want)
+	}
+}
@@ -8,8 +8,11 @@ package rockyardkv
 import (
 	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // =============================================================================
@@ -344,3 +347,95 @@ func TestSnapshotConsistentManyKeys(t *testing.T) {
 		}
 	}
 }
+
+// =============================================================================
+// Snapshot Leak Detection Tests
+// =============================================================================
+
+func TestSnapshotCountAndSequences(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	var snaps []*Snapshot
+	for i := range 3 {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%d", i), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		snaps = append(snaps, db.GetSnapshot())
+		if n := db.GetSnapshotCount(); n != i+1 {
+			t.Errorf("GetSnapshotCount() = %d, want %d", n, i+1)
+		}
+	}
+	oldest := snaps[0].Sequence()
+
+	// Releasing newer snapshots does not advance the oldest sequence
+	db.ReleaseSnapshot(snaps[2])
+	db.ReleaseSnapshot(snaps[1])
+	if err := db.Put(nil, []byte("later"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	seqs := db.GetAliveSnapshotSequences()
+	if len(seqs) != 1 || seqs[0] != oldest {
+		t.Errorf("GetAliveSnapshotSequences() = %v, want [%d]", seqs, oldest)
+	}
+
+	db.ReleaseSnapshot(snaps[0])
+	if n := db.GetSnapshotCount(); n != 0 {
+		t.Errorf("GetSnapshotCount() after release = %d, want 0", n)
+	}
+	if seqs := db.GetAliveSnapshotSequences(); len(seqs) != 0 {
+		t.Errorf("GetAliveSnapshotSequences() after release = %v, want none", seqs)
+	}
+}
+
+func TestDetectSnapshotLeaks(t *testing.T) {
+	logger := &captureLogger{}
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Logger = logger
+	opts.DetectSnapshotLeaks = true
+
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// A released snapshot is not reported
+	db.ReleaseSnapshot(db.GetSnapshot())
+	// A dropped one is, and it keeps pinning its sequence number
+	leakedSeq := db.GetSnapshot().Sequence()
+
+	for range 20 {
+		runtime.GC()
+		if logger.contains("WARN", "garbage collected without ReleaseSnapshot") {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !logger.contains("WARN", fmt.Sprintf("snapshot at sequence %d", leakedSeq)) {
+		t.Errorf("no leak warning, got:\n%s", strings.Join(logger.messages, "\n"))
+	}
+	if n := db.GetSnapshotCount(); n != 1 {
+		t.Errorf("GetSnapshotCount() = %d, want the leaked snapshot", n)
+	}
+
+	runtime.GC()
+	warnings := 0
+	logger.mu.Lock()
+	for _, msg := range logger.messages {
+		if strings.Contains(msg, "without ReleaseSnapshot") {
+			warnings++
+		}
+	}
+	logger.mu.Unlock()
+	if warnings != 1 {
+		t.Errorf("got %d leak warnings, want 1 for the leaked snapshot only", warnings)
+	}
+}
@@ -0,0 +1,163 @@
+package rockyardkv
+
+// destroy.go implements DestroyDB, which removes a database's files.
+//
+// Only files that belong to the database are removed: files named by the
+// database's naming scheme, plus every SST referenced by the MANIFEST that
+// CURRENT points to, plus the WALs in the archive directory. Unrelated files
+// in the directory are left alone, and the directory itself is removed only
+// if nothing else remains in it.
+//
+// Reference: RocksDB v10.7.5
+//   - db/db_impl/db_impl.cc (DestroyDB)
+//   - file/filename.cc (ParseFileName)
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/aalhour/rockyardkv/internal/version"
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+const (
+	// lockFileName is the name of the database lock file.
+	lockFileName = "LOCK"
+
+	// identityFileName is the name of the database identity file.
+	identityFileName = "IDENTITY"
+)
+
+// dbFileRegex matches the fixed-pattern file names a database creates.
+var dbFileRegex = regexp.MustCompile(
+	`^(CURRENT|CURRENT\.tmp|IDENTITY|LOG|LOG\.old\.\d+|MANIFEST-\d+|OPTIONS-\d+(\.dbtmp)?|\d+\.(sst|log|blob|dbtmp))$`)
+
+// walArchiveFileRegex matches the WALs moved into the archive directory.
+var walArchiveFileRegex = regexp.MustCompile(`^\d+\.log$`)
+
+// isDBFileName reports whether name is a file name the database creates.
+func isDBFileName(name string) bool {
+	return name == lockFileName || dbFileRegex.MatchString(name)
+}
+
+// DestroyDB removes the database at path.
+//
+// It refuses with ErrDBLocked if the database is open. Files that do not
+// belong to the database are left in place; the directory is removed only
+// if it is empty afterwards. Destroying a path that does not exist is not
+// an error.
+func DestroyDB(path string, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	fs := opts.FS
+	if fs == nil {
+		fs = vfs.Default()
+	}
+
+	if !fs.Exists(path) {
+		return nil
+	}
+
+	lockPath := filepath.Join(path, lockFileName)
+	lock, err := fs.Lock(lockPath)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrDBLocked, path, err)
+	}
+
+	owned := make(map[string]bool)
+	for _, name := range liveTableNames(path, fs) {
+		owned[name] = true
+	}
+
+	entries, err := fs.ListDir(path)
+	if err != nil {
+		_ = lock.Close()
+		return fmt.Errorf("destroy: failed to list directory: %w", err)
+	}
+
+	var firstErr error
+	for _, name := range entries {
+		if name == walArchiveDir {
+			if err := destroyWALArchive(fs, filepath.Join(path, name)); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if name == lockFileName || (!owned[name] && !isDBFileName(name)) {
+			continue
+		}
+		if err := fs.Remove(filepath.Join(path, name)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("destroy: failed to remove %s: %w", name, err)
+		}
+	}
+
+	// The lock file goes last, after the lock is released.
+	_ = lock.Close()
+	if err := fs.Remove(lockPath); err != nil && firstErr == nil {
+		firstErr = fmt.Errorf("destroy: failed to remove %s: %w", lockFileName, err)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Remove the directory only if nothing unrelated is left in it.
+	if remaining, err := fs.ListDir(path); err == nil && len(remaining) == 0 {
+		_ = fs.Remove(path)
+	}
+	return nil
+}
+
+// destroyWALArchive removes the archived WALs in dir, and dir itself if
+// nothing else is left in it.
+func destroyWALArchive(fs vfs.FS, dir string) error {
+	entries, err := fs.ListDir(dir)
+	if err != nil {
+		return fmt.Errorf("destroy: failed to list %s: %w", walArchiveDir, err)
+	}
+	var firstErr error
+	for _, name := range entries {
+		if !walArchiveFileRegex.MatchString(name) {
+			continue
+		}
+		if err := fs.Remove(filepath.Join(dir, name)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("destroy: failed to remove %s: %w", filepath.Join(walArchiveDir, name), err)
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if remaining, err := fs.ListDir(dir); err == nil && len(remaining) == 0 {
+		_ = fs.Remove(dir)
+	}
+	return nil
+}
+
+// liveTableNames returns the names of the SSTs referenced by the MANIFEST
+// that CURRENT points to. If the MANIFEST cannot be read, it returns nil and
+// the caller falls back to recognizing files by name.
+func liveTableNames(path string, fs vfs.FS) []string {
+	if !fs.Exists(filepath.Join(path, "CURRENT")) {
+		return nil
+	}
+
+	vs := version.NewVersionSet(version.VersionSetOptions{
+		DBName:    path,
+		FS:        fs,
+		NumLevels: version.MaxNumLevels,
+	})
+	if err := vs.Recover(); err != nil {
+		return nil
+	}
+	defer func() { _ = vs.Close() }()
+
+	v := vs.Current()
+	var names []string
+	for level := range v.NumLevels() {
+		for _, f := range v.Files(level) {
+			names = append(names, sstFileName(f.FD.GetNumber()))
+		}
+	}
+	return names
+}
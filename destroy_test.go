@@ -0,0 +1,148 @@
+package rockyardkv
+
+// destroy_test.go implements tests for DestroyDB.
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createDestroyTestDB creates a database at dir with a flushed SST and a WAL.
+func createDestroyTestDB(t *testing.T, dir string) {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("flushed"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("unflushed"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestDestroyDBLeavesForeignFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	createDestroyTestDB(t, dir)
+
+	foreign := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(foreign, []byte("keep me"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := DestroyDB(dir, nil); err != nil {
+		t.Fatalf("DestroyDB failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "notes.txt" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("remaining files = %v, want [notes.txt]", names)
+	}
+	data, err := os.ReadFile(foreign)
+	if err != nil || string(data) != "keep me" {
+		t.Errorf("foreign file = %q, %v; want unchanged", data, err)
+	}
+}
+
+func TestDestroyDBRemovesEmptyDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	createDestroyTestDB(t, dir)
+
+	if err := DestroyDB(dir, nil); err != nil {
+		t.Fatalf("DestroyDB failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("directory should be removed, Stat error = %v", err)
+	}
+
+	// Destroying a missing database is a no-op.
+	if err := DestroyDB(dir, nil); err != nil {
+		t.Errorf("DestroyDB on missing path failed: %v", err)
+	}
+}
+
+func TestDestroyDBRefusesWhenLocked(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	createDestroyTestDB(t, dir)
+
+	database, err := Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	if err := DestroyDB(dir, nil); !errors.Is(err, ErrDBLocked) {
+		t.Fatalf("DestroyDB error = %v, want ErrDBLocked", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "CURRENT")); err != nil {
+		t.Errorf("CURRENT should survive a refused DestroyDB: %v", err)
+	}
+}
+
+func TestDestroyDBRemovesArchivedWALsAndBlobFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.WALTTLSeconds = 3600
+	opts.EnableBlobFiles = true
+	opts.MinBlobSize = 0
+
+	// The first WAL becomes obsolete when the second session flushes the
+	// writes it recovered from it, and is then moved to the archive
+	for session := range 2 {
+		database, err := Open(dir, opts)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if err := database.Put(nil, []byte("key"), []byte("a value stored in a blob file")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if session == 1 {
+			if err := database.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			if err := database.(ReplicationDB).PurgeObsoleteWALFiles(); err != nil {
+				t.Fatalf("PurgeObsoleteWALFiles failed: %v", err)
+			}
+		}
+		if err := database.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	for _, pattern := range []string{"archive/*.log", "*.blob"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) == 0 {
+			t.Fatalf("no %s files before DestroyDB", pattern)
+		}
+	}
+
+	if err := DestroyDB(dir, nil); err != nil {
+		t.Fatalf("DestroyDB failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		entries, _ := os.ReadDir(dir)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory should be removed, remaining files = %v", names)
+	}
+}
@@ -0,0 +1,303 @@
+package rockyardkv
+
+// env.go implements the Env abstraction for time and background scheduling.
+//
+// The Env gives the database a single place to ask for the current time and
+// to run background jobs. Production code uses DefaultEnv(), which is backed
+// by the wall clock and a set of per-priority goroutine pools. Tests can
+// inject a MockEnv whose clock only moves when told to, which makes TTL,
+// rate limiting, and stall behavior deterministic.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/env.h
+//   - include/rocksdb/system_clock.h
+//   - util/threadpool_imp.cc
+//   - test_util/mock_time_env.h
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SystemClock provides the current time to the database.
+// Reference: RocksDB v10.7.5 include/rocksdb/system_clock.h
+type SystemClock interface {
+	// NowMicros returns the number of microseconds since the Unix epoch.
+	NowMicros() uint64
+
+	// SleepForMicroseconds blocks the calling goroutine for the given duration.
+	SleepForMicroseconds(micros int64)
+}
+
+// Priority selects the background thread pool a job is scheduled in.
+// Reference: RocksDB v10.7.5 include/rocksdb/env.h (Env::Priority)
+type Priority int
+
+const (
+	// PriorityBottom is for bottommost-level compactions.
+	PriorityBottom Priority = iota
+	// PriorityLow is for compactions.
+	PriorityLow
+	// PriorityHigh is for flushes.
+	PriorityHigh
+	// PriorityUser is for user-initiated work.
+	PriorityUser
+	// PriorityTotal is the number of priorities.
+	PriorityTotal
+)
+
+// String returns the string representation of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityBottom:
+		return "BOTTOM"
+	case PriorityLow:
+		return "LOW"
+	case PriorityHigh:
+		return "HIGH"
+	case PriorityUser:
+		return "USER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Env abstracts the operating environment: the clock and background threads.
+// Reference: RocksDB v10.7.5 include/rocksdb/env.h
+type Env interface {
+	SystemClock
+
+	// Schedule arranges for fn to run once in the thread pool for pri.
+	Schedule(fn func(), pri Priority)
+
+	// SetBackgroundThreads sets the number of threads in the pool for pri.
+	SetBackgroundThreads(num int, pri Priority)
+
+	// IncBackgroundThreadsIfNeeded grows the pool for pri to at least num threads.
+	IncBackgroundThreadsIfNeeded(num int, pri Priority)
+
+	// GetBackgroundThreads returns the number of threads in the pool for pri.
+	GetBackgroundThreads(pri Priority) int
+
+	// GetThreadPoolQueueLen returns the number of jobs waiting in the pool for pri.
+	GetThreadPoolQueueLen(pri Priority) int
+}
+
+var (
+	defaultEnvOnce sync.Once
+	defaultEnv     *systemEnv
+)
+
+// DefaultEnv returns the process-wide Env backed by the wall clock.
+// Reference: RocksDB v10.7.5 include/rocksdb/env.h (Env::Default)
+func DefaultEnv() Env {
+	defaultEnvOnce.Do(func() {
+		defaultEnv = newSystemEnv()
+	})
+	return defaultEnv
+}
+
+// systemEnv is the Env backed by the wall clock.
+type systemEnv struct {
+	pools [PriorityTotal]*threadPool
+}
+
+// newSystemEnv creates a systemEnv with its own thread pools.
+func newSystemEnv() *systemEnv {
+	e := &systemEnv{}
+	for i := range e.pools {
+		// RocksDB starts every pool except BOTTOM with a single thread.
+		threads := 1
+		if Priority(i) == PriorityBottom {
+			threads = 0
+		}
+		e.pools[i] = newThreadPool(threads)
+	}
+	return e
+}
+
+// NowMicros implements SystemClock.
+func (e *systemEnv) NowMicros() uint64 {
+	return uint64(time.Now().UnixMicro())
+}
+
+// SleepForMicroseconds implements SystemClock.
+func (e *systemEnv) SleepForMicroseconds(micros int64) {
+	if micros > 0 {
+		time.Sleep(time.Duration(micros) * time.Microsecond)
+	}
+}
+
+// Schedule implements Env.
+func (e *systemEnv) Schedule(fn func(), pri Priority) {
+	e.pool(pri).schedule(fn)
+}
+
+// SetBackgroundThreads implements Env.
+func (e *systemEnv) SetBackgroundThreads(num int, pri Priority) {
+	e.pool(pri).setThreads(num, false)
+}
+
+// IncBackgroundThreadsIfNeeded implements Env.
+func (e *systemEnv) IncBackgroundThreadsIfNeeded(num int, pri Priority) {
+	e.pool(pri).setThreads(num, true)
+}
+
+// GetBackgroundThreads implements Env.
+func (e *systemEnv) GetBackgroundThreads(pri Priority) int {
+	return e.pool(pri).threads()
+}
+
+// GetThreadPoolQueueLen implements Env.
+func (e *systemEnv) GetThreadPoolQueueLen(pri Priority) int {
+	return e.pool(pri).queueLen()
+}
+
+// pool returns the thread pool for pri, falling back to LOW for unknown values.
+func (e *systemEnv) pool(pri Priority) *threadPool {
+	if pri < 0 || pri >= PriorityTotal {
+		pri = PriorityLow
+	}
+	return e.pools[pri]
+}
+
+// MockEnv is an Env whose clock only advances when told to.
+// Background jobs still run on real goroutines; only time is simulated.
+// SleepForMicroseconds advances the mock clock instead of blocking.
+// Reference: RocksDB v10.7.5 test_util/mock_time_env.h
+type MockEnv struct {
+	*systemEnv
+	nowMicros atomic.Uint64
+}
+
+// NewMockEnv creates a MockEnv whose clock starts at start.
+func NewMockEnv(start time.Time) *MockEnv {
+	e := &MockEnv{systemEnv: newSystemEnv()}
+	e.nowMicros.Store(uint64(start.UnixMicro()))
+	return e
+}
+
+// NowMicros implements SystemClock.
+func (e *MockEnv) NowMicros() uint64 {
+	return e.nowMicros.Load()
+}
+
+// SleepForMicroseconds implements SystemClock by advancing the mock clock.
+func (e *MockEnv) SleepForMicroseconds(micros int64) {
+	if micros > 0 {
+		e.nowMicros.Add(uint64(micros))
+	}
+}
+
+// Advance moves the mock clock forward by d.
+func (e *MockEnv) Advance(d time.Duration) {
+	e.SleepForMicroseconds(d.Microseconds())
+}
+
+// SetCurrentTime sets the mock clock to t.
+func (e *MockEnv) SetCurrentTime(t time.Time) {
+	e.nowMicros.Store(uint64(t.UnixMicro()))
+}
+
+// clockNow returns the current time of clock as a time.Time.
+// A nil clock falls back to the wall clock.
+func clockNow(clock SystemClock) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return time.UnixMicro(int64(clock.NowMicros()))
+}
+
+// clockSleep blocks for d using clock. A nil clock falls back to time.Sleep.
+func clockSleep(clock SystemClock, d time.Duration) {
+	if clock == nil {
+		time.Sleep(d)
+		return
+	}
+	clock.SleepForMicroseconds(d.Microseconds())
+}
+
+// threadPool runs scheduled jobs on a bounded number of goroutines.
+// Workers are started on demand and exit when the queue drains, so an idle
+// pool holds no goroutines.
+// Reference: RocksDB v10.7.5 util/threadpool_imp.cc
+type threadPool struct {
+	mu         sync.Mutex
+	queue      []func()
+	maxThreads int
+	workers    int // goroutines started and not yet exited
+	running    int // workers currently executing a job
+}
+
+// newThreadPool creates a pool with the given number of threads.
+func newThreadPool(threads int) *threadPool {
+	return &threadPool{maxThreads: max(threads, 0)}
+}
+
+// schedule queues fn and starts a worker if the pool has spare capacity.
+func (p *threadPool) schedule(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, fn)
+	p.maybeStartWorkersLocked()
+}
+
+// setThreads resizes the pool. If onlyGrow is true, the pool never shrinks.
+// Shrinking takes effect as running workers finish their current job.
+func (p *threadPool) setThreads(num int, onlyGrow bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	num = max(num, 0)
+	if onlyGrow && num <= p.maxThreads {
+		return
+	}
+	p.maxThreads = num
+	p.maybeStartWorkersLocked()
+}
+
+// threads returns the configured pool size.
+func (p *threadPool) threads() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.maxThreads
+}
+
+// queueLen returns the number of jobs not yet picked up by a worker.
+func (p *threadPool) queueLen() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// maybeStartWorkersLocked starts workers until the pool is full or every
+// queued job has an idle worker. REQUIRES: p.mu is held.
+func (p *threadPool) maybeStartWorkersLocked() {
+	for p.workers < p.maxThreads && p.workers-p.running < len(p.queue) {
+		p.workers++
+		go p.worker()
+	}
+}
+
+// worker runs queued jobs until the queue is empty or the pool shrinks.
+func (p *threadPool) worker() {
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 || p.workers > p.maxThreads {
+			p.workers--
+			p.mu.Unlock()
+			return
+		}
+		fn := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		p.running++
+		p.mu.Unlock()
+
+		fn()
+
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,231 @@
+package rockyardkv
+
+// env_test.go implements tests for the Env abstraction.
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMockEnvClock(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	env := NewMockEnv(start)
+
+	if got := clockNow(env); !got.Equal(start) {
+		t.Fatalf("NowMicros = %v, want %v", got, start)
+	}
+
+	env.Advance(90 * time.Minute)
+	if got, want := clockNow(env), start.Add(90*time.Minute); !got.Equal(want) {
+		t.Errorf("after Advance: now = %v, want %v", got, want)
+	}
+
+	// Sleeping on a mock clock advances it instead of blocking.
+	env.SleepForMicroseconds(int64(time.Second / time.Microsecond))
+	if got, want := clockNow(env), start.Add(90*time.Minute+time.Second); !got.Equal(want) {
+		t.Errorf("after SleepForMicroseconds: now = %v, want %v", got, want)
+	}
+
+	env.SetCurrentTime(start)
+	if got := clockNow(env); !got.Equal(start) {
+		t.Errorf("after SetCurrentTime: now = %v, want %v", got, start)
+	}
+}
+
+func TestEnvScheduleRunsJobs(t *testing.T) {
+	env := NewMockEnv(time.Now())
+	env.SetBackgroundThreads(2, PriorityLow)
+	if got := env.GetBackgroundThreads(PriorityLow); got != 2 {
+		t.Fatalf("GetBackgroundThreads = %d, want 2", got)
+	}
+
+	var wg sync.WaitGroup
+	var ran atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		env.Schedule(func() {
+			defer wg.Done()
+			ran.Add(1)
+		}, PriorityLow)
+	}
+	wg.Wait()
+
+	if got := ran.Load(); got != 10 {
+		t.Errorf("ran %d jobs, want 10", got)
+	}
+}
+
+func TestEnvThreadPoolBoundsConcurrency(t *testing.T) {
+	env := NewMockEnv(time.Now())
+	env.SetBackgroundThreads(2, PriorityLow)
+
+	var wg sync.WaitGroup
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	for range 6 {
+		wg.Add(1)
+		env.Schedule(func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+		}, PriorityLow)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := env.GetThreadPoolQueueLen(PriorityLow); got != 4 {
+		t.Errorf("GetThreadPoolQueueLen = %d, want 4", got)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrency = %d, want 2", got)
+	}
+}
+
+func TestEnvIncBackgroundThreadsIfNeeded(t *testing.T) {
+	env := NewMockEnv(time.Now())
+	env.SetBackgroundThreads(4, PriorityHigh)
+
+	env.IncBackgroundThreadsIfNeeded(2, PriorityHigh)
+	if got := env.GetBackgroundThreads(PriorityHigh); got != 4 {
+		t.Errorf("IncBackgroundThreadsIfNeeded shrank pool to %d, want 4", got)
+	}
+
+	env.IncBackgroundThreadsIfNeeded(6, PriorityHigh)
+	if got := env.GetBackgroundThreads(PriorityHigh); got != 6 {
+		t.Errorf("IncBackgroundThreadsIfNeeded = %d, want 6", got)
+	}
+}
+
+// TestFIFOTTLCompactionWithMockClock verifies that advancing a mock clock
+// past the FIFO TTL drops expired files without any real waiting.
+func TestFIFOTTLCompactionWithMockClock(t *testing.T) {
+	env := NewMockEnv(time.Unix(1_700_000_000, 0))
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Env = env
+	opts.CompactionStyle = CompactionStyleFIFO
+	opts.FIFOCompactionOptions = &FIFOCompactionOptions{
+		MaxTableFilesSize: 1 << 30,
+		TTL:               time.Hour,
+	}
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.Put(nil, []byte("old"), []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// Within the TTL nothing is dropped.
+	env.Advance(30 * time.Minute)
+	if err := database.Put(nil, []byte("mid"), []byte("v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := database.WaitForCompact(nil); err != nil {
+		t.Fatalf("WaitForCompact failed: %v", err)
+	}
+	if _, err := database.Get(nil, []byte("old")); err != nil {
+		t.Fatalf("Get(old) before TTL: %v", err)
+	}
+
+	// Move past the TTL of the first file only; the next flush schedules
+	// a compaction that must drop it.
+	env.Advance(45 * time.Minute)
+	if err := database.Put(nil, []byte("new"), []byte("v3")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := database.Get(nil, []byte("old"))
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expired file was not dropped: Get(old) err = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, key := range []string{"mid", "new"} {
+		if _, err := database.Get(nil, []byte(key)); err != nil {
+			t.Errorf("Get(%s) after TTL compaction: %v", key, err)
+		}
+	}
+	if got, _ := database.GetProperty(PropertyNumFilesAtLevelPrefix + "0"); got != "2" {
+		t.Errorf("L0 files = %s, want 2", got)
+	}
+}
+
+func TestTTLDBWithMockClock(t *testing.T) {
+	env := NewMockEnv(time.Unix(1_700_000_000, 0))
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Env = env
+
+	ttlDB, err := OpenWithTTL(t.TempDir(), opts, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenWithTTL failed: %v", err)
+	}
+	defer ttlDB.Close()
+
+	if err := ttlDB.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	env.Advance(59 * time.Minute)
+	if got, err := ttlDB.Get(nil, []byte("key")); err != nil || string(got) != "value" {
+		t.Fatalf("Get before TTL = %q, %v; want value", got, err)
+	}
+
+	env.Advance(2 * time.Minute)
+	if _, err := ttlDB.Get(nil, []byte("key")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after TTL err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRateLimiterWithMockClock(t *testing.T) {
+	env := NewMockEnv(time.Unix(1_700_000_000, 0))
+	rl := NewGenericRateLimiter(&RateLimiterOptions{
+		BytesPerSecond: 1000,
+		RefillPeriod:   100 * time.Millisecond,
+		Clock:          env,
+	})
+
+	// 100 bytes are available up front; the remaining 900 must be waited for.
+	// On a mock clock the wait advances time instead of blocking.
+	start := time.Now()
+	rl.Request(1000, IOPriorityLow)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request blocked for %v on a mock clock", elapsed)
+	}
+	if advanced := clockNow(env).Sub(time.Unix(1_700_000_000, 0)); advanced < 900*time.Millisecond {
+		t.Errorf("mock clock advanced %v, want >= 900ms", advanced)
+	}
+}
@@ -6,6 +6,8 @@ package rockyardkv
 import (
 	"sync"
 	"time"
+
+	"github.com/aalhour/rockyardkv/internal/compaction"
 )
 
 // FlushJobInfo contains information about a flush job.
@@ -114,6 +116,79 @@ type CompactionJobInfo struct {
 	IsManualCompaction bool
 	// CompactionReason is the reason for the compaction.
 	CompactionReason CompactionReason
+	// NumInputFilesPerLevel is the number of input files by level.
+	NumInputFilesPerLevel map[int]int
+	// NumOutputFilesPerLevel is the number of output files by level.
+	NumOutputFilesPerLevel map[int]int
+	// NumDroppedRecords is the number of input records missing from the
+	// output: hidden by newer entries or deletions, covered by range
+	// tombstones, removed by the compaction filter, or folded into merge
+	// results.
+	NumDroppedRecords uint64
+	// Elapsed is how long the compaction took.
+	Elapsed time.Duration
+}
+
+// newCompactionJobInfo describes the inputs of compaction c.
+func (db *dbImpl) newCompactionJobInfo(c *compaction.Compaction) *CompactionJobInfo {
+	info := &CompactionJobInfo{
+		CFName:                 DefaultColumnFamilyName,
+		BaseInputLevel:         c.StartLevel(),
+		OutputLevel:            c.OutputLevel,
+		IsManualCompaction:     c.Reason == compaction.CompactionReasonManualCompaction,
+		CompactionReason:       compactionReason(c.Reason),
+		NumInputFilesPerLevel:  make(map[int]int),
+		NumOutputFilesPerLevel: make(map[int]int),
+	}
+	for _, input := range c.Inputs {
+		for _, f := range input.Files {
+			info.InputFiles = append(info.InputFiles, db.sstFilePath(f.FD.GetNumber()))
+			info.TotalInputBytes += f.FD.FileSize
+			info.NumInputFilesPerLevel[input.Level]++
+			if f.ColumnFamilyID != DefaultColumnFamilyID {
+				db.mu.RLock()
+				if cfd := db.columnFamilies.getByID(f.ColumnFamilyID); cfd != nil {
+					info.CFName = cfd.name
+				}
+				db.mu.RUnlock()
+			}
+		}
+	}
+	info.NumInputFiles = len(info.InputFiles)
+	return info
+}
+
+// setOutputs describes what compaction c wrote.
+func (info *CompactionJobInfo) setOutputs(db *dbImpl, c *compaction.Compaction, result *compactionResult) {
+	for _, f := range result.outputFiles {
+		info.OutputFiles = append(info.OutputFiles, db.sstFilePath(f.FD.GetNumber()))
+		info.TotalOutputBytes += f.FD.FileSize
+		info.NumOutputFilesPerLevel[c.OutputLevel]++
+	}
+	info.NumOutputFiles = len(info.OutputFiles)
+	info.NumInputRecords = result.numInputRecords
+	info.NumOutputRecords = result.numOutputRecords
+	if result.numInputRecords > result.numOutputRecords {
+		info.NumDroppedRecords = result.numInputRecords - result.numOutputRecords
+	}
+}
+
+// compactionReason converts the reason a compaction was picked.
+func compactionReason(r compaction.CompactionReason) CompactionReason {
+	switch r {
+	case compaction.CompactionReasonLevelL0FileNumTrigger:
+		return CompactionReasonLevelL0FilesNum
+	case compaction.CompactionReasonLevelMaxLevelSize:
+		return CompactionReasonLevelMaxLevelSize
+	case compaction.CompactionReasonManualCompaction:
+		return CompactionReasonManualCompaction
+	case compaction.CompactionReasonFlush:
+		return CompactionReasonFlush
+	case compaction.CompactionReasonFIFOTTL:
+		return CompactionReasonTTL
+	default:
+		return CompactionReasonUnknown
+	}
 }
 
 // CompactionReason describes why a compaction was triggered.
@@ -3,6 +3,8 @@ package rockyardkv
 // event_listener_test.go implements tests for event listener.
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -193,6 +195,148 @@ func TestCompactionJobInfo(t *testing.T) {
 	}
 }
 
+// compactionListener records the compactions it is notified of.
+type compactionListener struct {
+	NoOpEventListener
+	mu        sync.Mutex
+	begun     []*CompactionJobInfo
+	completed []*CompactionJobInfo
+}
+
+func (l *compactionListener) OnCompactionBegin(info *CompactionJobInfo) {
+	l.mu.Lock()
+	l.begun = append(l.begun, info)
+	l.mu.Unlock()
+}
+
+func (l *compactionListener) OnCompactionCompleted(info *CompactionJobInfo) {
+	l.mu.Lock()
+	l.completed = append(l.completed, info)
+	l.mu.Unlock()
+}
+
+// TestCompactionListenerJobInfo checks the accounting of a compaction that
+// drops overwritten and deleted keys.
+func TestCompactionListenerJobInfo(t *testing.T) {
+	listener := &compactionListener{}
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	opts.Listeners = []EventListener{listener}
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	const n = 1000
+	for i := range n {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%04d", i), make([]byte, 100)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	// Delete every other key
+	for i := 0; i < n; i += 2 {
+		if err := db.Delete(nil, fmt.Appendf(nil, "key%04d", i)); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+	if len(listener.completed) == 0 || len(listener.begun) != len(listener.completed) {
+		t.Fatalf("got %d begin and %d completed notifications", len(listener.begun), len(listener.completed))
+	}
+	// CompactRange compacts L0 first, then moves the result down
+	info := listener.completed[0]
+	if info.Status != nil {
+		t.Fatalf("Status = %v", info.Status)
+	}
+	if !info.IsManualCompaction || info.CompactionReason != CompactionReasonManualCompaction {
+		t.Errorf("IsManualCompaction = %v, CompactionReason = %v", info.IsManualCompaction, info.CompactionReason)
+	}
+	if info.NumInputFilesPerLevel[0] != 2 || info.NumInputFiles != 2 {
+		t.Errorf("input files = %v (%d), want 2 at L0", info.NumInputFilesPerLevel, info.NumInputFiles)
+	}
+	if info.NumOutputFilesPerLevel[info.OutputLevel] != info.NumOutputFiles || info.NumOutputFiles == 0 {
+		t.Errorf("output files = %v (%d) at L%d", info.NumOutputFilesPerLevel, info.NumOutputFiles, info.OutputLevel)
+	}
+	if info.NumInputRecords != n+n/2 {
+		t.Errorf("NumInputRecords = %d, want %d", info.NumInputRecords, n+n/2)
+	}
+	if info.NumDroppedRecords == 0 || info.NumDroppedRecords != info.NumInputRecords-info.NumOutputRecords {
+		t.Errorf("NumDroppedRecords = %d, input %d, output %d", info.NumDroppedRecords, info.NumInputRecords, info.NumOutputRecords)
+	}
+	if info.TotalOutputBytes == 0 || info.TotalOutputBytes >= info.TotalInputBytes {
+		t.Errorf("TotalOutputBytes = %d, want less than TotalInputBytes = %d", info.TotalOutputBytes, info.TotalInputBytes)
+	}
+	if info.Elapsed <= 0 {
+		t.Errorf("Elapsed = %v, want positive", info.Elapsed)
+	}
+	if begin := listener.begun[0]; begin.TotalInputBytes != info.TotalInputBytes || begin.NumOutputFiles != 0 {
+		t.Errorf("begin info = %+v", begin)
+	}
+}
+
+// TestCompactionListenerJobInfoParallelSameBounds checks the record counts of
+// a parallel compaction whose inputs all span the same keys, so that it runs
+// as a single job.
+func TestCompactionListenerJobInfoParallelSameBounds(t *testing.T) {
+	listener := &compactionListener{}
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	opts.MaxSubcompactions = 4
+	opts.Listeners = []EventListener{listener}
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	const n, rounds = 100, 4
+	for range rounds {
+		for i := range n {
+			if err := db.Put(nil, fmt.Appendf(nil, "key%04d", i), make([]byte, 100)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	if err := db.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+	if len(listener.completed) == 0 {
+		t.Fatal("got no completed notifications")
+	}
+	info := listener.completed[0]
+	if info.NumInputFiles != rounds {
+		t.Fatalf("NumInputFiles = %d, want %d", info.NumInputFiles, rounds)
+	}
+	if info.NumInputRecords != n*rounds || info.NumOutputRecords != n {
+		t.Errorf("NumInputRecords = %d, NumOutputRecords = %d, want %d and %d", info.NumInputRecords, info.NumOutputRecords, n*rounds, n)
+	}
+	if info.NumDroppedRecords != n*(rounds-1) {
+		t.Errorf("NumDroppedRecords = %d, want %d", info.NumDroppedRecords, n*(rounds-1))
+	}
+}
+
 func TestTableFileCreationInfo(t *testing.T) {
 	info := &TableFileCreationInfo{
 		DBName:   "/tmp/db",
@@ -16,11 +16,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"sync/atomic"
 
 	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/manifest"
+	"github.com/aalhour/rockyardkv/internal/memtable"
 	"github.com/aalhour/rockyardkv/internal/table"
 	"github.com/aalhour/rockyardkv/internal/version"
 )
@@ -43,6 +45,16 @@ var (
 	// ErrIngestNotBottommostLevel is returned when fail_if_not_bottommost_level is set
 	// but files cannot be placed in the bottommost level.
 	ErrIngestNotBottommostLevel = errors.New("ingest: files cannot be placed in bottommost level")
+
+	// ErrIngestSeqNoConflict is returned when a file whose entries carry
+	// non-zero sequence numbers cannot keep them, because it overlaps
+	// existing data or other ingested files, or is visible to a snapshot.
+	ErrIngestSeqNoConflict = errors.New("ingest: file with non-zero sequence numbers cannot keep them")
+
+	// ErrIngestGlobalSeqNoRequired is returned when a file needs a global
+	// sequence number to order against existing data and AllowGlobalSeqNo
+	// is false.
+	ErrIngestGlobalSeqNoRequired = errors.New("ingest: file requires a global sequence number and allow_global_seqno is false")
 )
 
 // IngestExternalFileOptions configures the behavior of IngestExternalFile.
@@ -58,7 +70,16 @@ type IngestExternalFileOptions struct {
 
 	// AllowGlobalSeqNo: enables assigning a global sequence number to each
 	// ingested file. If false, we will use the sequence numbers in the
-	// ingested file as is.
+	// ingested file as is, and ingestion fails with
+	// ErrIngestGlobalSeqNoRequired when a file overlaps existing data or,
+	// with SnapshotConsistency, a snapshot exists.
+	//
+	// Files whose entries carry non-zero sequence numbers, such as SSTs
+	// exported from another database, always keep them: a global sequence
+	// number would collapse their versions of a key. Such files must not
+	// overlap existing data, other ingested files or, with
+	// SnapshotConsistency, existing snapshots; otherwise ingestion fails
+	// with ErrIngestSeqNoConflict.
 	AllowGlobalSeqNo bool
 
 	// AllowBlockingFlush: if true, IngestExternalFile() will trigger and
@@ -103,6 +124,33 @@ type ingestedFileInfo struct {
 	largestKey   []byte // Largest user key
 	targetLevel  int    // Level where file will be placed
 	globalSeqNo  uint64 // Assigned global sequence number
+	keepSeqNos   bool   // Entries carry non-zero sequence numbers, which are kept
+
+	// Range of the sequence numbers of the file's entries once ingested:
+	// the embedded ones, or the global sequence number
+	smallestSeqNo uint64
+	largestSeqNo  uint64
+}
+
+// IngestExternalFileArg names a column family and the external SST files to
+// ingest into it, for use with IngestExternalFiles.
+// This matches the C++ RocksDB IngestExternalFileArg structure.
+type IngestExternalFileArg struct {
+	// ColumnFamily is the target column family. nil means the default column family.
+	ColumnFamily ColumnFamilyHandle
+
+	// ExternalFiles are the paths of the SST files to ingest.
+	ExternalFiles []string
+
+	// Options controls how these files are ingested.
+	Options IngestExternalFileOptions
+}
+
+// ingestJob is the per-column-family state of an ingestion.
+type ingestJob struct {
+	cfd   *columnFamilyData
+	opts  IngestExternalFileOptions
+	files []*ingestedFileInfo
 }
 
 // IngestExternalFile loads external SST files into the database.
@@ -123,75 +171,157 @@ func (db *dbImpl) IngestExternalFileCF(cf ColumnFamilyHandle, paths []string, op
 	if len(paths) == 0 {
 		return nil
 	}
+	return db.IngestExternalFiles([]IngestExternalFileArg{{ColumnFamily: cf, ExternalFiles: paths, Options: opts}})
+}
 
-	db.logger.Infof("[ingest] ingesting %d SST files", len(paths))
-
-	// Step 1: Verify and collect information about all files
-	files, err := db.verifyAndPrepareIngestFiles(paths, opts)
-	if err != nil {
-		return err
-	}
+// IngestExternalFiles loads external SST files into one or more column
+// families atomically: the files of every argument are committed to the
+// MANIFEST as a single atomic group, so either all of them become visible or,
+// on any error, none do and the database is left unchanged.
+//
+// Each argument must name a different column family.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/db.h (DB::IngestExternalFiles)
+//   - db/db_impl/db_impl.cc (DBImpl::IngestExternalFiles)
+func (db *dbImpl) IngestExternalFiles(args []IngestExternalFileArg) error {
+	// Step 1: Resolve column families, then verify and collect information about all files
+	jobs := make([]*ingestJob, 0, len(args))
+	seenCFs := make(map[uint32]bool, len(args))
+	numFiles := 0
+	for _, arg := range args {
+		cfd, err := db.getColumnFamilyData(arg.ColumnFamily)
+		if err != nil {
+			return err
+		}
+		if seenCFs[cfd.id] {
+			return fmt.Errorf("ingest: column family %q appears in more than one argument", cfd.name)
+		}
+		seenCFs[cfd.id] = true
+		if len(arg.ExternalFiles) == 0 {
+			continue
+		}
 
-	// Step 2: Check for overlap between ingested files (if not allowing global seqno)
-	if !opts.AllowGlobalSeqNo && len(files) > 1 {
-		if err := db.checkIngestedFilesOverlap(files); err != nil {
+		files, err := db.verifyAndPrepareIngestFiles(arg.ExternalFiles, arg.Options)
+		if err != nil {
 			return err
 		}
+
+		// Step 2: Check for overlap between ingested files (if not allowing global seqno)
+		if !arg.Options.AllowGlobalSeqNo && len(files) > 1 {
+			if err := db.checkIngestedFilesOverlap(files); err != nil {
+				return err
+			}
+		}
+
+		jobs = append(jobs, &ingestJob{cfd: cfd, opts: arg.Options, files: files})
+		numFiles += len(files)
 	}
+	if numFiles == 0 {
+		return nil
+	}
+
+	db.logger.Infof("[ingest] ingesting %d SST files into %d column families", numFiles, len(jobs))
 
 	// Step 3: Acquire DB mutex for the rest of the operation
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	// Step 4: Check for overlap with memtable
-	if db.checkMemtableOverlap(files) {
-		if !opts.AllowBlockingFlush {
-			return ErrIngestOverlapMemtable
+	for _, job := range jobs {
+		// Step 4: Check that files with their own sequence numbers can keep
+		// them, then check for overlap with memtable
+		if err := db.checkIngestedSeqNos(job); err != nil {
+			return err
 		}
-		// Flush memtable
-		db.mu.Unlock()
-		if err := db.Flush(DefaultFlushOptions()); err != nil {
-			db.mu.Lock()
-			return fmt.Errorf("ingest: failed to flush memtable: %w", err)
+		if err := db.resolveIngestMemtableOverlap(job); err != nil {
+			return err
 		}
-		db.mu.Lock()
-	}
 
-	// Step 5: Assign file numbers and determine target levels
-	for i := range files {
-		files[i].fileNumber = db.versions.NextFileNumber()
-		files[i].internalPath = db.sstFilePath(files[i].fileNumber)
-	}
+		// Step 5: Assign file numbers
+		for _, f := range job.files {
+			f.fileNumber = db.versions.NextFileNumber()
+			f.internalPath = db.sstFilePath(f.fileNumber)
+		}
 
-	// Step 6: Assign global sequence numbers
-	if err := db.assignGlobalSeqNos(files, opts); err != nil {
-		return err
+		// Step 6: Assign global sequence numbers
+		if err := db.assignGlobalSeqNos(job); err != nil {
+			return err
+		}
+
+		// Step 7: Determine target levels
+		if err := db.determineTargetLevels(job.files, job.opts); err != nil {
+			return err
+		}
 	}
 
-	// Step 7: Determine target levels
-	if err := db.determineTargetLevels(files, opts); err != nil {
-		return err
+	// Step 8: Copy/link files to DB directory
+	for _, job := range jobs {
+		if err := db.installIngestedFiles(job.files, job.opts); err != nil {
+			db.removeIngestedFiles(jobs)
+			return err
+		}
 	}
 
-	// Step 8: Copy/move files to DB directory
-	if err := db.installIngestedFiles(files, opts); err != nil {
+	// Step 9: Update MANIFEST with one edit per column family, applied atomically
+	if err := db.updateManifestForIngest(jobs); err != nil {
+		db.removeIngestedFiles(jobs)
 		return err
 	}
 
-	// Step 9: Update MANIFEST
-	if err := db.updateManifestForIngest(files, cf); err != nil {
-		// Cleanup copied files on failure
-		for _, f := range files {
-			os.Remove(f.internalPath)
+	// Step 10: The ingestion is durable; unlink moved source files
+	for _, job := range jobs {
+		if job.opts.MoveFiles {
+			for _, f := range job.files {
+				os.Remove(f.externalPath)
+			}
 		}
-		return err
 	}
 
-	db.logger.Infof("[ingest] completed: %d files ingested", len(files))
+	db.logger.Infof("[ingest] completed: %d files ingested", numFiles)
+
+	return nil
+}
+
+// resolveIngestMemtableOverlap flushes the memtables of the job's column
+// family if the job's files overlap them.
+// REQUIRES: db.mu held.
+func (db *dbImpl) resolveIngestMemtableOverlap(job *ingestJob) error {
+	mems := db.ingestMemtables(job.cfd)
+	if !slices.ContainsFunc(mems, func(mem *memtable.MemTable) bool {
+		return checkMemtableOverlap(mem, job.files)
+	}) {
+		return nil
+	}
+	if !job.opts.AllowBlockingFlush {
+		return ErrIngestOverlapMemtable
+	}
 
+	// Flush memtable
+	db.mu.Unlock()
+	var err error
+	if job.cfd.id == DefaultColumnFamilyID {
+		err = db.Flush(DefaultFlushOptions())
+	} else {
+		err = db.flushColumnFamily(job.cfd)
+	}
+	db.mu.Lock()
+	if err != nil {
+		return fmt.Errorf("ingest: failed to flush memtable: %w", err)
+	}
 	return nil
 }
 
+// removeIngestedFiles deletes any files already placed in the DB directory.
+func (db *dbImpl) removeIngestedFiles(jobs []*ingestJob) {
+	for _, job := range jobs {
+		for _, f := range job.files {
+			if f.internalPath != "" {
+				os.Remove(f.internalPath)
+			}
+		}
+	}
+}
+
 // verifyAndPrepareIngestFiles verifies each file is a valid SST and collects metadata.
 func (db *dbImpl) verifyAndPrepareIngestFiles(paths []string, opts IngestExternalFileOptions) ([]*ingestedFileInfo, error) {
 	files := make([]*ingestedFileInfo, 0, len(paths))
@@ -240,27 +370,73 @@ func (db *dbImpl) verifyIngestFile(path string, opts IngestExternalFileOptions)
 	}
 
 	// Get the key range by iterating
+	var smallestKey, largestKey []byte
 	iter := reader.NewIterator()
 
 	// Get smallest key
 	iter.SeekToFirst()
-	if !iter.Valid() {
-		return nil, ErrIngestEmptyFile
+	if iter.Valid() {
+		smallestKey = ingestExtractUserKey(iter.Key())
+
+		// Get largest key
+		iter.SeekToLast()
+		if !iter.Valid() {
+			return nil, ErrIngestInvalidFile
+		}
+		largestKey = ingestExtractUserKey(iter.Key())
 	}
-	smallestKey := ingestExtractUserKey(iter.Key())
 
-	// Get largest key
-	iter.SeekToLast()
-	if !iter.Valid() {
-		return nil, ErrIngestInvalidFile
+	// Take the sequence numbers of the entries, which are all zero in files
+	// written by SstFileWriter, from the table properties. Files written
+	// before those properties existed are scanned instead.
+	smallestSeqNo, largestSeqNo := uint64(dbformat.MaxSequenceNumber), uint64(0)
+	addSeqNo := func(seq uint64) {
+		smallestSeqNo = min(smallestSeqNo, seq)
+		largestSeqNo = max(largestSeqNo, seq)
+	}
+	props, err := reader.Properties()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table properties: %w", err)
+	}
+	if props.HasKeySeqnos {
+		addSeqNo(props.KeySmallestSeqno)
+		addSeqNo(props.KeyLargestSeqno)
+	} else {
+		for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+			addSeqNo(uint64(dbformat.ExtractSequenceNumber(iter.Key())))
+		}
+		if err := iter.Error(); err != nil {
+			return nil, fmt.Errorf("failed to read SST file: %w", err)
+		}
+	}
+
+	// Widen the range to cover range tombstones so that placement and
+	// memtable overlap checks see every key the file can delete.
+	tombstones, err := reader.GetRangeTombstoneList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read range tombstones: %w", err)
+	}
+	for _, t := range tombstones.All() {
+		if smallestKey == nil || bytes.Compare(t.StartKey, smallestKey) < 0 {
+			smallestKey = t.StartKey
+		}
+		if largestKey == nil || bytes.Compare(t.EndKey, largestKey) > 0 {
+			largestKey = t.EndKey
+		}
+		addSeqNo(uint64(t.SequenceNum))
+	}
+	if smallestKey == nil {
+		return nil, ErrIngestEmptyFile
 	}
-	largestKey := ingestExtractUserKey(iter.Key())
 
 	return &ingestedFileInfo{
-		externalPath: path,
-		fileSize:     uint64(stat.Size()),
-		smallestKey:  append([]byte(nil), smallestKey...),
-		largestKey:   append([]byte(nil), largestKey...),
+		externalPath:  path,
+		fileSize:      uint64(stat.Size()),
+		smallestKey:   append([]byte(nil), smallestKey...),
+		largestKey:    append([]byte(nil), largestKey...),
+		keepSeqNos:    largestSeqNo != 0,
+		smallestSeqNo: smallestSeqNo,
+		largestSeqNo:  largestSeqNo,
 	}, nil
 }
 
@@ -294,14 +470,87 @@ func (db *dbImpl) checkIngestedFilesOverlap(files []*ingestedFileInfo) error {
 	return nil
 }
 
+// checkIngestedSeqNos verifies that the files of a job whose entries carry
+// their own sequence numbers can keep them. Such a file must not overlap the
+// memtables, the LSM tree or the other files of the job, as its versions
+// cannot be ordered against that data, nor, with SnapshotConsistency, hold
+// sequence numbers visible to an existing snapshot.
+// REQUIRES: db.mu held.
+func (db *dbImpl) checkIngestedSeqNos(job *ingestJob) error {
+	if !slices.ContainsFunc(job.files, func(f *ingestedFileInfo) bool { return f.keepSeqNos }) {
+		return nil
+	}
+
+	mems := db.ingestMemtables(job.cfd)
+	current := db.versions.Current()
+	if current != nil {
+		current.Ref()
+		defer current.Unref()
+	}
+	newestSnapshot := dbformat.SequenceNumber(0)
+	hasSnapshot := false
+	for _, seq := range db.snapshotSequences() {
+		newestSnapshot = max(newestSnapshot, seq)
+		hasSnapshot = true
+	}
+
+	for _, f := range job.files {
+		if !f.keepSeqNos {
+			continue
+		}
+		for _, other := range job.files {
+			if other != f && ingestRangesOverlap(f.smallestKey, f.largestKey, other.smallestKey, other.largestKey) {
+				return fmt.Errorf("%w: file %s overlaps ingested file %s", ErrIngestSeqNoConflict, f.externalPath, other.externalPath)
+			}
+		}
+		if overlap := db.ingestFileOverlap(mems, current, f); overlap != "" {
+			return fmt.Errorf("%w: file %s overlaps %s", ErrIngestSeqNoConflict, f.externalPath, overlap)
+		}
+		if job.opts.SnapshotConsistency && hasSnapshot && uint64(newestSnapshot) >= f.smallestSeqNo {
+			return fmt.Errorf("%w: file %s holds sequence numbers %d-%d visible to the snapshot at %d",
+				ErrIngestSeqNoConflict, f.externalPath, f.smallestSeqNo, f.largestSeqNo, newestSnapshot)
+		}
+	}
+	return nil
+}
+
+// ingestMemtables returns the memtables of the column family.
+// REQUIRES: db.mu held.
+func (db *dbImpl) ingestMemtables(cfd *columnFamilyData) []*memtable.MemTable {
+	if cfd.id != DefaultColumnFamilyID {
+		cfd.memMu.RLock()
+		defer cfd.memMu.RUnlock()
+		return append([]*memtable.MemTable{cfd.mem}, cfd.imm...)
+	}
+	return append([]*memtable.MemTable{db.mem}, db.imm...)
+}
+
+// ingestFileOverlap describes the existing data an ingested file overlaps,
+// or returns "" if it overlaps none.
+func (db *dbImpl) ingestFileOverlap(mems []*memtable.MemTable, current *version.Version, f *ingestedFileInfo) string {
+	for _, mem := range mems {
+		if checkMemtableOverlap(mem, []*ingestedFileInfo{f}) {
+			return "the memtable"
+		}
+	}
+	if current != nil {
+		for level := range current.NumLevels() {
+			if db.levelOverlapsFile(current, level, f.smallestKey, f.largestKey) {
+				return fmt.Sprintf("level %d", level)
+			}
+		}
+	}
+	return ""
+}
+
 // checkMemtableOverlap checks if any ingested file overlaps with the memtable.
-func (db *dbImpl) checkMemtableOverlap(files []*ingestedFileInfo) bool {
-	if db.mem == nil || db.mem.ApproximateMemoryUsage() == 0 {
+func checkMemtableOverlap(mem *memtable.MemTable, files []*ingestedFileInfo) bool {
+	if mem == nil || mem.ApproximateMemoryUsage() == 0 {
 		return false
 	}
 
 	// Get memtable key range
-	memSmallest, memLargest := db.getMemtableKeyRange()
+	memSmallest, memLargest := getMemtableKeyRange(mem)
 	if memSmallest == nil || memLargest == nil {
 		return false
 	}
@@ -317,12 +566,8 @@ func (db *dbImpl) checkMemtableOverlap(files []*ingestedFileInfo) bool {
 }
 
 // getMemtableKeyRange returns the smallest and largest user keys in the memtable.
-func (db *dbImpl) getMemtableKeyRange() (smallest, largest []byte) {
-	if db.mem == nil {
-		return nil, nil
-	}
-
-	iter := db.mem.NewIterator()
+func getMemtableKeyRange(mem *memtable.MemTable) (smallest, largest []byte) {
+	iter := mem.NewIterator()
 
 	iter.SeekToFirst()
 	if !iter.Valid() {
@@ -345,28 +590,56 @@ func ingestRangesOverlap(aMin, aMax, bMin, bMax []byte) bool {
 }
 
 // assignGlobalSeqNos assigns global sequence numbers to ingested files.
-func (db *dbImpl) assignGlobalSeqNos(files []*ingestedFileInfo, opts IngestExternalFileOptions) error { //nolint:unparam // Error return kept for API consistency
-	if opts.IngestBehind {
-		// Ingest behind: all files get seqno 0
-		for _, f := range files {
-			f.globalSeqNo = 0
+// Files that keep their own sequence numbers get none; the database's
+// sequence number is advanced past theirs, so that later writes are newer.
+// Without AllowGlobalSeqNo the rest are ingested at sequence number zero,
+// which is only correct if nothing they overlap or any snapshot could see
+// them ordered differently.
+// REQUIRES: db.mu held.
+func (db *dbImpl) assignGlobalSeqNos(job *ingestJob) error {
+	opts := job.opts
+	var assign []*ingestedFileInfo
+	for _, f := range job.files {
+		f.globalSeqNo = 0
+		if f.keepSeqNos {
+			if f.largestSeqNo > atomic.LoadUint64(&db.seq) {
+				atomic.StoreUint64(&db.seq, f.largestSeqNo)
+			}
+			continue
 		}
-		return nil
+		assign = append(assign, f)
 	}
 
-	if !opts.SnapshotConsistency {
-		// No snapshot consistency required
-		for _, f := range files {
-			f.globalSeqNo = 0
+	if !opts.AllowGlobalSeqNo && !opts.IngestBehind && len(assign) > 0 {
+		if opts.SnapshotConsistency && len(db.snapshotSequences()) > 0 {
+			return fmt.Errorf("%w: snapshots exist", ErrIngestGlobalSeqNoRequired)
 		}
-		return nil
+		mems := db.ingestMemtables(job.cfd)
+		current := db.versions.Current()
+		if current != nil {
+			current.Ref()
+			defer current.Unref()
+		}
+		for _, f := range assign {
+			if overlap := db.ingestFileOverlap(mems, current, f); overlap != "" {
+				return fmt.Errorf("%w: file %s overlaps %s", ErrIngestGlobalSeqNoRequired, f.externalPath, overlap)
+			}
+		}
+		assign = nil
 	}
 
-	// Assign sequence numbers in order (newer files get higher seqno)
-	// This ensures that if files overlap, later files will overwrite earlier ones
-	baseSeq := atomic.AddUint64(&db.seq, uint64(len(files)))
-	for i, f := range files {
-		f.globalSeqNo = baseSeq - uint64(len(files)) + uint64(i) + 1
+	// Ingest behind places files under all data with seqno 0, as does
+	// ingestion without snapshot consistency
+	if !opts.IngestBehind && opts.SnapshotConsistency && len(assign) > 0 {
+		// Assign sequence numbers in order (newer files get higher seqno)
+		// This ensures that if files overlap, later files will overwrite earlier ones
+		baseSeq := atomic.AddUint64(&db.seq, uint64(len(assign)))
+		for i, f := range assign {
+			f.globalSeqNo = baseSeq - uint64(len(assign)) + uint64(i) + 1
+		}
+	}
+	for _, f := range assign {
+		f.smallestSeqNo, f.largestSeqNo = f.globalSeqNo, f.globalSeqNo
 	}
 
 	return nil
@@ -384,7 +657,7 @@ func (db *dbImpl) determineTargetLevels(files []*ingestedFileInfo, opts IngestEx
 	}
 	defer current.Unref()
 
-	numLevels := version.MaxNumLevels
+	numLevels := current.NumLevels()
 
 	for _, f := range files {
 		if opts.IngestBehind {
@@ -424,54 +697,156 @@ func (db *dbImpl) levelOverlapsFile(v *version.Version, level int, smallest, lar
 	return false
 }
 
-// installIngestedFiles copies or moves files to the DB directory.
+// installIngestedFiles places files in the DB directory. Files with an
+// assigned global sequence number are rewritten, so that their Merge, Delete
+// and range deletion entries order correctly against existing data. With
+// MoveFiles the rest are hard-linked when possible; otherwise they are copied.
+// Source files are left in place until the ingestion has been committed.
 func (db *dbImpl) installIngestedFiles(files []*ingestedFileInfo, opts IngestExternalFileOptions) error {
 	for _, f := range files {
-		if opts.MoveFiles {
-			// Try to rename (move) the file
-			if err := os.Rename(f.externalPath, f.internalPath); err != nil {
-				// Fall back to copy
-				if err := ingestCopyFile(f.externalPath, f.internalPath); err != nil {
-					return fmt.Errorf("failed to copy file %s: %w", f.externalPath, err)
-				}
-				// Remove original after successful copy
-				os.Remove(f.externalPath)
+		if f.globalSeqNo != 0 {
+			size, err := db.rewriteIngestedFile(f.externalPath, f.internalPath, f.globalSeqNo)
+			if err != nil {
+				return fmt.Errorf("failed to rewrite file %s: %w", f.externalPath, err)
 			}
-		} else {
-			// Copy the file
-			if err := ingestCopyFile(f.externalPath, f.internalPath); err != nil {
-				return fmt.Errorf("failed to copy file %s: %w", f.externalPath, err)
+			f.fileSize = size
+			continue
+		}
+		if opts.MoveFiles {
+			if err := os.Link(f.externalPath, f.internalPath); err == nil {
+				continue
 			}
 		}
+		if err := ingestCopyFile(f.externalPath, f.internalPath); err != nil {
+			return fmt.Errorf("failed to copy file %s: %w", f.externalPath, err)
+		}
 	}
 	return nil
 }
 
-// updateManifestForIngest adds the ingested files to the MANIFEST.
-func (db *dbImpl) updateManifestForIngest(files []*ingestedFileInfo, _ ColumnFamilyHandle) error {
-	edit := manifest.NewVersionEdit()
+// rewriteIngestedFile copies the SST at src to dst, assigning seqNo to every
+// point entry and range tombstone. It returns the size of the new file.
+//
+// Reference: RocksDB v10.7.5 db/external_sst_file_ingestion_job.cc
+// (AssignGlobalSeqnoForIngestedFile, write_global_seqno)
+func (db *dbImpl) rewriteIngestedFile(src, dst string, seqNo uint64) (uint64, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	reader, err := table.Open(&osFileWrapper{f: file, size: stat.Size()}, table.ReaderOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = out.Close() }()
+
+	builderOpts := table.DefaultBuilderOptions()
+	builderOpts.ComparatorName = db.ComparatorName()
+	builder := table.NewTableBuilder(out, builderOpts)
+
+	fail := func(err error) (uint64, error) {
+		builder.Abandon()
+		os.Remove(dst)
+		return 0, err
+	}
+
+	iter := reader.NewIterator()
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		parsed, err := dbformat.ParseInternalKey(iter.Key())
+		if err != nil {
+			return fail(err)
+		}
+		key := dbformat.NewInternalKey(parsed.UserKey, dbformat.SequenceNumber(seqNo), parsed.Type)
+		if err := builder.Add(key, iter.Value()); err != nil {
+			return fail(err)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fail(err)
+	}
+
+	tombstones, err := reader.GetRangeTombstoneList()
+	if err != nil {
+		return fail(err)
+	}
+	for _, t := range tombstones.All() {
+		if err := builder.AddRangeTombstone(t.StartKey, t.EndKey, dbformat.SequenceNumber(seqNo)); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := builder.Finish(); err != nil {
+		return fail(err)
+	}
+	if err := out.Sync(); err != nil {
+		return fail(err)
+	}
+	return builder.FileSize(), nil
+}
+
+// updateManifestForIngest adds the ingested files to the MANIFEST, one edit
+// per column family, logged as a single atomic group.
+func (db *dbImpl) updateManifestForIngest(jobs []*ingestJob) error {
+	edits := make([]*manifest.VersionEdit, 0, len(jobs))
+	lastSeq := manifest.SequenceNumber(db.versions.LastSequence())
 
-	for _, f := range files {
-		// Create internal keys for smallest/largest
-		smallestInternal := dbformat.NewInternalKey(f.smallestKey, dbformat.SequenceNumber(f.globalSeqNo), dbformat.TypeValue)
-		largestInternal := dbformat.NewInternalKey(f.largestKey, dbformat.SequenceNumber(f.globalSeqNo), dbformat.TypeValue)
+	for _, job := range jobs {
+		edit := manifest.NewVersionEdit()
+		if job.cfd.id != DefaultColumnFamilyID {
+			edit.SetColumnFamily(job.cfd.id)
+		}
+
+		for _, f := range job.files {
+			// Create internal keys for smallest/largest, bounding every
+			// version of the smallest and largest user keys
+			smallestInternal := dbformat.NewInternalKey(f.smallestKey, dbformat.SequenceNumber(f.largestSeqNo), dbformat.TypeValue)
+			largestInternal := dbformat.NewInternalKey(f.largestKey, dbformat.SequenceNumber(f.smallestSeqNo), dbformat.TypeValue)
+
+			fileMeta := &manifest.FileMetaData{
+				FD: manifest.FileDescriptor{
+					PackedNumberAndPathID: manifest.PackFileNumberAndPathID(f.fileNumber, 0),
+					FileSize:              f.fileSize,
+					SmallestSeqno:         manifest.SequenceNumber(f.smallestSeqNo),
+					LargestSeqno:          manifest.SequenceNumber(f.largestSeqNo),
+				},
+				Smallest: smallestInternal,
+				Largest:  largestInternal,
+			}
 
-		fileMeta := &manifest.FileMetaData{
-			FD: manifest.FileDescriptor{
-				PackedNumberAndPathID: manifest.PackFileNumberAndPathID(f.fileNumber, 0),
-				FileSize:              f.fileSize,
-				SmallestSeqno:         manifest.SequenceNumber(f.globalSeqNo),
-				LargestSeqno:          manifest.SequenceNumber(f.globalSeqNo),
-			},
-			Smallest: smallestInternal,
-			Largest:  largestInternal,
+			edit.AddFile(f.targetLevel, fileMeta)
+			lastSeq = max(lastSeq, manifest.SequenceNumber(f.largestSeqNo))
 		}
+		edits = append(edits, edit)
+	}
+
+	// Persist the assigned sequence numbers so they are not reused after reopen.
+	last := edits[len(edits)-1]
+	last.HasLastSequence = true
+	last.LastSequence = lastSeq
 
-		edit.AddFile(f.targetLevel, fileMeta)
+	// Apply the edits to the version set
+	if err := db.versions.LogAndApplyAtomicGroup(edits); err != nil {
+		return err
 	}
+	db.versions.SetLastSequence(uint64(lastSeq))
 
-	// Apply the edit to the version set
-	return db.versions.LogAndApply(edit)
+	// Ingested files can be newer than the flushed memtables kept for reads
+	db.immHistory = nil
+	return nil
 }
 
 // ingestCopyFile copies a file from src to dst.
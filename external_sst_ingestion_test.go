@@ -7,6 +7,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -268,6 +269,50 @@ func TestIngestExternalFile_AllowBlockingFlush(t *testing.T) {
 	}
 }
 
+func TestIngestExternalFile_AllowBlockingFlushColumnFamily(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "db")
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dbPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	cf, err := db.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+	if err := db.PutCF(DefaultWriteOptions(), cf, []byte("overlap_key"), []byte("memtable_value")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+
+	sstPath := filepath.Join(tmpDir, "external.sst")
+	createExternalSST(t, sstPath, map[string]string{
+		"overlap_key": "ingested_value",
+	})
+
+	ingestOpts := DefaultIngestExternalFileOptions()
+	ingestOpts.AllowBlockingFlush = false
+	err = db.IngestExternalFiles([]IngestExternalFileArg{{ColumnFamily: cf, ExternalFiles: []string{sstPath}, Options: ingestOpts}})
+	if !errors.Is(err, ErrIngestOverlapMemtable) {
+		t.Fatalf("IngestExternalFiles without blocking flush = %v, want ErrIngestOverlapMemtable", err)
+	}
+
+	// The column family's memtable is flushed so the file lands above it
+	ingestOpts.AllowBlockingFlush = true
+	err = db.IngestExternalFiles([]IngestExternalFileArg{{ColumnFamily: cf, ExternalFiles: []string{sstPath}, Options: ingestOpts}})
+	if err != nil {
+		t.Fatalf("IngestExternalFiles with blocking flush failed: %v", err)
+	}
+	val, err := db.GetCF(DefaultReadOptions(), cf, []byte("overlap_key"))
+	if err != nil || string(val) != "ingested_value" {
+		t.Errorf("GetCF = %q, %v; want ingested_value", val, err)
+	}
+}
+
 // =============================================================================
 // INTEGRATION TESTS: Multiple File Ingestion
 // =============================================================================
@@ -456,10 +501,423 @@ func TestIngestExternalFile_OverwriteExisting(t *testing.T) {
 	}
 }
 
+func TestIngestExternalFile_DeleteMergeAndRangeDelete(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "db")
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+	db, err := Open(dbPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	// Existing data: some flushed, one key left in the memtable
+	wo := DefaultWriteOptions()
+	for k, v := range map[string]string{
+		"del_key":   "old",
+		"merge_key": "base",
+		"range_a1":  "old",
+		"range_a2":  "old",
+		"range_b":   "kept",
+	} {
+		if err := db.Put(wo, []byte(k), []byte(v)); err != nil {
+			t.Fatalf("Put %s failed: %v", k, err)
+		}
+	}
+	if err := db.Flush(DefaultFlushOptions()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := db.Put(wo, []byte("range_a3"), []byte("old")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// External SST carrying every record type
+	sstPath := filepath.Join(tmpDir, "external.sst")
+	writer := NewSstFileWriter(DefaultSstFileWriterOptions())
+	if err := writer.Open(sstPath); err != nil {
+		t.Fatalf("Open SST writer failed: %v", err)
+	}
+	if err := writer.Delete([]byte("del_key")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := writer.Merge([]byte("merge_key"), []byte("ingested")); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if err := writer.Put([]byte("put_key"), []byte("new")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := writer.DeleteRange([]byte("range_a"), []byte("range_b")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if _, err := writer.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	if err := db.IngestExternalFile([]string{sstPath}, DefaultIngestExternalFileOptions()); err != nil {
+		t.Fatalf("IngestExternalFile failed: %v", err)
+	}
+
+	want := map[string]string{
+		"merge_key": "base,ingested",
+		"put_key":   "new",
+		"range_b":   "kept",
+	}
+	for _, k := range []string{"del_key", "range_a1", "range_a2", "range_a3"} {
+		if _, err := db.Get(DefaultReadOptions(), []byte(k)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get %s: expected ErrNotFound, got %v", k, err)
+		}
+	}
+	for k, v := range want {
+		val, err := db.Get(DefaultReadOptions(), []byte(k))
+		if err != nil {
+			t.Errorf("Get %s failed: %v", k, err)
+			continue
+		}
+		if string(val) != v {
+			t.Errorf("Get %s: expected %q, got %q", k, v, val)
+		}
+	}
+
+	// Iteration must skip the deleted keys as well
+	iter := db.NewIterator(DefaultReadOptions())
+	defer iter.Close()
+	var keys []string
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	if got, expected := strings.Join(keys, ","), "merge_key,put_key,range_b"; got != expected {
+		t.Errorf("Iterator keys: expected %s, got %s", expected, got)
+	}
+}
+
+// TestIngestExternalFile_EmbeddedSeqNos ingests an SST flushed by another
+// database, whose entries keep their sequence numbers: the versions of a key
+// stay ordered, later writes are newer, and a file that cannot keep its
+// sequence numbers is rejected.
+func TestIngestExternalFile_GlobalSeqNoNotAllowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	sstOverlap := filepath.Join(tmpDir, "overlap.sst")
+	sstDisjoint := filepath.Join(tmpDir, "disjoint.sst")
+	createExternalSST(t, sstOverlap, map[string]string{"a": "ingested", "c": "ingested"})
+	createExternalSST(t, sstDisjoint, map[string]string{"x": "ingested"})
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(filepath.Join(tmpDir, "db"), opts)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+	if err := db.Put(DefaultWriteOptions(), []byte("b"), []byte("existing")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := db.Flush(DefaultFlushOptions()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	ingestOpts := DefaultIngestExternalFileOptions()
+	ingestOpts.AllowGlobalSeqNo = false
+
+	// A file overlapping existing data needs a global sequence number
+	if err := db.IngestExternalFile([]string{sstOverlap}, ingestOpts); !errors.Is(err, ErrIngestGlobalSeqNoRequired) {
+		t.Errorf("Overlapping file: expected ErrIngestGlobalSeqNoRequired, got %v", err)
+	}
+
+	// So does any file while a snapshot exists
+	snap := db.GetSnapshot()
+	if err := db.IngestExternalFile([]string{sstDisjoint}, ingestOpts); !errors.Is(err, ErrIngestGlobalSeqNoRequired) {
+		t.Errorf("With snapshot: expected ErrIngestGlobalSeqNoRequired, got %v", err)
+	}
+	db.ReleaseSnapshot(snap)
+
+	// A disjoint file is ingested at sequence number zero
+	seq := db.GetLatestSequenceNumber()
+	if err := db.IngestExternalFile([]string{sstDisjoint}, ingestOpts); err != nil {
+		t.Fatalf("Disjoint file: IngestExternalFile failed: %v", err)
+	}
+	if got := db.GetLatestSequenceNumber(); got != seq {
+		t.Errorf("GetLatestSequenceNumber() = %d, want %d", got, seq)
+	}
+	if val, err := db.Get(DefaultReadOptions(), []byte("x")); err != nil || string(val) != "ingested" {
+		t.Errorf("Get(x) = %q, %v, want \"ingested\"", val, err)
+	}
+	if _, err := db.Get(DefaultReadOptions(), []byte("a")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(a) after rejected ingest: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestIngestExternalFile_EmbeddedSeqNos(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+
+	// Export an SST with several versions of each key, kept by snapshots
+	src, err := Open(filepath.Join(tmpDir, "src"), opts)
+	if err != nil {
+		t.Fatalf("Failed to open source DB: %v", err)
+	}
+	wo := DefaultWriteOptions()
+	var snapshots []*Snapshot
+	for _, op := range []func() error{
+		func() error { return src.Put(wo, []byte("k1"), []byte("base")) },
+		func() error { return src.Merge(wo, []byte("k1"), []byte("m1")) },
+		func() error { return src.Merge(wo, []byte("k1"), []byte("m2")) },
+		func() error { return src.Put(wo, []byte("k2"), []byte("v1")) },
+		func() error { return src.Put(wo, []byte("k2"), []byte("v2")) },
+	} {
+		if err := op(); err != nil {
+			t.Fatalf("Write to source DB failed: %v", err)
+		}
+		snapshots = append(snapshots, src.GetSnapshot())
+	}
+	if err := src.Flush(DefaultFlushOptions()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for _, snap := range snapshots {
+		src.ReleaseSnapshot(snap)
+	}
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	ssts, err := filepath.Glob(filepath.Join(tmpDir, "src", "*.sst"))
+	if err != nil || len(ssts) != 1 {
+		t.Fatalf("Expected one SST in the source DB, got %v (%v)", ssts, err)
+	}
+	sstPath := ssts[0]
+	const largestSeqNo = 5
+
+	openDB := func(name string) DB {
+		db, err := Open(filepath.Join(tmpDir, name), opts)
+		if err != nil {
+			t.Fatalf("Failed to open DB: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	scan := func(db DB) string {
+		iter := db.NewIterator(DefaultReadOptions())
+		defer iter.Close()
+		var entries []string
+		for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+			entries = append(entries, string(iter.Key())+"="+string(iter.Value()))
+		}
+		return strings.Join(entries, " ")
+	}
+
+	t.Run("Kept", func(t *testing.T) {
+		db := openDB("kept")
+		if err := db.Put(wo, []byte("other"), []byte("x")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		ingestOpts := DefaultIngestExternalFileOptions()
+		ingestOpts.AllowGlobalSeqNo = false
+		if err := db.IngestExternalFile([]string{sstPath}, ingestOpts); err != nil {
+			t.Fatalf("IngestExternalFile failed: %v", err)
+		}
+
+		if got, want := scan(db), "k1=base,m1,m2 k2=v2 other=x"; got != want {
+			t.Errorf("Scan after ingest = %q, want %q", got, want)
+		}
+		if seq := db.GetLatestSequenceNumber(); seq < largestSeqNo {
+			t.Errorf("GetLatestSequenceNumber() = %d, want at least %d", seq, largestSeqNo)
+		}
+
+		// Later writes are newer than every ingested version
+		if err := db.Put(wo, []byte("k2"), []byte("v3")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := db.Merge(wo, []byte("k1"), []byte("m3")); err != nil {
+			t.Fatalf("Merge failed: %v", err)
+		}
+		if got, want := scan(db), "k1=base,m1,m2,m3 k2=v3 other=x"; got != want {
+			t.Errorf("Scan after writes = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("OverlapRejected", func(t *testing.T) {
+		db := openDB("overlap")
+		if err := db.Put(wo, []byte("k2"), []byte("existing")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := db.Flush(DefaultFlushOptions()); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		liveVersions, _ := db.GetProperty(PropertyNumLiveVersions)
+		for _, allowGlobalSeqNo := range []bool{false, true} {
+			ingestOpts := DefaultIngestExternalFileOptions()
+			ingestOpts.AllowGlobalSeqNo = allowGlobalSeqNo
+			if err := db.IngestExternalFile([]string{sstPath}, ingestOpts); !errors.Is(err, ErrIngestSeqNoConflict) {
+				t.Errorf("AllowGlobalSeqNo=%v: expected ErrIngestSeqNoConflict, got %v", allowGlobalSeqNo, err)
+			}
+		}
+		if got, want := scan(db), "k2=existing"; got != want {
+			t.Errorf("Scan after rejected ingest = %q, want %q", got, want)
+		}
+		// The checks must not drop the current version
+		if got, _ := db.GetProperty(PropertyNumLiveVersions); got != liveVersions {
+			t.Errorf("%s = %s after rejected ingest, want %s", PropertyNumLiveVersions, got, liveVersions)
+		}
+	})
+
+	t.Run("SnapshotRejected", func(t *testing.T) {
+		db := openDB("snapshot")
+		if err := db.Put(wo, []byte("other"), []byte("x")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		snap := db.GetSnapshot()
+		if err := db.IngestExternalFile([]string{sstPath}, DefaultIngestExternalFileOptions()); !errors.Is(err, ErrIngestSeqNoConflict) {
+			t.Errorf("Expected ErrIngestSeqNoConflict with a snapshot, got %v", err)
+		}
+		db.ReleaseSnapshot(snap)
+		if err := db.IngestExternalFile([]string{sstPath}, DefaultIngestExternalFileOptions()); err != nil {
+			t.Fatalf("IngestExternalFile after releasing the snapshot failed: %v", err)
+		}
+		if got, want := scan(db), "k1=base,m1,m2 k2=v2 other=x"; got != want {
+			t.Errorf("Scan after ingest = %q, want %q", got, want)
+		}
+	})
+}
+
 // =============================================================================
 // STRESS TESTS: Concurrent Ingestion
 // =============================================================================
 
+func TestIngestExternalFiles_MultipleColumnFamilies(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "db")
+	sstDefault := filepath.Join(tmpDir, "default.sst")
+	sstCF := filepath.Join(tmpDir, "cf.sst")
+
+	createExternalSST(t, sstDefault, map[string]string{"a1": "default_a1", "a2": "default_a2"})
+	createExternalSST(t, sstCF, map[string]string{"a1": "cf_a1", "b1": "cf_b1"})
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dbPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	cf, err := db.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	err = db.IngestExternalFiles([]IngestExternalFileArg{
+		{ExternalFiles: []string{sstDefault}, Options: DefaultIngestExternalFileOptions()},
+		{ColumnFamily: cf, ExternalFiles: []string{sstCF}, Options: DefaultIngestExternalFileOptions()},
+	})
+	if err != nil {
+		t.Fatalf("IngestExternalFiles failed: %v", err)
+	}
+
+	for k, v := range map[string]string{"a1": "default_a1", "a2": "default_a2"} {
+		val, err := db.Get(DefaultReadOptions(), []byte(k))
+		if err != nil || string(val) != v {
+			t.Errorf("Get %s: expected %q, got %q (err=%v)", k, v, val, err)
+		}
+	}
+	for k, v := range map[string]string{"a1": "cf_a1", "b1": "cf_b1"} {
+		val, err := db.GetCF(DefaultReadOptions(), cf, []byte(k))
+		if err != nil || string(val) != v {
+			t.Errorf("GetCF %s: expected %q, got %q (err=%v)", k, v, val, err)
+		}
+	}
+	if _, err := db.Get(DefaultReadOptions(), []byte("b1")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get b1 from default CF: expected ErrNotFound, got %v", err)
+	}
+
+	// Duplicate column families are rejected.
+	err = db.IngestExternalFiles([]IngestExternalFileArg{
+		{ColumnFamily: cf, ExternalFiles: []string{sstCF}},
+		{ColumnFamily: cf, ExternalFiles: []string{sstCF}},
+	})
+	if err == nil {
+		t.Error("IngestExternalFiles with duplicate column family should fail")
+	}
+}
+
+func TestIngestExternalFiles_FailureRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "db")
+	sstDefault := filepath.Join(tmpDir, "default.sst")
+	sstCF := filepath.Join(tmpDir, "cf.sst")
+	badSST := filepath.Join(tmpDir, "bad.sst")
+
+	createExternalSST(t, sstDefault, map[string]string{"a1": "default_a1"})
+	createExternalSST(t, sstCF, map[string]string{"m1": "cf_m1"})
+	if err := os.WriteFile(badSST, []byte("not an sst file"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dbPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to open DB: %v", err)
+	}
+	defer db.Close()
+
+	cf, err := db.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	countSSTs := func() int {
+		matches, _ := filepath.Glob(filepath.Join(dbPath, "*.sst"))
+		return len(matches)
+	}
+	before := countSSTs()
+
+	moveOpts := DefaultIngestExternalFileOptions()
+	moveOpts.MoveFiles = true
+
+	// A corrupt file in the second column family fails the whole ingestion.
+	err = db.IngestExternalFiles([]IngestExternalFileArg{
+		{ExternalFiles: []string{sstDefault}, Options: moveOpts},
+		{ColumnFamily: cf, ExternalFiles: []string{badSST}, Options: moveOpts},
+	})
+	if err == nil {
+		t.Fatal("IngestExternalFiles with a corrupt file should fail")
+	}
+
+	// A memtable overlap in the second column family that may not be
+	// flushed fails it too.
+	if err := db.PutCF(DefaultWriteOptions(), cf, []byte("m1"), []byte("memtable")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+	noFlushOpts := moveOpts
+	noFlushOpts.AllowBlockingFlush = false
+	err = db.IngestExternalFiles([]IngestExternalFileArg{
+		{ExternalFiles: []string{sstDefault}, Options: moveOpts},
+		{ColumnFamily: cf, ExternalFiles: []string{sstCF}, Options: noFlushOpts},
+	})
+	if !errors.Is(err, ErrIngestOverlapMemtable) {
+		t.Fatalf("expected ErrIngestOverlapMemtable, got %v", err)
+	}
+
+	if _, err := db.Get(DefaultReadOptions(), []byte("a1")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get a1: expected ErrNotFound after failed ingestion, got %v", err)
+	}
+	val, err := db.GetCF(DefaultReadOptions(), cf, []byte("m1"))
+	if err != nil || string(val) != "memtable" {
+		t.Errorf("GetCF m1: expected %q, got %q (err=%v)", "memtable", val, err)
+	}
+	if after := countSSTs(); after != before {
+		t.Errorf("expected %d SST files in DB dir after failed ingestion, got %d", before, after)
+	}
+	for _, path := range []string{sstDefault, sstCF, badSST} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("external file %s should remain after failed ingestion: %v", path, err)
+		}
+	}
+}
+
 func TestIngestExternalFile_Concurrent(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping concurrent test in short mode")
@@ -16,9 +16,11 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
 
 	"github.com/aalhour/rockyardkv/internal/flush"
 	"github.com/aalhour/rockyardkv/internal/manifest"
+	"github.com/aalhour/rockyardkv/internal/memtable"
 	"github.com/aalhour/rockyardkv/internal/testutil"
 	"github.com/aalhour/rockyardkv/vfs"
 )
@@ -51,6 +53,11 @@ func (db *dbImpl) ComparatorName() string {
 	return db.comparator.Name()
 }
 
+// NowMicros implements flush.DB.
+func (db *dbImpl) NowMicros() uint64 {
+	return db.env.NowMicros()
+}
+
 // sstFilePath returns the path to an SST file.
 func (db *dbImpl) sstFilePath(number uint64) string {
 	return filepath.Join(db.name, sstFileName(number))
@@ -61,28 +68,47 @@ func sstFileName(number uint64) string {
 	return fmt.Sprintf("%06d.sst", number)
 }
 
-// doFlush performs the actual flush of the immutable memtable.
+// doFlush flushes all immutable memtables to a single L0 file.
 // This is called from the background flush goroutine or synchronously.
 func (db *dbImpl) doFlush() error {
 	// Whitebox [synctest]: barrier at doFlush start
 	_ = testutil.SP(testutil.SPDoFlushStart)
 
+	db.flushMu.Lock()
+	defer db.flushMu.Unlock()
+
 	db.mu.Lock()
-	if db.imm == nil {
+	if len(db.imm) == 0 {
 		db.mu.Unlock()
 		return nil // Nothing to flush
 	}
-	imm := db.imm
+	imms := db.imm
+	compressionType := db.options.Compression
+	prefixExtractor := db.options.PrefixExtractor
+	filterBitsPerKey := db.options.BloomFilterBitsPerKey
+	verifyCompression := db.options.VerifyCompression
+	blobOpts := db.blobOptions()
 	db.mu.Unlock()
 
+	db.logger.Infof("[flush] flushing %d memtables", len(imms))
+
 	// Create and run the flush job
-	job := flush.NewJob(db, imm)
+	job := flush.NewJob(db, imms...)
+	job.SetCompression(compressionType)
+	job.SetFilterBitsPerKey(filterBitsPerKey)
+	job.SetVerifyCompression(verifyCompression)
+	if prefixExtractor != nil {
+		job.SetPrefixExtractor(prefixExtractor.Name(), filterPrefix(prefixExtractor))
+	}
+	if blobOpts != nil {
+		job.SetBlobOptions(*blobOpts)
+	}
 	meta, err := job.Run()
 	if err != nil {
 		if errors.Is(err, flush.ErrNoOutput) {
 			// Empty flush is a no-op but still clears the immutable memtable.
 			db.mu.Lock()
-			db.imm = nil
+			db.removeFlushedMemTables(imms)
 			if db.immCond != nil {
 				db.immCond.Broadcast()
 			}
@@ -105,10 +131,10 @@ func (db *dbImpl) doFlush() error {
 		return err
 	}
 
-	// If the memtable was empty, just clear the immutable memtable
+	// If the memtables were empty, just clear them
 	if meta == nil {
 		db.mu.Lock()
-		db.imm = nil
+		db.removeFlushedMemTables(imms)
 		// Signal any waiters that immutable memtable is now available
 		if db.immCond != nil {
 			db.immCond.Broadcast()
@@ -156,6 +182,20 @@ func (db *dbImpl) doFlush() error {
 		Meta:  meta,
 	})
 
+	// Once the memtable replayed from older WALs is flushed, those WALs hold
+	// no unflushed records: LogNumber moves to the current WAL so that they
+	// can be recycled or archived. A WAL that holds an unresolved prepared
+	// transaction stays live, so that it is found again after a crash.
+	if db.recoveredMem != nil && slices.Contains(imms, db.recoveredMem) {
+		edit.HasLogNumber = true
+		edit.LogNumber = db.logFileNumber
+		if db.minLogWithPrep != nil {
+			if logNum := db.minLogWithPrep(); logNum != 0 && logNum < edit.LogNumber {
+				edit.LogNumber = logNum
+			}
+		}
+	}
+
 	// Whitebox [crashtest]: crash before manifest update — SST orphaned
 	testutil.MaybeKill(testutil.KPFlushUpdateManifest0)
 
@@ -168,12 +208,22 @@ func (db *dbImpl) doFlush() error {
 	// CRITICAL: LogAndApply writes to MANIFEST but doesn't update in-memory lastSequence.
 	// We must update it here to ensure subsequent flushes use the correct base value.
 	db.versions.SetLastSequence(uint64(newLastSeq))
+	if edit.HasLogNumber {
+		// Likewise for LogNumber, so that the WAL files made obsolete can be
+		// archived before the next open.
+		db.versions.SetLogNumber(edit.LogNumber)
+		db.recoveredMem = nil
+	}
+	db.registerBlobFiles(job.BlobFiles())
 
 	// Whitebox [crashtest]: crash after manifest update — flush complete
 	testutil.MaybeKill(testutil.KPFlushUpdateManifest1)
 
-	// Clear the immutable memtable
-	db.imm = nil
+	// Clear the immutable memtables, keeping them for reads if configured
+	for i := len(imms) - 1; i >= 0; i-- {
+		db.maintainFlushedMemTable(imms[i])
+	}
+	db.removeFlushedMemTables(imms)
 
 	// Signal any waiters that immutable memtable is now available
 	if db.immCond != nil {
@@ -185,9 +235,150 @@ func (db *dbImpl) doFlush() error {
 
 	db.mu.Unlock()
 
+	db.logger.Infof("[flush] flushed %d memtables to L0 file %d (%d bytes)",
+		len(imms), meta.FD.GetNumber(), meta.FD.FileSize)
+	return nil
+}
+
+// flushColumnFamily flushes the memtable of a column family other than the
+// default one to an L0 file, waiting for the flush to finish.
+//
+// Like the default column family's flush, it does not advance LogNumber:
+// the shared WAL stays live until the next open, and replaying it then only
+// re-adds entries the flushed file already holds, with the same sequence
+// numbers.
+func (db *dbImpl) flushColumnFamily(cfd *columnFamilyData) error {
+	db.flushMu.Lock()
+	defer db.flushMu.Unlock()
+
+	// Writers look the memtable up under db.mu, so swapping it under db.mu
+	// sends later writes to the new one
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrDBClosed
+	}
+	if db.backgroundError != nil {
+		err := fmt.Errorf("%w: %w", ErrBackgroundError, db.backgroundError)
+		db.mu.Unlock()
+		return err
+	}
+	cfd.memMu.Lock()
+	mem := cfd.mem
+	if mem == nil || mem.Empty() {
+		cfd.memMu.Unlock()
+		db.mu.Unlock()
+		return nil
+	}
+	cfd.imm = append([]*memtable.MemTable{mem}, cfd.imm...)
+	cfd.mem = cfd.newMemTable()
+	cfd.memMu.Unlock()
+	compressionType := db.options.Compression
+	prefixExtractor := db.options.PrefixExtractor
+	filterBitsPerKey := db.options.BloomFilterBitsPerKey
+	verifyCompression := db.options.VerifyCompression
+	blobOpts := db.blobOptions()
+	db.mu.Unlock()
+
+	removeImm := func() {
+		cfd.memMu.Lock()
+		cfd.imm = slices.DeleteFunc(cfd.imm, func(m *memtable.MemTable) bool { return m == mem })
+		cfd.memMu.Unlock()
+	}
+
+	job := flush.NewJob(db, mem)
+	job.SetColumnFamily(cfd.id, cfd.name)
+	job.SetCompression(compressionType)
+	job.SetFilterBitsPerKey(filterBitsPerKey)
+	job.SetVerifyCompression(verifyCompression)
+	if prefixExtractor != nil {
+		job.SetPrefixExtractor(prefixExtractor.Name(), filterPrefix(prefixExtractor))
+	}
+	if blobOpts != nil {
+		job.SetBlobOptions(*blobOpts)
+	}
+	meta, err := job.Run()
+	if errors.Is(err, flush.ErrNoOutput) {
+		removeImm()
+		return nil
+	}
+	if err != nil {
+		// The memtable stays in cfd.imm, so its entries remain readable
+		db.mu.Lock()
+		if db.backgroundError == nil {
+			db.backgroundError = err
+		}
+		db.mu.Unlock()
+		db.logger.Warnf("[flush] flush of column family %q failed: %v", cfd.name, err)
+		return err
+	}
+	meta.ColumnFamilyID = cfd.id
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	edit := manifest.NewVersionEdit()
+	edit.SetColumnFamily(cfd.id)
+	edit.AddFile(0, meta)
+	edit.HasLastSequence = true
+	edit.LastSequence = max(meta.FD.LargestSeqno, manifest.SequenceNumber(db.versions.LastSequence()))
+	if err := db.versions.LogAndApply(edit); err != nil {
+		return fmt.Errorf("failed to log version edit: %w", err)
+	}
+	db.versions.SetLastSequence(uint64(edit.LastSequence))
+	db.registerBlobFiles(job.BlobFiles())
+	removeImm()
+
+	db.logger.Infof("[flush] flushed column family %q to L0 file %d (%d bytes)",
+		cfd.name, meta.FD.GetNumber(), meta.FD.FileSize)
 	return nil
 }
 
+// removeFlushedMemTables removes the flushed memtables, the oldest in
+// db.imm, leaving those sealed while the flush ran.
+// REQUIRES: db.mu is held.
+func (db *dbImpl) removeFlushedMemTables(flushed []*memtable.MemTable) {
+	db.imm = db.imm[:len(db.imm)-len(flushed)]
+	if len(db.imm) == 0 {
+		db.imm = nil
+	}
+}
+
+// minWriteBufferNumberToMerge returns the number of immutable memtables that
+// a background flush waits for, which leaves room for the active memtable
+// within MaxWriteBufferNumber.
+func (db *dbImpl) minWriteBufferNumberToMerge() int {
+	return max(min(db.options.MinWriteBufferNumberToMerge, db.maxImmutableMemTables()), 1)
+}
+
+// maxImmutableMemTables returns the number of immutable memtables that can
+// wait for a flush before Flush blocks.
+func (db *dbImpl) maxImmutableMemTables() int {
+	return max(db.options.MaxWriteBufferNumber-1, 1)
+}
+
+// maintainFlushedMemTable adds a flushed memtable to db.immHistory, then
+// frees the oldest kept memtables until the history fits in
+// MaxWriteBufferSizeToMaintain.
+// REQUIRES: db.mu is held.
+func (db *dbImpl) maintainFlushedMemTable(mem *memtable.MemTable) {
+	budget := db.options.MaxWriteBufferSizeToMaintain
+	if budget <= 0 {
+		db.immHistory = nil
+		return
+	}
+
+	history := append([]*memtable.MemTable{mem}, db.immHistory...)
+	var size int64
+	for i, m := range history {
+		size += int64(m.ApproximateMemoryUsage())
+		if size > budget {
+			history = history[:i]
+			break
+		}
+	}
+	db.immHistory = history
+}
+
 // backgroundFlush runs in a goroutine to handle flush requests.
 //
 //nolint:unused // Reserved for future use when background flush scheduling is implemented
@@ -199,7 +390,7 @@ func (db *dbImpl) backgroundFlush() {
 		default:
 			// Check if there's an immutable memtable to flush
 			db.mu.RLock()
-			hasImm := db.imm != nil
+			hasImm := len(db.imm) > 0
 			db.mu.RUnlock()
 
 			if hasImm {
@@ -4,6 +4,7 @@ package rockyardkv
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -280,3 +281,174 @@ func TestFlushAndDelete(t *testing.T) {
 		}
 	}
 }
+
+// TestFlushMaintainsMemTables verifies that flushed memtables kept by
+// MaxWriteBufferSizeToMaintain serve snapshot reads without the SST file.
+func TestFlushMaintainsMemTables(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		budget   int64
+		wantKept uint64
+	}{
+		{"maintained", 64 << 20, 1},
+		{"over budget", 1, 0},
+		{"disabled", 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			opts.MaxWriteBufferSizeToMaintain = tc.budget
+
+			database, err := Open(t.TempDir(), opts)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer database.Close()
+
+			if err := database.Put(nil, []byte("key"), []byte("v1")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			snap := database.GetSnapshot()
+			defer database.ReleaseSnapshot(snap)
+			if err := database.Put(nil, []byte("key"), []byte("v2")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := database.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+
+			kept, _ := database.GetIntProperty(PropertyNumImmutableMemTableFlushed)
+			if kept != tc.wantKept {
+				t.Errorf("%s = %d, want %d", PropertyNumImmutableMemTableFlushed, kept, tc.wantKept)
+			}
+
+			// Take the flushed SST away so only a kept memtable can answer
+			impl := database.(*dbImpl)
+			for _, f := range database.GetLiveFilesMetaData() {
+				impl.tableCache.Evict(f.FileNumber)
+				if err := os.Remove(filepath.Join(f.Directory, f.Name)); err != nil {
+					t.Fatalf("Remove failed: %v", err)
+				}
+			}
+
+			readOpts := DefaultReadOptions()
+			readOpts.Snapshot = snap
+			value, err := database.Get(readOpts, []byte("key"))
+			if tc.wantKept == 0 {
+				if err == nil {
+					t.Errorf("Get at snapshot = %q, want an error without the SST", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get at snapshot failed: %v", err)
+			}
+			if string(value) != "v1" {
+				t.Errorf("Get at snapshot = %q, want v1", value)
+			}
+			if value, err := database.Get(nil, []byte("key")); err != nil || string(value) != "v2" {
+				t.Errorf("Get = %q, %v, want v2", value, err)
+			}
+		})
+	}
+}
+
+// TestFlushMergesMemTables verifies that background flushes wait for
+// MinWriteBufferNumberToMerge immutable memtables and write them to a single
+// L0 file.
+func TestFlushMergesMemTables(t *testing.T) {
+	for _, tc := range []struct {
+		minToMerge int
+		wantFiles  int
+	}{
+		{1, 2},
+		{2, 1},
+	} {
+		t.Run(fmt.Sprintf("min=%d", tc.minToMerge), func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			opts.MaxWriteBufferNumber = 3
+			opts.MinWriteBufferNumberToMerge = tc.minToMerge
+
+			database, err := Open(t.TempDir(), opts)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer database.Close()
+
+			noWait := &FlushOptions{Wait: false}
+			for i, value := range []string{"v1", "v2"} {
+				if err := database.Put(nil, []byte("key"), []byte(value)); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+				if err := database.Put(nil, fmt.Appendf(nil, "key%d", i), []byte(value)); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+				if err := database.Flush(noWait); err != nil {
+					t.Fatalf("Flush failed: %v", err)
+				}
+				if err := database.WaitForCompact(nil); err != nil {
+					t.Fatalf("WaitForCompact failed: %v", err)
+				}
+			}
+
+			if n, _ := database.GetIntProperty(PropertyNumImmutableMemTable); n != 0 {
+				t.Errorf("%s = %d, want 0", PropertyNumImmutableMemTable, n)
+			}
+			if n, _ := database.GetIntProperty(PropertyNumFilesAtLevelPrefix + "0"); n != uint64(tc.wantFiles) {
+				t.Errorf("L0 files = %d, want %d", n, tc.wantFiles)
+			}
+
+			for key, want := range map[string]string{"key": "v2", "key0": "v1", "key1": "v2"} {
+				value, err := database.Get(nil, []byte(key))
+				if err != nil {
+					t.Fatalf("Get(%s) failed: %v", key, err)
+				}
+				if string(value) != want {
+					t.Errorf("Get(%s) = %q, want %q", key, value, want)
+				}
+			}
+		})
+	}
+}
+
+// TestFlushWritesPendingMemTables verifies that a waiting Flush writes the
+// immutable memtables held back by MinWriteBufferNumberToMerge.
+func TestFlushWritesPendingMemTables(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MaxWriteBufferNumber = 3
+	opts.MinWriteBufferNumberToMerge = 2
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Flush(&FlushOptions{Wait: false}); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := database.WaitForCompact(nil); err != nil {
+		t.Fatalf("WaitForCompact failed: %v", err)
+	}
+	if n, _ := database.GetIntProperty(PropertyNumImmutableMemTable); n != 1 {
+		t.Fatalf("%s = %d, want 1", PropertyNumImmutableMemTable, n)
+	}
+	if value, err := database.Get(nil, []byte("key")); err != nil || string(value) != "value" {
+		t.Errorf("Get = %q, %v, want value", value, err)
+	}
+
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n, _ := database.GetIntProperty(PropertyNumImmutableMemTable); n != 0 {
+		t.Errorf("%s = %d, want 0", PropertyNumImmutableMemTable, n)
+	}
+	if n, _ := database.GetIntProperty(PropertyNumFilesAtLevelPrefix + "0"); n != 1 {
+		t.Errorf("L0 files = %d, want 1", n)
+	}
+}
@@ -0,0 +1,197 @@
+package rockyardkv
+
+// import_column_family.go implements creating a column family from SST files
+// exported by Checkpoint.ExportColumnFamily.
+//
+// Reference: RocksDB v10.7.5
+//   - db/import_column_family_job.h
+//   - db/import_column_family_job.cc
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aalhour/rockyardkv/internal/manifest"
+)
+
+// ErrImportInvalidMetadata is returned when import metadata is missing,
+// inconsistent, or incompatible with the database.
+var ErrImportInvalidMetadata = errors.New("db: invalid import metadata")
+
+// ImportColumnFamilyOptions controls CreateColumnFamilyWithImport.
+// This matches the C++ RocksDB ImportColumnFamilyOptions structure.
+type ImportColumnFamilyOptions struct {
+	// MoveFiles moves the exported files into the database instead of
+	// copying them. Files are hard-linked when possible.
+	MoveFiles bool
+}
+
+// importedFile tracks one exported file while it is imported.
+type importedFile struct {
+	src          string
+	meta         LiveFileMetaData
+	fileNumber   uint64
+	internalPath string
+	fileSize     uint64
+}
+
+// CreateColumnFamilyWithImport creates a column family named name and
+// populates it with the SST files described by metadata, placing each file at
+// its exported level without rewriting its data. The files must have been
+// produced with the same comparator as this database.
+//
+// On failure the column family is dropped and no files are left behind.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/db.h (DB::CreateColumnFamilyWithImport)
+//   - db/db_impl/db_impl.cc (DBImpl::CreateColumnFamilyWithImport)
+func (db *dbImpl) CreateColumnFamilyWithImport(opts ColumnFamilyOptions, name string, importOpts ImportColumnFamilyOptions, metadata *ExportImportFilesMetaData) (ColumnFamilyHandle, error) {
+	files, err := db.prepareImportFiles(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := db.CreateColumnFamily(opts, name)
+	if err != nil {
+		return nil, err
+	}
+	cfd := handle.(*columnFamilyHandle).cfd
+
+	if err := db.importColumnFamilyFiles(cfd, files, importOpts); err != nil {
+		_ = db.DropColumnFamily(handle) // Best-effort rollback
+		return nil, err
+	}
+
+	if importOpts.MoveFiles {
+		for _, f := range files {
+			os.Remove(f.src)
+		}
+	}
+
+	db.logger.Infof("[cf] imported %d SST files into column family %q", len(files), name)
+	return handle, nil
+}
+
+// prepareImportFiles validates metadata and returns the files to import,
+// ordered by level; L0 files are ordered by original file number so that L0
+// keeps its newest-last order, and files below L0 by smallest key.
+func (db *dbImpl) prepareImportFiles(metadata *ExportImportFilesMetaData) ([]*importedFile, error) {
+	if metadata == nil {
+		return nil, fmt.Errorf("%w: metadata is nil", ErrImportInvalidMetadata)
+	}
+	if metadata.DBComparatorName != db.comparator.Name() {
+		return nil, fmt.Errorf("%w: comparator %q does not match %q",
+			ErrImportInvalidMetadata, metadata.DBComparatorName, db.comparator.Name())
+	}
+
+	files := make([]*importedFile, 0, len(metadata.Files))
+	for _, m := range metadata.Files {
+		if m.Level < 0 || m.Level >= db.versions.NumLevels() {
+			return nil, fmt.Errorf("%w: file %s has invalid level %d", ErrImportInvalidMetadata, m.Name, m.Level)
+		}
+		src := filepath.Join(m.Directory, m.Name)
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, fmt.Errorf("db: failed to stat import file: %w", err)
+		}
+		files = append(files, &importedFile{src: src, meta: m, fileSize: uint64(info.Size())})
+	}
+
+	slices.SortFunc(files, func(a, b *importedFile) int {
+		if c := cmp.Compare(a.meta.Level, b.meta.Level); c != 0 {
+			return c
+		}
+		if a.meta.Level > 0 {
+			if c := db.comparator.Compare(ingestExtractUserKey(a.meta.SmallestKey), ingestExtractUserKey(b.meta.SmallestKey)); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.meta.FileNumber, b.meta.FileNumber)
+	})
+
+	// Files below L0 must not overlap within their level. Sorted by smallest
+	// key, if any two files of a level overlap then some file overlaps the
+	// one before it.
+	for i := 1; i < len(files); i++ {
+		prev, cur := files[i-1].meta, files[i].meta
+		if cur.Level == 0 || prev.Level != cur.Level {
+			continue
+		}
+		if db.comparator.Compare(ingestExtractUserKey(prev.LargestKey), ingestExtractUserKey(cur.SmallestKey)) >= 0 {
+			return nil, fmt.Errorf("%w: files %s and %s overlap in level %d",
+				ErrImportInvalidMetadata, prev.Name, cur.Name, cur.Level)
+		}
+	}
+
+	return files, nil
+}
+
+// importColumnFamilyFiles places files in the DB directory and adds them to
+// cfd with a single MANIFEST edit.
+func (db *dbImpl) importColumnFamilyFiles(cfd *columnFamilyData, files []*importedFile, importOpts ImportColumnFamilyOptions) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrDBClosed
+	}
+
+	removeInstalled := func() {
+		for _, f := range files {
+			if f.internalPath != "" {
+				os.Remove(f.internalPath)
+			}
+		}
+	}
+
+	edit := manifest.NewVersionEdit()
+	edit.SetColumnFamily(cfd.id)
+	lastSeq := manifest.SequenceNumber(db.versions.LastSequence())
+
+	for _, f := range files {
+		f.fileNumber = db.versions.NextFileNumber()
+		dst := db.sstFilePath(f.fileNumber)
+
+		install := copyFile
+		if importOpts.MoveFiles {
+			install = linkOrCopy
+		}
+		if err := install(f.src, dst); err != nil {
+			removeInstalled()
+			return fmt.Errorf("db: failed to import file %s: %w", f.src, err)
+		}
+		f.internalPath = dst
+
+		edit.AddFile(f.meta.Level, &manifest.FileMetaData{
+			FD: manifest.FileDescriptor{
+				PackedNumberAndPathID: manifest.PackFileNumberAndPathID(f.fileNumber, 0),
+				FileSize:              f.fileSize,
+				SmallestSeqno:         manifest.SequenceNumber(f.meta.SmallestSeqno),
+				LargestSeqno:          manifest.SequenceNumber(f.meta.LargestSeqno),
+			},
+			Smallest: append([]byte(nil), f.meta.SmallestKey...),
+			Largest:  append([]byte(nil), f.meta.LargestKey...),
+		})
+		lastSeq = max(lastSeq, manifest.SequenceNumber(f.meta.LargestSeqno))
+	}
+
+	// Imported entries keep their sequence numbers, so they must not be
+	// newer than what this database considers visible
+	edit.HasLastSequence = true
+	edit.LastSequence = lastSeq
+
+	if err := db.versions.LogAndApply(edit); err != nil {
+		removeInstalled()
+		return err
+	}
+	db.versions.SetLastSequence(uint64(lastSeq))
+	if uint64(lastSeq) > db.seq {
+		db.seq = uint64(lastSeq)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package rockyardkv
+
+// import_column_family_test.go implements tests for column family export/import.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// collectCF returns all key/value pairs of a column family in iteration order.
+func collectCF(t *testing.T, database DB, cf ColumnFamilyHandle) []string {
+	t.Helper()
+	iter := database.NewIteratorCF(nil, cf)
+	defer iter.Close()
+	var kvs []string
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		kvs = append(kvs, string(iter.Key())+"="+string(iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("iterator error: %v", err)
+	}
+	return kvs
+}
+
+func TestExportImportColumnFamily(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	src, err := Open(filepath.Join(tmpDir, "src"), opts)
+	if err != nil {
+		t.Fatalf("Failed to open source DB: %v", err)
+	}
+	defer src.Close()
+
+	cf, err := src.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	// Populate the column family from both an SST and its memtable.
+	sstPath := filepath.Join(tmpDir, "cf1.sst")
+	sstData := make(map[string]string)
+	for i := range 50 {
+		sstData[fmt.Sprintf("key%03d", i)] = fmt.Sprintf("sst%d", i)
+	}
+	createExternalSST(t, sstPath, sstData)
+	err = src.IngestExternalFiles([]IngestExternalFileArg{
+		{ColumnFamily: cf, ExternalFiles: []string{sstPath}, Options: DefaultIngestExternalFileOptions()},
+	})
+	if err != nil {
+		t.Fatalf("IngestExternalFiles failed: %v", err)
+	}
+	for i := 25; i < 75; i++ {
+		if err := src.PutCF(nil, cf, fmt.Appendf(nil, "key%03d", i), fmt.Appendf(nil, "mem%d", i)); err != nil {
+			t.Fatalf("PutCF failed: %v", err)
+		}
+	}
+	if err := src.DeleteCF(nil, cf, []byte("key010")); err != nil {
+		t.Fatalf("DeleteCF failed: %v", err)
+	}
+	if err := src.Put(nil, []byte("default-only"), []byte("v")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	want := collectCF(t, src, cf)
+	if len(want) != 74 {
+		t.Fatalf("source column family has %d keys, want 74", len(want))
+	}
+
+	cp, err := NewCheckpoint(src)
+	if err != nil {
+		t.Fatalf("NewCheckpoint failed: %v", err)
+	}
+	exportDir := filepath.Join(tmpDir, "export")
+	metadata, err := cp.ExportColumnFamily(cf, exportDir)
+	if err != nil {
+		t.Fatalf("ExportColumnFamily failed: %v", err)
+	}
+	if len(metadata.Files) != 2 {
+		t.Errorf("exported %d files, want 2 (ingested SST and memtable)", len(metadata.Files))
+	}
+	if _, err := cp.ExportColumnFamily(cf, exportDir); err == nil {
+		t.Error("ExportColumnFamily into an existing directory should fail")
+	}
+
+	dst, err := Open(filepath.Join(tmpDir, "dst"), opts)
+	if err != nil {
+		t.Fatalf("Failed to open destination DB: %v", err)
+	}
+	defer dst.Close()
+
+	imported, err := dst.CreateColumnFamilyWithImport(DefaultColumnFamilyOptions(), "imported", ImportColumnFamilyOptions{}, metadata)
+	if err != nil {
+		t.Fatalf("CreateColumnFamilyWithImport failed: %v", err)
+	}
+
+	if got := collectCF(t, dst, imported); !slices.Equal(got, want) {
+		t.Errorf("imported column family differs from source:\n got %v\nwant %v", got, want)
+	}
+	if _, err := dst.GetCF(nil, imported, []byte("key010")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCF key010: expected ErrNotFound, got %v", err)
+	}
+	if _, err := dst.GetCF(nil, imported, []byte("default-only")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCF default-only: expected ErrNotFound, got %v", err)
+	}
+	if got := collectCF(t, dst, nil); len(got) != 0 {
+		t.Errorf("destination default column family has keys %v, want none", got)
+	}
+
+	// Writes after the import must not be shadowed by imported sequence numbers.
+	if err := dst.PutCF(nil, imported, []byte("key030"), []byte("new")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+	val, err := dst.GetCF(nil, imported, []byte("key030"))
+	if err != nil || string(val) != "new" {
+		t.Errorf("GetCF key030 = %q, %v; want %q", val, err, "new")
+	}
+}
+
+func TestExportImportDefaultColumnFamily(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	src, err := Open(filepath.Join(tmpDir, "src"), opts)
+	if err != nil {
+		t.Fatalf("Failed to open source DB: %v", err)
+	}
+	defer src.Close()
+
+	for i := range 100 {
+		if err := src.Put(nil, fmt.Appendf(nil, "key%03d", i), fmt.Appendf(nil, "value%d", i)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if i == 49 {
+			if err := src.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+	}
+	want := collectCF(t, src, nil)
+
+	cp, err := NewCheckpoint(src)
+	if err != nil {
+		t.Fatalf("NewCheckpoint failed: %v", err)
+	}
+	metadata, err := cp.ExportColumnFamily(nil, filepath.Join(tmpDir, "export"))
+	if err != nil {
+		t.Fatalf("ExportColumnFamily failed: %v", err)
+	}
+
+	dst, err := Open(filepath.Join(tmpDir, "dst"), opts)
+	if err != nil {
+		t.Fatalf("Failed to open destination DB: %v", err)
+	}
+	defer dst.Close()
+
+	// A comparator mismatch is rejected without creating the column family.
+	bad := *metadata
+	bad.DBComparatorName = "some.other.Comparator"
+	if _, err := dst.CreateColumnFamilyWithImport(DefaultColumnFamilyOptions(), "imported", ImportColumnFamilyOptions{}, &bad); !errors.Is(err, ErrImportInvalidMetadata) {
+		t.Fatalf("expected ErrImportInvalidMetadata, got %v", err)
+	}
+	if slices.Contains(dst.ListColumnFamilies(), "imported") {
+		t.Error("failed import left column family behind")
+	}
+
+	imported, err := dst.CreateColumnFamilyWithImport(DefaultColumnFamilyOptions(), "imported", ImportColumnFamilyOptions{MoveFiles: true}, metadata)
+	if err != nil {
+		t.Fatalf("CreateColumnFamilyWithImport failed: %v", err)
+	}
+	if got := collectCF(t, dst, imported); !slices.Equal(got, want) {
+		t.Errorf("imported column family has %d keys, want %d", len(got), len(want))
+	}
+}
+
+func TestImportColumnFamilyRejectsOverlapBetweenNonNeighbours(t *testing.T) {
+	tmpDir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	database, err := Open(filepath.Join(tmpDir, "db"), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	// In file number order no two neighbours overlap, but the first and
+	// last files do
+	exportDir := filepath.Join(tmpDir, "export")
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	internalKey := func(userKey string) []byte {
+		return append([]byte(userKey), make([]byte, 8)...)
+	}
+	metadata := &ExportImportFilesMetaData{DBComparatorName: database.(*dbImpl).comparator.Name()}
+	for i, r := range [][2]string{{"a", "c"}, {"m", "n"}, {"b", "b"}} {
+		name := fmt.Sprintf("%06d.sst", i+1)
+		if err := os.WriteFile(filepath.Join(exportDir, name), []byte("sst"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		metadata.Files = append(metadata.Files, LiveFileMetaData{
+			Name:        name,
+			Directory:   exportDir,
+			FileNumber:  uint64(i + 1),
+			Level:       1,
+			SmallestKey: internalKey(r[0]),
+			LargestKey:  internalKey(r[1]),
+		})
+	}
+
+	_, err = database.CreateColumnFamilyWithImport(DefaultColumnFamilyOptions(), "imported", ImportColumnFamilyOptions{}, metadata)
+	if !errors.Is(err, ErrImportInvalidMetadata) {
+		t.Fatalf("CreateColumnFamilyWithImport = %v, want ErrImportInvalidMetadata", err)
+	}
+	if slices.Contains(database.ListColumnFamilies(), "imported") {
+		t.Error("failed import left column family behind")
+	}
+}
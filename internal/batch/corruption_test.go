@@ -6,6 +6,7 @@ package batch
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -378,3 +379,21 @@ func TestAppendBatch(t *testing.T) {
 func padKey(i int) string {
 	return string([]byte{byte('a' + i/26), byte('a' + i%26), byte('0' + i%10)})
 }
+
+// TestValidateCount tests that Validate checks the header count against the
+// records, ignoring records that are not counted.
+func TestValidateCount(t *testing.T) {
+	wb := New()
+	wb.Put([]byte("key1"), []byte("value1"))
+	wb.PutLogData([]byte("blob"))
+	wb.DeleteCF(1, []byte("key2"))
+	wb.PutEntity([]byte("key3"), []byte("entity"))
+	if err := wb.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	wb.SetCount(wb.Count() + 1)
+	if err := wb.Validate(); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Validate() with wrong count = %v, want ErrCorrupted", err)
+	}
+}
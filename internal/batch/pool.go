@@ -64,6 +64,7 @@ func (p *WriteBatchPool) Get() *WriteBatch {
 		wb = New()
 	}
 	wb.Clear()
+	wb.resetProtection()
 
 	// Track hit vs miss based on capacity
 	p.mu.Lock()
@@ -0,0 +1,139 @@
+package batch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProtectionInfoVerifies(t *testing.T) {
+	for _, n := range []int{1, 2, 4, 8} {
+		wb := New()
+		if err := wb.UpdateProtectionInfo(n); err != nil {
+			t.Fatalf("UpdateProtectionInfo(%d) failed: %v", n, err)
+		}
+		wb.Put([]byte("k1"), []byte("v1"))
+		wb.PutCF(3, []byte("k2"), []byte("v2"))
+		wb.Delete([]byte("k3"))
+		wb.SingleDeleteCF(3, []byte("k4"))
+		wb.Merge([]byte("k5"), []byte("m"))
+		wb.DeleteRange([]byte("a"), []byte("b"))
+		wb.PutLogData([]byte("blob"))
+
+		if got := len(wb.prot); got != 6 {
+			t.Fatalf("protection entries = %d, want 6", got)
+		}
+		if err := wb.VerifyProtectionInfo(); err != nil {
+			t.Errorf("VerifyProtectionInfo(%d bytes) failed: %v", n, err)
+		}
+	}
+}
+
+func TestProtectionInfoDetectsCorruption(t *testing.T) {
+	wb := New()
+	if err := wb.UpdateProtectionInfo(8); err != nil {
+		t.Fatalf("UpdateProtectionInfo failed: %v", err)
+	}
+	wb.Put([]byte("key"), []byte("value"))
+
+	// Flip a bit in the last byte of the value
+	wb.data[len(wb.data)-1] ^= 0x01
+
+	if err := wb.VerifyProtectionInfo(); !errors.Is(err, ErrProtectionMismatch) {
+		t.Errorf("VerifyProtectionInfo err = %v, want ErrProtectionMismatch", err)
+	}
+}
+
+func TestProtectionInfoComputedForExistingEntries(t *testing.T) {
+	wb := New()
+	wb.Put([]byte("k1"), []byte("v1"))
+	wb.Delete([]byte("k2"))
+
+	if err := wb.UpdateProtectionInfo(8); err != nil {
+		t.Fatalf("UpdateProtectionInfo failed: %v", err)
+	}
+	if got := len(wb.prot); got != 2 {
+		t.Fatalf("protection entries = %d, want 2", got)
+	}
+	if err := wb.VerifyProtectionInfo(); err != nil {
+		t.Errorf("VerifyProtectionInfo failed: %v", err)
+	}
+
+	// Changing the width of a protected batch is rejected
+	if err := wb.UpdateProtectionInfo(4); !errors.Is(err, ErrUnsupportedProtectionBytes) {
+		t.Errorf("UpdateProtectionInfo(4) err = %v, want ErrUnsupportedProtectionBytes", err)
+	}
+	if err := wb.UpdateProtectionInfo(3); !errors.Is(err, ErrUnsupportedProtectionBytes) {
+		t.Errorf("UpdateProtectionInfo(3) err = %v, want ErrUnsupportedProtectionBytes", err)
+	}
+}
+
+func TestProtectionInfoCloneClearAppend(t *testing.T) {
+	wb := New()
+	if err := wb.UpdateProtectionInfo(8); err != nil {
+		t.Fatalf("UpdateProtectionInfo failed: %v", err)
+	}
+	wb.Put([]byte("k1"), []byte("v1"))
+
+	clone := wb.Clone()
+	if err := clone.VerifyProtectionInfo(); err != nil {
+		t.Errorf("clone VerifyProtectionInfo failed: %v", err)
+	}
+
+	src := New()
+	src.Put([]byte("k2"), []byte("v2"))
+	if err := wb.Append(src); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := wb.VerifyProtectionInfo(); err != nil {
+		t.Errorf("VerifyProtectionInfo after Append failed: %v", err)
+	}
+
+	// The protection info of a malformed source cannot be computed
+	malformed := New()
+	malformed.Put([]byte("k3"), []byte("v3"))
+	data := malformed.Data()
+	bad, err := NewFromData(data[:len(data)-1])
+	if err != nil {
+		t.Fatalf("NewFromData failed: %v", err)
+	}
+	if err := wb.Append(bad); err == nil {
+		t.Error("Append of a truncated batch succeeded, want an error")
+	}
+
+	wb.Clear()
+	if !wb.HasProtectionInfo() {
+		t.Error("Clear disabled protection")
+	}
+	wb.Put([]byte("k3"), []byte("v3"))
+	if err := wb.VerifyProtectionInfo(); err != nil {
+		t.Errorf("VerifyProtectionInfo after Clear failed: %v", err)
+	}
+}
+
+func TestProtectionVerifier(t *testing.T) {
+	if v := New().NewProtectionVerifier(); v != nil {
+		t.Error("NewProtectionVerifier of an unprotected batch is not nil")
+	}
+
+	wb := New()
+	if err := wb.UpdateProtectionInfo(8); err != nil {
+		t.Fatalf("UpdateProtectionInfo failed: %v", err)
+	}
+	wb.Put([]byte("k1"), []byte("v1"))
+	wb.DeleteCF(3, []byte("k2"))
+
+	v := wb.NewProtectionVerifier()
+	if err := v.Verify(TypeValue, 0, []byte("k1"), []byte("v1")); err != nil {
+		t.Errorf("Verify of the first entry failed: %v", err)
+	}
+	if err := v.Verify(TypeDeletion, 3, []byte("k2"), []byte("x")); !errors.Is(err, ErrProtectionMismatch) {
+		t.Errorf("Verify of a changed entry = %v, want ErrProtectionMismatch", err)
+	}
+
+	v = wb.NewProtectionVerifier()
+	_ = v.Verify(TypeValue, 0, []byte("k1"), []byte("v1"))
+	_ = v.Verify(TypeDeletion, 3, []byte("k2"), nil)
+	if err := v.Verify(TypeValue, 0, []byte("k3"), nil); !errors.Is(err, ErrProtectionMismatch) {
+		t.Errorf("Verify past the last entry = %v, want ErrProtectionMismatch", err)
+	}
+}
@@ -20,6 +20,7 @@ package batch
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/aalhour/rockyardkv/internal/encoding"
 )
@@ -64,11 +65,18 @@ var (
 
 	// ErrTooSmall indicates the batch is smaller than the header.
 	ErrTooSmall = errors.New("batch: too small")
+
+	// ErrUnsupportedRecord indicates a record the handler cannot process.
+	ErrUnsupportedRecord = errors.New("batch: record not supported by handler")
 )
 
 // WriteBatch represents a collection of writes to be applied atomically.
 type WriteBatch struct {
 	data []byte // The raw batch data including header
+
+	// Per-entry protection info; see protection.go.
+	protBytes int      // Protection bytes per key, 0 if unprotected
+	prot      []uint64 // One entry per counted record, in batch order
 }
 
 // New creates a new empty WriteBatch.
@@ -93,6 +101,8 @@ func (wb *WriteBatch) Clear() {
 	wb.data = wb.data[:HeaderSize]
 	// Reset count to 0
 	binary.LittleEndian.PutUint32(wb.data[8:12], 0)
+	// Keep protection enabled, but drop the entries' protection info
+	wb.prot = wb.prot[:0]
 }
 
 // Data returns the raw batch data.
@@ -103,9 +113,13 @@ func (wb *WriteBatch) Data() []byte {
 // Clone creates a deep copy of the WriteBatch.
 func (wb *WriteBatch) Clone() *WriteBatch {
 	clone := &WriteBatch{
-		data: make([]byte, len(wb.data)),
+		data:      make([]byte, len(wb.data)),
+		protBytes: wb.protBytes,
 	}
 	copy(clone.data, wb.data)
+	if wb.protBytes > 0 {
+		clone.prot = append([]uint64(nil), wb.prot...)
+	}
 	return clone
 }
 
@@ -114,6 +128,12 @@ func (wb *WriteBatch) Size() int {
 	return len(wb.data)
 }
 
+// ApproximateSize returns the memory held by the batch's contents: the
+// batch data plus the protection info kept for each protected entry.
+func (wb *WriteBatch) ApproximateSize() int {
+	return len(wb.data) + len(wb.prot)*8
+}
+
 // Count returns the number of records in the batch.
 func (wb *WriteBatch) Count() uint32 {
 	return binary.LittleEndian.Uint32(wb.data[8:12])
@@ -148,6 +168,21 @@ func (wb *WriteBatch) PutCF(cfID uint32, key, value []byte) {
 	wb.putRecord(TypeColumnFamilyValue, cfID, key, value)
 }
 
+// PutEntity adds a wide-column entity record to the batch. The entity is
+// the serialized column set.
+func (wb *WriteBatch) PutEntity(key, entity []byte) {
+	wb.putRecord(TypeWideColumnEntity, 0, key, entity)
+}
+
+// PutEntityCF adds a wide-column entity record with column family to the batch.
+func (wb *WriteBatch) PutEntityCF(cfID uint32, key, entity []byte) {
+	if cfID == 0 {
+		wb.PutEntity(key, entity)
+		return
+	}
+	wb.putRecord(TypeColumnFamilyWideColumnEntity, cfID, key, entity)
+}
+
 // Delete adds a Delete record to the batch.
 func (wb *WriteBatch) Delete(key []byte) {
 	wb.deleteRecord(TypeDeletion, 0, key)
@@ -213,15 +248,30 @@ func (wb *WriteBatch) PutLogData(blob []byte) {
 }
 
 // Append appends the contents of another batch to this batch.
-// The sequence number of the source batch is ignored.
-func (wb *WriteBatch) Append(src *WriteBatch) {
+// The sequence number of the source batch is ignored. It fails if the
+// protection info of src cannot be computed because src is malformed.
+func (wb *WriteBatch) Append(src *WriteBatch) error {
 	if src.Count() == 0 {
-		return
+		return nil
+	}
+	// Carry protection info over, computing it if src was not protected
+	// with the same width
+	if wb.protBytes > 0 {
+		if src.protBytes == wb.protBytes {
+			wb.prot = append(wb.prot, src.prot...)
+		} else {
+			builder := &protectionHandler{bytesPerKey: wb.protBytes}
+			if err := src.Iterate(builder); err != nil {
+				return err
+			}
+			wb.prot = append(wb.prot, builder.prot...)
+		}
 	}
 	// Append everything after the header from the source
 	wb.data = append(wb.data, src.data[HeaderSize:]...)
 	// Add the counts
 	wb.SetCount(wb.Count() + src.Count())
+	return nil
 }
 
 // HasPut returns true if the batch contains at least one Put operation.
@@ -270,7 +320,8 @@ func (wb *WriteBatch) putRecord(tag byte, cfID uint32, key, value []byte) {
 
 	// Append column family ID if needed
 	if tag == TypeColumnFamilyValue || tag == TypeColumnFamilyMerge ||
-		tag == TypeColumnFamilyRangeDeletion || tag == TypeColumnFamilyBlobIndex {
+		tag == TypeColumnFamilyRangeDeletion || tag == TypeColumnFamilyBlobIndex ||
+		tag == TypeColumnFamilyWideColumnEntity {
 		wb.data = encoding.AppendVarint32(wb.data, cfID)
 	}
 
@@ -283,6 +334,8 @@ func (wb *WriteBatch) putRecord(tag byte, cfID uint32, key, value []byte) {
 	// Increment count
 	count := wb.Count()
 	wb.SetCount(count + 1)
+
+	wb.protectRecord(tag, cfID, key, value)
 }
 
 // deleteRecord adds a delete record to the batch.
@@ -301,6 +354,8 @@ func (wb *WriteBatch) deleteRecord(tag byte, cfID uint32, key []byte) {
 	// Increment count
 	count := wb.Count()
 	wb.SetCount(count + 1)
+
+	wb.protectRecord(tag, cfID, key, nil)
 }
 
 // MarkBeginPrepare adds a begin-prepare marker to the batch.
@@ -310,6 +365,14 @@ func (wb *WriteBatch) MarkBeginPrepare() {
 	// Note: BeginPrepare doesn't increment count - it's a marker
 }
 
+// MarkBeginPersistedPrepare adds the begin-prepare marker of a transaction
+// whose writes are applied at prepare rather than at commit, as those of a
+// write-prepared transaction are.
+func (wb *WriteBatch) MarkBeginPersistedPrepare() {
+	wb.data = append(wb.data, TypeBeginPersistedPrepareXID)
+	// Note: BeginPrepare doesn't increment count - it's a marker
+}
+
 // MarkEndPrepare adds an end-prepare marker with the transaction ID.
 // This completes the prepare phase of a two-phase commit.
 func (wb *WriteBatch) MarkEndPrepare(xid []byte) {
@@ -373,6 +436,32 @@ type Handler2PC interface {
 	MarkRollback(xid []byte) error
 }
 
+// HandlerPersistedPrepare extends Handler2PC for handlers that tell the
+// prepare sections begun by TypeBeginPersistedPrepareXID, whose writes were
+// applied at prepare, from those applied at commit. Other Handler2PC
+// handlers get MarkBeginPrepare(false) for them.
+type HandlerPersistedPrepare interface {
+	Handler2PC
+
+	// MarkBeginPersistedPrepare indicates the start of a prepared
+	// transaction whose writes were applied at prepare.
+	MarkBeginPersistedPrepare() error
+}
+
+// HandlerWideColumn extends Handler with wide-column entity support.
+// Batches containing entities can only be iterated by handlers that
+// implement it.
+type HandlerWideColumn interface {
+	Handler
+
+	// PutEntity stores a wide-column entity. entity is the serialized
+	// column set.
+	PutEntity(key, entity []byte) error
+
+	// PutEntityCF stores a wide-column entity in a column family.
+	PutEntityCF(cfID uint32, key, entity []byte) error
+}
+
 // Iterate calls the handler for each record in the batch.
 func (wb *WriteBatch) Iterate(handler Handler) error {
 	if len(wb.data) < HeaderSize {
@@ -482,6 +571,35 @@ func (wb *WriteBatch) Iterate(handler Handler) error {
 				}
 			}
 
+		case TypeColumnFamilyWideColumnEntity:
+			cfID, data, err = decodeVarint32(data)
+			if err != nil {
+				return err
+			}
+			fallthrough
+		case TypeWideColumnEntity:
+			key, data, err = decodeLengthPrefixed(data)
+			if err != nil {
+				return err
+			}
+			value, data, err = decodeLengthPrefixed(data)
+			if err != nil {
+				return err
+			}
+			hwc, ok := handler.(HandlerWideColumn)
+			if !ok {
+				return ErrUnsupportedRecord
+			}
+			if cfID == 0 {
+				if err := hwc.PutEntity(key, value); err != nil {
+					return err
+				}
+			} else {
+				if err := hwc.PutEntityCF(cfID, key, value); err != nil {
+					return err
+				}
+			}
+
 		case TypeColumnFamilyRangeDeletion:
 			cfID, data, err = decodeVarint32(data)
 			if err != nil {
@@ -526,7 +644,11 @@ func (wb *WriteBatch) Iterate(handler Handler) error {
 		// 2PC (Two-Phase Commit) markers
 		case TypeBeginPrepareXID, TypeBeginPersistedPrepareXID, TypeBeginUnprepareXID:
 			// Check if handler supports 2PC
-			if h2pc, ok := handler.(Handler2PC); ok {
+			if hp, ok := handler.(HandlerPersistedPrepare); ok && tag == TypeBeginPersistedPrepareXID {
+				if err := hp.MarkBeginPersistedPrepare(); err != nil {
+					return err
+				}
+			} else if h2pc, ok := handler.(Handler2PC); ok {
 				unprepared := (tag == TypeBeginUnprepareXID)
 				if err := h2pc.MarkBeginPrepare(unprepared); err != nil {
 					return err
@@ -589,6 +711,46 @@ func (wb *WriteBatch) Iterate(handler Handler) error {
 	return nil
 }
 
+// Validate checks that the batch data decodes into whole records and that
+// the header's count matches the number of records.
+//
+// Reference: RocksDB v10.7.5 db/write_batch.cc (WriteBatchInternal::Iterate)
+func (wb *WriteBatch) Validate() error {
+	var counter recordCounter
+	if err := wb.Iterate(&counter); err != nil {
+		return err
+	}
+	if counter.count != wb.Count() {
+		return fmt.Errorf("%w: header count %d, found %d records", ErrCorrupted, wb.Count(), counter.count)
+	}
+	return nil
+}
+
+// recordCounter is a HandlerWideColumn that counts the records included in
+// the batch count.
+type recordCounter struct {
+	count uint32
+}
+
+func (c *recordCounter) add() error {
+	c.count++
+	return nil
+}
+
+func (c *recordCounter) Put(_, _ []byte) error                     { return c.add() }
+func (c *recordCounter) Delete(_ []byte) error                     { return c.add() }
+func (c *recordCounter) SingleDelete(_ []byte) error               { return c.add() }
+func (c *recordCounter) Merge(_, _ []byte) error                   { return c.add() }
+func (c *recordCounter) DeleteRange(_, _ []byte) error             { return c.add() }
+func (c *recordCounter) LogData(_ []byte)                          {}
+func (c *recordCounter) PutCF(_ uint32, _, _ []byte) error         { return c.add() }
+func (c *recordCounter) DeleteCF(_ uint32, _ []byte) error         { return c.add() }
+func (c *recordCounter) SingleDeleteCF(_ uint32, _ []byte) error   { return c.add() }
+func (c *recordCounter) MergeCF(_ uint32, _, _ []byte) error       { return c.add() }
+func (c *recordCounter) DeleteRangeCF(_ uint32, _, _ []byte) error { return c.add() }
+func (c *recordCounter) PutEntity(_, _ []byte) error               { return c.add() }
+func (c *recordCounter) PutEntityCF(_ uint32, _, _ []byte) error   { return c.add() }
+
 func decodeVarint32(data []byte) (uint32, []byte, error) {
 	v, n, err := encoding.DecodeVarint32(data)
 	if err != nil {
@@ -278,6 +278,59 @@ func TestWriteBatchDeleteRange(t *testing.T) {
 	}
 }
 
+// entityHandler records wide-column entities in addition to testHandler's
+// operations.
+type entityHandler struct {
+	testHandler
+	entities []kvPair
+}
+
+func (h *entityHandler) PutEntity(key, entity []byte) error {
+	h.entities = append(h.entities, kvPair{0, dup(key), dup(entity)})
+	return nil
+}
+
+func (h *entityHandler) PutEntityCF(cfID uint32, key, entity []byte) error {
+	h.entities = append(h.entities, kvPair{cfID, dup(key), dup(entity)})
+	return nil
+}
+
+func TestWriteBatchPutEntity(t *testing.T) {
+	wb := New()
+	wb.PutEntity([]byte("key1"), []byte("entity1"))
+	wb.PutEntityCF(3, []byte("key2"), []byte("entity2"))
+	wb.Put([]byte("key3"), []byte("value3"))
+
+	if wb.Count() != 3 {
+		t.Errorf("Count = %d, want 3", wb.Count())
+	}
+
+	h := &entityHandler{}
+	if err := wb.Iterate(h); err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	want := []kvPair{
+		{0, []byte("key1"), []byte("entity1")},
+		{3, []byte("key2"), []byte("entity2")},
+	}
+	if len(h.entities) != len(want) {
+		t.Fatalf("Expected %d entities, got %d", len(want), len(h.entities))
+	}
+	for i, e := range h.entities {
+		if e.cfID != want[i].cfID || !bytes.Equal(e.key, want[i].key) || !bytes.Equal(e.value, want[i].value) {
+			t.Errorf("Entity %d = {%d %q %q}, want {%d %q %q}", i, e.cfID, e.key, e.value, want[i].cfID, want[i].key, want[i].value)
+		}
+	}
+	if len(h.puts) != 1 {
+		t.Errorf("Expected 1 put, got %d", len(h.puts))
+	}
+
+	// Handlers without entity support cannot apply the batch
+	if err := wb.Iterate(&testHandler{}); !errors.Is(err, ErrUnsupportedRecord) {
+		t.Errorf("Iterate without entity support = %v, want ErrUnsupportedRecord", err)
+	}
+}
+
 func TestWriteBatchFromData(t *testing.T) {
 	// Create a batch and get its data
 	wb1 := New()
@@ -767,3 +820,44 @@ func BenchmarkWriteBatchIterate(b *testing.B) {
 		wb.Iterate(h)
 	}
 }
+
+// prepareHandler records the begin-prepare markers of a batch.
+type prepareHandler struct {
+	testHandler
+	begins []string
+}
+
+func (h *prepareHandler) MarkBeginPrepare(unprepared bool) error {
+	h.begins = append(h.begins, "prepare")
+	return nil
+}
+
+func (h *prepareHandler) MarkBeginPersistedPrepare() error {
+	h.begins = append(h.begins, "persisted")
+	return nil
+}
+
+func (h *prepareHandler) MarkEndPrepare(xid []byte) error { return nil }
+func (h *prepareHandler) MarkCommit(xid []byte) error     { return nil }
+func (h *prepareHandler) MarkRollback(xid []byte) error   { return nil }
+
+func TestWriteBatchPersistedPrepare(t *testing.T) {
+	wb := New()
+	wb.MarkBeginPrepare()
+	wb.Put([]byte("a"), []byte("va"))
+	wb.MarkEndPrepare([]byte("txn1"))
+	wb.MarkBeginPersistedPrepare()
+	wb.Put([]byte("b"), []byte("vb"))
+	wb.MarkEndPrepare([]byte("txn2"))
+
+	h := &prepareHandler{}
+	if err := wb.Iterate(h); err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	if !slices.Equal(h.begins, []string{"prepare", "persisted"}) {
+		t.Errorf("begins = %v, want [prepare persisted]", h.begins)
+	}
+	if len(h.puts) != 2 {
+		t.Errorf("Expected 2 puts, got %d", len(h.puts))
+	}
+}
@@ -388,3 +388,50 @@ func TestBlobRecordCorruption(t *testing.T) {
 		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
 	}
 }
+
+func TestGarbageCollectorAgeCutoff(t *testing.T) {
+	dir := t.TempDir()
+	fs := vfs.Default()
+
+	fileNum := uint64(10)
+	builder := NewBuilder(fs, dir, func() uint64 { fileNum++; return fileNum }, BuilderOptions{FileSize: 1})
+	for range 4 {
+		if _, err := builder.Add([]byte("key"), bytes.Repeat([]byte("v"), 100)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	files := builder.FileNumbers()
+	if len(files) != 4 {
+		t.Fatalf("Builder wrote %d files, want 4 (one per blob)", len(files))
+	}
+
+	gc := NewGarbageCollector(fs, dir)
+	gc.SetOptions(true, 0.5, 0.5)
+	for _, f := range files {
+		gc.AddFileMetadata(f, 100)
+	}
+
+	// The oldest half of the files is eligible
+	if got, want := gc.AgeCutoffFileNumber(), files[1]+1; got != want {
+		t.Errorf("AgeCutoffFileNumber = %d, want %d", got, want)
+	}
+
+	deleted, freed := gc.DeleteFiles(files[:2])
+	if deleted != 2 || freed <= 0 {
+		t.Errorf("DeleteFiles = (%d, %d), want 2 files and some bytes", deleted, freed)
+	}
+	if fs.Exists(filepath.Join(dir, FileName(files[0]))) {
+		t.Error("Deleted blob file still exists")
+	}
+	if got := gc.Files(); len(got) != 2 || got[0] != files[2] {
+		t.Errorf("Files after delete = %v, want %v", got, files[2:])
+	}
+
+	gc.SetOptions(false, 0.5, 0.5)
+	if got := gc.AgeCutoffFileNumber(); got != 0 {
+		t.Errorf("AgeCutoffFileNumber with GC disabled = %d, want 0", got)
+	}
+}
@@ -0,0 +1,134 @@
+// builder.go implements Builder, which writes separated values to a
+// sequence of blob files for a flush or compaction.
+//
+// Reference: RocksDB v10.7.5
+//   - db/blob/blob_file_builder.h
+//   - db/blob/blob_file_builder.cc (BlobFileBuilder::Add)
+package blob
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aalhour/rockyardkv/internal/compression"
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+// BuilderOptions configures a Builder.
+type BuilderOptions struct {
+	// FileSize is the size at which a new blob file is started (0 = never roll)
+	FileSize uint64
+
+	// Compression is the compression type for blob values
+	Compression compression.Type
+}
+
+// Builder writes blob values, rolling to a new blob file whenever the
+// current one reaches FileSize.
+type Builder struct {
+	fs          vfs.FS
+	dbPath      string
+	nextFileNum func() uint64
+	opts        BuilderOptions
+
+	file    vfs.WritableFile
+	writer  *Writer
+	fileNum uint64
+
+	// Every blob file created, oldest first
+	fileNums []uint64
+}
+
+// NewBuilder creates a builder that allocates blob file numbers with
+// nextFileNum.
+func NewBuilder(fs vfs.FS, dbPath string, nextFileNum func() uint64, opts BuilderOptions) *Builder {
+	return &Builder{
+		fs:          fs,
+		dbPath:      dbPath,
+		nextFileNum: nextFileNum,
+		opts:        opts,
+	}
+}
+
+// Add writes value to the current blob file and returns the encoded blob
+// index to store in the SST in its place.
+func (b *Builder) Add(userKey, value []byte) ([]byte, error) {
+	if b.writer != nil && b.opts.FileSize > 0 && b.writer.FileSize() >= b.opts.FileSize {
+		if err := b.Finish(); err != nil {
+			return nil, err
+		}
+	}
+	if b.writer == nil {
+		if err := b.openFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, err := b.writer.AddBlob(userKey, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add blob: %w", err)
+	}
+	idx.FileNumber = b.fileNum
+	return idx.Encode(), nil
+}
+
+// openFile starts a new blob file.
+func (b *Builder) openFile() error {
+	fileNum := b.nextFileNum()
+	file, err := b.fs.Create(b.path(fileNum))
+	if err != nil {
+		return fmt.Errorf("failed to create blob file: %w", err)
+	}
+	b.fileNums = append(b.fileNums, fileNum)
+
+	writer, err := NewWriter(file, WriterOptions{CompressionType: b.opts.Compression})
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write blob file header: %w", err)
+	}
+	b.file = file
+	b.writer = writer
+	b.fileNum = fileNum
+	return nil
+}
+
+// Finish writes the footer of the current blob file, syncs and closes it.
+// Blob files must be finished before the SSTs referencing them are installed.
+func (b *Builder) Finish() error {
+	if b.writer == nil {
+		return nil
+	}
+	writer, file := b.writer, b.file
+	b.writer, b.file = nil, nil
+	if err := writer.Finish(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to finish blob file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync blob file: %w", err)
+	}
+	return file.Close()
+}
+
+// Abandon closes and removes every blob file created by the builder.
+func (b *Builder) Abandon() {
+	if b.file != nil {
+		_ = b.file.Close()
+		b.writer, b.file = nil, nil
+	}
+	for _, fileNum := range b.fileNums {
+		_ = b.fs.Remove(b.path(fileNum)) // Best-effort cleanup
+	}
+	b.fileNums = nil
+}
+
+// FileNumbers returns the numbers of the blob files created, oldest first.
+func (b *Builder) FileNumbers() []uint64 {
+	return b.fileNums
+}
+
+// path returns the path of the blob file with the given number.
+func (b *Builder) path(fileNum uint64) string {
+	return filepath.Join(b.dbPath, FileName(fileNum))
+}
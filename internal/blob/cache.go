@@ -1,16 +1,20 @@
 // cache.go implements blob file caching for BlobDB.
 //
 // BlobFileCache in RocksDB caches open blob file readers to avoid
-// repeated file opens for blob reads.
+// repeated file opens for blob reads. The optional value cache keeps
+// recently read blob values, since blob reads bypass the block cache.
 //
 // Reference: RocksDB v10.7.5
 //   - db/blob/blob_file_cache.h
 //   - db/blob/blob_file_cache.cc
+//   - db/blob/blob_source.cc (BlobSource::GetBlob)
 package blob
 
 import (
+	"slices"
 	"sync"
 
+	"github.com/aalhour/rockyardkv/internal/cache"
 	"github.com/aalhour/rockyardkv/vfs"
 )
 
@@ -21,11 +25,17 @@ type Cache struct {
 	dbPath  string
 	readers map[uint64]*Reader
 	maxSize int
+
+	// Cache of blob values, keyed by file number and offset (nil = disabled)
+	values cache.Cache
 }
 
 // CacheOptions configures the blob cache
 type CacheOptions struct {
 	MaxOpenFiles int
+
+	// ValueCache caches recently read blob values (nil = disabled)
+	ValueCache cache.Cache
 }
 
 // DefaultCacheOptions returns default cache options
@@ -42,24 +52,66 @@ func NewCache(fs vfs.FS, dbPath string, opts CacheOptions) *Cache {
 		dbPath:  dbPath,
 		readers: make(map[uint64]*Reader),
 		maxSize: opts.MaxOpenFiles,
+		values:  opts.ValueCache,
+	}
+}
+
+// GetValue returns the value of a blob, consulting the value cache first.
+// The returned slice belongs to the caller.
+func (c *Cache) GetValue(idx *BlobIndex) ([]byte, error) {
+	if value, ok := c.lookupValue(idx); ok {
+		return value, nil
+	}
+	record, err := c.Get(idx)
+	if err != nil {
+		return nil, err
+	}
+	c.insertValue(idx, record.Value)
+	return record.Value, nil
+}
+
+// lookupValue returns a copy of the cached value of a blob, if any.
+func (c *Cache) lookupValue(idx *BlobIndex) ([]byte, bool) {
+	if c.values == nil {
+		return nil, false
+	}
+	handle := c.values.Lookup(cache.CacheKey{FileNumber: idx.FileNumber, BlockOffset: idx.Offset})
+	if handle == nil {
+		return nil, false
+	}
+	value := slices.Clone(handle.Value())
+	c.values.Release(handle)
+	return value, true
+}
+
+// insertValue adds a copy of a blob value to the value cache, if any.
+func (c *Cache) insertValue(idx *BlobIndex, value []byte) {
+	if c.values == nil {
+		return
 	}
+	cached := slices.Clone(value)
+	key := cache.CacheKey{FileNumber: idx.FileNumber, BlockOffset: idx.Offset}
+	c.values.Release(c.values.Insert(key, cached, uint64(len(cached))))
 }
 
 // Get retrieves a blob from the cache, opening the blob file if necessary
 func (c *Cache) Get(idx *BlobIndex) (*BlobRecord, error) {
+	reader, err := c.reader(idx.FileNumber)
+	if err != nil {
+		return nil, err
+	}
+	return reader.GetBlob(idx)
+}
+
+// reader returns the reader of a blob file, opening the file if necessary.
+func (c *Cache) reader(fileNumber uint64) (*Reader, error) {
 	c.mu.RLock()
-	reader, ok := c.readers[idx.FileNumber]
+	reader, ok := c.readers[fileNumber]
 	c.mu.RUnlock()
-
-	if !ok {
-		var err error
-		reader, err = c.openReader(idx.FileNumber)
-		if err != nil {
-			return nil, err
-		}
+	if ok {
+		return reader, nil
 	}
-
-	return reader.GetBlob(idx)
+	return c.openReader(fileNumber)
 }
 
 // openReader opens a blob file reader and adds it to the cache
@@ -96,11 +148,11 @@ func (c *Cache) openReader(fileNumber uint64) (*Reader, error) {
 
 // blobFilePath returns the path to a blob file
 func (c *Cache) blobFilePath(fileNumber uint64) string {
-	return c.dbPath + "/" + blobFileName(fileNumber)
+	return c.dbPath + "/" + FileName(fileNumber)
 }
 
-// blobFileName returns the name of a blob file
-func blobFileName(fileNumber uint64) string {
+// FileName returns the name of a blob file
+func FileName(fileNumber uint64) string {
 	return formatFileNumber(fileNumber) + ".blob"
 }
 
@@ -10,6 +10,8 @@ package blob
 //   - db/compaction/compaction_job.cc - Auto-GC during compaction
 
 import (
+	"path/filepath"
+	"slices"
 	"sync"
 
 	"github.com/aalhour/rockyardkv/vfs"
@@ -84,6 +86,82 @@ func (gc *GarbageCollector) AddFileMetadata(fileNum uint64, totalBytes uint64) {
 	gc.totalBytes[fileNum] = totalBytes
 }
 
+// Files returns the numbers of the registered blob files, oldest first.
+func (gc *GarbageCollector) Files() []uint64 {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	files := make([]uint64, 0, len(gc.totalBytes))
+	for fileNum := range gc.totalBytes {
+		files = append(files, fileNum)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// TotalBytes returns the combined size of the registered blob files.
+func (gc *GarbageCollector) TotalBytes() uint64 {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	var total uint64
+	for _, size := range gc.totalBytes {
+		total += size
+	}
+	return total
+}
+
+// AgeCutoffFileNumber returns the file number below which blob files are
+// old enough for compaction to relocate their live blobs: the oldest
+// ageCutoff fraction of the registered files qualifies. It returns 0 when
+// auto-GC is disabled or no file qualifies.
+// Reference: RocksDB v10.7.5 db/version_set.cc
+// (VersionStorageInfo::ComputeFilesMarkedForForcedBlobGC)
+func (gc *GarbageCollector) AgeCutoffFileNumber() uint64 {
+	gc.mu.Lock()
+	enabled, ageCutoff := gc.enableAutoGC, gc.ageCutoff
+	gc.mu.Unlock()
+	if !enabled {
+		return 0
+	}
+	files := gc.Files()
+	n := int(float64(len(files)) * ageCutoff)
+	if n == 0 {
+		return 0
+	}
+	return files[n-1] + 1
+}
+
+// DeleteFiles removes the given blob files, which the caller guarantees
+// are no longer referenced, and stops tracking them.
+// Returns the number of files deleted and total bytes freed.
+func (gc *GarbageCollector) DeleteFiles(fileNums []uint64) (filesDeleted int, bytesFreed int64) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	for _, fileNum := range fileNums {
+		path := filepath.Join(gc.dbPath, FileName(fileNum))
+		var size int64
+		if info, err := gc.fs.Stat(path); err == nil {
+			size = info.Size()
+		}
+		if err := gc.fs.Remove(path); err != nil {
+			continue
+		}
+
+		filesDeleted++
+		bytesFreed += size
+
+		delete(gc.garbageBytes, fileNum)
+		delete(gc.garbageCount, fileNum)
+		delete(gc.totalBytes, fileNum)
+	}
+
+	gc.totalGCRuns++
+	gc.totalFilesFreed += uint64(filesDeleted)
+	gc.totalBytesFreed += uint64(bytesFreed)
+
+	return filesDeleted, bytesFreed
+}
+
 // RecordGarbage records garbage (deleted/overwritten blobs) for a file.
 // This is called during compaction when a blob reference is dropped.
 func (gc *GarbageCollector) RecordGarbage(fileNum uint64, blobSize uint64) {
@@ -101,12 +101,7 @@ func (m *FileManager) GetBlob(indexData []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	record, err := m.cache.Get(idx)
-	if err != nil {
-		return nil, err
-	}
-
-	return record.Value, nil
+	return m.cache.GetValue(idx)
 }
 
 // shouldRollFile returns true if we should start a new blob file.
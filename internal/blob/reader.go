@@ -14,6 +14,7 @@ import (
 	"io"
 
 	"github.com/aalhour/rockyardkv/internal/compression"
+	"github.com/aalhour/rockyardkv/internal/perf"
 	"github.com/aalhour/rockyardkv/vfs"
 )
 
@@ -66,11 +67,29 @@ func NewReader(file vfs.RandomAccessFile) (*Reader, error) {
 func (r *Reader) GetBlob(idx *BlobIndex) (*BlobRecord, error) {
 	// Read the blob record data
 	data := make([]byte, idx.Size)
-	if _, err := r.file.ReadAt(data, int64(idx.Offset)); err != nil {
+	if err := r.read(data, idx.Offset); err != nil {
 		return nil, err
 	}
+	return r.decodeRecord(data)
+}
+
+// read fills buf from the blob file at offset, counting the read in the
+// I/O stats.
+func (r *Reader) read(buf []byte, offset uint64) error {
+	if _, err := r.file.ReadAt(buf, int64(offset)); err != nil {
+		return err
+	}
+	perf.AddBlobFileRead(len(buf))
+	return nil
+}
+
+// dataEnd returns the offset at which the blob records end.
+func (r *Reader) dataEnd() uint64 {
+	return uint64(r.size - FooterSize)
+}
 
-	// Decode the record
+// decodeRecord decodes and decompresses the blob record in data.
+func (r *Reader) decodeRecord(data []byte) (*BlobRecord, error) {
 	record, err := DecodeRecord(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
@@ -9,7 +9,9 @@
 package compaction
 
 import (
+	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/manifest"
+	"github.com/aalhour/rockyardkv/internal/version"
 )
 
 // Compaction represents a single compaction operation.
@@ -24,6 +26,13 @@ type Compaction struct {
 	// Maximum output file size
 	MaxOutputFileSize uint64
 
+	// Files in the level below the output level that overlap the inputs.
+	// A later compaction of an output file into that level rewrites the
+	// grandparents it overlaps, so output files are also cut once they
+	// overlap more than MaxGrandparentOverlapBytes of them (0 = no limit).
+	Grandparents               []*manifest.FileMetaData
+	MaxGrandparentOverlapBytes uint64
+
 	// Smallest and largest keys across all input files
 	SmallestKey []byte
 	LargestKey  []byte
@@ -125,6 +134,17 @@ func (c *Compaction) StartLevel() int {
 	return c.Inputs[0].Level
 }
 
+// IsBottommostLevel returns true if no level below the output level holds
+// files in v, so the compaction writes the oldest data of the tree.
+func (c *Compaction) IsBottommostLevel(v *version.Version) bool {
+	for level := c.OutputLevel + 1; level < v.NumLevels(); level++ {
+		if v.NumFiles(level) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // computeKeyRange computes the smallest and largest keys across all input files.
 func (c *Compaction) computeKeyRange() {
 	for i, in := range c.Inputs {
@@ -150,6 +170,56 @@ func (c *Compaction) computeKeyRange() {
 	}
 }
 
+// GrandparentOverlapBytes returns the combined size of the grandparents that
+// overlap the internal key range [smallest, largest]: what compacting a file
+// with that range into the grandparent level would have to read from it.
+func (c *Compaction) GrandparentOverlapBytes(smallest, largest []byte) uint64 {
+	var total uint64
+	for _, f := range c.Grandparents {
+		if dbformat.CompareInternalKeys(f.Largest, smallest) < 0 || dbformat.CompareInternalKeys(f.Smallest, largest) > 0 {
+			continue
+		}
+		total += f.FD.FileSize
+	}
+	return total
+}
+
+// grandparentOverlap tracks the grandparents passed by the keys written to
+// the current output file, to cut the file once it overlaps more than the
+// compaction's MaxGrandparentOverlapBytes of them.
+// Reference: RocksDB v10.7.5 db/compaction/compaction_outputs.cc ShouldStopBefore
+type grandparentOverlap struct {
+	grandparents []*manifest.FileMetaData
+	limit        uint64
+
+	index      int    // First grandparent not yet passed
+	seenKey    bool   // Whether a key was added before
+	overlapped uint64 // Bytes of the grandparents passed by the current file
+}
+
+func newGrandparentOverlap(c *Compaction) grandparentOverlap {
+	return grandparentOverlap{grandparents: c.Grandparents, limit: c.MaxGrandparentOverlapBytes}
+}
+
+// exceeded advances past the grandparents that end before internalKey and
+// reports whether the current output file, extended to internalKey, would
+// overlap more than the limit of them.
+func (g *grandparentOverlap) exceeded(internalKey []byte) bool {
+	for g.index < len(g.grandparents) && dbformat.CompareInternalKeys(internalKey, g.grandparents[g.index].Largest) > 0 {
+		if g.seenKey {
+			g.overlapped += g.grandparents[g.index].FD.FileSize
+		}
+		g.index++
+	}
+	g.seenKey = true
+	return g.limit > 0 && g.overlapped > g.limit
+}
+
+// reset starts counting for a new output file.
+func (g *grandparentOverlap) reset() {
+	g.overlapped = 0
+}
+
 // compareKeys performs a simple bytewise comparison of keys.
 // For internal keys, this should use the internal key comparator.
 func compareKeys(a, b []byte) int {
@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/manifest"
+	"github.com/aalhour/rockyardkv/internal/rangedel"
 	"github.com/aalhour/rockyardkv/internal/table"
 	"github.com/aalhour/rockyardkv/vfs"
 )
@@ -415,6 +417,41 @@ func TestCompactionJobCreation(t *testing.T) {
 	}
 }
 
+func TestCompactionJobClipsRangeTombstonesWithUserComparator(t *testing.T) {
+	dir := t.TempDir()
+	fs := vfs.Default()
+	cache := table.NewTableCache(fs, table.TableCacheOptions{MaxOpenFiles: 10})
+
+	c := NewCompaction([]*CompactionInputFiles{}, 1)
+	job := NewCompactionJob(c, dir, fs, cache, func() uint64 { return 1 })
+	job.SetUserComparator(func(a, b []byte) int { return bytes.Compare(b, a) })
+	job.addRangeTombstones(rangedel.NewRangeTombstone([]byte("z"), []byte("a"), 10))
+
+	proc := newCompactionProcessor(job)
+	var err error
+	proc.currentFile, proc.builder, err = job.startOutputFile()
+	if err != nil {
+		t.Fatalf("startOutputFile failed: %v", err)
+	}
+	defer proc.closeUnfinished()
+
+	// In reverse order, [z, a) clipped to [m, c) is [m, c)
+	proc.fileLower = []byte("m")
+	if err := proc.addRangeTombstones([]byte("c")); err != nil {
+		t.Fatalf("addRangeTombstones failed: %v", err)
+	}
+	smallest, largest := proc.currentFile.smallest, proc.currentFile.largest
+	if smallest == nil || largest == nil {
+		t.Fatal("tombstone was not written to the file")
+	}
+	if got := dbformat.ExtractUserKey(smallest); string(got) != "m" {
+		t.Errorf("smallest user key = %q, want m", got)
+	}
+	if got := dbformat.ExtractUserKey(largest); string(got) != "c" {
+		t.Errorf("largest user key = %q, want c", got)
+	}
+}
+
 func TestCompactionJobSSTPath(t *testing.T) {
 	dir := "/test/db"
 	fs := vfs.Default()
@@ -1115,3 +1152,107 @@ func TestCompactionJobShouldDropKey(t *testing.T) {
 		t.Error("shouldDropKey should return false when no tombstones exist")
 	}
 }
+
+func TestObsoleteTrackerSnapshotStripes(t *testing.T) {
+	tracker := newObsoleteTracker([]dbformat.SequenceNumber{20})
+
+	entries := []struct {
+		key      []byte
+		obsolete bool
+	}{
+		// Newest version of a, and an older one no snapshot sees
+		{makeInternalKey("a", 30, uint8(dbformat.TypeValue)), false},
+		{makeInternalKey("a", 25, uint8(dbformat.TypeValue)), true},
+		// Visible to the snapshot at 20, then hidden from it
+		{makeInternalKey("a", 20, uint8(dbformat.TypeValue)), false},
+		{makeInternalKey("a", 10, uint8(dbformat.TypeDeletion)), true},
+		// Merge operands keep their base
+		{makeInternalKey("b", 30, uint8(dbformat.TypeMerge)), false},
+		{makeInternalKey("b", 29, uint8(dbformat.TypeValue)), false},
+		{makeInternalKey("b", 28, uint8(dbformat.TypeBlobIndex)), true},
+		// A deletion hides older versions
+		{makeInternalKey("c", 30, uint8(dbformat.TypeDeletion)), false},
+		{makeInternalKey("c", 29, uint8(dbformat.TypeBlobIndex)), true},
+		// Range deletions are never dropped
+		{makeInternalKey("c", 28, uint8(dbformat.TypeRangeDeletion)), false},
+		// A new user key starts over
+		{makeInternalKey("d", 5, uint8(dbformat.TypeValue)), false},
+	}
+	for i, e := range entries {
+		if got := tracker.isObsolete(e.key); got != e.obsolete {
+			t.Errorf("entry %d (%q seq %d): isObsolete = %v, want %v", i,
+				dbformat.ExtractUserKey(e.key), dbformat.ExtractSequenceNumber(e.key), got, e.obsolete)
+		}
+	}
+}
+
+// TestCompactionJobGrandparentOverlapSplit tests that output files are cut
+// so that none overlaps much more than MaxGrandparentOverlapBytes of the
+// grandparent level.
+func TestCompactionJobGrandparentOverlapSplit(t *testing.T) {
+	dir := t.TempDir()
+	fs := vfs.Default()
+	cache := table.NewTableCache(fs, table.TableCacheOptions{MaxOpenFiles: 10})
+	defer cache.Close()
+
+	var keys []string
+	for i := range 100 {
+		keys = append(keys, fmt.Sprintf("k%02d", i))
+	}
+	createTestSST(t, dir, 1, keys)
+
+	// Ten grandparents of 1000 bytes, each covering ten keys
+	const grandparentSize = 1000
+	var grandparents []*manifest.FileMetaData
+	for i := range 10 {
+		grandparents = append(grandparents, makeTestFileMetaData(uint64(20+i), grandparentSize,
+			makeInternalKey(keys[i*10], 50, 1), makeInternalKey(keys[i*10+9], 50, 1)))
+	}
+
+	run := func(limit uint64) ([]*manifest.FileMetaData, *Compaction, *CompactionJob) {
+		t.Helper()
+		input := makeTestFileMetaData(1, 1000, makeInternalKey("k00", 100, 1), makeInternalKey("k99", 100, 1))
+		c := NewCompaction([]*CompactionInputFiles{{Level: 1, Files: []*manifest.FileMetaData{input}}}, 2)
+		c.Grandparents = grandparents
+		c.MaxGrandparentOverlapBytes = limit
+		fileNum := uint64(100 + limit)
+		job := NewCompactionJob(c, dir, fs, cache, func() uint64 {
+			fileNum++
+			return fileNum
+		})
+		outputs, err := job.Run()
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return outputs, c, job
+	}
+
+	// Without a limit, the single output overlaps every grandparent
+	outputs, _, job := run(0)
+	if len(outputs) != 1 {
+		t.Fatalf("outputs = %d, want 1", len(outputs))
+	}
+	if got := job.GrandparentOverlapBytes(); got != 10*grandparentSize {
+		t.Errorf("GrandparentOverlapBytes() = %d, want %d", got, 10*grandparentSize)
+	}
+
+	// With a limit, every output overlaps at most the limit plus the
+	// grandparents it starts and ends in
+	const limit = 2500
+	outputs, c, job := run(limit)
+	if len(outputs) < 3 {
+		t.Fatalf("outputs = %d, want the compaction split into at least 3", len(outputs))
+	}
+	var total uint64
+	for _, f := range outputs {
+		overlap := c.GrandparentOverlapBytes(f.Smallest, f.Largest)
+		if overlap > limit+2*grandparentSize {
+			t.Errorf("output %d overlaps %d grandparent bytes, want at most %d",
+				f.FD.GetNumber(), overlap, limit+2*grandparentSize)
+		}
+		total += overlap
+	}
+	if got := job.GrandparentOverlapBytes(); got != total {
+		t.Errorf("GrandparentOverlapBytes() = %d, want the sum over outputs %d", got, total)
+	}
+}
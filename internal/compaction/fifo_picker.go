@@ -61,6 +61,11 @@ func NewFIFOCompactionPicker(opts *FIFOCompactionOptions) *FIFOCompactionPicker
 	}
 }
 
+// SetClock overrides the time source used for TTL expiry.
+func (p *FIFOCompactionPicker) SetClock(now func() time.Time) {
+	p.now = now
+}
+
 // NeedsCompaction returns true if files should be dropped.
 func (p *FIFOCompactionPicker) NeedsCompaction(v *version.Version) bool {
 	totalSize := p.getTotalSize(v)
@@ -106,7 +111,7 @@ func (p *FIFOCompactionPicker) PickCompaction(v *version.Version) *Compaction {
 // getTotalSize returns the total size of all SST files.
 func (p *FIFOCompactionPicker) getTotalSize(v *version.Version) uint64 {
 	var total uint64
-	for level := range version.MaxNumLevels {
+	for level := range v.NumLevels() {
 		for _, f := range v.Files(level) {
 			total += f.FD.FileSize
 		}
@@ -125,7 +130,7 @@ type sortedFile struct {
 func (p *FIFOCompactionPicker) getAllFilesSortedByAge(v *version.Version) []*sortedFile {
 	var files []*sortedFile
 
-	for level := range version.MaxNumLevels {
+	for level := range v.NumLevels() {
 		for _, f := range v.Files(level) {
 			if f.BeingCompacted {
 				continue
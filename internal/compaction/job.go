@@ -15,16 +15,21 @@
 package compaction
 
 import (
+	"bytes"
 	"fmt"
 	"path/filepath"
+	"slices"
 
+	"github.com/aalhour/rockyardkv/internal/blob"
 	"github.com/aalhour/rockyardkv/internal/block"
+	"github.com/aalhour/rockyardkv/internal/compression"
 	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/iterator"
 	"github.com/aalhour/rockyardkv/internal/manifest"
 	"github.com/aalhour/rockyardkv/internal/rangedel"
 	"github.com/aalhour/rockyardkv/internal/table"
 	"github.com/aalhour/rockyardkv/internal/testutil"
+	"github.com/aalhour/rockyardkv/internal/wide"
 	"github.com/aalhour/rockyardkv/vfs"
 )
 
@@ -86,6 +91,75 @@ type MergeOperator interface {
 	FullMerge(key []byte, existingValue []byte, operands [][]byte) (newValue []byte, ok bool)
 }
 
+// BlobResolver reads the value a TypeBlobIndex entry refers to. Compaction
+// carries blob references over unchanged and only needs the value when
+// merge operands must be applied on top of it.
+type BlobResolver func(blobIndex []byte) ([]byte, error)
+
+// BlobGCOptions configures blob garbage collection during compaction.
+// Reference: RocksDB v10.7.5 db/compaction/compaction_iterator.cc
+// (CompactionIterator::GarbageCollectBlobIfNeeded)
+type BlobGCOptions struct {
+	// CutoffFileNumber is the blob file number below which live blobs are
+	// relocated to new blob files
+	CutoffFileNumber uint64
+
+	// BlobFileSize is the size at which a new blob file is started
+	BlobFileSize uint64
+
+	// Compression is the compression type for relocated blob values
+	Compression compression.Type
+}
+
+// jobOptions holds the settings of CompactionJob and ParallelCompactionJob.
+// A parallel job that falls back to a single job hands all of them on.
+type jobOptions struct {
+	// Merge operator for combining merge operands during compaction
+	mergeOperator MergeOperator
+
+	// Verify input block checksums while reading
+	verifyChecksums bool
+
+	// Compression for the outputs' data blocks
+	compression compression.Type
+
+	// Column family recorded in the outputs' properties
+	columnFamilyID   uint32
+	columnFamilyName string
+
+	// Bloom filter bits per key of the outputs (0 = no filters)
+	filterBitsPerKey int
+
+	// Check that compressed blocks decompress to their contents
+	verifyCompression bool
+
+	// Omit filter blocks from the outputs
+	skipFilters bool
+
+	// Prefixes added to the outputs' filters (nil = whole keys only)
+	prefixName string
+	prefix     func(key []byte) ([]byte, bool)
+
+	// Orders user keys when range tombstones are clipped to output files
+	userCmp func(a, b []byte) int
+
+	// Reads separated values that merge operands apply to
+	blobResolver BlobResolver
+
+	// Live snapshot sequence numbers, for dropping obsolete versions
+	snapshots []dbformat.SequenceNumber
+}
+
+// defaultJobOptions returns the settings of a new job.
+func defaultJobOptions() jobOptions {
+	return jobOptions{
+		verifyChecksums:  true,
+		columnFamilyName: table.DefaultBuilderOptions().ColumnFamilyName,
+		filterBitsPerKey: table.DefaultBuilderOptions().FilterBitsPerKey,
+		userCmp:          bytes.Compare,
+	}
+}
+
 // CompactionJob performs a single compaction operation.
 // It reads from input files, merges them, and writes to new output files.
 type CompactionJob struct {
@@ -103,22 +177,51 @@ type CompactionJob struct {
 	// Range deletion aggregator for dropping keys covered by range tombstones
 	rangeDelAgg *rangedel.CompactionRangeDelAggregator
 
+	// Range tombstones read from the inputs, carried into the output's
+	// range deletion meta-block
+	rangeTombstones *rangedel.TombstoneList
+
 	// Earliest snapshot sequence number (for garbage collection decisions)
 	earliestSnapshot dbformat.SequenceNumber
 
+	// Drops versions that no snapshot can see
+	obsolete obsoleteTracker
+
 	// Rate limiter for controlling I/O rate (optional)
 	rateLimiter RateLimiter
 
 	// Compaction filter for custom filtering/transformation during compaction
 	filter Filter
 
-	// Merge operator for combining merge operands during compaction
-	mergeOperator MergeOperator
+	// Settings shared with ParallelCompactionJob
+	jobOptions
+
+	// Relocation of blobs out of old blob files (nil = disabled)
+	blobGC *BlobGCOptions
+
+	// Writes the relocated blobs
+	blobs *blob.Builder
+
+	// Blob files written by a successful run
+	blobFiles []uint64
+
+	// Paths of every output file created, for cleanup on failure
+	outputPaths []string
+
+	// Input files whose readers were taken from the table cache
+	inputFiles []uint64
 
 	// Statistics about filtered entries
 	filteredRecords uint64
 	changedRecords  uint64
 	mergedRecords   uint64
+
+	// Point entries read from the inputs and written to the outputs
+	numInputRecords  uint64
+	numOutputRecords uint64
+
+	// Bytes of grandparents overlapped by the outputs, summed over outputs
+	grandparentOverlapBytes uint64
 }
 
 // NewCompactionJob creates a new compaction job.
@@ -150,6 +253,7 @@ func NewCompactionJobWithSnapshot(
 		nextFileNum:      nextFileNum,
 		rangeDelAgg:      rangedel.NewCompactionRangeDelAggregator(earliestSnapshot),
 		earliestSnapshot: earliestSnapshot,
+		jobOptions:       defaultJobOptions(),
 	}
 }
 
@@ -172,6 +276,7 @@ func NewCompactionJobWithRateLimiter(
 		rangeDelAgg:      rangedel.NewCompactionRangeDelAggregator(earliestSnapshot),
 		earliestSnapshot: earliestSnapshot,
 		rateLimiter:      rateLimiter,
+		jobOptions:       defaultJobOptions(),
 	}
 }
 
@@ -187,12 +292,101 @@ func (j *CompactionJob) SetMergeOperator(m MergeOperator) {
 	j.mergeOperator = m
 }
 
+// SetVerifyChecksums controls whether input block checksums are verified.
+// When enabled (the default), a corrupted input block fails the compaction
+// instead of being copied into the output.
+func (j *CompactionJob) SetVerifyChecksums(verify bool) {
+	j.verifyChecksums = verify
+}
+
+// SetCompression sets the compression type for the outputs' data blocks.
+func (j *CompactionJob) SetCompression(c compression.Type) {
+	j.compression = c
+}
+
+// SetColumnFamily sets the column family recorded in the outputs'
+// properties. The default is the default column family.
+func (j *CompactionJob) SetColumnFamily(id uint32, name string) {
+	j.columnFamilyID = id
+	j.columnFamilyName = name
+}
+
+// SetVerifyCompression controls whether every compressed block is
+// decompressed and compared with the original.
+func (j *CompactionJob) SetVerifyCompression(verify bool) {
+	j.verifyCompression = verify
+}
+
+// SetFilterBitsPerKey sets the bloom filter bits per key of the outputs.
+// 0 writes no filters.
+func (j *CompactionJob) SetFilterBitsPerKey(bits int) {
+	j.filterBitsPerKey = bits
+}
+
+// SetPrefixExtractor makes the outputs' filters hold the key prefixes that
+// prefix returns, recorded under the extractor's name.
+func (j *CompactionJob) SetPrefixExtractor(name string, prefix func(key []byte) ([]byte, bool)) {
+	j.prefixName = name
+	j.prefix = prefix
+}
+
+// SetUserComparator sets the order of user keys used to clip range
+// tombstones to the output files. The default is bytewise.
+func (j *CompactionJob) SetUserComparator(cmp func(a, b []byte) int) {
+	j.userCmp = cmp
+}
+
+// SetSkipFilters controls whether the outputs are written without filter
+// blocks.
+func (j *CompactionJob) SetSkipFilters(skip bool) {
+	j.skipFilters = skip
+}
+
+// SetBlobResolver sets how blob references are read when merge operands
+// must be applied to a separated value.
+func (j *CompactionJob) SetBlobResolver(r BlobResolver) {
+	j.blobResolver = r
+}
+
+// SetSnapshots sets the sequence numbers of the live snapshots. Without a
+// merge operator, an entry hidden by a newer entry of the same key is
+// dropped unless one of these snapshots can still see it.
+func (j *CompactionJob) SetSnapshots(snapshots []dbformat.SequenceNumber) {
+	j.snapshots = snapshots
+	j.obsolete = newObsoleteTracker(snapshots)
+}
+
+// SetBlobGC enables blob garbage collection: live blobs in blob files
+// numbered below opts.CutoffFileNumber are rewritten to new blob files, so
+// that the old files stop being referenced. Requires a blob resolver.
+func (j *CompactionJob) SetBlobGC(opts BlobGCOptions) {
+	j.blobGC = &opts
+}
+
+// BlobFiles returns the numbers of the blob files written by Run.
+func (j *CompactionJob) BlobFiles() []uint64 {
+	return j.blobFiles
+}
+
 // FilterStats returns statistics about filtered entries.
 // Returns the count of removed records and changed records.
 func (j *CompactionJob) FilterStats() (removed, changed uint64) {
 	return j.filteredRecords, j.changedRecords
 }
 
+// RecordStats returns the number of point entries read from the inputs and
+// written to the outputs. Range tombstones are not counted.
+func (j *CompactionJob) RecordStats() (input, output uint64) {
+	return j.numInputRecords, j.numOutputRecords
+}
+
+// GrandparentOverlapBytes returns the combined size of the grandparents each
+// output file overlaps, summed over the outputs. It bounds how much later
+// compactions of the outputs into the grandparent level have to read.
+func (j *CompactionJob) GrandparentOverlapBytes() uint64 {
+	return j.grandparentOverlapBytes
+}
+
 // Run executes the compaction.
 // Returns the list of output files created.
 func (j *CompactionJob) Run() ([]*manifest.FileMetaData, error) {
@@ -212,6 +406,7 @@ func (j *CompactionJob) Run() ([]*manifest.FileMetaData, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create input iterators: %w", err)
 	}
+	defer j.releaseInputs()
 
 	// Create merging iterator
 	mergingIter := iterator.NewMergingIterator(iters, block.CompareInternalKeys)
@@ -222,9 +417,19 @@ func (j *CompactionJob) Run() ([]*manifest.FileMetaData, error) {
 	// Process all entries
 	err = j.processEntries(mergingIter)
 	if err != nil {
+		j.removeOutputs()
 		return nil, fmt.Errorf("process entries: %w", err)
 	}
 
+	// Relocated blobs must be durable before the outputs are installed
+	if j.blobs != nil {
+		if err := j.blobs.Finish(); err != nil {
+			j.removeOutputs()
+			return nil, err
+		}
+		j.blobFiles = j.blobs.FileNumbers()
+	}
+
 	// Whitebox [synctest]: barrier after output files written
 	_ = testutil.SP(testutil.SPCompactionFinishOutput)
 
@@ -245,6 +450,7 @@ func (j *CompactionJob) doTrivialMove() ([]*manifest.FileMetaData, error) {
 			outputMeta.FD = f.FD
 			outputMeta.Smallest = f.Smallest
 			outputMeta.Largest = f.Largest
+			outputMeta.OldestBlobFileNumber = f.OldestBlobFileNumber
 			j.compaction.Edit.AddFile(j.compaction.OutputLevel, outputMeta)
 
 			// Delete from the input level
@@ -284,25 +490,65 @@ func (j *CompactionJob) createInputIterators() ([]iterator.Iterator, error) {
 			}
 			openedFiles = append(openedFiles, f.FD.GetNumber())
 
-			// Load range tombstones from this file into the aggregator
-			if j.rangeDelAgg != nil {
-				tombstoneList, err := reader.GetRangeTombstoneList()
-				if err == nil && !tombstoneList.IsEmpty() {
+			// Load range tombstones from this file into the aggregator, and keep
+			// them so they can be written to the output
+			tombstoneList, err := reader.GetRangeTombstoneList()
+			if err != nil {
+				for _, fileNum := range openedFiles {
+					j.tableCache.Release(fileNum)
+				}
+				return nil, fmt.Errorf("read range tombstones %d: %w", f.FD.GetNumber(), err)
+			}
+			if !tombstoneList.IsEmpty() {
+				if j.rangeDelAgg != nil {
 					j.rangeDelAgg.AddTombstoneList(input.Level, tombstoneList)
 				}
+				j.addRangeTombstones(tombstoneList.All()...)
 			}
 
 			// Wrap the table iterator
 			iters = append(iters, &tableIteratorWrapper{
-				iter:       reader.NewIterator(),
+				iter:       reader.NewIteratorWithVerify(j.verifyChecksums),
 				fileNumber: f.FD.GetNumber(),
 			})
 		}
 	}
 
+	j.inputFiles = openedFiles
 	return iters, nil
 }
 
+// addRangeTombstones records tombstones to be written to the output.
+func (j *CompactionJob) addRangeTombstones(tombstones ...*rangedel.RangeTombstone) {
+	if j.rangeTombstones == nil {
+		j.rangeTombstones = rangedel.NewTombstoneList()
+	}
+	for _, t := range tombstones {
+		j.rangeTombstones.Add(t)
+	}
+}
+
+// releaseInputs returns the input readers to the table cache.
+func (j *CompactionJob) releaseInputs() {
+	for _, fileNum := range j.inputFiles {
+		j.tableCache.Release(fileNum)
+	}
+	j.inputFiles = nil
+}
+
+// removeOutputs deletes every output file of a failed compaction, so that no
+// partial output is left behind.
+func (j *CompactionJob) removeOutputs() {
+	for _, path := range j.outputPaths {
+		_ = j.fs.Remove(path)
+	}
+	j.outputPaths = nil
+	j.outputFiles = nil
+	if j.blobs != nil {
+		j.blobs.Abandon()
+	}
+}
+
 // sstPath returns the path to an SST file.
 func (j *CompactionJob) sstPath(fileNum uint64) string {
 	return filepath.Join(j.dbPath, fmt.Sprintf("%06d.sst", fileNum))
@@ -310,8 +556,13 @@ func (j *CompactionJob) sstPath(fileNum uint64) string {
 
 // processEntries iterates through all entries and writes them to output files.
 // When a merge operator is configured, merge operands for the same key are combined.
-func (j *CompactionJob) processEntries(iter *iterator.MergingIterator) error {
+func (j *CompactionJob) processEntries(iter *iterator.MergingIterator) (err error) {
 	proc := newCompactionProcessor(j)
+	defer func() {
+		if err != nil {
+			proc.closeUnfinished()
+		}
+	}()
 
 	iter.SeekToFirst()
 
@@ -319,14 +570,31 @@ func (j *CompactionJob) processEntries(iter *iterator.MergingIterator) error {
 		key := iter.Key()
 		value := iter.Value()
 
+		// Range deletions belong in the range deletion meta-block, not inline
+		// with point data.
+		if dbformat.ExtractValueType(key) == dbformat.TypeRangeDeletion {
+			j.addRangeTombstones(rangedel.NewRangeTombstone(
+				append([]byte{}, dbformat.ExtractUserKey(key)...),
+				append([]byte{}, value...),
+				dbformat.ExtractSequenceNumber(key)))
+			iter.Next()
+			continue
+		}
+		j.numInputRecords++
+
 		// Check if this key should be dropped (covered by a range tombstone)
 		if j.shouldDropKey(key) {
 			iter.Next()
 			continue
 		}
 
-		// If no merge operator, write entries as-is (original behavior)
+		// If no merge operator, write entries as-is unless a newer version
+		// hides them from every snapshot
 		if j.mergeOperator == nil {
+			if j.obsolete.isObsolete(key) {
+				iter.Next()
+				continue
+			}
 			if err := proc.writeRawEntry(key, value); err != nil {
 				return err
 			}
@@ -368,10 +636,18 @@ type compactionProcessor struct {
 	builder     *table.TableBuilder
 	currentFile *compactionOutputFile
 
+	// Grandparents overlapped by the current output file
+	grandparents grandparentOverlap
+
+	// Smallest user key of the current output file; range tombstones
+	// before it were written to earlier files (nil for the first file)
+	fileLower []byte
+
 	// Merge accumulator state (only used when merge operator is configured)
 	currentUserKey []byte
 	mergeOperands  [][]byte                // Collected in newest-first order
 	baseValue      []byte                  // Base value (from Put) if found
+	baseType       dbformat.ValueType      // TypeValue or TypeWideColumnEntity
 	hasBaseValue   bool                    // Whether we found a Put for this key
 	baseSeqNum     dbformat.SequenceNumber // Sequence number for output key
 	isDeleted      bool                    // Whether key is deleted
@@ -379,7 +655,7 @@ type compactionProcessor struct {
 
 // newCompactionProcessor creates a new processor for the given job.
 func newCompactionProcessor(job *CompactionJob) *compactionProcessor {
-	return &compactionProcessor{job: job}
+	return &compactionProcessor{job: job, grandparents: newGrandparentOverlap(job.compaction)}
 }
 
 // writeRawEntry writes an entry using its original internal key.
@@ -428,12 +704,20 @@ func (p *compactionProcessor) writeEntry(userKey, value []byte, seqNum dbformat.
 // Creates a new file if needed.
 func (p *compactionProcessor) addToOutput(internalKey, value []byte) error {
 	// Check if we should start a new output file
-	if p.builder == nil || p.job.shouldFinishFile(p.currentFile, internalKey) {
+	overlapExceeded := p.grandparents.exceeded(internalKey)
+	if p.builder == nil || p.job.shouldFinishFile(p.builder, p.currentFile, internalKey, overlapExceeded) {
 		if p.builder != nil {
+			// The file covers user keys up to the first key of the next one
+			userKey := dbformat.ExtractUserKey(internalKey)
+			if err := p.addRangeTombstones(userKey); err != nil {
+				return err
+			}
 			if err := p.job.finishOutputFile(p.builder, p.currentFile); err != nil {
 				return err
 			}
+			p.fileLower = append([]byte{}, userKey...)
 		}
+		p.grandparents.reset()
 		var err error
 		p.currentFile, p.builder, err = p.job.startOutputFile()
 		if err != nil {
@@ -441,10 +725,19 @@ func (p *compactionProcessor) addToOutput(internalKey, value []byte) error {
 		}
 	}
 
+	if dbformat.ExtractValueType(internalKey) == dbformat.TypeBlobIndex {
+		var err error
+		if value, err = p.job.relocateBlob(internalKey, value); err != nil {
+			return err
+		}
+		p.currentFile.oldestBlobFile = oldestBlobFile(p.currentFile.oldestBlobFile, value)
+	}
+
 	// Add the key-value pair
 	if err := p.builder.Add(internalKey, value); err != nil {
 		return fmt.Errorf("add to builder: %w", err)
 	}
+	p.job.numOutputRecords++
 
 	// Track key range
 	if p.currentFile.smallest == nil {
@@ -483,6 +776,7 @@ func (p *compactionProcessor) processEntryWithMerge(key, value []byte) error {
 	case dbformat.TypeValue:
 		// Found a Put - this is the base value
 		p.baseValue = append([]byte{}, value...)
+		p.baseType = dbformat.TypeValue
 		p.hasBaseValue = true
 
 	case dbformat.TypeMerge:
@@ -493,8 +787,47 @@ func (p *compactionProcessor) processEntryWithMerge(key, value []byte) error {
 		// Delete wins - discard any accumulated operands
 		p.isDeleted = true
 
+	case dbformat.TypeBlobIndex:
+		switch {
+		case p.hasBaseValue || p.isDeleted:
+			// Shadowed by a newer Put or Delete
+		case len(p.mergeOperands) == 0:
+			// Nothing to merge into the separated value; keep the reference.
+			// Like a Delete, it hides every older entry of the key.
+			if err := p.writeEntry(userKey, value, seqNum, valueType); err != nil {
+				return err
+			}
+			p.isDeleted = true
+		default:
+			// Merge operands need the value itself as their base
+			base, err := p.job.resolveBlob(userKey, value)
+			if err != nil {
+				return err
+			}
+			p.baseValue = base
+			p.baseType = dbformat.TypeValue
+			p.hasBaseValue = true
+		}
+
+	case dbformat.TypeWideColumnEntity:
+		switch {
+		case p.hasBaseValue || p.isDeleted:
+			// Shadowed by a newer Put or Delete
+		case len(p.mergeOperands) == 0:
+			// Nothing to merge; like a Delete, it hides every older entry
+			if err := p.writeEntry(userKey, value, seqNum, valueType); err != nil {
+				return err
+			}
+			p.isDeleted = true
+		default:
+			// Merge operands apply to the entity's default column
+			p.baseValue = append([]byte{}, value...)
+			p.baseType = dbformat.TypeWideColumnEntity
+			p.hasBaseValue = true
+		}
+
 	default:
-		// For other types (range delete, etc.), write directly
+		// For other types, write directly
 		if err := p.writeEntry(userKey, value, seqNum, valueType); err != nil {
 			return err
 		}
@@ -518,7 +851,7 @@ func (p *compactionProcessor) flushMerge() error {
 	// If no merge operands, write the base value directly
 	if len(p.mergeOperands) == 0 {
 		if p.hasBaseValue {
-			err := p.writeEntry(p.currentUserKey, p.baseValue, p.baseSeqNum, dbformat.TypeValue)
+			err := p.writeEntry(p.currentUserKey, p.baseValue, p.baseSeqNum, p.baseType)
 			p.resetMergeState()
 			return err
 		}
@@ -535,24 +868,26 @@ func (p *compactionProcessor) flushMerge() error {
 		}
 
 		var existingValue []byte
+		baseType := dbformat.TypeValue
 		if p.hasBaseValue {
 			existingValue = p.baseValue
+			baseType = p.baseType
 		}
 
-		mergedValue, ok := p.job.mergeOperator.FullMerge(p.currentUserKey, existingValue, reversed)
-		if !ok {
-			return fmt.Errorf("merge operator failed for key %q", p.currentUserKey)
+		mergedValue, mergedType, err := fullMerge(p.job.mergeOperator, p.currentUserKey, existingValue, baseType, reversed)
+		if err != nil {
+			return err
 		}
 
 		p.job.mergedRecords++
-		err := p.writeEntry(p.currentUserKey, mergedValue, p.baseSeqNum, dbformat.TypeValue)
+		err = p.writeEntry(p.currentUserKey, mergedValue, p.baseSeqNum, mergedType)
 		p.resetMergeState()
 		return err
 	}
 
 	// No merge operator configured - write entries as-is (fallback)
 	if p.hasBaseValue {
-		if err := p.writeEntry(p.currentUserKey, p.baseValue, p.baseSeqNum, dbformat.TypeValue); err != nil {
+		if err := p.writeEntry(p.currentUserKey, p.baseValue, p.baseSeqNum, p.baseType); err != nil {
 			return err
 		}
 	}
@@ -566,23 +901,123 @@ func (p *compactionProcessor) flushMerge() error {
 	return nil
 }
 
+// fullMerge applies operands, oldest first, to base. A wide-column entity
+// base has the operands applied to its default column and stays an entity,
+// as for reads; any other base merges into a plain value.
+func fullMerge(m MergeOperator, key, base []byte, baseType dbformat.ValueType, operands [][]byte) ([]byte, dbformat.ValueType, error) {
+	if baseType != dbformat.TypeWideColumnEntity {
+		merged, ok := m.FullMerge(key, base, operands)
+		if !ok {
+			return nil, 0, fmt.Errorf("merge operator failed for key %q", key)
+		}
+		return merged, dbformat.TypeValue, nil
+	}
+
+	columns, err := wide.Deserialize(base)
+	if err != nil {
+		return nil, 0, fmt.Errorf("merge into entity for key %q: %w", key, err)
+	}
+	var defaultValue []byte
+	if len(columns) > 0 && len(columns[0].Name) == 0 {
+		defaultValue = columns[0].Value
+	} else {
+		columns = append([]wide.Column{{Name: []byte{}}}, columns...)
+	}
+	merged, ok := m.FullMerge(key, defaultValue, operands)
+	if !ok {
+		return nil, 0, fmt.Errorf("merge operator failed for key %q", key)
+	}
+	columns[0].Value = merged
+	entity, err := wide.Serialize(columns)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entity, dbformat.TypeWideColumnEntity, nil
+}
+
 // resetMergeState clears the merge accumulator.
 func (p *compactionProcessor) resetMergeState() {
 	p.currentUserKey = nil
 	p.mergeOperands = nil
 	p.baseValue = nil
+	p.baseType = 0
 	p.hasBaseValue = false
 	p.isDeleted = false
 }
 
-// finish completes the current output file if any.
+// closeUnfinished closes the current output file if it was never finished.
+func (p *compactionProcessor) closeUnfinished() {
+	if p.currentFile != nil && !p.currentFile.finished {
+		_ = p.currentFile.file.Close()
+	}
+}
+
+// finish writes the remaining range tombstones and completes the current
+// output file if any. Tombstones are kept even when every key they cover was
+// dropped, since they may still shadow keys in levels below the output.
+// Reference: RocksDB v10.7.5 db/compaction/compaction_outputs.cc AddRangeDels
 func (p *compactionProcessor) finish() error {
+	if p.builder == nil && p.job.rangeTombstones != nil && !p.job.rangeTombstones.IsEmpty() {
+		var err error
+		p.currentFile, p.builder, err = p.job.startOutputFile()
+		if err != nil {
+			return err
+		}
+	}
+
 	if p.builder != nil {
+		if err := p.addRangeTombstones(nil); err != nil {
+			return err
+		}
 		return p.job.finishOutputFile(p.builder, p.currentFile)
 	}
 	return nil
 }
 
+// addRangeTombstones writes the parts of the range tombstones that fall in
+// [p.fileLower, upper) to the current output file and widens its key range
+// to cover them. A nil bound is unbounded. Clipping the tombstones to each
+// file keeps the output files of a level from overlapping.
+func (p *compactionProcessor) addRangeTombstones(upper []byte) error {
+	tombstones := p.job.rangeTombstones
+	if tombstones == nil || tombstones.IsEmpty() {
+		return nil
+	}
+
+	clipped := rangedel.NewTombstoneList()
+	for _, t := range tombstones.All() {
+		start, end := t.StartKey, t.EndKey
+		if p.fileLower != nil && p.job.userCmp(start, p.fileLower) < 0 {
+			start = p.fileLower
+		}
+		if upper != nil && p.job.userCmp(end, upper) > 0 {
+			end = upper
+		}
+		if p.job.userCmp(start, end) >= 0 {
+			continue
+		}
+		clipped.AddRange(start, end, t.SequenceNum)
+	}
+	if clipped.IsEmpty() {
+		return nil
+	}
+
+	if err := p.builder.AddRangeTombstones(clipped); err != nil {
+		return fmt.Errorf("add range tombstones: %w", err)
+	}
+	for _, t := range clipped.All() {
+		start := dbformat.NewInternalKey(t.StartKey, t.SequenceNum, dbformat.TypeRangeDeletion)
+		if p.currentFile.smallest == nil || block.CompareInternalKeys(start, p.currentFile.smallest) < 0 {
+			p.currentFile.smallest = start
+		}
+		end := dbformat.NewInternalKey(t.EndKey, dbformat.MaxSequenceNumber, dbformat.TypeRangeDeletion)
+		if p.currentFile.largest == nil || block.CompareInternalKeys(end, p.currentFile.largest) > 0 {
+			p.currentFile.largest = end
+		}
+	}
+	return nil
+}
+
 // =============================================================================
 // End of compactionProcessor helpers
 // =============================================================================
@@ -600,6 +1035,59 @@ func bytesEqual(a, b []byte) bool {
 	return true
 }
 
+// obsoleteTracker finds the entries of a user key that are hidden from
+// every snapshot by a newer entry of the same key. Two entries are in the
+// same snapshot stripe when no snapshot lies between their sequence
+// numbers; within a stripe only the newest entry is visible.
+// Reference: RocksDB v10.7.5 db/compaction/compaction_iterator.cc (NextFromInput)
+type obsoleteTracker struct {
+	// Live snapshot sequence numbers, ascending
+	snapshots []dbformat.SequenceNumber
+
+	// The previous entry seen
+	hasPrev    bool
+	userKey    []byte
+	stripe     int
+	hidesOlder bool
+}
+
+// newObsoleteTracker creates a tracker for the given snapshots.
+func newObsoleteTracker(snapshots []dbformat.SequenceNumber) obsoleteTracker {
+	sorted := slices.Clone(snapshots)
+	slices.Sort(sorted)
+	return obsoleteTracker{snapshots: sorted}
+}
+
+// isObsolete reports whether the entry is hidden by the previous, newer
+// entry of the same user key. Entries must be passed in internal key order.
+func (t *obsoleteTracker) isObsolete(internalKey []byte) bool {
+	valueType := dbformat.ExtractValueType(internalKey)
+	if valueType == dbformat.TypeRangeDeletion {
+		return false
+	}
+	userKey := dbformat.ExtractUserKey(internalKey)
+	seqNum := dbformat.ExtractSequenceNumber(internalKey)
+
+	// The stripe is the earliest snapshot that can see the entry
+	stripe, _ := slices.BinarySearch(t.snapshots, seqNum)
+	if t.hasPrev && t.hidesOlder && stripe == t.stripe && bytesEqual(userKey, t.userKey) {
+		return true
+	}
+
+	t.hasPrev = true
+	t.userKey = append(t.userKey[:0], userKey...)
+	t.stripe = stripe
+	switch valueType {
+	case dbformat.TypeValue, dbformat.TypeBlobIndex, dbformat.TypeWideColumnEntity,
+		dbformat.TypeDeletion, dbformat.TypeSingleDeletion:
+		t.hidesOlder = true
+	default:
+		// Merge operands need the older entries as their base
+		t.hidesOlder = false
+	}
+	return false
+}
+
 // shouldDropKey checks if a key should be dropped during compaction.
 // A key is dropped if:
 // 1. It's covered by a range tombstone with a higher sequence number
@@ -626,6 +1114,10 @@ type compactionOutputFile struct {
 	path       string
 	smallest   []byte
 	largest    []byte
+	finished   bool // finishOutputFile has run and closed file
+
+	// Oldest blob file referenced by the file (0 = none)
+	oldestBlobFile uint64
 }
 
 // startOutputFile creates a new output file.
@@ -638,8 +1130,19 @@ func (j *CompactionJob) startOutputFile() (*compactionOutputFile, *table.TableBu
 	if err != nil {
 		return nil, nil, fmt.Errorf("create file %s: %w", filePath, err)
 	}
+	j.outputPaths = append(j.outputPaths, filePath)
 
 	opts := table.DefaultBuilderOptions()
+	opts.ColumnFamilyID = j.columnFamilyID
+	opts.ColumnFamilyName = j.columnFamilyName
+	opts.Compression = j.compression
+	opts.FilterBitsPerKey = j.filterBitsPerKey
+	opts.VerifyCompression = j.verifyCompression
+	opts.Prefix = j.prefix
+	opts.PrefixExtractorName = j.prefixName
+	if j.skipFilters {
+		opts.FilterBitsPerKey = 0
+	}
 	builder := table.NewTableBuilder(file, opts)
 
 	output := &compactionOutputFile{
@@ -653,6 +1156,7 @@ func (j *CompactionJob) startOutputFile() (*compactionOutputFile, *table.TableBu
 
 // finishOutputFile completes an output file and records its metadata.
 func (j *CompactionJob) finishOutputFile(builder *table.TableBuilder, output *compactionOutputFile) error {
+	output.finished = true
 	err := builder.Finish()
 	if err != nil {
 		_ = output.file.Close()
@@ -689,8 +1193,10 @@ func (j *CompactionJob) finishOutputFile(builder *table.TableBuilder, output *co
 	fileMeta.FD = manifest.NewFileDescriptor(output.fileNumber, 0, fileSize)
 	fileMeta.Smallest = output.smallest
 	fileMeta.Largest = output.largest
+	fileMeta.OldestBlobFileNumber = output.oldestBlobFile
 
 	j.outputFiles = append(j.outputFiles, fileMeta)
+	j.grandparentOverlapBytes += j.compaction.GrandparentOverlapBytes(output.smallest, output.largest)
 
 	// Add to the edit
 	j.compaction.Edit.AddFile(j.compaction.OutputLevel, fileMeta)
@@ -698,17 +1204,22 @@ func (j *CompactionJob) finishOutputFile(builder *table.TableBuilder, output *co
 	return nil
 }
 
-// shouldFinishFile returns true if we should start a new output file.
-func (j *CompactionJob) shouldFinishFile(current *compactionOutputFile, _ []byte) bool {
+// shouldFinishFile returns true if the current output file has reached the
+// compaction's MaxOutputFileSize, or overlaps too many grandparent bytes, and
+// should be finished before internalKey is added. Files are only cut between
+// user keys, so all versions of a key stay in one file.
+// Reference: RocksDB v10.7.5 db/compaction/compaction_outputs.cc ShouldStopBefore
+func (j *CompactionJob) shouldFinishFile(builder *table.TableBuilder, current *compactionOutputFile, internalKey []byte, overlapExceeded bool) bool {
 	if current == nil {
 		return true
 	}
 
-	// Check file size
-	// A full implementation would track the builder's current size
-	// For now, we rely on the builder to handle file size limits
-
-	return false
+	limit := j.compaction.MaxOutputFileSize
+	full := limit > 0 && builder.EstimatedFileSize() >= limit
+	if (!full && !overlapExceeded) || current.largest == nil {
+		return false
+	}
+	return !bytesEqual(dbformat.ExtractUserKey(internalKey), dbformat.ExtractUserKey(current.largest))
 }
 
 // tableIteratorWrapper wraps a table.TableIterator to implement iterator.Iterator.
@@ -752,3 +1263,54 @@ func (w *tableIteratorWrapper) Prev() {
 func (w *tableIteratorWrapper) Error() error {
 	return w.iter.Error()
 }
+
+// resolveBlob reads the separated value of userKey through the blob resolver.
+func (j *CompactionJob) resolveBlob(userKey, blobIndex []byte) ([]byte, error) {
+	if j.blobResolver == nil {
+		return nil, fmt.Errorf("merge over blob reference for key %q: no blob resolver", userKey)
+	}
+	return j.blobResolver(blobIndex)
+}
+
+// relocateBlob returns the blob reference to write for a TypeBlobIndex
+// entry. With blob GC enabled, a blob in a file older than the cutoff is
+// copied to a new blob file and the new reference is returned.
+func (j *CompactionJob) relocateBlob(internalKey, blobIndex []byte) ([]byte, error) {
+	if j.blobGC == nil {
+		return blobIndex, nil
+	}
+	idx, err := blob.DecodeBlobIndex(blobIndex)
+	if err != nil {
+		return nil, fmt.Errorf("decode blob index: %w", err)
+	}
+	if idx.FileNumber >= j.blobGC.CutoffFileNumber {
+		return blobIndex, nil
+	}
+
+	userKey := dbformat.ExtractUserKey(internalKey)
+	value, err := j.resolveBlob(userKey, blobIndex)
+	if err != nil {
+		return nil, err
+	}
+	if j.blobs == nil {
+		j.blobs = blob.NewBuilder(j.fs, j.dbPath, j.nextFileNum, blob.BuilderOptions{
+			FileSize:    j.blobGC.BlobFileSize,
+			Compression: j.blobGC.Compression,
+		})
+	}
+	return j.blobs.Add(userKey, value)
+}
+
+// oldestBlobFile returns the older of oldest (0 = none) and the blob file
+// blobIndex refers to. Undecodable references are ignored; reads report
+// them as corruption.
+func oldestBlobFile(oldest uint64, blobIndex []byte) uint64 {
+	idx, err := blob.DecodeBlobIndex(blobIndex)
+	if err != nil {
+		return oldest
+	}
+	if oldest == 0 || idx.FileNumber < oldest {
+		return idx.FileNumber
+	}
+	return oldest
+}
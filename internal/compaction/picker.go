@@ -34,6 +34,10 @@ type LeveledCompactionPicker struct {
 	MaxBytesForLevelMulti float64 // Multiplier for each subsequent level
 	TargetFileSizeBase    uint64  // Target file size for L1
 	TargetFileSizeMulti   float64 // Multiplier for file size at each level
+
+	// MaxCompactionBytes bounds the total input size of a compaction.
+	// 0 means 25 times TargetFileSizeBase.
+	MaxCompactionBytes uint64
 }
 
 // DefaultLeveledCompactionPicker returns a picker with default settings.
@@ -126,15 +130,105 @@ func (p *LeveledCompactionPicker) targetSizeForLevel(level int) uint64 {
 	return size
 }
 
-// targetFileSizeForLevel returns the target file size for a level.
-func (p *LeveledCompactionPicker) targetFileSizeForLevel(level int) uint64 {
+// TargetFileSizeForLevel returns the size at which compaction output files
+// for level are cut: TargetFileSizeBase for L0 and L1, multiplied by
+// TargetFileSizeMulti for each level below L1.
+// Reference: RocksDB v10.7.5 options/cf_options.cc MaxFileSizeForLevel
+func (p *LeveledCompactionPicker) TargetFileSizeForLevel(level int) uint64 {
 	size := p.TargetFileSizeBase
-	for range level {
+	for i := 1; i < level; i++ {
 		size = uint64(float64(size) * p.TargetFileSizeMulti)
 	}
 	return size
 }
 
+// maxCompactionBytes returns the limit on a compaction's total input size.
+func (p *LeveledCompactionPicker) maxCompactionBytes() uint64 {
+	if p.MaxCompactionBytes > 0 {
+		return p.MaxCompactionBytes
+	}
+	return p.TargetFileSizeBase * 25
+}
+
+// availableOverlappingInputs returns the files in level overlapping
+// [smallest, largest] that are not being compacted.
+func availableOverlappingInputs(v *version.Version, level int, smallest, largest []byte) []*manifest.FileMetaData {
+	var available []*manifest.FileMetaData
+	for _, f := range v.OverlappingInputs(level, smallest, largest) {
+		if !f.BeingCompacted {
+			available = append(available, f)
+		}
+	}
+	return available
+}
+
+// keyRange returns the smallest and largest keys of the files in groups.
+func keyRange(groups ...[]*manifest.FileMetaData) (smallest, largest []byte) {
+	for _, files := range groups {
+		for _, f := range files {
+			if smallest == nil || compareKeys(f.Smallest, smallest) < 0 {
+				smallest = f.Smallest
+			}
+			if largest == nil || compareKeys(f.Largest, largest) > 0 {
+				largest = f.Largest
+			}
+		}
+	}
+	return smallest, largest
+}
+
+// expandStartInputs grows the start level inputs of a compaction into level
+// to every file of level within the key range of all the inputs, but only if
+// that pulls in no more files from level+1 and keeps the inputs within
+// MaxCompactionBytes. The output level files are rewritten either way, so the
+// extra files cost no extra output level I/O now and save a compaction of
+// the same output files later.
+// Reference: RocksDB v10.7.5 db/compaction/compaction_picker.cc SetupOtherInputs
+func (p *LeveledCompactionPicker) expandStartInputs(v *version.Version, level int, start, output []*manifest.FileMetaData) []*manifest.FileMetaData {
+	if len(output) == 0 {
+		return start
+	}
+	smallest, largest := keyRange(start, output)
+	expanded := v.OverlappingInputs(level, smallest, largest)
+	if len(expanded) <= len(start) {
+		return start
+	}
+	for _, f := range expanded {
+		if f.BeingCompacted {
+			return start
+		}
+	}
+	smallest, largest = keyRange(expanded)
+	if len(availableOverlappingInputs(v, level+1, smallest, largest)) != len(output) {
+		return start
+	}
+	if totalFileSize(expanded)+totalFileSize(output) > p.maxCompactionBytes() {
+		return start
+	}
+	return expanded
+}
+
+// SetGrandparents records in c the files of the level below its output level
+// that overlap its inputs, and limits how many bytes of them an output file
+// may overlap to MaxCompactionBytes.
+// Reference: RocksDB v10.7.5 db/compaction/compaction.cc (Compaction::Compaction, grandparents_)
+func (p *LeveledCompactionPicker) SetGrandparents(v *version.Version, c *Compaction) {
+	if c.OutputLevel+1 >= p.NumLevels {
+		return
+	}
+	c.Grandparents = v.OverlappingInputs(c.OutputLevel+1, c.SmallestKey, c.LargestKey)
+	c.MaxGrandparentOverlapBytes = p.maxCompactionBytes()
+}
+
+// totalFileSize returns the combined size of files.
+func totalFileSize(files []*manifest.FileMetaData) uint64 {
+	var total uint64
+	for _, f := range files {
+		total += f.FD.FileSize
+	}
+	return total
+}
+
 // pickL0Compaction picks a compaction from L0 to L1.
 func (p *LeveledCompactionPicker) pickL0Compaction(v *version.Version) *Compaction {
 	l0Files := v.Files(0)
@@ -153,32 +247,34 @@ func (p *LeveledCompactionPicker) pickL0Compaction(v *version.Version) *Compacti
 		return nil
 	}
 
-	// Start with available L0 files (they may overlap)
-	l0Input := &CompactionInputFiles{
-		Level: 0,
-		Files: make([]*manifest.FileMetaData, len(availableFiles)),
-	}
-	copy(l0Input.Files, availableFiles)
-
-	// Find the key range covered by L0 files
+	// Take the oldest L0 files (they may overlap) together with the L1 files
+	// they overlap, for as long as the inputs fit in MaxCompactionBytes.
+	// Newer L0 files stay behind, so no key moves below a newer version of
+	// itself. The oldest file is always compacted.
+	limit := p.maxCompactionBytes()
+	var l0Picked, l1Available []*manifest.FileMetaData
 	var smallest, largest []byte
-	for _, f := range availableFiles {
-		if smallest == nil || compareKeys(f.Smallest, smallest) < 0 {
-			smallest = f.Smallest
+	for i, f := range availableFiles {
+		s, l := smallest, largest
+		if s == nil || compareKeys(f.Smallest, s) < 0 {
+			s = f.Smallest
 		}
-		if largest == nil || compareKeys(f.Largest, largest) > 0 {
-			largest = f.Largest
+		if l == nil || compareKeys(f.Largest, l) > 0 {
+			l = f.Largest
 		}
+		l1Files := availableOverlappingInputs(v, 1, s, l)
+		if i > 0 && totalFileSize(availableFiles[:i+1])+totalFileSize(l1Files) > limit {
+			break
+		}
+		smallest, largest = s, l
+		l0Picked, l1Available = availableFiles[:i+1], l1Files
 	}
 
-	// Find overlapping files in L1 that are not being compacted
-	l1Files := v.OverlappingInputs(1, smallest, largest)
-	var l1Available []*manifest.FileMetaData
-	for _, f := range l1Files {
-		if !f.BeingCompacted {
-			l1Available = append(l1Available, f)
-		}
+	l0Input := &CompactionInputFiles{
+		Level: 0,
+		Files: make([]*manifest.FileMetaData, len(l0Picked)),
 	}
+	copy(l0Input.Files, l0Picked)
 	l1Input := &CompactionInputFiles{
 		Level: 1,
 		Files: l1Available,
@@ -192,7 +288,8 @@ func (p *LeveledCompactionPicker) pickL0Compaction(v *version.Version) *Compacti
 	c := NewCompaction(inputs, 1)
 	c.Reason = CompactionReasonLevelL0FileNumTrigger
 	c.Score = float64(len(l0Files)) / float64(p.L0CompactionTrigger)
-	c.MaxOutputFileSize = p.targetFileSizeForLevel(1)
+	c.MaxOutputFileSize = p.TargetFileSizeForLevel(1)
+	p.SetGrandparents(v, c)
 
 	return c
 }
@@ -204,18 +301,32 @@ func (p *LeveledCompactionPicker) pickLevelCompaction(v *version.Version, level
 		return nil
 	}
 
-	// Pick the file with the largest size that is not being compacted (simple heuristic)
-	var picked *manifest.FileMetaData
-	var maxSize uint64
+	// Pick the file with the largest size that is not being compacted (simple
+	// heuristic), preferring files whose compaction with the overlapping
+	// files in level+1 fits in MaxCompactionBytes. If none fits, the largest
+	// file is compacted anyway so the level can still shrink.
+	nextLevel := level + 1
+	limit := p.maxCompactionBytes()
+	var picked, largest *manifest.FileMetaData
+	var nextLevelAvailable, largestOverlap []*manifest.FileMetaData
 	for _, f := range files {
 		if f.BeingCompacted {
 			continue
 		}
-		if f.FD.FileSize > maxSize {
-			maxSize = f.FD.FileSize
-			picked = f
+		overlap := availableOverlappingInputs(v, nextLevel, f.Smallest, f.Largest)
+		if largest == nil || f.FD.FileSize > largest.FD.FileSize {
+			largest, largestOverlap = f, overlap
+		}
+		if f.FD.FileSize+totalFileSize(overlap) > limit {
+			continue
+		}
+		if picked == nil || f.FD.FileSize > picked.FD.FileSize {
+			picked, nextLevelAvailable = f, overlap
 		}
 	}
+	if picked == nil {
+		picked, nextLevelAvailable = largest, largestOverlap
+	}
 
 	if picked == nil {
 		return nil
@@ -223,18 +334,9 @@ func (p *LeveledCompactionPicker) pickLevelCompaction(v *version.Version, level
 
 	levelInput := &CompactionInputFiles{
 		Level: level,
-		Files: []*manifest.FileMetaData{picked},
+		Files: p.expandStartInputs(v, level, []*manifest.FileMetaData{picked}, nextLevelAvailable),
 	}
 
-	// Find overlapping files in level+1 that are not being compacted
-	nextLevel := level + 1
-	nextLevelFiles := v.OverlappingInputs(nextLevel, picked.Smallest, picked.Largest)
-	var nextLevelAvailable []*manifest.FileMetaData
-	for _, f := range nextLevelFiles {
-		if !f.BeingCompacted {
-			nextLevelAvailable = append(nextLevelAvailable, f)
-		}
-	}
 	nextLevelInput := &CompactionInputFiles{
 		Level: nextLevel,
 		Files: nextLevelAvailable,
@@ -248,7 +350,8 @@ func (p *LeveledCompactionPicker) pickLevelCompaction(v *version.Version, level
 	c := NewCompaction(inputs, nextLevel)
 	c.Reason = CompactionReasonLevelMaxLevelSize
 	c.Score = score
-	c.MaxOutputFileSize = p.targetFileSizeForLevel(nextLevel)
+	c.MaxOutputFileSize = p.TargetFileSizeForLevel(nextLevel)
+	p.SetGrandparents(v, c)
 
 	return c
 }
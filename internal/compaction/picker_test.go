@@ -1,6 +1,7 @@
 package compaction
 
 import (
+	"slices"
 	"testing"
 	"time"
 
@@ -373,25 +374,22 @@ func TestTargetFileSizeForLevel(t *testing.T) {
 	picker.TargetFileSizeBase = 64 * 1024 * 1024 // 64 MB
 	picker.TargetFileSizeMulti = 2
 
-	// The function multiplies by TargetFileSizeMulti for each level
-	// level 0: base (loop runs 0 times)
-	// level 1: base * 2 (loop runs 1 time)
-	// level 2: base * 2 * 2 = base * 4 (loop runs 2 times)
+	// TargetFileSizeBase applies to L1; each level below multiplies it
 	tests := []struct {
 		level    int
 		expected uint64
 	}{
-		{0, 64 * 1024 * 1024},   // L0: base
-		{1, 128 * 1024 * 1024},  // L1: base * 2
-		{2, 256 * 1024 * 1024},  // L2: base * 4
-		{3, 512 * 1024 * 1024},  // L3: base * 8
-		{4, 1024 * 1024 * 1024}, // L4: base * 16
+		{0, 64 * 1024 * 1024},  // L0: base
+		{1, 64 * 1024 * 1024},  // L1: base
+		{2, 128 * 1024 * 1024}, // L2: base * 2
+		{3, 256 * 1024 * 1024}, // L3: base * 4
+		{4, 512 * 1024 * 1024}, // L4: base * 8
 	}
 
 	for _, tt := range tests {
-		got := picker.targetFileSizeForLevel(tt.level)
+		got := picker.TargetFileSizeForLevel(tt.level)
 		if got != tt.expected {
-			t.Errorf("targetFileSizeForLevel(%d) = %d, want %d", tt.level, got, tt.expected)
+			t.Errorf("TargetFileSizeForLevel(%d) = %d, want %d", tt.level, got, tt.expected)
 		}
 	}
 }
@@ -1033,3 +1031,169 @@ func TestFIFOCompactionPickerNoFileCreationTime(t *testing.T) {
 		t.Errorf("Should delete file 1 (oldest by seqno), got file %d", inputFiles[0].FD.GetNumber())
 	}
 }
+
+// TestLeveledCompactionPickerMaxCompactionBytesL0 tests that L0 compactions
+// take only the oldest L0 files that fit in MaxCompactionBytes.
+func TestLeveledCompactionPickerMaxCompactionBytesL0(t *testing.T) {
+	picker := DefaultLeveledCompactionPicker()
+	picker.L0CompactionTrigger = 4
+	picker.MaxCompactionBytes = 3500
+
+	vset := version.NewVersionSet(version.VersionSetOptions{})
+	v := version.NewVersion(vset, 1)
+
+	edit := manifest.NewVersionEdit()
+	for i := range 4 {
+		edit.AddFile(0, makeTestFileMetaData(uint64(i+1), 1000, []byte("a"), []byte("z")))
+	}
+	edit.AddFile(1, makeTestFileMetaData(10, 1000, []byte("a"), []byte("z")))
+
+	builder := version.NewBuilder(vset, v)
+	if err := builder.Apply(edit); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	v = builder.SaveTo(vset)
+
+	c := picker.PickCompaction(v)
+	if c == nil {
+		t.Fatal("Expected compaction to be picked")
+	}
+
+	// Two L0 files plus the L1 file fit; a third L0 file would not
+	l0 := c.Inputs[0].Files
+	if len(l0) != 2 {
+		t.Fatalf("L0 inputs = %d, want 2", len(l0))
+	}
+	if l0[0].FD.GetNumber() != 1 || l0[1].FD.GetNumber() != 2 {
+		t.Errorf("L0 inputs = [%d %d], want the oldest files [1 2]",
+			l0[0].FD.GetNumber(), l0[1].FD.GetNumber())
+	}
+	if len(c.Inputs) != 2 || len(c.Inputs[1].Files) != 1 {
+		t.Errorf("Expected the overlapping L1 file as input")
+	}
+
+	// With a limit below a single file, the oldest file is still compacted
+	picker.MaxCompactionBytes = 1
+	c = picker.PickCompaction(v)
+	if c == nil || len(c.Inputs[0].Files) != 1 || c.Inputs[0].Files[0].FD.GetNumber() != 1 {
+		t.Error("Expected a compaction of the oldest L0 file alone")
+	}
+}
+
+// TestLeveledCompactionPickerMaxCompactionBytesLevel tests that level
+// compactions prefer files whose inputs fit in MaxCompactionBytes.
+func TestLeveledCompactionPickerMaxCompactionBytesLevel(t *testing.T) {
+	picker := DefaultLeveledCompactionPicker()
+	picker.L0CompactionTrigger = 100 // Disable L0 trigger
+	picker.MaxBytesForLevelBase = 1000
+	picker.MaxCompactionBytes = 5000
+
+	vset := version.NewVersionSet(version.VersionSetOptions{})
+	v := version.NewVersion(vset, 1)
+
+	// File 10 is the largest but overlaps a lot of L2 data
+	edit := manifest.NewVersionEdit()
+	edit.AddFile(1, makeTestFileMetaData(10, 3000, []byte("a"), []byte("m")))
+	edit.AddFile(1, makeTestFileMetaData(11, 2000, []byte("n"), []byte("z")))
+	edit.AddFile(2, makeTestFileMetaData(20, 4000, []byte("b"), []byte("c")))
+	edit.AddFile(2, makeTestFileMetaData(21, 1000, []byte("o"), []byte("p")))
+
+	builder := version.NewBuilder(vset, v)
+	if err := builder.Apply(edit); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	v = builder.SaveTo(vset)
+
+	c := picker.PickCompaction(v)
+	if c == nil {
+		t.Fatal("Expected compaction to be picked")
+	}
+	if got := c.Inputs[0].Files[0].FD.GetNumber(); got != 11 {
+		t.Errorf("Picked file %d, want 11", got)
+	}
+
+	// When no file fits, the largest file is compacted anyway
+	picker.MaxCompactionBytes = 1
+	c = picker.PickCompaction(v)
+	if c == nil {
+		t.Fatal("Expected compaction to be picked")
+	}
+	if got := c.Inputs[0].Files[0].FD.GetNumber(); got != 10 {
+		t.Errorf("Picked file %d, want 10", got)
+	}
+}
+
+// TestLeveledCompactionPickerMinimalOutputInputs tests that a level
+// compaction takes only the output level files its range overlaps, grows the
+// start level inputs only where that adds no output level files, and records
+// the overlapping grandparents.
+func TestLeveledCompactionPickerMinimalOutputInputs(t *testing.T) {
+	picker := DefaultLeveledCompactionPicker()
+	picker.L0CompactionTrigger = 100 // Disable L0 trigger
+	picker.MaxBytesForLevelBase = 1000
+	picker.MaxCompactionBytes = 10000
+
+	vset := version.NewVersionSet(version.VersionSetOptions{})
+	v := version.NewVersion(vset, 1)
+
+	file := func(num, size uint64, smallest, largest string) *manifest.FileMetaData {
+		return makeTestFileMetaData(num, size, makeInternalKey(smallest, 100, 1), makeInternalKey(largest, 100, 1))
+	}
+	edit := manifest.NewVersionEdit()
+	edit.AddFile(1, file(10, 3000, "c", "d"))
+	edit.AddFile(1, file(11, 500, "e", "f"))
+	edit.AddFile(1, file(12, 500, "m", "n"))
+	edit.AddFile(2, file(20, 1000, "a", "b"))
+	edit.AddFile(2, file(21, 1000, "c", "g"))
+	edit.AddFile(2, file(22, 1000, "h", "k"))
+	edit.AddFile(2, file(23, 1000, "m", "p"))
+	edit.AddFile(2, file(24, 1000, "x", "z"))
+	edit.AddFile(3, file(30, 100, "a", "c"))
+	edit.AddFile(3, file(31, 100, "d", "e"))
+	edit.AddFile(3, file(32, 100, "q", "z"))
+
+	builder := version.NewBuilder(vset, v)
+	if err := builder.Apply(edit); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	v = builder.SaveTo(vset)
+
+	c := picker.PickCompaction(v)
+	if c == nil {
+		t.Fatal("Expected compaction to be picked")
+	}
+
+	fileNumbers := func(files []*manifest.FileMetaData) []uint64 {
+		var nums []uint64
+		for _, f := range files {
+			nums = append(nums, f.FD.GetNumber())
+		}
+		return nums
+	}
+	if len(c.Inputs) != 2 {
+		t.Fatalf("Inputs = %d levels, want 2", len(c.Inputs))
+	}
+	// File 11 fits in the range of file 21; file 12 would pull in file 23
+	if got := fileNumbers(c.Inputs[0].Files); !slices.Equal(got, []uint64{10, 11}) {
+		t.Errorf("L1 inputs = %v, want [10 11]", got)
+	}
+	if got := fileNumbers(c.Inputs[1].Files); !slices.Equal(got, []uint64{21}) {
+		t.Errorf("L2 inputs = %v, want [21]", got)
+	}
+	if got := fileNumbers(c.Grandparents); !slices.Equal(got, []uint64{30, 31}) {
+		t.Errorf("Grandparents = %v, want [30 31]", got)
+	}
+	if c.MaxGrandparentOverlapBytes != picker.MaxCompactionBytes {
+		t.Errorf("MaxGrandparentOverlapBytes = %d, want %d", c.MaxGrandparentOverlapBytes, picker.MaxCompactionBytes)
+	}
+
+	// Without room for file 11, the start level is not expanded
+	picker.MaxCompactionBytes = 4200
+	c = picker.PickCompaction(v)
+	if c == nil {
+		t.Fatal("Expected compaction to be picked")
+	}
+	if got := fileNumbers(c.Inputs[0].Files); !slices.Equal(got, []uint64{10}) {
+		t.Errorf("L1 inputs = %v, want [10]", got)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"sync/atomic"
 
 	"github.com/aalhour/rockyardkv/internal/block"
+	"github.com/aalhour/rockyardkv/internal/compression"
 	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/iterator"
 	"github.com/aalhour/rockyardkv/internal/manifest"
@@ -60,6 +61,9 @@ type SubcompactionStats struct {
 
 	// Number of output files
 	NumOutputFiles int
+
+	// Bytes of grandparents overlapped by the output files, summed over them
+	GrandparentOverlapBytes uint64
 }
 
 // ParallelCompactionJob runs a compaction job with subcompactions.
@@ -80,8 +84,8 @@ type ParallelCompactionJob struct {
 	// Aggregate statistics
 	stats SubcompactionStats
 
-	// Merge operator for combining merge operands during compaction
-	mergeOperator MergeOperator
+	// Settings shared with CompactionJob
+	jobOptions
 }
 
 // NewParallelCompactionJob creates a new parallel compaction job.
@@ -108,6 +112,7 @@ func NewParallelCompactionJob(
 		tableCache:        tableCache,
 		nextFileNum:       nextFileNum,
 		numSubcompactions: numSubcompactions,
+		jobOptions:        defaultJobOptions(),
 	}
 }
 
@@ -117,6 +122,67 @@ func (job *ParallelCompactionJob) SetMergeOperator(m MergeOperator) {
 	job.mergeOperator = m
 }
 
+// SetVerifyChecksums controls whether input block checksums are verified.
+func (job *ParallelCompactionJob) SetVerifyChecksums(verify bool) {
+	job.verifyChecksums = verify
+}
+
+// SetCompression sets the compression type for the outputs' data blocks.
+func (job *ParallelCompactionJob) SetCompression(c compression.Type) {
+	job.compression = c
+}
+
+// SetColumnFamily sets the column family recorded in the outputs'
+// properties. The default is the default column family.
+func (job *ParallelCompactionJob) SetColumnFamily(id uint32, name string) {
+	job.columnFamilyID = id
+	job.columnFamilyName = name
+}
+
+// SetVerifyCompression controls whether every compressed block is
+// decompressed and compared with the original.
+func (job *ParallelCompactionJob) SetVerifyCompression(verify bool) {
+	job.verifyCompression = verify
+}
+
+// SetFilterBitsPerKey sets the bloom filter bits per key of the outputs.
+// 0 writes no filters.
+func (job *ParallelCompactionJob) SetFilterBitsPerKey(bits int) {
+	job.filterBitsPerKey = bits
+}
+
+// SetPrefixExtractor makes the outputs' filters hold the key prefixes that
+// prefix returns, recorded under the extractor's name.
+func (job *ParallelCompactionJob) SetPrefixExtractor(name string, prefix func(key []byte) ([]byte, bool)) {
+	job.prefixName = name
+	job.prefix = prefix
+}
+
+// SetUserComparator sets the order of user keys used to clip range
+// tombstones to the output files. The default is bytewise.
+func (job *ParallelCompactionJob) SetUserComparator(cmp func(a, b []byte) int) {
+	job.userCmp = cmp
+}
+
+// SetSkipFilters controls whether the outputs are written without filter
+// blocks.
+func (job *ParallelCompactionJob) SetSkipFilters(skip bool) {
+	job.skipFilters = skip
+}
+
+// SetSnapshots sets the sequence numbers of the live snapshots. Without a
+// merge operator, an entry hidden by a newer entry of the same key is
+// dropped unless one of these snapshots can still see it.
+func (job *ParallelCompactionJob) SetSnapshots(snapshots []dbformat.SequenceNumber) {
+	job.snapshots = snapshots
+}
+
+// SetBlobResolver sets how blob references are read when merge operands
+// must be applied to a separated value.
+func (job *ParallelCompactionJob) SetBlobResolver(r BlobResolver) {
+	job.blobResolver = r
+}
+
 // Run executes the parallel compaction job.
 func (job *ParallelCompactionJob) Run() ([]*manifest.FileMetaData, error) {
 	// Partition the key range
@@ -125,10 +191,25 @@ func (job *ParallelCompactionJob) Run() ([]*manifest.FileMetaData, error) {
 	if len(boundaries) <= 2 {
 		// Not enough range to parallelize, use single compaction
 		singleJob := NewCompactionJob(job.compaction, job.dbPath, job.fs, job.tableCache, job.nextFileNum)
-		if job.mergeOperator != nil {
-			singleJob.SetMergeOperator(job.mergeOperator)
+		singleJob.jobOptions = job.jobOptions
+		singleJob.SetSnapshots(job.snapshots)
+		outputs, err := singleJob.Run()
+		if err != nil {
+			return nil, err
 		}
-		return singleJob.Run()
+		job.stats.NumInputRecords, job.stats.NumOutputRecords = singleJob.RecordStats()
+		for _, input := range job.compaction.Inputs {
+			for _, f := range input.Files {
+				job.stats.BytesRead += f.FD.FileSize
+			}
+		}
+		for _, f := range outputs {
+			job.stats.BytesWritten += f.FD.FileSize
+		}
+		job.stats.NumOutputFiles = len(outputs)
+		job.stats.GrandparentOverlapBytes = singleJob.GrandparentOverlapBytes()
+		job.outputFiles = outputs
+		return outputs, nil
 	}
 
 	// Create subcompactions
@@ -200,6 +281,7 @@ func (job *ParallelCompactionJob) Run() ([]*manifest.FileMetaData, error) {
 		job.stats.BytesRead += sub.stats.BytesRead
 		job.stats.BytesWritten += sub.stats.BytesWritten
 		job.stats.NumOutputFiles += sub.stats.NumOutputFiles
+		job.stats.GrandparentOverlapBytes += sub.stats.GrandparentOverlapBytes
 	}
 
 	// Add output files to the compaction's version edit
@@ -298,7 +380,8 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 			if err != nil {
 				return fmt.Errorf("failed to open SST %d: %w", f.FD.GetNumber(), err)
 			}
-			iter := reader.NewIterator()
+			defer job.tableCache.Release(f.FD.GetNumber())
+			iter := reader.NewIteratorWithVerify(job.verifyChecksums)
 			iters = append(iters, iter)
 			sub.stats.BytesRead += f.FD.FileSize
 		}
@@ -315,11 +398,6 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 	var currentBuilder *table.TableBuilder
 	var currentFile *manifest.FileMetaData
 	var currentPath string
-	var entriesInCurrentFile uint64
-
-	// Target entries per file (estimate based on max output size and average entry size)
-	const avgEntrySize = 100 // bytes
-	targetEntriesPerFile := job.compaction.MaxOutputFileSize / avgEntrySize
 
 	finishCurrentFile := func() error {
 		if currentBuilder == nil {
@@ -340,10 +418,10 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 		sub.outputs = append(sub.outputs, currentFile)
 		sub.stats.NumOutputFiles++
 		sub.stats.BytesWritten += currentFile.FD.FileSize
+		sub.stats.GrandparentOverlapBytes += job.compaction.GrandparentOverlapBytes(currentFile.Smallest, currentFile.Largest)
 
 		currentBuilder = nil
 		currentFile = nil
-		entriesInCurrentFile = 0
 		return nil
 	}
 
@@ -356,20 +434,46 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 			return err
 		}
 
-		currentBuilder = table.NewTableBuilder(file, table.DefaultBuilderOptions())
+		opts := table.DefaultBuilderOptions()
+		opts.ColumnFamilyID = job.columnFamilyID
+		opts.ColumnFamilyName = job.columnFamilyName
+		opts.Compression = job.compression
+		opts.FilterBitsPerKey = job.filterBitsPerKey
+		opts.VerifyCompression = job.verifyCompression
+		opts.Prefix = job.prefix
+		opts.PrefixExtractorName = job.prefixName
+		if job.skipFilters {
+			opts.FilterBitsPerKey = 0
+		}
+		currentBuilder = table.NewTableBuilder(file, opts)
 		currentFile = manifest.NewFileMetaData()
 		currentFile.FD = manifest.NewFileDescriptor(fileNum, 0, 0)
-		entriesInCurrentFile = 0
 		return nil
 	}
 
+	// Grandparents overlapped by the current file
+	grandparents := newGrandparentOverlap(job.compaction)
+
 	// Helper to write an entry to the current file
 	writeEntry := func(internalKey, value []byte) error {
+		// Finish a full file, or one overlapping too many grandparent bytes,
+		// cutting only between user keys
+		overlapExceeded := grandparents.exceeded(internalKey)
+		full := job.compaction.MaxOutputFileSize > 0 &&
+			currentBuilder != nil && currentBuilder.EstimatedFileSize() >= job.compaction.MaxOutputFileSize
+		if currentBuilder != nil && (full || overlapExceeded) &&
+			!bytes.Equal(extractUserKey(internalKey), extractUserKey(currentFile.Largest)) {
+			if err := finishCurrentFile(); err != nil {
+				return err
+			}
+		}
+
 		// Start a new file if needed
 		if currentBuilder == nil {
 			if err := startNewFile(); err != nil {
 				return err
 			}
+			grandparents.reset()
 		}
 
 		// Track key range
@@ -377,31 +481,28 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 			currentFile.Smallest = append([]byte(nil), internalKey...)
 		}
 		currentFile.Largest = append(currentFile.Largest[:0], internalKey...)
+		if dbformat.ExtractValueType(internalKey) == dbformat.TypeBlobIndex {
+			currentFile.OldestBlobFileNumber = oldestBlobFile(currentFile.OldestBlobFileNumber, value)
+		}
 
 		// Add to current file
 		if err := currentBuilder.Add(internalKey, value); err != nil {
 			return err
 		}
 		sub.stats.NumOutputRecords++
-		entriesInCurrentFile++
-
-		// Check if we need to finish the current file (based on entry count estimate)
-		if entriesInCurrentFile >= targetEntriesPerFile {
-			if err := finishCurrentFile(); err != nil {
-				return err
-			}
-		}
-
 		return nil
 	}
 
+	// Drops versions that no snapshot can see
+	obsolete := newObsoleteTracker(job.snapshots)
+
 	// Merge accumulator state (only used when merge operator is configured)
 	var currentUserKey []byte
 	var mergeOperands [][]byte
 	var baseSeqNum dbformat.SequenceNumber
 
 	// Helper to flush accumulated merge operands
-	flushMergeOperands := func(baseValue []byte) error {
+	flushMergeOperands := func(baseValue []byte, baseType dbformat.ValueType) error {
 		if len(mergeOperands) == 0 || job.mergeOperator == nil {
 			return nil
 		}
@@ -412,12 +513,12 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 			reversed[len(mergeOperands)-1-i] = op
 		}
 
-		mergedValue, ok := job.mergeOperator.FullMerge(currentUserKey, baseValue, reversed)
-		if !ok {
-			return fmt.Errorf("merge operator failed for key %q", currentUserKey)
+		mergedValue, mergedType, err := fullMerge(job.mergeOperator, currentUserKey, baseValue, baseType, reversed)
+		if err != nil {
+			return err
 		}
 
-		internalKey := dbformat.NewInternalKey(currentUserKey, baseSeqNum, dbformat.TypeValue)
+		internalKey := dbformat.NewInternalKey(currentUserKey, baseSeqNum, mergedType)
 		return writeEntry(internalKey, mergedValue)
 	}
 
@@ -448,8 +549,12 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 
 		sub.stats.NumInputRecords++
 
-		// If no merge operator, write entries as-is (original behavior)
+		// If no merge operator, write entries as-is unless a newer version
+		// hides them from every snapshot
 		if job.mergeOperator == nil {
+			if obsolete.isObsolete(key) {
+				continue
+			}
 			if err := writeEntry(key, value); err != nil {
 				return err
 			}
@@ -464,7 +569,7 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 		if currentUserKey == nil || !bytes.Equal(userKey, currentUserKey) {
 			// Flush any pending merge operands without a base value
 			if len(mergeOperands) > 0 {
-				if err := flushMergeOperands(nil); err != nil {
+				if err := flushMergeOperands(nil, dbformat.TypeValue); err != nil {
 					return err
 				}
 			}
@@ -484,7 +589,7 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 		case dbformat.TypeValue:
 			// Found a Put - flush merge operands with this base value
 			if len(mergeOperands) > 0 {
-				if err := flushMergeOperands(value); err != nil {
+				if err := flushMergeOperands(value, dbformat.TypeValue); err != nil {
 					return err
 				}
 				resetMergeState()
@@ -495,6 +600,39 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 				}
 			}
 
+		case dbformat.TypeBlobIndex:
+			// Merge operands need the separated value itself as their base
+			if len(mergeOperands) > 0 {
+				if job.blobResolver == nil {
+					return fmt.Errorf("merge over blob reference for key %q: no blob resolver", userKey)
+				}
+				base, err := job.blobResolver(value)
+				if err != nil {
+					return err
+				}
+				if err := flushMergeOperands(base, dbformat.TypeValue); err != nil {
+					return err
+				}
+				resetMergeState()
+			} else {
+				if err := writeEntry(key, value); err != nil {
+					return err
+				}
+			}
+
+		case dbformat.TypeWideColumnEntity:
+			// Merge operands apply to the entity's default column
+			if len(mergeOperands) > 0 {
+				if err := flushMergeOperands(value, dbformat.TypeWideColumnEntity); err != nil {
+					return err
+				}
+				resetMergeState()
+			} else {
+				if err := writeEntry(key, value); err != nil {
+					return err
+				}
+			}
+
 		case dbformat.TypeDeletion, dbformat.TypeSingleDeletion:
 			// Delete discards any accumulated merge operands
 			resetMergeState()
@@ -512,12 +650,16 @@ func (job *ParallelCompactionJob) runSubcompaction(sub *SubcompactionState) erro
 	}
 
 	if err := merged.Error(); err != nil {
+		// Don't leave a partially written output behind
+		if currentBuilder != nil {
+			_ = job.fs.Remove(currentPath)
+		}
 		return err
 	}
 
 	// Flush any remaining merge operands (without a base value)
 	if len(mergeOperands) > 0 {
-		if err := flushMergeOperands(nil); err != nil {
+		if err := flushMergeOperands(nil, dbformat.TypeValue); err != nil {
 			return err
 		}
 	}
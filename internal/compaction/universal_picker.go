@@ -151,7 +151,7 @@ func (p *UniversalCompactionPicker) getSortedRuns(v *version.Version) []*sortedR
 	}
 
 	// Levels 1-6: each level is a single sorted run
-	for level := 1; level < version.MaxNumLevels; level++ {
+	for level := 1; level < v.NumLevels(); level++ {
 		files := v.Files(level)
 		if len(files) == 0 {
 			continue
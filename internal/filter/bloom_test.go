@@ -338,3 +338,28 @@ func BenchmarkBloomFilterQuery(b *testing.B) {
 		reader.MayContain(key)
 	}
 }
+
+func TestDynamicBloom(t *testing.T) {
+	b := NewDynamicBloom(1000 * 10)
+
+	for i := range 1000 {
+		b.Add(fmt.Appendf(nil, "key%d", i))
+	}
+
+	// No false negatives
+	for i := range 1000 {
+		if !b.MayContain(fmt.Appendf(nil, "key%d", i)) {
+			t.Fatalf("MayContain(key%d) = false after Add", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := range 10000 {
+		if b.MayContain(fmt.Appendf(nil, "absent%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.05 {
+		t.Errorf("false positive rate = %.4f, want <= 0.05", rate)
+	}
+}
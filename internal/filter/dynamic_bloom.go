@@ -0,0 +1,80 @@
+package filter
+
+// dynamic_bloom.go implements DynamicBloom, a fixed-size Bloom filter that
+// keys can be added to while it is being queried. Memtables use it to answer
+// point lookups for absent keys without searching the skiplist.
+//
+// Like the SST filter, every key sets its probes within a single 512-bit
+// cache line.
+//
+// Reference: RocksDB v10.7.5
+//   - util/dynamic_bloom.h
+//   - util/dynamic_bloom.cc
+
+import (
+	"sync/atomic"
+
+	"github.com/aalhour/rockyardkv/internal/checksum"
+)
+
+// dynamicBloomProbes is the number of probes per key, as RocksDB's default
+// memtable bloom uses.
+const dynamicBloomProbes = 6
+
+// wordsPerCacheLine is the number of 64-bit words in a cache line.
+const wordsPerCacheLine = CacheLineBits / 64
+
+// DynamicBloom is a Bloom filter of fixed size that supports concurrent Add
+// and MayContain calls.
+type DynamicBloom struct {
+	data          []atomic.Uint64
+	numCacheLines uint32
+}
+
+// NewDynamicBloom creates a filter of at least totalBits bits, rounded up to
+// a whole number of cache lines.
+func NewDynamicBloom(totalBits uint32) *DynamicBloom {
+	numCacheLines := max((totalBits+CacheLineBits-1)/CacheLineBits, 1)
+	return &DynamicBloom{
+		data:          make([]atomic.Uint64, numCacheLines*wordsPerCacheLine),
+		numCacheLines: numCacheLines,
+	}
+}
+
+// Add adds a key to the filter.
+func (b *DynamicBloom) Add(key []byte) {
+	h := checksum.XXH3_64bits(key)
+	line := b.cacheLine(uint32(h))
+	probe := uint32(h >> 32)
+	for range dynamicBloomProbes {
+		bitpos := probe >> (32 - 9)
+		line[bitpos>>6].Or(1 << (bitpos & 63))
+		probe *= 0x9e3779b9
+	}
+}
+
+// MayContain returns false if the key was definitely never added.
+func (b *DynamicBloom) MayContain(key []byte) bool {
+	h := checksum.XXH3_64bits(key)
+	line := b.cacheLine(uint32(h))
+	probe := uint32(h >> 32)
+	for range dynamicBloomProbes {
+		bitpos := probe >> (32 - 9)
+		if line[bitpos>>6].Load()&(1<<(bitpos&63)) == 0 {
+			return false
+		}
+		probe *= 0x9e3779b9
+	}
+	return true
+}
+
+// ApproximateMemoryUsage returns the size of the filter in bytes.
+func (b *DynamicBloom) ApproximateMemoryUsage() int {
+	return len(b.data) * 8
+}
+
+// cacheLine returns the words of the cache line selected by h.
+func (b *DynamicBloom) cacheLine(h uint32) []atomic.Uint64 {
+	start := fastRange32(h, b.numCacheLines) * wordsPerCacheLine
+	return b.data[start : start+wordsPerCacheLine]
+}
@@ -0,0 +1,24 @@
+package flush
+
+// blob.go configures the separation of large values into blob files
+// during flush.
+//
+// Reference: RocksDB v10.7.5
+//   - db/blob/blob_file_builder.h
+
+import (
+	"github.com/aalhour/rockyardkv/internal/compression"
+)
+
+// BlobOptions configures the separation of large values into blob files.
+type BlobOptions struct {
+	// MinBlobSize is the smallest value written to a blob file
+	MinBlobSize uint64
+
+	// BlobFileSize is the size at which a new blob file is started
+	// (0 = never roll)
+	BlobFileSize uint64
+
+	// Compression is the compression type for blob values
+	Compression compression.Type
+}
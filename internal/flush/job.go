@@ -11,6 +11,10 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/aalhour/rockyardkv/internal/blob"
+	"github.com/aalhour/rockyardkv/internal/compression"
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/iterator"
 	"github.com/aalhour/rockyardkv/internal/manifest"
 	"github.com/aalhour/rockyardkv/internal/memtable"
 	"github.com/aalhour/rockyardkv/internal/table"
@@ -37,27 +41,99 @@ type DB interface {
 
 	// ComparatorName returns the name of the comparator.
 	ComparatorName() string
+
+	// NowMicros returns the current time from the database's clock.
+	NowMicros() uint64
 }
 
-// Job flushes a memtable to an SST file.
+// Job flushes one or more memtables to an SST file.
 type Job struct {
 	db DB
 
-	// The memtable being flushed
-	mem *memtable.MemTable
+	// The memtables being flushed, merged into one file
+	mems []*memtable.MemTable
 
 	// Output file number
 	fileNum uint64
+
+	// Column family recorded in the output's properties
+	columnFamilyID   uint32
+	columnFamilyName string
+
+	// Compression for the output's data blocks
+	compression compression.Type
+
+	// Bloom filter bits per key of the output (0 = no filter)
+	filterBitsPerKey int
+
+	// Check that compressed blocks decompress to their contents
+	verifyCompression bool
+
+	// Prefixes added to the output's filter (nil = whole keys only)
+	prefixName string
+	prefix     func(key []byte) ([]byte, bool)
+
+	// Separation of large values into blob files (nil = disabled)
+	blobOpts *BlobOptions
+
+	// Blob files written by a successful run
+	blobFiles []uint64
 }
 
-// NewJob creates a new flush job for the given memtable.
-func NewJob(db DB, mem *memtable.MemTable) *Job {
+// NewJob creates a new flush job that writes the given memtables, which
+// must share a comparator, to a single SST file.
+func NewJob(db DB, mems ...*memtable.MemTable) *Job {
 	return &Job{
-		db:  db,
-		mem: mem,
+		db:               db,
+		mems:             mems,
+		columnFamilyName: table.DefaultBuilderOptions().ColumnFamilyName,
+		filterBitsPerKey: table.DefaultBuilderOptions().FilterBitsPerKey,
 	}
 }
 
+// SetColumnFamily sets the column family recorded in the output's
+// properties. The default is the default column family.
+func (fj *Job) SetColumnFamily(id uint32, name string) {
+	fj.columnFamilyID = id
+	fj.columnFamilyName = name
+}
+
+// SetCompression sets the compression type for the output's data blocks.
+func (fj *Job) SetCompression(c compression.Type) {
+	fj.compression = c
+}
+
+// SetVerifyCompression controls whether every compressed block is
+// decompressed and compared with the original.
+func (fj *Job) SetVerifyCompression(verify bool) {
+	fj.verifyCompression = verify
+}
+
+// SetFilterBitsPerKey sets the bloom filter bits per key of the output.
+// 0 writes no filter.
+func (fj *Job) SetFilterBitsPerKey(bits int) {
+	fj.filterBitsPerKey = bits
+}
+
+// SetPrefixExtractor makes the output's filters hold the key prefixes that
+// prefix returns, recorded under the extractor's name.
+func (fj *Job) SetPrefixExtractor(name string, prefix func(key []byte) ([]byte, bool)) {
+	fj.prefixName = name
+	fj.prefix = prefix
+}
+
+// SetBlobOptions enables blob separation: values of at least
+// opts.MinBlobSize bytes are written to blob files, and the SST stores a
+// TypeBlobIndex entry referencing them instead.
+func (fj *Job) SetBlobOptions(opts BlobOptions) {
+	fj.blobOpts = &opts
+}
+
+// BlobFiles returns the numbers of the blob files written by Run.
+func (fj *Job) BlobFiles() []uint64 {
+	return fj.blobFiles
+}
+
 // Run executes the flush job.
 // Returns the metadata of the created SST file, or an error.
 func (fj *Job) Run() (*manifest.FileMetaData, error) {
@@ -85,13 +161,44 @@ func (fj *Job) Run() (*manifest.FileMetaData, error) {
 	}
 	defer func() { _ = file.Close() }()
 
+	var blobs *blob.Builder
+	if fj.blobOpts != nil {
+		blobs = blob.NewBuilder(fj.db.FS(), fj.db.DBPath(), fj.db.NextFileNumber, blob.BuilderOptions{
+			FileSize:    fj.blobOpts.BlobFileSize,
+			Compression: fj.blobOpts.Compression,
+		})
+	}
+	succeeded := false
+	defer func() {
+		if blobs != nil && !succeeded {
+			blobs.Abandon()
+		}
+	}()
+
 	// Create table builder
 	opts := table.DefaultBuilderOptions()
 	opts.ComparatorName = fj.db.ComparatorName()
+	opts.ColumnFamilyID = fj.columnFamilyID
+	opts.ColumnFamilyName = fj.columnFamilyName
+	opts.Compression = fj.compression
+	opts.FilterBitsPerKey = fj.filterBitsPerKey
+	opts.VerifyCompression = fj.verifyCompression
+	opts.Prefix = fj.prefix
+	opts.PrefixExtractorName = fj.prefixName
 	builder := table.NewTableBuilder(file, opts)
 
-	// Iterate over the memtable and add all entries
-	iter := fj.mem.NewIterator()
+	// Iterate over the memtables in internal key order and add all entries
+	icmp := dbformat.NewInternalKeyComparator(dbformat.UserKeyComparer(fj.mems[0].UserComparator()))
+	var iter iterator.Iterator
+	if len(fj.mems) == 1 {
+		iter = fj.mems[0].NewIterator()
+	} else {
+		children := make([]iterator.Iterator, len(fj.mems))
+		for i, mem := range fj.mems {
+			children[i] = mem.NewIterator()
+		}
+		iter = iterator.NewMergingIterator(children, icmp.Compare)
+	}
 	var firstKey, lastKey []byte
 	var smallestSeq, largestSeq uint64
 
@@ -99,6 +206,18 @@ func (fj *Job) Run() (*manifest.FileMetaData, error) {
 		key := iter.Key()
 		value := iter.Value()
 
+		// Large values go to a blob file; the SST keeps a reference
+		if blobs != nil && dbformat.ExtractValueType(key) == dbformat.TypeValue && uint64(len(value)) >= fj.blobOpts.MinBlobSize {
+			userKey := dbformat.ExtractUserKey(key)
+			index, err := blobs.Add(userKey, value)
+			if err != nil {
+				builder.Abandon()
+				return nil, err
+			}
+			key = dbformat.NewInternalKey(userKey, dbformat.ExtractSequenceNumber(key), dbformat.TypeBlobIndex)
+			value = index
+		}
+
 		// The key from memtable iterator is an internal key
 		if err := builder.Add(key, value); err != nil {
 			builder.Abandon()
@@ -127,17 +246,42 @@ func (fj *Job) Run() (*manifest.FileMetaData, error) {
 	}
 
 	// Add range tombstones from the memtable to the SST file.
-	// Range tombstones are stored in a separate meta-block.
-	// Reference: RocksDB flushes range tombstones in flush_job.cc
+	// Range tombstones are stored in the "rocksdb.range_del" meta-block, never
+	// inline with point data, and widen the file boundaries so that readers
+	// consult them for every key they cover.
+	// Reference: RocksDB v10.7.5 db/builder.cc BuildTable
 	hasRangeTombstones := false
-	if fj.mem.HasRangeTombstones() {
-		tombstones := fj.mem.GetRangeTombstones()
+	haveSeq := builder.NumEntries() > 0
+	for _, mem := range fj.mems {
+		if !mem.HasRangeTombstones() {
+			continue
+		}
+		tombstones := mem.GetRangeTombstones()
 		if tombstones != nil && !tombstones.IsEmpty() {
 			if err := builder.AddRangeTombstones(tombstones); err != nil {
 				builder.Abandon()
 				return nil, fmt.Errorf("failed to add range tombstones to SST: %w", err)
 			}
 			hasRangeTombstones = true
+
+			for _, t := range tombstones.All() {
+				start := dbformat.NewInternalKey(t.StartKey, t.SequenceNum, dbformat.TypeRangeDeletion)
+				if firstKey == nil || icmp.Compare(start, firstKey) < 0 {
+					firstKey = start
+				}
+				// The end key is exclusive; the max sequence number sentinel sorts
+				// it before any point key with the same user key.
+				end := dbformat.NewInternalKey(t.EndKey, dbformat.MaxSequenceNumber, dbformat.TypeRangeDeletion)
+				if lastKey == nil || icmp.Compare(end, lastKey) > 0 {
+					lastKey = end
+				}
+				seq := uint64(t.SequenceNum)
+				if !haveSeq {
+					smallestSeq, largestSeq, haveSeq = seq, seq, true
+				}
+				smallestSeq = min(smallestSeq, seq)
+				largestSeq = max(largestSeq, seq)
+			}
 		}
 	}
 
@@ -149,6 +293,14 @@ func (fj *Job) Run() (*manifest.FileMetaData, error) {
 		return nil, ErrNoOutput
 	}
 
+	// Blob files must be durable before the SST that references them
+	if blobs != nil {
+		if err := blobs.Finish(); err != nil {
+			builder.Abandon()
+			return nil, err
+		}
+	}
+
 	// Finish the SST file
 	if err := builder.Finish(); err != nil {
 		return nil, fmt.Errorf("failed to finish SST file: %w", err)
@@ -187,7 +339,13 @@ func (fj *Job) Run() (*manifest.FileMetaData, error) {
 	meta.FD.LargestSeqno = manifest.SequenceNumber(largestSeq)
 	meta.Smallest = firstKey
 	meta.Largest = lastKey
+	meta.FileCreationTime = fj.db.NowMicros() / 1e6 // seconds, as in RocksDB
+	if blobs != nil && len(blobs.FileNumbers()) > 0 {
+		fj.blobFiles = blobs.FileNumbers()
+		meta.OldestBlobFileNumber = fj.blobFiles[0]
+	}
 
+	succeeded = true
 	return meta, nil
 }
 
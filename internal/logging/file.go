@@ -0,0 +1,269 @@
+package logging
+
+// file.go implements FileLogger, the info log written to the LOG file in the
+// database directory.
+//
+// Reference: RocksDB v10.7.5
+//   - logging/auto_roll_logger.cc (AutoRollLogger)
+//   - file/filename.cc (InfoLogFileName, OldInfoLogFileName)
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+// InfoLogFileName is the name of the info log file in the database directory.
+const InfoLogFileName = "LOG"
+
+// InfoLogger is a Logger whose messages can be logged at a given level and
+// whose level can be changed while it is in use.
+//
+// Reference: RocksDB v10.7.5 include/rocksdb/env.h (Logger::Logv, SetInfoLogLevel)
+type InfoLogger interface {
+	Logger
+
+	// Logf logs a formatted message at the given level.
+	Logf(level Level, format string, args ...any)
+
+	// SetInfoLogLevel sets the most verbose level that is logged.
+	SetInfoLogLevel(level Level)
+}
+
+// oldInfoLogPrefix is the prefix of rolled LOG files, which are named
+// LOG.old.<microseconds>.
+const oldInfoLogPrefix = InfoLogFileName + ".old."
+
+// FileLoggerOptions configures when a FileLogger rolls LOG.
+type FileLoggerOptions struct {
+	// MaxFileSize rolls LOG before it grows past this many bytes.
+	// 0 disables rolling by size.
+	MaxFileSize int64
+
+	// TimeToRoll rolls LOG once it has been open this long.
+	// 0 disables rolling by time.
+	TimeToRoll time.Duration
+
+	// KeepFileNum is the number of rolled LOG files to keep; older ones are
+	// deleted. 0 keeps all of them.
+	KeepFileNum int
+}
+
+// FileLogger writes log messages to the LOG file of a database directory.
+// When LOG would grow past MaxFileSize, or has been open for TimeToRoll, the
+// file is renamed to LOG.old.<microseconds> and a new LOG is started. An
+// existing LOG is rolled the same way when the logger is created. Only the
+// newest KeepFileNum rolled files are kept.
+//
+// Write errors are ignored: logging must never fail a database operation.
+type FileLogger struct {
+	fs    vfs.FS
+	dir   string
+	opts  FileLoggerOptions
+	level atomic.Int32
+	now   func() time.Time
+
+	mu       sync.Mutex
+	file     vfs.WritableFile
+	size     int64
+	openedAt time.Time
+	logger   *log.Logger
+
+	fatalHandler atomic.Pointer[FatalHandler]
+}
+
+var _ InfoLogger = (*FileLogger)(nil)
+
+// NewFileLogger creates a logger writing to the LOG file in dir.
+func NewFileLogger(fs vfs.FS, dir string, opts FileLoggerOptions, level Level) (*FileLogger, error) {
+	l := &FileLogger{
+		fs:   fs,
+		dir:  dir,
+		opts: opts,
+		now:  time.Now,
+	}
+	l.level.Store(int32(level))
+	l.logger = log.New(writerFunc(l.write), "", log.LstdFlags)
+	if err := l.roll(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// writerFunc adapts a function to io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// roll moves the current LOG aside, creates a new one, and deletes the rolled
+// files beyond KeepFileNum.
+// REQUIRES: l.mu is held, or l is not yet shared.
+func (l *FileLogger) roll() error {
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+	path := filepath.Join(l.dir, InfoLogFileName)
+	if l.fs.Exists(path) {
+		// Rolls within the same microsecond must not overwrite each other
+		ts := l.now().UnixMicro()
+		old := filepath.Join(l.dir, oldInfoLogPrefix+strconv.FormatInt(ts, 10))
+		for l.fs.Exists(old) {
+			ts++
+			old = filepath.Join(l.dir, oldInfoLogPrefix+strconv.FormatInt(ts, 10))
+		}
+		if err := l.fs.Rename(path, old); err != nil {
+			return fmt.Errorf("logging: failed to roll %s: %w", path, err)
+		}
+	}
+	file, err := l.fs.Create(path)
+	if err != nil {
+		return fmt.Errorf("logging: failed to create %s: %w", path, err)
+	}
+	l.file = file
+	l.size = 0
+	l.openedAt = l.now()
+	l.purgeOldFiles()
+	return nil
+}
+
+// purgeOldFiles deletes the oldest rolled LOG files beyond KeepFileNum.
+// Deletion is best-effort: a file that cannot be deleted is retried on the
+// next roll.
+func (l *FileLogger) purgeOldFiles() {
+	if l.opts.KeepFileNum <= 0 {
+		return
+	}
+	names, err := l.fs.ListDir(l.dir)
+	if err != nil {
+		return
+	}
+	var stamps []int64
+	for _, name := range names {
+		suffix, ok := strings.CutPrefix(name, oldInfoLogPrefix)
+		if !ok {
+			continue
+		}
+		if ts, err := strconv.ParseInt(suffix, 10, 64); err == nil {
+			stamps = append(stamps, ts)
+		}
+	}
+	if len(stamps) <= l.opts.KeepFileNum {
+		return
+	}
+	slices.Sort(stamps)
+	for _, ts := range stamps[:len(stamps)-l.opts.KeepFileNum] {
+		_ = l.fs.Remove(filepath.Join(l.dir, oldInfoLogPrefix+strconv.FormatInt(ts, 10)))
+	}
+}
+
+// shouldRoll reports whether LOG must be rolled before n more bytes are
+// written to it.
+// REQUIRES: l.mu is held.
+func (l *FileLogger) shouldRoll(n int) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.opts.MaxFileSize > 0 && l.size+int64(n) > l.opts.MaxFileSize {
+		return true
+	}
+	return l.opts.TimeToRoll > 0 && l.now().Sub(l.openedAt) >= l.opts.TimeToRoll
+}
+
+// write appends one formatted line to LOG, rolling it first if needed.
+func (l *FileLogger) write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return len(p), nil
+	}
+	if l.shouldRoll(len(p)) {
+		if err := l.roll(); err != nil {
+			return len(p), nil
+		}
+	}
+	n, _ := l.file.Write(p)
+	l.size += int64(n)
+	return len(p), nil
+}
+
+// SetFatalHandler sets the handler called when Fatalf is invoked.
+func (l *FileLogger) SetFatalHandler(h FatalHandler) {
+	l.fatalHandler.Store(&h)
+}
+
+// SetInfoLogLevel sets the most verbose level that is logged.
+func (l *FileLogger) SetInfoLogLevel(level Level) {
+	l.level.Store(int32(level))
+}
+
+// Level returns the logging level.
+func (l *FileLogger) Level() Level {
+	return Level(l.level.Load())
+}
+
+// Logf logs a formatted message at the given level.
+func (l *FileLogger) Logf(level Level, format string, args ...any) {
+	if l.Level() >= level {
+		_ = l.logger.Output(2, level.String()+" "+fmt.Sprintf(format, args...))
+	}
+}
+
+// Errorf logs a formatted error message.
+func (l *FileLogger) Errorf(format string, args ...any) {
+	l.Logf(LevelError, format, args...)
+}
+
+// Warnf logs a formatted warning message.
+func (l *FileLogger) Warnf(format string, args ...any) {
+	l.Logf(LevelWarn, format, args...)
+}
+
+// Infof logs a formatted informational message.
+func (l *FileLogger) Infof(format string, args ...any) {
+	l.Logf(LevelInfo, format, args...)
+}
+
+// Debugf logs a formatted debug message.
+func (l *FileLogger) Debugf(format string, args ...any) {
+	l.Logf(LevelDebug, format, args...)
+}
+
+// Fatalf logs a fatal error and triggers the fatal handler.
+func (l *FileLogger) Fatalf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	_ = l.logger.Output(2, "FATAL "+msg)
+	if h := l.fatalHandler.Load(); h != nil {
+		(*h)(msg)
+	}
+}
+
+// Sync flushes LOG to stable storage.
+func (l *FileLogger) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Sync()
+}
+
+// Close closes LOG. Messages logged afterwards are dropped.
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
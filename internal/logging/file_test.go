@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+func TestFileLogger_SetInfoLogLevel(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewFileLogger(vfs.Default(), dir, FileLoggerOptions{}, LevelWarn)
+	if err != nil {
+		t.Fatalf("NewFileLogger failed: %v", err)
+	}
+
+	l.Infof("hidden info")
+	l.SetInfoLogLevel(LevelInfo)
+	l.Logf(LevelInfo, "shown %s", "info")
+	l.Debugf("hidden debug")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	l.Errorf("after close")
+
+	data, err := os.ReadFile(filepath.Join(dir, InfoLogFileName))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	output := string(data)
+	if !strings.Contains(output, "INFO shown info") {
+		t.Errorf("LOG missing info message after SetInfoLogLevel: %q", output)
+	}
+	for _, hidden := range []string{"hidden info", "hidden debug", "after close"} {
+		if strings.Contains(output, hidden) {
+			t.Errorf("LOG contains %q: %q", hidden, output)
+		}
+	}
+}
+
+func TestFileLogger_Roll(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, InfoLogFileName), []byte("previous open\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	l, err := NewFileLogger(vfs.Default(), dir, FileLoggerOptions{MaxFileSize: 100}, LevelInfo)
+	if err != nil {
+		t.Fatalf("NewFileLogger failed: %v", err)
+	}
+	for range 10 {
+		l.Infof("%s", strings.Repeat("x", 40))
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	old, err := filepath.Glob(filepath.Join(dir, InfoLogFileName+".old.*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	// The previous LOG, then one file per line since a line is over half
+	// the limit
+	if len(old) < 2 {
+		t.Errorf("got %d rolled files, want the previous LOG and rolls past the size limit", len(old))
+	}
+	info, err := os.Stat(filepath.Join(dir, InfoLogFileName))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() > 100 {
+		t.Errorf("LOG has %d bytes, want at most 100", info.Size())
+	}
+}
+
+func TestFileLogger_RollByTime(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewFileLogger(vfs.Default(), dir, FileLoggerOptions{TimeToRoll: time.Hour}, LevelInfo)
+	if err != nil {
+		t.Fatalf("NewFileLogger failed: %v", err)
+	}
+	defer l.Close()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	l.Infof("first")
+	l.Infof("second")
+	now = now.Add(2 * time.Hour)
+	l.Infof("third")
+
+	old, err := filepath.Glob(filepath.Join(dir, InfoLogFileName+".old.*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(old) != 1 {
+		t.Fatalf("got %d rolled files, want 1", len(old))
+	}
+	data, err := os.ReadFile(old[0])
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !strings.Contains(string(data), "second") || strings.Contains(string(data), "third") {
+		t.Errorf("rolled LOG = %q, want the lines written before TimeToRoll", data)
+	}
+}
+
+func TestFileLogger_KeepFileNum(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewFileLogger(vfs.Default(), dir, FileLoggerOptions{MaxFileSize: 100, KeepFileNum: 3}, LevelInfo)
+	if err != nil {
+		t.Fatalf("NewFileLogger failed: %v", err)
+	}
+	for range 20 {
+		l.Infof("%s", strings.Repeat("x", 60))
+	}
+	l.Infof("last")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	old, err := filepath.Glob(filepath.Join(dir, InfoLogFileName+".old.*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(old) != 3 {
+		t.Errorf("got %d rolled files, want KeepFileNum=3", len(old))
+	}
+	// The newest lines survive
+	data, err := os.ReadFile(filepath.Join(dir, InfoLogFileName))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !strings.Contains(string(data), "last") {
+		t.Errorf("LOG = %q, want the last line", data)
+	}
+}
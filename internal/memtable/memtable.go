@@ -6,13 +6,16 @@ import (
 	"sync/atomic"
 
 	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/filter"
+	"github.com/aalhour/rockyardkv/internal/perf"
 	"github.com/aalhour/rockyardkv/internal/rangedel"
 )
 
 // MemTable is an in-memory data structure that holds writes before they are
-// flushed to SST files. It uses a SkipList for ordered storage.
+// flushed to SST files. It keeps its entries ordered in a Rep, by default a
+// SkipList.
 //
-// Entry format stored in the SkipList:
+// Entry format stored in the Rep:
 //
 //	internal_key_size : varint32 (length of internal_key)
 //	internal_key      : internal_key_size bytes (user_key + 8 bytes for seq+type)
@@ -21,8 +24,8 @@ import (
 //
 // Reference: RocksDB v10.7.5 db/memtable.cc
 type MemTable struct {
-	skiplist *SkipList
-	compare  Comparator
+	rep     Rep
+	compare Comparator
 
 	// Range tombstones stored separately from point data.
 	// In RocksDB, range tombstones are stored in a separate data structure
@@ -48,10 +51,86 @@ type MemTable struct {
 
 	// Mutex for write synchronization
 	mu sync.Mutex
+
+	// bloom holds the whole keys and/or prefixes of the point entries, so
+	// that lookups of absent keys can skip the search (nil = disabled)
+	bloom             *filter.DynamicBloom
+	wholeKeyFiltering bool
+	prefix            func(key []byte) ([]byte, bool)
+}
+
+// Options configures optional MemTable features.
+//
+// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+// (memtable_prefix_bloom_size_ratio, memtable_whole_key_filtering),
+// memtable/skiplist.h (max_height, branching_factor)
+type Options struct {
+	// BloomBits is the size of the bloom filter in bits (0 = no filter).
+	BloomBits uint32
+
+	// WholeKeyFiltering adds whole user keys to the bloom filter.
+	WholeKeyFiltering bool
+
+	// Prefix, if set, returns the prefix of a key to add to the bloom filter,
+	// and false for keys outside the prefix domain.
+	Prefix func(key []byte) ([]byte, bool)
+
+	// SkipListMaxHeight and SkipListBranchingFactor configure the skiplist;
+	// see NewSkipListWithParams (0 = default).
+	SkipListMaxHeight       int
+	SkipListBranchingFactor int
+
+	// NewRep, if set, creates the Rep that holds the entries, ordered by
+	// cmp, instead of a SkipList.
+	NewRep func(cmp Comparator) Rep
+}
+
+// Rep is the ordered collection holding the entries of a MemTable.
+// Writes are serialized by the MemTable; reads may run concurrently with
+// them.
+//
+// Reference: RocksDB v10.7.5 include/rocksdb/memtablerep.h (MemTableRep)
+type Rep interface {
+	// Insert adds an entry.
+	// REQUIRES: Nothing equal to entry is currently in the rep.
+	Insert(entry []byte)
+
+	// Count returns the number of entries.
+	Count() int64
+
+	// NewIterator returns an unpositioned iterator over the entries.
+	NewIterator() RepIterator
+}
+
+// RepIterator iterates over the entries of a Rep in order.
+type RepIterator interface {
+	Valid() bool
+	Key() []byte
+	Next()
+	Prev()
+	Seek(target []byte)
+	SeekToFirst()
+	SeekToLast()
+}
+
+// skipListRep adapts a SkipList to Rep.
+type skipListRep struct {
+	*SkipList
+}
+
+func (r skipListRep) NewIterator() RepIterator {
+	return r.SkipList.NewIterator()
 }
 
 // NewMemTable creates a new MemTable.
 func NewMemTable(cmp Comparator) *MemTable {
+	return NewMemTableWithOptions(cmp, Options{})
+}
+
+// NewMemTableWithOptions creates a new MemTable with the given options. The
+// bloom filter is only created if it has something to hold: whole keys, or
+// prefixes.
+func NewMemTableWithOptions(cmp Comparator, opts Options) *MemTable {
 	if cmp == nil {
 		cmp = BytewiseComparator
 	}
@@ -62,14 +141,28 @@ func NewMemTable(cmp Comparator) *MemTable {
 		return compareMemTableEntries(a, b, cmp)
 	}
 
-	return &MemTable{
-		skiplist:        NewSkipList(internalCmp),
+	var rep Rep
+	if opts.NewRep != nil {
+		rep = opts.NewRep(internalCmp)
+	} else {
+		rep = skipListRep{NewSkipListWithParams(internalCmp, opts.SkipListMaxHeight, opts.SkipListBranchingFactor)}
+	}
+
+	mt := &MemTable{
+		rep:             rep,
 		compare:         cmp,
 		rangeTombstones: rangedel.NewTombstoneList(),
 		refs:            1,
 		firstSeqno:      0,
 		earliestSeqno:   ^dbformat.SequenceNumber(0),
 	}
+	if opts.BloomBits > 0 && (opts.WholeKeyFiltering || opts.Prefix != nil) {
+		mt.bloom = filter.NewDynamicBloom(opts.BloomBits)
+		mt.wholeKeyFiltering = opts.WholeKeyFiltering
+		mt.prefix = opts.Prefix
+		mt.memoryUsage = int64(mt.bloom.ApproximateMemoryUsage())
+	}
+	return mt
 }
 
 // extractInternalKey extracts the internal key from a memtable entry.
@@ -149,9 +242,9 @@ func (mt *MemTable) Add(seq dbformat.SequenceNumber, typ dbformat.ValueType, key
 	internalKeyLen := len(key) + 8
 	trailer := dbformat.PackSequenceAndType(seq, typ)
 
-	// Build the entry for the skiplist
+	// Build the entry for the rep
 	// Format: internal_key (user_key + trailer)
-	// We store just the internal key in the skiplist
+	// We store just the internal key in the rep
 	// Values are stored separately or encoded together
 
 	// For simplicity, we encode key and value together:
@@ -172,10 +265,13 @@ func (mt *MemTable) Add(seq dbformat.SequenceNumber, typ dbformat.ValueType, key
 	// Append value
 	entry = append(entry, value...)
 
-	mt.skiplist.Insert(entry)
+	mt.rep.Insert(entry)
+	if mt.bloom != nil {
+		mt.addToBloom(key)
+	}
 
 	// Update memory usage
-	atomic.AddInt64(&mt.memoryUsage, int64(len(entry)+64)) // 64 for skiplist node overhead
+	atomic.AddInt64(&mt.memoryUsage, int64(len(entry)+64)) // 64 for rep overhead
 
 	// Update sequence number tracking
 	if seq < mt.earliestSeqno {
@@ -231,6 +327,11 @@ func (mt *MemTable) HasRangeTombstones() bool {
 	return !mt.rangeTombstones.IsEmpty()
 }
 
+// UserComparator returns the user key comparator the memtable orders keys by.
+func (mt *MemTable) UserComparator() Comparator {
+	return mt.compare
+}
+
 // RangeTombstoneCount returns the number of range tombstones.
 func (mt *MemTable) RangeTombstoneCount() int {
 	return mt.rangeTombstones.Len()
@@ -240,14 +341,7 @@ func (mt *MemTable) RangeTombstoneCount() int {
 // Returns the value and whether the key was found.
 // If the key was deleted, returns nil value with found=true and a deletion status.
 func (mt *MemTable) Get(key []byte, seq dbformat.SequenceNumber) (value []byte, found bool, deleted bool) {
-	// Build a lookup key: user_key + max sequence number
-	// This will position us at the first entry for this user key
-	lookupKey := make([]byte, len(key)+8)
-	copy(lookupKey, key)
-	binary.LittleEndian.PutUint64(lookupKey[len(key):], dbformat.PackSequenceAndType(seq, dbformat.ValueTypeForSeek))
-
-	iter := mt.skiplist.NewIterator()
-	iter.Seek(buildLookupEntry(lookupKey))
+	iter := mt.seekForGet(key, seq)
 
 	// Find the highest sequence number among range tombstones covering this key
 	var rangeDelSeq dbformat.SequenceNumber
@@ -313,13 +407,7 @@ func (mt *MemTable) Get(key []byte, seq dbformat.SequenceNumber) (value []byte,
 // GetWithMerge is like Get but also returns whether the entry is a merge operand.
 // Returns: value, found, deleted, isMerge
 func (mt *MemTable) GetWithMerge(key []byte, seq dbformat.SequenceNumber) (value []byte, found bool, deleted bool, isMerge bool) {
-	// Build a lookup key: user_key + max sequence number
-	lookupKey := make([]byte, len(key)+8)
-	copy(lookupKey, key)
-	binary.LittleEndian.PutUint64(lookupKey[len(key):], dbformat.PackSequenceAndType(seq, dbformat.ValueTypeForSeek))
-
-	iter := mt.skiplist.NewIterator()
-	iter.Seek(buildLookupEntry(lookupKey))
+	iter := mt.seekForGet(key, seq)
 
 	// Find the highest sequence number among range tombstones covering this key
 	var rangeDelSeq dbformat.SequenceNumber
@@ -378,15 +466,10 @@ func (mt *MemTable) GetWithMerge(key []byte, seq dbformat.SequenceNumber) (value
 }
 
 // CollectMergeOperands collects all merge operands for a key until a base value or deletion is found.
-// Returns: baseValue (nil if not found or deleted), mergeOperands (newest first), foundBase, deleted
-func (mt *MemTable) CollectMergeOperands(key []byte, seq dbformat.SequenceNumber) (baseValue []byte, mergeOperands [][]byte, foundBase bool, deleted bool) {
-	// Build a lookup key: user_key + max sequence number
-	lookupKey := make([]byte, len(key)+8)
-	copy(lookupKey, key)
-	binary.LittleEndian.PutUint64(lookupKey[len(key):], dbformat.PackSequenceAndType(seq, dbformat.ValueTypeForSeek))
-
-	iter := mt.skiplist.NewIterator()
-	iter.Seek(buildLookupEntry(lookupKey))
+// Returns: baseValue (nil if not found or deleted), baseType (TypeValue or
+// TypeWideColumnEntity), mergeOperands (newest first), foundBase, deleted
+func (mt *MemTable) CollectMergeOperands(key []byte, seq dbformat.SequenceNumber) (baseValue []byte, baseType dbformat.ValueType, mergeOperands [][]byte, foundBase bool, deleted bool) {
+	iter := mt.seekForGet(key, seq)
 
 	// Find the highest sequence number among range tombstones covering this key
 	var rangeDelSeq dbformat.SequenceNumber
@@ -414,17 +497,17 @@ func (mt *MemTable) CollectMergeOperands(key []byte, seq dbformat.SequenceNumber
 
 		// Check if a range tombstone with higher seq supersedes this entry
 		if rangeDelSeq > entrySeq {
-			return nil, mergeOperands, false, true
+			return nil, 0, mergeOperands, false, true
 		}
 
 		// Process based on value type
 		switch entryType {
-		case dbformat.TypeValue:
+		case dbformat.TypeValue, dbformat.TypeWideColumnEntity:
 			// Found base value
-			return entryValue, mergeOperands, true, false
+			return entryValue, entryType, mergeOperands, true, false
 		case dbformat.TypeDeletion, dbformat.TypeSingleDeletion:
 			// Key was deleted
-			return nil, mergeOperands, false, true
+			return nil, 0, mergeOperands, false, true
 		case dbformat.TypeMerge:
 			// Collect merge operand
 			mergeOperands = append(mergeOperands, entryValue)
@@ -435,10 +518,73 @@ func (mt *MemTable) CollectMergeOperands(key []byte, seq dbformat.SequenceNumber
 
 	// If we only checked range tombstone at the end
 	if rangeDelSeq > 0 && len(mergeOperands) == 0 {
-		return nil, nil, false, true
+		return nil, 0, nil, false, true
 	}
 
-	return nil, mergeOperands, false, false
+	return nil, 0, mergeOperands, false, false
+}
+
+// CountSuccessiveMergeEntries returns the number of merge operands for key
+// that follow its newest entry without a base value or deletion in between.
+// Reference: RocksDB v10.7.5 db/memtable.cc (MemTable::CountSuccessiveMergeEntries)
+func (mt *MemTable) CountSuccessiveMergeEntries(key []byte) int {
+	iter := mt.seekForGet(key, dbformat.MaxSequenceNumber)
+	count := 0
+	for ; iter.Valid(); iter.Next() {
+		entryKey, _, _, entryType, ok := parseEntry(iter.Key())
+		if !ok || mt.compare(key, entryKey) != 0 || entryType != dbformat.TypeMerge {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// seekForGet positions a rep iterator at the first entry for key
+// visible at seq. If the bloom filter rules the key out, the iterator is left
+// unpositioned, so that callers find no point entry for the key.
+func (mt *MemTable) seekForGet(key []byte, seq dbformat.SequenceNumber) RepIterator {
+	iter := mt.rep.NewIterator()
+	if mt.bloom != nil {
+		mayContain := mt.bloomMayContain(key)
+		perf.AddBloomMemtable(mayContain)
+		if !mayContain {
+			return iter
+		}
+	}
+
+	// Build a lookup key: user_key + max sequence number
+	lookupKey := make([]byte, len(key)+8)
+	copy(lookupKey, key)
+	binary.LittleEndian.PutUint64(lookupKey[len(key):], dbformat.PackSequenceAndType(seq, dbformat.ValueTypeForSeek))
+
+	perf.AddSeekOnMemtable()
+	iter.Seek(buildLookupEntry(lookupKey))
+	return iter
+}
+
+// addToBloom adds the whole key and/or its prefix to the bloom filter.
+func (mt *MemTable) addToBloom(key []byte) {
+	if mt.wholeKeyFiltering {
+		mt.bloom.Add(key)
+	}
+	if mt.prefix != nil {
+		if prefix, ok := mt.prefix(key); ok {
+			mt.bloom.Add(prefix)
+		}
+	}
+}
+
+// bloomMayContain reports whether the bloom filter may hold key. Keys outside
+// the prefix domain can only be ruled out by whole key filtering.
+func (mt *MemTable) bloomMayContain(key []byte) bool {
+	if mt.wholeKeyFiltering {
+		return mt.bloom.MayContain(key)
+	}
+	if prefix, ok := mt.prefix(key); ok {
+		return mt.bloom.MayContain(prefix)
+	}
+	return true
 }
 
 // getMaxRangeTombstoneSeq returns the maximum sequence number among range
@@ -531,25 +677,25 @@ func (mt *MemTable) SetNextLogNumber(num uint64) {
 
 // Count returns the number of entries in the memtable.
 func (mt *MemTable) Count() int64 {
-	return mt.skiplist.Count()
+	return mt.rep.Count()
 }
 
-// Empty returns true if the memtable has no entries.
+// Empty returns true if the memtable has no entries and no range tombstones.
 func (mt *MemTable) Empty() bool {
-	return mt.Count() == 0
+	return mt.Count() == 0 && !mt.HasRangeTombstones()
 }
 
 // NewIterator returns an iterator over the memtable.
 func (mt *MemTable) NewIterator() *MemTableIterator {
 	return &MemTableIterator{
-		iter:    mt.skiplist.NewIterator(),
+		iter:    mt.rep.NewIterator(),
 		compare: mt.compare,
 	}
 }
 
 // MemTableIterator iterates over memtable entries.
 type MemTableIterator struct {
-	iter    *Iterator
+	iter    RepIterator
 	compare Comparator
 
 	// Cached parsed values
@@ -579,6 +725,7 @@ func (it *MemTableIterator) SeekToLast() {
 
 // Seek positions the iterator at the first entry with key >= target.
 func (it *MemTableIterator) Seek(target []byte) {
+	perf.AddSeekOnMemtable()
 	it.iter.Seek(buildLookupEntry(target))
 	it.parseCurrentEntry()
 }
@@ -637,7 +784,7 @@ func (it *MemTableIterator) Type() dbformat.ValueType {
 	return it.typ
 }
 
-// parseCurrentEntry parses the current entry from the underlying skiplist iterator.
+// parseCurrentEntry parses the current entry from the underlying rep iterator.
 func (it *MemTableIterator) parseCurrentEntry() {
 	if !it.iter.Valid() {
 		it.valid = false
@@ -27,6 +27,19 @@ func TestMemTableEmpty(t *testing.T) {
 	}
 }
 
+func TestMemTableNotEmptyWithOnlyRangeTombstone(t *testing.T) {
+	mt := NewMemTable(BytewiseComparator)
+
+	mt.AddRangeTombstone(1, []byte("a"), []byte("c"))
+
+	if mt.Empty() {
+		t.Error("Memtable with a range tombstone should not be empty")
+	}
+	if mt.Count() != 0 {
+		t.Errorf("Count = %d, want 0", mt.Count())
+	}
+}
+
 func TestMemTableAdd(t *testing.T) {
 	mt := NewMemTable(BytewiseComparator)
 
@@ -362,6 +375,71 @@ func BenchmarkMemTableIterate(b *testing.B) {
 	}
 }
 
+// BenchmarkMemTableSortedInsert compares loading sorted keys into a
+// skiplist and a vector memtable, including the iteration a flush does.
+func BenchmarkMemTableSortedInsert(b *testing.B) {
+	const n = 100000
+	keys := make([][]byte, n)
+	for i := range n {
+		keys[i] = fmt.Appendf(nil, "key%010d", i)
+	}
+	for _, bm := range []struct {
+		name string
+		opts Options
+	}{
+		{"skiplist", Options{}},
+		{"vector", Options{NewRep: func(cmp Comparator) Rep { return NewVectorRep(cmp) }}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				mt := NewMemTableWithOptions(BytewiseComparator, bm.opts)
+				for i, key := range keys {
+					mt.Add(dbformat.SequenceNumber(i+1), dbformat.TypeValue, key, key)
+				}
+				iter := mt.NewIterator()
+				for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/key")
+		})
+	}
+}
+
+// BenchmarkMemTableBranchingFactor compares insert and lookup throughput at
+// different skiplist branching factors.
+func BenchmarkMemTableBranchingFactor(b *testing.B) {
+	const n = 100000
+	keys := make([][]byte, n)
+	for i := range n {
+		keys[i] = fmt.Appendf(nil, "key%010d", (i*7919)%n)
+	}
+	for _, bf := range []int{2, 4, 8, 16} {
+		opts := Options{SkipListBranchingFactor: bf, SkipListMaxHeight: MaxPossibleHeight}
+		b.Run(fmt.Sprintf("Add/bf=%d", bf), func(b *testing.B) {
+			var mt *MemTable
+			i := 0
+			for b.Loop() {
+				if i%n == 0 {
+					mt = NewMemTableWithOptions(BytewiseComparator, opts)
+				}
+				mt.Add(dbformat.SequenceNumber(i+1), dbformat.TypeValue, keys[i%n], keys[i%n])
+				i++
+			}
+		})
+		b.Run(fmt.Sprintf("Get/bf=%d", bf), func(b *testing.B) {
+			mt := NewMemTableWithOptions(BytewiseComparator, opts)
+			for i, key := range keys {
+				mt.Add(dbformat.SequenceNumber(i+1), dbformat.TypeValue, key, key)
+			}
+			i := 0
+			for b.Loop() {
+				mt.Get(keys[i%n], n)
+				i++
+			}
+		})
+	}
+}
+
 // =============================================================================
 // Merge Operand Collection Tests
 // =============================================================================
@@ -369,7 +447,7 @@ func BenchmarkMemTableIterate(b *testing.B) {
 func TestCollectMergeOperandsEmpty(t *testing.T) {
 	mt := NewMemTable(BytewiseComparator)
 
-	baseValue, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
+	baseValue, _, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
 	if foundBase {
 		t.Error("Should not find base in empty memtable")
 	}
@@ -390,7 +468,7 @@ func TestCollectMergeOperandsSingleMerge(t *testing.T) {
 	// Add a single merge operand
 	mt.Add(1, dbformat.TypeMerge, []byte("key"), []byte("op1"))
 
-	baseValue, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
+	baseValue, _, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
 	if foundBase {
 		t.Error("Should not find base (only merge operand)")
 	}
@@ -416,7 +494,7 @@ func TestCollectMergeOperandsMultipleMerges(t *testing.T) {
 	mt.Add(2, dbformat.TypeMerge, []byte("key"), []byte("op2"))
 	mt.Add(3, dbformat.TypeMerge, []byte("key"), []byte("op3"))
 
-	baseValue, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
+	baseValue, _, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
 	if foundBase {
 		t.Error("Should not find base (only merge operands)")
 	}
@@ -446,7 +524,7 @@ func TestCollectMergeOperandsWithBase(t *testing.T) {
 	mt.Add(2, dbformat.TypeMerge, []byte("key"), []byte("op1"))
 	mt.Add(3, dbformat.TypeMerge, []byte("key"), []byte("op2"))
 
-	baseValue, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
+	baseValue, _, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
 	if !foundBase {
 		t.Error("Should find base value")
 	}
@@ -478,7 +556,7 @@ func TestCollectMergeOperandsWithDeletion(t *testing.T) {
 	mt.Add(4, dbformat.TypeMerge, []byte("key"), []byte("op2"))
 	mt.Add(5, dbformat.TypeMerge, []byte("key"), []byte("op3"))
 
-	baseValue, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
+	baseValue, _, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
 	// Should stop at deletion - only operands after deletion are collected
 	if foundBase {
 		t.Error("Should not find base (deleted)")
@@ -512,7 +590,7 @@ func TestCollectMergeOperandsSequenceFiltering(t *testing.T) {
 	mt.Add(5, dbformat.TypeMerge, []byte("key"), []byte("op4"))
 
 	// Query with seq=3 should only see entries with seq <= 3
-	baseValue, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 3)
+	baseValue, _, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 3)
 	if !foundBase {
 		t.Error("Should find base value")
 	}
@@ -540,7 +618,7 @@ func TestCollectMergeOperandsOnlyBase(t *testing.T) {
 	// Add only a base value (no merges)
 	mt.Add(1, dbformat.TypeValue, []byte("key"), []byte("base"))
 
-	baseValue, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
+	baseValue, _, operands, foundBase, deleted := mt.CollectMergeOperands([]byte("key"), 100)
 	if !foundBase {
 		t.Error("Should find base value")
 	}
@@ -566,7 +644,7 @@ func TestCollectMergeOperandsDifferentKeys(t *testing.T) {
 	mt.Add(5, dbformat.TypeValue, []byte("key2"), []byte("base2"))
 
 	// Query key1
-	baseValue, operands, foundBase, _ := mt.CollectMergeOperands([]byte("key1"), 100)
+	baseValue, _, operands, foundBase, _ := mt.CollectMergeOperands([]byte("key1"), 100)
 	if !foundBase {
 		t.Error("Should find base for key1")
 	}
@@ -584,7 +662,7 @@ func TestCollectMergeOperandsDifferentKeys(t *testing.T) {
 	}
 
 	// Query key2
-	baseValue, operands, foundBase, _ = mt.CollectMergeOperands([]byte("key2"), 100)
+	baseValue, _, operands, foundBase, _ = mt.CollectMergeOperands([]byte("key2"), 100)
 	if !foundBase {
 		t.Error("Should find base for key2")
 	}
@@ -603,7 +681,7 @@ func TestCollectMergeOperandsEmptyOperand(t *testing.T) {
 	// Add merge operand with empty value
 	mt.Add(1, dbformat.TypeMerge, []byte("key"), []byte{})
 
-	_, operands, _, _ := mt.CollectMergeOperands([]byte("key"), 100)
+	_, _, operands, _, _ := mt.CollectMergeOperands([]byte("key"), 100)
 	if len(operands) != 1 {
 		t.Fatalf("Should have 1 operand, got %d", len(operands))
 	}
@@ -611,3 +689,96 @@ func TestCollectMergeOperandsEmptyOperand(t *testing.T) {
 		t.Errorf("Operand should be empty, got %q", operands[0])
 	}
 }
+
+func TestCountSuccessiveMergeEntries(t *testing.T) {
+	mt := NewMemTable(BytewiseComparator)
+
+	if n := mt.CountSuccessiveMergeEntries([]byte("key")); n != 0 {
+		t.Errorf("empty memtable: count = %d, want 0", n)
+	}
+
+	mt.Add(1, dbformat.TypeMerge, []byte("key"), []byte("op1"))
+	mt.Add(2, dbformat.TypeValue, []byte("key"), []byte("base"))
+	mt.Add(3, dbformat.TypeMerge, []byte("key"), []byte("op2"))
+	mt.Add(4, dbformat.TypeMerge, []byte("key"), []byte("op3"))
+	mt.Add(5, dbformat.TypeMerge, []byte("other"), []byte("op"))
+
+	// The operand below the base value does not count
+	if n := mt.CountSuccessiveMergeEntries([]byte("key")); n != 2 {
+		t.Errorf("count = %d, want 2", n)
+	}
+	if n := mt.CountSuccessiveMergeEntries([]byte("other")); n != 1 {
+		t.Errorf("other: count = %d, want 1", n)
+	}
+
+	mt.Add(6, dbformat.TypeDeletion, []byte("key"), nil)
+	if n := mt.CountSuccessiveMergeEntries([]byte("key")); n != 0 {
+		t.Errorf("after deletion: count = %d, want 0", n)
+	}
+}
+
+func TestMemTableBloom(t *testing.T) {
+	mt := NewMemTableWithOptions(BytewiseComparator, Options{
+		BloomBits:         8 * 1024,
+		WholeKeyFiltering: true,
+	})
+	if mt.bloom == nil {
+		t.Fatal("bloom should be allocated when BloomBits > 0")
+	}
+
+	for i := range 100 {
+		mt.Add(dbformat.SequenceNumber(i+1), dbformat.TypeValue, fmt.Appendf(nil, "key%03d", i), []byte("v"))
+	}
+	for i := range 100 {
+		if _, found, _ := mt.Get(fmt.Appendf(nil, "key%03d", i), 1000); !found {
+			t.Errorf("key%03d not found", i)
+		}
+	}
+	if _, found, _ := mt.Get([]byte("missing"), 1000); found {
+		t.Error("missing key should not be found")
+	}
+}
+
+// TestMemTableSkipListParams checks that the skiplist parameters do not
+// change the order of the entries or what lookups find.
+func TestMemTableSkipListParams(t *testing.T) {
+	const n = 2000
+	for _, params := range []struct{ maxHeight, branchingFactor int }{
+		{1, 2}, {4, 2}, {12, 4}, {32, 2}, {12, 16}, {32, 64},
+	} {
+		t.Run(fmt.Sprintf("h=%d/bf=%d", params.maxHeight, params.branchingFactor), func(t *testing.T) {
+			mt := NewMemTableWithOptions(BytewiseComparator, Options{
+				SkipListMaxHeight:       params.maxHeight,
+				SkipListBranchingFactor: params.branchingFactor,
+			})
+			// Two versions of each key, inserted in scrambled order
+			for i := range 2 * n {
+				k := (i * 7919) % (2 * n)
+				seq := dbformat.SequenceNumber(k + 1)
+				mt.Add(seq, dbformat.TypeValue, fmt.Appendf(nil, "key%05d", k%n), fmt.Appendf(nil, "v%d", seq))
+			}
+
+			iter := mt.NewIterator()
+			count := 0
+			for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+				// Ascending user keys, newest version first
+				wantKey := fmt.Sprintf("key%05d", count/2)
+				wantSeq := dbformat.SequenceNumber(count/2 + 1 + n*(1-count%2))
+				if string(iter.UserKey()) != wantKey || iter.Sequence() != wantSeq {
+					t.Fatalf("entry %d = %q@%d, want %q@%d", count, iter.UserKey(), iter.Sequence(), wantKey, wantSeq)
+				}
+				count++
+			}
+			if count != 2*n {
+				t.Fatalf("iterated %d entries, want %d", count, 2*n)
+			}
+
+			for i := range n {
+				value, found, _ := mt.Get(fmt.Appendf(nil, "key%05d", i), 2*n)
+				if want := fmt.Sprintf("v%d", i+n+1); !found || string(value) != want {
+					t.Fatalf("Get(key%05d) = %q, %v, want %q", i, value, found, want)
+				}
+			}
+		})
+	}
+}
@@ -23,6 +23,9 @@ const (
 	// DefaultBranchingFactor is the default branching factor.
 	// On average, 1/branchingFactor nodes will be promoted to next level.
 	DefaultBranchingFactor = 4
+
+	// MaxPossibleHeight is the largest supported maximum height.
+	MaxPossibleHeight = 32
 )
 
 // Comparator compares two keys and returns:
@@ -90,6 +93,7 @@ func NewSkipList(cmp Comparator) *SkipList {
 }
 
 // NewSkipListWithParams creates a new skip list with custom parameters.
+// Non-positive parameters select the defaults.
 func NewSkipListWithParams(cmp Comparator, maxHeight, branchingFactor int) *SkipList {
 	if cmp == nil {
 		cmp = BytewiseComparator
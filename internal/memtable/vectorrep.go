@@ -0,0 +1,130 @@
+package memtable
+
+// vectorrep.go implements VectorRep, a memtable representation for bulk
+// loads.
+//
+// Entries are appended to a slice. As long as they arrive in order, which is
+// the case when sorted keys are written with increasing sequence numbers,
+// the slice stays sorted and is searched with a binary search. An entry that
+// arrives out of order marks the slice unsorted; the next iterator, e.g. the
+// one a flush uses, sorts a copy of it once.
+//
+// Iterators see the entries present when they were created.
+//
+// Reference: RocksDB v10.7.5 memtable/vectorrep.cc
+
+import (
+	"slices"
+	"sync"
+)
+
+// VectorRep is a Rep that keeps the entries in a slice.
+type VectorRep struct {
+	compare Comparator
+
+	mu      sync.RWMutex
+	entries [][]byte
+	sorted  bool
+}
+
+// NewVectorRep creates an empty VectorRep ordered by cmp.
+func NewVectorRep(cmp Comparator) *VectorRep {
+	if cmp == nil {
+		cmp = BytewiseComparator
+	}
+	return &VectorRep{compare: cmp, sorted: true}
+}
+
+// Insert appends an entry.
+// REQUIRES: Nothing equal to entry is currently in the rep.
+func (r *VectorRep) Insert(entry []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sorted && len(r.entries) > 0 && r.compare(r.entries[len(r.entries)-1], entry) > 0 {
+		r.sorted = false
+	}
+	r.entries = append(r.entries, entry)
+}
+
+// Count returns the number of entries.
+func (r *VectorRep) Count() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return int64(len(r.entries))
+}
+
+// Sorted reports whether the entries were inserted in order, or have been
+// sorted since.
+func (r *VectorRep) Sorted() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sorted
+}
+
+// NewIterator returns an iterator over the entries present now, sorting
+// them first if needed.
+func (r *VectorRep) NewIterator() RepIterator {
+	r.mu.RLock()
+	if r.sorted {
+		entries := r.entries[:len(r.entries):len(r.entries)]
+		r.mu.RUnlock()
+		return &vectorIterator{entries: entries, compare: r.compare, pos: -1}
+	}
+	r.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.sorted {
+		// Sort a copy: older iterators still read the unsorted slice
+		sorted := slices.Clone(r.entries)
+		slices.SortFunc(sorted, r.compare)
+		r.entries = sorted
+		r.sorted = true
+	}
+	entries := r.entries[:len(r.entries):len(r.entries)]
+	return &vectorIterator{entries: entries, compare: r.compare, pos: -1}
+}
+
+// vectorIterator iterates over sorted entries.
+type vectorIterator struct {
+	entries [][]byte
+	compare Comparator
+	pos     int
+}
+
+func (it *vectorIterator) Valid() bool {
+	return it.pos >= 0 && it.pos < len(it.entries)
+}
+
+func (it *vectorIterator) Key() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.entries[it.pos]
+}
+
+func (it *vectorIterator) Next() {
+	if it.Valid() {
+		it.pos++
+	}
+}
+
+func (it *vectorIterator) Prev() {
+	if it.Valid() {
+		it.pos--
+	}
+}
+
+// Seek positions the iterator at the first entry >= target.
+func (it *vectorIterator) Seek(target []byte) {
+	it.pos, _ = slices.BinarySearchFunc(it.entries, target, it.compare)
+}
+
+func (it *vectorIterator) SeekToFirst() {
+	it.pos = 0
+}
+
+func (it *vectorIterator) SeekToLast() {
+	it.pos = len(it.entries) - 1
+}
@@ -0,0 +1,129 @@
+package memtable
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+)
+
+func collectRep(iter RepIterator) []string {
+	var keys []string
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	return keys
+}
+
+func TestVectorRepSortedInserts(t *testing.T) {
+	r := NewVectorRep(BytewiseComparator)
+	for i := range 100 {
+		r.Insert(fmt.Appendf(nil, "key%03d", i))
+	}
+	if !r.Sorted() {
+		t.Fatal("in-order inserts should keep the rep sorted")
+	}
+	if r.Count() != 100 {
+		t.Errorf("Count = %d, want 100", r.Count())
+	}
+
+	iter := r.NewIterator()
+	iter.Seek([]byte("key050"))
+	if !iter.Valid() || string(iter.Key()) != "key050" {
+		t.Fatalf("Seek(key050) = %q", iter.Key())
+	}
+	iter.Seek([]byte("key050a"))
+	if !iter.Valid() || string(iter.Key()) != "key051" {
+		t.Fatalf("Seek(key050a) = %q", iter.Key())
+	}
+	iter.Prev()
+	if !iter.Valid() || string(iter.Key()) != "key050" {
+		t.Fatalf("Prev = %q", iter.Key())
+	}
+	iter.Seek([]byte("zzz"))
+	if iter.Valid() {
+		t.Fatalf("Seek past the end = %q, want invalid", iter.Key())
+	}
+	iter.SeekToLast()
+	if !iter.Valid() || string(iter.Key()) != "key099" {
+		t.Fatalf("SeekToLast = %q", iter.Key())
+	}
+	iter.SeekToFirst()
+	iter.Prev()
+	if iter.Valid() {
+		t.Fatal("Prev before the first entry should invalidate the iterator")
+	}
+}
+
+func TestVectorRepOutOfOrderInserts(t *testing.T) {
+	r := NewVectorRep(BytewiseComparator)
+	for _, key := range []string{"b", "d", "a"} {
+		r.Insert([]byte(key))
+	}
+	if r.Sorted() {
+		t.Fatal("out-of-order insert should mark the rep unsorted")
+	}
+
+	iter := r.NewIterator()
+	if got := fmt.Sprint(collectRep(iter)); got != "[a b d]" {
+		t.Fatalf("entries = %s, want [a b d]", got)
+	}
+	if !r.Sorted() {
+		t.Fatal("creating an iterator should sort the rep")
+	}
+
+	// Iterators see the entries present when they were created
+	r.Insert([]byte("c"))
+	if got := fmt.Sprint(collectRep(iter)); got != "[a b d]" {
+		t.Errorf("old iterator entries = %s, want [a b d]", got)
+	}
+	if got := fmt.Sprint(collectRep(r.NewIterator())); got != "[a b c d]" {
+		t.Errorf("new iterator entries = %s, want [a b c d]", got)
+	}
+}
+
+func TestMemTableVectorRep(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		order func(i int) int
+	}{
+		{"sorted", func(i int) int { return i }},
+		{"unsorted", func(i int) int { return (i * 7919) % 1000 }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := NewMemTableWithOptions(BytewiseComparator, Options{
+				NewRep: func(cmp Comparator) Rep { return NewVectorRep(cmp) },
+			})
+			for i := range 1000 {
+				k := tt.order(i)
+				mt.Add(dbformat.SequenceNumber(i+1), dbformat.TypeValue, fmt.Appendf(nil, "key%04d", k), fmt.Appendf(nil, "value%d", k))
+			}
+			mt.Add(1001, dbformat.TypeDeletion, []byte("key0500"), nil)
+
+			iter := mt.NewIterator()
+			i := 0
+			var prev []byte
+			for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+				if prev != nil && BytewiseComparator(prev, iter.UserKey()) > 0 {
+					t.Fatalf("entry %d %q is out of order after %q", i, iter.UserKey(), prev)
+				}
+				prev = iter.UserKey()
+				i++
+			}
+			if i != 1001 {
+				t.Fatalf("iterated %d entries, want 1001", i)
+			}
+
+			value, found, deleted := mt.Get([]byte("key0042"), 2000)
+			if !found || deleted || string(value) != "value42" {
+				t.Errorf("Get(key0042) = %q, %v, %v", value, found, deleted)
+			}
+			if _, found, deleted := mt.Get([]byte("key0500"), 2000); !found || !deleted {
+				t.Errorf("Get(key0500) = %v, %v, want deleted", found, deleted)
+			}
+			if _, found, _ := mt.Get([]byte("key9999"), 2000); found {
+				t.Error("Get(key9999) should not find anything")
+			}
+		})
+	}
+}
@@ -41,10 +41,28 @@ type ParsedOptions struct {
 	MaxBytesForLevelMultiplier     float64
 	TargetFileSizeBase             int64
 	TargetFileSizeMultiplier       int
+	MaxCompactionBytes             uint64
 	NumLevels                      int
 	Compression                    compression.Type
 	CompactionStyle                CompactionStyle
 	MaxSubcompactions              int
+	MaxBackgroundJobs              int
+	MaxBackgroundFlushes           int
+	MaxBackgroundCompactions       int
+	MaxFileOpeningThreads          int
+	RecycleLogFileNum              int
+	WALTTLSeconds                  uint64
+	WALSizeLimitMB                 uint64
+	ManualWalFlush                 bool
+	TwoWriteQueues                 bool
+	MaxLogFileSize                 int64
+	LogFileTimeToRoll              int64
+	KeepLogFileNum                 int
+	StatsDumpPeriodSec             uint64
+	MaxSuccessiveMerges            int
+	OptimizeFiltersForHits         bool
+	MaxWriteBufferSizeToMaintain   int64
+	MinWriteBufferNumberToMerge    int
 }
 
 // ReadOptionsFile reads and parses an OPTIONS file.
@@ -72,6 +90,10 @@ func ParseOptionsFile(r io.Reader) (*ParsedOptions, error) {
 		Compression:                    compression.NoCompression,
 		CompactionStyle:                CompactionStyleLevel,
 		MaxSubcompactions:              1,
+		MaxBackgroundJobs:              2,
+		MaxBackgroundFlushes:           -1,
+		MaxBackgroundCompactions:       -1,
+		MaxFileOpeningThreads:          16,
 	}
 
 	scanner := bufio.NewScanner(r)
@@ -128,6 +150,8 @@ func ParseOptionsFile(r io.Reader) (*ParsedOptions, error) {
 				opts.MaxBytesForLevelBase, _ = strconv.ParseInt(value, 10, 64)
 			case "max_bytes_for_level_multiplier":
 				opts.MaxBytesForLevelMultiplier, _ = strconv.ParseFloat(value, 64)
+			case "max_compaction_bytes":
+				opts.MaxCompactionBytes, _ = strconv.ParseUint(value, 10, 64)
 			case "target_file_size_base":
 				opts.TargetFileSizeBase, _ = strconv.ParseInt(value, 10, 64)
 			case "target_file_size_multiplier":
@@ -140,6 +164,32 @@ func ParseOptionsFile(r io.Reader) (*ParsedOptions, error) {
 				opts.CompactionStyle = StringToCompactionStyle(value)
 			case "max_subcompactions":
 				opts.MaxSubcompactions, _ = strconv.Atoi(value)
+			case "max_background_jobs":
+				opts.MaxBackgroundJobs, _ = strconv.Atoi(value)
+			case "max_background_flushes":
+				opts.MaxBackgroundFlushes, _ = strconv.Atoi(value)
+			case "max_background_compactions":
+				opts.MaxBackgroundCompactions, _ = strconv.Atoi(value)
+			case "max_file_opening_threads":
+				opts.MaxFileOpeningThreads, _ = strconv.Atoi(value)
+			case "recycle_log_file_num":
+				opts.RecycleLogFileNum, _ = strconv.Atoi(value)
+			case "WAL_ttl_seconds":
+				opts.WALTTLSeconds, _ = strconv.ParseUint(value, 10, 64)
+			case "WAL_size_limit_MB":
+				opts.WALSizeLimitMB, _ = strconv.ParseUint(value, 10, 64)
+			case "manual_wal_flush":
+				opts.ManualWalFlush = value == "true"
+			case "two_write_queues":
+				opts.TwoWriteQueues = value == "true"
+			case "max_log_file_size":
+				opts.MaxLogFileSize, _ = strconv.ParseInt(value, 10, 64)
+			case "log_file_time_to_roll":
+				opts.LogFileTimeToRoll, _ = strconv.ParseInt(value, 10, 64)
+			case "keep_log_file_num":
+				opts.KeepLogFileNum, _ = strconv.Atoi(value)
+			case "stats_dump_period_sec":
+				opts.StatsDumpPeriodSec, _ = strconv.ParseUint(value, 10, 64)
 			}
 
 		case strings.HasPrefix(currentSection, "CFOptions"):
@@ -149,6 +199,14 @@ func ParseOptionsFile(r io.Reader) (*ParsedOptions, error) {
 				opts.WriteBufferSize, _ = strconv.ParseInt(value, 10, 64)
 			case "compression":
 				opts.Compression = StringToCompressionType(value)
+			case "optimize_filters_for_hits":
+				opts.OptimizeFiltersForHits = value == "true"
+			case "max_write_buffer_size_to_maintain":
+				opts.MaxWriteBufferSizeToMaintain, _ = strconv.ParseInt(value, 10, 64)
+			case "min_write_buffer_number_to_merge":
+				opts.MinWriteBufferNumberToMerge, _ = strconv.Atoi(value)
+			case "max_successive_merges":
+				opts.MaxSuccessiveMerges, _ = strconv.Atoi(value)
 			}
 		}
 	}
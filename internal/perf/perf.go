@@ -0,0 +1,182 @@
+// Package perf implements the counters behind the public PerfContext.
+//
+// RocksDB keeps a PerfContext per thread. Go has no thread-local storage,
+// so these counters are process-wide: they aggregate activity from every
+// goroutine and are best read around an operation performed in isolation.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/perf_context.h
+//   - include/rocksdb/perf_level.h
+//   - include/rocksdb/iostats_context.h
+//   - monitoring/perf_context_imp.h (PERF_COUNTER_ADD)
+package perf
+
+import "sync/atomic"
+
+// Context holds the performance counters.
+type Context struct {
+	// BlockReadCount is the number of blocks read from disk.
+	BlockReadCount atomic.Uint64
+
+	// BlockReadByte is the number of bytes read from disk for blocks.
+	BlockReadByte atomic.Uint64
+
+	// IndexBlockReadCount is the number of index blocks read from disk.
+	IndexBlockReadCount atomic.Uint64
+
+	// FilterBlockReadCount is the number of filter blocks read from disk.
+	FilterBlockReadCount atomic.Uint64
+
+	// SeekOnMemtableCount is the number of seeks into memtable skiplists.
+	SeekOnMemtableCount atomic.Uint64
+
+	// BloomMemtableHitCount is the number of memtable bloom filter probes
+	// that did not rule the key out.
+	BloomMemtableHitCount atomic.Uint64
+
+	// BloomMemtableMissCount is the number of memtable bloom filter probes
+	// that ruled the key out.
+	BloomMemtableMissCount atomic.Uint64
+
+	// BloomSstHitCount is the number of SST prefix bloom filter probes by
+	// iterator seeks that did not rule the prefix out.
+	BloomSstHitCount atomic.Uint64
+
+	// BloomSstMissCount is the number of SST prefix bloom filter probes by
+	// iterator seeks that ruled the prefix out.
+	BloomSstMissCount atomic.Uint64
+}
+
+// IOStats holds the file I/O counters.
+type IOStats struct {
+	// BytesRead is the number of bytes read from SST files.
+	BytesRead atomic.Uint64
+
+	// ReadCount is the number of read calls issued to SST files.
+	ReadCount atomic.Uint64
+
+	// BlobBytesRead is the number of bytes read from blob files.
+	BlobBytesRead atomic.Uint64
+
+	// BlobReadCount is the number of read calls issued to blob files.
+	BlobReadCount atomic.Uint64
+}
+
+var (
+	enabled  atomic.Bool
+	global   Context
+	globalIO IOStats
+)
+
+// SetEnabled turns counting on or off.
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// Enabled reports whether counting is on.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Global returns the process-wide counters.
+func Global() *Context {
+	return &global
+}
+
+// GlobalIOStats returns the process-wide I/O counters.
+func GlobalIOStats() *IOStats {
+	return &globalIO
+}
+
+// Reset zeroes every I/O counter.
+func (s *IOStats) Reset() {
+	s.BytesRead.Store(0)
+	s.ReadCount.Store(0)
+	s.BlobBytesRead.Store(0)
+	s.BlobReadCount.Store(0)
+}
+
+// AddFileRead records a read of n bytes from an SST file.
+func AddFileRead(n int) {
+	if !enabled.Load() {
+		return
+	}
+	globalIO.ReadCount.Add(1)
+	globalIO.BytesRead.Add(uint64(n))
+}
+
+// AddBlobFileRead records a read of n bytes from a blob file.
+func AddBlobFileRead(n int) {
+	if !enabled.Load() {
+		return
+	}
+	globalIO.BlobReadCount.Add(1)
+	globalIO.BlobBytesRead.Add(uint64(n))
+}
+
+// Reset zeroes every counter.
+func (c *Context) Reset() {
+	c.BlockReadCount.Store(0)
+	c.BlockReadByte.Store(0)
+	c.IndexBlockReadCount.Store(0)
+	c.FilterBlockReadCount.Store(0)
+	c.SeekOnMemtableCount.Store(0)
+	c.BloomMemtableHitCount.Store(0)
+	c.BloomMemtableMissCount.Store(0)
+	c.BloomSstHitCount.Store(0)
+	c.BloomSstMissCount.Store(0)
+}
+
+// AddBlockRead records a block of n bytes read from disk.
+func AddBlockRead(n int) {
+	if !enabled.Load() {
+		return
+	}
+	global.BlockReadCount.Add(1)
+	global.BlockReadByte.Add(uint64(n))
+}
+
+// AddIndexBlockRead records an index block read from disk.
+func AddIndexBlockRead() {
+	if enabled.Load() {
+		global.IndexBlockReadCount.Add(1)
+	}
+}
+
+// AddFilterBlockRead records a filter block read from disk.
+func AddFilterBlockRead() {
+	if enabled.Load() {
+		global.FilterBlockReadCount.Add(1)
+	}
+}
+
+// AddSeekOnMemtable records a seek into a memtable.
+func AddSeekOnMemtable() {
+	if enabled.Load() {
+		global.SeekOnMemtableCount.Add(1)
+	}
+}
+
+// AddBloomMemtable records a memtable bloom filter probe.
+func AddBloomMemtable(mayContain bool) {
+	if !enabled.Load() {
+		return
+	}
+	if mayContain {
+		global.BloomMemtableHitCount.Add(1)
+	} else {
+		global.BloomMemtableMissCount.Add(1)
+	}
+}
+
+// AddBloomSst records an SST bloom filter probe.
+func AddBloomSst(mayContain bool) {
+	if !enabled.Load() {
+		return
+	}
+	if mayContain {
+		global.BloomSstHitCount.Add(1)
+	} else {
+		global.BloomSstMissCount.Add(1)
+	}
+}
@@ -16,8 +16,10 @@
 package table
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand/v2"
 	"sort"
@@ -79,8 +81,29 @@ type BuilderOptions struct {
 
 	// Compression is the compression type for data blocks.
 	Compression compression.Type
+
+	// Prefix, if set, returns the prefix of a user key to add to the filter
+	// next to the whole key, and false for keys outside the prefix domain.
+	// Readers then answer PrefixMayMatch for PrefixExtractorName.
+	Prefix func(key []byte) ([]byte, bool)
+
+	// PrefixExtractorName is the name of the prefix extractor behind Prefix,
+	// recorded in the table properties.
+	PrefixExtractorName string
+
+	// VerifyCompression decompresses every compressed block and fails the
+	// build with ErrCompressionVerification unless it matches the original.
+	VerifyCompression bool
 }
 
+// ErrCompressionVerification indicates that a compressed block did not
+// decompress to its contents.
+var ErrCompressionVerification = errors.New("table: compressed block does not decompress to its contents")
+
+// compressBlock compresses a data block. Tests replace it to corrupt the
+// compressor's output.
+var compressBlock = compression.Compress
+
 // DefaultBuilderOptions returns default options for TableBuilder.
 func DefaultBuilderOptions() BuilderOptions {
 	return BuilderOptions{
@@ -119,6 +142,8 @@ type TableBuilder struct {
 
 	// Filter builder (optional, nil if disabled)
 	filterBuilder *filter.BloomFilterBuilder
+	lastPrefix    []byte // Last prefix added to the filter
+	hasPrefix     bool   // Whether a prefix was added to the filter
 
 	// Pending index entry for the last flushed data block
 	pendingIndexEntry bool
@@ -137,6 +162,8 @@ type TableBuilder struct {
 	indexSize         uint64 // size of index block (excluding trailer)
 	filterSize        uint64 // size of filter block
 	numRangeDeletions uint64 // number of range tombstones
+	smallestSeqno     uint64 // smallest sequence number of entries and range tombstones
+	largestSeqno      uint64 // largest sequence number of entries and range tombstones
 
 	// State tracking
 	finished bool
@@ -205,6 +232,7 @@ func (tb *TableBuilder) AddRangeTombstone(startKey, endKey []byte, seqNum dbform
 
 	// Add to range deletion block: key = internal key, value = end key
 	tb.rangeDelBlock.Add(internalKey, endKey)
+	tb.trackSeqno(uint64(seqNum))
 	tb.numRangeDeletions++
 
 	return nil
@@ -262,6 +290,9 @@ func (tb *TableBuilder) Add(key, value []byte) error {
 
 	// Add to data block
 	tb.dataBlock.Add(key, value)
+	if len(key) >= dbformat.NumInternalBytes {
+		tb.trackSeqno(uint64(dbformat.ExtractSequenceNumber(key)))
+	}
 	tb.numEntries++
 	tb.rawKeySize += uint64(len(key))
 	tb.rawValueSize += uint64(len(value))
@@ -270,10 +301,18 @@ func (tb *TableBuilder) Add(key, value []byte) error {
 	if tb.filterBuilder != nil {
 		// For internal keys, the user key is everything except the last 8 bytes
 		userKey := key
-		if len(key) > 8 {
-			userKey = key[:len(key)-8]
+		if len(key) >= dbformat.NumInternalBytes {
+			userKey = key[:len(key)-dbformat.NumInternalBytes]
 		}
 		tb.filterBuilder.AddKey(userKey)
+		if tb.options.Prefix != nil {
+			// Keys sharing a prefix are adjacent, so each prefix is added once
+			if prefix, ok := tb.options.Prefix(userKey); ok && (!tb.hasPrefix || !bytes.Equal(prefix, tb.lastPrefix)) {
+				tb.filterBuilder.AddKey(prefix)
+				tb.lastPrefix = append(tb.lastPrefix[:0], prefix...)
+				tb.hasPrefix = true
+			}
+		}
 	}
 
 	// Save last key for index
@@ -290,6 +329,17 @@ func (tb *TableBuilder) Add(key, value []byte) error {
 	return nil
 }
 
+// trackSeqno widens the sequence number range reported in the
+// rocksdb.key.smallest.seqno and rocksdb.key.largest.seqno properties.
+func (tb *TableBuilder) trackSeqno(seq uint64) {
+	if tb.numEntries == 0 && tb.numRangeDeletions == 0 {
+		tb.smallestSeqno, tb.largestSeqno = seq, seq
+		return
+	}
+	tb.smallestSeqno = min(tb.smallestSeqno, seq)
+	tb.largestSeqno = max(tb.largestSeqno, seq)
+}
+
 // flushDataBlock writes the current data block to the file.
 func (tb *TableBuilder) flushDataBlock() error {
 	if tb.dataBlock.Empty() {
@@ -326,8 +376,14 @@ func (tb *TableBuilder) writeBlockWithTrailer(blockData []byte, blockType block.
 	compressionType := block.CompressionNone
 
 	if tb.options.Compression != compression.NoCompression && blockType == block.TypeData {
-		compressed, err := compression.Compress(tb.options.Compression, blockData)
+		compressed, err := compressBlock(tb.options.Compression, blockData)
 		if err == nil && compressed != nil && len(compressed) < len(blockData) {
+			if tb.options.VerifyCompression {
+				decompressed, err := compression.DecompressWithSize(tb.options.Compression, compressed, len(blockData))
+				if err != nil || !bytes.Equal(decompressed, blockData) {
+					return block.Handle{}, fmt.Errorf("%w: %s block at offset %d", ErrCompressionVerification, tb.options.Compression, tb.offset)
+				}
+			}
 			// Only use compression if it actually reduces size
 			// For format_version >= 2, prepend varint32 decompressed size for most algorithms.
 			// Exception: Snappy embeds the uncompressed size in its format, so no prefix needed.
@@ -584,8 +640,20 @@ func (tb *TableBuilder) writePropertiesBlock() (block.Handle, error) {
 	addUint64Prop("rocksdb.filter.size", tb.filterSize)
 	addUint64Prop("rocksdb.format.version", uint64(tb.options.FormatVersion))
 	addUint64Prop("rocksdb.index.size", tb.indexSize)
+	if tb.numEntries > 0 || tb.numRangeDeletions > 0 {
+		addUint64Prop(PropKeyLargestSeqno, tb.largestSeqno)
+		addUint64Prop(PropKeySmallestSeqno, tb.smallestSeqno)
+	}
 	addUint64Prop("rocksdb.num.data.blocks", tb.numDataBlocks)
 	addUint64Prop("rocksdb.num.entries", tb.numEntries)
+	if tb.options.Prefix != nil && tb.options.PrefixExtractorName != "" {
+		addStringProp(PropPrefixExtractorName, tb.options.PrefixExtractorName)
+	}
+	if tb.filterSize > 0 {
+		// The filter holds every user key; a user-collected property, as in
+		// RocksDB's BlockBasedTablePropertiesCollector
+		addStringProp(PropWholeKeyFiltering, "1")
+	}
 	if tb.numRangeDeletions > 0 {
 		addUint64Prop("rocksdb.num.range-deletions", tb.numRangeDeletions)
 	}
@@ -648,6 +716,27 @@ func (tb *TableBuilder) FileSize() uint64 {
 	return tb.offset
 }
 
+// estimatedTailSize is an allowance for the properties block, metaindex block
+// and footer, which are only written by Finish.
+const estimatedTailSize = 2048
+
+// EstimatedFileSize returns an estimate of the file size if Finish were called
+// now: the bytes written so far plus the buffered data, index, filter and range
+// deletion blocks and an allowance for the fixed-size tail of the file.
+func (tb *TableBuilder) EstimatedFileSize() uint64 {
+	size := tb.offset + estimatedTailSize
+	size += uint64(tb.dataBlock.EstimatedSize() + block.BlockTrailerSize)
+	size += uint64(tb.indexBlock.EstimatedSize() + block.BlockTrailerSize)
+	if tb.pendingIndexEntry {
+		size += uint64(len(tb.lastKey) + 2*binary.MaxVarintLen64)
+	}
+	size += uint64(tb.rangeDelBlock.EstimatedSize() + block.BlockTrailerSize)
+	if tb.filterBuilder != nil {
+		size += uint64(tb.filterBuilder.EstimatedSize() + block.BlockTrailerSize)
+	}
+	return size
+}
+
 // Status returns any error encountered during building.
 func (tb *TableBuilder) Status() error {
 	return tb.err
@@ -314,6 +314,28 @@ func TestTableBuilderManyEntries(t *testing.T) {
 	}
 }
 
+func TestTableBuilderEstimatedFileSize(t *testing.T) {
+	var buf bytes.Buffer
+	tb := NewTableBuilder(&buf, DefaultBuilderOptions())
+
+	for i := range 500 {
+		key := fmt.Appendf(nil, "key%05d", i)
+		if err := tb.Add(key, bytes.Repeat([]byte("v"), 100)); err != nil {
+			t.Fatalf("Add(%s) error = %v", key, err)
+		}
+	}
+
+	estimate := tb.EstimatedFileSize()
+	if err := tb.Finish(); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	// The estimate must not undershoot, so callers can use it as a size cap.
+	if actual := uint64(buf.Len()); estimate < actual {
+		t.Errorf("EstimatedFileSize() = %d, want >= finished size %d", estimate, actual)
+	}
+}
+
 func TestTableBuilderEmptyKey(t *testing.T) {
 	var buf bytes.Buffer
 	opts := DefaultBuilderOptions()
@@ -10,6 +10,8 @@ package table
 import (
 	"sync"
 
+	"github.com/aalhour/rockyardkv/internal/cache"
+	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/vfs"
 )
 
@@ -28,12 +30,16 @@ type TableCache struct {
 	lruHead *cachedReader
 	lruTail *cachedReader
 
-	// Maximum number of open readers to cache
+	// Maximum number of open readers to cache; <= 0 means unlimited
 	maxSize int
 
 	// Current number of cached readers
 	size int
 
+	// Reads recorded by the evicted readers of each file, so that read
+	// counts outlive evictions too. Dropped only by Evict.
+	evictedReads map[uint64]uint64
+
 	// Reader options
 	opts ReaderOptions
 }
@@ -49,15 +55,32 @@ type cachedReader struct {
 
 	// Reference count (how many active users)
 	refs int
+
+	// Set by Evict while the reader is in use; the reader is removed on
+	// its last release
+	evicted bool
+
+	// Size index of the file, built on first use and dropped with the
+	// reader, so that MaxOpenFiles bounds the indexes kept too
+	sizeIndex *SizeIndex
 }
 
 // TableCacheOptions configures the TableCache.
 type TableCacheOptions struct {
 	// MaxOpenFiles is the maximum number of SST files to keep open.
+	// Readers beyond this bound are closed in LRU order once released, and
+	// reopened on demand. A value <= 0 keeps every reader open.
 	MaxOpenFiles int
 
 	// VerifyChecksums enables checksum verification when reading blocks.
 	VerifyChecksums bool
+
+	// BlockCache caches the data blocks of the open files (nil = disabled).
+	BlockCache cache.Cache
+
+	// VerifyBlockCacheEntries checksums the blocks in BlockCache; see
+	// ReaderOptions.VerifyBlockCacheEntries.
+	VerifyBlockCacheEntries bool
 }
 
 // DefaultTableCacheOptions returns default options.
@@ -71,11 +94,14 @@ func DefaultTableCacheOptions() TableCacheOptions {
 // NewTableCache creates a new TableCache.
 func NewTableCache(fs vfs.FS, opts TableCacheOptions) *TableCache {
 	return &TableCache{
-		fs:      fs,
-		cache:   make(map[uint64]*cachedReader),
-		maxSize: opts.MaxOpenFiles,
+		fs:           fs,
+		cache:        make(map[uint64]*cachedReader),
+		evictedReads: make(map[uint64]uint64),
+		maxSize:      opts.MaxOpenFiles,
 		opts: ReaderOptions{
-			VerifyChecksums: opts.VerifyChecksums,
+			VerifyChecksums:         opts.VerifyChecksums,
+			BlockCache:              opts.BlockCache,
+			VerifyBlockCacheEntries: opts.VerifyBlockCacheEntries,
 		},
 	}
 }
@@ -93,13 +119,20 @@ func (tc *TableCache) Get(fileNum uint64, path string) (*Reader, error) {
 		return cr.reader, nil
 	}
 
-	// Not cached, open the file
+	// Not cached. Make room first so the open file count stays within bounds.
+	if tc.maxSize > 0 {
+		tc.evictDownTo(tc.maxSize - 1)
+	}
+
+	// Open the file
 	file, err := tc.fs.OpenRandomAccess(path)
 	if err != nil {
 		return nil, err
 	}
 
-	reader, err := Open(file, tc.opts)
+	readerOpts := tc.opts
+	readerOpts.FileNumber = fileNum
+	reader, err := Open(file, readerOpts)
 	if err != nil {
 		_ = file.Close()
 		return nil, err
@@ -131,17 +164,71 @@ func (tc *TableCache) Release(fileNum uint64) {
 
 	if cr, ok := tc.cache[fileNum]; ok {
 		cr.refs--
+		if cr.refs == 0 {
+			if cr.evicted {
+				tc.remove(cr)
+				return
+			}
+			tc.evictIfNeeded()
+		}
+	}
+}
+
+// SizeIndex returns the size index of the given file, whose keys are
+// ordered by cmp. The index block is decoded once per open of the file:
+// the index is kept until the reader is evicted.
+func (tc *TableCache) SizeIndex(fileNum uint64, path string, cmp *dbformat.InternalKeyComparator) (*SizeIndex, error) {
+	reader, err := tc.Get(fileNum, path)
+	if err != nil {
+		return nil, err
+	}
+	defer tc.Release(fileNum)
+
+	tc.mu.Lock()
+	cr := tc.cache[fileNum]
+	s := cr.sizeIndex
+	tc.mu.Unlock()
+	if s != nil {
+		return s, nil
 	}
+
+	s, err = NewSizeIndex(reader, cmp)
+	if err != nil {
+		return nil, err
+	}
+	tc.mu.Lock()
+	cr.sizeIndex = s
+	tc.mu.Unlock()
+	return s, nil
 }
 
-// Evict removes a specific file from the cache.
+// Evict removes a specific file from the cache. A reader still in use is
+// closed once its last reference is released.
 func (tc *TableCache) Evict(fileNum uint64) {
 	tc.mu.Lock()
 	defer tc.mu.Unlock()
 
 	if cr, ok := tc.cache[fileNum]; ok {
-		tc.remove(cr)
+		if cr.refs > 0 {
+			cr.evicted = true
+		} else {
+			tc.remove(cr)
+		}
 	}
+	delete(tc.evictedReads, fileNum)
+}
+
+// NumReads returns the number of point lookups recorded for the given file
+// by its readers, including readers that were evicted since.
+func (tc *TableCache) NumReads(fileNum uint64) uint64 {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	reads := tc.evictedReads[fileNum]
+	if cr, ok := tc.cache[fileNum]; ok {
+		reads += cr.reader.NumReads()
+	}
+	return reads
 }
 
 // Close closes all cached readers and clears the cache.
@@ -153,6 +240,7 @@ func (tc *TableCache) Close() error {
 		_ = cr.reader.Close()
 	}
 	tc.cache = make(map[uint64]*cachedReader)
+	tc.evictedReads = make(map[uint64]uint64)
 	tc.lruHead = nil
 	tc.lruTail = nil
 	tc.size = 0
@@ -225,19 +313,32 @@ func (tc *TableCache) remove(cr *cachedReader) {
 	// Remove from cache map
 	delete(tc.cache, cr.fileNum)
 	tc.size--
+	if reads := cr.reader.NumReads(); reads > 0 && !cr.evicted {
+		tc.evictedReads[cr.fileNum] += reads
+	}
 
 	// Close the reader
 	_ = cr.reader.Close()
 }
 
 // evictIfNeeded evicts the least recently used entries if the cache is full.
+// Readers that are still in use are skipped; they become eligible for
+// eviction when their last reference is released.
 func (tc *TableCache) evictIfNeeded() {
-	for tc.size > tc.maxSize && tc.lruTail != nil {
-		// Don't evict if still in use
-		if tc.lruTail.refs > 0 {
-			break
+	if tc.maxSize <= 0 {
+		return
+	}
+	tc.evictDownTo(tc.maxSize)
+}
+
+// evictDownTo evicts unused readers in LRU order until at most limit remain.
+func (tc *TableCache) evictDownTo(limit int) {
+	for cr := tc.lruTail; cr != nil && tc.size > limit; {
+		prev := cr.prev
+		if cr.refs == 0 {
+			tc.remove(cr)
 		}
-		tc.remove(tc.lruTail)
+		cr = prev
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/vfs"
 )
 
@@ -91,6 +92,64 @@ func TestTableCacheEviction(t *testing.T) {
 	}
 }
 
+func TestTableCacheEvictionSkipsInUseReaders(t *testing.T) {
+	fs := vfs.Default()
+	tmpDir := t.TempDir()
+
+	for i := 1; i <= 3; i++ {
+		if err := createTestSST(fs, filepath.Join(tmpDir, sstFileName(uint64(i)))); err != nil {
+			t.Fatalf("failed to create test SST %d: %v", i, err)
+		}
+	}
+
+	cache := NewTableCache(fs, TableCacheOptions{MaxOpenFiles: 1})
+	defer cache.Close()
+
+	// File 1 stays in use while files 2 and 3 are opened.
+	if _, err := cache.Get(1, filepath.Join(tmpDir, sstFileName(1))); err != nil {
+		t.Fatalf("Get(1) failed: %v", err)
+	}
+	for i := uint64(2); i <= 3; i++ {
+		if _, err := cache.Get(i, filepath.Join(tmpDir, sstFileName(i))); err != nil {
+			t.Fatalf("Get(%d) failed: %v", i, err)
+		}
+		cache.Release(i)
+	}
+	if cache.Size() != 1 {
+		t.Errorf("cache size with reader in use = %d, want 1", cache.Size())
+	}
+
+	// Releasing the last reference keeps the cache at its bound.
+	cache.Release(1)
+	if cache.Size() != 1 {
+		t.Errorf("cache size after release = %d, want 1", cache.Size())
+	}
+}
+
+func TestTableCacheUnlimited(t *testing.T) {
+	fs := vfs.Default()
+	tmpDir := t.TempDir()
+
+	cache := NewTableCache(fs, TableCacheOptions{MaxOpenFiles: -1})
+	defer cache.Close()
+
+	numFiles := 5
+	for i := 1; i <= numFiles; i++ {
+		sstPath := filepath.Join(tmpDir, sstFileName(uint64(i)))
+		if err := createTestSST(fs, sstPath); err != nil {
+			t.Fatalf("failed to create test SST %d: %v", i, err)
+		}
+		if _, err := cache.Get(uint64(i), sstPath); err != nil {
+			t.Fatalf("Get failed for file %d: %v", i, err)
+		}
+		cache.Release(uint64(i))
+	}
+
+	if cache.Size() != numFiles {
+		t.Errorf("cache size = %d, want %d", cache.Size(), numFiles)
+	}
+}
+
 func TestTableCacheEvict(t *testing.T) {
 	fs := vfs.Default()
 	tmpDir := t.TempDir()
@@ -122,6 +181,40 @@ func TestTableCacheEvict(t *testing.T) {
 	}
 }
 
+func TestTableCacheEvictInUse(t *testing.T) {
+	fs := vfs.Default()
+	tmpDir := t.TempDir()
+
+	sstPath := filepath.Join(tmpDir, "000001.sst")
+	if err := createTestSST(fs, sstPath); err != nil {
+		t.Fatalf("failed to create test SST: %v", err)
+	}
+
+	cache := NewTableCache(fs, DefaultTableCacheOptions())
+	defer cache.Close()
+
+	reader, err := cache.Get(1, sstPath)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// The reader stays open for its user until released.
+	cache.Evict(1)
+	iter := reader.NewIterator()
+	iter.SeekToFirst()
+	if !iter.Valid() {
+		t.Fatalf("iterator over evicted reader in use is not valid: %v", iter.Error())
+	}
+	if cache.Size() != 1 {
+		t.Errorf("cache size with evicted reader in use = %d, want 1", cache.Size())
+	}
+
+	cache.Release(1)
+	if cache.Size() != 0 {
+		t.Errorf("cache size after release = %d, want 0", cache.Size())
+	}
+}
+
 func TestTableCacheClose(t *testing.T) {
 	fs := vfs.Default()
 	tmpDir := t.TempDir()
@@ -149,6 +242,127 @@ func TestTableCacheClose(t *testing.T) {
 	}
 }
 
+func TestTableCacheSizeIndex(t *testing.T) {
+	fs := vfs.Default()
+	tmpDir := t.TempDir()
+
+	// A file with many small blocks, plus a second file to displace it
+	path1 := filepath.Join(tmpDir, sstFileName(1))
+	file, err := fs.Create(path1)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	builderOpts := DefaultBuilderOptions()
+	builderOpts.BlockSize = 64
+	builder := NewTableBuilder(file, builderOpts)
+	for i := range 200 {
+		key := makeTestInternalKey([]byte{byte('a' + i/26), byte('a' + i%26)}, 1)
+		if err := builder.Add(key, bytes.Repeat([]byte{'v'}, 16)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	_ = file.Close()
+	path2 := filepath.Join(tmpDir, sstFileName(2))
+	if err := createTestSST(fs, path2); err != nil {
+		t.Fatalf("failed to create test SST: %v", err)
+	}
+
+	cache := NewTableCache(fs, TableCacheOptions{MaxOpenFiles: 1})
+	defer cache.Close()
+
+	index, err := cache.SizeIndex(1, path1, nil)
+	if err != nil {
+		t.Fatalf("SizeIndex failed: %v", err)
+	}
+
+	// Offsets agree with the reader's own approximation
+	reader, err := cache.Get(1, path1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	for _, userKey := range []string{"a", "ab", "cq", "gz", "hz"} {
+		key := makeTestInternalKey([]byte(userKey), 1<<40)
+		if got, want := index.OffsetOf(key), reader.ApproximateOffsetOf(key); got != want {
+			t.Errorf("OffsetOf(%q) = %d, want %d", userKey, got, want)
+		}
+	}
+	cache.Release(1)
+	if size := index.ApproximateSize(makeTestInternalKey([]byte("b"), 1<<40), makeTestInternalKey([]byte("d"), 1<<40)); size == 0 {
+		t.Error("ApproximateSize over several blocks = 0")
+	}
+
+	// The index is kept with the open reader
+	again, err := cache.SizeIndex(1, path1, nil)
+	if err != nil {
+		t.Fatalf("SizeIndex failed: %v", err)
+	}
+	if again != index {
+		t.Error("SizeIndex was rebuilt while the reader was open")
+	}
+
+	// Evicting the reader, here by opening another file, drops it
+	if _, err := cache.Get(2, path2); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	cache.Release(2)
+	rebuilt, err := cache.SizeIndex(1, path1, nil)
+	if err != nil {
+		t.Fatalf("SizeIndex failed: %v", err)
+	}
+	if rebuilt == index {
+		t.Error("SizeIndex survived the eviction of its reader")
+	}
+}
+
+func TestSizeIndexComparator(t *testing.T) {
+	fs := vfs.Default()
+	path := filepath.Join(t.TempDir(), sstFileName(1))
+	file, err := fs.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Keys in descending order, as a reverse comparator sorts them
+	builderOpts := DefaultBuilderOptions()
+	builderOpts.BlockSize = 64
+	builder := NewTableBuilder(file, builderOpts)
+	for i := 199; i >= 0; i-- {
+		key := makeTestInternalKey([]byte{byte('a' + i/26), byte('a' + i%26)}, 1)
+		if err := builder.Add(key, bytes.Repeat([]byte{'v'}, 16)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	_ = file.Close()
+
+	cache := NewTableCache(fs, DefaultTableCacheOptions())
+	defer cache.Close()
+	reverse := dbformat.NewInternalKeyComparator(func(a, b []byte) int { return bytes.Compare(b, a) })
+	index, err := cache.SizeIndex(1, path, reverse)
+	if err != nil {
+		t.Fatalf("SizeIndex failed: %v", err)
+	}
+
+	// In reverse order, [hz, fa) starts at the file's first entry and ends
+	// before the data of fa and the keys after it
+	start := index.OffsetOf(makeTestInternalKey([]byte("hz"), 1<<40))
+	limit := index.OffsetOf(makeTestInternalKey([]byte("fa"), 1<<40))
+	if start != 0 {
+		t.Errorf("OffsetOf(hz) = %d, want 0", start)
+	}
+	if limit <= start {
+		t.Errorf("OffsetOf(fa) = %d, want past OffsetOf(hz) = %d", limit, start)
+	}
+	if end := index.OffsetOf(makeTestInternalKey([]byte("a"), 1<<40)); end <= limit {
+		t.Errorf("OffsetOf(a) = %d, want past OffsetOf(fa) = %d", end, limit)
+	}
+}
+
 // createTestSST creates a simple SST file for testing.
 func createTestSST(fs vfs.FS, path string) error {
 	file, err := fs.Create(path)
@@ -218,3 +432,52 @@ func formatSSTName(number uint64) string {
 	}
 	return string(b) + ".sst"
 }
+
+func TestTableCacheNumReadsSurvivesEviction(t *testing.T) {
+	fs := vfs.Default()
+	tmpDir := t.TempDir()
+
+	for i := uint64(1); i <= 2; i++ {
+		if err := createTestSST(fs, filepath.Join(tmpDir, sstFileName(i))); err != nil {
+			t.Fatalf("failed to create test SST %d: %v", i, err)
+		}
+	}
+
+	cache := NewTableCache(fs, TableCacheOptions{MaxOpenFiles: 1})
+	defer cache.Close()
+
+	reader, err := cache.Get(1, filepath.Join(tmpDir, sstFileName(1)))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	reader.RecordRead()
+	reader.RecordRead()
+	cache.Release(1)
+
+	// Opening file 2 evicts the reader of file 1
+	if _, err := cache.Get(2, filepath.Join(tmpDir, sstFileName(2))); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	cache.Release(2)
+	if got := cache.NumReads(1); got != 2 {
+		t.Errorf("NumReads(1) after eviction = %d, want 2", got)
+	}
+
+	reader, err = cache.Get(1, filepath.Join(tmpDir, sstFileName(1)))
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	reader.RecordRead()
+	cache.Release(1)
+	if got := cache.NumReads(1); got != 3 {
+		t.Errorf("NumReads(1) after reopen = %d, want 3", got)
+	}
+	if got := cache.NumReads(2); got != 0 {
+		t.Errorf("NumReads(2) = %d, want 0", got)
+	}
+
+	cache.Evict(1)
+	if got := cache.NumReads(1); got != 0 {
+		t.Errorf("NumReads(1) after Evict = %d, want 0", got)
+	}
+}
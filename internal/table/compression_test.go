@@ -2,6 +2,7 @@ package table
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -243,3 +244,35 @@ func BenchmarkTableCompressionNone(b *testing.B) {
 		builder.Finish()
 	}
 }
+
+func TestTableBuilderVerifyCompression(t *testing.T) {
+	// A compressor whose output does not decompress to its input
+	defer func(orig func(compression.Type, []byte) ([]byte, error)) { compressBlock = orig }(compressBlock)
+	compressBlock = func(ct compression.Type, data []byte) ([]byte, error) {
+		data = bytes.Clone(data)
+		data[0] ^= 0xff
+		return compression.Compress(ct, data)
+	}
+
+	build := func(verify bool) error {
+		opts := DefaultBuilderOptions()
+		opts.Compression = compression.SnappyCompression
+		opts.VerifyCompression = verify
+		builder := NewTableBuilder(&bytes.Buffer{}, opts)
+		for i := range 50 {
+			key := fmt.Sprintf("key%05d", i)
+			value := fmt.Sprintf("value%05d_repeated_repeated_repeated_data", i)
+			if err := builder.Add([]byte(key), []byte(value)); err != nil {
+				return err
+			}
+		}
+		return builder.Finish()
+	}
+
+	if err := build(false); err != nil {
+		t.Fatalf("build without verification failed: %v", err)
+	}
+	if err := build(true); !errors.Is(err, ErrCompressionVerification) {
+		t.Errorf("build with verification = %v, want ErrCompressionVerification", err)
+	}
+}
@@ -253,3 +253,99 @@ func BenchmarkTableFilterLookup(b *testing.B) {
 		reader.KeyMayMatch(lookupKey)
 	}
 }
+
+func TestTableBuilderWithPrefixFilter(t *testing.T) {
+	opts := DefaultBuilderOptions()
+	opts.PrefixExtractorName = "rocksdb.FixedPrefix"
+	opts.Prefix = func(key []byte) ([]byte, bool) {
+		if len(key) < 4 {
+			return nil, false
+		}
+		return key[:4], true
+	}
+
+	buf := &bytes.Buffer{}
+	builder := NewTableBuilder(buf, opts)
+	for i := range 100 {
+		prefix := []string{"aaaa", "bbbb"}[i/50]
+		key := makeInternalKey(fmt.Appendf(nil, "%s%04d", prefix, i), uint64(i+1), 0x01)
+		if err := builder.Add(key, []byte("value")); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	reader, err := Open(NewMemFile(buf.Bytes()), ReaderOptions{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reader.Close()
+
+	props, err := reader.Properties()
+	if err != nil {
+		t.Fatalf("Properties failed: %v", err)
+	}
+	if props.PrefixExtractorName != opts.PrefixExtractorName {
+		t.Errorf("PrefixExtractorName = %q, want %q", props.PrefixExtractorName, opts.PrefixExtractorName)
+	}
+	for _, prefix := range []string{"aaaa", "bbbb"} {
+		if !reader.PrefixMayMatch([]byte(prefix), opts.PrefixExtractorName) {
+			t.Errorf("PrefixMayMatch(%s) = false, want true", prefix)
+		}
+	}
+	// Whole keys are still in the filter
+	if !reader.KeyMayMatch([]byte("aaaa0007")) {
+		t.Error("KeyMayMatch(aaaa0007) = false, want true")
+	}
+
+	misses := 0
+	for i := range 1000 {
+		if !reader.PrefixMayMatch(fmt.Appendf(nil, "z%03d", i), opts.PrefixExtractorName) {
+			misses++
+		}
+	}
+	if misses < 980 {
+		t.Errorf("PrefixMayMatch ruled out %d of 1000 absent prefixes, want at least 980", misses)
+	}
+
+	// The filter only answers for the extractor it was built with
+	if !reader.PrefixMayMatch([]byte("z000"), "rocksdb.CappedPrefix") {
+		t.Error("PrefixMayMatch with another extractor = false, want true")
+	}
+}
+
+// TestTableBuilderWholeKeyFiltering verifies that tables with a filter record
+// whole key filtering, and that the empty user key is in the filter.
+func TestTableBuilderWholeKeyFiltering(t *testing.T) {
+	for _, bitsPerKey := range []int{0, 10} {
+		opts := DefaultBuilderOptions()
+		opts.FilterBitsPerKey = bitsPerKey
+
+		buf := &bytes.Buffer{}
+		builder := NewTableBuilder(buf, opts)
+		for i, userKey := range []string{"", "key"} {
+			if err := builder.Add(makeInternalKey([]byte(userKey), uint64(i+1), 0x01), []byte("value")); err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+		}
+		if err := builder.Finish(); err != nil {
+			t.Fatalf("Finish failed: %v", err)
+		}
+
+		reader, err := Open(NewMemFile(buf.Bytes()), ReaderOptions{})
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if got, want := reader.WholeKeyFiltering(), bitsPerKey > 0; got != want {
+			t.Errorf("bits=%d: WholeKeyFiltering() = %v, want %v", bitsPerKey, got, want)
+		}
+		for _, userKey := range []string{"", "key"} {
+			if !reader.KeyMayMatch([]byte(userKey)) {
+				t.Errorf("bits=%d: KeyMayMatch(%q) = false, want true", bitsPerKey, userKey)
+			}
+		}
+		_ = reader.Close()
+	}
+}
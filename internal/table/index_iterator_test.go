@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/block"
 )
 
 // TestIndexBlockIteratorDirect tests the TableIterator with multi-block tables.
@@ -391,7 +393,8 @@ func TestIndexBlockIteratorPrevAndKey(t *testing.T) {
 func TestIndexBlockIteratorSyntheticData(t *testing.T) {
 	// Create a synthetic index block with value_delta_encoding format:
 	// Each entry is: <shared:varint><non_shared:varint><key_delta><value>
-	// Value is a BlockHandle: <offset:varint><size:varint>
+	// Value is a BlockHandle: <offset:varint><size:varint>, or only the
+	// signed size delta for entries that share key bytes
 	var buf bytes.Buffer
 
 	// Entry 1: key="key1", handle=(offset=0, size=100)
@@ -401,12 +404,11 @@ func TestIndexBlockIteratorSyntheticData(t *testing.T) {
 	buf.WriteByte(0)   // offset = 0
 	buf.WriteByte(100) // size = 100
 
-	// Entry 2: key="key2", handle=(offset=100, size=100)
+	// Entry 2: key="key2", handle=(offset=105, size=100) after the 5-byte trailer
 	buf.WriteByte(3) // shared = 3 ("key" shared)
 	buf.WriteByte(1) // non_shared = 1 ("2")
 	buf.Write([]byte("2"))
-	buf.WriteByte(100) // offset = 100
-	buf.WriteByte(100) // size = 100
+	buf.WriteByte(0) // size delta = 0
 
 	// Add restart points (1 restart at offset 0)
 	restartData := make([]byte, 8)
@@ -445,6 +447,13 @@ func TestIndexBlockIteratorSyntheticData(t *testing.T) {
 		if !bytes.Equal(key, []byte("key2")) {
 			t.Errorf("Second key: got %q, want %q", key, "key2")
 		}
+		handle, err := block.DecodeHandleFrom(iter.Value())
+		if err != nil {
+			t.Fatalf("Second value: %v", err)
+		}
+		if want := (block.Handle{Offset: 105, Size: 100}); handle != want {
+			t.Errorf("Second handle: got %+v, want %+v", handle, want)
+		}
 
 		// After last, should be invalid
 		iter.Next()
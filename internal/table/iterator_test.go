@@ -2,7 +2,10 @@ package table
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/block"
 )
 
 // -----------------------------------------------------------------------------
@@ -484,6 +487,86 @@ func TestTableIteratorSeekBetween(t *testing.T) {
 	}
 }
 
+// TestReaderApproximateOffsetOf tests that offsets grow with the key and
+// that keys past the end map to the end of the data section.
+func TestReaderApproximateOffsetOf(t *testing.T) {
+	memFile := &memFileForTest{}
+	opts := DefaultBuilderOptions()
+	opts.BlockSize = 256
+	builder := NewTableBuilder(memFile, opts)
+
+	for i := range 1000 {
+		key := makeIterTestKey(fmt.Appendf(nil, "key%05d", i), 1)
+		if err := builder.Add(key, bytes.Repeat([]byte("v"), 64)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	reader, err := Open(&readableMemFile{memFile}, ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reader.Close()
+
+	first := reader.ApproximateOffsetOf(makeIterTestKey([]byte("key00000"), 1))
+	mid := reader.ApproximateOffsetOf(makeIterTestKey([]byte("key00500"), 1))
+	end := reader.ApproximateOffsetOf(makeIterTestKey([]byte("zzz"), 1))
+
+	if first != 0 {
+		t.Errorf("offset of first key = %d, want 0", first)
+	}
+	if mid <= first || mid >= end {
+		t.Errorf("offset of middle key = %d, want in (%d, %d)", mid, first, end)
+	}
+	if end > uint64(len(memFile.data)) {
+		t.Errorf("offset past end = %d, exceeds file size %d", end, len(memFile.data))
+	}
+}
+
+func TestReaderIndexEntries(t *testing.T) {
+	memFile := &memFileForTest{}
+	opts := DefaultBuilderOptions()
+	opts.BlockSize = 256
+	builder := NewTableBuilder(memFile, opts)
+
+	for i := range 200 {
+		key := makeIterTestKey(fmt.Appendf(nil, "key%05d", i), 1)
+		if err := builder.Add(key, bytes.Repeat([]byte("v"), 64)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	reader, err := Open(&readableMemFile{memFile}, ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reader.Close()
+
+	entries, err := reader.IndexEntries()
+	if err != nil {
+		t.Fatalf("IndexEntries failed: %v", err)
+	}
+	if len(entries) < 2 {
+		t.Fatalf("got %d index entries, want several blocks", len(entries))
+	}
+	var offset uint64
+	for i, e := range entries {
+		if e.Handle.Offset != offset {
+			t.Errorf("entry %d offset = %d, want %d", i, e.Handle.Offset, offset)
+		}
+		offset = e.Handle.Offset + e.Handle.Size + block.BlockTrailerSize
+		if i > 0 && bytes.Compare(entries[i-1].Key, e.Key) >= 0 {
+			t.Errorf("entry %d key %q not after %q", i, e.Key, entries[i-1].Key)
+		}
+	}
+}
+
 // TestTableIteratorRepeatedSeeks tests multiple seeks on the same iterator.
 func TestTableIteratorRepeatedSeeks(t *testing.T) {
 	memFile := &memFileForTest{}
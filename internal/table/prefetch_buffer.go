@@ -0,0 +1,140 @@
+package table
+
+// prefetch_buffer.go implements readahead for sequential data block reads.
+//
+// Reference: RocksDB v10.7.5
+//   - file/file_prefetch_buffer.h
+//   - file/file_prefetch_buffer.cc
+//   - table/block_based/block_prefetcher.cc
+
+import "github.com/aalhour/rockyardkv/internal/perf"
+
+const (
+	// maxReadaheadGrowth bounds how far an explicit readahead window can grow,
+	// as a multiple of its initial size.
+	maxReadaheadGrowth = 8
+
+	// initialAutoReadaheadSize is the first readahead window of automatic
+	// readahead.
+	// Reference: RocksDB v10.7.5 BlockBasedTableOptions::initial_auto_readahead_size
+	initialAutoReadaheadSize = 8 * 1024
+
+	// maxAutoReadaheadSize caps the automatic readahead window.
+	// Reference: RocksDB v10.7.5 BlockBasedTableOptions::max_auto_readahead_size
+	maxAutoReadaheadSize = 256 * 1024
+
+	// numFileReadsForAutoReadahead is the number of sequential block reads
+	// after which automatic readahead starts.
+	// Reference: RocksDB v10.7.5 BlockBasedTableOptions::num_file_reads_for_auto_readahead
+	numFileReadsForAutoReadahead = 2
+)
+
+// prefetchBuffer serves block reads from a window of the file read ahead of
+// the requested offset. The window doubles each time a sequential read runs
+// past it, up to maxReadaheadSize, and shrinks back to its initial size when
+// a read is not sequential.
+//
+// In automatic mode, blocks are read one at a time until
+// numFileReadsForAutoReadahead sequential reads have been seen, so that
+// point lookups and short scans don't pay for readahead.
+type prefetchBuffer struct {
+	file ReadableFile
+
+	// limit is the end of the region that may be read ahead (the data section)
+	limit uint64
+
+	// Buffered window [offset, offset+len(buf))
+	buf    []byte
+	offset uint64
+
+	initialReadaheadSize uint64
+	readaheadSize        uint64
+	maxReadaheadSize     uint64
+
+	// Sequential access detection
+	auto            bool
+	lastEnd         uint64 // End offset of the previous read
+	hasLast         bool
+	sequentialReads int // Sequential reads since the last non-sequential one
+}
+
+// newPrefetchBuffer creates a prefetch buffer that reads ahead readaheadSize
+// bytes, never past limit.
+func newPrefetchBuffer(file ReadableFile, limit uint64, readaheadSize uint64) *prefetchBuffer {
+	return &prefetchBuffer{
+		file:                 file,
+		limit:                limit,
+		initialReadaheadSize: readaheadSize,
+		readaheadSize:        readaheadSize,
+		maxReadaheadSize:     readaheadSize * maxReadaheadGrowth,
+	}
+}
+
+// newAutoPrefetchBuffer creates a prefetch buffer that starts reading ahead
+// once it detects a sequential scan, growing from initialAutoReadaheadSize
+// to maxAutoReadaheadSize.
+func newAutoPrefetchBuffer(file ReadableFile, limit uint64) *prefetchBuffer {
+	return &prefetchBuffer{
+		file:                 file,
+		limit:                limit,
+		initialReadaheadSize: initialAutoReadaheadSize,
+		readaheadSize:        initialAutoReadaheadSize,
+		maxReadaheadSize:     maxAutoReadaheadSize,
+		auto:                 true,
+	}
+}
+
+// read returns the n bytes at offset. The returned slice is never modified
+// afterwards, so it may be retained by the caller.
+func (p *prefetchBuffer) read(offset uint64, n int) ([]byte, error) {
+	sequential := p.hasLast && offset == p.lastEnd
+	p.lastEnd, p.hasLast = offset+uint64(n), true
+	if sequential {
+		p.sequentialReads++
+	} else {
+		p.sequentialReads = 0
+		p.readaheadSize = p.initialReadaheadSize
+	}
+
+	end := p.offset + uint64(len(p.buf))
+	if offset >= p.offset && offset+uint64(n) <= end {
+		start := offset - p.offset
+		return p.buf[start : start+uint64(n)], nil
+	}
+
+	if (p.auto && p.sequentialReads < numFileReadsForAutoReadahead) || (!sequential && offset < p.offset) {
+		// No scan detected yet, or reading backwards where readahead would
+		// only fetch bytes already passed
+		return p.readDirect(offset, n)
+	}
+
+	size := max(uint64(n), p.readaheadSize)
+	if offset+size > p.limit {
+		size = max(uint64(n), p.limit-min(offset, p.limit))
+	}
+
+	buf, err := p.readDirect(offset, int(size))
+	if err != nil {
+		return nil, err
+	}
+	p.buf = buf
+	p.offset = offset
+	if sequential {
+		p.readaheadSize = min(p.readaheadSize*2, p.maxReadaheadSize)
+	}
+	return buf[:n], nil
+}
+
+// readDirect reads n bytes at offset without buffering.
+func (p *prefetchBuffer) readDirect(offset uint64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := p.file.ReadAt(buf, int64(offset))
+	if err != nil && read < n {
+		return nil, err
+	}
+	if read < n {
+		return nil, ErrInvalidSST
+	}
+	perf.AddFileRead(n)
+	return buf, nil
+}
@@ -0,0 +1,208 @@
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+)
+
+// countingFile is a ReadableFile that records the size of every read.
+// A non-zero latency is added to each read, simulating a cold device.
+type countingFile struct {
+	data    []byte
+	reads   []int
+	latency time.Duration
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads = append(f.reads, len(p))
+	for start := time.Now(); time.Since(start) < f.latency; {
+		// Busy-wait: sleeps are too coarse for per-read device latency
+	}
+	if off >= int64(len(f.data)) {
+		return 0, nil
+	}
+	return copy(p, f.data[off:]), nil
+}
+
+func (f *countingFile) Size() int64  { return int64(len(f.data)) }
+func (f *countingFile) Close() error { return nil }
+
+// buildReadaheadTestTable builds a table of n entries in many small blocks.
+func buildReadaheadTestTable(t testing.TB, n int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	opts := DefaultBuilderOptions()
+	opts.BlockSize = 256
+	opts.FilterBitsPerKey = 0
+	builder := NewTableBuilder(&buf, opts)
+	for i := range n {
+		key := dbformat.NewInternalKey(fmt.Appendf(nil, "key%06d", i), dbformat.SequenceNumber(i+1), dbformat.TypeValue)
+		if err := builder.Add(key, bytes.Repeat([]byte{byte('a' + i%26)}, 64)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// scanAll returns every key and value of the table, read forward.
+func scanAll(t testing.TB, data []byte, opts IteratorOptions) ([]string, *countingFile) {
+	t.Helper()
+	return scanAllWithLatency(t, data, opts, 0)
+}
+
+// scanAllWithLatency is scanAll over a file whose reads each take latency.
+func scanAllWithLatency(t testing.TB, data []byte, opts IteratorOptions, latency time.Duration) ([]string, *countingFile) {
+	t.Helper()
+
+	file := &countingFile{data: data, latency: latency}
+	reader, err := Open(file, ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	file.reads = nil
+
+	var entries []string
+	iter := reader.NewIteratorWithOptions(opts)
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		entries = append(entries, string(iter.Key())+"="+string(iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	return entries, file
+}
+
+func TestTableIteratorReadahead(t *testing.T) {
+	data := buildReadaheadTestTable(t, 2000)
+
+	plain, plainFile := scanAll(t, data, IteratorOptions{VerifyChecksums: true})
+	ahead, aheadFile := scanAll(t, data, IteratorOptions{VerifyChecksums: true, ReadaheadSize: 4096})
+
+	if len(plain) != 2000 {
+		t.Fatalf("plain scan returned %d entries, want 2000", len(plain))
+	}
+	if len(ahead) != len(plain) {
+		t.Fatalf("readahead scan returned %d entries, want %d", len(ahead), len(plain))
+	}
+	for i := range plain {
+		if plain[i] != ahead[i] {
+			t.Fatalf("entry %d differs: %q vs %q", i, ahead[i], plain[i])
+		}
+	}
+
+	if len(aheadFile.reads) >= len(plainFile.reads)/4 {
+		t.Errorf("readahead scan issued %d reads, plain scan %d; want far fewer", len(aheadFile.reads), len(plainFile.reads))
+	}
+
+	// The window grows while the scan stays sequential, up to its cap
+	largest := 0
+	for _, n := range aheadFile.reads {
+		largest = max(largest, n)
+	}
+	if largest <= 4096 || largest > 4096*maxReadaheadGrowth {
+		t.Errorf("largest read = %d, want in (4096, %d]", largest, 4096*maxReadaheadGrowth)
+	}
+}
+
+func TestTableIteratorReadaheadSeekAndPrev(t *testing.T) {
+	data := buildReadaheadTestTable(t, 1000)
+
+	reader, err := Open(&countingFile{data: data}, ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	iter := reader.NewIteratorWithOptions(IteratorOptions{VerifyChecksums: true, ReadaheadSize: 2048})
+
+	// Backward iteration over the whole table
+	count := 0
+	for iter.SeekToLast(); iter.Valid(); iter.Prev() {
+		want := fmt.Sprintf("key%06d", 999-count)
+		if got := string(dbformat.ExtractUserKey(iter.Key())); got != want {
+			t.Fatalf("Prev entry %d = %q, want %q", count, got, want)
+		}
+		count++
+	}
+	if count != 1000 {
+		t.Fatalf("Prev visited %d entries, want 1000", count)
+	}
+
+	// Seeks in both directions, each followed by a short forward scan
+	for _, start := range []int{900, 100, 500, 499, 0} {
+		iter.Seek(dbformat.NewInternalKey(fmt.Appendf(nil, "key%06d", start), dbformat.MaxSequenceNumber, dbformat.TypeValue))
+		for i := range 20 {
+			want := fmt.Sprintf("key%06d", start+i)
+			if !iter.Valid() {
+				t.Fatalf("Seek(%d)+%d: iterator invalid: %v", start, i, iter.Error())
+			}
+			if got := string(dbformat.ExtractUserKey(iter.Key())); got != want {
+				t.Fatalf("Seek(%d)+%d = %q, want %q", start, i, got, want)
+			}
+			iter.Next()
+		}
+	}
+}
+
+// BenchmarkTableScanCold measures a full-table scan when every file read
+// pays a device round trip, with and without readahead.
+func BenchmarkTableScanCold(b *testing.B) {
+	data := buildReadaheadTestTable(b, 20000)
+
+	for _, readahead := range []uint64{0, 64 << 10} {
+		b.Run(fmt.Sprintf("readahead=%d", readahead), func(b *testing.B) {
+			for b.Loop() {
+				scanAllWithLatency(b, data, IteratorOptions{ReadaheadSize: readahead}, 20*time.Microsecond)
+			}
+		})
+	}
+}
+
+func TestTableIteratorAutoReadahead(t *testing.T) {
+	data := buildReadaheadTestTable(t, 20000)
+
+	_, file := scanAll(t, data, IteratorOptions{AutoReadahead: true})
+	if len(file.reads) < 4 {
+		t.Fatalf("scan issued %d reads, want at least 4", len(file.reads))
+	}
+
+	// Single-block reads until the scan is detected, then 8KB doubling to 256KB
+	for i, n := range file.reads[:numFileReadsForAutoReadahead] {
+		if n >= initialAutoReadaheadSize {
+			t.Errorf("read %d = %d bytes, want a single block before readahead starts", i, n)
+		}
+	}
+	want := initialAutoReadaheadSize
+	for i, n := range file.reads[numFileReadsForAutoReadahead : len(file.reads)-1] {
+		if n != want {
+			t.Fatalf("readahead read %d = %d bytes, want %d", i, n, want)
+		}
+		want = min(want*2, maxAutoReadaheadSize)
+	}
+	if want != maxAutoReadaheadSize {
+		t.Errorf("readahead never reached %d bytes", maxAutoReadaheadSize)
+	}
+
+	// A non-sequential Seek falls back to single-block reads
+	reader, err := Open(file, ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	iter := reader.NewIteratorWithOptions(IteratorOptions{AutoReadahead: true})
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+	}
+	file.reads = nil
+	iter.Seek(dbformat.NewInternalKey([]byte("key010000"), dbformat.MaxSequenceNumber, dbformat.TypeValue))
+	if !iter.Valid() {
+		t.Fatalf("Seek: iterator invalid: %v", iter.Error())
+	}
+	if len(file.reads) != 1 || file.reads[0] >= initialAutoReadaheadSize {
+		t.Errorf("reads after Seek = %v, want one single-block read", file.reads)
+	}
+}
@@ -43,6 +43,7 @@ const (
 	PropComparator                     = "rocksdb.comparator"
 	PropMergeOperator                  = "rocksdb.merge.operator"
 	PropPrefixExtractorName            = "rocksdb.prefix.extractor.name"
+	PropWholeKeyFiltering              = "rocksdb.block.based.table.whole.key.filtering"
 	PropPropertyCollectors             = "rocksdb.property.collectors"
 	PropCompression                    = "rocksdb.compression"
 	PropCompressionOptions             = "rocksdb.compression_options"
@@ -86,6 +87,10 @@ type TableProperties struct {
 	KeyLargestSeqno   uint64
 	KeySmallestSeqno  uint64
 
+	// HasKeySeqnos reports whether the file records KeyLargestSeqno and
+	// KeySmallestSeqno; files written before they existed leave them zero.
+	HasKeySeqnos bool
+
 	// Boolean-like properties (stored as uint64)
 	IndexKeyIsUserKey              uint64
 	IndexValueIsDeltaEncoded       uint64
@@ -201,6 +206,7 @@ func parseUint64Property(props *TableProperties, key string, value []byte) bool
 		target = &props.UserDefinedTimestampsPersisted
 	case PropKeyLargestSeqno:
 		target = &props.KeyLargestSeqno
+		props.HasKeySeqnos = true
 	case PropKeySmallestSeqno:
 		target = &props.KeySmallestSeqno
 	case PropSlowCompressionEstimatedSize:
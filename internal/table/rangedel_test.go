@@ -99,6 +99,59 @@ func TestTableBuilderAddRangeTombstone(t *testing.T) {
 	}
 }
 
+func TestTableRangeTombstonesInMetaBlock(t *testing.T) {
+	var buf bytes.Buffer
+	opts := DefaultBuilderOptions()
+	opts.FilterBitsPerKey = 0
+	builder := NewTableBuilder(&buf, opts)
+
+	builder.Add(dbformat.NewInternalKey([]byte("a"), 10, dbformat.TypeValue), []byte("val_a"))
+	builder.Add(dbformat.NewInternalKey([]byte("d"), 11, dbformat.TypeValue), []byte("val_d"))
+	if err := builder.AddRangeTombstone([]byte("b"), []byte("e"), 12); err != nil {
+		t.Fatalf("AddRangeTombstone failed: %v", err)
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	reader, err := Open(newMemReadableFile(buf.Bytes()), ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	// The tombstone must be listed in the metaindex under the RocksDB name
+	found := false
+	for _, name := range reader.MetaBlockNames() {
+		if name == "rocksdb.range_del" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("MetaBlockNames() = %v, missing rocksdb.range_del", reader.MetaBlockNames())
+	}
+
+	// ... and never inline with the point data
+	iter := reader.NewIterator()
+	n := 0
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		if dbformat.ExtractValueType(iter.Key()) == dbformat.TypeRangeDeletion {
+			t.Errorf("range deletion %q found in data blocks", iter.Key())
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("data blocks hold %d entries, want 2", n)
+	}
+
+	props, err := reader.Properties()
+	if err != nil {
+		t.Fatalf("Properties failed: %v", err)
+	}
+	if props.NumRangeDeletions != 1 {
+		t.Errorf("NumRangeDeletions = %d, want 1", props.NumRangeDeletions)
+	}
+}
+
 func TestTableBuilderNoRangeTombstones(t *testing.T) {
 	var buf bytes.Buffer
 	opts := DefaultBuilderOptions()
@@ -256,6 +309,14 @@ func TestTableRangeTombstonesProperties(t *testing.T) {
 	if props.NumRangeDeletions != 3 {
 		t.Errorf("NumRangeDeletions = %d, want 3", props.NumRangeDeletions)
 	}
+
+	// The sequence number range covers entries and range tombstones
+	if !props.HasKeySeqnos {
+		t.Fatal("HasKeySeqnos = false, want true")
+	}
+	if props.KeySmallestSeqno != 10 || props.KeyLargestSeqno != 300 {
+		t.Errorf("KeySmallestSeqno, KeyLargestSeqno = %d, %d, want 10, 300", props.KeySmallestSeqno, props.KeyLargestSeqno)
+	}
 }
 
 func TestTableRangeTombstonesShouldDelete(t *testing.T) {
@@ -26,13 +26,16 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync/atomic"
 
 	"github.com/aalhour/rockyardkv/internal/block"
+	"github.com/aalhour/rockyardkv/internal/cache"
 	"github.com/aalhour/rockyardkv/internal/checksum"
 	"github.com/aalhour/rockyardkv/internal/compression"
 	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/encoding"
 	"github.com/aalhour/rockyardkv/internal/filter"
+	"github.com/aalhour/rockyardkv/internal/perf"
 	"github.com/aalhour/rockyardkv/internal/rangedel"
 )
 
@@ -74,6 +77,18 @@ type ReaderOptions struct {
 	// CacheBlocks enables caching of data blocks.
 	// (Not implemented yet - for future block cache integration)
 	CacheBlocks bool
+
+	// BlockCache caches the data blocks read by table iterators, keyed by
+	// FileNumber and block offset (nil = disabled).
+	BlockCache cache.Cache
+
+	// FileNumber identifies the file in BlockCache.
+	FileNumber uint64
+
+	// VerifyBlockCacheEntries stores a checksum with every block added to
+	// BlockCache and verifies it on every cache hit, so that a block
+	// corrupted in memory fails the read with ErrChecksumMismatch.
+	VerifyBlockCacheEntries bool
 }
 
 // Reader reads an SST file in the block-based table format.
@@ -91,6 +106,9 @@ type Reader struct {
 	filterHandle     block.Handle
 	rangeDelHandle   block.Handle
 
+	// Names of all meta blocks listed in the metaindex, in on-disk order
+	metaBlockNames []string
+
 	// Cached blocks (loaded on Open)
 	indexBlock *block.Block
 	properties *TableProperties
@@ -98,9 +116,14 @@ type Reader struct {
 	// Bloom filter reader (optional, nil if no filter)
 	filterReader *filter.BloomFilterReader
 
-	// Index format detection: true if index uses value_delta_encoding (C++ RocksDB format)
-	// false if index uses standard block format (Go-generated SSTs)
+	// Index layout: whether index values are delta encoded and index keys
+	// are user keys (C++ RocksDB format_version >= 4 and >= 3); both false
+	// for the standard block format of Go-generated SSTs
 	indexUsesValueDeltaEncoding bool
+	indexKeyIsUserKey           bool
+
+	// Point lookups that read the table's data
+	numReads atomic.Uint64
 }
 
 // Open opens an SST file for reading.
@@ -160,6 +183,7 @@ func (r *Reader) readFooter() error {
 	if _, err := r.file.ReadAt(buf, offset); err != nil {
 		return err
 	}
+	perf.AddFileRead(footerSize)
 
 	// Decode footer
 	footer, err := block.DecodeFooter(buf, uint64(offset), 0)
@@ -200,6 +224,7 @@ func (r *Reader) readMetaindex() error {
 		if err != nil {
 			continue // Skip invalid entries
 		}
+		r.metaBlockNames = append(r.metaBlockNames, name)
 
 		switch {
 		case name == "rocksdb.index":
@@ -248,32 +273,64 @@ func (r *Reader) checkUnsupportedFeatures() error {
 	return nil
 }
 
-// IndexBlockIterator is a specialized iterator for index blocks.
-// Index blocks use value_delta_encoding (format_version >= 4), where:
-// - Entries have format: <shared:byte><non_shared:byte><key_delta><value>
-// - No value_size is stored; value extends to next entry or end of data
+// IndexBlockIterator is a specialized iterator for the index blocks written
+// by C++ RocksDB, whose layout depends on two table properties:
+//   - rocksdb.index.value.is.delta.encoded (format_version >= 4): entries
+//     have no value length, <shared:varint32><non_shared:varint32><key_delta><value>.
+//     The value of an entry that shares key bytes with its predecessor is
+//     only the signed difference between its block size and the previous
+//     one; the block starts right after the previous block and its trailer.
+//     Other entries hold a full BlockHandle.
+//   - rocksdb.index.key.is.user.key (format_version >= 3): keys are user
+//     keys without the 8-byte sequence number and type trailer.
+//
+// Key returns internal keys in either case: user keys are given the
+// smallest trailer, so they sort after every version of the user key and
+// still bound the keys of their block.
+//
+// Reference: RocksDB v10.7.5 table/block_based/block_based_table_reader.cc
+// (index_key_includes_seq, index_value_is_full) and table/format.cc
+// (IndexValue::DecodeFrom)
 type IndexBlockIterator struct {
 	data        []byte // Block data (without restarts/footer)
 	dataEnd     int    // End of entry data
 	entryStart  int    // Start of current entry (for Prev tracking)
 	current     int    // Current position (after parsing, points to next entry)
-	key         []byte // Current key
-	valueOffset int    // Start of current value
-	valueEnd    int    // End of current value
+	key         []byte // Current key as stored in the block
+	internalKey []byte // Current key with a trailer, for user-key indexes
+	value       []byte // Current value as a full BlockHandle
+	valueBuf    []byte // Backing array for values rebuilt from deltas
+	handle      block.Handle
 	valid       bool
 	err         error
+
+	valueDeltaEncoded bool
+	keyIsUserKey      bool
+	blockTrailerSize  uint64
 }
 
-// NewIndexBlockIterator creates an iterator for an index block.
+// NewIndexBlockIterator creates an iterator for an index block with delta
+// encoded values and internal keys.
 func NewIndexBlockIterator(data []byte, dataEnd int) *IndexBlockIterator {
+	return newIndexBlockIterator(data, dataEnd, true, false, block.BlockTrailerSize)
+}
+
+// newIndexBlockIterator creates an iterator for an index block with the
+// given layout.
+func newIndexBlockIterator(data []byte, dataEnd int, valueDeltaEncoded, keyIsUserKey bool, blockTrailerSize uint64) *IndexBlockIterator {
 	return &IndexBlockIterator{
-		data:    data,
-		dataEnd: dataEnd,
+		data:              data,
+		dataEnd:           dataEnd,
+		valueDeltaEncoded: valueDeltaEncoded,
+		keyIsUserKey:      keyIsUserKey,
+		blockTrailerSize:  blockTrailerSize,
 	}
 }
 
 func (it *IndexBlockIterator) SeekToFirst() {
 	it.key = it.key[:0]
+	it.handle = block.Handle{}
+	it.err = nil
 	it.current = 0
 	it.parseCurrentEntry()
 }
@@ -298,36 +355,21 @@ func (it *IndexBlockIterator) Prev() {
 		return
 	}
 
-	// We need to scan from the beginning to find the entry before the current one.
-	targetEntryStart := it.entryStart
-	it.SeekToFirst()
-
-	var prevKey []byte
-	var prevValueOffset, prevValueEnd, prevEntryStart int
-	found := false
-
-	for it.Valid() && it.entryStart < targetEntryStart {
-		prevKey = append(prevKey[:0], it.key...)
-		prevValueOffset = it.valueOffset
-		prevValueEnd = it.valueEnd
-		prevEntryStart = it.entryStart
-		found = true
-		it.Next()
-	}
-
-	if found {
-		it.key = prevKey
-		it.valueOffset = prevValueOffset
-		it.valueEnd = prevValueEnd
-		it.entryStart = prevEntryStart
-		it.current = it.valueEnd // Ready for next
-		it.valid = true
-	} else {
-		it.valid = false
+	// Keys and delta encoded values depend on the preceding entries, so
+	// find the start of the previous entry with a scan from the beginning
+	// and decode up to it again.
+	target := it.entryStart
+	prevStart := -1
+	for it.SeekToFirst(); it.Valid() && it.entryStart < target; it.Next() {
+		prevStart = it.entryStart
 	}
+	it.seekToEntry(prevStart)
 }
 
 func (it *IndexBlockIterator) Key() []byte {
+	if it.keyIsUserKey {
+		return it.internalKey
+	}
 	return it.key
 }
 
@@ -335,35 +377,31 @@ func (it *IndexBlockIterator) Value() []byte {
 	if !it.valid {
 		return nil
 	}
-	return it.data[it.valueOffset:it.valueEnd]
+	return it.value
 }
 
 func (it *IndexBlockIterator) SeekToLast() {
-	// Iterate to find the last entry
-	it.SeekToFirst()
-	if !it.Valid() {
+	lastStart := -1
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		lastStart = it.entryStart
+	}
+	if it.err != nil {
 		return
 	}
+	it.seekToEntry(lastStart)
+}
 
-	var lastKey []byte
-	var lastValueOffset, lastValueEnd, lastEntryStart int
-
-	for it.Valid() {
-		lastKey = append(lastKey[:0], it.key...)
-		lastValueOffset = it.valueOffset
-		lastValueEnd = it.valueEnd
-		lastEntryStart = it.entryStart
+// seekToEntry positions the iterator at the entry starting at offset start,
+// or invalidates it if start is negative.
+func (it *IndexBlockIterator) seekToEntry(start int) {
+	if start < 0 {
+		it.valid = false
+		return
+	}
+	it.SeekToFirst()
+	for it.Valid() && it.entryStart < start {
 		it.Next()
 	}
-
-	// Restore to last entry
-	it.key = lastKey
-	it.valueOffset = lastValueOffset
-	it.valueEnd = lastValueEnd
-	it.entryStart = lastEntryStart
-	it.current = lastValueEnd
-	it.valid = true
-	it.err = nil
 }
 
 func (it *IndexBlockIterator) Seek(target []byte) {
@@ -371,7 +409,7 @@ func (it *IndexBlockIterator) Seek(target []byte) {
 	it.SeekToFirst()
 	for it.Valid() {
 		// Compare key with target using internal key comparison
-		if block.CompareInternalKeys(it.key, target) >= 0 {
+		if block.CompareInternalKeys(it.Key(), target) >= 0 {
 			return
 		}
 		it.Next()
@@ -386,71 +424,86 @@ func (it *IndexBlockIterator) parseCurrentEntry() {
 
 	// Remember where this entry starts (for Prev)
 	it.entryStart = it.current
+	entry := it.data[:it.dataEnd]
 
-	// Format for C++ RocksDB index blocks with value_delta_encoding (format_version >= 4):
-	// <shared:varint32><non_shared:varint32><key_delta><value>
-	// Note: NO value_length field! The value is a BlockHandle (two varints: offset, size).
-
-	// Read shared key length
-	shared, n := decodeVarint32FromBytes(it.data[it.current:it.dataEnd])
+	// Read shared and non-shared key lengths, and the value length unless
+	// values are delta encoded
+	shared, n := decodeVarint32FromBytes(entry[it.current:])
 	if n == 0 {
-		it.err = ErrInvalidSST
-		it.valid = false
+		it.corrupt()
 		return
 	}
 	it.current += n
-
-	// Read non-shared key length
-	nonShared, n := decodeVarint32FromBytes(it.data[it.current:it.dataEnd])
+	nonShared, n := decodeVarint32FromBytes(entry[it.current:])
 	if n == 0 {
-		it.err = ErrInvalidSST
-		it.valid = false
+		it.corrupt()
 		return
 	}
 	it.current += n
-
-	// Check bounds for key
-	if it.current+int(nonShared) > it.dataEnd {
-		it.err = ErrInvalidSST
-		it.valid = false
-		return
+	valueLen := uint32(0)
+	if !it.valueDeltaEncoded {
+		if valueLen, n = decodeVarint32FromBytes(entry[it.current:]); n == 0 {
+			it.corrupt()
+			return
+		}
+		it.current += n
 	}
 
 	// Build key
-	if int(shared) > len(it.key) {
-		it.err = ErrInvalidSST
-		it.valid = false
+	if it.current+int(nonShared) > it.dataEnd || int(shared) > len(it.key) {
+		it.corrupt()
 		return
 	}
-	it.key = append(it.key[:shared], it.data[it.current:it.current+int(nonShared)]...)
+	it.key = append(it.key[:shared], entry[it.current:it.current+int(nonShared)]...)
 	it.current += int(nonShared)
-
-	// Value is a BlockHandle (two varints: offset and size)
-	it.valueOffset = it.current
-
-	// Decode offset varint
-	_, n = decodeVarint32FromBytes(it.data[it.current:it.dataEnd])
-	if n == 0 {
-		it.err = ErrInvalidSST
-		it.valid = false
-		return
+	if it.keyIsUserKey {
+		it.internalKey = append(append(it.internalKey[:0], it.key...), make([]byte, dbformat.NumInternalBytes)...)
 	}
-	it.current += n
 
-	// Decode size varint
-	_, n = decodeVarint32FromBytes(it.data[it.current:it.dataEnd])
-	if n == 0 {
-		it.err = ErrInvalidSST
-		it.valid = false
-		return
+	valueStart := it.current
+	switch {
+	case !it.valueDeltaEncoded:
+		if it.current+int(valueLen) > it.dataEnd {
+			it.corrupt()
+			return
+		}
+		it.current += int(valueLen)
+		it.value = entry[valueStart:it.current]
+	case shared == 0:
+		// Full BlockHandle: offset and size varints
+		handle, rest, err := block.DecodeHandle(entry[it.current:])
+		if err != nil {
+			it.corrupt()
+			return
+		}
+		it.current = it.dataEnd - len(rest)
+		it.handle = handle
+		it.value = entry[valueStart:it.current]
+	default:
+		// Size delta from the previous block, which this one follows
+		delta, n, err := encoding.DecodeVarsignedint64(entry[it.current:])
+		if err != nil || it.handle.IsNull() {
+			it.corrupt()
+			return
+		}
+		it.current += n
+		it.handle = block.Handle{
+			Offset: it.handle.Offset + it.handle.Size + it.blockTrailerSize,
+			Size:   uint64(int64(it.handle.Size) + delta),
+		}
+		it.valueBuf = it.handle.EncodeTo(it.valueBuf[:0])
+		it.value = it.valueBuf
 	}
-	it.current += n
-
-	it.valueEnd = it.current // Value ends after the two varints
 
 	it.valid = true
 }
 
+// corrupt invalidates the iterator on a malformed entry.
+func (it *IndexBlockIterator) corrupt() {
+	it.err = ErrInvalidSST
+	it.valid = false
+}
+
 // decodeVarint32FromBytes decodes a varint32 from the start of data.
 // Returns the value and number of bytes consumed (0 if error).
 func decodeVarint32FromBytes(data []byte) (uint32, int) {
@@ -491,20 +544,38 @@ func (r *Reader) readIndex() error {
 	if err != nil {
 		return err
 	}
+	perf.AddIndexBlockRead()
 
 	r.indexBlock = indexBlock
 
-	// Detect whether index block uses value_delta_encoding (C++ RocksDB format)
-	// or standard block format (Go-generated SSTs).
-	// For format_version >= 4, C++ RocksDB uses value_delta_encoding.
-	// We detect by trying to parse the first entry with IndexBlockIterator.
-	if r.footer.FormatVersion >= 4 {
+	// The table properties record the index layout. Without them, detect
+	// value_delta_encoding, used by C++ RocksDB for format_version >= 4, by
+	// trying to parse the first entry with IndexBlockIterator.
+	// Reference: RocksDB v10.7.5 table/block_based/block_based_table_reader.cc
+	// (BlockBasedTable::Open, index_key_includes_seq and index_value_is_full)
+	if r.properties != nil {
+		r.indexUsesValueDeltaEncoding = r.properties.IndexValueIsDeltaEncoded != 0
+		r.indexKeyIsUserKey = r.properties.IndexKeyIsUserKey != 0
+	} else if r.footer.FormatVersion >= 4 {
 		r.indexUsesValueDeltaEncoding = r.detectValueDeltaEncoding()
 	}
 
 	return nil
 }
 
+// useIndexBlockIterator reports whether the index block needs an
+// IndexBlockIterator rather than a standard block iterator.
+func (r *Reader) useIndexBlockIterator() bool {
+	return r.indexUsesValueDeltaEncoding || r.indexKeyIsUserKey
+}
+
+// newIndexBlockIterator returns an IndexBlockIterator over the index block
+// for the table's index layout.
+func (r *Reader) newIndexBlockIterator() *IndexBlockIterator {
+	return newIndexBlockIterator(r.indexBlock.Data(), r.indexBlock.DataEnd(),
+		r.indexUsesValueDeltaEncoding, r.indexKeyIsUserKey, uint64(r.footer.BlockTrailerSize))
+}
+
 // detectValueDeltaEncoding tries to determine if the index block uses value_delta_encoding.
 // It does this by trying to parse the first entry and checking if the resulting
 // BlockHandle makes sense (offset < file_size, reasonable size).
@@ -570,6 +641,9 @@ func (r *Reader) readFilter() error {
 	if _, err := r.file.ReadAt(buf, int64(r.filterHandle.Offset)); err != nil {
 		return err
 	}
+	perf.AddFileRead(totalSize)
+	perf.AddBlockRead(totalSize)
+	perf.AddFilterBlockRead()
 
 	// Filter data is just the block without trailer
 	filterData := buf[:r.filterHandle.Size]
@@ -589,13 +663,44 @@ func (r *Reader) readFilter() error {
 // - No filter is present
 // - The filter indicates the key might be present
 // Returns false (definitely not present) if the filter says the key is not present.
+// Only use it when WholeKeyFiltering reports true.
 func (r *Reader) KeyMayMatch(key []byte) bool {
 	if r.filterReader == nil {
 		return true // No filter, assume may match
 	}
+	// Point lookups are counted by the caller's statistics; the
+	// BloomSst perf counters are for iterator seeks only
 	return r.filterReader.MayContain(key)
 }
 
+// PrefixMayMatch reports whether keys with the given prefix may be in this
+// SST file. It returns false only if the file's filter holds the prefixes of
+// prefixExtractorName, the extractor recorded when the file was built, and
+// rules the prefix out.
+//
+// Reference: RocksDB v10.7.5 table/block_based/block_based_table_reader.cc
+// (BlockBasedTable::PrefixRangeMayMatch)
+func (r *Reader) PrefixMayMatch(prefix []byte, prefixExtractorName string) bool {
+	if r.filterReader == nil || r.properties == nil ||
+		r.properties.PrefixExtractorName == "" || r.properties.PrefixExtractorName != prefixExtractorName {
+		return true
+	}
+	mayMatch := r.filterReader.MayContain(prefix)
+	perf.AddBloomSst(mayMatch)
+	return mayMatch
+}
+
+// WholeKeyFiltering reports whether the table's filter holds all of its
+// user keys, as recorded in its properties. The filters of tables written
+// without the property, e.g. with only prefixes, cannot rule keys out.
+//
+// Reference: RocksDB v10.7.5 table/block_based/block_based_table_reader.cc
+// (BlockBasedTable::Open, whole_key_filtering)
+func (r *Reader) WholeKeyFiltering() bool {
+	return r.filterReader != nil && r.properties != nil &&
+		r.properties.UserCollectedProperties[PropWholeKeyFiltering] == "1"
+}
+
 // HasFilter returns true if this table has a Bloom filter.
 func (r *Reader) HasFilter() bool {
 	return r.filterReader != nil
@@ -606,8 +711,21 @@ func (r *Reader) HasFilter() bool {
 // 256 MiB is well above typical block sizes (4 KiB to 4 MiB).
 const maxBlockSize = 256 * 1024 * 1024
 
-// readBlock reads and optionally verifies a block from the file.
+// readBlock reads a block from the file, verifying its checksum if the
+// reader was opened with VerifyChecksums.
 func (r *Reader) readBlock(handle block.Handle) (*block.Block, error) {
+	return r.readBlockVerify(handle, r.options.VerifyChecksums)
+}
+
+// readBlockVerify reads a block from the file, verifying its checksum if
+// verifyChecksums is true.
+func (r *Reader) readBlockVerify(handle block.Handle, verifyChecksums bool) (*block.Block, error) {
+	return r.readBlockPrefetch(handle, verifyChecksums, nil)
+}
+
+// readBlockPrefetch reads a block like readBlockVerify, serving the read from
+// pf if it is not nil.
+func (r *Reader) readBlockPrefetch(handle block.Handle, verifyChecksums bool, pf *prefetchBuffer) (*block.Block, error) {
 	// Block format:
 	// [block data] [compression type: 1 byte] [checksum: 4 bytes]
 	// Total size = handle.Size + BlockTrailerSize
@@ -635,17 +753,27 @@ func (r *Reader) readBlock(handle block.Handle) (*block.Block, error) {
 			handle.Offset, totalSize, r.size, ErrInvalidSST)
 	}
 
-	buf := make([]byte, totalSize)
-	n, err := r.file.ReadAt(buf, int64(handle.Offset))
-	if err != nil {
-		return nil, err
-	}
-	if n < totalSize {
-		return nil, ErrInvalidSST
+	var buf []byte
+	if pf != nil {
+		var err error
+		if buf, err = pf.read(handle.Offset, totalSize); err != nil {
+			return nil, err
+		}
+	} else {
+		buf = make([]byte, totalSize)
+		n, err := r.file.ReadAt(buf, int64(handle.Offset))
+		if err != nil {
+			return nil, err
+		}
+		if n < totalSize {
+			return nil, ErrInvalidSST
+		}
+		perf.AddFileRead(totalSize)
 	}
+	perf.AddBlockRead(totalSize)
 
 	// Verify checksum if requested
-	if r.options.VerifyChecksums && trailerSize > 0 {
+	if verifyChecksums && trailerSize > 0 {
 		blockData := buf[:len(buf)-trailerSize]
 		compressionType := buf[len(buf)-trailerSize]
 		storedChecksum := encoding.DecodeFixed32(buf[len(buf)-4:])
@@ -711,6 +839,69 @@ func (r *Reader) readBlock(handle block.Handle) (*block.Block, error) {
 	return block.NewBlock(blockData)
 }
 
+// readDataBlock reads a data block like readBlockPrefetch, serving it from
+// the block cache if the reader has one. A block read from the file is added
+// to the cache if fillCache is true.
+// Reference: RocksDB v10.7.5 table/block_based/block_based_table_reader.cc (MaybeReadBlockAndLoadToCache)
+func (r *Reader) readDataBlock(handle block.Handle, verifyChecksums bool, pf *prefetchBuffer, fillCache bool) (*block.Block, error) {
+	blockCache := r.options.BlockCache
+	if blockCache == nil {
+		return r.readBlockPrefetch(handle, verifyChecksums, pf)
+	}
+
+	key := cache.CacheKey{FileNumber: r.options.FileNumber, BlockOffset: handle.Offset}
+	if h := blockCache.Lookup(key); h != nil {
+		// Cached blocks are never modified, so the data outlives the handle
+		data := h.Value()
+		blockCache.Release(h)
+		if r.options.VerifyBlockCacheEntries {
+			var ok bool
+			if data, ok = unprotectCacheEntry(data); !ok {
+				return nil, fmt.Errorf("%w: block cache entry of file %d at offset %d",
+					ErrChecksumMismatch, r.options.FileNumber, handle.Offset)
+			}
+		}
+		return block.NewBlock(data)
+	}
+
+	dataBlock, err := r.readBlockPrefetch(handle, verifyChecksums, pf)
+	if err != nil {
+		return nil, err
+	}
+	if fillCache {
+		data := dataBlock.Data()
+		switch {
+		case r.options.VerifyBlockCacheEntries:
+			data = protectCacheEntry(data)
+		case pf != nil:
+			// The block may point into the readahead buffer; caching it
+			// as is would pin the whole buffer while charging the cache
+			// only for the block
+			data = append(make([]byte, 0, len(data)), data...)
+		}
+		blockCache.Release(blockCache.Insert(key, data, uint64(len(data))))
+	}
+	return dataBlock, nil
+}
+
+// protectCacheEntry returns a copy of a block's data followed by its
+// CRC32C, to be stored in the block cache.
+func protectCacheEntry(data []byte) []byte {
+	entry := make([]byte, len(data), len(data)+4)
+	copy(entry, data)
+	return encoding.AppendFixed32(entry, checksum.Value(data))
+}
+
+// unprotectCacheEntry returns the block data of a block cache entry written
+// by protectCacheEntry, and whether its checksum matches.
+func unprotectCacheEntry(entry []byte) ([]byte, bool) {
+	if len(entry) < 4 {
+		return nil, false
+	}
+	data := entry[:len(entry)-4]
+	return data, encoding.DecodeFixed32(entry[len(data):]) == checksum.Value(data)
+}
+
 // checksumModifierForContext computes the context checksum modifier.
 // This matches RocksDB's ChecksumModifierForContext function.
 func checksumModifierForContext(baseContextChecksum uint32, offset uint64) uint32 {
@@ -722,19 +913,78 @@ func checksumModifierForContext(baseContextChecksum uint32, offset uint64) uint3
 	return baseContextChecksum ^ (lower32 + upper32)
 }
 
+// RecordRead counts a point lookup that read the table's data, for finding
+// the most read tables.
+func (r *Reader) RecordRead() {
+	r.numReads.Add(1)
+}
+
+// NumReads returns the number of point lookups recorded by RecordRead since
+// the reader was opened.
+func (r *Reader) NumReads() uint64 {
+	return r.numReads.Load()
+}
+
 // NewIterator returns an iterator over the table contents.
 // The iterator is initially invalid; call SeekToFirst or Seek before use.
 func (r *Reader) NewIterator() *TableIterator {
-	ti := &TableIterator{
-		reader:    r,
-		dataBlock: nil,
-		dataIter:  nil,
-	}
+	return r.NewIteratorWithOptions(IteratorOptions{
+		VerifyChecksums: r.options.VerifyChecksums,
+		FillCache:       true,
+	})
+}
+
+// NewIteratorWithVerify returns an iterator over the table contents that
+// verifies data block checksums if verifyChecksums is true, regardless of the
+// reader's VerifyChecksums option.
+// Reference: RocksDB v10.7.5 include/rocksdb/options.h (ReadOptions::verify_checksums)
+func (r *Reader) NewIteratorWithVerify(verifyChecksums bool) *TableIterator {
+	return r.NewIteratorWithOptions(IteratorOptions{VerifyChecksums: verifyChecksums})
+}
 
-	// Use IndexBlockIterator if the index block uses value_delta_encoding
-	// (C++ RocksDB format_version >= 4). Otherwise use standard block iterator.
-	if r.indexUsesValueDeltaEncoding {
-		ti.indexIter = NewIndexBlockIterator(r.indexBlock.Data(), r.indexBlock.DataEnd())
+// IteratorOptions controls the behavior of a table iterator.
+type IteratorOptions struct {
+	// VerifyChecksums verifies data block checksums as they are read.
+	VerifyChecksums bool
+
+	// ReadaheadSize, if non-zero, makes forward iteration read this many bytes
+	// of data blocks ahead at a time instead of one block per read. The window
+	// grows while the scan stays sequential.
+	ReadaheadSize uint64
+
+	// AutoReadahead, used when ReadaheadSize is zero, starts reading ahead
+	// once the iterator has read data blocks sequentially, growing the window
+	// from 8KB to 256KB, and stops again on a non-sequential read.
+	// Reference: RocksDB v10.7.5 table/block_based/block_prefetcher.cc
+	AutoReadahead bool
+
+	// FillCache adds the data blocks read from the file to the reader's
+	// block cache. Blocks already cached are used either way.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (ReadOptions::fill_cache)
+	FillCache bool
+}
+
+// NewIteratorWithOptions returns an iterator over the table contents
+// configured by opts.
+// Reference: RocksDB v10.7.5 include/rocksdb/options.h (ReadOptions::readahead_size)
+func (r *Reader) NewIteratorWithOptions(opts IteratorOptions) *TableIterator {
+	ti := &TableIterator{
+		reader:          r,
+		dataBlock:       nil,
+		dataIter:        nil,
+		verifyChecksums: opts.VerifyChecksums,
+		fillCache:       opts.FillCache,
+	}
+	if opts.ReadaheadSize > 0 {
+		ti.prefetch = newPrefetchBuffer(r.file, r.dataSectionEnd(), opts.ReadaheadSize)
+	} else if opts.AutoReadahead {
+		ti.prefetch = newAutoPrefetchBuffer(r.file, r.dataSectionEnd())
+	}
+
+	// Use IndexBlockIterator for the index layouts of C++ RocksDB
+	// (format_version >= 3). Otherwise use standard block iterator.
+	if r.useIndexBlockIterator() {
+		ti.indexIter = r.newIndexBlockIterator()
 		ti.useIndexIter = true
 	} else {
 		// Standard block format (Go-generated SSTs or older format versions)
@@ -745,11 +995,24 @@ func (r *Reader) NewIterator() *TableIterator {
 	return ti
 }
 
+// dataSectionEnd returns the file offset at which the data blocks end.
+func (r *Reader) dataSectionEnd() uint64 {
+	if !r.footer.MetaindexHandle.IsNull() {
+		return r.footer.MetaindexHandle.Offset
+	}
+	return uint64(r.size)
+}
+
 // Close releases resources associated with the reader.
 func (r *Reader) Close() error {
 	return r.file.Close()
 }
 
+// Options returns the options the reader was opened with.
+func (r *Reader) Options() ReaderOptions {
+	return r.options
+}
+
 // Footer returns the parsed footer.
 func (r *Reader) Footer() *block.Footer {
 	return r.footer
@@ -781,6 +1044,87 @@ func (r *Reader) Properties() (*TableProperties, error) {
 	return props, nil
 }
 
+// ApproximateOffsetOf returns the approximate file offset at which the data
+// for the given internal key begins. Keys past the last data block map to the
+// start of the metaindex block, i.e. the end of the data section.
+//
+// Reference: RocksDB v10.7.5 table/block_based/block_based_table_reader.cc
+// (BlockBasedTable::ApproximateOffsetOf)
+func (r *Reader) ApproximateOffsetOf(key []byte) uint64 {
+	var handleBytes []byte
+	if r.useIndexBlockIterator() {
+		it := r.newIndexBlockIterator()
+		it.Seek(key)
+		if it.Valid() {
+			handleBytes = it.Value()
+		}
+	} else {
+		it := r.indexBlock.NewIterator()
+		it.Seek(key)
+		if it.Valid() {
+			handleBytes = it.Value()
+		}
+	}
+
+	if handleBytes != nil {
+		if handle, _, err := block.DecodeHandle(handleBytes); err == nil {
+			return handle.Offset
+		}
+	}
+
+	// Key is past the last data block (or the index entry is unreadable).
+	if off := r.footer.MetaindexHandle.Offset; off > 0 {
+		return off
+	}
+	return uint64(r.size)
+}
+
+// IndexEntry describes one data block as recorded in the index block.
+type IndexEntry struct {
+	// Key is the block's separator: an internal key >= every key in the
+	// block and < every key in the next block.
+	Key []byte
+
+	// Handle locates the block in the file.
+	Handle block.Handle
+}
+
+// IndexEntries returns one entry per data block, in key order.
+func (r *Reader) IndexEntries() ([]IndexEntry, error) {
+	type indexIterator interface {
+		SeekToFirst()
+		Valid() bool
+		Next()
+		Key() []byte
+		Value() []byte
+	}
+	var it indexIterator
+	if r.useIndexBlockIterator() {
+		it = r.newIndexBlockIterator()
+	} else {
+		it = r.indexBlock.NewIterator()
+	}
+
+	var entries []IndexEntry
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		handle, _, err := block.DecodeHandle(it.Value())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode index entry: %w", err)
+		}
+		entries = append(entries, IndexEntry{
+			Key:    append([]byte(nil), it.Key()...),
+			Handle: handle,
+		})
+	}
+	return entries, nil
+}
+
+// MetaBlockNames returns the names of the meta blocks listed in the
+// metaindex block, such as "rocksdb.properties" and "rocksdb.range_del".
+func (r *Reader) MetaBlockNames() []string {
+	return append([]string(nil), r.metaBlockNames...)
+}
+
 // HasRangeTombstones returns true if the SST file contains range tombstones.
 func (r *Reader) HasRangeTombstones() bool {
 	return !r.rangeDelHandle.IsNull()
@@ -881,6 +1225,11 @@ type TableIterator struct {
 	dataBlock      *block.Block        // Current data block
 	dataIter       *block.Iterator     // Iterator over current data block
 	err            error
+
+	verifyChecksums bool // Verify data block checksums as they are read
+	fillCache       bool // Add data blocks read from the file to the block cache
+
+	prefetch *prefetchBuffer // Readahead for data blocks (nil if disabled)
 }
 
 // Valid returns true if the iterator is positioned at a valid entry.
@@ -1036,7 +1385,7 @@ func (it *TableIterator) loadDataBlock() {
 	}
 
 	// Read the data block
-	dataBlock, err := it.reader.readBlock(handle)
+	dataBlock, err := it.reader.readDataBlock(handle, it.verifyChecksums, it.prefetch, it.fillCache)
 	if err != nil {
 		it.err = err
 		it.dataBlock = nil
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/aalhour/rockyardkv/internal/block"
+	"github.com/aalhour/rockyardkv/internal/cache"
 )
 
 // BytesFile wraps a byte slice for ReadableFile interface.
@@ -1116,3 +1118,74 @@ func TestUnsupportedFeatureErrors(t *testing.T) {
 			ErrUnsupportedPartitionedIndex.Error(), expected)
 	}
 }
+
+func TestTableIteratorBlockCache(t *testing.T) {
+	data := buildReadaheadTestTable(t, 200)
+	blockCache := cache.NewLRUCache(1 << 20)
+	file := &countingFile{data: data}
+	reader, err := Open(file, ReaderOptions{VerifyChecksums: true, BlockCache: blockCache, FileNumber: 7})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	scan := func(opts IteratorOptions) []string {
+		t.Helper()
+		file.reads = nil
+		var entries []string
+		iter := reader.NewIteratorWithOptions(opts)
+		for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+			entries = append(entries, string(iter.Key())+"="+string(iter.Value()))
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatalf("iteration failed: %v", err)
+		}
+		return entries
+	}
+
+	// Without FillCache, blocks are read from the file and not cached
+	want := scan(IteratorOptions{VerifyChecksums: true})
+	if blockCache.GetOccupancyCount() != 0 {
+		t.Fatalf("cache holds %d blocks, want none", blockCache.GetOccupancyCount())
+	}
+
+	// With FillCache, every data block read is cached
+	scan(IteratorOptions{VerifyChecksums: true, FillCache: true})
+	numBlocks := blockCache.GetOccupancyCount()
+	if numBlocks < 2 || uint64(len(file.reads)) != numBlocks {
+		t.Fatalf("cached %d blocks after %d reads, want one block per read", numBlocks, len(file.reads))
+	}
+
+	// Later scans are served from the cache
+	hits := blockCache.GetHitCount()
+	got := scan(IteratorOptions{VerifyChecksums: true})
+	if len(file.reads) != 0 {
+		t.Errorf("scan read the file %d times, want none", len(file.reads))
+	}
+	if blockCache.GetHitCount()-hits != numBlocks {
+		t.Errorf("scan hit the cache %d times, want %d", blockCache.GetHitCount()-hits, numBlocks)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("cached scan returned %d entries, want %d", len(got), len(want))
+	}
+
+	// Blocks read through readahead are cached as copies, not as slices of
+	// the readahead buffer
+	entries, err := reader.IndexEntries()
+	if err != nil {
+		t.Fatalf("IndexEntries failed: %v", err)
+	}
+	for _, e := range entries {
+		blockCache.Erase(cache.CacheKey{FileNumber: 7, BlockOffset: e.Handle.Offset})
+	}
+	scan(IteratorOptions{VerifyChecksums: true, FillCache: true, ReadaheadSize: 4096})
+	for _, e := range entries {
+		h := blockCache.Lookup(cache.CacheKey{FileNumber: 7, BlockOffset: e.Handle.Offset})
+		if h == nil {
+			t.Fatalf("block at offset %d not cached", e.Handle.Offset)
+		}
+		if data := h.Value(); cap(data) != len(data) {
+			t.Errorf("cached block at offset %d has cap %d, want its own %d bytes", e.Handle.Offset, cap(data), len(data))
+		}
+		blockCache.Release(h)
+	}
+}
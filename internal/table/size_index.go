@@ -0,0 +1,79 @@
+package table
+
+// size_index.go implements SizeIndex, a decoded copy of an SST file's index
+// block used to approximate the size of key ranges.
+//
+// Reference: RocksDB v10.7.5
+//   - table/block_based/block_based_table_reader.cc
+//     (BlockBasedTable::ApproximateOffsetOf, BlockBasedTable::ApproximateSize)
+
+import (
+	"sort"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+)
+
+// SizeIndex maps the data blocks of an SST file to their file offsets. It
+// holds no reference to the file, so it stays usable after the reader it was
+// built from is closed.
+type SizeIndex struct {
+	// keys are the blocks' separator internal keys, in ascending order
+	keys [][]byte
+
+	// offsets are the blocks' starting offsets
+	offsets []uint64
+
+	// dataEnd is the offset at which the data section ends
+	dataEnd uint64
+
+	// cmp orders the file's internal keys
+	cmp *dbformat.InternalKeyComparator
+}
+
+// NewSizeIndex decodes the index block of r, whose keys are ordered by cmp.
+// A nil cmp orders user keys bytewise.
+func NewSizeIndex(r *Reader, cmp *dbformat.InternalKeyComparator) (*SizeIndex, error) {
+	entries, err := r.IndexEntries()
+	if err != nil {
+		return nil, err
+	}
+	s := &SizeIndex{
+		keys:    make([][]byte, len(entries)),
+		offsets: make([]uint64, len(entries)),
+		dataEnd: r.footer.MetaindexHandle.Offset,
+		cmp:     cmp,
+	}
+	if s.cmp == nil {
+		s.cmp = dbformat.DefaultInternalKeyComparator
+	}
+	if s.dataEnd == 0 {
+		s.dataEnd = uint64(r.size)
+	}
+	for i, e := range entries {
+		s.keys[i] = e.Key
+		s.offsets[i] = e.Handle.Offset
+	}
+	return s, nil
+}
+
+// OffsetOf returns the approximate file offset at which the data for the
+// given internal key begins, like Reader.ApproximateOffsetOf.
+func (s *SizeIndex) OffsetOf(key []byte) uint64 {
+	i := sort.Search(len(s.keys), func(i int) bool {
+		return s.cmp.Compare(s.keys[i], key) >= 0
+	})
+	if i < len(s.offsets) {
+		return s.offsets[i]
+	}
+	return s.dataEnd
+}
+
+// ApproximateSize returns the approximate number of bytes of data between
+// the internal keys start and limit.
+func (s *SizeIndex) ApproximateSize(start, limit []byte) uint64 {
+	startOff, limitOff := s.OffsetOf(start), s.OffsetOf(limit)
+	if limitOff > startOff {
+		return limitOff - startOff
+	}
+	return 0
+}
@@ -11,8 +11,10 @@ package txn
 
 import (
 	"errors"
+	"hash/maphash"
 	"maps"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,6 +28,10 @@ var (
 
 	// ErrLockNotHeld is returned when trying to unlock a key not held by the transaction.
 	ErrLockNotHeld = errors.New("db: lock not held by transaction")
+
+	// ErrLockLimit is returned when locking a key would exceed the maximum
+	// number of locked keys.
+	ErrLockLimit = errors.New("db: lock limit reached")
 )
 
 // LockType represents the type of lock.
@@ -108,31 +114,64 @@ func (li *LockInfo) NumHolders() int {
 
 // LockManager manages locks for pessimistic transactions.
 // It supports shared and exclusive locks with deadlock detection.
+//
+// The locks are sharded into stripes by a hash of the key, each with its own
+// mutex, so transactions locking different keys rarely contend. The wait-for
+// graph used for deadlock detection spans all stripes and has a mutex of its
+// own, taken after a stripe's and only by transactions that wait or release
+// locks others wait for.
+//
+// Reference: RocksDB v10.7.5 utilities/transactions/lock/point/point_lock_manager.h (LockMap, LockMapStripe)
 type LockManager struct {
-	mu sync.Mutex
+	stripes []*lockStripe
+	seed    maphash.Seed
 
-	// locks maps key -> lock info
-	locks map[string]*LockInfo
+	// waitMu protects waitFor
+	waitMu sync.Mutex
 
 	// waitFor maps txnID -> set of txnIDs it's waiting for (for deadlock detection)
 	waitFor map[uint64]map[uint64]struct{}
 
-	// txnLocks maps txnID -> set of keys it holds locks on (for bulk unlock)
-	txnLocks map[uint64]map[string]struct{}
+	// numLocks is the number of keys in the stripes' lock maps
+	numLocks atomic.Int64
 
 	// Configuration
 	defaultTimeout time.Duration
+	maxNumLocks    int64
+}
+
+// lockStripe holds the locks of the keys that hash to it.
+type lockStripe struct {
+	mu sync.Mutex
+
+	// locks maps key -> lock info
+	locks map[string]*LockInfo
+
+	// txnLocks maps txnID -> set of keys of the stripe it holds locks on
+	// (for bulk unlock)
+	txnLocks map[uint64]map[string]struct{}
 }
 
 // LockManagerOptions configures the lock manager.
 type LockManagerOptions struct {
 	DefaultTimeout time.Duration
+
+	// NumStripes is the number of stripes the locks are sharded into
+	// (0 = 1, a single mutex for all keys). A TransactionDB sets it from
+	// TransactionDBOptions.NumStripes.
+	NumStripes int
+
+	// MaxNumLocks is the maximum number of keys locked at the same time;
+	// locking another key fails with ErrLockLimit (<= 0 = unlimited).
+	MaxNumLocks int64
 }
 
 // DefaultLockManagerOptions returns default options.
 func DefaultLockManagerOptions() LockManagerOptions {
 	return LockManagerOptions{
 		DefaultTimeout: 5 * time.Second,
+		NumStripes:     16,
+		MaxNumLocks:    -1,
 	}
 }
 
@@ -141,12 +180,54 @@ func NewLockManager(opts LockManagerOptions) *LockManager {
 	if opts.DefaultTimeout == 0 {
 		opts.DefaultTimeout = 5 * time.Second
 	}
+	stripes := make([]*lockStripe, max(1, opts.NumStripes))
+	for i := range stripes {
+		stripes[i] = &lockStripe{
+			locks:    make(map[string]*LockInfo),
+			txnLocks: make(map[uint64]map[string]struct{}),
+		}
+	}
 	return &LockManager{
-		locks:          make(map[string]*LockInfo),
+		stripes:        stripes,
+		seed:           maphash.MakeSeed(),
 		waitFor:        make(map[uint64]map[uint64]struct{}),
-		txnLocks:       make(map[uint64]map[string]struct{}),
 		defaultTimeout: opts.DefaultTimeout,
+		maxNumLocks:    opts.MaxNumLocks,
+	}
+}
+
+// addLockInfo adds the lock info of a key that has none to its stripe
+// (caller must hold stripe.mu). It returns nil if the key would exceed
+// maxNumLocks.
+// Reference: RocksDB v10.7.5 utilities/transactions/lock/point/point_lock_manager.cc (AcquireLocked, kLockLimit)
+func (lm *LockManager) addLockInfo(stripe *lockStripe, keyStr string) *LockInfo {
+	for {
+		n := lm.numLocks.Load()
+		if lm.maxNumLocks > 0 && n >= lm.maxNumLocks {
+			return nil
+		}
+		if lm.numLocks.CompareAndSwap(n, n+1) {
+			break
+		}
+	}
+	lockInfo := NewLockInfo()
+	stripe.locks[keyStr] = lockInfo
+	return lockInfo
+}
+
+// removeLockInfo removes the lock info of a key that is neither held nor
+// waited for from its stripe (caller must hold stripe.mu).
+func (lm *LockManager) removeLockInfo(stripe *lockStripe, keyStr string) {
+	delete(stripe.locks, keyStr)
+	lm.numLocks.Add(-1)
+}
+
+// stripe returns the stripe of a key.
+func (lm *LockManager) stripe(keyStr string) *lockStripe {
+	if len(lm.stripes) == 1 {
+		return lm.stripes[0]
 	}
+	return lm.stripes[maphash.String(lm.seed, keyStr)%uint64(len(lm.stripes))]
 }
 
 // Lock attempts to acquire a lock on the given key for the transaction.
@@ -159,21 +240,24 @@ func (lm *LockManager) Lock(txnID uint64, key []byte, lockType LockType, timeout
 	}
 
 	keyStr := string(key)
+	stripe := lm.stripe(keyStr)
 
-	lm.mu.Lock()
+	stripe.mu.Lock()
 
 	// Get or create lock info for this key
-	lockInfo, exists := lm.locks[keyStr]
+	lockInfo, exists := stripe.locks[keyStr]
 	if !exists {
-		lockInfo = NewLockInfo()
-		lm.locks[keyStr] = lockInfo
+		if lockInfo = lm.addLockInfo(stripe, keyStr); lockInfo == nil {
+			stripe.mu.Unlock()
+			return ErrLockLimit
+		}
 	}
 
 	// Check if we already hold a compatible lock
 	if currentType, held := lockInfo.Holders[txnID]; held {
 		if currentType == LockTypeExclusive || lockType == LockTypeShared {
 			// We already hold an exclusive lock, or we're requesting shared and already have something
-			lm.mu.Unlock()
+			stripe.mu.Unlock()
 			return nil
 		}
 		// We hold a shared lock but want exclusive - need to upgrade
@@ -183,20 +267,25 @@ func (lm *LockManager) Lock(txnID uint64, key []byte, lockType LockType, timeout
 
 	// Check if we can grant the lock immediately
 	if lm.canGrantLock(lockInfo, txnID, lockType) {
-		lm.grantLock(lockInfo, txnID, keyStr, lockType)
-		lm.mu.Unlock()
+		stripe.grantLock(lockInfo, txnID, keyStr, lockType)
+		stripe.mu.Unlock()
 		return nil
 	}
 
-	// Need to wait - check for deadlock first
+	// Need to wait - check for deadlock first. The check and the new edges
+	// are one step under waitMu, so of two transactions closing a cycle
+	// through different stripes the second one sees the first one's edges.
 	waitingFor := lm.collectBlockingTxns(lockInfo, txnID)
+	lm.waitMu.Lock()
 	if lm.wouldCauseDeadlock(txnID, waitingFor) {
-		lm.mu.Unlock()
+		lm.waitMu.Unlock()
+		stripe.mu.Unlock()
 		return ErrDeadlock
 	}
 
 	// Add to wait-for graph
 	lm.addToWaitFor(txnID, waitingFor)
+	lm.waitMu.Unlock()
 
 	// Create wait request
 	req := &LockRequest{
@@ -207,7 +296,7 @@ func (lm *LockManager) Lock(txnID uint64, key []byte, lockType LockType, timeout
 	}
 	lockInfo.WaitQueue = append(lockInfo.WaitQueue, req)
 
-	lm.mu.Unlock()
+	stripe.mu.Unlock()
 
 	// Wait for lock or timeout
 	timer := time.NewTimer(timeout)
@@ -218,8 +307,11 @@ func (lm *LockManager) Lock(txnID uint64, key []byte, lockType LockType, timeout
 		// Lock granted
 		return nil
 	case <-timer.C:
-		// Timeout - remove from wait queue
-		lm.removeFromWaitQueue(keyStr, txnID)
+		// Timeout - remove from wait queue, unless the lock was granted
+		// in the meantime
+		if lm.removeFromWaitQueue(keyStr, req) {
+			return nil
+		}
 		return ErrLockTimeout
 	}
 }
@@ -228,14 +320,16 @@ func (lm *LockManager) Lock(txnID uint64, key []byte, lockType LockType, timeout
 // Returns true if the lock was acquired, false otherwise.
 func (lm *LockManager) TryLock(txnID uint64, key []byte, lockType LockType) bool {
 	keyStr := string(key)
+	stripe := lm.stripe(keyStr)
 
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
 
-	lockInfo, exists := lm.locks[keyStr]
+	lockInfo, exists := stripe.locks[keyStr]
 	if !exists {
-		lockInfo = NewLockInfo()
-		lm.locks[keyStr] = lockInfo
+		if lockInfo = lm.addLockInfo(stripe, keyStr); lockInfo == nil {
+			return false
+		}
 	}
 
 	// Check if we already hold a compatible lock
@@ -246,7 +340,7 @@ func (lm *LockManager) TryLock(txnID uint64, key []byte, lockType LockType) bool
 	}
 
 	if lm.canGrantLock(lockInfo, txnID, lockType) {
-		lm.grantLock(lockInfo, txnID, keyStr, lockType)
+		stripe.grantLock(lockInfo, txnID, keyStr, lockType)
 		return true
 	}
 
@@ -256,16 +350,21 @@ func (lm *LockManager) TryLock(txnID uint64, key []byte, lockType LockType) bool
 // Unlock releases the lock held by the transaction on the given key.
 func (lm *LockManager) Unlock(txnID uint64, key []byte) error {
 	keyStr := string(key)
+	stripe := lm.stripe(keyStr)
 
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
 
-	return lm.unlockInternal(txnID, keyStr)
+	if err := lm.unlockInternal(stripe, txnID, keyStr); err != nil {
+		return err
+	}
+	lm.removeWaitEdges(txnID)
+	return nil
 }
 
-// unlockInternal releases a lock (caller must hold lm.mu).
-func (lm *LockManager) unlockInternal(txnID uint64, keyStr string) error {
-	lockInfo, exists := lm.locks[keyStr]
+// unlockInternal releases a lock (caller must hold stripe.mu).
+func (lm *LockManager) unlockInternal(stripe *lockStripe, txnID uint64, keyStr string) error {
+	lockInfo, exists := stripe.locks[keyStr]
 	if !exists {
 		return ErrLockNotHeld
 	}
@@ -278,25 +377,19 @@ func (lm *LockManager) unlockInternal(txnID uint64, keyStr string) error {
 	delete(lockInfo.Holders, txnID)
 
 	// Remove from txn's lock set
-	if txnKeys, ok := lm.txnLocks[txnID]; ok {
+	if txnKeys, ok := stripe.txnLocks[txnID]; ok {
 		delete(txnKeys, keyStr)
 		if len(txnKeys) == 0 {
-			delete(lm.txnLocks, txnID)
+			delete(stripe.txnLocks, txnID)
 		}
 	}
 
-	// Remove from wait-for graph
-	delete(lm.waitFor, txnID)
-	for _, waitingFor := range lm.waitFor {
-		delete(waitingFor, txnID)
-	}
-
 	// Try to grant locks to waiting requests
-	lm.processWaitQueue(keyStr, lockInfo)
+	lm.processWaitQueue(stripe, keyStr, lockInfo)
 
 	// Clean up empty lock info
 	if len(lockInfo.Holders) == 0 && len(lockInfo.WaitQueue) == 0 {
-		delete(lm.locks, keyStr)
+		lm.removeLockInfo(stripe, keyStr)
 	}
 
 	return nil
@@ -304,27 +397,38 @@ func (lm *LockManager) unlockInternal(txnID uint64, keyStr string) error {
 
 // UnlockAll releases all locks held by the transaction.
 func (lm *LockManager) UnlockAll(txnID uint64) {
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
+	for _, stripe := range lm.stripes {
+		stripe.mu.Lock()
+		// Get all keys of the stripe held by this transaction, copied since
+		// unlockInternal modifies txnLocks
+		txnKeys := stripe.txnLocks[txnID]
+		keys := make([]string, 0, len(txnKeys))
+		for key := range txnKeys {
+			keys = append(keys, key)
+		}
 
-	// Get all keys held by this transaction
-	txnKeys, ok := lm.txnLocks[txnID]
-	if !ok {
-		return
+		// Unlock each key
+		for _, key := range keys {
+			_ = lm.unlockInternal(stripe, txnID, key)
+		}
+		stripe.mu.Unlock()
 	}
 
-	// Make a copy since unlockInternal modifies txnLocks
-	keys := make([]string, 0, len(txnKeys))
-	for key := range txnKeys {
-		keys = append(keys, key)
-	}
+	// Clean up wait-for graph
+	lm.waitMu.Lock()
+	lm.removeWaitEdgesLocked(txnID)
+	lm.waitMu.Unlock()
+}
 
-	// Unlock each key
-	for _, key := range keys {
-		_ = lm.unlockInternal(txnID, key)
-	}
+// removeWaitEdges removes the wait-for edges from and to the transaction.
+func (lm *LockManager) removeWaitEdges(txnID uint64) {
+	lm.waitMu.Lock()
+	defer lm.waitMu.Unlock()
+	lm.removeWaitEdgesLocked(txnID)
+}
 
-	// Clean up wait-for graph
+// removeWaitEdgesLocked is removeWaitEdges with lm.waitMu held.
+func (lm *LockManager) removeWaitEdgesLocked(txnID uint64) {
 	delete(lm.waitFor, txnID)
 	for _, waitingFor := range lm.waitFor {
 		delete(waitingFor, txnID)
@@ -359,15 +463,15 @@ func (lm *LockManager) canGrantLock(lockInfo *LockInfo, txnID uint64, lockType L
 	return !lockInfo.HasExclusiveHolder()
 }
 
-// grantLock grants the lock to the transaction.
-func (lm *LockManager) grantLock(lockInfo *LockInfo, txnID uint64, keyStr string, lockType LockType) {
+// grantLock grants the lock to the transaction (caller must hold s.mu).
+func (s *lockStripe) grantLock(lockInfo *LockInfo, txnID uint64, keyStr string, lockType LockType) {
 	lockInfo.Holders[txnID] = lockType
 
 	// Track in txn's lock set
-	if _, ok := lm.txnLocks[txnID]; !ok {
-		lm.txnLocks[txnID] = make(map[string]struct{})
+	if _, ok := s.txnLocks[txnID]; !ok {
+		s.txnLocks[txnID] = make(map[string]struct{})
 	}
-	lm.txnLocks[txnID][keyStr] = struct{}{}
+	s.txnLocks[txnID][keyStr] = struct{}{}
 }
 
 // collectBlockingTxns returns the set of transactions blocking the given transaction.
@@ -381,7 +485,7 @@ func (lm *LockManager) collectBlockingTxns(lockInfo *LockInfo, txnID uint64) map
 	return blocking
 }
 
-// addToWaitFor adds wait-for edges in the graph.
+// addToWaitFor adds wait-for edges in the graph (caller must hold lm.waitMu).
 func (lm *LockManager) addToWaitFor(txnID uint64, waitingFor map[uint64]struct{}) {
 	if _, ok := lm.waitFor[txnID]; !ok {
 		lm.waitFor[txnID] = make(map[uint64]struct{})
@@ -391,7 +495,8 @@ func (lm *LockManager) addToWaitFor(txnID uint64, waitingFor map[uint64]struct{}
 	}
 }
 
-// wouldCauseDeadlock checks if adding wait edges would cause a cycle.
+// wouldCauseDeadlock checks if adding wait edges would cause a cycle
+// (caller must hold lm.waitMu).
 func (lm *LockManager) wouldCauseDeadlock(txnID uint64, waitingFor map[uint64]struct{}) bool {
 	// DFS to detect cycles in wait-for graph
 	visited := make(map[uint64]bool)
@@ -437,8 +542,9 @@ func (lm *LockManager) wouldCauseDeadlock(txnID uint64, waitingFor map[uint64]st
 	return false
 }
 
-// processWaitQueue tries to grant locks to waiting requests.
-func (lm *LockManager) processWaitQueue(keyStr string, lockInfo *LockInfo) {
+// processWaitQueue tries to grant locks to waiting requests (caller must
+// hold stripe.mu).
+func (lm *LockManager) processWaitQueue(stripe *lockStripe, keyStr string, lockInfo *LockInfo) {
 	// Process wait queue in order (FIFO)
 	newQueue := make([]*LockRequest, 0, len(lockInfo.WaitQueue))
 
@@ -449,11 +555,13 @@ func (lm *LockManager) processWaitQueue(keyStr string, lockInfo *LockInfo) {
 
 		if lm.canGrantLock(lockInfo, req.TxnID, req.LockType) {
 			// Grant the lock
-			lm.grantLock(lockInfo, req.TxnID, keyStr, req.LockType)
+			stripe.grantLock(lockInfo, req.TxnID, keyStr, req.LockType)
 			req.Granted = true
 
 			// Remove from wait-for graph
+			lm.waitMu.Lock()
 			delete(lm.waitFor, req.TxnID)
+			lm.waitMu.Unlock()
 
 			// Signal the waiting goroutine
 			close(req.Waiting)
@@ -466,34 +574,45 @@ func (lm *LockManager) processWaitQueue(keyStr string, lockInfo *LockInfo) {
 	lockInfo.WaitQueue = newQueue
 }
 
-// removeFromWaitQueue removes a transaction from the wait queue for a key.
-func (lm *LockManager) removeFromWaitQueue(keyStr string, txnID uint64) {
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
+// removeFromWaitQueue removes a timed out request from the wait queue of a
+// key. It returns true if the request was granted before it could be
+// removed, in which case the transaction holds the lock.
+func (lm *LockManager) removeFromWaitQueue(keyStr string, req *LockRequest) bool {
+	stripe := lm.stripe(keyStr)
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
 
-	lockInfo, exists := lm.locks[keyStr]
-	if !exists {
-		return
+	if req.Granted {
+		return true
 	}
 
-	newQueue := make([]*LockRequest, 0, len(lockInfo.WaitQueue))
-	for _, req := range lockInfo.WaitQueue {
-		if req.TxnID != txnID {
-			newQueue = append(newQueue, req)
+	if lockInfo, exists := stripe.locks[keyStr]; exists {
+		newQueue := make([]*LockRequest, 0, len(lockInfo.WaitQueue))
+		for _, queued := range lockInfo.WaitQueue {
+			if queued != req {
+				newQueue = append(newQueue, queued)
+			}
+		}
+		lockInfo.WaitQueue = newQueue
+		if len(lockInfo.Holders) == 0 && len(lockInfo.WaitQueue) == 0 {
+			lm.removeLockInfo(stripe, keyStr)
 		}
 	}
-	lockInfo.WaitQueue = newQueue
 
 	// Remove from wait-for graph
-	delete(lm.waitFor, txnID)
+	lm.waitMu.Lock()
+	delete(lm.waitFor, req.TxnID)
+	lm.waitMu.Unlock()
+	return false
 }
 
 // GetLockInfo returns information about a lock (for debugging/testing).
 func (lm *LockManager) GetLockInfo(key []byte) *LockInfo {
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
+	stripe := lm.stripe(string(key))
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
 
-	if lockInfo, exists := lm.locks[string(key)]; exists {
+	if lockInfo, exists := stripe.locks[string(key)]; exists {
 		// Return a copy to avoid races
 		copy := &LockInfo{
 			Holders:   make(map[uint64]LockType),
@@ -514,17 +633,56 @@ func (lm *LockManager) GetLockInfo(key []byte) *LockInfo {
 
 // NumLocks returns the number of keys with active locks.
 func (lm *LockManager) NumLocks() int {
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
-	return len(lm.locks)
+	n := 0
+	for _, stripe := range lm.stripes {
+		stripe.mu.Lock()
+		n += len(stripe.locks)
+		stripe.mu.Unlock()
+	}
+	return n
 }
 
 // NumTxnLocks returns the number of locks held by a transaction.
 func (lm *LockManager) NumTxnLocks(txnID uint64) int {
-	lm.mu.Lock()
-	defer lm.mu.Unlock()
-	if keys, ok := lm.txnLocks[txnID]; ok {
-		return len(keys)
+	n := 0
+	for _, stripe := range lm.stripes {
+		stripe.mu.Lock()
+		n += len(stripe.txnLocks[txnID])
+		stripe.mu.Unlock()
+	}
+	return n
+}
+
+// KeysLockedInRange returns the keys in [begin, end) that transactions
+// other than txnID hold locks on, in no particular order. Keys are ordered
+// by cmp, the comparator of the database they belong to.
+func (lm *LockManager) KeysLockedInRange(txnID uint64, begin, end []byte, cmp func(a, b []byte) int) [][]byte {
+	var keys [][]byte
+	for _, stripe := range lm.stripes {
+		stripe.mu.Lock()
+		for keyStr, lockInfo := range stripe.locks {
+			key := []byte(keyStr)
+			if cmp(key, begin) < 0 || cmp(key, end) >= 0 {
+				continue
+			}
+			for holder := range lockInfo.Holders {
+				if holder != txnID {
+					keys = append(keys, key)
+					break
+				}
+			}
+		}
+		stripe.mu.Unlock()
 	}
-	return 0
+	return keys
+}
+
+// DefaultTimeout returns the lock timeout used when Lock is given none.
+func (lm *LockManager) DefaultTimeout() time.Duration {
+	return lm.defaultTimeout
+}
+
+// NumStripes returns the number of stripes the locks are sharded into.
+func (lm *LockManager) NumStripes() int {
+	return len(lm.stripes)
 }
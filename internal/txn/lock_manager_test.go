@@ -3,7 +3,9 @@ package txn
 // lock_manager_test.go implements tests for lock manager.
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -460,3 +462,175 @@ func TestLockManagerDeadlockChain(t *testing.T) {
 		t.Error("Timed out waiting for goroutines to finish")
 	}
 }
+
+func TestLockManagerNumStripes(t *testing.T) {
+	if got := NewLockManager(LockManagerOptions{}).NumStripes(); got != 1 {
+		t.Errorf("NumStripes() = %d with NumStripes 0, want 1", got)
+	}
+	if got := NewLockManager(LockManagerOptions{NumStripes: 8}).NumStripes(); got != 8 {
+		t.Errorf("NumStripes() = %d, want 8", got)
+	}
+}
+
+// keysInDistinctStripes returns n keys that hash to different stripes.
+func keysInDistinctStripes(t *testing.T, lm *LockManager, n int) [][]byte {
+	t.Helper()
+	var keys [][]byte
+	seen := make(map[*lockStripe]bool)
+	for i := 0; len(keys) < n; i++ {
+		if i == 10000 {
+			t.Fatalf("found only %d keys in distinct stripes", len(keys))
+		}
+		key := fmt.Appendf(nil, "key%d", i)
+		if s := lm.stripe(string(key)); !seen[s] {
+			seen[s] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// TestLockManagerDeadlockAcrossStripes tests that deadlocks through keys of
+// different stripes are detected.
+func TestLockManagerDeadlockAcrossStripes(t *testing.T) {
+	lm := NewLockManager(LockManagerOptions{NumStripes: 16})
+	keys := keysInDistinctStripes(t, lm, 3)
+
+	// T1, T2 and T3 hold K1, K2 and K3, then T1 waits for K2 and T2 for K3
+	for i, key := range keys {
+		if err := lm.Lock(uint64(i+1), key, LockTypeExclusive, time.Second); err != nil {
+			t.Fatalf("Txn %d failed to acquire its key: %v", i+1, err)
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range 2 {
+		wg.Go(func() {
+			errs[i] = lm.Lock(uint64(i+1), keys[i+1], LockTypeExclusive, 5*time.Second)
+		})
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// T3 waiting for K1 closes the cycle T3->T1->T2->T3
+	if err := lm.Lock(3, keys[0], LockTypeExclusive, 5*time.Second); !errors.Is(err, ErrDeadlock) {
+		t.Errorf("Expected ErrDeadlock, got %v", err)
+	}
+
+	// Releasing T3's locks lets T2, then T1, proceed
+	lm.UnlockAll(3)
+	time.Sleep(20 * time.Millisecond)
+	lm.UnlockAll(2)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Txn %d failed to acquire its second key: %v", i+1, err)
+		}
+	}
+	lm.UnlockAll(1)
+	if lm.NumLocks() != 0 {
+		t.Errorf("Expected 0 locks after test, got %d", lm.NumLocks())
+	}
+}
+
+// TestLockManagerConcurrentDeadlocksAcrossStripes has pairs of transactions
+// lock two keys of different stripes in opposite orders at the same time.
+// One of each pair must detect the deadlock instead of both timing out.
+func TestLockManagerConcurrentDeadlocksAcrossStripes(t *testing.T) {
+	lm := NewLockManager(LockManagerOptions{NumStripes: 16})
+	const pairs = 20
+
+	var timeouts atomic.Int64
+	var wg sync.WaitGroup
+	for p := range pairs {
+		keys := [][]byte{fmt.Appendf(nil, "a%d", p), fmt.Appendf(nil, "b%d", p)}
+		for lm.stripe(string(keys[0])) == lm.stripe(string(keys[1])) {
+			keys[1] = append(keys[1], '+')
+		}
+		var ready sync.WaitGroup
+		ready.Add(2)
+		for i := range 2 {
+			txnID := uint64(2*p + i + 1)
+			first, second := keys[i], keys[1-i]
+			wg.Go(func() {
+				defer lm.UnlockAll(txnID)
+				if err := lm.Lock(txnID, first, LockTypeExclusive, 5*time.Second); err != nil {
+					ready.Done()
+					t.Errorf("Txn %d failed to acquire its first key: %v", txnID, err)
+					return
+				}
+				ready.Done()
+				ready.Wait()
+				if err := lm.Lock(txnID, second, LockTypeExclusive, 2*time.Second); errors.Is(err, ErrLockTimeout) {
+					timeouts.Add(1)
+				}
+			})
+		}
+	}
+	wg.Wait()
+
+	if n := timeouts.Load(); n != 0 {
+		t.Errorf("%d lock requests of deadlocked transactions timed out, want deadlock errors", n)
+	}
+	if lm.NumLocks() != 0 {
+		t.Errorf("Expected 0 locks after test, got %d", lm.NumLocks())
+	}
+}
+
+func TestLockManagerMaxNumLocks(t *testing.T) {
+	lm := NewLockManager(LockManagerOptions{NumStripes: 4, MaxNumLocks: 3})
+
+	for i := range 3 {
+		if err := lm.Lock(1, fmt.Appendf(nil, "key%d", i), LockTypeExclusive, time.Second); err != nil {
+			t.Fatalf("Lock %d failed: %v", i, err)
+		}
+	}
+	if err := lm.Lock(2, []byte("key3"), LockTypeShared, time.Second); !errors.Is(err, ErrLockLimit) {
+		t.Errorf("Lock beyond the limit = %v, want ErrLockLimit", err)
+	}
+	if lm.TryLock(2, []byte("key3"), LockTypeShared) {
+		t.Error("TryLock beyond the limit succeeded")
+	}
+
+	// Keys already locked do not count again
+	if err := lm.Lock(1, []byte("key0"), LockTypeExclusive, time.Second); err != nil {
+		t.Errorf("Lock of a held key failed: %v", err)
+	}
+
+	// Releasing a lock makes room for another one
+	if err := lm.Unlock(1, []byte("key0")); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := lm.Lock(2, []byte("key3"), LockTypeShared, time.Second); err != nil {
+		t.Errorf("Lock after an unlock failed: %v", err)
+	}
+	lm.UnlockAll(1)
+	lm.UnlockAll(2)
+	if lm.NumLocks() != 0 {
+		t.Errorf("Expected 0 locks after test, got %d", lm.NumLocks())
+	}
+}
+
+func TestLockManagerKeysLockedInRange(t *testing.T) {
+	lm := NewLockManager(DefaultLockManagerOptions())
+	for txnID, key := range map[uint64]string{1: "a", 2: "b", 3: "c", 4: "d"} {
+		if !lm.TryLock(txnID, []byte(key), LockTypeExclusive) {
+			t.Fatalf("TryLock(%d, %s) failed", txnID, key)
+		}
+	}
+
+	// Keys of other transactions in [b, d)
+	keys := lm.KeysLockedInRange(3, []byte("a0"), []byte("d"), bytes.Compare)
+	if len(keys) != 1 || string(keys[0]) != "b" {
+		t.Errorf("KeysLockedInRange = %q, want [b]", keys)
+	}
+	if keys := lm.KeysLockedInRange(9, []byte("a"), []byte("z"), bytes.Compare); len(keys) != 4 {
+		t.Errorf("KeysLockedInRange of all keys = %q, want 4 keys", keys)
+	}
+
+	// Keys of other transactions in [c, a) in reverse order
+	reverse := func(a, b []byte) int { return bytes.Compare(b, a) }
+	keys = lm.KeysLockedInRange(2, []byte("c"), []byte("a"), reverse)
+	if len(keys) != 1 || string(keys[0]) != "c" {
+		t.Errorf("KeysLockedInRange in reverse order = %q, want [c]", keys)
+	}
+}
@@ -92,7 +92,10 @@ func (v *Version) Unref() {
 
 // NumLevels returns the number of levels in use.
 func (v *Version) NumLevels() int {
-	return MaxNumLevels
+	if v.vset == nil {
+		return MaxNumLevels
+	}
+	return v.vset.NumLevels()
 }
 
 // NumFiles returns the number of files at the given level.
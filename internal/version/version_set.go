@@ -21,6 +21,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -53,7 +54,8 @@ type VersionSetOptions struct {
 	// MaxManifestFileSize is the maximum size of a MANIFEST file before rotation.
 	MaxManifestFileSize uint64
 
-	// NumLevels is the number of levels in the LSM tree.
+	// NumLevels is the number of levels in the LSM tree, at most
+	// MaxNumLevels. 0 means MaxNumLevels.
 	NumLevels int
 
 	// ComparatorName is the name of the comparator used by the database.
@@ -69,6 +71,15 @@ type VersionSetOptions struct {
 // ErrComparatorMismatch indicates that the database was created with a different comparator.
 var ErrComparatorMismatch = errors.New("version: comparator mismatch")
 
+// ErrTooManyLevels indicates that the MANIFEST places files at levels beyond
+// the configured number of levels.
+//
+// The MANIFEST does not record the number of levels a database was created
+// with, so Recover checks only where its files are: like RocksDB, it accepts
+// a NumLevels other than the one the database was written with, larger or
+// smaller, as long as no file lies at or beyond it.
+var ErrTooManyLevels = errors.New("version: db has more levels than num_levels")
+
 // DefaultVersionSetOptions returns default options.
 func DefaultVersionSetOptions(dbname string) VersionSetOptions {
 	return VersionSetOptions{
@@ -113,6 +124,9 @@ type VersionSet struct {
 	// Version numbering (for debugging)
 	currentVersionNumber uint64
 
+	// Number of installed versions that changed each column family
+	cfVersionNumbers map[uint32]uint64
+
 	// MANIFEST writer
 	manifestFile   vfs.WritableFile
 	manifestWriter *wal.Writer
@@ -148,11 +162,35 @@ func (vs *VersionSet) Current() *Version {
 	return vs.current
 }
 
+// NumLevels returns the number of levels in the LSM tree.
+func (vs *VersionSet) NumLevels() int {
+	if vs.opts.NumLevels <= 0 || vs.opts.NumLevels > MaxNumLevels {
+		return MaxNumLevels
+	}
+	return vs.opts.NumLevels
+}
+
 // NextFileNumber allocates a new file number.
 func (vs *VersionSet) NextFileNumber() uint64 {
 	return atomic.AddUint64(&vs.nextFileNumber, 1) - 1
 }
 
+// MarkFileNumberUsed ensures that file numbers allocated in the future are
+// greater than number.
+//
+// Reference: RocksDB v10.7.5 db/version_set.cc VersionSet::MarkFileNumberUsed
+func (vs *VersionSet) MarkFileNumberUsed(number uint64) {
+	for {
+		next := atomic.LoadUint64(&vs.nextFileNumber)
+		if next > number {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&vs.nextFileNumber, next, number+1) {
+			return
+		}
+	}
+}
+
 // NextVersionNumber allocates a new version number.
 func (vs *VersionSet) NextVersionNumber() uint64 {
 	return atomic.AddUint64(&vs.currentVersionNumber, 1)
@@ -163,6 +201,18 @@ func (vs *VersionSet) CurrentVersionNumber() uint64 {
 	return atomic.LoadUint64(&vs.currentVersionNumber)
 }
 
+// ColumnFamilyVersionNumber returns the number of versions installed by
+// LogAndApply with edits of the column family, such as flushes, compactions
+// and ingestions. It only grows, so a change tells that a new version of the
+// column family has been installed.
+//
+// Reference: RocksDB v10.7.5 db/column_family.h (ColumnFamilyData::GetSuperVersionNumber)
+func (vs *VersionSet) ColumnFamilyVersionNumber(cfID uint32) uint64 {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	return vs.cfVersionNumbers[cfID]
+}
+
 // NumLiveVersions returns the number of live versions.
 func (vs *VersionSet) NumLiveVersions() int {
 	vs.listMu.Lock()
@@ -175,6 +225,27 @@ func (vs *VersionSet) NumLiveVersions() int {
 	return count
 }
 
+// OldestBlobFileNumber returns the oldest blob file referenced by any SST
+// of any live version, or 0 if none references a blob file. Blob files
+// numbered below it are no longer needed.
+// Reference: RocksDB v10.7.5 db/version_set.cc (VersionSet::AddObsoleteBlobFile)
+func (vs *VersionSet) OldestBlobFileNumber() uint64 {
+	vs.listMu.Lock()
+	defer vs.listMu.Unlock()
+
+	var oldest uint64
+	for v := vs.dummyVersions.next; v != &vs.dummyVersions; v = v.next {
+		for level := range v.NumLevels() {
+			for _, f := range v.Files(level) {
+				if f.OldestBlobFileNumber != 0 && (oldest == 0 || f.OldestBlobFileNumber < oldest) {
+					oldest = f.OldestBlobFileNumber
+				}
+			}
+		}
+	}
+	return oldest
+}
+
 // GetManifestFileNumber returns the current MANIFEST file number.
 func (vs *VersionSet) GetManifestFileNumber() uint64 {
 	vs.mu.Lock()
@@ -194,7 +265,12 @@ func (vs *VersionSet) SetLastSequence(seq uint64) {
 
 // LogNumber returns the current log file number.
 func (vs *VersionSet) LogNumber() uint64 {
-	return vs.logNumber
+	return atomic.LoadUint64(&vs.logNumber)
+}
+
+// SetLogNumber sets the current log file number.
+func (vs *VersionSet) SetLogNumber(logNumber uint64) {
+	atomic.StoreUint64(&vs.logNumber, logNumber)
 }
 
 // ManifestFileNumber returns the current manifest file number.
@@ -282,22 +358,10 @@ func (vs *VersionSet) Recover() error {
 	// Map from CF ID to name (nil means dropped)
 	cfMap := make(map[uint32]string)
 
-	for {
-		record, err := reader.ReadRecord()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("manifest read error: %w", err)
-		}
-
-		var edit manifest.VersionEdit
-		if err := edit.DecodeFrom(record); err != nil {
-			return fmt.Errorf("manifest decode error: %w", err)
-		}
-
+	// applyEdit applies one recovered edit to the builder and extracts its state.
+	applyEdit := func(edit *manifest.VersionEdit) error {
 		// Apply the edit
-		if err := builder.Apply(&edit); err != nil {
+		if err := builder.Apply(edit); err != nil {
 			return err
 		}
 
@@ -330,7 +394,7 @@ func (vs *VersionSet) Recover() error {
 		}
 		if edit.HasLogNumber {
 			hasLogNumber = true
-			vs.logNumber = edit.LogNumber
+			atomic.StoreUint64(&vs.logNumber, edit.LogNumber)
 		}
 		if edit.HasPrevLogNumber {
 			vs.prevLogNumber = edit.PrevLogNumber
@@ -362,6 +426,46 @@ func (vs *VersionSet) Recover() error {
 			}
 			delete(cfMap, cfID)
 		}
+
+		return nil
+	}
+
+	// Pending members of an atomic group (see LogAndApplyAtomicGroup).
+	var atomicGroup []*manifest.VersionEdit
+
+	for {
+		record, err := reader.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("manifest read error: %w", err)
+		}
+
+		var decoded manifest.VersionEdit
+		if err := decoded.DecodeFrom(record); err != nil {
+			return fmt.Errorf("manifest decode error: %w", err)
+		}
+
+		// Hold back atomic group members until the whole group has been read;
+		// a group cut short by a crash is dropped.
+		if decoded.IsInAtomicGroup {
+			atomicGroup = append(atomicGroup, &decoded)
+			if decoded.RemainingEntries > 0 {
+				continue
+			}
+			for _, edit := range atomicGroup {
+				if err := applyEdit(edit); err != nil {
+					return err
+				}
+			}
+			atomicGroup = nil
+			continue
+		}
+
+		if err := applyEdit(&decoded); err != nil {
+			return err
+		}
 	}
 
 	// Build list of recovered column families (excluding default CF which has ID 0)
@@ -391,9 +495,17 @@ func (vs *VersionSet) Recover() error {
 		atomic.StoreUint64(&vs.nextFileNumber, maxFileNumSeen+1)
 	}
 
-	// Create the recovered version
+	// Create the recovered version. Only the levels holding files bound
+	// NumLevels; see ErrTooManyLevels.
+	current := builder.SaveTo(vs)
+	for level := vs.NumLevels(); level < MaxNumLevels; level++ {
+		if n := len(current.files[level]); n > 0 {
+			return fmt.Errorf("%w: %d files at level %d, num_levels is %d",
+				ErrTooManyLevels, n, level, vs.NumLevels())
+		}
+	}
 	vs.manifestFileNumber = manifestNum
-	vs.current = builder.SaveTo(vs)
+	vs.current = current
 	vs.current.Ref()
 	vs.appendVersion(vs.current)
 
@@ -402,25 +514,52 @@ func (vs *VersionSet) Recover() error {
 
 // LogAndApply logs a VersionEdit to the MANIFEST and applies it.
 func (vs *VersionSet) LogAndApply(edit *manifest.VersionEdit) error {
+	return vs.logAndApplyEdits([]*manifest.VersionEdit{edit})
+}
+
+// LogAndApplyAtomicGroup logs several VersionEdits to the MANIFEST as one
+// atomic group and installs a single new version reflecting all of them.
+// Recovery applies the group only if every edit in it was written, so the
+// edits (typically one per column family) take effect together or not at all.
+//
+// Reference: RocksDB v10.7.5 db/version_set.cc (LogAndApply with atomic groups)
+func (vs *VersionSet) LogAndApplyAtomicGroup(edits []*manifest.VersionEdit) error {
+	if len(edits) == 1 {
+		return vs.LogAndApply(edits[0])
+	}
+	for i, edit := range edits {
+		edit.SetAtomicGroup(uint32(len(edits) - 1 - i))
+	}
+	return vs.logAndApplyEdits(edits)
+}
+
+// logAndApplyEdits writes edits to the MANIFEST with a single sync and
+// installs the version produced by applying all of them.
+func (vs *VersionSet) logAndApplyEdits(edits []*manifest.VersionEdit) error {
 	// Whitebox [synctest]: barrier at LogAndApply start
 	_ = testutil.SP(testutil.SPVersionSetLogAndApply)
 
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
-	// Create new version by applying edit to current
+	// Create new version by applying the edits to current
 	builder := NewBuilder(vs, vs.current)
-	if err := builder.Apply(edit); err != nil {
-		return err
+	for _, edit := range edits {
+		if err := builder.Apply(edit); err != nil {
+			return err
+		}
 	}
 	newVersion := builder.SaveTo(vs)
 
 	// Persist NextFileNumber with every edit so recovery never reuses file numbers.
-	edit.HasNextFileNumber = true
-	edit.NextFileNumber = atomic.LoadUint64(&vs.nextFileNumber)
+	encoded := make([][]byte, len(edits))
+	for i, edit := range edits {
+		edit.HasNextFileNumber = true
+		edit.NextFileNumber = atomic.LoadUint64(&vs.nextFileNumber)
 
-	// Encode the edit
-	encoded := edit.EncodeTo()
+		// Encode the edit
+		encoded[i] = edit.EncodeTo()
+	}
 
 	// Write to MANIFEST
 	// Track if we created a new MANIFEST so we can update CURRENT after sync.
@@ -458,9 +597,11 @@ func (vs *VersionSet) LogAndApply(edit *manifest.VersionEdit) error {
 	// Whitebox [crashtest]: crash before MANIFEST write — tests partial manifest handling
 	testutil.MaybeKill(testutil.KPManifestWrite0)
 
-	// Write the edit
-	if _, err := vs.manifestWriter.AddRecord(encoded); err != nil {
-		return err
+	// Write the edits
+	for _, record := range encoded {
+		if _, err := vs.manifestWriter.AddRecord(record); err != nil {
+			return err
+		}
 	}
 
 	// Whitebox [crashtest]: crash before MANIFEST sync — tests unsynced manifest
@@ -497,6 +638,15 @@ func (vs *VersionSet) LogAndApply(edit *manifest.VersionEdit) error {
 	}
 	vs.current = newVersion
 
+	if vs.cfVersionNumbers == nil {
+		vs.cfVersionNumbers = make(map[uint32]uint64)
+	}
+	for i, edit := range edits {
+		if !slices.ContainsFunc(edits[:i], func(e *manifest.VersionEdit) bool { return e.ColumnFamily == edit.ColumnFamily }) {
+			vs.cfVersionNumbers[edit.ColumnFamily]++
+		}
+	}
+
 	return nil
 }
 
@@ -522,7 +672,7 @@ func (vs *VersionSet) writeSnapshot() *manifest.VersionEdit {
 		HasComparator:     true,
 		Comparator:        "leveldb.BytewiseComparator",
 		HasLogNumber:      true,
-		LogNumber:         vs.logNumber,
+		LogNumber:         atomic.LoadUint64(&vs.logNumber),
 		HasNextFileNumber: true,
 		NextFileNumber:    atomic.LoadUint64(&vs.nextFileNumber),
 		HasLastSequence:   true,
@@ -618,6 +618,65 @@ func TestVersionSetRecoverWithDeletedFiles(t *testing.T) {
 	}
 }
 
+func TestVersionSetLogAndApplyAtomicGroup(t *testing.T) {
+	dir := t.TempDir()
+	opts := VersionSetOptions{
+		DBName:              dir,
+		FS:                  vfs.Default(),
+		MaxManifestFileSize: 1024 * 1024,
+		NumLevels:           MaxNumLevels,
+	}
+
+	vs1 := NewVersionSet(opts)
+	if err := vs1.Create(); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	newFileEdit := func(cf uint32, fileNum uint64) *manifest.VersionEdit {
+		edit := &manifest.VersionEdit{}
+		edit.SetColumnFamily(cf)
+		edit.AddFile(0, &manifest.FileMetaData{
+			FD:       manifest.NewFileDescriptor(fileNum, 0, 1000),
+			Smallest: makeInternalKey("a", 1, 1),
+			Largest:  makeInternalKey("z", 1, 1),
+		})
+		return edit
+	}
+
+	// A complete group is applied as one version
+	if err := vs1.LogAndApplyAtomicGroup([]*manifest.VersionEdit{newFileEdit(0, 1), newFileEdit(1, 2)}); err != nil {
+		t.Fatalf("LogAndApplyAtomicGroup() error = %v", err)
+	}
+	if vs1.NumLevelFiles(0) != 2 {
+		t.Errorf("NumLevelFiles(0) = %d, want 2", vs1.NumLevelFiles(0))
+	}
+
+	// Simulate a crash partway through a second group: only its first member reaches the MANIFEST
+	partial := newFileEdit(0, 3)
+	partial.SetAtomicGroup(1)
+	if _, err := vs1.manifestWriter.AddRecord(partial.EncodeTo()); err != nil {
+		t.Fatalf("AddRecord() error = %v", err)
+	}
+	vs1.Close()
+
+	vs2 := NewVersionSet(opts)
+	if err := vs2.Recover(); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	defer vs2.Close()
+
+	files := vs2.Current().Files(0)
+	if len(files) != 2 {
+		t.Fatalf("recovered %d L0 files, want 2 (partial group must be dropped)", len(files))
+	}
+	for _, f := range files {
+		wantCF := uint32(f.FD.GetNumber() - 1)
+		if f.ColumnFamilyID != wantCF {
+			t.Errorf("file %d: ColumnFamilyID = %d, want %d", f.FD.GetNumber(), f.ColumnFamilyID, wantCF)
+		}
+	}
+}
+
 func TestVersionSetRecoverCorruptCurrent(t *testing.T) {
 	dir := t.TempDir()
 	opts := VersionSetOptions{
@@ -1028,3 +1087,60 @@ func TestVersionSetManifestRotation(t *testing.T) {
 		t.Errorf("NumLevelFiles(0) = %d, want 50", vs.NumLevelFiles(0))
 	}
 }
+
+func TestVersionSetRecoverTooManyLevels(t *testing.T) {
+	dir := t.TempDir()
+	opts := VersionSetOptions{
+		DBName:              dir,
+		FS:                  vfs.Default(),
+		MaxManifestFileSize: 1024 * 1024,
+		NumLevels:           5,
+	}
+	vs := NewVersionSet(opts)
+	if err := vs.Create(); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	edit := &manifest.VersionEdit{
+		NewFiles: []manifest.NewFileEntry{
+			{
+				Level: 4,
+				Meta: &manifest.FileMetaData{
+					FD:       manifest.NewFileDescriptor(10, 0, 1000),
+					Smallest: makeInternalKey("a", 100, 1),
+					Largest:  makeInternalKey("z", 100, 1),
+				},
+			},
+		},
+	}
+	if err := vs.LogAndApply(edit); err != nil {
+		t.Fatalf("LogAndApply() error = %v", err)
+	}
+	vs.Close()
+
+	// Level 4 exists with 5 levels but not with 4
+	opts.NumLevels = 5
+	vs = NewVersionSet(opts)
+	if err := vs.Recover(); err != nil {
+		t.Fatalf("Recover(num_levels=5) error = %v", err)
+	}
+	if got := vs.Current().NumLevels(); got != 5 {
+		t.Errorf("NumLevels() = %d, want 5", got)
+	}
+	vs.Close()
+
+	// The check is on the files only: more levels than the database was
+	// written with are accepted
+	opts.NumLevels = MaxNumLevels
+	vs = NewVersionSet(opts)
+	if err := vs.Recover(); err != nil {
+		t.Fatalf("Recover(num_levels=%d) error = %v", MaxNumLevels, err)
+	}
+	vs.Close()
+
+	opts.NumLevels = 4
+	vs = NewVersionSet(opts)
+	if err := vs.Recover(); !errors.Is(err, ErrTooManyLevels) {
+		t.Errorf("Recover(num_levels=4) error = %v, want ErrTooManyLevels", err)
+	}
+}
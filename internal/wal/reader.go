@@ -26,7 +26,8 @@ var (
 	// ErrInvalidRecordType indicates an unrecognized record type.
 	ErrInvalidRecordType = errors.New("wal: invalid record type")
 
-	// ErrOldRecord indicates a record from a recycled log file.
+	// ErrOldRecord indicates a record from a previous use of a recycled log
+	// file. It marks the end of the log.
 	ErrOldRecord = errors.New("wal: old record from recycled log")
 
 	// ErrUnexpectedEOF indicates an unexpected end of file.
@@ -64,6 +65,7 @@ type Reader struct {
 	endOfBuffer   int    //nolint:unused // Reserved for block boundary tracking
 	lastRecordEnd int    // Position after the last record
 	blockOffset   int    // Offset within current block
+	recycled      bool   // Whether a recyclable record has been read
 
 	// Fragment assembly
 	fragments          []byte // Accumulated fragments for multi-part records
@@ -266,6 +268,13 @@ func (r *Reader) readPhysicalRecord() (RecordType, []byte, error) {
 		// Extract payload
 		payload := r.buffer[headerSize : headerSize+length]
 
+		// A recycled log is written in the recyclable format only, so a
+		// legacy record after a recyclable one is stale data from the
+		// file's previous use.
+		if r.recycled && !IsRecyclableType(recordType) && recordType != ZeroType {
+			return 0, nil, r.oldRecord(headerSize + length)
+		}
+
 		// Verify checksum if enabled
 		if r.checksum {
 			// Compute expected CRC
@@ -276,12 +285,7 @@ func (r *Reader) readPhysicalRecord() (RecordType, []byte, error) {
 				logNum := encoding.DecodeFixed32(r.buffer[7:11])
 				if uint64(logNum) != r.logNumber {
 					// This is an old record from a recycled log
-					if r.reporter != nil {
-						r.reporter.OldLogRecord(headerSize + length)
-					}
-					r.buffer = r.buffer[headerSize+length:]
-					r.blockOffset += headerSize + length
-					return 0, nil, ErrOldRecord
+					return 0, nil, r.oldRecord(headerSize + length)
 				}
 				// Extend CRC with log number
 				crc = checksum.Extend(crc, r.buffer[7:11])
@@ -317,6 +321,9 @@ func (r *Reader) readPhysicalRecord() (RecordType, []byte, error) {
 		r.buffer = r.buffer[headerSize+length:]
 		r.blockOffset += headerSize + length
 		r.lastRecordEnd = r.blockOffset
+		if IsRecyclableType(recordType) {
+			r.recycled = true
+		}
 
 		// Make a copy of payload since buffer may be reused
 		result := make([]byte, len(payload))
@@ -325,6 +332,18 @@ func (r *Reader) readPhysicalRecord() (RecordType, []byte, error) {
 	}
 }
 
+// oldRecord reports a record left over from a previous use of a recycled
+// log. It marks the end of the log: the rest of the file is stale.
+// Reference: RocksDB v10.7.5 db/log_reader.cc (kOldRecord)
+func (r *Reader) oldRecord(bytes int) error {
+	if r.reporter != nil {
+		r.reporter.OldLogRecord(bytes)
+	}
+	r.buffer = nil
+	r.eof = true
+	return ErrOldRecord
+}
+
 // reportCorruption reports a corruption to the reporter if one is set.
 func (r *Reader) reportCorruption(bytes int, err error) {
 	if r.reporter != nil {
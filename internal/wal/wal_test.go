@@ -777,6 +777,77 @@ func TestReaderRecyclableWrongLogNumber(t *testing.T) {
 	}
 }
 
+// overwriteLog writes records as log logNumber over the start of old, as a
+// recycled log file is reused, and returns the resulting file contents.
+func overwriteLog(t *testing.T, old []byte, logNumber uint64, records [][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, logNumber, true)
+	for _, rec := range records {
+		if _, err := w.AddRecord(rec); err != nil {
+			t.Fatalf("AddRecord error: %v", err)
+		}
+	}
+	if buf.Len() > len(old) {
+		return buf.Bytes()
+	}
+	return append(buf.Bytes(), old[buf.Len():]...)
+}
+
+func TestReaderRecycledLogStopsAtStaleRecords(t *testing.T) {
+	for _, recyclable := range []bool{true, false} {
+		// Fill the file's previous use with more, larger records than the new one
+		var old bytes.Buffer
+		w := NewWriter(&old, 1, recyclable)
+		for i := range 100 {
+			w.AddRecord(bigString(string(rune('a'+i%26)), 1000+i*97))
+		}
+
+		records := [][]byte{[]byte("first"), bigString("second", 50000), []byte("third")}
+		data := overwriteLog(t, old.Bytes(), 2, records)
+
+		reporter := newTestReporter()
+		r := NewReader(bytes.NewReader(data), reporter, true, 2)
+		for i, want := range records {
+			got, err := r.ReadRecord()
+			if err != nil {
+				t.Fatalf("recyclable=%v: ReadRecord(%d) error: %v", recyclable, i, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("recyclable=%v: ReadRecord(%d) returned %d bytes, want %d", recyclable, i, len(got), len(want))
+			}
+		}
+		if _, err := r.ReadRecord(); !errors.Is(err, ErrOldRecord) && !errors.Is(err, io.EOF) {
+			t.Fatalf("recyclable=%v: ReadRecord after last record = %v, want end of log", recyclable, err)
+		}
+		if _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+			t.Errorf("recyclable=%v: ReadRecord after end of log = %v, want EOF", recyclable, err)
+		}
+	}
+}
+
+func TestReaderRecycledLogStopsAtLegacyRecord(t *testing.T) {
+	// The new record ends exactly where an intact legacy record starts
+	var old bytes.Buffer
+	w := NewWriter(&old, 1, false)
+	w.AddRecord(make([]byte, len("new")+RecyclableHeaderSize-HeaderSize))
+	w.AddRecord([]byte("stale"))
+
+	data := overwriteLog(t, old.Bytes(), 2, [][]byte{[]byte("new")})
+
+	reporter := newTestReporter()
+	r := NewReader(bytes.NewReader(data), reporter, true, 2)
+	if got, err := r.ReadRecord(); err != nil || string(got) != "new" {
+		t.Fatalf("ReadRecord = %q, %v, want %q", got, err, "new")
+	}
+	if got, err := r.ReadRecord(); !errors.Is(err, ErrOldRecord) {
+		t.Fatalf("ReadRecord = %q, %v, want ErrOldRecord", got, err)
+	}
+	if len(reporter.oldRecords) != 1 {
+		t.Errorf("reported %d old records, want 1", len(reporter.oldRecords))
+	}
+}
+
 // -----------------------------------------------------------------------------
 // Checksum tests
 // -----------------------------------------------------------------------------
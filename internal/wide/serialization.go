@@ -0,0 +1,130 @@
+// Package wide implements the serialization of wide-column entities.
+//
+// A wide-column entity maps a key to a set of named columns. It is stored as
+// the value of a TypeWideColumnEntity entry, using the RocksDB version 1
+// layout:
+//
+//	[Version (varint32)]
+//	[Number of Columns (varint32)]
+//	[Column Index: Name (length-prefixed) | Value Size (varint32)]...
+//	[Column Values]...
+//
+// Columns are ordered by name, with no duplicates. The column with the empty
+// name is the default column, which plain reads return.
+//
+// Reference: RocksDB v10.7.5
+//   - db/wide/wide_column_serialization.h
+//   - db/wide/wide_column_serialization.cc
+package wide
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"github.com/aalhour/rockyardkv/internal/encoding"
+)
+
+// Version is the serialization format version written by Serialize.
+const Version = 1
+
+var (
+	// ErrColumnsOutOfOrder is returned when columns are not strictly ordered
+	// by name, which includes duplicate names.
+	ErrColumnsOutOfOrder = errors.New("wide: columns out of order")
+
+	// ErrUnsupportedVersion is returned for entities of a newer format.
+	ErrUnsupportedVersion = errors.New("wide: unsupported serialization version")
+
+	// ErrCorrupted is returned when an entity cannot be decoded.
+	ErrCorrupted = errors.New("wide: corrupted entity")
+)
+
+// Column is a named column of an entity.
+type Column struct {
+	Name  []byte
+	Value []byte
+}
+
+// SortColumns orders columns by name.
+func SortColumns(columns []Column) {
+	slices.SortStableFunc(columns, func(a, b Column) int {
+		return bytes.Compare(a.Name, b.Name)
+	})
+}
+
+// Serialize encodes columns, which must be ordered by name.
+func Serialize(columns []Column) ([]byte, error) {
+	size := 2 * encoding.MaxVarint32Length
+	for i, col := range columns {
+		if i > 0 && bytes.Compare(columns[i-1].Name, col.Name) >= 0 {
+			return nil, ErrColumnsOutOfOrder
+		}
+		size += 2*encoding.MaxVarint32Length + len(col.Name) + len(col.Value)
+	}
+
+	out := make([]byte, 0, size)
+	out = encoding.AppendVarint32(out, Version)
+	out = encoding.AppendVarint32(out, uint32(len(columns)))
+	for _, col := range columns {
+		out = encoding.AppendLengthPrefixedSlice(out, col.Name)
+		out = encoding.AppendVarint32(out, uint32(len(col.Value)))
+	}
+	for _, col := range columns {
+		out = append(out, col.Value...)
+	}
+	return out, nil
+}
+
+// Deserialize decodes an entity. The returned columns alias data.
+func Deserialize(data []byte) ([]Column, error) {
+	s := encoding.NewSlice(data)
+	version, ok := s.GetVarint32()
+	if !ok {
+		return nil, ErrCorrupted
+	}
+	if version > Version {
+		return nil, ErrUnsupportedVersion
+	}
+	numColumns, ok := s.GetVarint32()
+	if !ok || uint64(numColumns) > uint64(s.Remaining()) {
+		return nil, ErrCorrupted
+	}
+
+	columns := make([]Column, numColumns)
+	sizes := make([]uint32, numColumns)
+	for i := range columns {
+		name, ok := s.GetLengthPrefixedSlice()
+		if !ok {
+			return nil, ErrCorrupted
+		}
+		if i > 0 && bytes.Compare(columns[i-1].Name, name) >= 0 {
+			return nil, ErrColumnsOutOfOrder
+		}
+		if sizes[i], ok = s.GetVarint32(); !ok {
+			return nil, ErrCorrupted
+		}
+		columns[i].Name = name
+	}
+	for i := range columns {
+		value, ok := s.GetBytes(int(sizes[i]))
+		if !ok {
+			return nil, ErrCorrupted
+		}
+		columns[i].Value = value
+	}
+	return columns, nil
+}
+
+// DefaultColumnValue returns the value of the default column of an entity,
+// or an empty value if the entity has no default column.
+func DefaultColumnValue(data []byte) ([]byte, error) {
+	columns, err := Deserialize(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) > 0 && len(columns[0].Name) == 0 {
+		return columns[0].Value, nil
+	}
+	return []byte{}, nil
+}
@@ -0,0 +1,96 @@
+package wide
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: []byte(""), Value: []byte("default")},
+		{Name: []byte("age"), Value: []byte("28")},
+		{Name: []byte("name"), Value: []byte("Alice")},
+		{Name: []byte("note"), Value: []byte{}},
+	}
+	data, err := Serialize(columns)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	got, err := Deserialize(data)
+	if err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if len(got) != len(columns) {
+		t.Fatalf("Deserialize returned %d columns, want %d", len(got), len(columns))
+	}
+	for i := range columns {
+		if !bytes.Equal(got[i].Name, columns[i].Name) || !bytes.Equal(got[i].Value, columns[i].Value) {
+			t.Errorf("Column %d = %q:%q, want %q:%q", i, got[i].Name, got[i].Value, columns[i].Name, columns[i].Value)
+		}
+	}
+
+	value, err := DefaultColumnValue(data)
+	if err != nil || string(value) != "default" {
+		t.Errorf("DefaultColumnValue = %q, %v; want default", value, err)
+	}
+}
+
+func TestSerializeLayout(t *testing.T) {
+	// Version, column count, then the column index, then the values
+	data, err := Serialize([]Column{
+		{Name: []byte("a"), Value: []byte("xy")},
+		{Name: []byte("b"), Value: []byte("z")},
+	})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	want := []byte{1, 2, 1, 'a', 2, 1, 'b', 1, 'x', 'y', 'z'}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Serialize = %v, want %v", data, want)
+	}
+}
+
+func TestSerializeColumnOrder(t *testing.T) {
+	columns := []Column{
+		{Name: []byte("b"), Value: []byte("2")},
+		{Name: []byte("a"), Value: []byte("1")},
+	}
+	if _, err := Serialize(columns); !errors.Is(err, ErrColumnsOutOfOrder) {
+		t.Errorf("Serialize unsorted = %v, want ErrColumnsOutOfOrder", err)
+	}
+
+	SortColumns(columns)
+	if _, err := Serialize(columns); err != nil {
+		t.Errorf("Serialize sorted failed: %v", err)
+	}
+
+	duplicates := []Column{{Name: []byte("a")}, {Name: []byte("a")}}
+	if _, err := Serialize(duplicates); !errors.Is(err, ErrColumnsOutOfOrder) {
+		t.Errorf("Serialize duplicates = %v, want ErrColumnsOutOfOrder", err)
+	}
+}
+
+func TestDeserializeErrors(t *testing.T) {
+	data, err := Serialize([]Column{{Name: []byte("name"), Value: []byte("value")}})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	if _, err := Deserialize(data[:len(data)-1]); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Deserialize truncated = %v, want ErrCorrupted", err)
+	}
+	if _, err := Deserialize([]byte{2, 0}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Deserialize version 2 = %v, want ErrUnsupportedVersion", err)
+	}
+	if _, err := Deserialize(nil); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Deserialize empty = %v, want ErrCorrupted", err)
+	}
+
+	// An entity without a default column reads as an empty value
+	value, err := DefaultColumnValue(data)
+	if err != nil || len(value) != 0 {
+		t.Errorf("DefaultColumnValue = %q, %v; want empty", value, err)
+	}
+}
@@ -0,0 +1,46 @@
+package rockyardkv
+
+// iostats_context.go implements IOStatsContext, counters of the file I/O
+// issued on behalf of reads.
+//
+// Like PerfContext, the counters are process-wide rather than thread-local,
+// and are only collected while the perf level is PerfLevelEnableCount.
+//
+// Reference: RocksDB v10.7.5 include/rocksdb/iostats_context.h
+
+import "github.com/aalhour/rockyardkv/internal/perf"
+
+// IOStatsContext is a snapshot of the I/O counters.
+type IOStatsContext struct {
+	// BytesRead is the number of bytes read from SST files.
+	BytesRead uint64
+
+	// ReadCount is the number of read calls issued to SST files. Together
+	// with BytesRead it gives the average read size, which grows when
+	// readahead is in effect.
+	ReadCount uint64
+
+	// BlobBytesRead is the number of bytes read from blob files.
+	BlobBytesRead uint64
+
+	// BlobReadCount is the number of read calls issued to blob files. Blob
+	// prefetching during iteration lowers it by batching the reads of
+	// neighbouring blobs.
+	BlobReadCount uint64
+}
+
+// GetIOStatsContext returns a snapshot of the current I/O counters.
+func GetIOStatsContext() IOStatsContext {
+	s := perf.GlobalIOStats()
+	return IOStatsContext{
+		BytesRead:     s.BytesRead.Load(),
+		ReadCount:     s.ReadCount.Load(),
+		BlobBytesRead: s.BlobBytesRead.Load(),
+		BlobReadCount: s.BlobReadCount.Load(),
+	}
+}
+
+// ResetIOStatsContext zeroes every I/O counter.
+func ResetIOStatsContext() {
+	perf.GlobalIOStats().Reset()
+}
@@ -0,0 +1,110 @@
+package rockyardkv
+
+// iostats_context_test.go implements tests for IOStatsContext and automatic
+// iterator readahead.
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestIOStatsContextAutoReadahead(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+
+	const numKeys = 20000
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%06d", i), bytes.Repeat([]byte("v"), 100)); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	// Open the table so that only data block reads are counted below
+	if _, err := db.Get(nil, []byte("key000000")); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+
+	SetPerfLevel(PerfLevelEnableCount)
+	defer SetPerfLevel(PerfLevelDisable)
+
+	// A long forward scan ramps readahead up, so reads get large
+	ResetIOStatsContext()
+	iter := db.NewIterator(nil)
+	count := 0
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		count++
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("iteration error: %v", err)
+	}
+	iter.Close()
+	if count != numKeys {
+		t.Fatalf("scan visited %d keys, want %d", count, numKeys)
+	}
+	scan := GetIOStatsContext()
+	if scan.ReadCount == 0 {
+		t.Fatalf("scan issued no reads")
+	}
+	if avg := scan.BytesRead / scan.ReadCount; avg < 32*1024 {
+		t.Errorf("scan: %d reads of %d bytes on average, want readahead to grow reads past 32KB",
+			scan.ReadCount, avg)
+	}
+
+	// Random seeks keep reading one block at a time
+	ResetIOStatsContext()
+	iter = db.NewIterator(nil)
+	rng := rand.New(rand.NewSource(1))
+	for range 200 {
+		iter.Seek(fmt.Appendf(nil, "key%06d", rng.Intn(numKeys)))
+		if !iter.Valid() {
+			t.Fatalf("Seek: iterator invalid: %v", iter.Error())
+		}
+	}
+	iter.Close()
+	seeks := GetIOStatsContext()
+	if seeks.ReadCount == 0 {
+		t.Fatalf("seeks issued no reads")
+	}
+	if avg := seeks.BytesRead / seeks.ReadCount; avg >= 8*1024 {
+		t.Errorf("seeks: %d reads of %d bytes on average, want single-block reads under 8KB",
+			seeks.ReadCount, avg)
+	}
+}
+
+func TestIOStatsContextDisabled(t *testing.T) {
+	SetPerfLevel(PerfLevelDisable)
+	ResetIOStatsContext()
+
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	if _, err := db.Get(nil, []byte("key")); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+
+	if s := GetIOStatsContext(); s != (IOStatsContext{}) {
+		t.Errorf("disabled IOStatsContext = %+v, want zero", s)
+	}
+}
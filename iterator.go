@@ -11,8 +11,12 @@ package rockyardkv
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
+	"slices"
+	"sync"
 
+	"github.com/aalhour/rockyardkv/internal/blob"
 	"github.com/aalhour/rockyardkv/internal/dbformat"
 	"github.com/aalhour/rockyardkv/internal/manifest"
 	"github.com/aalhour/rockyardkv/internal/memtable"
@@ -51,11 +55,23 @@ type Iterator interface {
 	// REQUIRES: Valid()
 	Key() []byte
 
-	// Value returns the value at the current position.
+	// Value returns the value at the current position. For a wide-column
+	// entity this is the value of the default column.
 	// REQUIRES: Valid()
 	Value() []byte
 
-	// Error returns any error that has occurred.
+	// Columns returns the wide columns at the current position, ordered by
+	// name. A plain value is returned as a single default column.
+	// REQUIRES: Valid()
+	Columns() []WideColumn
+
+	// Status returns the error that stopped the iterator, such as a
+	// corrupted block or an I/O error. When Valid returns false, a nil
+	// Status means that the iterator reached the end of its range.
+	// Reference: RocksDB v10.7.5 include/rocksdb/iterator_base.h (Status)
+	Status() error
+
+	// Error returns the same error as Status.
 	Error() error
 
 	// Close releases resources associated with the iterator.
@@ -76,6 +92,8 @@ func (it *errorIterator) Next()                     {}
 func (it *errorIterator) Prev()                     {}
 func (it *errorIterator) Key() []byte               { return nil }
 func (it *errorIterator) Value() []byte             { return nil }
+func (it *errorIterator) Columns() []WideColumn     { return nil }
+func (it *errorIterator) Status() error             { return it.err }
 func (it *errorIterator) Error() error              { return it.err }
 func (it *errorIterator) Close() error              { return nil }
 
@@ -90,8 +108,8 @@ type dbIterator struct {
 
 	// Internal iterators
 	memIter  *memtable.MemTableIterator
-	immIter  *memtable.MemTableIterator // Immutable memtable iterator
-	sstIters []*sstIterWrapper          // SST file iterators
+	immIters []*memtable.MemTableIterator // Immutable memtable iterators
+	sstIters []*sstIterWrapper            // SST file iterators
 
 	// Version reference (to keep SST files alive)
 	version *version.Version
@@ -107,6 +125,9 @@ type dbIterator struct {
 	savedKey []byte
 	// savedValue is the current value
 	savedValue []byte
+	// savedColumns are the columns of the current entry if it is a
+	// wide-column entity (nil = plain value)
+	savedColumns []WideColumn
 
 	// direction indicates whether we're moving forward or backward
 	direction int // 1 = forward, -1 = backward, 0 = not moving
@@ -121,6 +142,33 @@ type dbIterator struct {
 
 	// Comparator for key comparison (nil means use bytewise)
 	comparator Comparator
+
+	// readaheadSize is the initial readahead for SST data blocks
+	// (0 = automatic readahead)
+	readaheadSize uint64
+
+	// fillCache adds the SST data blocks read to the block cache
+	fillCache bool
+
+	// asyncIO positions the SST iterators concurrently, overlapping their
+	// block reads
+	asyncIO bool
+
+	// blobPrefetcher batches blob reads during forward iteration
+	// (nil = read each blob separately)
+	blobPrefetcher *blob.Prefetcher
+
+	// verifyOrder checks key order and visibility on every step
+	verifyOrder bool
+}
+
+// resolveBlobIndexForward reads the value referenced by a blob index
+// reached by forward iteration, through the blob prefetcher if enabled.
+func (it *dbIterator) resolveBlobIndexForward(index []byte) ([]byte, error) {
+	if it.blobPrefetcher == nil {
+		return it.db.resolveBlobIndex(index)
+	}
+	return it.db.readBlob(index, it.blobPrefetcher.GetValue)
 }
 
 // compareKeys compares two user keys using the configured comparator.
@@ -185,17 +233,34 @@ type sstIterWrapper struct {
 	fileNum  uint64
 	reader   *table.Reader
 	released bool
+
+	// pruned is set when the file's prefix filter ruled out the last Seek:
+	// the iterator is exhausted without having read a block
+	pruned bool
 }
 
-func (w *sstIterWrapper) Valid() bool        { return w.iter != nil && w.iter.Valid() }
-func (w *sstIterWrapper) Key() []byte        { return w.iter.Key() }
-func (w *sstIterWrapper) Value() []byte      { return w.iter.Value() }
-func (w *sstIterWrapper) SeekToFirst()       { w.iter.SeekToFirst() }
-func (w *sstIterWrapper) SeekToLast()        { w.iter.SeekToLast() }
-func (w *sstIterWrapper) Seek(target []byte) { w.iter.Seek(target) }
-func (w *sstIterWrapper) Next()              { w.iter.Next() }
-func (w *sstIterWrapper) Prev()              { w.iter.Prev() }
-func (w *sstIterWrapper) Error() error       { return w.iter.Error() }
+func (w *sstIterWrapper) Valid() bool   { return !w.pruned && w.iter != nil && w.iter.Valid() }
+func (w *sstIterWrapper) Key() []byte   { return w.iter.Key() }
+func (w *sstIterWrapper) Value() []byte { return w.iter.Value() }
+func (w *sstIterWrapper) SeekToFirst()  { w.pruned = false; w.iter.SeekToFirst() }
+func (w *sstIterWrapper) SeekToLast()   { w.pruned = false; w.iter.SeekToLast() }
+func (w *sstIterWrapper) Seek(target []byte) {
+	w.pruned = false
+	w.iter.Seek(target)
+}
+func (w *sstIterWrapper) Error() error { return w.iter.Error() }
+
+func (w *sstIterWrapper) Next() {
+	if !w.pruned {
+		w.iter.Next()
+	}
+}
+
+func (w *sstIterWrapper) Prev() {
+	if !w.pruned {
+		w.iter.Prev()
+	}
+}
 
 func (w *sstIterWrapper) userKey() []byte {
 	key := w.iter.Key()
@@ -230,11 +295,14 @@ func decodeFixed64(b []byte) uint64 {
 // newDBIterator creates a new database iterator for the default column family.
 // Reserved - currently NewIterator uses newDBIteratorCF directly.
 func newDBIterator(db *dbImpl, snapshot *Snapshot) *dbIterator { //nolint:unused // reserved for future use
-	return newDBIteratorCF(db, nil, snapshot)
+	return newDBIteratorCF(db, nil, snapshot, 0, true)
 }
 
 // newDBIteratorCF creates a new database iterator for a specific column family.
-func newDBIteratorCF(db *dbImpl, cfd *columnFamilyData, snapshot *Snapshot) *dbIterator {
+// A non-zero readaheadSize sets the readahead of the SST iterators, which
+// otherwise read ahead automatically once they detect a sequential scan.
+// fillCache adds the data blocks they read to the block cache.
+func newDBIteratorCF(db *dbImpl, cfd *columnFamilyData, snapshot *Snapshot, readaheadSize uint64, fillCache bool) *dbIterator {
 	// Determine snapshot sequence number for range deletion visibility
 	var snapshotSeq dbformat.SequenceNumber
 	if snapshot != nil {
@@ -244,26 +312,29 @@ func newDBIteratorCF(db *dbImpl, cfd *columnFamilyData, snapshot *Snapshot) *dbI
 	}
 
 	iter := &dbIterator{
-		db:          db,
-		cfd:         cfd,
-		snapshot:    snapshot,
-		rangeDelAgg: rangedel.NewRangeDelAggregator(snapshotSeq),
-		comparator:  db.comparator,
+		db:            db,
+		cfd:           cfd,
+		snapshot:      snapshot,
+		rangeDelAgg:   rangedel.NewRangeDelAggregator(snapshotSeq),
+		comparator:    db.comparator,
+		readaheadSize: readaheadSize,
+		fillCache:     fillCache,
 	}
 
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	// Get memtable iterators
-	var mem, imm *memtable.MemTable
+	var mem *memtable.MemTable
+	var imms []*memtable.MemTable
 	if cfd == nil || cfd.id == DefaultColumnFamilyID {
 		mem = db.mem
-		imm = db.imm
+		imms = db.imm
 	} else {
 		cfd.memMu.RLock()
 		mem = cfd.mem
 		if len(cfd.imm) > 0 {
-			imm = cfd.imm[0]
+			imms = cfd.imm[:1]
 		}
 		cfd.memMu.RUnlock()
 	}
@@ -279,10 +350,11 @@ func newDBIteratorCF(db *dbImpl, cfd *columnFamilyData, snapshot *Snapshot) *dbI
 			iter.rangeDelAgg.AddTombstones(-1, fragmented)
 		}
 	}
-	if imm != nil {
+	for _, imm := range imms {
 		imm.Ref()
-		iter.immIter = imm.NewIterator()
-		iter.iterators = append(iter.iterators, &memtableIterWrapper{iter: iter.immIter})
+		immIter := imm.NewIterator()
+		iter.immIters = append(iter.immIters, immIter)
+		iter.iterators = append(iter.iterators, &memtableIterWrapper{iter: immIter})
 
 		// Add range tombstones from immutable memtable to aggregator (level -1)
 		if imm.HasRangeTombstones() {
@@ -338,8 +410,15 @@ func (it *dbIterator) createSSTIterator(f *manifest.FileMetaData) *sstIterWrappe
 		return nil
 	}
 
+	tableIter := reader.NewIteratorWithOptions(table.IteratorOptions{
+		VerifyChecksums: reader.Options().VerifyChecksums,
+		ReadaheadSize:   it.readaheadSize,
+		AutoReadahead:   true,
+		FillCache:       it.fillCache,
+	})
+
 	return &sstIterWrapper{
-		iter:    reader.NewIterator(),
+		iter:    tableIter,
 		fileNum: fileNum,
 		reader:  reader,
 	}
@@ -366,9 +445,7 @@ func (it *dbIterator) SeekToFirst() {
 	}
 
 	// Seek all iterators to first
-	for _, iter := range it.iterators {
-		iter.SeekToFirst()
-	}
+	it.positionAll(internalIterator.SeekToFirst)
 
 	it.findNextValidEntry()
 }
@@ -382,9 +459,7 @@ func (it *dbIterator) SeekToLast() {
 	it.direction = dirBackward
 
 	// Seek all iterators to last
-	for _, iter := range it.iterators {
-		iter.SeekToLast()
-	}
+	it.positionAll(internalIterator.SeekToLast)
 
 	// If we have an upper bound, we need to position before it
 	// This is done in findPrevValidEntry which checks the lower bound,
@@ -401,6 +476,28 @@ func (it *dbIterator) SeekToLast() {
 	it.findPrevValidEntry()
 }
 
+// positionAll calls position on every internal iterator. With AsyncIO the
+// SST iterators are positioned concurrently, so that the block reads of
+// different files overlap instead of adding up.
+// Reference: RocksDB v10.7.5 include/rocksdb/options.h (ReadOptions::async_io)
+func (it *dbIterator) positionAll(position func(internalIterator)) {
+	if !it.asyncIO || len(it.sstIters) < 2 {
+		for _, iter := range it.iterators {
+			position(iter)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	for _, iter := range it.iterators {
+		if _, ok := iter.(*sstIterWrapper); ok {
+			wg.Go(func() { position(iter) })
+		} else {
+			position(iter)
+		}
+	}
+	wg.Wait()
+}
+
 // Seek positions the iterator at the first key >= target.
 func (it *dbIterator) Seek(target []byte) {
 	// Don't clear errors set during construction (e.g., SST file corruption)
@@ -426,10 +523,18 @@ func (it *dbIterator) Seek(target []byte) {
 	// Create an internal key for seeking (target + max sequence number)
 	seekKey := makeInternalKey(target, uint64(dbformat.MaxSequenceNumber), dbformat.ValueTypeForSeek)
 
-	// Seek all iterators
-	for _, iter := range it.iterators {
+	// Seek all iterators, skipping the SST files whose prefix filter rules
+	// out the seek prefix: with PrefixSameAsStart the iteration never leaves
+	// the prefix, so they hold no key it returns
+	usePrefixFilter := it.seekPrefix != nil && !it.totalOrderSeek
+	it.positionAll(func(iter internalIterator) {
+		if sst, ok := iter.(*sstIterWrapper); ok && usePrefixFilter && sst.reader != nil &&
+			!sst.reader.PrefixMayMatch(it.seekPrefix, it.prefixExtractor.Name()) {
+			sst.pruned = true
+			return
+		}
 		iter.Seek(seekKey)
-	}
+	})
 
 	it.findNextValidEntry()
 }
@@ -452,6 +557,10 @@ func (it *dbIterator) Next() {
 		return
 	}
 
+	if it.verifyOrder {
+		defer it.verifyStep(it.savedKey, dirForward)
+	}
+
 	prevDirection := it.direction
 	it.direction = dirForward
 
@@ -478,6 +587,10 @@ func (it *dbIterator) Prev() {
 		return
 	}
 
+	if it.verifyOrder {
+		defer it.verifyStep(it.savedKey, dirBackward)
+	}
+
 	prevDirection := it.direction
 	it.direction = dirBackward
 
@@ -670,11 +783,59 @@ outerLoop:
 			}
 		}
 
+		value := it.iterators[minIdx].Value()
+		if valueType == dbformat.TypeMerge {
+			// Combine the operands with the older versions of the key. This
+			// moves the iterators at the key past the versions read.
+			key := copySlice(minKey)
+			var versions []keyVersion
+			for _, iter := range it.iterators {
+				if iter.Valid() && it.keysEqual(iter.userKey(), key) {
+					versions = it.readVersions(iter, key, versions)
+				}
+			}
+			merged, mergedType, err := it.mergeVersions(key, versions)
+			if err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+			minKey, value, valueType = key, merged, mergedType
+		}
+		if valueType == dbformat.TypeBlobIndex {
+			resolved, err := it.resolveBlobIndexForward(value)
+			if err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+			value = resolved
+		}
+		it.savedColumns = nil
+		if valueType == dbformat.TypeWideColumnEntity {
+			columns, err := decodeEntity(copySlice(value))
+			if err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+			it.savedColumns = columns
+			value = defaultColumn(columns)
+		}
+
+		if it.verifyOrder {
+			if err := it.verifyVisible(minKey, minSeq); err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+		}
+
 		// Found a valid entry
 		it.savedKey = make([]byte, len(minKey))
 		copy(it.savedKey, minKey)
-		it.savedValue = make([]byte, len(it.iterators[minIdx].Value()))
-		copy(it.savedValue, it.iterators[minIdx].Value())
+		it.savedValue = make([]byte, len(value))
+		copy(it.savedValue, value)
 		it.currentIter = minIdx
 		it.valid = true
 		return
@@ -734,15 +895,11 @@ outerLoop:
 		keyToCheck := make([]byte, len(maxKey))
 		copy(keyToCheck, maxKey)
 
-		// Find the newest version by seeking in the iterator where we found maxKey.
-		// This handles the case where we're at an older version within the same SST.
-		newestType, newestSeq, newestValue := it.findNewestVersionInIterator(it.iterators[maxIdx], keyToCheck)
-
-		// Also check other iterators that might have a newer version
-		for i, iter := range it.iterators {
-			if i == maxIdx {
-				continue
-			}
+		// Read the visible versions of the key from every iterator at it.
+		// Within an SST, backward iteration reaches the oldest version of a
+		// key first, so each iterator seeks to the newest visible one.
+		var versions []keyVersion
+		for _, iter := range it.iterators {
 			if !iter.Valid() {
 				// Check if the iterator became invalid due to an error (not just BOF)
 				if err := iter.Error(); err != nil {
@@ -752,15 +909,8 @@ outerLoop:
 				}
 				continue
 			}
-			// Only check if this iterator is also at the same key
-			if !it.keysEqual(iter.userKey(), keyToCheck) {
-				continue
-			}
-			otherType, otherSeq, otherValue := it.findNewestVersionInIterator(iter, keyToCheck)
-			if otherSeq > newestSeq {
-				newestType = otherType
-				newestSeq = otherSeq
-				newestValue = otherValue
+			if it.keysEqual(iter.userKey(), keyToCheck) {
+				versions = it.readVersionsBackward(iter, keyToCheck, versions)
 			}
 		}
 
@@ -771,13 +921,15 @@ outerLoop:
 			}
 		}
 
-		// Check if deleted
-		if newestType == dbformat.TypeDeletion || newestType == dbformat.TypeSingleDeletion {
+		// Not visible to the snapshot
+		if len(versions) == 0 {
 			continue outerLoop
 		}
+		slices.SortFunc(versions, compareKeyVersions)
+		newestType, newestSeq, newestValue := versions[0].valueType, versions[0].seq, versions[0].value
 
-		// Check snapshot visibility
-		if it.snapshot != nil && newestSeq > it.snapshot.Sequence() {
+		// Check if deleted
+		if newestType == dbformat.TypeDeletion || newestType == dbformat.TypeSingleDeletion {
 			continue outerLoop
 		}
 
@@ -803,6 +955,44 @@ outerLoop:
 			}
 		}
 
+		if newestType == dbformat.TypeMerge {
+			merged, mergedType, err := it.mergeVersions(keyToCheck, versions)
+			if err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+			newestType, newestValue = mergedType, merged
+		}
+		if newestType == dbformat.TypeBlobIndex {
+			resolved, err := it.db.resolveBlobIndex(newestValue)
+			if err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+			newestValue = resolved
+		}
+		it.savedColumns = nil
+		if newestType == dbformat.TypeWideColumnEntity {
+			columns, err := decodeEntity(copySlice(newestValue))
+			if err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+			it.savedColumns = columns
+			newestValue = defaultColumn(columns)
+		}
+
+		if it.verifyOrder {
+			if err := it.verifyVisible(keyToCheck, newestSeq); err != nil {
+				it.err = err
+				it.valid = false
+				return
+			}
+		}
+
 		// Found valid entry
 		it.savedKey = keyToCheck
 		it.savedValue = make([]byte, len(newestValue))
@@ -813,36 +1003,86 @@ outerLoop:
 	}
 }
 
-// findNewestVersionInIterator seeks to find the newest version of userKey in the given iterator.
-// After this call, the iterator is positioned at the newest version of userKey (if it exists).
-// The caller is responsible for calling Prev() to move past all versions of this key.
-//
-// Note: This function intentionally does NOT restore the iterator position. The previous
-// "restore" logic was buggy and could leave the iterator before the target key, causing
-// the subsequent skip loop to miss keys. Instead, we leave the iterator at the newest
-// version, and the caller's skip loop handles moving past all versions correctly.
-func (it *dbIterator) findNewestVersionInIterator(iter internalIterator, userKey []byte) (dbformat.ValueType, uint64, []byte) {
-	// Seek to find newest version (highest sequence number for this user key)
-	seekKey := makeInternalKey(userKey, uint64(dbformat.MaxSequenceNumber), dbformat.ValueTypeForSeek)
-	iter.Seek(seekKey)
+// keyVersion is one version of a user key read from an internal iterator.
+type keyVersion struct {
+	seq       uint64
+	valueType dbformat.ValueType
+	value     []byte
+}
 
-	var resultType dbformat.ValueType
-	var resultSeq uint64
-	var resultValue []byte
+// compareKeyVersions orders versions of a key newest first.
+func compareKeyVersions(a, b keyVersion) int {
+	return cmp.Compare(b.seq, a.seq)
+}
 
-	if iter.Valid() && it.keysEqual(iter.userKey(), userKey) {
-		resultType = iter.valueType()
-		resultSeq = iter.seqNum()
-		if resultType != dbformat.TypeDeletion && resultType != dbformat.TypeSingleDeletion {
-			resultValue = make([]byte, len(iter.Value()))
-			copy(resultValue, iter.Value())
+// readVersions appends to versions the versions of userKey in iter that are
+// visible to the iterator, reading forward from iter's current position.
+// Reading stops at the first version that is not a merge operand, since it
+// shadows the older ones.
+func (it *dbIterator) readVersions(iter internalIterator, userKey []byte, versions []keyVersion) []keyVersion {
+	for ; iter.Valid() && it.keysEqual(iter.userKey(), userKey); iter.Next() {
+		seq := iter.seqNum()
+		if it.snapshot != nil && seq > it.snapshot.Sequence() {
+			continue
+		}
+		valueType := iter.valueType()
+		versions = append(versions, keyVersion{seq: seq, valueType: valueType, value: copySlice(iter.Value())})
+		if valueType != dbformat.TypeMerge {
+			break
 		}
 	}
+	return versions
+}
 
-	// Don't restore position - leave iterator at newest version of userKey.
-	// The caller's skip loop will move past all versions of this key.
+// readVersionsBackward is readVersions for an iterator at some version of
+// userKey during backward iteration, where the oldest version of a key in an
+// SST is reached first. It seeks to the newest visible version before
+// reading, and leaves iter at the newest version of userKey so the caller
+// can step back past the key.
+func (it *dbIterator) readVersionsBackward(iter internalIterator, userKey []byte, versions []keyVersion) []keyVersion {
+	visibleSeq := uint64(dbformat.MaxSequenceNumber)
+	if it.snapshot != nil {
+		visibleSeq = it.snapshot.Sequence()
+	}
+	iter.Seek(makeInternalKey(userKey, visibleSeq, dbformat.ValueTypeForSeek))
+	versions = it.readVersions(iter, userKey, versions)
+	iter.Seek(makeInternalKey(userKey, uint64(dbformat.MaxSequenceNumber), dbformat.ValueTypeForSeek))
+	return versions
+}
 
-	return resultType, resultSeq, resultValue
+// mergeVersions applies the merge operands at the head of versions, which
+// may come from several iterators, to the version below them. There is no
+// base value if the key was deleted there, covered by a range tombstone, or
+// never written before. Both directions resolve a key this way, so Prev
+// returns the same merged value as Next.
+// Reference: RocksDB v10.7.5 db/db_iter.cc (DBIter::MergeValuesNewToOld)
+func (it *dbIterator) mergeVersions(userKey []byte, versions []keyVersion) ([]byte, dbformat.ValueType, error) {
+	slices.SortFunc(versions, compareKeyVersions)
+
+	var operands [][]byte
+	var base []byte
+	baseType := dbformat.TypeValue
+	for _, v := range versions {
+		if it.rangeDelAgg != nil && it.rangeDelAgg.ShouldDelete(userKey, dbformat.SequenceNumber(v.seq)) {
+			break
+		}
+		if v.valueType == dbformat.TypeMerge {
+			operands = append(operands, v.value)
+			continue
+		}
+		switch v.valueType {
+		case dbformat.TypeBlobIndex:
+			resolved, err := it.db.resolveBlobIndex(v.value)
+			if err != nil {
+				return nil, 0, err
+			}
+			base = resolved
+		case dbformat.TypeValue, dbformat.TypeWideColumnEntity:
+			base, baseType = v.value, v.valueType
+		}
+		break
+	}
+	return it.db.mergeEntry(userKey, base, baseType, operands)
 }
 
 // Key returns the key at the current position.
@@ -861,11 +1101,27 @@ func (it *dbIterator) Value() []byte {
 	return it.savedValue
 }
 
-// Error returns any error that has occurred.
-func (it *dbIterator) Error() error {
+// Columns returns the wide columns at the current position.
+func (it *dbIterator) Columns() []WideColumn {
+	if !it.Valid() {
+		return nil
+	}
+	if it.savedColumns != nil {
+		return it.savedColumns
+	}
+	return []WideColumn{{Name: DefaultWideColumnName, Value: it.savedValue}}
+}
+
+// Status returns the error that stopped the iterator, or nil.
+func (it *dbIterator) Status() error {
 	return it.err
 }
 
+// Error returns the same error as Status.
+func (it *dbIterator) Error() error {
+	return it.Status()
+}
+
 // Close releases resources associated with the iterator.
 func (it *dbIterator) Close() error {
 	// Release SST file references
@@ -883,7 +1139,7 @@ func (it *dbIterator) Close() error {
 	}
 
 	it.memIter = nil
-	it.immIter = nil
+	it.immIters = nil
 	it.sstIters = nil
 	it.iterators = nil
 
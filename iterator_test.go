@@ -5,6 +5,8 @@ package rockyardkv
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -196,6 +198,76 @@ func TestIteratorSeekBeyondRange(t *testing.T) {
 	}
 }
 
+// TestIteratorStatus tests that Status tells the end of a scan from a scan
+// stopped by a corrupted block.
+func TestIteratorStatus(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	const numKeys = 1000
+	for i := range numKeys {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%04d", i), bytes.Repeat([]byte("v"), 100)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	scan := func() (int, Iterator) {
+		iter := db.NewIterator(nil)
+		n := 0
+		for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+			n++
+		}
+		return n, iter
+	}
+
+	// A clean scan ends with a nil Status
+	n, iter := scan()
+	if n != numKeys {
+		t.Errorf("clean scan returned %d keys, want %d", n, numKeys)
+	}
+	if err := iter.Status(); err != nil {
+		t.Errorf("clean scan: Status() = %v, want nil", err)
+	}
+	iter.Close()
+
+	// Corrupt a data block in the middle of the SST file
+	ssts := listSSTFiles(t, dir)
+	if len(ssts) != 1 {
+		t.Fatalf("got SST files %v, want one", ssts)
+	}
+	path := filepath.Join(dir, ssts[0])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// A scan stopped by the corruption ends with a non-nil Status
+	n, iter = scan()
+	defer iter.Close()
+	if n >= numKeys {
+		t.Errorf("corrupted scan returned %d keys, want fewer than %d", n, numKeys)
+	}
+	if iter.Status() == nil {
+		t.Fatal("corrupted scan: Status() = nil, want an error")
+	}
+	if iter.Error() != iter.Status() {
+		t.Errorf("Error() = %v, want Status() = %v", iter.Error(), iter.Status())
+	}
+}
+
 // TestIteratorFullScan tests forward and backward full scans.
 func TestIteratorFullScan(t *testing.T) {
 	opts := DefaultOptions()
@@ -1514,3 +1586,129 @@ func TestIteratorDeleteMultiWithDelete(t *testing.T) {
 		t.Fatal("Expected invalid before first key")
 	}
 }
+
+// TestIteratorReadahead tests that ReadaheadSize does not change what an
+// iterator returns, across several SST files and both directions.
+func TestIteratorReadahead(t *testing.T) {
+	opts := DefaultOptions()
+	db, cleanup := createTestDB(t, opts)
+	defer cleanup()
+
+	for file := range 3 {
+		for i := file; i < 3000; i += 3 {
+			key := fmt.Appendf(nil, "key%05d", i)
+			if err := db.Put(nil, key, bytes.Repeat(key, 8)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	collect := func(readOpts *ReadOptions, forward bool) []string {
+		iter := db.NewIterator(readOpts)
+		defer iter.Close()
+		var keys []string
+		if forward {
+			for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+				keys = append(keys, string(iter.Key())+"="+string(iter.Value()))
+			}
+		} else {
+			for iter.SeekToLast(); iter.Valid(); iter.Prev() {
+				keys = append(keys, string(iter.Key())+"="+string(iter.Value()))
+			}
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatalf("iteration failed: %v", err)
+		}
+		return keys
+	}
+
+	for _, forward := range []bool{true, false} {
+		want := collect(nil, forward)
+		got := collect(&ReadOptions{ReadaheadSize: 16 << 10}, forward)
+		if len(want) != 3000 {
+			t.Fatalf("forward=%v: plain scan returned %d entries, want 3000", forward, len(want))
+		}
+		if len(got) != len(want) {
+			t.Fatalf("forward=%v: readahead scan returned %d entries, want %d", forward, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("forward=%v: entry %d = %q, want %q", forward, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// TestIteratorAsyncIO verifies that positioning the SST files concurrently
+// returns the same entries as positioning them one after the other.
+func TestIteratorAsyncIO(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Level0FileNumCompactionTrigger = 8 // keep the files in L0
+	db, cleanup := createTestDB(t, opts)
+	defer cleanup()
+
+	// Overlapping files, each overwriting or deleting some keys of the
+	// previous ones, plus unflushed writes in the memtable
+	for file := range 4 {
+		for i := file; i < 1000; i += 2 {
+			key := fmt.Appendf(nil, "key%04d", i)
+			if i%7 == file {
+				if err := db.Delete(nil, key); err != nil {
+					t.Fatalf("Delete failed: %v", err)
+				}
+				continue
+			}
+			if err := db.Put(nil, key, fmt.Appendf(nil, "v%d-%d", file, i)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	for i := 0; i < 1000; i += 5 {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%04d", i), []byte("mem")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	collect := func(asyncIO bool) []string {
+		iter := db.NewIterator(&ReadOptions{AsyncIO: asyncIO})
+		defer iter.Close()
+		var entries []string
+		for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+			entries = append(entries, string(iter.Key())+"="+string(iter.Value()))
+		}
+		for iter.SeekToLast(); iter.Valid(); iter.Prev() {
+			entries = append(entries, string(iter.Key())+"="+string(iter.Value()))
+		}
+		for i := 0; i < 1000; i += 37 {
+			iter.Seek(fmt.Appendf(nil, "key%04d", i))
+			for n := 0; n < 5 && iter.Valid(); n++ {
+				entries = append(entries, string(iter.Key())+"="+string(iter.Value()))
+				iter.Next()
+			}
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatalf("iteration failed: %v", err)
+		}
+		return entries
+	}
+
+	want := collect(false)
+	got := collect(true)
+	if len(want) == 0 {
+		t.Fatal("plain iteration returned no entries")
+	}
+	if len(got) != len(want) {
+		t.Fatalf("AsyncIO iteration returned %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("entry %d = %q with AsyncIO, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package rockyardkv
+
+// iterator_verify.go implements the consistency checks enabled by
+// ReadOptions.VerifyIteratorOrder.
+//
+// Each memtable and SST iterator is wrapped so that every step is checked
+// against the internal key order (user key ascending, sequence descending),
+// and the database iterator checks that the user keys it surfaces move
+// strictly in the direction of iteration and are visible at its snapshot.
+//
+// Reference: RocksDB v10.7.5
+//   - db/db_iter.cc (DBIter debug assertions)
+//   - table/merging_iterator.cc (key order assertions)
+
+import (
+	"fmt"
+)
+
+// enableOrderVerification wraps the merged iterators with order checks.
+func (it *dbIterator) enableOrderVerification() {
+	it.verifyOrder = true
+	for i, iter := range it.iterators {
+		it.iterators[i] = &orderCheckingIter{internalIterator: iter, compareKeys: it.compareKeys}
+	}
+}
+
+// verifyStep checks that a Next or Prev step moved past prevKey in dir.
+// It runs after the step, so a violation invalidates the new position.
+func (it *dbIterator) verifyStep(prevKey []byte, dir int) {
+	if !it.Valid() {
+		return
+	}
+	if it.compareKeys(it.savedKey, prevKey)*dir <= 0 {
+		it.err = fmt.Errorf("%w: iterator moved from %q to %q", ErrCorruption, prevKey, it.savedKey)
+		it.valid = false
+	}
+}
+
+// verifyVisible checks that an entry about to be surfaced is visible at the
+// iterator's snapshot.
+func (it *dbIterator) verifyVisible(userKey []byte, seq uint64) error {
+	if it.snapshot != nil && seq > it.snapshot.Sequence() {
+		return fmt.Errorf("%w: key %q at sequence %d is newer than snapshot %d",
+			ErrCorruption, userKey, seq, it.snapshot.Sequence())
+	}
+	return nil
+}
+
+// orderCheckingIter fails once a Next or Prev step moves against the
+// internal key order. Seeks restart the check.
+type orderCheckingIter struct {
+	internalIterator
+	compareKeys func(a, b []byte) int
+
+	prevKey []byte
+	prevSeq uint64
+	hasPrev bool
+	err     error
+}
+
+func (w *orderCheckingIter) Valid() bool {
+	return w.err == nil && w.internalIterator.Valid()
+}
+
+func (w *orderCheckingIter) Error() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.internalIterator.Error()
+}
+
+func (w *orderCheckingIter) SeekToFirst() {
+	w.hasPrev = false
+	w.internalIterator.SeekToFirst()
+}
+
+func (w *orderCheckingIter) SeekToLast() {
+	w.hasPrev = false
+	w.internalIterator.SeekToLast()
+}
+
+func (w *orderCheckingIter) Seek(target []byte) {
+	w.hasPrev = false
+	w.internalIterator.Seek(target)
+}
+
+func (w *orderCheckingIter) Next() {
+	w.remember()
+	w.internalIterator.Next()
+	w.check(dirForward)
+}
+
+func (w *orderCheckingIter) Prev() {
+	w.remember()
+	w.internalIterator.Prev()
+	w.check(dirBackward)
+}
+
+// remember records the current position before a step.
+func (w *orderCheckingIter) remember() {
+	w.hasPrev = w.Valid()
+	if w.hasPrev {
+		w.prevKey = append(w.prevKey[:0], w.userKey()...)
+		w.prevSeq = w.seqNum()
+	}
+}
+
+// check fails if the step did not move strictly in dir.
+func (w *orderCheckingIter) check(dir int) {
+	if !w.hasPrev || !w.Valid() {
+		return
+	}
+	userKey, seq := w.userKey(), w.seqNum()
+	cmp := w.compareKeys(userKey, w.prevKey)
+	if cmp == 0 {
+		// Newer versions of a user key come first
+		switch {
+		case seq < w.prevSeq:
+			cmp = 1
+		case seq > w.prevSeq:
+			cmp = -1
+		}
+	}
+	if cmp*dir <= 0 {
+		w.err = fmt.Errorf("%w: internal keys out of order: %q@%d followed by %q@%d",
+			ErrCorruption, w.prevKey, w.prevSeq, userKey, seq)
+	}
+}
@@ -0,0 +1,141 @@
+package rockyardkv
+
+// iterator_verify_test.go implements tests for iterator order verification.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/checksum"
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/table"
+)
+
+func TestVerifyIteratorOrderHealthyDB(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	database, err := Open(filepath.Join(t.TempDir(), "db"), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	for i := range 50 {
+		if err := database.Put(nil, fmt.Appendf(nil, "key%03d", i), []byte("v1")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for i := 0; i < 50; i += 3 {
+		if err := database.Put(nil, fmt.Appendf(nil, "key%03d", i), []byte("v2")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.Delete(nil, []byte("key010")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	readOpts := DefaultReadOptions()
+	readOpts.VerifyIteratorOrder = true
+	iter := database.NewIterator(readOpts)
+	defer iter.Close()
+
+	count := 0
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		count++
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("forward scan: unexpected error: %v", err)
+	}
+	if count != 49 {
+		t.Errorf("forward scan: got %d keys, want 49", count)
+	}
+
+	// Reverse, then switch direction mid-scan
+	count = 0
+	for iter.SeekToLast(); iter.Valid(); iter.Prev() {
+		count++
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("backward scan: unexpected error: %v", err)
+	}
+	if count != 49 {
+		t.Errorf("backward scan: got %d keys, want 49", count)
+	}
+	iter.Seek([]byte("key020"))
+	iter.Next()
+	iter.Prev()
+	iter.Prev()
+	if !iter.Valid() || string(iter.Key()) != "key019" {
+		t.Errorf("direction change: at %q (err %v), want key019", iter.Key(), iter.Error())
+	}
+}
+
+func TestVerifyIteratorOrderOutOfOrderFile(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	database, err := Open(filepath.Join(dir, "db"), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	// Build a file whose keys are out of order, which SstFileWriter refuses
+	sstPath := filepath.Join(dir, "bad.sst")
+	f, err := os.Create(sstPath)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	builder := table.NewTableBuilder(f, table.BuilderOptions{
+		BlockSize:            4096,
+		BlockRestartInterval: 16,
+		FormatVersion:        5,
+		ChecksumType:         checksum.TypeCRC32C,
+	})
+	for _, key := range []string{"a", "c", "b"} {
+		if err := builder.Add(dbformat.NewInternalKey([]byte(key), 0, dbformat.TypeValue), []byte(key)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := database.IngestExternalFile([]string{sstPath}, DefaultIngestExternalFileOptions()); err != nil {
+		t.Fatalf("IngestExternalFile failed: %v", err)
+	}
+
+	scan := func(verify bool) ([]string, error) {
+		readOpts := DefaultReadOptions()
+		readOpts.VerifyIteratorOrder = verify
+		iter := database.NewIterator(readOpts)
+		defer iter.Close()
+		var keys []string
+		for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+			keys = append(keys, string(iter.Key()))
+		}
+		return keys, iter.Error()
+	}
+
+	// Without verification the corruption surfaces as out-of-order keys
+	keys, err := scan(false)
+	if err != nil || fmt.Sprint(keys) != "[a c b]" {
+		t.Fatalf("unverified scan = %v, %v; want [a c b]", keys, err)
+	}
+
+	keys, err = scan(true)
+	if !errors.Is(err, ErrCorruption) {
+		t.Fatalf("verified scan: expected ErrCorruption, got %v", err)
+	}
+	if fmt.Sprint(keys) != "[a c]" {
+		t.Errorf("verified scan returned %v before failing, want [a c]", keys)
+	}
+}
@@ -4,13 +4,16 @@ package rockyardkv
 //
 // Reference: RocksDB v10.7.5.
 //   include/rocksdb/metadata.h - LiveFileMetaData, SstFileMetaData structs
-//   db/db_filesnapshot.cc - GetLiveFiles, GetLiveFilesMetaData implementations
+//   db/db_filesnapshot.cc - GetLiveFiles, GetLiveFilesMetaData, GetLiveFilesStorageInfo implementations
 //   include/rocksdb/db.h - API definitions
 
 import (
 	"fmt"
 	"path/filepath"
 	"sync/atomic"
+
+	"github.com/aalhour/rockyardkv/internal/blob"
+	"github.com/aalhour/rockyardkv/internal/manifest"
 )
 
 // LiveFileMetaData describes a live SST file in the database.
@@ -56,6 +59,36 @@ type LiveFileMetaData struct {
 	BeingCompacted bool
 }
 
+// LevelMetaData describes the SST files of one level of a column family.
+// Reference: RocksDB v10.7.5 include/rocksdb/metadata.h LevelMetaData
+type LevelMetaData struct {
+	// Level is the level number.
+	Level int
+
+	// Size is the total size of the files in bytes.
+	Size uint64
+
+	// Files are the files of the level, in key order for levels above 0
+	// and oldest first for level 0.
+	Files []LiveFileMetaData
+}
+
+// ColumnFamilyMetaData describes the SST files of a column family.
+// Reference: RocksDB v10.7.5 include/rocksdb/metadata.h ColumnFamilyMetaData
+type ColumnFamilyMetaData struct {
+	// Name is the name of the column family.
+	Name string
+
+	// Size is the total size of the files in bytes.
+	Size uint64
+
+	// FileCount is the number of files.
+	FileCount int
+
+	// Levels has one entry per level, including empty levels.
+	Levels []LevelMetaData
+}
+
 // GetLiveFiles returns a list of all files in the database except WAL files.
 // Reference: RocksDB v10.7.5 db/db_filesnapshot.cc GetLiveFiles()
 func (db *dbImpl) GetLiveFiles(flushMemtable bool) ([]string, uint64, error) {
@@ -140,24 +173,269 @@ func (db *dbImpl) GetLiveFilesMetaData() []LiveFileMetaData {
 	for level := range current.NumLevels() {
 		files := current.Files(level)
 		for _, f := range files {
-			meta := LiveFileMetaData{
-				Name:             fmt.Sprintf("%06d.sst", f.FD.GetNumber()),
+			metadata = append(metadata, db.liveFileMetaData(level, f, "default"))
+		}
+	}
+
+	return metadata
+}
+
+// GetColumnFamilyMetaData returns the SST files of a column family by level.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc GetColumnFamilyMetaData()
+func (db *dbImpl) GetColumnFamilyMetaData(cf ColumnFamilyHandle) (*ColumnFamilyMetaData, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &ColumnFamilyMetaData{Name: cfd.name}
+	current := db.versions.Current()
+	if current == nil {
+		return metadata, nil
+	}
+
+	for level := range current.NumLevels() {
+		levelMeta := LevelMetaData{Level: level}
+		for _, f := range current.Files(level) {
+			if f.ColumnFamilyID != cfd.id {
+				continue
+			}
+			levelMeta.Files = append(levelMeta.Files, db.liveFileMetaData(level, f, cfd.name))
+			levelMeta.Size += f.FD.FileSize
+		}
+		metadata.Size += levelMeta.Size
+		metadata.FileCount += len(levelMeta.Files)
+		metadata.Levels = append(metadata.Levels, levelMeta)
+	}
+
+	return metadata, nil
+}
+
+// liveFileMetaData describes file f at level.
+func (db *dbImpl) liveFileMetaData(level int, f *manifest.FileMetaData, cfName string) LiveFileMetaData {
+	return LiveFileMetaData{
+		Name:             fmt.Sprintf("%06d.sst", f.FD.GetNumber()),
+		Directory:        db.name,
+		FileNumber:       f.FD.GetNumber(),
+		Size:             f.FD.FileSize,
+		ColumnFamilyName: cfName,
+		Level:            level,
+		SmallestKey:      f.Smallest, // Internal key
+		LargestKey:       f.Largest,  // Internal key
+		SmallestSeqno:    uint64(f.FD.SmallestSeqno),
+		LargestSeqno:     uint64(f.FD.LargestSeqno),
+		BeingCompacted:   f.BeingCompacted,
+	}
+}
+
+// FileType identifies the kind of a database file.
+// Reference: RocksDB v10.7.5 include/rocksdb/types.h (FileType)
+type FileType int
+
+const (
+	// FileTypeWAL is a write-ahead log file (NNNNNN.log).
+	FileTypeWAL FileType = iota
+
+	// FileTypeTable is an SST file (NNNNNN.sst).
+	FileTypeTable
+
+	// FileTypeDescriptor is a MANIFEST file.
+	FileTypeDescriptor
+
+	// FileTypeCurrent is the CURRENT file.
+	FileTypeCurrent
+
+	// FileTypeBlob is a blob file (NNNNNN.blob).
+	FileTypeBlob
+)
+
+// LiveFileStorageInfo describes a file needed to copy the database.
+//
+// SST and blob files are immutable: they are copied whole and can be hard
+// linked instead. The MANIFEST and WAL files keep growing, so only their
+// first Size bytes belong to the copy (TrimToSize). The CURRENT file is not
+// copied at all; ReplacementContents is written in its place.
+//
+// Reference: RocksDB v10.7.5 include/rocksdb/metadata.h LiveFileStorageInfo
+type LiveFileStorageInfo struct {
+	// RelativeFilename is the file name relative to Directory.
+	RelativeFilename string
+
+	// Directory is the directory containing the file.
+	Directory string
+
+	// FileNumber is the file number, or 0 for the CURRENT file.
+	FileNumber uint64
+
+	// FileType is the kind of file.
+	FileType FileType
+
+	// Size is the number of bytes to copy.
+	Size uint64
+
+	// TrimToSize is true if the file may be larger than Size, and only its
+	// first Size bytes are to be copied.
+	TrimToSize bool
+
+	// ReplacementContents, if not empty, is written in place of the file's
+	// current contents.
+	ReplacementContents string
+}
+
+// LiveFilesStorageInfoOptions controls GetLiveFilesStorageInfo.
+// Reference: RocksDB v10.7.5 include/rocksdb/options.h LiveFilesStorageInfoOptions
+type LiveFilesStorageInfoOptions struct {
+	// WALSizeForFlush flushes the memtable first if the live WAL files
+	// hold at least this many bytes. 0 always flushes.
+	WALSizeForFlush uint64
+}
+
+// GetLiveFilesStorageInfo returns the files needed to copy the database as
+// of one point in time: the SST and blob files of the current version, the
+// MANIFEST and the live WAL files up to their current size, and the CURRENT
+// file. File deletions are disabled while the list is built.
+// Reference: RocksDB v10.7.5 db/db_filesnapshot.cc GetLiveFilesStorageInfo()
+func (db *dbImpl) GetLiveFilesStorageInfo(opts LiveFilesStorageInfoOptions) ([]LiveFileStorageInfo, error) {
+	return db.getLiveFilesStorageInfo(opts, true)
+}
+
+// getLiveFilesStorageInfo implements GetLiveFilesStorageInfo. The memtable
+// is only flushed if canFlush is true.
+func (db *dbImpl) getLiveFilesStorageInfo(opts LiveFilesStorageInfoOptions, canFlush bool) ([]LiveFileStorageInfo, error) {
+	if err := db.DisableFileDeletions(); err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.EnableFileDeletions() }()
+
+	if canFlush {
+		flushNeeded := true
+		if opts.WALSizeForFlush > 0 {
+			walSize, err := db.liveWALSize()
+			if err != nil {
+				return nil, err
+			}
+			flushNeeded = walSize >= opts.WALSizeForFlush
+		}
+		if flushNeeded {
+			if err := db.Flush(&FlushOptions{Wait: true}); err != nil {
+				return nil, err
+			}
+		}
+		// Write buffered WAL records so that the WAL sizes cover every write
+		if err := db.FlushWAL(false); err != nil {
+			return nil, err
+		}
+	}
+
+	// Hold mu against MANIFEST updates and logMu against WAL appends, so
+	// that every size is taken at the same point and on a record boundary
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+	db.logMu.Lock()
+	defer db.logMu.Unlock()
+
+	var files []LiveFileStorageInfo
+	current := db.versions.Current()
+	for level := range current.NumLevels() {
+		for _, f := range current.Files(level) {
+			files = append(files, LiveFileStorageInfo{
+				RelativeFilename: sstFileName(f.FD.GetNumber()),
 				Directory:        db.name,
 				FileNumber:       f.FD.GetNumber(),
+				FileType:         FileTypeTable,
 				Size:             f.FD.FileSize,
-				ColumnFamilyName: "default",
-				Level:            level,
-				SmallestKey:      f.Smallest, // Internal key
-				LargestKey:       f.Largest,  // Internal key
-				SmallestSeqno:    uint64(f.FD.SmallestSeqno),
-				LargestSeqno:     uint64(f.FD.LargestSeqno),
-				BeingCompacted:   f.BeingCompacted,
-			}
-			metadata = append(metadata, meta)
+			})
+		}
+	}
+	var blobFiles []uint64
+	if db.blobGC != nil {
+		blobFiles = db.blobGC.Files()
+	}
+	for _, fileNum := range blobFiles {
+		info, err := db.fs.Stat(filepath.Join(db.name, blob.FileName(fileNum)))
+		if err != nil {
+			return nil, err
 		}
+		files = append(files, LiveFileStorageInfo{
+			RelativeFilename: blob.FileName(fileNum),
+			Directory:        db.name,
+			FileNumber:       fileNum,
+			FileType:         FileTypeBlob,
+			Size:             uint64(info.Size()),
+		})
 	}
 
-	return metadata
+	if err := db.versions.SyncManifest(); err != nil {
+		return nil, err
+	}
+	manifestNum := db.versions.ManifestFileNumber()
+	manifestName := fmt.Sprintf("MANIFEST-%06d", manifestNum)
+	info, err := db.fs.Stat(filepath.Join(db.name, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, LiveFileStorageInfo{
+		RelativeFilename: manifestName,
+		Directory:        db.name,
+		FileNumber:       manifestNum,
+		FileType:         FileTypeDescriptor,
+		Size:             uint64(info.Size()),
+		TrimToSize:       true,
+	})
+
+	logFiles, err := db.liveLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, logNum := range logFiles {
+		info, err := db.fs.Stat(db.logFilePath(logNum))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, LiveFileStorageInfo{
+			RelativeFilename: logFileName(logNum),
+			Directory:        db.name,
+			FileNumber:       logNum,
+			FileType:         FileTypeWAL,
+			Size:             uint64(info.Size()),
+			TrimToSize:       true,
+		})
+	}
+
+	currentContents := manifestName + "\n"
+	files = append(files, LiveFileStorageInfo{
+		RelativeFilename:    "CURRENT",
+		Directory:           db.name,
+		FileType:            FileTypeCurrent,
+		Size:                uint64(len(currentContents)),
+		ReplacementContents: currentContents,
+	})
+
+	return files, nil
+}
+
+// liveWALSize returns the total size of the live WAL files.
+func (db *dbImpl) liveWALSize() (uint64, error) {
+	logFiles, err := db.liveLogFiles()
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for _, logNum := range logFiles {
+		if info, err := db.fs.Stat(db.logFilePath(logNum)); err == nil {
+			total += uint64(info.Size())
+		}
+	}
+	return total, nil
 }
 
 // fileDeletionDisabled tracks whether file deletion is disabled.
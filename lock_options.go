@@ -15,6 +15,10 @@ var (
 
 	// ErrLockNotHeld is returned when trying to unlock a key not held by the transaction.
 	ErrLockNotHeld = txn.ErrLockNotHeld
+
+	// ErrLockLimit is returned when locking a key would exceed
+	// TransactionDBOptions.MaxNumLocks.
+	ErrLockLimit = txn.ErrLockLimit
 )
 
 // LockType represents the type of lock.
@@ -0,0 +1,183 @@
+package rockyardkv
+
+// logger_test.go implements tests for Options.Logger and the LOG file.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureLogger records every message logged through it.
+type captureLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *captureLogger) logf(level InfoLogLevel, format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level.String()+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Errorf(format string, args ...any) {
+	l.logf(InfoLogLevelError, format, args...)
+}
+func (l *captureLogger) Warnf(format string, args ...any) { l.logf(InfoLogLevelWarn, format, args...) }
+func (l *captureLogger) Infof(format string, args ...any) { l.logf(InfoLogLevelInfo, format, args...) }
+func (l *captureLogger) Debugf(format string, args ...any) {
+	l.logf(InfoLogLevelDebug, format, args...)
+}
+func (l *captureLogger) Fatalf(format string, args ...any) {
+	l.logf(InfoLogLevelError, format, args...)
+}
+
+// contains reports whether a recorded message contains all of substrs.
+func (l *captureLogger) contains(substrs ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, msg := range l.messages {
+		matched := true
+		for _, s := range substrs {
+			if !strings.Contains(msg, s) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoggerFlushAndCompaction(t *testing.T) {
+	logger := &captureLogger{}
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Logger = logger
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	for i := range 2 {
+		if err := database.Put(nil, fmt.Appendf(nil, "key%d", i), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	if err := database.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+
+	for _, want := range [][]string{
+		{"INFO", "[flush] flushing"},
+		{"INFO", "[flush] flushed"},
+		{"INFO", "[compact] compacting 2 files"},
+		{"INFO", "[compact] compacted 2 files"},
+	} {
+		if !logger.contains(want...) {
+			t.Errorf("no message containing %q, got:\n%s", want, strings.Join(logger.messages, "\n"))
+		}
+	}
+}
+
+func TestDefaultLoggerWritesLOG(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MaxLogFileSize = 256
+
+	openPutFlushClose := func() {
+		t.Helper()
+		database, err := Open(dir, opts)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		for i := range 5 {
+			if err := database.Put(nil, fmt.Appendf(nil, "key%d", i), []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := database.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+		}
+		if err := database.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+
+	// With the default KeepLogFileNum nothing is deleted, so every line
+	// logged by both runs is in LOG or one of the rolled files
+	for range 2 {
+		openPutFlushClose()
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "LOG"))
+	if err != nil {
+		t.Fatalf("ReadFile(LOG) failed: %v", err)
+	}
+	if len(data) > 256 {
+		t.Errorf("LOG has %d bytes, want at most MaxLogFileSize", len(data))
+	}
+	old, err := filepath.Glob(filepath.Join(dir, "LOG.old.*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(old) < 2 {
+		t.Fatalf("got %d LOG.old.* files, want LOG rolled at MaxLogFileSize", len(old))
+	}
+	all := string(data)
+	for _, name := range old {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", name, err)
+		}
+		if len(b) > 256 {
+			t.Errorf("%s has %d bytes, want at most MaxLogFileSize", filepath.Base(name), len(b))
+		}
+		all += string(b)
+	}
+	for _, want := range []string{
+		"[db] created new database",
+		"[db] opened database",
+		"[flush] flushed",
+		"[db] closing database",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("LOG files have no line containing %q", want)
+		}
+	}
+
+	// Rolled files are named by roll time, so the oldest sort first
+	slices.Sort(old)
+	opts.KeepLogFileNum = 2
+	openPutFlushClose()
+	kept, err := filepath.Glob(filepath.Join(dir, "LOG.old.*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(kept) != 2 {
+		t.Fatalf("got %d LOG.old.* files, want KeepLogFileNum=2", len(kept))
+	}
+	for _, name := range kept {
+		if slices.Contains(old[:len(old)-1], name) {
+			t.Errorf("%s was kept, want the oldest rolled files deleted first", filepath.Base(name))
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", name, err)
+		}
+		if !strings.Contains(string(b), "[") {
+			t.Errorf("%s has no log lines: %q", filepath.Base(name), b)
+		}
+	}
+}
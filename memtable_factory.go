@@ -0,0 +1,36 @@
+package rockyardkv
+
+// memtable_factory.go implements the memtable representations that can be
+// selected through Options.MemtableFactory.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/memtablerep.h
+//   - memtable/vectorrep.cc
+
+import (
+	"github.com/aalhour/rockyardkv/internal/memtable"
+)
+
+// MemTableRepFactory selects the data structure that holds the entries of
+// a memtable. A nil factory selects a skiplist.
+type MemTableRepFactory interface {
+	// Name returns the name of the factory.
+	Name() string
+
+	newRep(cmp memtable.Comparator) memtable.Rep
+}
+
+// VectorMemTableFactory keeps the entries of a memtable in a slice. An
+// insert is an append, which makes loading keys in sorted order faster than
+// with a skiplist. Keys may still arrive out of order: the memtable is then
+// sorted once, by the next read or by the flush. Point lookups and
+// iterators on such a memtable are much slower than with a skiplist, so the
+// factory suits bulk loads that do not read until the data is flushed.
+type VectorMemTableFactory struct{}
+
+// Name returns "VectorRepFactory".
+func (VectorMemTableFactory) Name() string { return "VectorRepFactory" }
+
+func (VectorMemTableFactory) newRep(cmp memtable.Comparator) memtable.Rep {
+	return memtable.NewVectorRep(cmp)
+}
@@ -0,0 +1,93 @@
+package rockyardkv
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestVectorMemTableFactory loads keys through a vector memtable, in order
+// and out of order, and checks the flushed SST after reopening with the
+// default memtable.
+func TestVectorMemTableFactory(t *testing.T) {
+	const n = 2000
+	for _, tt := range []struct {
+		name  string
+		order func(i int) int
+	}{
+		{"sorted", func(i int) int { return i }},
+		{"unsorted", func(i int) int { return (i * 7919) % n }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			opts.MemtableFactory = VectorMemTableFactory{}
+
+			db, err := Open(dir, opts)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			for i := range n {
+				k := tt.order(i)
+				if err := db.Put(nil, fmt.Appendf(nil, "key%05d", k), fmt.Appendf(nil, "value%d", k)); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+			}
+			// Overwrite and delete keys that were loaded earlier
+			if err := db.Put(nil, []byte("key00010"), []byte("updated")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := db.Delete(nil, []byte("key00020")); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := db.Flush(nil); err != nil {
+				t.Fatalf("Flush failed: %v", err)
+			}
+			if err := db.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			db, err = Open(dir, DefaultOptions())
+			if err != nil {
+				t.Fatalf("Reopen failed: %v", err)
+			}
+			defer db.Close()
+			if files, _ := db.GetProperty("rocksdb.num-files-at-level0"); files != "1" {
+				t.Fatalf("num-files-at-level0 = %q, want 1", files)
+			}
+
+			iter := db.NewIterator(nil)
+			defer iter.Close()
+			count := 0
+			for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+				count++
+			}
+			if err := iter.Error(); err != nil {
+				t.Fatalf("iterator error: %v", err)
+			}
+			if count != n-1 {
+				t.Errorf("iterated %d keys, want %d", count, n-1)
+			}
+
+			for k := range n {
+				key := fmt.Appendf(nil, "key%05d", k)
+				value, err := db.Get(nil, key)
+				switch k {
+				case 10:
+					if err != nil || string(value) != "updated" {
+						t.Errorf("Get(%s) = %q, %v, want updated", key, value, err)
+					}
+				case 20:
+					if !errors.Is(err, ErrNotFound) {
+						t.Errorf("Get(%s) = %q, %v, want ErrNotFound", key, value, err)
+					}
+				default:
+					if want := fmt.Sprintf("value%d", k); err != nil || string(value) != want {
+						t.Fatalf("Get(%s) = %q, %v, want %s", key, value, err, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,7 +4,10 @@ package rockyardkv
 
 import (
 	"errors"
+	"slices"
 	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
 )
 
 // =============================================================================
@@ -234,6 +237,87 @@ func TestMergeBatch(t *testing.T) {
 	t.Log("Batch merge operations written successfully")
 }
 
+func TestMaxSuccessiveMerges(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+	opts.MaxSuccessiveMerges = 3
+
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+	mem := db.(*dbImpl).mem
+
+	key := []byte("log")
+	if err := db.Put(nil, key, []byte("a")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	for _, operand := range []string{"b", "c", "d"} {
+		if err := db.Merge(nil, key, []byte(operand)); err != nil {
+			t.Fatalf("Merge(%s) error = %v", operand, err)
+		}
+	}
+	if n := mem.CountSuccessiveMergeEntries(key); n != 3 {
+		t.Fatalf("successive merges = %d, want 3", n)
+	}
+
+	// The merge past the limit collapses the chain into a value
+	if err := db.Merge(nil, key, []byte("e")); err != nil {
+		t.Fatalf("Merge(e) error = %v", err)
+	}
+	if n := mem.CountSuccessiveMergeEntries(key); n != 0 {
+		t.Errorf("successive merges after collapse = %d, want 0", n)
+	}
+	value, _, operands, foundBase, _ := mem.CollectMergeOperands(key, dbformat.MaxSequenceNumber)
+	if !foundBase || len(operands) != 0 || string(value) != "a,b,c,d,e" {
+		t.Errorf("newest memtable entry = %q (base %v, %d operands), want collapsed value %q",
+			value, foundBase, len(operands), "a,b,c,d,e")
+	}
+
+	got, err := db.Get(nil, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(got) != "a,b,c,d,e" {
+		t.Errorf("Get() = %q, want %q", got, "a,b,c,d,e")
+	}
+}
+
+func TestMaxSuccessiveMergesBatch(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &UInt64AddOperator{}
+	opts.MaxSuccessiveMerges = 2
+
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	// Operands earlier in the same batch are part of the collapsed value
+	wb := NewWriteBatch()
+	for i := range 7 {
+		wb.Merge([]byte("counter"), encodeUint64(uint64(i+1)))
+	}
+	if err := db.Write(nil, wb); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if n := db.(*dbImpl).mem.CountSuccessiveMergeEntries([]byte("counter")); n > 2 {
+		t.Errorf("successive merges = %d, want at most 2", n)
+	}
+	value, err := db.Get(nil, []byte("counter"))
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := decodeUint64(value); got != 28 {
+		t.Errorf("Get() = %d, want 28", got)
+	}
+}
+
 // =============================================================================
 // Merge Operator Edge Cases
 // =============================================================================
@@ -291,3 +375,83 @@ func TestMergeLargeValue(t *testing.T) {
 
 	t.Log("Large value merge works")
 }
+
+func TestMergeIteratorPrevMatchesNext(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	mustWrite := func(name string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s error = %v", name, err)
+		}
+	}
+	put := func(key, value string) { mustWrite("Put", db.Put(nil, []byte(key), []byte(value))) }
+	merge := func(key, value string) { mustWrite("Merge", db.Merge(nil, []byte(key), []byte(value))) }
+	flush := func() { mustWrite("Flush", db.Flush(nil)) }
+
+	// Each key's operands straddle a flush, so the versions of a key are
+	// split between SSTs and the memtable.
+	put("a", "a0")
+	merge("a", "a1")
+	merge("b", "b1")
+	put("c", "c0")
+	merge("c", "c1")
+	mustWrite("Delete", db.Delete(nil, []byte("c")))
+	put("d", "d0")
+	put("e", "e0")
+	flush()
+	snapshot := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snapshot)
+	merge("a", "a2")
+	merge("b", "b2")
+	flush()
+	merge("b", "b3")
+	merge("c", "c2")
+	mustWrite("DeleteRange", db.DeleteRange(nil, []byte("d"), []byte("e")))
+	merge("d", "d1")
+
+	scan := func(readOpts *ReadOptions) (forward, backward []string) {
+		t.Helper()
+		iter := db.NewIterator(readOpts)
+		defer iter.Close()
+		for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+			forward = append(forward, string(iter.Key())+"="+string(iter.Value()))
+		}
+		for iter.SeekToLast(); iter.Valid(); iter.Prev() {
+			backward = append([]string{string(iter.Key()) + "=" + string(iter.Value())}, backward...)
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatalf("iterator error = %v", err)
+		}
+		return forward, backward
+	}
+
+	tests := []struct {
+		name     string
+		readOpts *ReadOptions
+		want     []string
+	}{
+		{"Latest", nil, []string{"a=a0,a1,a2", "b=b1,b2,b3", "c=c2", "d=d1", "e=e0"}},
+		{"Snapshot", &ReadOptions{Snapshot: snapshot}, []string{"a=a0,a1", "b=b1", "d=d0", "e=e0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forward, backward := scan(tt.readOpts)
+			if !slices.Equal(forward, tt.want) {
+				t.Errorf("forward = %q, want %q", forward, tt.want)
+			}
+			if !slices.Equal(backward, tt.want) {
+				t.Errorf("backward = %q, want %q", backward, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package rockyardkv
+
+// metrics.go implements WriteMetrics, which exports Options.Statistics and
+// the integer DB properties in the Prometheus text exposition format.
+//
+// Metric names are the RocksDB names without the "rocksdb." prefix, with
+// dots and dashes turned into underscores and a "rockyardkv_" prefix:
+// rocksdb.block.cache.hit becomes rockyardkv_block_cache_hit.
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// metricsNamespace prefixes every exported metric name.
+const metricsNamespace = "rockyardkv_"
+
+// metricsProperties are the integer properties exported as gauges.
+var metricsProperties = []string{
+	PropertyNumImmutableMemTable,
+	PropertyMemTableFlushPending,
+	PropertyCurSizeActiveMemTable,
+	PropertyCurSizeAllMemTables,
+	PropertyNumEntriesActiveMemTable,
+	PropertyNumDeletesActiveMemTable,
+	PropertyCompactionPending,
+	PropertyNumRunningFlushes,
+	PropertyNumRunningCompactions,
+	PropertyNumSnapshots,
+	PropertyEstimateNumKeys,
+	PropertyEstimateLiveDataSize,
+	PropertyTotalSstFilesSize,
+	PropertyLiveSstFilesSize,
+	PropertyBackgroundErrors,
+	PropertyNumLiveVersions,
+}
+
+// metricName converts a RocksDB ticker, histogram or property name into a
+// Prometheus metric name.
+func metricName(name string) string {
+	name = strings.TrimPrefix(name, "rocksdb.")
+	return metricsNamespace + strings.NewReplacer(".", "_", "-", "_").Replace(name)
+}
+
+// WriteMetrics writes the tickers and histograms of Options.Statistics and
+// the integer properties of the database to w in the Prometheus text
+// exposition format, for serving from an HTTP metrics handler. Tickers are
+// counters, histograms are summaries with a count and a sum, and properties
+// are gauges. Without Statistics only the properties are written.
+func (db *dbImpl) WriteMetrics(w io.Writer) error {
+	db.mu.RLock()
+	closed := db.closed
+	db.mu.RUnlock()
+	if closed {
+		return ErrDBClosed
+	}
+
+	var b strings.Builder
+	if s := db.options.Statistics; s != nil {
+		for ticker := range TickerEnumMax {
+			writeMetric(&b, ticker.String(), "counter", s.GetTickerCount(ticker))
+		}
+		for histogram := range HistogramEnumMax {
+			data := s.GetHistogramData(histogram)
+			name := metricName(histogram.String())
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s summary\n", name, histogram, name)
+			fmt.Fprintf(&b, "%s_sum %d\n%s_count %d\n", name, data.Sum, name, data.Count)
+		}
+	}
+
+	for _, property := range metricsProperties {
+		if value, ok := db.GetIntProperty(property); ok {
+			writeMetric(&b, property, "gauge", value)
+		}
+	}
+
+	name := metricName(PropertyNumFilesAtLevelPrefix)
+	fmt.Fprintf(&b, "# HELP %s %s<N>\n# TYPE %s gauge\n", name, PropertyNumFilesAtLevelPrefix, name)
+	numLevels := 0
+	if v := db.versions.Current(); v != nil {
+		numLevels = v.NumLevels()
+	}
+	for level := range numLevels {
+		if value, ok := db.GetIntProperty(PropertyNumFilesAtLevelPrefix + strconv.Itoa(level)); ok {
+			fmt.Fprintf(&b, "%s{level=\"%d\"} %d\n", name, level, value)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMetric writes one unlabeled metric with its HELP and TYPE lines.
+func writeMetric(b *strings.Builder, rocksdbName, metricType string, value uint64) {
+	name := metricName(rocksdbName)
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, rocksdbName, name, metricType, name, value)
+}
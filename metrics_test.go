@@ -0,0 +1,111 @@
+package rockyardkv
+
+// metrics_test.go implements tests for the Prometheus metrics export.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	dir := t.TempDir()
+	stats := NewStatistics()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Statistics = stats
+	opts.BloomFilterBitsPerKey = 10
+	opts.NumLevels = 4
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for i := range 100 {
+		if err := database.Put(nil, fmt.Appendf(nil, "key%03d", i), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for i := range 100 {
+		if _, err := database.Get(nil, fmt.Appendf(nil, "key%03dx", i)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(key%03dx) = %v, want ErrNotFound", i, err)
+		}
+	}
+	stats.MeasureTime(HistogramDBGet, 10)
+
+	var out strings.Builder
+	if err := database.WriteMetrics(&out); err != nil {
+		t.Fatalf("WriteMetrics failed: %v", err)
+	}
+
+	// Every sample is "<name>[{labels}] <integer>", every metric has one TYPE
+	samples := make(map[string]uint64)
+	types := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name, _, _ = strings.Cut(name, " ")
+			if types[name] {
+				t.Errorf("metric %s has more than one TYPE line", name)
+			}
+			types[name] = true
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("malformed sample %q", line)
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			t.Fatalf("sample %q has a non-integer value", line)
+		}
+		samples[name] = n
+	}
+
+	for name, want := range map[string]uint64{
+		"rockyardkv_block_cache_hit":               0,
+		"rockyardkv_bloom_filter_useful":           stats.GetTickerCount(TickerBloomFilterUseful),
+		"rockyardkv_db_get_micros_sum":             10,
+		"rockyardkv_db_get_micros_count":           1,
+		"rockyardkv_num_snapshots":                 0,
+		`rockyardkv_num_files_at_level{level="0"}`: 1,
+		`rockyardkv_num_files_at_level{level="1"}`: 0,
+		`rockyardkv_num_files_at_level{level="3"}`: 0,
+	} {
+		got, ok := samples[name]
+		if !ok {
+			t.Errorf("metric %s missing from output:\n%s", name, out.String())
+			continue
+		}
+		if got != want {
+			t.Errorf("%s = %d, want %d", name, got, want)
+		}
+	}
+	if _, ok := samples[`rockyardkv_num_files_at_level{level="4"}`]; ok {
+		t.Error("metric for level 4 present, want only the NumLevels=4 levels")
+	}
+	if samples["rockyardkv_bloom_filter_useful"] == 0 {
+		t.Error("rockyardkv_bloom_filter_useful = 0, want the lookups the filter ruled out")
+	}
+	if samples["rockyardkv_total_sst_files_size"] == 0 {
+		t.Error("rockyardkv_total_sst_files_size = 0, want the flushed SST's size")
+	}
+
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := database.WriteMetrics(io.Discard); !errors.Is(err, ErrDBClosed) {
+		t.Errorf("WriteMetrics after Close = %v, want ErrDBClosed", err)
+	}
+}
@@ -329,6 +329,66 @@ func TestMultiGetAfterFlush(t *testing.T) {
 	}
 }
 
+// TestMultiGetAsyncIO verifies that MultiGet with AsyncIO returns the same
+// results as without, across overwrites, deletions, range deletions and
+// merges spread over several files.
+func TestMultiGetAsyncIO(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Level0FileNumCompactionTrigger = 8 // keep the files in L0
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+	db, cleanup := createTestDB(t, opts)
+	defer cleanup()
+
+	var snapshot *Snapshot
+	for file := range 4 {
+		for i := file; i < 200; i += 2 {
+			key := fmt.Appendf(nil, "key%03d", i)
+			var err error
+			switch {
+			case i%7 == file:
+				err = db.Delete(nil, key)
+			case i%5 == 0:
+				err = db.Merge(nil, key, fmt.Appendf(nil, "m%d", file))
+			default:
+				err = db.Put(nil, key, fmt.Appendf(nil, "v%d-%d", file, i))
+			}
+			if err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+		}
+		if file == 1 {
+			if err := db.DeleteRange(nil, []byte("key050"), []byte("key070")); err != nil {
+				t.Fatalf("DeleteRange failed: %v", err)
+			}
+			snapshot = db.GetSnapshot()
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	defer db.ReleaseSnapshot(snapshot)
+	for i := 0; i < 200; i += 9 {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%03d", i), []byte("mem")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	var keys [][]byte
+	for i := range 210 {
+		keys = append(keys, fmt.Appendf(nil, "key%03d", i))
+	}
+	for _, snap := range []*Snapshot{nil, snapshot} {
+		wantValues, wantErrs := db.MultiGet(&ReadOptions{Snapshot: snap}, keys)
+		values, errs := db.MultiGet(&ReadOptions{Snapshot: snap, AsyncIO: true}, keys)
+		for i, key := range keys {
+			if !errors.Is(errs[i], wantErrs[i]) || !bytes.Equal(values[i], wantValues[i]) {
+				t.Errorf("snapshot=%v: MultiGet(%s) = %q, %v with AsyncIO, want %q, %v",
+					snap != nil, key, values[i], errs[i], wantValues[i], wantErrs[i])
+			}
+		}
+	}
+}
+
 // =============================================================================
 // SingleDelete Tests (matching C++ RocksDB db/db_basic_test.cc SingleDelete tests)
 // =============================================================================
@@ -15,6 +15,21 @@ import (
 // This allows users to pass their own logger implementation.
 type Logger = logging.Logger
 
+// InfoLogger is an alias for the logging.InfoLogger interface, a Logger
+// that logs at a given level and whose level can be changed.
+type InfoLogger = logging.InfoLogger
+
+// InfoLogLevel is the level of an info log message.
+type InfoLogLevel = logging.Level
+
+// Info log levels, from least to most verbose.
+const (
+	InfoLogLevelError = logging.LevelError
+	InfoLogLevelWarn  = logging.LevelWarn
+	InfoLogLevelInfo  = logging.LevelInfo
+	InfoLogLevelDebug = logging.LevelDebug
+)
+
 // CompressionType is an alias for the compression type.
 type CompressionType = compression.Type
 
@@ -148,12 +163,26 @@ type Options struct {
 	ErrorIfExists bool
 
 	// ParanoidChecks enables additional checks for data integrity.
+	// On open, the MANIFEST is cross-checked against the files on disk:
+	// every referenced SST must exist with the recorded size, files within
+	// a non-L0 level must not overlap, and CURRENT must name the recovered
+	// MANIFEST. Any violation fails the open with ErrCorruption.
+	// Default: true
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (paranoid_checks)
 	ParanoidChecks bool
 
 	// FS is the filesystem implementation to use.
 	// If nil, the OS filesystem is used.
 	FS vfs.FS
 
+	// Env provides the clock and background thread pools.
+	// TTL expiry, FIFO TTL compaction, rate limiting, and write stalls all
+	// consult this clock, so tests can inject a MockEnv to control time.
+	// If nil, DefaultEnv() is used.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (DBOptions::env)
+	Env Env
+
 	// Comparator defines the order of keys in the database.
 	// If nil, a default bytewise comparator is used.
 	Comparator Comparator
@@ -166,10 +195,167 @@ type Options struct {
 	// Default: 2
 	MaxWriteBufferNumber int
 
+	// MinWriteBufferNumberToMerge is the number of immutable memtables that
+	// a background flush waits for. They are merged into a single L0 file,
+	// so a larger value writes fewer, larger L0 files. It is capped at
+	// MaxWriteBufferNumber-1. An explicit Flush that waits writes all
+	// immutable memtables regardless.
+	// Default: 1
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+	MinWriteBufferNumberToMerge int
+
+	// MaxWriteBufferSizeToMaintain is the total size of flushed memtables to
+	// keep in memory. Point lookups, including those at a snapshot taken
+	// before the flush, search the kept memtables before the SST files.
+	// When a flush adds a memtable, the oldest kept ones are freed until the
+	// total fits. Applies to the default column family.
+	// 0 frees memtables as soon as they are flushed.
+	// Default: 0
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+	MaxWriteBufferSizeToMaintain int64
+
+	// MemtablePrefixBloomSizeRatio, if positive, makes each memtable keep a
+	// bloom filter of WriteBufferSize * MemtablePrefixBloomSizeRatio bytes
+	// (the ratio is capped at 0.25). The filter holds the prefixes given by
+	// PrefixExtractor and, with MemtableWholeKeyFiltering, whole keys, so that
+	// point lookups of absent keys skip the memtable search.
+	// Default: 0 (no memtable bloom filter)
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (memtable_prefix_bloom_size_ratio)
+	MemtablePrefixBloomSizeRatio float64
+
+	// MemtableWholeKeyFiltering adds whole keys to the memtable bloom filter.
+	// Without it the filter only helps when PrefixExtractor is set.
+	// Default: false
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (memtable_whole_key_filtering)
+	MemtableWholeKeyFiltering bool
+
+	// MemTableSkipListBranchingFactor is the fan-out of the memtable
+	// skiplist: on average one node in this many is promoted to the next
+	// level. A larger factor makes inserts cheaper and nodes smaller, at the
+	// cost of more comparisons per search. Must be at least 2.
+	// Default: 0 (4)
+	//
+	// Reference: RocksDB v10.7.5 memtable/skiplist.h (branching_factor)
+	MemTableSkipListBranchingFactor int
+
+	// MemTableSkipListMaxHeight is the maximum number of levels of the
+	// memtable skiplist, between 1 and 32. Searches stay logarithmic up to
+	// about MemTableSkipListBranchingFactor^MemTableSkipListMaxHeight
+	// entries per memtable.
+	// Default: 0 (12)
+	//
+	// Reference: RocksDB v10.7.5 memtable/skiplist.h (max_height)
+	MemTableSkipListMaxHeight int
+
+	// MemtableFactory selects the data structure that holds the entries of
+	// memtables, e.g. VectorMemTableFactory for sorted bulk loads.
+	// Default: nil (skiplist)
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (memtable_factory)
+	MemtableFactory MemTableRepFactory
+
 	// MaxOpenFiles is the maximum number of SST files to keep open.
+	// Table readers are held in an LRU; evicting a reader closes its file
+	// handle and drops its index and filter, and the reader is reopened on
+	// the next access. -1 keeps every reader open.
 	// Default: 1000
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (max_open_files)
 	MaxOpenFiles int
 
+	// MaxFileOpeningThreads is the number of goroutines used to open table
+	// files in parallel when PinTopLevelIndexAndFilter warms the table cache.
+	// Default: 16
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (max_file_opening_threads)
+	MaxFileOpeningThreads int
+
+	// RecycleLogFileNum is the number of obsolete WAL files kept for reuse.
+	// A new WAL is created by renaming and overwriting one of them, which
+	// avoids allocating a fresh file; WAL files are written in the
+	// recyclable record format so that recovery stops at the records left
+	// over from the file's previous use. Obsolete WAL files beyond the pool
+	// are deleted. 0 disables recycling and keeps every WAL file.
+	// Default: 0
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (recycle_log_file_num)
+	RecycleLogFileNum int
+
+	// WALTTLSeconds enables WAL archival: obsolete WAL files are moved to
+	// the archive/ subdirectory, where GetUpdatesSince can still read them,
+	// and deleted once they are older than this many seconds. Archival
+	// disables RecycleLogFileNum. 0 means archived WAL files do not expire.
+	// Default: 0
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (WAL_ttl_seconds)
+	WALTTLSeconds uint64
+
+	// WALSizeLimitMB enables WAL archival like WALTTLSeconds, deleting the
+	// oldest archived WAL files while the archive is larger than this many
+	// megabytes. 0 means the archive size is not limited.
+	// Default: 0
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (WAL_size_limit_MB)
+	WALSizeLimitMB uint64
+
+	// ManualWalFlush keeps WAL records in an in-memory buffer instead of
+	// writing each one to the WAL file. Buffered records reach the file only
+	// when FlushWAL or SyncWAL is called, a write with WriteOptions.Sync is
+	// made, or the database is closed; records still buffered when the
+	// process crashes are lost, even though their writes succeeded.
+	// Default: false
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (manual_wal_flush)
+	ManualWalFlush bool
+
+	// TwoWriteQueues writes batches that have no memtable entries, such as
+	// the commit and rollback markers of write-prepared transactions, through
+	// a second write queue. They only append to the WAL, so they do not wait
+	// behind the memtable writes of regular writes and prepares.
+	// Default: false
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (two_write_queues)
+	TwoWriteQueues bool
+
+	// PinTopLevelIndexAndFilter opens the table readers of L0 and the base
+	// level during Open, loading their index and filter blocks, and pins
+	// them in the table cache. This makes Open slower but spares the first
+	// reads from loading those blocks.
+	// Default: false
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/table.h (pin_top_level_index_and_filter)
+	PinTopLevelIndexAndFilter bool
+
+	// BlockCache caches the SST data blocks read by Get and iterators, so
+	// that repeated reads of the same blocks skip the file. Compaction reads
+	// use the cache but do not fill it. A BlockCache must not be shared
+	// between DBs. See DB.WarmBlockCache to fill it after a restart.
+	// Default: nil (disabled)
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/table.h (BlockBasedTableOptions::block_cache)
+	BlockCache Cache
+
+	// VerifyBlockCacheEntries keeps a checksum with every block in
+	// BlockCache and verifies it on each cache hit. Blocks are otherwise
+	// only verified when read from the file, so memory corruption of a
+	// cached block would go unnoticed; with this option the read fails with
+	// a checksum mismatch instead of returning wrong data. The option must
+	// not change while BlockCache holds blocks of the database.
+	// Default: false
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (block_protection_bytes_per_key)
+	VerifyBlockCacheEntries bool
+
+	// VerifyCompression decompresses every block that flush and compaction
+	// compress and fails the job unless the result matches the original,
+	// guarding against compressor bugs at the cost of write throughput.
+	// Default: false
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/table.h (BlockBasedTableOptions::verify_compression)
+	VerifyCompression bool
+
 	// BlockSize is the approximate size of data blocks within SST files.
 	// Default: 4KB
 	BlockSize int
@@ -190,6 +376,14 @@ type Options struct {
 	// If nil, Merge operations will return an error.
 	MergeOperator MergeOperator
 
+	// MaxSuccessiveMerges bounds the merge operands of a key in the active
+	// memtable: a Merge that finds this many operands on top of the key
+	// reads the current value, applies the new operand, and writes the
+	// result as a plain value instead.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (max_successive_merges)
+	// Default: 0 (unbounded)
+	MaxSuccessiveMerges int
+
 	// PrefixExtractor extracts prefixes from keys for prefix-based operations.
 	// When set, bloom filters are built for prefixes instead of whole keys,
 	// and prefix seek can be used for efficient iteration within a prefix.
@@ -201,14 +395,66 @@ type Options struct {
 	// Default: 4
 	Level0FileNumCompactionTrigger int
 
+	// NumLevels is the number of levels in the LSM tree, from 1 to 7.
+	// Leveled and universal compaction need at least 2. A database can be
+	// reopened with more levels, but not with fewer than it has files in.
+	// 0 means 7.
+	// Default: 7
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+	NumLevels int
+
 	// MaxBytesForLevelBase is the maximum total data size for level-1.
 	// Default: 256MB
 	MaxBytesForLevelBase int64
 
+	// MaxBytesForLevelMultiplier scales the maximum size of each level below
+	// level-1: level L holds up to
+	// MaxBytesForLevelBase * MaxBytesForLevelMultiplier^(L-1) bytes. A level
+	// over its size is compacted into the next one.
+	// Default: 10
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+	MaxBytesForLevelMultiplier float64
+
+	// MaxCompactionBytes bounds the total input size of a single leveled
+	// compaction. When the candidate inputs would exceed it, the picker
+	// compacts fewer files and leaves the rest to later compactions, which
+	// bounds compaction duration and output size. At least one file is
+	// always compacted. Compaction output files are also cut once they
+	// overlap more than this many bytes of the level below the output
+	// level, which bounds the cost of compacting them further down.
+	// 0 means 25 times TargetFileSizeBase.
+	// Default: 0
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+	MaxCompactionBytes uint64
+
+	// TargetFileSizeBase is the size at which leveled compaction cuts its
+	// output files for level-1. Files are only cut between user keys, so a
+	// file can exceed the target by the size of one key's versions.
+	// Default: 64MB
+	TargetFileSizeBase int64
+
+	// TargetFileSizeMultiplier scales the target file size for each level
+	// below level-1: files for level L target
+	// TargetFileSizeBase * TargetFileSizeMultiplier^(L-1).
+	// Default: 1
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+	TargetFileSizeMultiplier int
+
 	// BloomFilterBitsPerKey is the number of bits per key for bloom filters.
 	// 0 disables bloom filters. Default: 10
 	BloomFilterBitsPerKey int
 
+	// OptimizeFiltersForHits omits filter blocks from the SST files written
+	// to the bottommost level. Those files hold most of the data, so this
+	// saves most of the filter memory and write cost, at the price of an
+	// index search for every lookup of a missing key that reaches them.
+	// Suits workloads whose lookups mostly find their keys. Only the filter
+	// is omitted: the data blocks of those files keep their checksums.
+	// Applies to the default column family.
+	// Default: false
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h
+	OptimizeFiltersForHits bool
+
 	// Level0SlowdownWritesTrigger is the number of L0 files that triggers
 	// write slowdown. When L0 file count exceeds this, writes are delayed.
 	// Default: 20
@@ -255,6 +501,52 @@ type Options struct {
 	// Default: NoCompression
 	Compression CompressionType
 
+	// EnableBlobFiles separates large values from the LSM tree. Flush writes
+	// values of at least MinBlobSize bytes to blob files and stores only a
+	// blob reference in the SST; Get and iterators resolve the reference
+	// transparently. Compaction carries blob references over unchanged
+	// unless EnableBlobGarbageCollection is set.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (enable_blob_files)
+	// Default: false
+	EnableBlobFiles bool
+
+	// MinBlobSize is the smallest value, in bytes, written to a blob file
+	// when EnableBlobFiles is set. Smaller values stay inline in the SST.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (min_blob_size)
+	// Default: 0
+	MinBlobSize uint64
+
+	// BlobFileSize is the size at which a flush starts a new blob file.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (blob_file_size)
+	// Default: 256MB
+	BlobFileSize uint64
+
+	// BlobCompressionType is the compression algorithm for blob values.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (blob_compression_type)
+	// Default: NoCompression
+	BlobCompressionType CompressionType
+
+	// BlobCache caches recently read blob values. Blob reads bypass the SST
+	// block cache, so without it every read of a separated value goes to
+	// the blob file. A BlobCache must not be shared between DBs.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (blob_cache)
+	// Default: nil (disabled)
+	BlobCache Cache
+
+	// EnableBlobGarbageCollection makes compaction relocate the live blobs of
+	// the oldest blob files to new blob files. Once no SST references an old
+	// blob file any more, it is deleted.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (enable_blob_garbage_collection)
+	// Default: false
+	EnableBlobGarbageCollection bool
+
+	// BlobGarbageCollectionAgeCutoff is the fraction of blob files, oldest
+	// first, whose blobs are relocated by compaction when
+	// EnableBlobGarbageCollection is set.
+	// Reference: RocksDB v10.7.5 include/rocksdb/advanced_options.h (blob_garbage_collection_age_cutoff)
+	// Default: 0.25
+	BlobGarbageCollectionAgeCutoff float64
+
 	// MaxSubcompactions is the maximum number of subcompactions per compaction job.
 	// Subcompactions allow parallel compaction within a single job by dividing
 	// the key range. Higher values can improve compaction throughput on multi-core
@@ -262,6 +554,37 @@ type Options struct {
 	// Default: 1 (no parallel subcompaction)
 	MaxSubcompactions int
 
+	// MaxBackgroundJobs is the maximum number of concurrent background jobs
+	// (flushes and compactions combined). Unless MaxBackgroundFlushes or
+	// MaxBackgroundCompactions is set, a quarter of the jobs (at least one)
+	// go to flushes and the rest to compactions.
+	// Can be changed at runtime via SetDBOptions("max_background_jobs").
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (max_background_jobs)
+	// Default: 2
+	MaxBackgroundJobs int
+
+	// MaxBackgroundFlushes is the maximum number of concurrent flush jobs.
+	// Flushes run in the Env's HIGH priority pool, separate from compactions,
+	// so a long compaction cannot delay a flush.
+	// -1 derives the limit from MaxBackgroundJobs.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (max_background_flushes)
+	// Default: -1
+	MaxBackgroundFlushes int
+
+	// MaxBackgroundCompactions is the maximum number of concurrent compaction
+	// jobs. Compactions run in the Env's LOW priority pool.
+	// -1 derives the limit from MaxBackgroundJobs.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (max_background_compactions)
+	// Default: -1
+	MaxBackgroundCompactions int
+
+	// VerifyChecksumsInCompaction verifies the checksum of every input block
+	// read by compaction. A mismatch aborts the compaction and sets a
+	// background error instead of copying corrupt data into new SST files.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (verify_checksums_in_compaction)
+	// Default: true
+	VerifyChecksumsInCompaction bool
+
 	// UseDirectReads enables O_DIRECT for reading data.
 	// This bypasses the OS page cache and reads directly from disk.
 	// Beneficial for reducing memory pressure and cache pollution.
@@ -277,8 +600,54 @@ type Options struct {
 	UseDirectIOForFlushAndCompaction bool
 
 	// Logger is the logger for database operations.
-	// If nil, a default logger writing to stderr is used.
+	// If nil, Open logs at InfoLogLevelInfo to the LOG file in the database
+	// directory, and falls back to stderr if LOG cannot be created.
+	// Read-only and secondary instances log to stderr.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (info_log)
 	Logger Logger
+
+	// MaxLogFileSize is the size at which the LOG file is rolled over to
+	// LOG.old.<timestamp>. Only used by the default LOG file logger.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (max_log_file_size)
+	// Default: 0 (never roll by size)
+	MaxLogFileSize int64
+
+	// LogFileTimeToRoll is how long the LOG file is written before it is
+	// rolled over. Only used by the default LOG file logger.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (log_file_time_to_roll)
+	// Default: 0 (never roll by time)
+	LogFileTimeToRoll time.Duration
+
+	// KeepLogFileNum is the number of rolled LOG files to keep; older ones
+	// are deleted when LOG rolls. 0 keeps all of them.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (keep_log_file_num)
+	// Default: 1000
+	KeepLogFileNum int
+
+	// StatsDumpPeriodSec is how often, in seconds, the rocksdb.stats
+	// property is written to the info log. 0 disables the dumps.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (stats_dump_period_sec)
+	// Default: 600
+	StatsDumpPeriodSec uint
+
+	// Statistics, if set, collects the database's tickers, such as the
+	// bloom filter counters behind GetBloomFilterStats. See NewStatistics.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (statistics)
+	// Default: nil (not collected)
+	Statistics Statistics
+
+	// Listeners are notified of compactions as they begin and complete.
+	// Callbacks run on the compacting goroutine without DB locks held, and
+	// should return quickly.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (listeners)
+	// Default: nil
+	Listeners []EventListener
+
+	// DetectSnapshotLeaks logs a warning when a Snapshot is garbage
+	// collected without being released. The leaked snapshot still pins its
+	// sequence number; see GetSnapshotCount and GetAliveSnapshotSequences.
+	// Default: false
+	DetectSnapshotLeaks bool
 }
 
 // DefaultOptions returns a new Options with default values.
@@ -286,27 +655,43 @@ func DefaultOptions() *Options {
 	return &Options{
 		CreateIfMissing:                  false,
 		ErrorIfExists:                    false,
-		ParanoidChecks:                   false,
+		ParanoidChecks:                   true,
 		FS:                               nil,              // Will use vfs.Default()
+		Env:                              nil,              // Will use DefaultEnv()
 		Comparator:                       nil,              // Will use BytewiseComparator
 		WriteBufferSize:                  64 * 1024 * 1024, // 64MB
 		MaxWriteBufferNumber:             2,
+		MinWriteBufferNumberToMerge:      1,
 		MaxOpenFiles:                     1000,
+		MaxFileOpeningThreads:            16,
+		PinTopLevelIndexAndFilter:        false,
 		BlockSize:                        4096,
 		BlockRestartInterval:             16,
 		ChecksumType:                     ChecksumTypeCRC32C,
 		FormatVersion:                    3,
 		Level0FileNumCompactionTrigger:   4,
+		NumLevels:                        7,
 		MaxBytesForLevelBase:             256 * 1024 * 1024, // 256MB
+		MaxBytesForLevelMultiplier:       10,
+		TargetFileSizeBase:               64 * 1024 * 1024, // 64MB
+		TargetFileSizeMultiplier:         1,
 		BloomFilterBitsPerKey:            10,
 		Level0SlowdownWritesTrigger:      20,
 		Level0StopWritesTrigger:          36,
 		DisableAutoCompactions:           false,
 		CompactionStyle:                  CompactionStyleLevel,
-		MaxSubcompactions:                1,     // Default: no parallel subcompaction
-		UseDirectReads:                   false, // Direct I/O disabled by default
+		BlobFileSize:                     256 * 1024 * 1024, // 256MB
+		BlobGarbageCollectionAgeCutoff:   0.25,              // Oldest quarter of blob files
+		MaxSubcompactions:                1,                 // Default: no parallel subcompaction
+		UseDirectReads:                   false,             // Direct I/O disabled by default
 		UseDirectIOForFlushAndCompaction: false,
-		Logger:                           nil, // Will use defaultLogger
+		VerifyChecksumsInCompaction:      true,
+		MaxBackgroundJobs:                2,
+		MaxBackgroundFlushes:             -1,  // Derived from MaxBackgroundJobs
+		MaxBackgroundCompactions:         -1,  // Derived from MaxBackgroundJobs
+		Logger:                           nil, // Will use the LOG file logger
+		KeepLogFileNum:                   1000,
+		StatsDumpPeriodSec:               600,
 	}
 }
 
@@ -360,6 +745,47 @@ type ReadOptions struct {
 	// IterateLowerBound sets a lower bound for iteration.
 	// The iterator will skip any key < this bound.
 	IterateLowerBound []byte
+
+	// ReadaheadSize, if non-zero, makes iterators read SST data blocks this
+	// many bytes ahead at a time during forward iteration, instead of one
+	// block per read. The readahead window doubles while the scan stays
+	// sequential, up to 8 times this size. Useful for long scans over data
+	// that is not cached.
+	//
+	// When zero, iterators read ahead automatically: after two sequential
+	// data block reads they start prefetching 8KB, doubling up to 256KB while
+	// the scan continues, and fall back to single-block reads after a
+	// non-sequential Seek.
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (ReadOptions::readahead_size)
+	ReadaheadSize uint64
+
+	// AsyncIO makes iterator seeks (Seek, SeekToFirst, SeekToLast) read
+	// the blocks of the SST files concurrently instead of one file after
+	// the other. This cuts the latency of seeks that touch many files,
+	// e.g. across L0 files and levels on slow storage, at the cost of a
+	// goroutine per file and seek. MultiGet likewise searches all files
+	// that may hold one of its keys concurrently before assembling the
+	// results, which may read files a synchronous lookup would have
+	// skipped. Results are the same either way.
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (ReadOptions::async_io)
+	AsyncIO bool
+
+	// BlobPrefetchSize, if non-zero, makes iterators read blob files this
+	// many bytes ahead during forward iteration, so that the values of
+	// neighbouring keys stored in the same blob file are fetched with a
+	// single read. Only applies when EnableBlobFiles is set.
+	BlobPrefetchSize uint64
+
+	// VerifyIteratorOrder makes iterators check, on every step, that the
+	// memtables and SST files they merge return keys in internal key order
+	// and that the entries they surface are visible at their snapshot. A
+	// violation invalidates the iterator and is reported by Error() instead
+	// of surfacing keys out of order. Intended for debugging.
+	//
+	// Reference: RocksDB v10.7.5 db/db_iter.cc (DBIter debug assertions)
+	VerifyIteratorOrder bool
 }
 
 // DefaultReadOptions returns ReadOptions with default values.
@@ -386,6 +812,20 @@ type WriteOptions struct {
 	// Use only when you can tolerate data loss in exchange for higher throughput.
 	// Call Flush() explicitly before shutdown to persist unflushed data.
 	DisableWAL bool
+
+	// ProtectionBytesPerKey enables per-entry protection info for the batch
+	// being written. Each entry carries a checksum of its key, value, operation
+	// type and column family. Batches created with NewWriteBatchWithProtection
+	// are protected from the moment entries are added and verified before the
+	// batch is appended to the WAL; other batches are protected when Write is
+	// called. Every entry is verified again as it is inserted into the
+	// memtable. A mismatch fails the write with ErrCorruption; found during
+	// the memtable insert, after the batch was logged, it also sets the
+	// background error.
+	// Supported values: 0 (disabled), 1, 2, 4, 8.
+	// Reference: RocksDB v10.7.5 include/rocksdb/options.h (WriteOptions::protection_bytes_per_key)
+	// Default: 0
+	ProtectionBytesPerKey int
 }
 
 // DefaultWriteOptions returns WriteOptions with default values.
@@ -398,7 +838,9 @@ func DefaultWriteOptions() *WriteOptions {
 
 // FlushOptions contains options for flush operations.
 type FlushOptions struct {
-	// Wait indicates whether to wait for the flush to complete.
+	// Wait indicates whether to wait for the flush to complete. Without it,
+	// Flush switches the memtable and returns; the flush runs in the Env's
+	// HIGH priority pool.
 	Wait bool
 
 	// AllowWriteStall indicates whether to allow write stalls.
@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aalhour/rockyardkv/internal/compression"
 	"github.com/aalhour/rockyardkv/internal/options"
@@ -75,16 +76,38 @@ func WriteOptionsFile(fs vfs.FS, dbPath string, opts *Options, fileNum uint64) e
 	fmt.Fprintf(w, "  level0_file_num_compaction_trigger=%d\n", opts.Level0FileNumCompactionTrigger)
 	fmt.Fprintf(w, "  level0_slowdown_writes_trigger=%d\n", opts.Level0SlowdownWritesTrigger)
 	fmt.Fprintf(w, "  level0_stop_writes_trigger=%d\n", opts.Level0StopWritesTrigger)
+	fmt.Fprintf(w, "  num_levels=%d\n", opts.NumLevels)
 	fmt.Fprintf(w, "  max_bytes_for_level_base=%d\n", opts.MaxBytesForLevelBase)
+	fmt.Fprintf(w, "  max_bytes_for_level_multiplier=%g\n", opts.MaxBytesForLevelMultiplier)
+	fmt.Fprintf(w, "  max_compaction_bytes=%d\n", opts.MaxCompactionBytes)
+	fmt.Fprintf(w, "  target_file_size_base=%d\n", opts.TargetFileSizeBase)
+	fmt.Fprintf(w, "  target_file_size_multiplier=%d\n", opts.TargetFileSizeMultiplier)
 	fmt.Fprintf(w, "  compression=%s\n", compressionTypeToString(opts.Compression))
 	fmt.Fprintf(w, "  compaction_style=%s\n", compactionStyleToString(opts.CompactionStyle))
 	fmt.Fprintf(w, "  max_subcompactions=%d\n", opts.MaxSubcompactions)
+	fmt.Fprintf(w, "  max_background_jobs=%d\n", opts.MaxBackgroundJobs)
+	fmt.Fprintf(w, "  max_background_flushes=%d\n", opts.MaxBackgroundFlushes)
+	fmt.Fprintf(w, "  max_background_compactions=%d\n", opts.MaxBackgroundCompactions)
+	fmt.Fprintf(w, "  max_file_opening_threads=%d\n", opts.MaxFileOpeningThreads)
+	fmt.Fprintf(w, "  recycle_log_file_num=%d\n", opts.RecycleLogFileNum)
+	fmt.Fprintf(w, "  WAL_ttl_seconds=%d\n", opts.WALTTLSeconds)
+	fmt.Fprintf(w, "  WAL_size_limit_MB=%d\n", opts.WALSizeLimitMB)
+	fmt.Fprintf(w, "  manual_wal_flush=%t\n", opts.ManualWalFlush)
+	fmt.Fprintf(w, "  two_write_queues=%t\n", opts.TwoWriteQueues)
+	fmt.Fprintf(w, "  max_log_file_size=%d\n", opts.MaxLogFileSize)
+	fmt.Fprintf(w, "  log_file_time_to_roll=%d\n", int64(opts.LogFileTimeToRoll/time.Second))
+	fmt.Fprintf(w, "  keep_log_file_num=%d\n", opts.KeepLogFileNum)
+	fmt.Fprintf(w, "  stats_dump_period_sec=%d\n", opts.StatsDumpPeriodSec)
 	fmt.Fprintln(w)
 
 	// Write default CF options
 	fmt.Fprintln(w, "[CFOptions \"default\"]")
 	fmt.Fprintf(w, "  write_buffer_size=%d\n", opts.WriteBufferSize)
 	fmt.Fprintf(w, "  compression=%s\n", compressionTypeToString(opts.Compression))
+	fmt.Fprintf(w, "  optimize_filters_for_hits=%t\n", opts.OptimizeFiltersForHits)
+	fmt.Fprintf(w, "  max_write_buffer_size_to_maintain=%d\n", opts.MaxWriteBufferSizeToMaintain)
+	fmt.Fprintf(w, "  min_write_buffer_number_to_merge=%d\n", opts.MinWriteBufferNumberToMerge)
+	fmt.Fprintf(w, "  max_successive_merges=%d\n", opts.MaxSuccessiveMerges)
 	fmt.Fprintln(w)
 
 	if err := w.Flush(); err != nil {
@@ -30,6 +30,10 @@ func TestWriteAndReadOptionsFile(t *testing.T) {
 	opts.Compression = compression.LZ4Compression
 	opts.CompactionStyle = CompactionStyleUniversal
 	opts.MaxSubcompactions = 4
+	opts.MaxBackgroundJobs = 8
+	opts.MaxBackgroundFlushes = 2
+	opts.MaxBackgroundCompactions = 3
+	opts.MaxFileOpeningThreads = 4
 
 	// Write options file
 	err = WriteOptionsFile(fs, dir, opts, 1)
@@ -69,6 +73,18 @@ func TestWriteAndReadOptionsFile(t *testing.T) {
 	if parsed.MaxSubcompactions != opts.MaxSubcompactions {
 		t.Errorf("MaxSubcompactions = %d, want %d", parsed.MaxSubcompactions, opts.MaxSubcompactions)
 	}
+	if parsed.MaxBackgroundJobs != opts.MaxBackgroundJobs {
+		t.Errorf("MaxBackgroundJobs = %d, want %d", parsed.MaxBackgroundJobs, opts.MaxBackgroundJobs)
+	}
+	if parsed.MaxBackgroundFlushes != opts.MaxBackgroundFlushes {
+		t.Errorf("MaxBackgroundFlushes = %d, want %d", parsed.MaxBackgroundFlushes, opts.MaxBackgroundFlushes)
+	}
+	if parsed.MaxBackgroundCompactions != opts.MaxBackgroundCompactions {
+		t.Errorf("MaxBackgroundCompactions = %d, want %d", parsed.MaxBackgroundCompactions, opts.MaxBackgroundCompactions)
+	}
+	if parsed.MaxFileOpeningThreads != opts.MaxFileOpeningThreads {
+		t.Errorf("MaxFileOpeningThreads = %d, want %d", parsed.MaxFileOpeningThreads, opts.MaxFileOpeningThreads)
+	}
 }
 
 func TestParseOptionsFile(t *testing.T) {
@@ -0,0 +1,95 @@
+package rockyardkv
+
+// perf_context.go implements PerfContext, a set of low-level performance
+// counters for diagnosing individual operations.
+//
+// Unlike RocksDB, whose PerfContext is thread-local, the counters here are
+// process-wide because Go has no thread-local storage. Read them around an
+// operation performed in isolation.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/perf_context.h
+//   - include/rocksdb/perf_level.h
+
+import "github.com/aalhour/rockyardkv/internal/perf"
+
+// PerfLevel controls which performance counters are collected.
+type PerfLevel int
+
+const (
+	// PerfLevelDisable disables all counters.
+	PerfLevelDisable PerfLevel = iota + 1
+
+	// PerfLevelEnableCount enables count-based counters.
+	PerfLevelEnableCount
+)
+
+// SetPerfLevel sets the performance counter level.
+func SetPerfLevel(level PerfLevel) {
+	perf.SetEnabled(level >= PerfLevelEnableCount)
+}
+
+// GetPerfLevel returns the current performance counter level.
+func GetPerfLevel() PerfLevel {
+	if perf.Enabled() {
+		return PerfLevelEnableCount
+	}
+	return PerfLevelDisable
+}
+
+// PerfContext is a snapshot of the performance counters.
+type PerfContext struct {
+	// BlockReadCount is the number of blocks read from disk.
+	BlockReadCount uint64
+
+	// BlockReadByte is the number of bytes read from disk for blocks.
+	BlockReadByte uint64
+
+	// IndexBlockReadCount is the number of index blocks read from disk.
+	IndexBlockReadCount uint64
+
+	// FilterBlockReadCount is the number of filter blocks read from disk.
+	FilterBlockReadCount uint64
+
+	// SeekOnMemtableCount is the number of seeks into memtables, by point
+	// lookups and iterators.
+	SeekOnMemtableCount uint64
+
+	// BloomMemtableHitCount is the number of memtable bloom filter probes
+	// that did not rule the key out.
+	BloomMemtableHitCount uint64
+
+	// BloomMemtableMissCount is the number of memtable bloom filter probes
+	// that ruled the key out, sparing a memtable seek.
+	BloomMemtableMissCount uint64
+
+	// BloomSstHitCount is the number of SST prefix bloom filter probes by
+	// iterator seeks that did not rule the prefix out.
+	BloomSstHitCount uint64
+
+	// BloomSstMissCount is the number of SST prefix bloom filter probes by
+	// iterator seeks that ruled the prefix out, so that the seek read no
+	// block of the file. RocksDB counts these as bloom_filter_useful.
+	BloomSstMissCount uint64
+}
+
+// GetPerfContext returns a snapshot of the current counters.
+func GetPerfContext() PerfContext {
+	c := perf.Global()
+	return PerfContext{
+		BlockReadCount:         c.BlockReadCount.Load(),
+		BlockReadByte:          c.BlockReadByte.Load(),
+		IndexBlockReadCount:    c.IndexBlockReadCount.Load(),
+		FilterBlockReadCount:   c.FilterBlockReadCount.Load(),
+		SeekOnMemtableCount:    c.SeekOnMemtableCount.Load(),
+		BloomMemtableHitCount:  c.BloomMemtableHitCount.Load(),
+		BloomMemtableMissCount: c.BloomMemtableMissCount.Load(),
+		BloomSstHitCount:       c.BloomSstHitCount.Load(),
+		BloomSstMissCount:      c.BloomSstMissCount.Load(),
+	}
+}
+
+// ResetPerfContext zeroes every counter.
+func ResetPerfContext() {
+	perf.Global().Reset()
+}
@@ -0,0 +1,257 @@
+package rockyardkv
+
+// perf_context_test.go implements tests for PerfContext.
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPerfContextCountsTableReads(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	// Disabled counters stay at zero.
+	SetPerfLevel(PerfLevelDisable)
+	ResetPerfContext()
+	impl := db.(*dbImpl)
+	impl.tableCache.Close()
+	if _, err := db.Get(nil, []byte("key")); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if pc := GetPerfContext(); pc != (PerfContext{}) {
+		t.Errorf("disabled PerfContext = %+v, want zero", pc)
+	}
+
+	SetPerfLevel(PerfLevelEnableCount)
+	defer SetPerfLevel(PerfLevelDisable)
+	if GetPerfLevel() != PerfLevelEnableCount {
+		t.Fatalf("GetPerfLevel = %d, want PerfLevelEnableCount", GetPerfLevel())
+	}
+
+	// Dropping the cached reader forces the table to be reopened.
+	impl.tableCache.Close()
+	ResetPerfContext()
+	if _, err := db.Get(nil, []byte("key")); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	pc := GetPerfContext()
+	if pc.IndexBlockReadCount != 1 {
+		t.Errorf("IndexBlockReadCount = %d, want 1", pc.IndexBlockReadCount)
+	}
+	if pc.FilterBlockReadCount != 1 {
+		t.Errorf("FilterBlockReadCount = %d, want 1", pc.FilterBlockReadCount)
+	}
+	if pc.BlockReadCount < 3 || pc.BlockReadByte == 0 {
+		t.Errorf("BlockReadCount = %d, BlockReadByte = %d; want index, filter and data reads",
+			pc.BlockReadCount, pc.BlockReadByte)
+	}
+
+	ResetPerfContext()
+	if pc := GetPerfContext(); pc != (PerfContext{}) {
+		t.Errorf("PerfContext after reset = %+v, want zero", pc)
+	}
+}
+
+func TestPerfContextMemtableBloom(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		configure func(*Options)
+		absent    string
+	}{
+		{"WholeKey", func(o *Options) { o.MemtableWholeKeyFiltering = true }, "user:0099"},
+		{"Prefix", func(o *Options) { o.PrefixExtractor = NewFixedPrefixExtractor(5) }, "other:0001"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			opts.MemtablePrefixBloomSizeRatio = 0.1
+			tc.configure(opts)
+
+			db, err := Open(t.TempDir(), opts)
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			defer db.Close()
+			for i := range 50 {
+				if err := db.Put(nil, fmt.Appendf(nil, "user:%04d", i), []byte("value")); err != nil {
+					t.Fatalf("Put error: %v", err)
+				}
+			}
+
+			SetPerfLevel(PerfLevelEnableCount)
+			defer SetPerfLevel(PerfLevelDisable)
+
+			// Present keys pass the filter and search the memtable
+			ResetPerfContext()
+			if v, err := db.Get(nil, []byte("user:0007")); err != nil || string(v) != "value" {
+				t.Fatalf("Get(user:0007) = %q, %v", v, err)
+			}
+			if pc := GetPerfContext(); pc.SeekOnMemtableCount != 1 || pc.BloomMemtableHitCount != 1 {
+				t.Errorf("present key: PerfContext = %+v, want one bloom hit and one memtable seek", pc)
+			}
+
+			// Absent keys are answered by the filter
+			ResetPerfContext()
+			if _, err := db.Get(nil, []byte(tc.absent)); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get(%s): expected ErrNotFound, got %v", tc.absent, err)
+			}
+			if pc := GetPerfContext(); pc.SeekOnMemtableCount != 0 || pc.BloomMemtableMissCount != 1 {
+				t.Errorf("absent key: PerfContext = %+v, want one bloom miss and no memtable seek", pc)
+			}
+
+			// Range deletions still apply to keys the filter rules out
+			if err := db.Put(nil, []byte("user:0100"), []byte("flushed")); err != nil {
+				t.Fatalf("Put error: %v", err)
+			}
+			if err := db.Flush(nil); err != nil {
+				t.Fatalf("Flush error: %v", err)
+			}
+			if err := db.DeleteRange(nil, []byte("user:0100"), []byte("user:0101")); err != nil {
+				t.Fatalf("DeleteRange error: %v", err)
+			}
+			if _, err := db.Get(nil, []byte("user:0100")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(user:0100) after DeleteRange: expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPerfContextNoMemtableBloom(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	SetPerfLevel(PerfLevelEnableCount)
+	defer SetPerfLevel(PerfLevelDisable)
+	ResetPerfContext()
+	if _, err := db.Get(nil, []byte("missing")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get: expected ErrNotFound, got %v", err)
+	}
+	if pc := GetPerfContext(); pc.SeekOnMemtableCount != 1 || pc.BloomMemtableMissCount != 0 {
+		t.Errorf("PerfContext = %+v, want one memtable seek and no bloom probe", pc)
+	}
+}
+
+func TestPerfContextSstPrefixBloom(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.PrefixExtractor = NewFixedPrefixExtractor(5)
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+	for i := range 50 {
+		if err := db.Put(nil, fmt.Appendf(nil, "user:%04d", i), []byte("value")); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	SetPerfLevel(PerfLevelEnableCount)
+	defer SetPerfLevel(PerfLevelDisable)
+
+	seek := func(readOpts *ReadOptions, target string) (PerfContext, int) {
+		t.Helper()
+		iter := db.NewIterator(readOpts)
+		defer iter.Close()
+		ResetPerfContext()
+		n := 0
+		for iter.Seek([]byte(target)); iter.Valid(); iter.Next() {
+			n++
+		}
+		if err := iter.Error(); err != nil {
+			t.Fatalf("iterator error: %v", err)
+		}
+		return GetPerfContext(), n
+	}
+
+	// An absent prefix is answered by the filter without reading a block
+	pc, n := seek(&ReadOptions{PrefixSameAsStart: true}, "other:0001")
+	if n != 0 {
+		t.Errorf("Seek(other:0001) found %d keys, want 0", n)
+	}
+	if pc.BloomSstMissCount != 1 || pc.BloomSstHitCount != 0 || pc.BlockReadCount != 0 {
+		t.Errorf("absent prefix: PerfContext = %+v, want one SST bloom miss and no block read", pc)
+	}
+
+	// A present prefix passes the filter and is read
+	pc, n = seek(&ReadOptions{PrefixSameAsStart: true}, "user:0040")
+	if n != 10 {
+		t.Errorf("Seek(user:0040) found %d keys, want 10", n)
+	}
+	if pc.BloomSstHitCount != 1 || pc.BloomSstMissCount != 0 {
+		t.Errorf("present prefix: PerfContext = %+v, want one SST bloom hit", pc)
+	}
+
+	// Total order seeks and seeks that may leave the prefix skip the filter
+	for _, readOpts := range []*ReadOptions{
+		{PrefixSameAsStart: true, TotalOrderSeek: true},
+		{},
+	} {
+		pc, _ := seek(readOpts, "other:0001")
+		if pc.BloomSstHitCount != 0 || pc.BloomSstMissCount != 0 {
+			t.Errorf("ReadOptions %+v: PerfContext = %+v, want no SST bloom probe", readOpts, pc)
+		}
+	}
+}
+
+func TestPerfContextSstBloomSkipsPointLookups(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.BloomFilterBitsPerKey = 10
+	opts.Statistics = NewStatistics()
+	db, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer db.Close()
+	for i := range 50 {
+		if err := db.Put(nil, fmt.Appendf(nil, "key%04d", i), []byte("value")); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	SetPerfLevel(PerfLevelEnableCount)
+	defer SetPerfLevel(PerfLevelDisable)
+
+	// The whole-key filter rules the key out, which statistics count; the
+	// BloomSst perf counters are for iterator seeks only
+	ResetPerfContext()
+	if _, err := db.Get(nil, []byte("key0025x")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(key0025x): expected ErrNotFound, got %v", err)
+	}
+	if useful := opts.Statistics.GetTickerCount(TickerBloomFilterUseful); useful == 0 {
+		t.Fatal("Get(key0025x) was not ruled out by the SST filter")
+	}
+	if pc := GetPerfContext(); pc.BloomSstHitCount != 0 || pc.BloomSstMissCount != 0 {
+		t.Errorf("Get: PerfContext = %+v, want no SST bloom probe counted", pc)
+	}
+}
@@ -31,11 +31,25 @@ var (
 
 	// ErrWriteConflict is returned when a key was modified after the transaction's snapshot.
 	ErrWriteConflict = errors.New("db: write conflict - key modified after snapshot")
+
+	// ErrTransactionNameExists is returned when naming a transaction with the
+	// name of another one not finished yet.
+	ErrTransactionNameExists = errors.New("db: transaction name already in use")
+
+	// ErrTransactionUnnamed is returned when preparing a transaction that has
+	// not been named.
+	ErrTransactionUnnamed = errors.New("db: transaction must be named before prepare")
+
+	// ErrTransactionWALDisabled is returned when preparing a transaction, or
+	// rolling back a prepared one, with the WAL disabled.
+	ErrTransactionWALDisabled = errors.New("db: two-phase commit requires the WAL")
 )
 
 // PessimisticTransactionOptions configures a pessimistic transaction.
 type PessimisticTransactionOptions struct {
 	// SetSnapshot determines if the transaction should set a snapshot at creation.
+	// Without one, writes are not validated against a snapshot, though Get
+	// reads from one pinned by the first Get.
 	SetSnapshot bool
 
 	// LockTimeout is the timeout for acquiring locks.
@@ -87,9 +101,15 @@ type PessimisticTransaction struct {
 	// Write batch for transaction writes
 	writeBatch *batch.WriteBatch
 
+	// Writes made at commit only; see GetCommitTimeWriteBatch
+	commitTimeBatch *WriteBatch
+
 	// Snapshot for consistent reads
 	snapshot *Snapshot
 
+	// Snapshot pinned by the first Get without one; used for reads only
+	readSnap *Snapshot
+
 	// Locks held by this transaction
 	lockedKeys map[string]LockType
 
@@ -107,9 +127,13 @@ type PessimisticTransaction struct {
 	// Write options
 	writeOpts *WriteOptions
 
+	// Name set by SetName, required by Prepare
+	name string
+
 	// Transaction state
-	closed  bool
-	expired bool
+	closed   bool
+	expired  bool
+	prepared bool // the writes are in the WAL, waiting for Commit or Rollback
 
 	// Expiration timer
 	expirationTime time.Time
@@ -150,6 +174,12 @@ func (txn *PessimisticTransaction) Put(key, value []byte) error {
 
 // PutCF acquires an exclusive lock and sets the value in the specified column family.
 func (txn *PessimisticTransaction) PutCF(cf ColumnFamilyHandle, key, value []byte) error {
+	return txn.put(cf, key, key, value)
+}
+
+// put locks lockKey and writes value under key, which differ only when key
+// carries a user-defined timestamp.
+func (txn *PessimisticTransaction) put(cf ColumnFamilyHandle, lockKey, key, value []byte) error {
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
@@ -161,16 +191,20 @@ func (txn *PessimisticTransaction) PutCF(cf ColumnFamilyHandle, key, value []byt
 		return ErrTransactionReadOnly
 	}
 
+	if txn.prepared {
+		return ErrTxnAlreadyPrepared
+	}
+
 	// Acquire exclusive lock
-	if err := txn.tryLock(key, LockTypeExclusive); err != nil {
+	if err := txn.tryLock(lockKey, LockTypeExclusive); err != nil {
 		return err
 	}
 
 	// Validate that the key hasn't been modified since our snapshot
 	if err := txn.validateSnapshot(key); err != nil {
 		// Unlock the key we just locked since validation failed
-		_ = txn.txnDB.lockManager.Unlock(txn.id, key)
-		delete(txn.lockedKeys, string(key))
+		_ = txn.txnDB.lockManager.Unlock(txn.id, lockKey)
+		delete(txn.lockedKeys, string(lockKey))
 		return err
 	}
 
@@ -220,9 +254,7 @@ func (txn *PessimisticTransaction) GetCF(cf ColumnFamilyHandle, key []byte) ([]b
 
 	// Read from database using snapshot
 	readOpts := DefaultReadOptions()
-	if txn.snapshot != nil {
-		readOpts.Snapshot = txn.snapshot
-	}
+	readOpts.Snapshot = txn.pinReadSnapshot()
 
 	if cf == nil {
 		return txn.txnDB.db.Get(readOpts, key)
@@ -306,6 +338,10 @@ func (txn *PessimisticTransaction) DeleteCF(cf ColumnFamilyHandle, key []byte) e
 		return ErrTransactionReadOnly
 	}
 
+	if txn.prepared {
+		return ErrTxnAlreadyPrepared
+	}
+
 	// Acquire exclusive lock
 	if err := txn.tryLock(key, LockTypeExclusive); err != nil {
 		return err
@@ -334,7 +370,144 @@ func (txn *PessimisticTransaction) DeleteCF(cf ColumnFamilyHandle, key []byte) e
 	return nil
 }
 
-// Commit applies the transaction and releases all locks.
+// DeleteRange locks the keys in [begin, end) and removes them from the
+// specified column family at commit. Until the transaction ends, other
+// transactions wait to lock keys in the range, as for the keys it wrote;
+// keys in the range they already hold are waited for. With a snapshot, a
+// range whose keys or values changed since fails with ErrWriteConflict.
+// An empty range, with begin not before end, is ErrTransactionInvalidRange.
+func (txn *PessimisticTransaction) DeleteRange(cf ColumnFamilyHandle, begin, end []byte) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if err := txn.checkState(); err != nil {
+		return err
+	}
+
+	if txn.opts.ReadOnly {
+		return ErrTransactionReadOnly
+	}
+
+	if txn.prepared {
+		return ErrTxnAlreadyPrepared
+	}
+
+	if txn.txnDB.db.cmp.Compare(begin, end) >= 0 {
+		return ErrTransactionInvalidRange
+	}
+
+	r, err := txn.txnDB.lockRange(txn.id, begin, end, txn.opts.LockTimeout)
+	if err != nil {
+		return err
+	}
+
+	// Validate that the range hasn't been modified since our snapshot
+	if txn.snapshot != nil {
+		changed, err := rangeChangedSince(txn.txnDB.db, cf, begin, end, txn.snapshot)
+		if err == nil && changed {
+			err = ErrWriteConflict
+		}
+		if err != nil {
+			txn.txnDB.unlockRanges(func(l *rangeLock) bool { return l == r })
+			return err
+		}
+	}
+
+	cfID := uint32(0)
+	if cf != nil {
+		cfID = cf.ID()
+	}
+
+	if cfID == 0 {
+		txn.writeBatch.DeleteRange(begin, end)
+	} else {
+		txn.writeBatch.DeleteRangeCF(cfID, begin, end)
+	}
+
+	return nil
+}
+
+// SetName names the transaction, as Prepare requires. The name identifies
+// the transaction while it is unfinished, also after a crash; see
+// TransactionDB.GetTransactionByName. It must be set before Prepare, and
+// only once.
+//
+// Reference: RocksDB v10.7.5 utilities/transactions/pessimistic_transaction.cc (PessimisticTransaction::SetName)
+func (txn *PessimisticTransaction) SetName(name string) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if err := txn.checkState(); err != nil {
+		return err
+	}
+	if txn.prepared {
+		return ErrTxnAlreadyPrepared
+	}
+	if txn.name != "" {
+		return errors.New("db: transaction already named")
+	}
+	if name == "" {
+		return errors.New("db: transaction name must not be empty")
+	}
+	if err := txn.txnDB.setName(txn.id, name); err != nil {
+		return err
+	}
+	txn.name = name
+	return nil
+}
+
+// GetName returns the name set by SetName, or "" if there is none.
+func (txn *PessimisticTransaction) GetName() string {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	return txn.name
+}
+
+// Prepare writes the transaction's writes to the WAL between prepare
+// markers, without applying them, as the first phase of a two-phase commit.
+// The transaction then takes no more writes and keeps its locks until Commit
+// applies the writes or Rollback discards them. If the database crashes
+// before either, OpenTransactionDB restores the transaction as prepared.
+//
+// Reference: RocksDB v10.7.5 utilities/transactions/pessimistic_transaction.cc (WriteCommittedTxn::PrepareInternal)
+func (txn *PessimisticTransaction) Prepare() error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if err := txn.checkState(); err != nil {
+		return err
+	}
+	if txn.prepared {
+		return ErrTxnAlreadyPrepared
+	}
+	if txn.name == "" {
+		return ErrTransactionUnnamed
+	}
+
+	prepareBatch := batch.New()
+	prepareBatch.MarkBeginPrepare()
+	if err := prepareBatch.Append(txn.writeBatch); err != nil {
+		return err
+	}
+	prepareBatch.MarkEndPrepare([]byte(txn.name))
+
+	// The WAL file is read before the write, so that a WAL switched to
+	// meanwhile only keeps an older file live
+	db := txn.txnDB.db
+	db.mu.RLock()
+	logNum := db.logFileNumber
+	db.mu.RUnlock()
+	if err := db.writeWALOnly(txn.writeOpts, prepareBatch); err != nil {
+		return err
+	}
+
+	txn.prepared = true
+	txn.txnDB.setPrepared(txn.id, logNum)
+	return nil
+}
+
+// Commit applies the transaction and releases all locks. A prepared
+// transaction writes its commit marker to the WAL with its writes.
 func (txn *PessimisticTransaction) Commit() error {
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
@@ -343,10 +516,25 @@ func (txn *PessimisticTransaction) Commit() error {
 		return err
 	}
 
-	// Apply the write batch
-	writeCount := txn.writeBatch.Count()
-	if writeCount > 0 {
-		if err := txn.txnDB.db.Write(txn.writeOpts, newWriteBatchFromInternal(txn.writeBatch)); err != nil {
+	// Apply the write batch, with the commit-time writes
+	wb := txn.writeBatch
+	if txn.prepared || (txn.commitTimeBatch != nil && txn.commitTimeBatch.Count() > 0) {
+		wb = batch.New()
+		if txn.prepared {
+			wb.MarkCommit([]byte(txn.name))
+		}
+		if err := wb.Append(txn.writeBatch); err != nil {
+			return err
+		}
+		if txn.commitTimeBatch != nil {
+			if err := wb.Append(txn.commitTimeBatch.internal); err != nil {
+				return err
+			}
+		}
+	}
+	writeCount := wb.Count()
+	if writeCount > 0 || txn.prepared {
+		if err := txn.txnDB.db.Write(txn.writeOpts, newWriteBatchFromInternal(wb)); err != nil {
 			// On failure, still release locks
 			txn.releaseLocks()
 			return err
@@ -362,8 +550,16 @@ func (txn *PessimisticTransaction) Commit() error {
 	return nil
 }
 
-// Rollback discards the transaction and releases all locks.
+// Rollback discards the transaction and releases all locks. A prepared
+// transaction writes a rollback marker to the WAL.
 func (txn *PessimisticTransaction) Rollback() error {
+	return txn.rollback(true)
+}
+
+// rollback discards the transaction and releases its locks. Without
+// writeMarker, a prepared transaction stays prepared in the WAL, as when the
+// TransactionDB is closed.
+func (txn *PessimisticTransaction) rollback(writeMarker bool) error {
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
@@ -371,6 +567,14 @@ func (txn *PessimisticTransaction) Rollback() error {
 		return ErrTransactionClosed
 	}
 
+	if txn.prepared && writeMarker {
+		rollbackBatch := batch.New()
+		rollbackBatch.MarkRollback([]byte(txn.name))
+		if err := txn.txnDB.db.writeWALOnly(txn.writeOpts, rollbackBatch); err != nil {
+			return err
+		}
+	}
+
 	// Release all locks and cleanup
 	txn.releaseLocks()
 	txn.close()
@@ -473,11 +677,31 @@ func (txn *PessimisticTransaction) SetSnapshot() {
 	txn.snapshot = txn.txnDB.db.GetSnapshot()
 }
 
-// GetSnapshot returns the transaction's snapshot.
+// GetSnapshot returns the transaction's snapshot, set at creation or by
+// SetSnapshot, or else the one pinned by its first Get, or nil if it has
+// none yet.
 func (txn *PessimisticTransaction) GetSnapshot() *Snapshot {
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
-	return txn.snapshot
+	if txn.snapshot != nil {
+		return txn.snapshot
+	}
+	return txn.readSnap
+}
+
+// pinReadSnapshot returns the snapshot for Get: the transaction's snapshot,
+// or else one pinned by the first Get so that repeated reads of keys the
+// transaction did not write return the same values. Writes and
+// GetForUpdate are not validated against the pinned snapshot.
+// REQUIRES: txn.mu is held.
+func (txn *PessimisticTransaction) pinReadSnapshot() *Snapshot {
+	if txn.snapshot != nil {
+		return txn.snapshot
+	}
+	if txn.readSnap == nil {
+		txn.readSnap = txn.txnDB.db.GetSnapshot()
+	}
+	return txn.readSnap
 }
 
 // GetWriteBatchSize returns the number of entries in the write batch.
@@ -487,6 +711,23 @@ func (txn *PessimisticTransaction) GetWriteBatchSize() uint32 {
 	return txn.writeBatch.Count()
 }
 
+// GetCommitTimeWriteBatch returns a batch of writes that Commit makes
+// together with the transaction's own writes, atomically. For a
+// WritePreparedTxn, they are written with the commit marker rather than by
+// Prepare, so that a 2PC coordinator can record commit-time bookkeeping such
+// as a commit timestamp. The writes take no locks and are not visible to the
+// transaction's reads.
+//
+// Reference: RocksDB v10.7.5 include/rocksdb/utilities/transaction.h (Transaction::GetCommitTimeWriteBatch)
+func (txn *PessimisticTransaction) GetCommitTimeWriteBatch() *WriteBatch {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.commitTimeBatch == nil {
+		txn.commitTimeBatch = NewWriteBatch()
+	}
+	return txn.commitTimeBatch
+}
+
 // GetNumLocks returns the number of locks held by this transaction.
 func (txn *PessimisticTransaction) GetNumLocks() int {
 	txn.mu.Lock()
@@ -537,7 +778,7 @@ func (txn *PessimisticTransaction) tryLock(key []byte, lockType LockType) error
 	}
 
 	// Acquire lock from lock manager
-	err := txn.txnDB.lockManager.Lock(txn.id, key, lockType, txn.opts.LockTimeout)
+	err := txn.txnDB.lockKey(txn.id, key, lockType, txn.opts.LockTimeout)
 	if err != nil {
 		return err
 	}
@@ -557,6 +798,7 @@ func (txn *PessimisticTransaction) tryLock(key []byte, lockType LockType) error
 // releaseLocks releases all locks held by this transaction.
 func (txn *PessimisticTransaction) releaseLocks() {
 	txn.txnDB.lockManager.UnlockAll(txn.id)
+	txn.txnDB.unlockRanges(func(r *rangeLock) bool { return r.txnID == txn.id })
 	txn.lockedKeys = make(map[string]LockType)
 }
 
@@ -647,7 +889,15 @@ func (txn *PessimisticTransaction) close() {
 		txn.txnDB.db.ReleaseSnapshot(txn.snapshot)
 		txn.snapshot = nil
 	}
+	if txn.readSnap != nil {
+		txn.txnDB.db.ReleaseSnapshot(txn.readSnap)
+		txn.readSnap = nil
+	}
+	if txn.name != "" {
+		txn.txnDB.finishNamed(txn.id, txn.name)
+	}
 	txn.writeBatch = nil
+	txn.commitTimeBatch = nil
 	txn.savepoints = nil
 	txn.closed = true
 }
@@ -657,6 +907,7 @@ func (txn *PessimisticTransaction) getFromWriteBatch(cfID uint32, key []byte) ([
 	handler := &pessimisticBatchReader{
 		targetCFID: cfID,
 		targetKey:  key,
+		cmp:        txn.txnDB.db.cmp.Compare,
 	}
 	_ = txn.writeBatch.Iterate(handler)
 	return handler.value, handler.found, handler.deleted
@@ -666,6 +917,7 @@ func (txn *PessimisticTransaction) getFromWriteBatch(cfID uint32, key []byte) ([
 type pessimisticBatchReader struct {
 	targetCFID uint32
 	targetKey  []byte
+	cmp        func(a, b []byte) int // orders range deletion bounds
 	found      bool
 	deleted    bool
 	value      []byte
@@ -716,11 +968,22 @@ func (r *pessimisticBatchReader) SingleDeleteCF(cfID uint32, key []byte) error {
 	// SingleDelete has the same effect as Delete for read purposes
 	return r.DeleteCF(cfID, key)
 }
-func (r *pessimisticBatchReader) Merge(key, value []byte) error                      { return nil }
-func (r *pessimisticBatchReader) MergeCF(cfID uint32, key, value []byte) error       { return nil }
-func (r *pessimisticBatchReader) DeleteRange(start, end []byte) error                { return nil }
-func (r *pessimisticBatchReader) DeleteRangeCF(cfID uint32, start, end []byte) error { return nil }
-func (r *pessimisticBatchReader) LogData(blob []byte)                                {}
+func (r *pessimisticBatchReader) Merge(key, value []byte) error                { return nil }
+func (r *pessimisticBatchReader) MergeCF(cfID uint32, key, value []byte) error { return nil }
+func (r *pessimisticBatchReader) LogData(blob []byte)                          {}
+
+func (r *pessimisticBatchReader) DeleteRange(start, end []byte) error {
+	return r.DeleteRangeCF(0, start, end)
+}
+
+func (r *pessimisticBatchReader) DeleteRangeCF(cfID uint32, start, end []byte) error {
+	if cfID == r.targetCFID && r.cmp(r.targetKey, start) >= 0 && r.cmp(r.targetKey, end) < 0 {
+		r.found = true
+		r.deleted = true
+		r.value = nil
+	}
+	return nil
+}
 
 // batchCopier copies entries from one batch to another up to a max count.
 type batchCopier struct {
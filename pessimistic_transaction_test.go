@@ -4,11 +4,14 @@ package rockyardkv
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/aalhour/rockyardkv/vfs"
 )
 
 func TestPessimisticTransactionBasic(t *testing.T) {
@@ -434,6 +437,285 @@ func TestPessimisticTransactionDeadlock(t *testing.T) {
 	txn1.Rollback()
 }
 
+func TestPessimisticTransactionMaxNumLocks(t *testing.T) {
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+	txnDBOpts := DefaultTransactionDBOptions()
+	txnDBOpts.MaxNumLocks = 5
+	txnDB, err := OpenTransactionDB(filepath.Join(t.TempDir(), "testdb"), dbOpts, txnDBOpts)
+	if err != nil {
+		t.Fatalf("Failed to open TransactionDB: %v", err)
+	}
+	defer txnDB.Close()
+
+	// The sixth key of the transaction exceeds the limit
+	txn := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	for i := range 5 {
+		if err := txn.Put(fmt.Appendf(nil, "key%d", i), []byte("value")); err != nil {
+			t.Fatalf("Put %d failed: %v", i, err)
+		}
+	}
+	if err := txn.Put([]byte("key5"), []byte("value")); !errors.Is(err, ErrLockLimit) {
+		t.Fatalf("Put beyond the lock limit = %v, want ErrLockLimit", err)
+	}
+	if _, err := txn.GetForUpdate([]byte("key6"), true); !errors.Is(err, ErrLockLimit) {
+		t.Fatalf("GetForUpdate beyond the lock limit = %v, want ErrLockLimit", err)
+	}
+
+	// Aborting releases the locks it held
+	if err := txn.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if n := txnDB.getLockManager().NumLocks(); n != 0 {
+		t.Fatalf("%d keys locked after Rollback, want 0", n)
+	}
+	txn = txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	for i := range 5 {
+		if err := txn.Put(fmt.Appendf(nil, "key%d", i+5), []byte("value")); err != nil {
+			t.Fatalf("Put %d after Rollback failed: %v", i, err)
+		}
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, err := txnDB.Get([]byte("key0")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a rolled back write = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPessimisticTransactionCommitTimeWriteBatch(t *testing.T) {
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+	txnDB, err := OpenTransactionDB(filepath.Join(t.TempDir(), "testdb"), dbOpts, DefaultTransactionDBOptions())
+	if err != nil {
+		t.Fatalf("Failed to open TransactionDB: %v", err)
+	}
+	defer txnDB.Close()
+
+	txn := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	if err := txn.Put([]byte("key1"), []byte("value1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	txn.GetCommitTimeWriteBatch().Put([]byte("commit_ts"), []byte("42"))
+	if _, err := txnDB.Get([]byte("commit_ts")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of the commit-time write before Commit = %v, want ErrNotFound", err)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	for key, want := range map[string]string{"key1": "value1", "commit_ts": "42"} {
+		val, err := txnDB.Get([]byte(key))
+		if err != nil || string(val) != want {
+			t.Errorf("Get(%q) = (%q, %v), want (%q, nil)", key, val, err, want)
+		}
+	}
+
+	// A rolled back transaction does not write it
+	txn = txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	txn.GetCommitTimeWriteBatch().Put([]byte("rolled_back"), []byte("1"))
+	if err := txn.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if _, err := txnDB.Get([]byte("rolled_back")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a rolled back commit-time write = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTransactionDBWrite(t *testing.T) {
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+	txnDBOpts := DefaultTransactionDBOptions()
+	txnDBOpts.DefaultWriteTimeout = 50
+	txnDB, err := OpenTransactionDB(filepath.Join(t.TempDir(), "testdb"), dbOpts, txnDBOpts)
+	if err != nil {
+		t.Fatalf("Failed to open TransactionDB: %v", err)
+	}
+	defer txnDB.Close()
+
+	txn := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	if err := txn.Put([]byte("b"), []byte("txn")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// The batch conflicts on b and writes nothing
+	batch := NewWriteBatch()
+	batch.Put([]byte("c"), []byte("batch"))
+	batch.Put([]byte("b"), []byte("batch"))
+	batch.Delete([]byte("a"))
+	if err := txnDB.Write(nil, batch); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Write of a key locked by a transaction = %v, want ErrLockTimeout", err)
+	}
+	if _, err := txnDB.Get([]byte("c")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(c) after a conflicting Write = %v, want ErrNotFound", err)
+	}
+	if n := txnDB.getLockManager().NumTxnLocks(txn.ID()); n != 1 || txnDB.getLockManager().NumLocks() != 1 {
+		t.Errorf("%d keys locked after a conflicting Write, want only the transaction's", txnDB.getLockManager().NumLocks())
+	}
+
+	// Once the transaction commits the batch applies
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := txnDB.Write(nil, batch); err != nil {
+		t.Fatalf("Write after Commit failed: %v", err)
+	}
+	for _, key := range []string{"b", "c"} {
+		if val, err := txnDB.Get([]byte(key)); err != nil || string(val) != "batch" {
+			t.Errorf("Get(%q) = (%q, %v), want batch", key, val, err)
+		}
+	}
+	if n := txnDB.getLockManager().NumLocks(); n != 0 {
+		t.Errorf("%d keys locked after Write, want 0", n)
+	}
+
+	// A transaction can lock keys the batch wrote
+	txn = txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	if err := txn.Put([]byte("c"), []byte("txn")); err != nil {
+		t.Fatalf("Put after Write failed: %v", err)
+	}
+	if err := txn.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	rangeBatch := NewWriteBatch()
+	rangeBatch.DeleteRange([]byte("a"), []byte("z"))
+	if err := txnDB.Write(nil, rangeBatch); !errors.Is(err, ErrTransactionDBDeleteRange) {
+		t.Errorf("Write of a range deletion = %v, want ErrTransactionDBDeleteRange", err)
+	}
+}
+
+func TestPessimisticTransactionDeleteRange(t *testing.T) {
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+	txnDBOpts := DefaultTransactionDBOptions()
+	txnDBOpts.DefaultWriteTimeout = 50
+	txnDB, err := OpenTransactionDB(filepath.Join(t.TempDir(), "testdb"), dbOpts, txnDBOpts)
+	if err != nil {
+		t.Fatalf("Failed to open TransactionDB: %v", err)
+	}
+	defer txnDB.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := txnDB.Put([]byte(key), []byte("v")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	shortTimeout := DefaultPessimisticTransactionOptions()
+	shortTimeout.LockTimeout = 50 * time.Millisecond
+
+	txn1 := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	if err := txn1.DeleteRange(nil, []byte("b"), []byte("d")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+
+	// Writers into the locked range conflict, including of new keys
+	txn2 := txnDB.BeginTransaction(shortTimeout, nil)
+	if err := txn2.Put([]byte("bb"), []byte("txn2")); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Put into a locked range = %v, want ErrLockTimeout", err)
+	}
+	if _, err := txn2.GetForUpdate([]byte("c"), false); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("GetForUpdate in a locked range = %v, want ErrLockTimeout", err)
+	}
+	if err := txn2.Put([]byte("d"), []byte("txn2")); err != nil {
+		t.Errorf("Put at the end of the range failed: %v", err)
+	}
+	batch := NewWriteBatch()
+	batch.Put([]byte("b"), []byte("batch"))
+	if err := txnDB.Write(nil, batch); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Write into a locked range = %v, want ErrLockTimeout", err)
+	}
+	if _, err := txnDB.Get([]byte("b")); err != nil {
+		t.Errorf("Get before Commit failed: %v", err)
+	}
+
+	// The range deletion applies atomically at commit, releasing the range
+	if err := txn1.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	for key, wantFound := range map[string]bool{"a": true, "b": false, "c": false, "d": true} {
+		if _, err := txnDB.Get([]byte(key)); (err == nil) != wantFound {
+			t.Errorf("Get(%s) after Commit = %v, want found %v", key, err, wantFound)
+		}
+	}
+	if err := txn2.Put([]byte("bb"), []byte("txn2")); err != nil {
+		t.Errorf("Put after the range was released failed: %v", err)
+	}
+
+	// A range over a key another transaction holds waits for it
+	txn3 := txnDB.BeginTransaction(shortTimeout, nil)
+	if err := txn3.DeleteRange(nil, []byte("a"), []byte("c")); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("DeleteRange over a locked key = %v, want ErrLockTimeout", err)
+	}
+	if err := txn2.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := txn3.DeleteRange(nil, []byte("a"), []byte("c")); err != nil {
+		t.Errorf("DeleteRange after the key was released failed: %v", err)
+	}
+	if err := txn3.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	// With a snapshot, a range written since conflicts
+	txn4 := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	if err := txnDB.Put([]byte("m"), []byte("concurrent")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := txn4.DeleteRange(nil, []byte("l"), []byte("n")); !errors.Is(err, ErrWriteConflict) {
+		t.Errorf("DeleteRange of a range written after the snapshot = %v, want ErrWriteConflict", err)
+	}
+	if err := txn4.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if n := txnDB.getLockManager().NumLocks(); n != 0 {
+		t.Errorf("%d keys locked after all transactions ended, want 0", n)
+	}
+}
+
+func TestPessimisticTransactionDeleteRangeComparator(t *testing.T) {
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+	dbOpts.Comparator = reverseComparator{}
+	txnDB, err := OpenTransactionDB(filepath.Join(t.TempDir(), "testdb"), dbOpts, DefaultTransactionDBOptions())
+	if err != nil {
+		t.Fatalf("Failed to open TransactionDB: %v", err)
+	}
+	defer txnDB.Close()
+
+	shortTimeout := DefaultPessimisticTransactionOptions()
+	shortTimeout.LockTimeout = 50 * time.Millisecond
+
+	// In reverse order, [c, a) holds c and b
+	txn1 := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	defer txn1.Rollback()
+	if err := txn1.DeleteRange(nil, []byte("a"), []byte("c")); !errors.Is(err, ErrTransactionInvalidRange) {
+		t.Errorf("DeleteRange with end before begin = %v, want ErrTransactionInvalidRange", err)
+	}
+	if err := txn1.DeleteRange(nil, []byte("c"), []byte("c")); !errors.Is(err, ErrTransactionInvalidRange) {
+		t.Errorf("DeleteRange of an empty range = %v, want ErrTransactionInvalidRange", err)
+	}
+	if err := txn1.Put([]byte("b"), []byte("v")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := txn1.DeleteRange(nil, []byte("c"), []byte("a")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if _, err := txn1.Get([]byte("b")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("txn Get of a key the range deleted = %v, want ErrNotFound", err)
+	}
+
+	txn2 := txnDB.BeginTransaction(shortTimeout, nil)
+	defer txn2.Rollback()
+	if err := txn2.Put([]byte("bb"), []byte("txn2")); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Put into a locked range = %v, want ErrLockTimeout", err)
+	}
+	for _, key := range []string{"a", "d"} {
+		if err := txn2.Put([]byte(key), []byte("txn2")); err != nil {
+			t.Errorf("Put(%s) outside the locked range failed: %v", key, err)
+		}
+	}
+}
+
 // TestPessimisticTransactionRaceCondition tests for race conditions.
 func TestPessimisticTransactionRaceCondition(t *testing.T) {
 	dir := t.TempDir()
@@ -835,3 +1117,247 @@ func TestPessimisticTransactionSavepointSingleDeletePreservation(t *testing.T) {
 
 	txn.Rollback()
 }
+
+// TestPessimisticTransaction2PCRecoveryAfterCrash tests that a transaction
+// prepared before a crash is restored as prepared, holding its writes back
+// until it is committed, while those committed or rolled back after their
+// prepare are resolved by replay.
+func TestPessimisticTransaction2PCRecoveryAfterCrash(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "testdb")
+	faultFS := vfs.NewFaultInjectionFS(vfs.Default())
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+	dbOpts.FS = faultFS
+
+	open := func() *TransactionDB {
+		t.Helper()
+		txnDB, err := OpenTransactionDB(dbPath, dbOpts, DefaultTransactionDBOptions())
+		if err != nil {
+			t.Fatalf("Failed to open TransactionDB: %v", err)
+		}
+		return txnDB
+	}
+	crash := func(txnDB *TransactionDB) {
+		faultFS.SetFilesystemActive(false)
+		_ = txnDB.Close()
+		faultFS.SetFilesystemActive(true)
+	}
+
+	txnDB := open()
+	prepare := func(name string) *PessimisticTransaction {
+		t.Helper()
+		txn := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), &WriteOptions{Sync: true})
+		if err := txn.SetName(name); err != nil {
+			t.Fatalf("SetName(%s) failed: %v", name, err)
+		}
+		if err := txn.Put([]byte(name), []byte(name+"_value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := txn.Prepare(); err != nil {
+			t.Fatalf("Prepare(%s) failed: %v", name, err)
+		}
+		return txn
+	}
+	if err := prepare("committed").Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := prepare("rolledback").Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	prepared := prepare("prepared")
+	if err := prepared.Put([]byte("late"), []byte("value")); !errors.Is(err, ErrTxnAlreadyPrepared) {
+		t.Fatalf("Put after Prepare: got %v, want ErrTxnAlreadyPrepared", err)
+	}
+	if _, err := txnDB.Get([]byte("prepared")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(prepared) before commit: got %v, want ErrNotFound", err)
+	}
+	crash(txnDB)
+
+	txnDB = open()
+	if _, err := txnDB.Get([]byte("committed")); err != nil {
+		t.Errorf("Get(committed) failed: %v", err)
+	}
+	if _, err := txnDB.Get([]byte("rolledback")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(rolledback): got %v, want ErrNotFound", err)
+	}
+	if _, err := txnDB.Get([]byte("prepared")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(prepared) after recovery: got %v, want ErrNotFound", err)
+	}
+
+	recovered := txnDB.GetAllPreparedTransactions()
+	if len(recovered) != 1 || recovered[0].GetName() != "prepared" {
+		t.Fatalf("Recovered %d prepared transactions, want only \"prepared\"", len(recovered))
+	}
+	if txnDB.GetTransactionByName("prepared") != recovered[0] {
+		t.Fatal("GetTransactionByName did not return the recovered transaction")
+	}
+
+	// The recovered transaction still holds the lock on its key
+	other := txnDB.BeginTransaction(PessimisticTransactionOptions{LockTimeout: 10 * time.Millisecond}, nil)
+	if err := other.Put([]byte("prepared"), []byte("other")); err == nil {
+		t.Fatal("Put of a key locked by the recovered transaction succeeded")
+	}
+	_ = other.Rollback()
+
+	if err := recovered[0].Commit(); err != nil {
+		t.Fatalf("Commit of the recovered transaction failed: %v", err)
+	}
+	if len(txnDB.GetAllPreparedTransactions()) != 0 {
+		t.Fatal("Expected no prepared transactions after commit")
+	}
+	crash(txnDB)
+
+	// The commit survives another crash
+	txnDB = open()
+	defer txnDB.Close()
+	val, err := txnDB.Get([]byte("prepared"))
+	if err != nil {
+		t.Fatalf("Get(prepared) after commit failed: %v", err)
+	}
+	if string(val) != "prepared_value" {
+		t.Errorf("Expected 'prepared_value', got %q", val)
+	}
+	if n := len(txnDB.GetAllPreparedTransactions()); n != 0 {
+		t.Errorf("Expected no prepared transactions after reopen, got %d", n)
+	}
+}
+
+// TestPessimisticTransaction2PCRecoveryDeleteRange tests that a transaction
+// recovered prepared with a DeleteRange still holds the lock on its range.
+func TestPessimisticTransaction2PCRecoveryDeleteRange(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "testdb")
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+
+	txnDB, err := OpenTransactionDB(dbPath, dbOpts, DefaultTransactionDBOptions())
+	if err != nil {
+		t.Fatalf("Failed to open TransactionDB: %v", err)
+	}
+	txn := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+	if err := txn.SetName("ranged"); err != nil {
+		t.Fatalf("SetName failed: %v", err)
+	}
+	if err := txn.DeleteRange(nil, []byte("b"), []byte("d")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	if err := txn.Prepare(); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	_ = txnDB.Close()
+
+	txnDB, err = OpenTransactionDB(dbPath, dbOpts, DefaultTransactionDBOptions())
+	if err != nil {
+		t.Fatalf("Failed to reopen TransactionDB: %v", err)
+	}
+	defer txnDB.Close()
+	recovered := txnDB.GetTransactionByName("ranged")
+	if recovered == nil {
+		t.Fatal("The prepared transaction was not recovered")
+	}
+
+	other := txnDB.BeginTransaction(PessimisticTransactionOptions{LockTimeout: 10 * time.Millisecond}, nil)
+	if err := other.Put([]byte("c"), []byte("other")); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Put in the recovered range: got %v, want ErrLockTimeout", err)
+	}
+	if err := other.Put([]byte("d"), []byte("other")); err != nil {
+		t.Fatalf("Put past the recovered range failed: %v", err)
+	}
+
+	if err := recovered.Commit(); err != nil {
+		t.Fatalf("Commit of the recovered transaction failed: %v", err)
+	}
+	if err := other.Put([]byte("c"), []byte("other")); err != nil {
+		t.Fatalf("Put after the recovered commit failed: %v", err)
+	}
+	if err := other.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+}
+
+// TestPessimisticTransaction2PCOtherOpens tests that the opens that cannot
+// restore prepared transactions do not expose their writes: Open refuses
+// the WAL, while read-only and secondary instances and RepairDB keep only
+// the committed writes.
+func TestPessimisticTransaction2PCOtherOpens(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "testdb")
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+
+	txnDB, err := OpenTransactionDB(dbPath, dbOpts, DefaultTransactionDBOptions())
+	if err != nil {
+		t.Fatalf("Failed to open TransactionDB: %v", err)
+	}
+	if err := txnDB.Put([]byte("key"), []byte("committed")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	prepare := func(name string, key []byte) *PessimisticTransaction {
+		t.Helper()
+		txn := txnDB.BeginTransaction(DefaultPessimisticTransactionOptions(), nil)
+		if err := txn.SetName(name); err != nil {
+			t.Fatalf("SetName(%s) failed: %v", name, err)
+		}
+		if err := txn.Put(key, []byte(name)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := txn.Prepare(); err != nil {
+			t.Fatalf("Prepare(%s) failed: %v", name, err)
+		}
+		return txn
+	}
+	if err := prepare("rolledback", []byte("key")).Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := prepare("committed", []byte("committed_key")).Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	prepare("prepared", []byte("prepared_key"))
+	if err := txnDB.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if db, err := Open(dbPath, dbOpts); !errors.Is(err, ErrNotSupported) {
+		if err == nil {
+			db.Close()
+		}
+		t.Fatalf("Open: got %v, want ErrNotSupported", err)
+	}
+
+	check := func(name string, get func(key []byte) ([]byte, error)) {
+		t.Helper()
+		val, err := get([]byte("key"))
+		if err != nil || string(val) != "committed" {
+			t.Errorf("%s: Get(key) = %q, %v; want the value before the rolled back transaction", name, val, err)
+		}
+		if _, err := get([]byte("committed_key")); err != nil {
+			t.Errorf("%s: Get(committed_key) failed: %v", name, err)
+		}
+		if _, err := get([]byte("prepared_key")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Get(prepared_key): got %v, want ErrNotFound", name, err)
+		}
+	}
+
+	roDB, err := OpenForReadOnly(dbPath, dbOpts, false)
+	if err != nil {
+		t.Fatalf("OpenForReadOnly failed: %v", err)
+	}
+	check("read-only", func(key []byte) ([]byte, error) { return roDB.Get(nil, key) })
+	roDB.Close()
+
+	secondary, err := OpenAsSecondary(dbPath, filepath.Join(dir, "secondary"), dbOpts)
+	if err != nil {
+		t.Fatalf("OpenAsSecondary failed: %v", err)
+	}
+	check("secondary", func(key []byte) ([]byte, error) { return secondary.Get(nil, key) })
+	secondary.Close()
+
+	if err := RepairDB(dbPath, dbOpts); err != nil {
+		t.Fatalf("RepairDB failed: %v", err)
+	}
+	database, err := Open(dbPath, dbOpts)
+	if err != nil {
+		t.Fatalf("Open after RepairDB failed: %v", err)
+	}
+	defer database.Close()
+	check("repaired", func(key []byte) ([]byte, error) { return database.Get(nil, key) })
+}
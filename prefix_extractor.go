@@ -125,3 +125,18 @@ func (e *NoopPrefixExtractor) Transform(key []byte) []byte {
 func (e *NoopPrefixExtractor) InDomain(key []byte) bool {
 	return true
 }
+
+// filterPrefix adapts pe to the prefix function of memtable and SST bloom
+// filters, which reports false for keys outside the prefix domain. It
+// returns nil if pe is nil.
+func filterPrefix(pe PrefixExtractor) func(key []byte) ([]byte, bool) {
+	if pe == nil {
+		return nil
+	}
+	return func(key []byte) ([]byte, bool) {
+		if !pe.InDomain(key) {
+			return nil, false
+		}
+		return pe.Transform(key), true
+	}
+}
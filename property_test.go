@@ -3,7 +3,9 @@ package rockyardkv
 // property_test.go implements tests for property.
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -324,3 +326,208 @@ func TestGetPropertyLevelStats(t *testing.T) {
 		t.Errorf("Expected 7 level lines, got %d: %s", levelLines, val)
 	}
 }
+
+func TestGetPropertyCF(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer database.Close()
+
+	cf, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	// Three L0 files in the default column family
+	for i := range 3 {
+		if err := database.Put(nil, []byte("key"+strconv.Itoa(i)), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	// One ingested file and a few memtable entries in cf1
+	sstPath := filepath.Join(t.TempDir(), "cf1.sst")
+	createExternalSST(t, sstPath, map[string]string{"a": "1", "b": "2"})
+	if err := database.IngestExternalFiles([]IngestExternalFileArg{
+		{ColumnFamily: cf, ExternalFiles: []string{sstPath}, Options: DefaultIngestExternalFileOptions()},
+	}); err != nil {
+		t.Fatalf("IngestExternalFiles failed: %v", err)
+	}
+	for i := range 5 {
+		if err := database.PutCF(nil, cf, []byte("key"+strconv.Itoa(i)), []byte("value")); err != nil {
+			t.Fatalf("PutCF failed: %v", err)
+		}
+	}
+
+	numFiles := func(cf ColumnFamilyHandle) (level0, total uint64) {
+		t.Helper()
+		for level := range opts.NumLevels {
+			n, ok := database.GetIntPropertyCF(cf, PropertyNumFilesAtLevelPrefix+strconv.Itoa(level))
+			if !ok {
+				t.Fatalf("num-files-at-level%d should exist", level)
+			}
+			if level == 0 {
+				level0 = n
+			}
+			total += n
+		}
+		return level0, total
+	}
+	if level0, total := numFiles(nil); level0 != 3 || total != 3 {
+		t.Errorf("default CF: %d files at L0 and %d in total, want 3 and 3", level0, total)
+	}
+	if level0, total := numFiles(cf); level0 >= 3 || total != 1 {
+		t.Errorf("cf1: %d files at L0 and %d in total, want fewer than 3 and 1", level0, total)
+	}
+	if v, _ := database.GetProperty(PropertyNumFilesAtLevelPrefix + "0"); v != "3" {
+		t.Errorf("GetProperty(num-files-at-level0) = %s, want the default CF's 3", v)
+	}
+
+	// Memtable properties are those of the column family's memtable
+	if n, _ := database.GetIntPropertyCF(nil, PropertyNumEntriesActiveMemTable); n != 0 {
+		t.Errorf("default CF: %d memtable entries, want 0", n)
+	}
+	if n, _ := database.GetIntPropertyCF(cf, PropertyNumEntriesActiveMemTable); n != 5 {
+		t.Errorf("cf1: %d memtable entries, want 5", n)
+	}
+
+	// Properties of the whole database are the same for every column family
+	if n, _ := database.GetIntPropertyCF(cf, PropertyNumColumnFamilies); n != 2 {
+		t.Errorf("cf1: num-column-families = %d, want 2", n)
+	}
+
+	if err := database.DropColumnFamily(cf); err != nil {
+		t.Fatalf("DropColumnFamily failed: %v", err)
+	}
+	if _, ok := database.GetPropertyCF(cf, PropertyNumEntriesActiveMemTable); ok {
+		t.Error("property of a dropped column family should not exist")
+	}
+}
+
+func TestGetMapPropertySSTables(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer database.Close()
+
+	// One SST per key prefix
+	for _, prefix := range []string{"a", "b", "c"} {
+		for i := range 20 {
+			if err := database.Put(nil, fmt.Appendf(nil, "%s%02d", prefix, i), []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	// Read only keys of the "b" file
+	for range 5 {
+		for i := range 20 {
+			if _, err := database.Get(nil, fmt.Appendf(nil, "b%02d", i)); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+		}
+	}
+
+	props, ok := database.GetMapProperty(PropertySSTables)
+	if !ok {
+		t.Fatalf("GetMapProperty(%s) not supported", PropertySSTables)
+	}
+	files := 0
+	for key, smallest := range props {
+		name, ok := strings.CutSuffix(key, ".smallest")
+		if !ok {
+			continue
+		}
+		files++
+		if props[name+".level"] != "0" {
+			t.Errorf("%s.level = %q, want 0", name, props[name+".level"])
+		}
+		if want := smallest[:1] + "19"; props[name+".largest"] != want {
+			t.Errorf("%s key range = %q to %q, want %q to %q", name, smallest, props[name+".largest"], smallest, want)
+		}
+		reads, err := strconv.ParseUint(props[name+".reads"], 10, 64)
+		if err != nil {
+			t.Fatalf("%s.reads = %q: %v", name, props[name+".reads"], err)
+		}
+		if smallest == "b00" {
+			if reads < 100 {
+				t.Errorf("%s (hot) reads = %d, want at least 100", name, reads)
+			}
+		} else if reads > 5 {
+			t.Errorf("%s (cold, %s) reads = %d, want near 0", name, smallest, reads)
+		}
+	}
+	if files != 3 {
+		t.Errorf("rocksdb.sstables lists %d files, want 3: %v", files, props)
+	}
+}
+
+func TestGetIntPropertyCurrentSuperVersionNumber(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.DisableAutoCompactions = true
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	defer database.Close()
+
+	cf, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "other")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+	versionNumber := func(cf ColumnFamilyHandle) uint64 {
+		t.Helper()
+		n, ok := database.GetIntPropertyCF(cf, PropertyCurrentSuperVersionNumber)
+		if !ok {
+			t.Fatalf("GetIntPropertyCF(%s) not supported", PropertyCurrentSuperVersionNumber)
+		}
+		return n
+	}
+	otherBefore := versionNumber(cf)
+
+	last := versionNumber(nil)
+	for i := range 2 {
+		for j := range 10 {
+			if err := database.Put(nil, fmt.Appendf(nil, "key%02d", j), fmt.Appendf(nil, "value%d", i)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if n := versionNumber(nil); n <= last {
+			t.Errorf("version number after flush %d = %d, want above %d", i, n, last)
+		} else {
+			last = n
+		}
+	}
+
+	if err := database.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+	if n := versionNumber(nil); n <= last {
+		t.Errorf("version number after compaction = %d, want above %d", n, last)
+	}
+
+	// Versions of the default column family leave the other one's unchanged
+	if n := versionNumber(cf); n != otherBefore {
+		t.Errorf("version number of column family %q = %d, want %d", cf.Name(), n, otherBefore)
+	}
+}
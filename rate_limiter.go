@@ -69,6 +69,9 @@ type GenericRateLimiter struct {
 	// Mode
 	mode RateLimiterMode
 
+	// Clock used for refills and waits; nil means the wall clock
+	clock SystemClock
+
 	// Token bucket state
 	availableBytes int64
 	lastRefillTime time.Time
@@ -96,6 +99,13 @@ type RateLimiterOptions struct {
 
 	// Mode specifies what I/O to rate limit.
 	Mode RateLimiterMode
+
+	// Clock is used to refill tokens and wait for quota.
+	// If nil, the limiter adopts the Env of the first DB it is passed to,
+	// and keeps that clock for every other DB sharing it; standalone, it
+	// uses the wall clock. Set Clock when DBs with different Envs share
+	// the limiter.
+	Clock SystemClock
 }
 
 // DefaultRateLimiterOptions returns default options.
@@ -125,7 +135,8 @@ func NewGenericRateLimiter(opts *RateLimiterOptions) *GenericRateLimiter {
 		refillPeriod:   opts.RefillPeriod,
 		fairness:       opts.Fairness,
 		mode:           opts.Mode,
-		lastRefillTime: time.Now(),
+		clock:          opts.Clock,
+		lastRefillTime: clockNow(opts.Clock),
 	}
 	rl.cv = sync.NewCond(&rl.mu)
 
@@ -137,6 +148,13 @@ func NewGenericRateLimiter(opts *RateLimiterOptions) *GenericRateLimiter {
 
 // Request requests bytes to be written/read.
 func (rl *GenericRateLimiter) Request(bytes int64, priority IOPriority) {
+	rl.request(bytes, priority, nil)
+}
+
+// request implements Request. It returns without the quota once cancel is
+// closed, at most a refill period later.
+// Reference: RocksDB v10.7.5 util/rate_limiter.cc (GenericRateLimiter::Request, stop_)
+func (rl *GenericRateLimiter) request(bytes int64, priority IOPriority, cancel <-chan struct{}) {
 	if bytes <= 0 {
 		return
 	}
@@ -153,19 +171,18 @@ func (rl *GenericRateLimiter) Request(bytes int64, priority IOPriority) {
 
 	// Wait for tokens
 	for rl.availableBytes < bytes {
+		select {
+		case <-cancel:
+			return
+		default:
+		}
+
 		// Calculate how long to wait
 		needed := bytes - rl.availableBytes
 		waitTime := min(time.Duration(needed*int64(time.Second))/time.Duration(rl.bytesPerSecond), rl.refillPeriod)
 
-		// Wait with timeout
-		done := make(chan struct{})
-		go func() {
-			time.Sleep(waitTime)
-			close(done)
-		}()
-
 		rl.mu.Unlock()
-		<-done
+		clockSleep(rl.clock, waitTime)
 		rl.mu.Lock()
 
 		rl.refill()
@@ -177,7 +194,7 @@ func (rl *GenericRateLimiter) Request(bytes int64, priority IOPriority) {
 // refill adds tokens based on elapsed time.
 // Must be called with rl.mu held.
 func (rl *GenericRateLimiter) refill() {
-	now := time.Now()
+	now := clockNow(rl.clock)
 	elapsed := now.Sub(rl.lastRefillTime)
 	if elapsed < time.Millisecond {
 		return // Too soon
@@ -195,6 +212,19 @@ func (rl *GenericRateLimiter) refill() {
 	}
 }
 
+// adoptClock sets the limiter's clock if none was configured.
+// Open calls this so a limiter built without a clock follows the DB's Env.
+// The token bucket runs on a single clock, so the first DB binds it.
+func (rl *GenericRateLimiter) adoptClock(clock SystemClock) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.clock != nil || clock == nil {
+		return
+	}
+	rl.clock = clock
+	rl.lastRefillTime = clockNow(clock)
+}
+
 // SetBytesPerSecond dynamically sets the rate limit.
 func (rl *GenericRateLimiter) SetBytesPerSecond(bytesPerSecond int64) {
 	rl.mu.Lock()
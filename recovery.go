@@ -7,16 +7,21 @@ package rockyardkv
 //   - db/db_impl/db_impl_write.cc
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
 	"regexp"
 	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/aalhour/rockyardkv/internal/batch"
 	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/manifest"
 	"github.com/aalhour/rockyardkv/internal/memtable"
+	"github.com/aalhour/rockyardkv/internal/version"
 	"github.com/aalhour/rockyardkv/internal/wal"
 )
 
@@ -51,11 +56,7 @@ func (db *dbImpl) replayWAL() error {
 	slices.Sort(toReplay)
 
 	// Create memtable for recovery with the configured comparator
-	var memCmp memtable.Comparator
-	if db.comparator != nil {
-		memCmp = db.comparator.Compare
-	}
-	db.mem = memtable.NewMemTable(memCmp)
+	db.mem = db.newMemTable()
 
 	// Replay each log file
 	maxSeq := db.seq
@@ -128,6 +129,10 @@ func (db *dbImpl) replayLogFile(logNum uint64) (uint64, error) {
 		if errors.Is(err, io.EOF) {
 			break
 		}
+		if errors.Is(err, wal.ErrOldRecord) {
+			// The rest of a recycled log is left from its previous use
+			break
+		}
 		if err != nil {
 			// Log corruption - we can either fail or continue
 			// For now, stop at first error (strict mode)
@@ -151,12 +156,21 @@ func (db *dbImpl) replayLogFile(logNum uint64) (uint64, error) {
 
 		// Apply the batch to memtable
 		// Note: lockHeld=true because we're called from recover() which holds db.mu
-		handler := &memtableInserter{
+		inserter := &memtableInserter{
 			db:         db,
 			sequence:   batchSeq,
 			defaultMem: db.mem,
 			lockHeld:   true,
 		}
+		var handler batch.Handler
+		switch db.prepares {
+		case prepareAfterCommit:
+			handler = &preparedSectionInserter{memtableInserter: inserter, logNumber: logNum}
+		case prepareApply:
+			handler = &preparedSectionRefuser{memtableInserter: inserter, allowPersisted: true}
+		default:
+			handler = &preparedSectionRefuser{memtableInserter: inserter}
+		}
 		if err := wb.Iterate(handler); err != nil {
 			return maxSeq, fmt.Errorf("failed to apply batch: %w", err)
 		}
@@ -165,6 +179,253 @@ func (db *dbImpl) replayLogFile(logNum uint64) (uint64, error) {
 	return maxSeq, nil
 }
 
+// liveLogFiles returns the numbers of the WAL files that the MANIFEST does not
+// yet cover, oldest first.
+func (db *dbImpl) liveLogFiles() ([]uint64, error) {
+	logFiles, err := db.findLogFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find log files: %w", err)
+	}
+	minLogNumber := db.versions.LogNumber()
+	logFiles = slices.DeleteFunc(logFiles, func(num uint64) bool { return num < minLogNumber })
+	slices.Sort(logFiles)
+	return logFiles, nil
+}
+
+// replayWALReadOnly replays the live WAL files into a new memtable without
+// modifying the database, for read-only and secondary instances. It returns
+// the memtable and the largest sequence number covered by the MANIFEST or the
+// replayed records.
+//
+// Only the default column family is served by these instances, so records for
+// other column families are skipped. A record that cannot be read or decoded
+// ends the replay of its file: the WAL may be torn at the tail, or still being
+// appended to by a primary.
+func (db *dbImpl) replayWALReadOnly() (*memtable.MemTable, uint64, error) {
+	logFiles, err := db.liveLogFiles()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	mem := db.newMemTable()
+
+	var committed committedReplay
+	maxSeq := db.versions.LastSequence()
+	for _, logNum := range logFiles {
+		maxSeq = max(maxSeq, db.replayLogFileReadOnly(logNum, mem, &committed))
+	}
+	return mem, maxSeq, nil
+}
+
+// replayLogFileReadOnly applies the committed default column family records
+// of a WAL file to mem and returns the largest sequence number seen.
+func (db *dbImpl) replayLogFileReadOnly(logNum uint64, mem *memtable.MemTable, committed *committedReplay) uint64 {
+	file, err := db.fs.Open(db.logFilePath(logNum))
+	if err != nil {
+		// The log may have been deleted after a flush
+		return 0
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := wal.NewReader(file, nil /* reporter */, true /* checksum */, logNum)
+
+	var maxSeq uint64
+	for {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				db.logger.Debugf("[recovery] stopped reading log %d: %v", logNum, err)
+			}
+			return maxSeq
+		}
+
+		wb, err := batch.NewFromData(record)
+		if err != nil {
+			db.logger.Debugf("[recovery] stopped reading log %d: %v", logNum, err)
+			return maxSeq
+		}
+
+		batches, err := committed.batches(wb)
+		if err != nil {
+			db.logger.Debugf("[recovery] stopped reading log %d: %v", logNum, err)
+			return maxSeq
+		}
+		for _, b := range batches {
+			handler := &shadowMemtableInserter{
+				memtableInserter: memtableInserter{
+					db:         db,
+					sequence:   b.Sequence(),
+					defaultMem: mem,
+					lockHeld:   true,
+				},
+			}
+			if err := b.Iterate(handler); err != nil {
+				db.logger.Debugf("[recovery] stopped reading log %d: %v", logNum, err)
+				return maxSeq
+			}
+			maxSeq = max(maxSeq, b.Sequence()+uint64(b.Count())-1)
+		}
+	}
+}
+
+// committedReplay picks the WAL batches to apply for a replay that cannot
+// restore prepared transactions, those of read-only and secondary instances
+// and of RepairDB, so that it applies committed writes only:
+//   - the prepare section of a TransactionDB transaction is dropped, as its
+//     commit record holds its writes again;
+//   - that of a WritePreparedTxnDB transaction, whose commit record holds
+//     the commit marker only, is held and applied when that marker is read,
+//     at the sequence numbers it was written with;
+//   - sections rolled back or never resolved are dropped.
+type committedReplay struct {
+	held map[string]*batch.WriteBatch // WritePreparedTxnDB sections by name
+}
+
+// batches returns the batches to apply for the WAL record wb, in order.
+func (r *committedReplay) batches(wb *batch.WriteBatch) ([]*batch.WriteBatch, error) {
+	var markers walMarkers
+	if err := wb.Iterate(&markers); err != nil {
+		return nil, err
+	}
+	if markers.prepared {
+		if markers.persisted && markers.xid != nil {
+			section, err := batch.NewFromData(slices.Clone(wb.Data()))
+			if err != nil {
+				return nil, err
+			}
+			if r.held == nil {
+				r.held = make(map[string]*batch.WriteBatch)
+			}
+			r.held[string(markers.xid)] = section
+		}
+		return nil, nil
+	}
+
+	batches := []*batch.WriteBatch{wb}
+	for _, xid := range markers.commits {
+		if section, ok := r.held[xid]; ok {
+			batches = append(batches, section)
+			delete(r.held, xid)
+		}
+	}
+	for _, xid := range markers.rollbacks {
+		delete(r.held, xid)
+	}
+	return batches, nil
+}
+
+// walMarkers is a batch handler that gathers the two-phase commit markers of
+// a WAL record.
+type walMarkers struct {
+	prepared  bool   // the record holds a prepare section
+	persisted bool   // of a WritePreparedTxnDB transaction
+	xid       []byte // the name of the prepared transaction
+	commits   []string
+	rollbacks []string
+}
+
+var (
+	_ batch.HandlerPersistedPrepare = (*walMarkers)(nil)
+	_ batch.HandlerWideColumn       = (*walMarkers)(nil)
+)
+
+func (m *walMarkers) MarkBeginPrepare(unprepared bool) error {
+	m.prepared = true
+	return nil
+}
+
+func (m *walMarkers) MarkBeginPersistedPrepare() error {
+	m.prepared = true
+	m.persisted = true
+	return nil
+}
+
+func (m *walMarkers) MarkEndPrepare(xid []byte) error {
+	m.xid = slices.Clone(xid)
+	return nil
+}
+
+func (m *walMarkers) MarkCommit(xid []byte) error {
+	m.commits = append(m.commits, string(xid))
+	return nil
+}
+
+func (m *walMarkers) MarkRollback(xid []byte) error {
+	m.rollbacks = append(m.rollbacks, string(xid))
+	return nil
+}
+
+func (m *walMarkers) Put(_, _ []byte) error                     { return nil }
+func (m *walMarkers) Delete(_ []byte) error                     { return nil }
+func (m *walMarkers) SingleDelete(_ []byte) error               { return nil }
+func (m *walMarkers) Merge(_, _ []byte) error                   { return nil }
+func (m *walMarkers) DeleteRange(_, _ []byte) error             { return nil }
+func (m *walMarkers) LogData(_ []byte)                          {}
+func (m *walMarkers) PutCF(_ uint32, _, _ []byte) error         { return nil }
+func (m *walMarkers) DeleteCF(_ uint32, _ []byte) error         { return nil }
+func (m *walMarkers) SingleDeleteCF(_ uint32, _ []byte) error   { return nil }
+func (m *walMarkers) MergeCF(_ uint32, _, _ []byte) error       { return nil }
+func (m *walMarkers) DeleteRangeCF(_ uint32, _, _ []byte) error { return nil }
+func (m *walMarkers) PutEntity(_, _ []byte) error               { return nil }
+func (m *walMarkers) PutEntityCF(_ uint32, _, _ []byte) error   { return nil }
+
+// shadowMemtableInserter applies WAL records to the memtable of a read-only
+// or secondary instance. Records for column families other than the default
+// consume their sequence number but are otherwise skipped.
+type shadowMemtableInserter struct {
+	memtableInserter
+}
+
+func (m *shadowMemtableInserter) skip(cfID uint32) bool {
+	if cfID == DefaultColumnFamilyID {
+		return false
+	}
+	m.sequence++
+	return true
+}
+
+func (m *shadowMemtableInserter) PutCF(cfID uint32, key, value []byte) error {
+	if m.skip(cfID) {
+		return nil
+	}
+	return m.memtableInserter.PutCF(cfID, key, value)
+}
+
+func (m *shadowMemtableInserter) PutEntityCF(cfID uint32, key, entity []byte) error {
+	if m.skip(cfID) {
+		return nil
+	}
+	return m.memtableInserter.PutEntityCF(cfID, key, entity)
+}
+
+func (m *shadowMemtableInserter) DeleteCF(cfID uint32, key []byte) error {
+	if m.skip(cfID) {
+		return nil
+	}
+	return m.memtableInserter.DeleteCF(cfID, key)
+}
+
+func (m *shadowMemtableInserter) SingleDeleteCF(cfID uint32, key []byte) error {
+	if m.skip(cfID) {
+		return nil
+	}
+	return m.memtableInserter.SingleDeleteCF(cfID, key)
+}
+
+func (m *shadowMemtableInserter) MergeCF(cfID uint32, key, value []byte) error {
+	if m.skip(cfID) {
+		return nil
+	}
+	return m.memtableInserter.MergeCF(cfID, key, value)
+}
+
+func (m *shadowMemtableInserter) DeleteRangeCF(cfID uint32, startKey, endKey []byte) error {
+	if m.skip(cfID) {
+		return nil
+	}
+	return m.memtableInserter.DeleteRangeCF(cfID, startKey, endKey)
+}
+
 // deleteOrphanedSSTFiles removes SST files that aren't referenced in the MANIFEST.
 // This is critical for preventing internal key collisions after crash recovery.
 //
@@ -234,67 +495,403 @@ func (db *dbImpl) deleteOrphanedSSTFiles() error {
 	return nil
 }
 
-// walRecoveryHandler applies recovered operations to the memtable.
-// Reserved for future WAL recovery implementation.
+// checkConsistency validates the recovered state against the files on disk.
+// It is run on open when Options.ParanoidChecks is set and verifies that:
+//   - CURRENT names the MANIFEST that was recovered, and that MANIFEST exists
+//   - every SST referenced by the MANIFEST exists with the recorded size
+//   - files within each non-L0 level have non-overlapping key ranges
+//
+// Any violation is reported as ErrCorruption.
+//
+// Reference: RocksDB v10.7.5
+//   - db/db_impl/db_impl.cc (DBImpl::CheckConsistency)
+//   - db/version_builder.cc (VersionBuilder::Rep::CheckConsistency)
+func (db *dbImpl) checkConsistency() error {
+	if err := db.checkCurrentFile(); err != nil {
+		return err
+	}
+
+	version := db.versions.Current()
+	if version == nil {
+		return nil
+	}
+	if err := db.checkVersionFiles(version); err != nil {
+		return err
+	}
+	return db.checkLevelRanges(version)
+}
+
+// checkVersionFiles verifies that every SST file of v exists with the size
+// recorded in the MANIFEST.
+func (db *dbImpl) checkVersionFiles(v *version.Version) error {
+	for level := range v.NumLevels() {
+		for _, f := range v.Files(level) {
+			number := f.FD.GetNumber()
+			path := db.sstFilePath(number)
+			info, err := db.fs.Stat(path)
+			if err != nil {
+				return fmt.Errorf("%w: sst file %s (level %d) referenced by MANIFEST: %w",
+					ErrCorruption, sstFileName(number), level, err)
+			}
+			if size := uint64(info.Size()); size != f.FD.FileSize {
+				return fmt.Errorf("%w: sst file %s (level %d) size mismatch: MANIFEST records %d bytes, file has %d bytes",
+					ErrCorruption, sstFileName(number), level, f.FD.FileSize, size)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLevelRanges verifies that the files within each non-L0 level of v
+// have non-overlapping key ranges.
+func (db *dbImpl) checkLevelRanges(v *version.Version) error {
+	var userCmp dbformat.UserKeyComparer
+	if db.cmp != nil {
+		userCmp = db.cmp.Compare
+	}
+	icmp := dbformat.NewInternalKeyComparator(userCmp)
+	for level := 1; level < v.NumLevels(); level++ {
+		// Column families share the version's levels, so ranges only need to
+		// be disjoint within a column family.
+		files := slices.Clone(v.Files(level))
+		slices.SortFunc(files, func(a, b *manifest.FileMetaData) int {
+			if a.ColumnFamilyID != b.ColumnFamilyID {
+				return cmp.Compare(a.ColumnFamilyID, b.ColumnFamilyID)
+			}
+			return icmp.Compare(a.Smallest, b.Smallest)
+		})
+		for i := 1; i < len(files); i++ {
+			prev, next := files[i-1], files[i]
+			if prev.ColumnFamilyID != next.ColumnFamilyID {
+				continue
+			}
+			if icmp.Compare(prev.Largest, next.Smallest) >= 0 {
+				return fmt.Errorf("%w: overlapping ranges in level %d: file %s largest %q >= file %s smallest %q",
+					ErrCorruption, level,
+					sstFileName(prev.FD.GetNumber()), dbformat.ExtractUserKey(prev.Largest),
+					sstFileName(next.FD.GetNumber()), dbformat.ExtractUserKey(next.Smallest))
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkCurrentFile verifies that CURRENT points at the MANIFEST the version
+// set was recovered from, and that the MANIFEST is still present.
+func (db *dbImpl) checkCurrentFile() error {
+	currentPath := filepath.Join(db.name, "CURRENT")
+	file, err := db.fs.Open(currentPath)
+	if err != nil {
+		return fmt.Errorf("%w: read CURRENT: %w", ErrCorruption, err)
+	}
+	data, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil {
+		return fmt.Errorf("%w: read CURRENT: %w", ErrCorruption, err)
+	}
+
+	manifestName := strings.TrimSpace(string(data))
+	expected := fmt.Sprintf("MANIFEST-%06d", db.versions.ManifestFileNumber())
+	if manifestName != expected {
+		return fmt.Errorf("%w: CURRENT names %q, recovered from %q",
+			ErrCorruption, manifestName, expected)
+	}
+	if _, err := db.fs.Stat(filepath.Join(db.name, manifestName)); err != nil {
+		return fmt.Errorf("%w: MANIFEST %s named by CURRENT: %w", ErrCorruption, manifestName, err)
+	}
+	return nil
+}
+
+// prepareReplay says how the WAL replay at open treats the prepare sections of
+// two-phase commit transactions. A transaction database writes its prepared
+// transactions to the memtable at commit (TransactionDB) or at prepare
+// (WritePreparedTxnDB), and marks their sections apart; an open that cannot
+// resolve the sections it finds fails rather than expose uncommitted writes.
+//
+// Reference: RocksDB v10.7.5 db/write_batch.cc (MemTableInserter::MarkBeginPrepare)
+type prepareReplay int
+
+const (
+	// prepareRefuse fails on any prepare section, as Open does.
+	prepareRefuse prepareReplay = iota
+
+	// prepareAfterCommit holds the sections of TransactionDB until their
+	// commit or rollback marker; see preparedSectionInserter.
+	prepareAfterCommit
+
+	// prepareApply applies the sections of WritePreparedTxnDB as they are,
+	// its commit cache telling which are committed.
+	prepareApply
+)
+
+// errPreparedWAL returns the error of an open that found a prepare section
+// it cannot replay, naming the open that can.
+func errPreparedWAL(persisted bool) error {
+	open := "OpenTransactionDB"
+	if persisted {
+		open = "OpenWritePreparedTxnDB"
+	}
+	return fmt.Errorf("%w: WAL contains prepared transactions; open with %s", ErrNotSupported, open)
+}
+
+// preparedSectionRefuser replays a WAL batch like memtableInserter, failing
+// on a prepare section, before any of its writes are applied. With
+// allowPersisted, the sections of WritePreparedTxnDB are applied instead,
+// including those it wrote with the plain begin marker before it had its own.
+type preparedSectionRefuser struct {
+	*memtableInserter
+	allowPersisted bool
+}
+
+var _ batch.HandlerPersistedPrepare = (*preparedSectionRefuser)(nil)
+
+func (h *preparedSectionRefuser) MarkBeginPrepare(unprepared bool) error {
+	if h.allowPersisted {
+		return nil
+	}
+	return errPreparedWAL(false)
+}
+
+func (h *preparedSectionRefuser) MarkBeginPersistedPrepare() error {
+	if h.allowPersisted {
+		return nil
+	}
+	return errPreparedWAL(true)
+}
+
+func (h *preparedSectionRefuser) MarkEndPrepare(xid []byte) error { return nil }
+func (h *preparedSectionRefuser) MarkCommit(xid []byte) error     { return nil }
+func (h *preparedSectionRefuser) MarkRollback(xid []byte) error   { return nil }
+
+// recoveredPrepare is a transaction found prepared in the WAL, with neither
+// a commit nor a rollback marker after its prepare section.
+type recoveredPrepare struct {
+	batch     *batch.WriteBatch // The transaction's writes
+	logNumber uint64            // WAL file holding the prepare section
+}
+
+// preparedSectionInserter replays a WAL batch like memtableInserter, except
+// that the writes of a prepare section are collected rather than applied.
+// Those of a transaction that commits are applied by the commit batch, which
+// holds them too; those of one that is not resolved are kept in
+// db.recoveredPrepared.
+// Reference: RocksDB v10.7.5 db/write_batch.cc (MemTableInserter::MarkBeginPrepare)
+type preparedSectionInserter struct {
+	*memtableInserter
+	logNumber uint64
+
+	// The writes of the open prepare section, or nil outside of one
+	rebuilding *batch.WriteBatch
+}
+
+var _ batch.HandlerPersistedPrepare = (*preparedSectionInserter)(nil)
+
+func (h *preparedSectionInserter) MarkBeginPrepare(unprepared bool) error {
+	h.rebuilding = batch.New()
+	return nil
+}
+
+func (h *preparedSectionInserter) MarkBeginPersistedPrepare() error {
+	return errPreparedWAL(true)
+}
+
+func (h *preparedSectionInserter) MarkEndPrepare(xid []byte) error {
+	if h.rebuilding == nil {
+		return nil
+	}
+	db := h.db
+	if db.recoveredPrepared == nil {
+		db.recoveredPrepared = make(map[string]*recoveredPrepare)
+	}
+	db.recoveredPrepared[string(xid)] = &recoveredPrepare{batch: h.rebuilding, logNumber: h.logNumber}
+	h.rebuilding = nil
+	return nil
+}
+
+func (h *preparedSectionInserter) MarkCommit(xid []byte) error {
+	delete(h.db.recoveredPrepared, string(xid))
+	return nil
+}
+
+func (h *preparedSectionInserter) MarkRollback(xid []byte) error {
+	delete(h.db.recoveredPrepared, string(xid))
+	return nil
+}
+
+func (h *preparedSectionInserter) Put(key, value []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.Put(key, value)
+		return nil
+	}
+	return h.memtableInserter.Put(key, value)
+}
+
+func (h *preparedSectionInserter) PutCF(cfID uint32, key, value []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.PutCF(cfID, key, value)
+		return nil
+	}
+	return h.memtableInserter.PutCF(cfID, key, value)
+}
+
+func (h *preparedSectionInserter) PutEntity(key, entity []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.PutEntity(key, entity)
+		return nil
+	}
+	return h.memtableInserter.PutEntity(key, entity)
+}
+
+func (h *preparedSectionInserter) PutEntityCF(cfID uint32, key, entity []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.PutEntityCF(cfID, key, entity)
+		return nil
+	}
+	return h.memtableInserter.PutEntityCF(cfID, key, entity)
+}
+
+func (h *preparedSectionInserter) Delete(key []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.Delete(key)
+		return nil
+	}
+	return h.memtableInserter.Delete(key)
+}
+
+func (h *preparedSectionInserter) DeleteCF(cfID uint32, key []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.DeleteCF(cfID, key)
+		return nil
+	}
+	return h.memtableInserter.DeleteCF(cfID, key)
+}
+
+func (h *preparedSectionInserter) SingleDelete(key []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.SingleDelete(key)
+		return nil
+	}
+	return h.memtableInserter.SingleDelete(key)
+}
+
+func (h *preparedSectionInserter) SingleDeleteCF(cfID uint32, key []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.SingleDeleteCF(cfID, key)
+		return nil
+	}
+	return h.memtableInserter.SingleDeleteCF(cfID, key)
+}
+
+func (h *preparedSectionInserter) Merge(key, value []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.Merge(key, value)
+		return nil
+	}
+	return h.memtableInserter.Merge(key, value)
+}
+
+func (h *preparedSectionInserter) MergeCF(cfID uint32, key, value []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.MergeCF(cfID, key, value)
+		return nil
+	}
+	return h.memtableInserter.MergeCF(cfID, key, value)
+}
+
+func (h *preparedSectionInserter) DeleteRange(startKey, endKey []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.DeleteRange(startKey, endKey)
+		return nil
+	}
+	return h.memtableInserter.DeleteRange(startKey, endKey)
+}
+
+func (h *preparedSectionInserter) DeleteRangeCF(cfID uint32, startKey, endKey []byte) error {
+	if h.rebuilding != nil {
+		h.rebuilding.DeleteRangeCF(cfID, startKey, endKey)
+		return nil
+	}
+	return h.memtableInserter.DeleteRangeCF(cfID, startKey, endKey)
+}
+
+// walRecoveryHandler applies recovered operations to a memtable per column
+// family and drops those of column families it has no memtable for. It is
+// used by RepairDB to convert WALs to tables.
 type walRecoveryHandler struct {
-	mem      *memtable.MemTable
+	mems     map[uint32]*memtable.MemTable // By column family ID
 	sequence uint64
+	dropped  int
 }
 
-// Compile-time check that walRecoveryHandler implements batch.Handler
-var _ batch.Handler = (*walRecoveryHandler)(nil)
-
-func (h *walRecoveryHandler) Put(key, value []byte) error {
-	h.mem.Add(dbformat.SequenceNumber(h.sequence), dbformat.TypeValue, key, value)
+// Compile-time check that walRecoveryHandler implements batch.HandlerWideColumn
+var _ batch.HandlerWideColumn = (*walRecoveryHandler)(nil)
+
+// add applies a record to the memtable of its column family. A dropped
+// record still consumes a sequence number, so the records after it keep
+// theirs.
+func (h *walRecoveryHandler) add(cfID uint32, typ dbformat.ValueType, key, value []byte) error {
+	if mem := h.mems[cfID]; mem != nil {
+		mem.Add(dbformat.SequenceNumber(h.sequence), typ, key, value)
+	} else {
+		h.dropped++
+	}
 	h.sequence++
 	return nil
 }
 
+func (h *walRecoveryHandler) Put(key, value []byte) error {
+	return h.add(DefaultColumnFamilyID, dbformat.TypeValue, key, value)
+}
+
+func (h *walRecoveryHandler) PutEntity(key, entity []byte) error {
+	return h.add(DefaultColumnFamilyID, dbformat.TypeWideColumnEntity, key, entity)
+}
+
 func (h *walRecoveryHandler) Delete(key []byte) error {
-	h.mem.Add(dbformat.SequenceNumber(h.sequence), dbformat.TypeDeletion, key, nil)
-	h.sequence++
-	return nil
+	return h.add(DefaultColumnFamilyID, dbformat.TypeDeletion, key, nil)
 }
 
 func (h *walRecoveryHandler) SingleDelete(key []byte) error {
-	h.mem.Add(dbformat.SequenceNumber(h.sequence), dbformat.TypeSingleDeletion, key, nil)
-	h.sequence++
-	return nil
+	return h.add(DefaultColumnFamilyID, dbformat.TypeSingleDeletion, key, nil)
 }
 
 func (h *walRecoveryHandler) Merge(key, value []byte) error {
-	h.mem.Add(dbformat.SequenceNumber(h.sequence), dbformat.TypeMerge, key, value)
-	h.sequence++
-	return nil
+	return h.add(DefaultColumnFamilyID, dbformat.TypeMerge, key, value)
 }
 
 func (h *walRecoveryHandler) DeleteRange(startKey, endKey []byte) error {
-	h.mem.AddRangeTombstone(dbformat.SequenceNumber(h.sequence), startKey, endKey)
-	h.sequence++
-	return nil
+	return h.DeleteRangeCF(DefaultColumnFamilyID, startKey, endKey)
 }
 
 func (h *walRecoveryHandler) DeleteRangeCF(cfID uint32, startKey, endKey []byte) error {
-	// TODO: Support column family recovery
-	h.mem.AddRangeTombstone(dbformat.SequenceNumber(h.sequence), startKey, endKey)
+	if mem := h.mems[cfID]; mem != nil {
+		mem.AddRangeTombstone(dbformat.SequenceNumber(h.sequence), startKey, endKey)
+	} else {
+		h.dropped++
+	}
 	h.sequence++
 	return nil
 }
 
 func (h *walRecoveryHandler) PutCF(cfID uint32, key, value []byte) error {
-	return h.Put(key, value)
+	return h.add(cfID, dbformat.TypeValue, key, value)
+}
+
+func (h *walRecoveryHandler) PutEntityCF(cfID uint32, key, entity []byte) error {
+	return h.add(cfID, dbformat.TypeWideColumnEntity, key, entity)
 }
 
 func (h *walRecoveryHandler) DeleteCF(cfID uint32, key []byte) error {
-	return h.Delete(key)
+	return h.add(cfID, dbformat.TypeDeletion, key, nil)
 }
 
 func (h *walRecoveryHandler) MergeCF(cfID uint32, key, value []byte) error {
-	return h.Merge(key, value)
+	return h.add(cfID, dbformat.TypeMerge, key, value)
 }
 
 func (h *walRecoveryHandler) SingleDeleteCF(cfID uint32, key []byte) error {
-	return h.SingleDelete(key)
+	return h.add(cfID, dbformat.TypeSingleDeletion, key, nil)
 }
 
 func (h *walRecoveryHandler) LogData(blob []byte) {
@@ -0,0 +1,499 @@
+package rockyardkv
+
+// repair.go implements RepairDB, which rebuilds a database whose MANIFEST is
+// missing or unreadable from the SST and WAL files that survive.
+//
+// Repair proceeds in four phases:
+//  1. Every SST file is opened and scanned to reconstruct its metadata
+//     (key range, sequence numbers, size) and its column family.
+//  2. Every WAL file is replayed into a memtable per column family and
+//     flushed to new SSTs.
+//  3. A fresh MANIFEST is written that recreates the column families and
+//     places every recovered table in L0. L0 tolerates overlapping ranges,
+//     so files whose relative order cannot be trusted are still readable.
+//  4. Old MANIFEST files, converted WALs, and unreadable SSTs are moved to
+//     a "lost" subdirectory rather than deleted.
+//
+// Column families are recreated from the rocksdb.column.family.id and
+// rocksdb.column.family.name properties of the SST files. A WAL names a
+// column family only by ID, so WAL records of a column family that has no
+// SST file are dropped rather than mixed into another one.
+//
+// Reference: RocksDB v10.7.5
+//   - db/repair.cc
+//   - include/rocksdb/db.h (RepairDB)
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/aalhour/rockyardkv/internal/batch"
+	"github.com/aalhour/rockyardkv/internal/blob"
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/flush"
+	"github.com/aalhour/rockyardkv/internal/logging"
+	"github.com/aalhour/rockyardkv/internal/manifest"
+	"github.com/aalhour/rockyardkv/internal/memtable"
+	"github.com/aalhour/rockyardkv/internal/table"
+	"github.com/aalhour/rockyardkv/internal/version"
+	"github.com/aalhour/rockyardkv/internal/wal"
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+// manifestFileRegex matches MANIFEST file names like "MANIFEST-000001"
+var manifestFileRegex = regexp.MustCompile(`^MANIFEST-(\d+)$`)
+
+// lostDirName is the subdirectory that receives files set aside by repair.
+const lostDirName = "lost"
+
+// unknownColumnFamilyID is the rocksdb.column.family.id of tables written
+// outside of any column family, e.g. by the C++ SstFileWriter.
+// Reference: RocksDB v10.7.5 include/rocksdb/table_properties.h (kUnknownColumnFamily)
+const unknownColumnFamilyID = 0x7fffffff
+
+// RepairDB rebuilds the database at path from its surviving SST and WAL
+// files and writes a fresh MANIFEST and CURRENT.
+//
+// Repair is best-effort: records after the first corruption in a WAL are
+// dropped, and SST files that cannot be read are moved to the "lost"
+// subdirectory. It fails with ErrDBLocked if the database is open, and
+// with ErrCorruption if an SST file does not tell which column family it
+// belongs to.
+func RepairDB(path string, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	fs := opts.FS
+	if fs == nil {
+		fs = vfs.Default()
+	}
+	env := opts.Env
+	if env == nil {
+		env = DefaultEnv()
+	}
+	comparator := opts.Comparator
+	if comparator == nil {
+		comparator = DefaultComparator()
+	}
+
+	if !fs.Exists(path) {
+		return ErrDBNotFound
+	}
+
+	lock, err := fs.Lock(filepath.Join(path, lockFileName))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrDBLocked, path, err)
+	}
+	defer func() { _ = lock.Close() }()
+
+	r := &repairer{
+		dbname:     path,
+		fs:         fs,
+		env:        env,
+		comparator: comparator,
+		logger:     logging.OrDefault(opts.Logger),
+		columnFamilies: map[uint32]string{
+			DefaultColumnFamilyID: DefaultColumnFamilyName,
+		},
+		versions: version.NewVersionSet(version.VersionSetOptions{
+			DBName:              path,
+			FS:                  fs,
+			MaxManifestFileSize: 1024 * 1024 * 1024, // 1GB
+			NumLevels:           version.MaxNumLevels,
+			Logger:              opts.Logger,
+		}),
+	}
+	return r.run()
+}
+
+// repairer holds the state of a single RepairDB run.
+type repairer struct {
+	dbname     string
+	fs         vfs.FS
+	env        Env
+	comparator Comparator
+	logger     Logger
+	versions   *version.VersionSet
+
+	manifests []string
+	logs      []uint64
+	tables    []uint64
+
+	// columnFamilies maps the ID of each column family found in the tables
+	// to its name
+	columnFamilies map[uint32]string
+
+	// committed carries the prepared transactions of the WALs across them,
+	// so that only committed writes are converted
+	committed committedReplay
+
+	// recovered holds the metadata of every table that will be in the
+	// new MANIFEST.
+	recovered []*manifest.FileMetaData
+	maxSeq    uint64
+}
+
+// Compile-time check that repairer can run flush jobs.
+var _ flush.DB = (*repairer)(nil)
+
+// NextFileNumber implements flush.DB.
+func (r *repairer) NextFileNumber() uint64 {
+	return r.versions.NextFileNumber()
+}
+
+// SSTFilePath implements flush.DB.
+func (r *repairer) SSTFilePath(fileNum uint64) string {
+	return filepath.Join(r.dbname, sstFileName(fileNum))
+}
+
+// FS implements flush.DB.
+func (r *repairer) FS() vfs.FS {
+	return r.fs
+}
+
+// DBPath implements flush.DB.
+func (r *repairer) DBPath() string {
+	return r.dbname
+}
+
+// ComparatorName implements flush.DB.
+func (r *repairer) ComparatorName() string {
+	return r.comparator.Name()
+}
+
+// NowMicros implements flush.DB.
+func (r *repairer) NowMicros() uint64 {
+	return r.env.NowMicros()
+}
+
+func (r *repairer) run() error {
+	r.logger.Infof("[repair] repairing database at %s", r.dbname)
+
+	if err := r.findFiles(); err != nil {
+		return err
+	}
+	if err := r.extractMetaData(); err != nil {
+		return err
+	}
+	if err := r.convertLogsToTables(); err != nil {
+		return err
+	}
+	if err := r.writeDescriptor(); err != nil {
+		return err
+	}
+
+	for _, name := range r.manifests {
+		r.archiveFile(name)
+	}
+
+	r.logger.Infof("[repair] recovered %d tables, last sequence %d", len(r.recovered), r.maxSeq)
+	return nil
+}
+
+// findFiles classifies the files in the database directory and reserves
+// every file number already in use.
+func (r *repairer) findFiles() error {
+	entries, err := r.fs.ListDir(r.dbname)
+	if err != nil {
+		return fmt.Errorf("repair: failed to list directory: %w", err)
+	}
+
+	var maxNumber uint64
+	for _, entry := range entries {
+		var number uint64
+		if m := sstFileRegex.FindStringSubmatch(entry); m != nil {
+			number, _ = strconv.ParseUint(m[1], 10, 64)
+			r.tables = append(r.tables, number)
+		} else if m := logFileRegex.FindStringSubmatch(entry); m != nil {
+			number, _ = strconv.ParseUint(m[1], 10, 64)
+			r.logs = append(r.logs, number)
+		} else if m := manifestFileRegex.FindStringSubmatch(entry); m != nil {
+			number, _ = strconv.ParseUint(m[1], 10, 64)
+			r.manifests = append(r.manifests, entry)
+		} else {
+			continue
+		}
+		maxNumber = max(maxNumber, number)
+	}
+
+	if len(r.tables) == 0 && len(r.logs) == 0 {
+		return fmt.Errorf("%w: repair found no SST or WAL files in %s", ErrCorruption, r.dbname)
+	}
+
+	slices.Sort(r.tables)
+	slices.Sort(r.logs)
+	r.versions.MarkFileNumberUsed(maxNumber)
+	return nil
+}
+
+// convertLogsToTables replays each WAL into a memtable per column family and
+// flushes them to new SSTs. Converted WALs are archived whether or not they
+// were readable.
+func (r *repairer) convertLogsToTables() error {
+	for _, logNum := range r.logs {
+		if err := r.convertLogToTable(logNum); err != nil {
+			return fmt.Errorf("repair: log %d: %w", logNum, err)
+		}
+		r.archiveFile(logFileName(logNum))
+	}
+	return nil
+}
+
+func (r *repairer) convertLogToTable(logNum uint64) error {
+	file, err := r.fs.Open(filepath.Join(r.dbname, logFileName(logNum)))
+	if err != nil {
+		r.logger.Warnf("[repair] cannot open log %d: %v", logNum, err)
+		return nil
+	}
+	defer func() { _ = file.Close() }()
+
+	mems := make(map[uint32]*memtable.MemTable, len(r.columnFamilies))
+	for id := range r.columnFamilies {
+		mems[id] = memtable.NewMemTable(r.comparator.Compare)
+	}
+	reader := wal.NewReader(file, nil /* reporter */, true /* checksum */, logNum)
+
+	records, dropped := 0, 0
+	for {
+		record, err := reader.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Keep everything before the first corruption.
+			r.logger.Warnf("[repair] log %d: dropping records after corruption: %v", logNum, err)
+			break
+		}
+
+		wb, err := batch.NewFromData(record)
+		if err != nil {
+			r.logger.Warnf("[repair] log %d: skipping undecodable batch: %v", logNum, err)
+			continue
+		}
+		batches, err := r.committed.batches(wb)
+		if err != nil {
+			r.logger.Warnf("[repair] log %d: skipping unreadable batch: %v", logNum, err)
+			continue
+		}
+		for _, b := range batches {
+			handler := &walRecoveryHandler{mems: mems, sequence: b.Sequence()}
+			err := b.Iterate(handler)
+			dropped += handler.dropped
+			if err != nil {
+				r.logger.Warnf("[repair] log %d: skipping unreadable batch: %v", logNum, err)
+				break
+			}
+		}
+		records++
+	}
+	if dropped > 0 {
+		r.logger.Warnf("[repair] log %d: dropped %d records of column families without tables", logNum, dropped)
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(mems)) {
+		job := flush.NewJob(r, mems[id])
+		job.SetColumnFamily(id, r.columnFamilies[id])
+		meta, err := job.Run()
+		if errors.Is(err, flush.ErrNoOutput) || (err == nil && meta == nil) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		meta.ColumnFamilyID = id
+
+		r.recovered = append(r.recovered, meta)
+		r.maxSeq = max(r.maxSeq, uint64(meta.FD.LargestSeqno))
+		r.logger.Infof("[repair] log %d: %d batches converted to table %d of column family %q",
+			logNum, records, meta.FD.GetNumber(), r.columnFamilies[id])
+	}
+	return nil
+}
+
+// extractMetaData reconstructs the metadata of every pre-existing SST and
+// collects the column families they belong to. Tables that cannot be read
+// are archived.
+func (r *repairer) extractMetaData() error {
+	for _, number := range r.tables {
+		meta, cfName, err := r.scanTable(number)
+		if err != nil {
+			r.logger.Warnf("[repair] table %d: %v", number, err)
+			r.archiveFile(sstFileName(number))
+			continue
+		}
+		if err := r.addColumnFamily(meta.ColumnFamilyID, cfName); err != nil {
+			return fmt.Errorf("repair: table %d: %w", number, err)
+		}
+		r.recovered = append(r.recovered, meta)
+		r.maxSeq = max(r.maxSeq, uint64(meta.FD.LargestSeqno))
+	}
+	return nil
+}
+
+// addColumnFamily records the column family a table belongs to. Every ID
+// must keep one name and every name one ID.
+func (r *repairer) addColumnFamily(id uint32, name string) error {
+	// Tables of the default column family may leave the name empty
+	if id == DefaultColumnFamilyID {
+		return nil
+	}
+	if id == unknownColumnFamilyID || name == "" {
+		return fmt.Errorf("%w: unknown column family", ErrCorruption)
+	}
+	if known, ok := r.columnFamilies[id]; ok {
+		if known != name {
+			return fmt.Errorf("%w: column family %d is named both %q and %q", ErrCorruption, id, known, name)
+		}
+		return nil
+	}
+	for knownID, known := range r.columnFamilies {
+		if known == name {
+			return fmt.Errorf("%w: column family %q has both ID %d and %d", ErrCorruption, name, knownID, id)
+		}
+	}
+	r.columnFamilies[id] = name
+	return nil
+}
+
+// scanTable opens an SST and scans it to recover its key range, sequence
+// number bounds and column family. It also returns the column family's name.
+func (r *repairer) scanTable(number uint64) (*manifest.FileMetaData, string, error) {
+	file, err := r.fs.OpenRandomAccess(r.SSTFilePath(number))
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader, err := table.Open(file, table.ReaderOptions{VerifyChecksums: true})
+	if err != nil {
+		return nil, "", err
+	}
+	props, err := reader.Properties()
+	if err != nil {
+		return nil, "", err
+	}
+
+	meta := manifest.NewFileMetaData()
+	meta.ColumnFamilyID = uint32(props.ColumnFamilyID)
+	meta.FD = manifest.NewFileDescriptor(number, 0, uint64(file.Size()))
+	meta.FD.SmallestSeqno = manifest.SequenceNumber(dbformat.MaxSequenceNumber)
+
+	icmp := dbformat.NewInternalKeyComparator(r.comparator.Compare)
+	addKey := func(key []byte, seq dbformat.SequenceNumber) {
+		if meta.Smallest == nil || icmp.Compare(key, meta.Smallest) < 0 {
+			meta.Smallest = append([]byte(nil), key...)
+		}
+		if meta.Largest == nil || icmp.Compare(key, meta.Largest) > 0 {
+			meta.Largest = append([]byte(nil), key...)
+		}
+		meta.FD.SmallestSeqno = min(meta.FD.SmallestSeqno, manifest.SequenceNumber(seq))
+		meta.FD.LargestSeqno = max(meta.FD.LargestSeqno, manifest.SequenceNumber(seq))
+	}
+
+	iter := reader.NewIteratorWithVerify(true)
+	entries := 0
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		addKey(iter.Key(), dbformat.ExtractSequenceNumber(iter.Key()))
+		if dbformat.ExtractValueType(iter.Key()) == dbformat.TypeBlobIndex {
+			if idx, err := blob.DecodeBlobIndex(iter.Value()); err == nil &&
+				(meta.OldestBlobFileNumber == 0 || idx.FileNumber < meta.OldestBlobFileNumber) {
+				meta.OldestBlobFileNumber = idx.FileNumber
+			}
+		}
+		entries++
+	}
+	if err := iter.Error(); err != nil {
+		return nil, "", err
+	}
+
+	tombstones, err := reader.GetRangeTombstoneList()
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range tombstones.Len() {
+		t := tombstones.Get(i)
+		addKey(makeInternalKey(t.StartKey, uint64(t.SequenceNum), dbformat.TypeRangeDeletion), t.SequenceNum)
+		addKey(makeInternalKey(t.EndKey, uint64(dbformat.MaxSequenceNumber), dbformat.TypeRangeDeletion), t.SequenceNum)
+	}
+
+	if entries == 0 && tombstones.Len() == 0 {
+		return nil, "", errors.New("table is empty")
+	}
+	return meta, props.ColumnFamilyName, nil
+}
+
+// writeDescriptor writes a new MANIFEST with every recovered column family
+// and every recovered table in L0, and points CURRENT at it.
+func (r *repairer) writeDescriptor() error {
+	if err := r.versions.Create(); err != nil {
+		return fmt.Errorf("repair: failed to create MANIFEST: %w", err)
+	}
+	defer func() { _ = r.versions.Close() }()
+
+	edit := &manifest.VersionEdit{
+		HasLastSequence: true,
+		LastSequence:    manifest.SequenceNumber(r.maxSeq),
+	}
+	edits := []*manifest.VersionEdit{edit}
+	ids := slices.Sorted(maps.Keys(r.columnFamilies))
+	for _, id := range ids[1:] {
+		cfEdit := &manifest.VersionEdit{}
+		cfEdit.SetColumnFamily(id)
+		cfEdit.AddColumnFamily(r.columnFamilies[id])
+		cfEdit.SetMaxColumnFamily(ids[len(ids)-1])
+		edits = append(edits, cfEdit)
+	}
+
+	// A file's column family is that of the edit adding it
+	for _, id := range ids {
+		filesEdit := edit
+		if id != DefaultColumnFamilyID {
+			filesEdit = &manifest.VersionEdit{}
+			filesEdit.SetColumnFamily(id)
+			edits = append(edits, filesEdit)
+		}
+		for _, meta := range r.recovered {
+			if meta.ColumnFamilyID == id {
+				filesEdit.NewFiles = append(filesEdit.NewFiles, manifest.NewFileEntry{
+					Level: 0,
+					Meta:  meta,
+				})
+			}
+		}
+	}
+	for _, e := range edits {
+		if err := r.versions.LogAndApply(e); err != nil {
+			return fmt.Errorf("repair: failed to write MANIFEST: %w", err)
+		}
+	}
+
+	// The old MANIFEST files are archived after the new one is written.
+	newManifest := fmt.Sprintf("MANIFEST-%06d", r.versions.ManifestFileNumber())
+	r.manifests = slices.DeleteFunc(r.manifests, func(name string) bool {
+		return name == newManifest
+	})
+	return nil
+}
+
+// archiveFile moves a file into the "lost" subdirectory.
+// Failures are logged and otherwise ignored.
+func (r *repairer) archiveFile(name string) {
+	lostDir := filepath.Join(r.dbname, lostDirName)
+	if err := r.fs.MkdirAll(lostDir, 0755); err != nil {
+		r.logger.Warnf("[repair] cannot create %s: %v", lostDir, err)
+		return
+	}
+	src := filepath.Join(r.dbname, name)
+	if err := r.fs.Rename(src, filepath.Join(lostDir, name)); err != nil {
+		r.logger.Warnf("[repair] cannot archive %s: %v", name, err)
+		return
+	}
+	r.logger.Infof("[repair] archived %s", name)
+}
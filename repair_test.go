@@ -0,0 +1,347 @@
+package rockyardkv
+
+// repair_test.go implements tests for RepairDB.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/table"
+)
+
+// removeManifests deletes every MANIFEST file and CURRENT from dir.
+func removeManifests(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "MANIFEST-") || e.Name() == "CURRENT" {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				t.Fatalf("Remove %s failed: %v", e.Name(), err)
+			}
+		}
+	}
+}
+
+func TestRepairDBAfterManifestLoss(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for i := range 3 {
+		for j := range 10 {
+			key := fmt.Sprintf("key%02d_%02d", i, j)
+			if err := database.Put(nil, []byte(key), []byte("value_"+key)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	// Overwrite a flushed key; the newer value lives only in the WAL.
+	if err := database.Put(nil, []byte("key00_00"), []byte("updated")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	database.Close()
+
+	removeManifests(t, dir)
+
+	if err := RepairDB(dir, opts); err != nil {
+		t.Fatalf("RepairDB failed: %v", err)
+	}
+
+	opts.CreateIfMissing = false
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open after repair failed: %v", err)
+	}
+	defer database.Close()
+
+	for i := range 3 {
+		for j := range 10 {
+			key := fmt.Sprintf("key%02d_%02d", i, j)
+			want := "value_" + key
+			if key == "key00_00" {
+				want = "updated"
+			}
+			got, err := database.Get(nil, []byte(key))
+			if err != nil {
+				t.Fatalf("Get(%s) failed: %v", key, err)
+			}
+			if string(got) != want {
+				t.Errorf("Get(%s) = %q, want %q", key, got, want)
+			}
+		}
+	}
+
+	// New writes must not collide with recovered sequence numbers.
+	if err := database.Put(nil, []byte("key00_00"), []byte("after_repair")); err != nil {
+		t.Fatalf("Put after repair failed: %v", err)
+	}
+	got, err := database.Get(nil, []byte("key00_00"))
+	if err != nil || string(got) != "after_repair" {
+		t.Errorf("Get after repair = %q, %v; want after_repair", got, err)
+	}
+}
+
+// TestRepairDBKeepsColumnFamilies checks that column families are recreated
+// from their tables, with their WAL records.
+func TestRepairDBKeepsColumnFamilies(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	cf, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf1")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+	unflushed, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "cf2")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("shared"), []byte("default")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.PutCF(nil, cf, []byte("shared"), []byte("cf1")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+	if err := database.PutCF(nil, cf, []byte("flushed"), []byte("cf1")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+	if err := database.FlushAndWaitForL0(cf); err != nil {
+		t.Fatalf("FlushAndWaitForL0 failed: %v", err)
+	}
+	// Only in the WAL
+	if err := database.PutCF(nil, cf, []byte("logged"), []byte("cf1")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+	if err := database.PutCF(nil, unflushed, []byte("cf2_only"), []byte("cf2")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("last"), []byte("default")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	database.Close()
+
+	removeManifests(t, dir)
+
+	if err := RepairDB(dir, opts); err != nil {
+		t.Fatalf("RepairDB failed: %v", err)
+	}
+
+	opts.CreateIfMissing = false
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open after repair failed: %v", err)
+	}
+	defer database.Close()
+
+	for _, key := range []string{"shared", "last"} {
+		got, err := database.Get(nil, []byte(key))
+		if err != nil || string(got) != "default" {
+			t.Errorf("Get(%s) = %q, %v; want default", key, got, err)
+		}
+	}
+	cf = database.GetColumnFamily("cf1")
+	if cf == nil {
+		t.Fatal("cf1 missing after repair")
+	}
+	for _, key := range []string{"shared", "flushed", "logged"} {
+		got, err := database.GetCF(nil, cf, []byte(key))
+		if err != nil || string(got) != "cf1" {
+			t.Errorf("GetCF(cf1, %s) = %q, %v; want cf1", key, got, err)
+		}
+	}
+
+	// cf2 has no table to name it, so its WAL records are dropped
+	if database.GetColumnFamily("cf2") != nil {
+		t.Error("cf2 recreated without a table")
+	}
+	for _, key := range []string{"flushed", "logged", "cf2_only"} {
+		if got, err := database.Get(nil, []byte(key)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) = %q, %v; want ErrNotFound", key, got, err)
+		}
+	}
+}
+
+func TestRepairDBUnknownColumnFamily(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	database.Close()
+
+	// A table written outside of any column family
+	f, err := os.Create(filepath.Join(dir, sstFileName(999)))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	builderOpts := table.DefaultBuilderOptions()
+	builderOpts.ColumnFamilyID = unknownColumnFamilyID
+	builderOpts.ColumnFamilyName = ""
+	builder := table.NewTableBuilder(f, builderOpts)
+	if err := builder.Add(dbformat.NewInternalKey([]byte("other"), 1, dbformat.TypeValue), []byte("value")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := builder.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	removeManifests(t, dir)
+
+	if err := RepairDB(dir, opts); !errors.Is(err, ErrCorruption) {
+		t.Errorf("RepairDB = %v, want ErrCorruption", err)
+	}
+}
+
+func TestRepairDBCustomComparator(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Comparator = reverseComparator{}
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for _, key := range []string{"a", "m", "z"} {
+		if err := database.Put(nil, []byte(key), []byte("value_"+key)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	database.Close()
+
+	removeManifests(t, dir)
+
+	if err := RepairDB(dir, opts); err != nil {
+		t.Fatalf("RepairDB failed: %v", err)
+	}
+
+	opts.CreateIfMissing = false
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open after repair failed: %v", err)
+	}
+	defer database.Close()
+
+	// In reverse order the tables span z to a
+	files := database.GetLiveFilesMetaData()
+	if len(files) == 0 {
+		t.Fatal("no live files after repair")
+	}
+	for _, f := range files {
+		smallest, largest := extractUserKey(f.SmallestKey), extractUserKey(f.LargestKey)
+		if string(smallest) != "z" || string(largest) != "a" {
+			t.Errorf("file %s bounds = [%q, %q], want [z, a]", f.Name, smallest, largest)
+		}
+	}
+}
+
+func TestRepairDBArchivesCorruptTable(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("good"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	database.Close()
+
+	junk := sstFileName(999)
+	if err := os.WriteFile(filepath.Join(dir, junk), []byte("not an sst file"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	removeManifests(t, dir)
+
+	if err := RepairDB(dir, opts); err != nil {
+		t.Fatalf("RepairDB failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, lostDirName, junk)); err != nil {
+		t.Errorf("corrupt table should be archived: %v", err)
+	}
+
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open after repair failed: %v", err)
+	}
+	defer database.Close()
+	got, err := database.Get(nil, []byte("good"))
+	if err != nil || string(got) != "value" {
+		t.Errorf("Get(good) = %q, %v; want value", got, err)
+	}
+}
+
+func TestRepairDBMissingDirectory(t *testing.T) {
+	err := RepairDB(filepath.Join(t.TempDir(), "missing"), DefaultOptions())
+	if !errors.Is(err, ErrDBNotFound) {
+		t.Errorf("RepairDB error = %v, want ErrDBNotFound", err)
+	}
+}
+
+func TestRepairDBRefusesWhenLocked(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if err := RepairDB(dir, DefaultOptions()); !errors.Is(err, ErrDBLocked) {
+		t.Fatalf("RepairDB on an open database = %v, want ErrDBLocked", err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Once the database is closed, repair runs and releases the lock
+	if err := RepairDB(dir, DefaultOptions()); err != nil {
+		t.Fatalf("RepairDB failed: %v", err)
+	}
+	database, err = Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open after RepairDB failed: %v", err)
+	}
+	defer database.Close()
+	if val, err := database.Get(nil, []byte("key")); err != nil || string(val) != "value" {
+		t.Errorf("Get = (%q, %v), want (%q, nil)", val, err, "value")
+	}
+}
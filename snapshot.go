@@ -10,31 +10,52 @@ package rockyardkv
 //   - db/snapshot_impl.h
 
 import (
+	"runtime"
+	"slices"
 	"sync/atomic"
-	"time"
 )
 
 // Snapshot provides a consistent read view of the database.
 // The contents of a snapshot are guaranteed to be consistent.
 type Snapshot struct {
-	db        *dbImpl
+	db       *dbImpl
+	sequence uint64
+	refs     atomic.Int32
+
+	// entry is the snapshot's node in the DB's snapshot list. It is kept
+	// apart from the Snapshot so that the list does not keep a leaked
+	// Snapshot reachable, which lets Options.DetectSnapshotLeaks notice it.
+	entry *snapshotEntry
+
+	// leakCheck reports the snapshot if it is collected before Release.
+	// Only set with Options.DetectSnapshotLeaks.
+	leakCheck runtime.Cleanup
+}
+
+// snapshotEntry is a live snapshot in the DB's snapshot list.
+type snapshotEntry struct {
 	sequence  uint64
-	refs      atomic.Int32
 	createdAt int64 // Unix timestamp when snapshot was created
 
 	// Linked list for snapshot management
-	prev *Snapshot
-	next *Snapshot
+	prev *snapshotEntry
+	next *snapshotEntry
 }
 
 // newSnapshot creates a new snapshot at the given sequence number.
 func newSnapshot(db *dbImpl, seq uint64) *Snapshot {
 	s := &Snapshot{
-		db:        db,
-		sequence:  seq,
-		createdAt: time.Now().Unix(),
+		db:       db,
+		sequence: seq,
+		entry: &snapshotEntry{
+			sequence:  seq,
+			createdAt: clockNow(db.env).Unix(),
+		},
 	}
 	s.refs.Store(1)
+	if db.options.DetectSnapshotLeaks {
+		s.leakCheck = runtime.AddCleanup(s, db.reportSnapshotLeak, s.entry)
+	}
 	return s
 }
 
@@ -47,9 +68,38 @@ func (s *Snapshot) Sequence() uint64 {
 // After calling Release, the snapshot should not be used.
 func (s *Snapshot) Release() {
 	if s.refs.Add(-1) == 0 {
+		s.leakCheck.Stop()
 		// Notify the DB to clean up
 		if s.db != nil {
 			s.db.releaseSnapshot(s)
 		}
 	}
 }
+
+// reportSnapshotLeak logs a snapshot that was garbage collected without
+// being released. The snapshot stays in the list, pinning its sequence
+// number, so that GetSnapshotCount keeps reporting the leak.
+func (db *dbImpl) reportSnapshotLeak(entry *snapshotEntry) {
+	db.logger.Warnf("[db] snapshot at sequence %d was garbage collected without ReleaseSnapshot", entry.sequence)
+}
+
+// GetSnapshotCount returns the number of snapshots that have not been
+// released.
+func (db *dbImpl) GetSnapshotCount() int {
+	return db.countSnapshots()
+}
+
+// GetAliveSnapshotSequences returns the sequence numbers of the snapshots
+// that have not been released, oldest first. Compaction keeps the versions
+// of keys that these snapshots can see.
+func (db *dbImpl) GetAliveSnapshotSequences() []uint64 {
+	db.snapshotLock.Lock()
+	defer db.snapshotLock.Unlock()
+
+	var seqs []uint64
+	for e := db.snapshots; e != nil; e = e.next {
+		seqs = append(seqs, e.sequence)
+	}
+	slices.Sort(seqs)
+	return seqs
+}
@@ -14,6 +14,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/aalhour/rockyardkv/internal/checksum"
@@ -39,6 +41,11 @@ var (
 
 	// ErrSstWriterEmptyFile is returned when trying to finish a file with no entries.
 	ErrSstWriterEmptyFile = errors.New("sst: cannot finish file with no entries")
+
+	// ErrSstWriterMultipleFiles is returned by Finish when MaxOutputFileSize
+	// is set, since the output may span several files; FinishAll returns
+	// them all.
+	ErrSstWriterMultipleFiles = errors.New("sst: output may span several files, use FinishAll")
 )
 
 // ExternalSstFileInfo contains information about an SST file created by SstFileWriter.
@@ -98,6 +105,14 @@ type SstFileWriterOptions struct {
 
 	// FormatVersion is the SST file format version.
 	FormatVersion uint32
+
+	// MaxOutputFileSize splits the output into several files, each kept at or
+	// below this many bytes. When adding an entry would push the current file
+	// past the limit, it is finished and the entry starts a new file named
+	// after the path given to Open with a "-000002", "-000003", ... suffix
+	// before the extension. 0 means a single file of unbounded size.
+	// A writer with a limit must be completed with FinishAll.
+	MaxOutputFileSize uint64
 }
 
 // DefaultSstFileWriterOptions returns default options for SstFileWriter.
@@ -126,6 +141,8 @@ func DefaultSstFileWriterOptions() SstFileWriterOptions {
 //	info, _ := writer.Finish()
 //
 // Keys MUST be added in sorted order according to the comparator.
+// With MaxOutputFileSize set, use FinishAll, not Finish, to collect every
+// output file.
 type SstFileWriter struct {
 	mu sync.Mutex
 
@@ -133,10 +150,14 @@ type SstFileWriter struct {
 	opts SstFileWriterOptions
 
 	// File state
+	basePath string
 	filePath string
 	file     *os.File
 	builder  *table.TableBuilder
 
+	// Files already finished by splitting on MaxOutputFileSize
+	outputs []*ExternalSstFileInfo
+
 	// Key tracking
 	lastKey     []byte
 	smallestKey []byte
@@ -182,6 +203,20 @@ func (w *SstFileWriter) Open(filePath string) error {
 		return ErrSstWriterAlreadyOpened
 	}
 
+	if err := w.openOutput(filePath); err != nil {
+		return err
+	}
+	w.basePath = filePath
+	w.outputs = nil
+	w.lastKey = nil
+	w.opened = true
+	w.finished = false
+
+	return nil
+}
+
+// openOutput creates the output file at filePath and resets the per-file state.
+func (w *SstFileWriter) openOutput(filePath string) error {
 	// Create the file
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -201,11 +236,8 @@ func (w *SstFileWriter) Open(filePath string) error {
 	w.file = file
 	w.filePath = filePath
 	w.builder = table.NewTableBuilder(file, builderOpts)
-	w.opened = true
-	w.finished = false
 
 	// Reset state
-	w.lastKey = nil
 	w.smallestKey = nil
 	w.largestKey = nil
 	w.rangeTombstones = nil
@@ -217,6 +249,33 @@ func (w *SstFileWriter) Open(filePath string) error {
 	return nil
 }
 
+// maybeRollOver finishes the current output file and opens the next one if
+// adding the given entry would exceed MaxOutputFileSize.
+func (w *SstFileWriter) maybeRollOver(internalKey, value []byte) error {
+	if w.opts.MaxOutputFileSize == 0 || w.numEntries == 0 {
+		return nil
+	}
+	// Entry bytes plus up to three varint headers in the data block.
+	entrySize := uint64(len(internalKey) + len(value) + 15)
+	if w.builder.EstimatedFileSize()+entrySize <= w.opts.MaxOutputFileSize {
+		return nil
+	}
+
+	info, err := w.finishOutput()
+	if err != nil {
+		return err
+	}
+	w.outputs = append(w.outputs, info)
+
+	ext := filepath.Ext(w.basePath)
+	next := fmt.Sprintf("%s-%06d%s", strings.TrimSuffix(w.basePath, ext), len(w.outputs)+1, ext)
+	if err := w.openOutput(next); err != nil {
+		w.finished = true
+		return err
+	}
+	return nil
+}
+
 // Put adds a key-value pair to the SST file.
 // Keys must be added in sorted order according to the comparator.
 func (w *SstFileWriter) Put(key, value []byte) error {
@@ -234,6 +293,9 @@ func (w *SstFileWriter) Put(key, value []byte) error {
 
 	// Create internal key with sequence number 0 and type Value
 	internalKey := dbformat.NewInternalKey(key, 0, dbformat.TypeValue)
+	if err := w.maybeRollOver(internalKey, value); err != nil {
+		return err
+	}
 
 	// Add to builder
 	if err := w.builder.Add(internalKey, value); err != nil {
@@ -264,6 +326,9 @@ func (w *SstFileWriter) Delete(key []byte) error {
 
 	// Create internal key with sequence number 0 and type Deletion
 	internalKey := dbformat.NewInternalKey(key, 0, dbformat.TypeDeletion)
+	if err := w.maybeRollOver(internalKey, nil); err != nil {
+		return err
+	}
 
 	// Add to builder with empty value
 	if err := w.builder.Add(internalKey, nil); err != nil {
@@ -278,7 +343,8 @@ func (w *SstFileWriter) Delete(key []byte) error {
 }
 
 // DeleteRange adds a range deletion [startKey, endKey) to the SST file.
-// Range deletions are stored in a separate meta-block.
+// Range deletions are stored in a separate meta-block of the output file
+// that is open when they are added.
 func (w *SstFileWriter) DeleteRange(startKey, endKey []byte) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -329,6 +395,9 @@ func (w *SstFileWriter) Merge(key, value []byte) error {
 
 	// Create internal key with sequence number 0 and type Merge
 	internalKey := dbformat.NewInternalKey(key, 0, dbformat.TypeMerge)
+	if err := w.maybeRollOver(internalKey, value); err != nil {
+		return err
+	}
 
 	// Add to builder
 	if err := w.builder.Add(internalKey, value); err != nil {
@@ -343,11 +412,36 @@ func (w *SstFileWriter) Merge(key, value []byte) error {
 }
 
 // Finish completes the SST file and returns information about it.
+// A writer with MaxOutputFileSize set may have split its output into
+// several files, so Finish fails for it with ErrSstWriterMultipleFiles and
+// leaves it open; complete it with FinishAll instead.
 // After calling Finish, the writer cannot be used again without calling Open.
 func (w *SstFileWriter) Finish() (*ExternalSstFileInfo, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.opts.MaxOutputFileSize != 0 {
+		return nil, ErrSstWriterMultipleFiles
+	}
+	infos, err := w.finishAll()
+	if err != nil {
+		return nil, err
+	}
+	return infos[0], nil
+}
+
+// FinishAll completes the SST output and returns information about every
+// file produced since Open, in key order.
+// After calling FinishAll, the writer cannot be used again without calling Open.
+func (w *SstFileWriter) FinishAll() ([]*ExternalSstFileInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.finishAll()
+}
+
+// finishAll completes the SST output.
+// REQUIRES: w.mu is held.
+func (w *SstFileWriter) finishAll() ([]*ExternalSstFileInfo, error) {
 	if !w.opened {
 		return nil, ErrSstWriterNotOpened
 	}
@@ -355,6 +449,18 @@ func (w *SstFileWriter) Finish() (*ExternalSstFileInfo, error) {
 		return nil, ErrSstWriterAlreadyFinished
 	}
 
+	info, err := w.finishOutput()
+	if err != nil {
+		w.removeOutputs()
+		return nil, err
+	}
+	w.finished = true
+
+	return append(w.outputs, info), nil
+}
+
+// finishOutput completes the current output file and returns information about it.
+func (w *SstFileWriter) finishOutput() (*ExternalSstFileInfo, error) {
 	// Check if we have any entries
 	if w.numEntries == 0 && w.numRangeDelEntries == 0 {
 		w.cleanup()
@@ -386,8 +492,7 @@ func (w *SstFileWriter) Finish() (*ExternalSstFileInfo, error) {
 	if err := w.file.Close(); err != nil {
 		return nil, fmt.Errorf("sst: failed to close file: %w", err)
 	}
-
-	w.finished = true
+	w.file = nil
 
 	// Build the file info
 	info := &ExternalSstFileInfo{
@@ -418,6 +523,7 @@ func (w *SstFileWriter) FileSize() uint64 {
 }
 
 // Abandon abandons the current file and cleans up resources.
+// Files already split off by MaxOutputFileSize are removed as well.
 func (w *SstFileWriter) Abandon() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -427,6 +533,7 @@ func (w *SstFileWriter) Abandon() error {
 	}
 
 	w.cleanup()
+	w.removeOutputs()
 	return nil
 }
 
@@ -475,6 +582,14 @@ func (w *SstFileWriter) compareKeys(a, b []byte) int {
 	return bytes.Compare(a, b)
 }
 
+// removeOutputs deletes the files already split off by MaxOutputFileSize.
+func (w *SstFileWriter) removeOutputs() {
+	for _, info := range w.outputs {
+		os.Remove(info.FilePath)
+	}
+	w.outputs = nil
+}
+
 // cleanup closes the file and removes it.
 func (w *SstFileWriter) cleanup() {
 	if w.file != nil {
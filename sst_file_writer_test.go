@@ -5,6 +5,7 @@ package rockyardkv
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -549,6 +550,91 @@ func TestSstFileWriter_LargeFile(t *testing.T) {
 	}
 }
 
+func TestSstFileWriter_MaxOutputFileSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	sstPath := filepath.Join(tmpDir, "split.sst")
+
+	const maxSize = 32 * 1024
+	opts := DefaultSstFileWriterOptions()
+	opts.MaxOutputFileSize = maxSize
+	writer := NewSstFileWriter(opts)
+	if err := writer.Open(sstPath); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	numEntries := 2000
+	for i := range numEntries {
+		key := fmt.Appendf(nil, "key%06d", i)
+		value := bytes.Repeat([]byte{byte(i)}, 100)
+		if err := writer.Put(key, value); err != nil {
+			t.Fatalf("Put %d failed: %v", i, err)
+		}
+	}
+
+	// Finish can describe only one file, so it refuses a writer that may split
+	if _, err := writer.Finish(); !errors.Is(err, ErrSstWriterMultipleFiles) {
+		t.Fatalf("Finish = %v, want ErrSstWriterMultipleFiles", err)
+	}
+
+	infos, err := writer.FinishAll()
+	if err != nil {
+		t.Fatalf("FinishAll failed: %v", err)
+	}
+	if len(infos) < 2 {
+		t.Fatalf("Expected multiple output files, got %d", len(infos))
+	}
+
+	var total uint64
+	paths := make([]string, 0, len(infos))
+	for i, info := range infos {
+		stat, err := os.Stat(info.FilePath)
+		if err != nil {
+			t.Fatalf("Stat %s failed: %v", info.FilePath, err)
+		}
+		if uint64(stat.Size()) != info.FileSize {
+			t.Errorf("File %d: info size %d, on-disk size %d", i, info.FileSize, stat.Size())
+		}
+		if info.FileSize > maxSize {
+			t.Errorf("File %d: size %d exceeds limit %d", i, info.FileSize, maxSize)
+		}
+		if i > 0 && bytes.Compare(infos[i-1].LargestKey, info.SmallestKey) >= 0 {
+			t.Errorf("File %d overlaps the previous file", i)
+		}
+		total += info.NumEntries
+		paths = append(paths, info.FilePath)
+	}
+	if infos[0].FilePath != sstPath {
+		t.Errorf("First file path = %s, want %s", infos[0].FilePath, sstPath)
+	}
+	if total != uint64(numEntries) {
+		t.Errorf("Expected %d entries across files, got %d", numEntries, total)
+	}
+
+	// Every file is ingestible, together and without global seqno
+	dbOpts := DefaultOptions()
+	dbOpts.CreateIfMissing = true
+	db, err := Open(filepath.Join(tmpDir, "db"), dbOpts)
+	if err != nil {
+		t.Fatalf("Open DB failed: %v", err)
+	}
+	defer db.Close()
+
+	ingestOpts := DefaultIngestExternalFileOptions()
+	ingestOpts.AllowGlobalSeqNo = false
+	if err := db.IngestExternalFile(paths, ingestOpts); err != nil {
+		t.Fatalf("IngestExternalFile failed: %v", err)
+	}
+	for _, i := range []int{0, numEntries / 2, numEntries - 1} {
+		val, err := db.Get(DefaultReadOptions(), fmt.Appendf(nil, "key%06d", i))
+		if err != nil {
+			t.Fatalf("Get key%06d failed: %v", i, err)
+		}
+		if !bytes.Equal(val, bytes.Repeat([]byte{byte(i)}, 100)) {
+			t.Errorf("Get key%06d returned wrong value", i)
+		}
+	}
+}
+
 func TestSstFileWriter_Merge(t *testing.T) {
 	tmpDir := t.TempDir()
 	sstPath := filepath.Join(tmpDir, "test.sst")
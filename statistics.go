@@ -490,3 +490,50 @@ func formatInt(n int64) string {
 	}
 	return s
 }
+
+// recordTick adds count to a ticker of Options.Statistics, if set.
+func (db *dbImpl) recordTick(tickerType TickerType, count uint64) {
+	if s := db.options.Statistics; s != nil {
+		s.RecordTick(tickerType, count)
+	}
+}
+
+// BloomStats holds the SST bloom filter counters of point lookups.
+type BloomStats struct {
+	// Useful is the number of lookups the filter ruled out, sparing the
+	// data block read (TickerBloomFilterUseful).
+	Useful uint64
+
+	// FullPositive is the number of lookups the filter let through
+	// (TickerBloomFilterFullPositive).
+	FullPositive uint64
+
+	// FullTruePositive is the number of lookups the filter let through
+	// that found the key (TickerBloomFilterFullTruePositive).
+	FullTruePositive uint64
+
+	// FalsePositiveRate is the share of lookups of absent keys that the
+	// filter let through: (FullPositive - FullTruePositive) /
+	// (FullPositive - FullTruePositive + Useful). It is 0 before any
+	// lookup of an absent key.
+	FalsePositiveRate float64
+}
+
+// GetBloomFilterStats returns the bloom filter counters collected by
+// Options.Statistics. Without Statistics all counters are 0.
+func (db *dbImpl) GetBloomFilterStats() BloomStats {
+	s := db.options.Statistics
+	if s == nil {
+		return BloomStats{}
+	}
+	stats := BloomStats{
+		Useful:           s.GetTickerCount(TickerBloomFilterUseful),
+		FullPositive:     s.GetTickerCount(TickerBloomFilterFullPositive),
+		FullTruePositive: s.GetTickerCount(TickerBloomFilterFullTruePositive),
+	}
+	falsePositives := stats.FullPositive - min(stats.FullTruePositive, stats.FullPositive)
+	if negatives := falsePositives + stats.Useful; negatives > 0 {
+		stats.FalsePositiveRate = float64(falsePositives) / float64(negatives)
+	}
+	return stats
+}
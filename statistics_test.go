@@ -3,6 +3,8 @@ package rockyardkv
 // statistics_test.go implements tests for statistics.
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 	"testing"
 )
@@ -251,3 +253,60 @@ func TestAllHistogramTypes(t *testing.T) {
 		}
 	}
 }
+
+// TestBloomFilterStats verifies the bloom filter counters of point lookups
+// and that fewer bits per key show a higher false-positive rate.
+func TestBloomFilterStats(t *testing.T) {
+	measure := func(bitsPerKey int) BloomStats {
+		t.Helper()
+		opts := DefaultOptions()
+		opts.BloomFilterBitsPerKey = bitsPerKey
+		opts.Statistics = NewStatistics()
+		db, cleanup := createTestDB(t, opts)
+		defer cleanup()
+
+		// Even keys are written, odd keys are absent but within the file
+		for i := 0; i < 4000; i += 2 {
+			if err := db.Put(nil, fmt.Appendf(nil, "key%06d", i), []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		if err := db.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		for i := range 3999 {
+			_, err := db.Get(nil, fmt.Appendf(nil, "key%06d", i))
+			if i%2 == 0 && err != nil {
+				t.Fatalf("Get(key%06d) failed: %v", i, err)
+			}
+			if i%2 == 1 && !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get(key%06d) = %v, want ErrNotFound", i, err)
+			}
+		}
+		return db.GetBloomFilterStats()
+	}
+
+	few, many := measure(2), measure(20)
+	for _, stats := range []BloomStats{few, many} {
+		if stats.FullTruePositive != 2000 {
+			t.Errorf("FullTruePositive = %d, want 2000", stats.FullTruePositive)
+		}
+		if got := stats.Useful + stats.FullPositive; got != 3999 {
+			t.Errorf("Useful + FullPositive = %d, want 3999", got)
+		}
+	}
+	if few.FalsePositiveRate <= many.FalsePositiveRate {
+		t.Errorf("FalsePositiveRate = %.4f with 2 bits per key, want more than %.4f with 20",
+			few.FalsePositiveRate, many.FalsePositiveRate)
+	}
+	if many.FalsePositiveRate > 0.01 {
+		t.Errorf("FalsePositiveRate = %.4f with 20 bits per key, want at most 0.01", many.FalsePositiveRate)
+	}
+
+	// Without Statistics nothing is collected
+	db, cleanup := createTestDB(t, DefaultOptions())
+	defer cleanup()
+	if stats := db.GetBloomFilterStats(); stats != (BloomStats{}) {
+		t.Errorf("GetBloomFilterStats() = %+v without Statistics, want zero", stats)
+	}
+}
@@ -0,0 +1,58 @@
+package rockyardkv
+
+// stats_dump.go implements the periodic statistics dump
+// (Options.StatsDumpPeriodSec).
+//
+// Reference: RocksDB v10.7.5
+//   - db/db_impl/db_impl.cc (DBImpl::DumpStats)
+//   - db/periodic_task_scheduler.cc (kDumpStats)
+
+import "time"
+
+// startStatsDump starts the goroutine that writes rocksdb.stats to the info
+// log every StatsDumpPeriodSec seconds.
+func (db *dbImpl) startStatsDump() {
+	if db.options.StatsDumpPeriodSec == 0 {
+		return
+	}
+	db.statsDumpStop = make(chan struct{})
+	db.statsDumpDone = make(chan struct{})
+	period := time.Duration(db.options.StatsDumpPeriodSec) * time.Second
+	go db.statsDumpLoop(period, db.statsDumpStop, db.statsDumpDone)
+}
+
+// stopStatsDump stops the statistics dump goroutine and waits for it to exit.
+func (db *dbImpl) stopStatsDump() {
+	if db.statsDumpStop == nil {
+		return
+	}
+	close(db.statsDumpStop)
+	<-db.statsDumpDone
+	db.statsDumpStop = nil
+}
+
+// statsDumpLoop dumps the statistics every period until stopCh is closed.
+func (db *dbImpl) statsDumpLoop(period time.Duration, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			db.dumpStats()
+		}
+	}
+}
+
+// dumpStats writes the rocksdb.stats property to the info log.
+func (db *dbImpl) dumpStats() {
+	stats, ok := db.GetProperty(PropertyStats)
+	if !ok {
+		return
+	}
+	db.logger.Infof("[db] ------- DUMPING STATS -------\n%s", stats)
+}
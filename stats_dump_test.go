@@ -0,0 +1,80 @@
+package rockyardkv
+
+// stats_dump_test.go implements tests for Options.StatsDumpPeriodSec.
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsDumpPeriodSec(t *testing.T) {
+	logger := &captureLogger{}
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Logger = logger
+	opts.StatsDumpPeriodSec = 1
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	dumps := func() []string {
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		var found []string
+		for _, msg := range logger.messages {
+			if strings.Contains(msg, "DUMPING STATS") {
+				found = append(found, msg)
+			}
+		}
+		return found
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for len(dumps()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	found := dumps()
+	if len(found) < 2 {
+		t.Fatalf("got %d stats dumps, want at least 2", len(found))
+	}
+	last := found[len(found)-1]
+	for _, want := range []string{
+		"** DB Stats **",
+		"Uptime(secs)",
+		"** Compaction Stats [default] **",
+		"Level Files Size(MB)",
+		"  0       1 ",
+		"Compaction pending:",
+	} {
+		if !strings.Contains(last, want) {
+			t.Errorf("stats dump missing %q:\n%s", want, last)
+		}
+	}
+}
+
+func TestStatsDumpDisabled(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.StatsDumpPeriodSec = 0
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	if database.(*dbImpl).statsDumpStop != nil {
+		t.Error("stats dump goroutine started with StatsDumpPeriodSec=0")
+	}
+}
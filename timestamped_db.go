@@ -110,39 +110,7 @@ func (t *TimestampedDB) GetWithTimestamp(opts *ReadOptions, key, timestamp []byt
 		return nil, nil, ErrInvalidTimestampSize
 	}
 
-	// Create an iterator to find the key
-	iter := t.db.NewIterator(opts)
-	defer func() { _ = iter.Close() }()
-
-	// Seek to the key with the specified timestamp
-	// Since larger timestamps come first, we seek to key+timestamp
-	// and check if it matches our user key
-	keyWithTS := AppendTimestampToKey(key, timestamp)
-	iter.Seek(keyWithTS)
-
-	if !iter.Valid() {
-		if err := iter.Error(); err != nil {
-			return nil, nil, err
-		}
-		return nil, nil, ErrNotFound
-	}
-
-	// Check if the found key matches our user key
-	foundKey := iter.Key()
-	foundUserKey, foundTimestamp := StripTimestampFromKey(foundKey, t.tsSize)
-
-	// Compare user keys
-	if t.comparator.CompareWithoutTimestamp(key, foundUserKey, false, false) != 0 {
-		return nil, nil, ErrNotFound
-	}
-
-	// Check if the found timestamp is <= requested timestamp
-	// (larger timestamps come first, so if foundTS > timestamp, we overshot)
-	if t.comparator.CompareTimestamp(foundTimestamp, timestamp) > 0 {
-		return nil, nil, ErrNotFound
-	}
-
-	return iter.Value(), foundTimestamp, nil
+	return getAtTimestamp(t.db, t.comparator, opts, nil, key, timestamp)
 }
 
 // Get retrieves the value for a key at the maximum timestamp.
@@ -320,12 +288,75 @@ func (ti *TimestampedIterator) Value() []byte {
 	return ti.iter.Value()
 }
 
-// Error returns any error encountered by the iterator.
+// Columns returns the wide columns at the current position.
+func (ti *TimestampedIterator) Columns() []WideColumn {
+	return ti.iter.Columns()
+}
+
+// Status returns the error that stopped the iterator, or nil.
+func (ti *TimestampedIterator) Status() error {
+	return ti.iter.Status()
+}
+
+// Error returns the same error as Status.
 func (ti *TimestampedIterator) Error() error {
-	return ti.iter.Error()
+	return ti.iter.Status()
 }
 
 // Close closes the iterator.
 func (ti *TimestampedIterator) Close() error {
 	return ti.iter.Close()
 }
+
+// getAtTimestamp returns the newest version of key in cf whose timestamp is
+// at or below timestamp, along with the timestamp it was written at.
+func getAtTimestamp(db DB, cmp TimestampedComparator, opts *ReadOptions, cf ColumnFamilyHandle, key, timestamp []byte) (value, foundTS []byte, err error) {
+	var iter Iterator
+	if cf == nil {
+		iter = db.NewIterator(opts)
+	} else {
+		iter = db.NewIteratorCF(opts, cf)
+	}
+	defer func() { _ = iter.Close() }()
+
+	// Since larger timestamps come first, seek to key+timestamp and check
+	// that the entry found belongs to the same user key
+	iter.Seek(AppendTimestampToKey(key, timestamp))
+	if !iter.Valid() {
+		if err := iter.Error(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrNotFound
+	}
+
+	tsSize := cmp.TimestampSize()
+	foundUserKey, foundTimestamp := StripTimestampFromKey(iter.Key(), tsSize)
+	if cmp.CompareWithoutTimestamp(key, foundUserKey, false, false) != 0 {
+		return nil, nil, ErrNotFound
+	}
+
+	// If the found timestamp is newer than requested, we overshot
+	if cmp.CompareTimestamp(foundTimestamp, timestamp) > 0 {
+		return nil, nil, ErrNotFound
+	}
+
+	return copySlice(iter.Value()), copySlice(foundTimestamp), nil
+}
+
+// timestampComparator returns the timestamp-aware comparator of cf, failing
+// when the column family does not store user-defined timestamps.
+func (db *dbImpl) timestampComparator(cf ColumnFamilyHandle) (TimestampedComparator, error) {
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return nil, err
+	}
+	cmp := cfd.options.Comparator
+	if cmp == nil {
+		cmp = db.comparator
+	}
+	tsCmp, ok := cmp.(TimestampedComparator)
+	if !ok || tsCmp.TimestampSize() == 0 {
+		return nil, ErrTimestampNotSupported
+	}
+	return tsCmp, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestTimestampedDBBasicOperations(t *testing.T) {
@@ -369,3 +370,140 @@ func TestTimestampedDBWithoutTimestampComparator(t *testing.T) {
 		t.Errorf("OpenTimestampedDB with non-timestamp comparator: expected ErrTimestampNotSupported, got %v", err)
 	}
 }
+
+func TestTransactionPutWithTimestamp(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Comparator = BytewiseComparatorWithU64Ts{}
+
+	database, err := Open(filepath.Join(t.TempDir(), "db"), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	tsdb, err := WrapWithTimestamp(database, BytewiseComparatorWithU64Ts{})
+	if err != nil {
+		t.Fatalf("WrapWithTimestamp failed: %v", err)
+	}
+	if err := tsdb.PutWithTimestamp(nil, []byte("a"), []byte("a@10"), EncodeU64Ts(10)); err != nil {
+		t.Fatalf("PutWithTimestamp failed: %v", err)
+	}
+
+	txn := database.BeginTransaction(DefaultTransactionOptions(), nil)
+	if err := txn.PutWithTimestamp(nil, []byte("a"), []byte("a@20"), EncodeU64Ts(20)); err != nil {
+		t.Fatalf("txn.PutWithTimestamp failed: %v", err)
+	}
+	if err := txn.PutWithTimestamp(nil, []byte("b"), []byte("b@30"), EncodeU64Ts(30)); err != nil {
+		t.Fatalf("txn.PutWithTimestamp failed: %v", err)
+	}
+	if err := txn.PutWithTimestamp(nil, []byte("a"), []byte("bad"), []byte{1}); !errors.Is(err, ErrInvalidTimestampSize) {
+		t.Fatalf("short timestamp: expected ErrInvalidTimestampSize, got %v", err)
+	}
+
+	// Pending versions are visible to the transaction at their timestamps
+	for _, tc := range []struct {
+		key, want string
+		ts        uint64
+	}{
+		{"a", "a@10", 15},
+		{"a", "a@20", 25},
+		{"b", "b@30", 30},
+	} {
+		val, err := txn.GetForUpdateWithTimestamp(nil, []byte(tc.key), EncodeU64Ts(tc.ts))
+		if err != nil {
+			t.Fatalf("GetForUpdateWithTimestamp(%s@%d) failed: %v", tc.key, tc.ts, err)
+		}
+		if string(val) != tc.want {
+			t.Errorf("GetForUpdateWithTimestamp(%s@%d) = %q, want %q", tc.key, tc.ts, val, tc.want)
+		}
+	}
+	if _, err := txn.GetForUpdateWithTimestamp(nil, []byte("b"), EncodeU64Ts(29)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetForUpdateWithTimestamp(b@29): expected ErrNotFound, got %v", err)
+	}
+
+	if _, _, err := tsdb.GetWithTimestamp(nil, []byte("b"), EncodeU64Ts(30)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("uncommitted write visible outside transaction: %v", err)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		key, want string
+		ts, found uint64
+	}{
+		{"a", "a@10", 19, 10},
+		{"a", "a@20", 20, 20},
+		{"b", "b@30", 100, 30},
+	} {
+		val, foundTS, err := tsdb.GetWithTimestamp(nil, []byte(tc.key), EncodeU64Ts(tc.ts))
+		if err != nil {
+			t.Fatalf("GetWithTimestamp(%s@%d) failed: %v", tc.key, tc.ts, err)
+		}
+		if string(val) != tc.want || !bytes.Equal(foundTS, EncodeU64Ts(tc.found)) {
+			t.Errorf("GetWithTimestamp(%s@%d) = %q at %v, want %q at %d", tc.key, tc.ts, val, foundTS, tc.want, tc.found)
+		}
+	}
+	if _, _, err := tsdb.GetWithTimestamp(nil, []byte("b"), EncodeU64Ts(29)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetWithTimestamp(b@29): expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPessimisticTransactionPutWithTimestamp(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.Comparator = BytewiseComparatorWithU64Ts{}
+
+	txnDB, err := OpenTransactionDB(filepath.Join(t.TempDir(), "db"), opts, DefaultTransactionDBOptions())
+	if err != nil {
+		t.Fatalf("OpenTransactionDB failed: %v", err)
+	}
+	defer txnDB.Close()
+
+	txnOpts := DefaultPessimisticTransactionOptions()
+	txnOpts.LockTimeout = 10 * time.Millisecond
+
+	txn1 := txnDB.BeginTransaction(txnOpts, nil)
+	if err := txn1.PutWithTimestamp(nil, []byte("k1"), []byte("v1"), EncodeU64Ts(5)); err != nil {
+		t.Fatalf("PutWithTimestamp failed: %v", err)
+	}
+	if err := txn1.PutWithTimestamp(nil, []byte("k2"), []byte("v2"), EncodeU64Ts(7)); err != nil {
+		t.Fatalf("PutWithTimestamp failed: %v", err)
+	}
+
+	// Locks cover the user key, so another version of k1 must wait
+	txn2 := txnDB.BeginTransaction(txnOpts, nil)
+	if _, err := txn2.GetForUpdateWithTimestamp(nil, []byte("k1"), EncodeU64Ts(9)); err == nil {
+		t.Fatal("GetForUpdateWithTimestamp acquired a lock held by another transaction")
+	}
+	_ = txn2.Rollback()
+
+	if err := txn1.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	tsdb, err := WrapWithTimestamp(txnDB.GetDB(), BytewiseComparatorWithU64Ts{})
+	if err != nil {
+		t.Fatalf("WrapWithTimestamp failed: %v", err)
+	}
+	for key, ts := range map[string]uint64{"k1": 5, "k2": 7} {
+		val, foundTS, err := tsdb.GetWithTimestamp(nil, []byte(key), EncodeU64Ts(ts))
+		if err != nil {
+			t.Fatalf("GetWithTimestamp(%s@%d) failed: %v", key, ts, err)
+		}
+		if !bytes.Equal(foundTS, EncodeU64Ts(ts)) {
+			t.Errorf("GetWithTimestamp(%s@%d) found timestamp %v", key, ts, foundTS)
+		}
+		if _, _, err := tsdb.GetWithTimestamp(nil, []byte(key), EncodeU64Ts(ts-1)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetWithTimestamp(%s@%d): expected ErrNotFound, got %v (value %q)", key, ts-1, err, val)
+		}
+	}
+
+	txn3 := txnDB.BeginTransaction(txnOpts, nil)
+	val, err := txn3.GetForUpdateWithTimestamp(nil, []byte("k1"), EncodeU64Ts(9))
+	if err != nil || string(val) != "v1" {
+		t.Fatalf("GetForUpdateWithTimestamp(k1@9) = %q, %v", val, err)
+	}
+	_ = txn3.Rollback()
+}
@@ -11,6 +11,7 @@ package rockyardkv
 //   - utilities/transactions/optimistic_transaction.h
 
 import (
+	"bytes"
 	"errors"
 	"sync"
 
@@ -29,11 +30,23 @@ var (
 
 	// ErrTransactionClosed is returned when operating on a closed transaction.
 	ErrTransactionClosed = errors.New("db: transaction is closed")
+
+	// ErrTransactionInvalidRange is returned by DeleteRange when begin is
+	// not before end.
+	ErrTransactionInvalidRange = errors.New("db: transaction range begin is not before end")
 )
 
 // TransactionOptions configures a transaction.
 type TransactionOptions struct {
 	// SetSnapshot determines if the transaction should set a snapshot at creation.
+	// With a snapshot, reads see the database as of the snapshot and Commit
+	// fails with ErrTransactionConflict if a key the transaction read or
+	// wrote changed after it (snapshot isolation). Without one, only changes
+	// after the transaction first accessed the key conflict (read
+	// committed), though Get still reads from a snapshot pinned by the
+	// first Get, so repeated reads see one point in time.
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/utilities/transaction_db.h (TransactionOptions::set_snapshot)
 	SetSnapshot bool
 }
 
@@ -67,12 +80,26 @@ type Transaction interface {
 	// For pessimistic transactions, this acquires a lock.
 	GetForUpdate(key []byte, exclusive bool) ([]byte, error)
 
+	// PutWithTimestamp sets the value for key at the user-defined timestamp
+	// ts. The column family's comparator must support timestamps.
+	PutWithTimestamp(cf ColumnFamilyHandle, key, value, ts []byte) error
+
+	// GetForUpdateWithTimestamp retrieves the newest version of key at or
+	// below ts and, like GetForUpdate, locks or tracks the key.
+	GetForUpdateWithTimestamp(cf ColumnFamilyHandle, key, ts []byte) ([]byte, error)
+
 	// Delete removes the key from the transaction.
 	Delete(key []byte) error
 
 	// DeleteCF removes the key from the specified column family.
 	DeleteCF(cf ColumnFamilyHandle, key []byte) error
 
+	// DeleteRange removes the keys in [begin, end) from the specified
+	// column family. Commit returns ErrTransactionConflict if the keys or
+	// values in the range changed after the transaction's snapshot. An
+	// empty range, with begin not before end, is ErrTransactionInvalidRange.
+	DeleteRange(cf ColumnFamilyHandle, begin, end []byte) error
+
 	// Commit validates and applies the transaction.
 	// Returns ErrTransactionConflict if there are write conflicts.
 	Commit() error
@@ -83,10 +110,20 @@ type Transaction interface {
 	// SetSnapshot sets the transaction's snapshot to the current database state.
 	SetSnapshot()
 
-	// GetSnapshot returns the transaction's snapshot.
+	// GetSnapshot returns the transaction's snapshot, set at creation or by
+	// SetSnapshot, or else the one pinned by its first Get for reads, or nil
+	// if it has none yet.
 	GetSnapshot() *Snapshot
 }
 
+// trackedRange is a key range deleted by a transaction, tracked for
+// conflict detection.
+type trackedRange struct {
+	cf         ColumnFamilyHandle
+	begin, end []byte
+	snap       *Snapshot // Held by the range until the transaction closes
+}
+
 // trackedKey represents a key tracked for conflict detection.
 type trackedKey struct {
 	cfID     uint32
@@ -108,9 +145,15 @@ type optimisticTransaction struct {
 	// Snapshot for consistent reads
 	snapshot *Snapshot
 
+	// Snapshot pinned by the first Get without one; used for reads only
+	readSnap *Snapshot
+
 	// Tracked keys for conflict detection
 	trackedKeys map[string]trackedKey
 
+	// Ranges deleted by the transaction, validated against the snapshot
+	trackedRanges []trackedRange
+
 	// Write options
 	writeOpts *WriteOptions
 
@@ -172,15 +215,21 @@ func (txn *optimisticTransaction) Get(key []byte) ([]byte, error) {
 }
 
 // GetForUpdate retrieves the value and marks it for conflict detection.
-// For optimistic transactions, this is equivalent to Get but tracks for validation.
+// For optimistic transactions, this is equivalent to Get but tracks for
+// validation, and without a snapshot set it reads the latest value.
 func (txn *optimisticTransaction) GetForUpdate(key []byte, exclusive bool) ([]byte, error) {
-	// For optimistic transactions, GetForUpdate is just Get with tracking
 	// The 'exclusive' flag is ignored since we use optimistic concurrency
-	return txn.Get(key)
+	return txn.get(nil, key, true)
 }
 
 // GetCF retrieves the value from the specified column family.
 func (txn *optimisticTransaction) GetCF(cf ColumnFamilyHandle, key []byte) ([]byte, error) {
+	return txn.get(cf, key, false)
+}
+
+// get reads key from cf. Without a snapshot set, a plain read uses the
+// snapshot pinned by the first one, and a read for update the latest value.
+func (txn *optimisticTransaction) get(cf ColumnFamilyHandle, key []byte, forUpdate bool) ([]byte, error) {
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
@@ -207,8 +256,10 @@ func (txn *optimisticTransaction) GetCF(cf ColumnFamilyHandle, key []byte) ([]by
 
 	// Read from database using snapshot
 	readOpts := DefaultReadOptions()
-	if txn.snapshot != nil {
+	if forUpdate {
 		readOpts.Snapshot = txn.snapshot
+	} else {
+		readOpts.Snapshot = txn.pinReadSnapshot()
 	}
 
 	if cf == nil {
@@ -224,6 +275,7 @@ func (txn *optimisticTransaction) getFromWriteBatch(cfID uint32, key []byte) ([]
 	handler := &txnBatchReader{
 		targetCFID: cfID,
 		targetKey:  key,
+		cmp:        txn.db.cmp.Compare,
 	}
 	_ = txn.writeBatch.Iterate(handler)
 	return handler.value, handler.found, handler.deleted
@@ -261,6 +313,74 @@ func (txn *optimisticTransaction) DeleteCF(cf ColumnFamilyHandle, key []byte) er
 	return nil
 }
 
+// DeleteRange removes the keys in [begin, end) from the specified column
+// family. The range is validated at commit against the transaction's
+// snapshot, or without one against the database as of this call.
+func (txn *optimisticTransaction) DeleteRange(cf ColumnFamilyHandle, begin, end []byte) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.closed {
+		return ErrTransactionClosed
+	}
+
+	if txn.db.cmp.Compare(begin, end) >= 0 {
+		return ErrTransactionInvalidRange
+	}
+
+	// Track the range for conflict detection
+	snap := txn.snapshot
+	if snap != nil {
+		snap.refs.Add(1)
+	} else {
+		snap = txn.db.GetSnapshot()
+	}
+	txn.trackedRanges = append(txn.trackedRanges, trackedRange{cf: cf, begin: bytes.Clone(begin), end: bytes.Clone(end), snap: snap})
+
+	// Add to write batch
+	if cf == nil || cf.ID() == 0 {
+		txn.writeBatch.DeleteRange(begin, end)
+	} else {
+		txn.writeBatch.DeleteRangeCF(cf.ID(), begin, end)
+	}
+
+	return nil
+}
+
+// rangeChangedSince reports whether the keys or values in [begin, end) of
+// cf differ from those at snap.
+func rangeChangedSince(db *dbImpl, cf ColumnFamilyHandle, begin, end []byte, snap *Snapshot) (bool, error) {
+	newIter := func(snap *Snapshot) Iterator {
+		opts := DefaultReadOptions()
+		opts.Snapshot = snap
+		opts.IterateUpperBound = end
+		if cf == nil {
+			return db.NewIterator(opts)
+		}
+		return db.NewIteratorCF(opts, cf)
+	}
+	before, now := newIter(snap), newIter(nil)
+	defer before.Close()
+	defer now.Close()
+
+	before.Seek(begin)
+	now.Seek(begin)
+	for before.Valid() && now.Valid() {
+		if !bytes.Equal(before.Key(), now.Key()) || !bytes.Equal(before.Value(), now.Value()) {
+			return true, nil
+		}
+		before.Next()
+		now.Next()
+	}
+	if err := before.Status(); err != nil {
+		return false, err
+	}
+	if err := now.Status(); err != nil {
+		return false, err
+	}
+	return before.Valid() != now.Valid(), nil
+}
+
 // Commit validates and applies the transaction.
 func (txn *optimisticTransaction) Commit() error {
 	txn.mu.Lock()
@@ -317,7 +437,25 @@ func (txn *optimisticTransaction) SetSnapshot() {
 func (txn *optimisticTransaction) GetSnapshot() *Snapshot {
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
-	return txn.snapshot
+	if txn.snapshot != nil {
+		return txn.snapshot
+	}
+	return txn.readSnap
+}
+
+// pinReadSnapshot returns the snapshot for Get: the transaction's snapshot,
+// or else one pinned by the first Get so that repeated reads of keys the
+// transaction did not write return the same values. The pinned snapshot
+// is not used for conflict detection.
+// REQUIRES: txn.mu is held.
+func (txn *optimisticTransaction) pinReadSnapshot() *Snapshot {
+	if txn.snapshot != nil {
+		return txn.snapshot
+	}
+	if txn.readSnap == nil {
+		txn.readSnap = txn.db.GetSnapshot()
+	}
+	return txn.readSnap
 }
 
 // trackKey adds a key to the set of tracked keys for conflict detection.
@@ -379,62 +517,106 @@ func (txn *optimisticTransaction) checkForConflicts() error {
 			}
 		}
 
-		// Check if the key has been modified after our tracked sequence number
-		// This is a simplified check - we look for the key's latest version
-		latestSeq := txn.getLatestSeqForKey(cfd, []byte(tracked.key))
+		// Check if the key has been modified after our tracked sequence
+		// number, which is the snapshot's if the transaction has one
+		latestSeq, err := txn.getLatestSeqForKey(cfd, []byte(tracked.key))
+		if err != nil {
+			return err
+		}
 		if latestSeq > tracked.seqNum {
 			// Key was modified after our read/write
 			return ErrTransactionConflict
 		}
 	}
+	for _, r := range txn.trackedRanges {
+		changed, err := rangeChangedSince(txn.db, r.cf, r.begin, r.end, r.snap)
+		if err != nil {
+			return err
+		}
+		if changed {
+			return ErrTransactionConflict
+		}
+	}
 	return nil
 }
 
-// getLatestSeqForKey gets the latest sequence number for a key.
-func (txn *optimisticTransaction) getLatestSeqForKey(cfd *columnFamilyData, key []byte) dbformat.SequenceNumber {
-	// Check memtable
-	var mem *memtable.MemTable
-	txn.db.mu.RLock()
+// getLatestSeqForKey returns the sequence number of the newest entry of key,
+// a value or a deletion, or 0 if there is none. The memtables are searched
+// first, then the SST files.
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc (GetLatestSequenceForKey)
+func (txn *optimisticTransaction) getLatestSeqForKey(cfd *columnFamilyData, key []byte) (dbformat.SequenceNumber, error) {
+	db := txn.db
+	var mems []*memtable.MemTable
+	db.mu.RLock()
 	if cfd.id == DefaultColumnFamilyID {
-		mem = txn.db.mem
+		mems = append(mems, db.mem)
+		mems = append(mems, db.imm...)
+		mems = append(mems, db.immHistory...)
 	} else {
 		cfd.memMu.RLock()
-		mem = cfd.mem
+		mems = append(mems, cfd.mem)
+		mems = append(mems, cfd.imm...)
 		cfd.memMu.RUnlock()
 	}
-	txn.db.mu.RUnlock()
-
-	if mem != nil {
-		// Search the memtable for the latest version of this key
-		iter := mem.NewIterator()
-		iter.Seek(key)
-		if iter.Valid() {
-			// Parse the internal key to get the sequence number
-			internalKey := iter.Key()
-			if len(internalKey) >= 8 {
-				// Internal key format: user_key + 8-byte trailer
-				// Trailer: (seq << 8) | type
-				userKey := internalKey[:len(internalKey)-8]
-				if string(userKey) == string(key) {
-					trailer := internalKey[len(internalKey)-8:]
-					seqAndType := uint64(trailer[0]) |
-						uint64(trailer[1])<<8 |
-						uint64(trailer[2])<<16 |
-						uint64(trailer[3])<<24 |
-						uint64(trailer[4])<<32 |
-						uint64(trailer[5])<<40 |
-						uint64(trailer[6])<<48 |
-						uint64(trailer[7])<<56
-					seq := dbformat.SequenceNumber(seqAndType >> 8)
-					return seq
-				}
+	current := db.versions.Current()
+	if current != nil {
+		current.Ref()
+	}
+	db.mu.RUnlock()
+	if current != nil {
+		defer current.Unref()
+	}
+
+	// Memtables are newest first, so the first entry found is the newest
+	for _, mem := range mems {
+		if mem == nil {
+			continue
+		}
+		if seq, ok := latestSeqInMemTable(mem, key); ok {
+			return seq, nil
+		}
+	}
+	if current == nil {
+		return 0, nil
+	}
+
+	// Files of different levels may overlap, so take the newest entry of all
+	var latest dbformat.SequenceNumber
+	for level := range current.NumLevels() {
+		for _, f := range current.Files(level) {
+			if f.ColumnFamilyID != cfd.id ||
+				db.cmp.Compare(key, extractUserKey(f.Smallest)) < 0 ||
+				db.cmp.Compare(key, extractUserKey(f.Largest)) > 0 ||
+				dbformat.SequenceNumber(f.FD.LargestSeqno) <= latest {
+				continue
+			}
+			_, found, _, _, seq, err := db.getFromFile(f, key, dbformat.MaxSequenceNumber, nil)
+			if err != nil {
+				return 0, err
+			}
+			if found && seq > latest {
+				latest = seq
 			}
 		}
 	}
+	return latest, nil
+}
 
-	// If not in memtable, the key's sequence is older than our snapshot
-	// (or doesn't exist), so no conflict
-	return 0
+// latestSeqInMemTable returns the sequence number of the newest entry of key
+// in mem, and whether there is one.
+func latestSeqInMemTable(mem *memtable.MemTable, key []byte) (dbformat.SequenceNumber, bool) {
+	iter := mem.NewIterator()
+	iter.Seek(key)
+	if !iter.Valid() {
+		return 0, false
+	}
+	// Internal key format: user_key + 8-byte trailer of (seq << 8) | type
+	internalKey := iter.Key()
+	if len(internalKey) < dbformat.NumInternalBytes ||
+		string(internalKey[:len(internalKey)-dbformat.NumInternalBytes]) != string(key) {
+		return 0, false
+	}
+	return dbformat.ExtractSequenceNumber(internalKey), true
 }
 
 // close releases resources and marks the transaction as closed.
@@ -443,6 +625,14 @@ func (txn *optimisticTransaction) close() {
 		txn.db.ReleaseSnapshot(txn.snapshot)
 		txn.snapshot = nil
 	}
+	if txn.readSnap != nil {
+		txn.db.ReleaseSnapshot(txn.readSnap)
+		txn.readSnap = nil
+	}
+	for _, r := range txn.trackedRanges {
+		txn.db.ReleaseSnapshot(r.snap)
+	}
+	txn.trackedRanges = nil
 	txn.writeBatch = nil
 	txn.trackedKeys = nil
 	txn.closed = true
@@ -452,6 +642,7 @@ func (txn *optimisticTransaction) close() {
 type txnBatchReader struct {
 	targetCFID uint32
 	targetKey  []byte
+	cmp        func(a, b []byte) int // orders range deletion bounds
 	found      bool
 	deleted    bool
 	value      []byte
@@ -501,11 +692,22 @@ func (r *txnBatchReader) SingleDeleteCF(cfID uint32, key []byte) error {
 	return r.DeleteCF(cfID, key)
 }
 
-func (r *txnBatchReader) Merge(key, value []byte) error                      { return nil }
-func (r *txnBatchReader) MergeCF(cfID uint32, key, value []byte) error       { return nil }
-func (r *txnBatchReader) DeleteRange(start, end []byte) error                { return nil }
-func (r *txnBatchReader) DeleteRangeCF(cfID uint32, start, end []byte) error { return nil }
-func (r *txnBatchReader) LogData(blob []byte)                                {}
+func (r *txnBatchReader) Merge(key, value []byte) error                { return nil }
+func (r *txnBatchReader) MergeCF(cfID uint32, key, value []byte) error { return nil }
+func (r *txnBatchReader) LogData(blob []byte)                          {}
+
+func (r *txnBatchReader) DeleteRange(start, end []byte) error {
+	return r.DeleteRangeCF(0, start, end)
+}
+
+func (r *txnBatchReader) DeleteRangeCF(cfID uint32, start, end []byte) error {
+	if cfID == r.targetCFID && r.cmp(r.targetKey, start) >= 0 && r.cmp(r.targetKey, end) < 0 {
+		r.found = true
+		r.deleted = true
+		r.value = nil
+	}
+	return nil
+}
 
 func bytesEqual(a, b []byte) bool {
 	if len(a) != len(b) {
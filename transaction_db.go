@@ -10,9 +10,14 @@ package rockyardkv
 //   - utilities/transactions/pessimistic_transaction_db.cc
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/aalhour/rockyardkv/internal/txn"
 )
@@ -33,6 +38,17 @@ type TransactionDB struct {
 	mu         sync.RWMutex
 	activeTxns map[uint64]*PessimisticTransaction
 
+	// Key ranges locked by DeleteRange of active transactions
+	rangeMu    sync.Mutex
+	rangeLocks []*rangeLock
+
+	// Names of the named transactions not finished yet, and the WAL file
+	// holding the prepare section of each prepared one. prepMu is taken
+	// last, after any other lock.
+	prepMu       sync.Mutex
+	names        map[string]uint64 // name -> transaction ID
+	preparedLogs map[uint64]uint64 // transaction ID -> WAL file number
+
 	// Configuration
 	opts TransactionDBOptions
 }
@@ -42,76 +58,164 @@ type TransactionDBOptions struct {
 	// LockManagerOptions configures the lock manager.
 	LockManagerOptions LockManagerOptions
 
-	// MaxNumLocks is the maximum number of locks to track (0 = unlimited).
-	MaxNumLocks uint64
-
-	// NumStripes is the number of lock stripes for lock manager striping (for future use).
+	// MaxNumLocks is the maximum number of keys locked at the same time.
+	// Once reached, a transaction locking another key, e.g. by Put or
+	// GetForUpdate, fails with ErrLockLimit. Not positive = unlimited.
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/utilities/transaction_db.h (TransactionDBOptions::max_num_locks)
+	MaxNumLocks int64
+
+	// NumStripes is the number of stripes the lock manager shards the locks
+	// into by key hash, each with its own mutex. More stripes reduce the
+	// contention of transactions locking different keys (0 = 1 stripe).
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/utilities/transaction_db.h (TransactionDBOptions::num_stripes)
 	NumStripes int
 
 	// TransactionLockTimeout is the default lock timeout for transactions.
 	TransactionLockTimeout int64 // in milliseconds
+
+	// DefaultWriteTimeout is how long Write waits for the lock of each key
+	// in its batch before failing with ErrLockTimeout. Not positive = the
+	// lock manager's default timeout.
+	//
+	// Reference: RocksDB v10.7.5 include/rocksdb/utilities/transaction_db.h (TransactionDBOptions::default_write_timeout)
+	DefaultWriteTimeout int64 // in milliseconds
 }
 
+// ErrTransactionDBDeleteRange is returned by TransactionDB.Write for a batch
+// holding a range deletion, whose keys cannot be locked.
+var ErrTransactionDBDeleteRange = errors.New("transactiondb: DeleteRange in a locked write batch is not supported")
+
 // DefaultTransactionDBOptions returns default options.
 func DefaultTransactionDBOptions() TransactionDBOptions {
 	return TransactionDBOptions{
 		LockManagerOptions:     DefaultLockManagerOptions(),
-		MaxNumLocks:            0,
+		MaxNumLocks:            -1,
 		NumStripes:             16,
 		TransactionLockTimeout: 5000,
+		DefaultWriteTimeout:    1000,
 	}
 }
 
-// OpenTransactionDB opens or creates a TransactionDB.
+// OpenTransactionDB opens or creates a TransactionDB. Transactions prepared
+// before the database was closed or crashed, and neither committed nor
+// rolled back, are restored as prepared; see GetAllPreparedTransactions.
+//
+// Reference: RocksDB v10.7.5 utilities/transactions/pessimistic_transaction_db.cc (PessimisticTransactionDB::Initialize)
 func OpenTransactionDB(path string, dbOpts *Options, txnDBOpts TransactionDBOptions) (*TransactionDB, error) {
+	txnDB, err := openTransactionDB(path, dbOpts, txnDBOpts, prepareAfterCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	db := txnDB.db
+	db.mu.Lock()
+	recovered := db.recoveredPrepared
+	db.recoveredPrepared = nil
+	db.minLogWithPrep = txnDB.minLogWithPrep
+	db.mu.Unlock()
+
+	for name, prep := range recovered {
+		if err := txnDB.restorePrepared(name, prep); err != nil {
+			_ = txnDB.Close()
+			return nil, err
+		}
+	}
+	return txnDB, nil
+}
+
+// openTransactionDB opens the database of a TransactionDB, replaying the WAL
+// as prepares says; see openDB.
+func openTransactionDB(path string, dbOpts *Options, txnDBOpts TransactionDBOptions, prepares prepareReplay) (*TransactionDB, error) {
 	opts := dbOpts
 	if opts == nil {
 		opts = DefaultOptions()
 	}
 
 	// Open the underlying database
-	database, err := Open(path, opts)
+	db, err := openDB(path, opts, prepares)
 	if err != nil {
 		return nil, err
 	}
+	return newTransactionDB(db, txnDBOpts), nil
+}
 
-	// Cast to *dbImpl
+// WrapDB wraps an existing database as a TransactionDB. Prepared
+// transactions are only restored by OpenTransactionDB.
+func WrapDB(database DB, txnDBOpts TransactionDBOptions) (*TransactionDB, error) {
 	dbImpl, ok := database.(*dbImpl)
 	if !ok {
-		_ = database.Close()
 		return nil, fmt.Errorf("transactiondb: requires rockyardkv DB implementation, got %T", database)
 	}
+	return newTransactionDB(dbImpl, txnDBOpts), nil
+}
 
-	txnDB := &TransactionDB{
-		db:          dbImpl,
-		lockManager: txn.NewLockManager(txnDBOpts.LockManagerOptions),
-		activeTxns:  make(map[uint64]*PessimisticTransaction),
-		opts:        txnDBOpts,
+// newTransactionDB creates a TransactionDB over db.
+func newTransactionDB(db *dbImpl, txnDBOpts TransactionDBOptions) *TransactionDB {
+	return &TransactionDB{
+		db:           db,
+		lockManager:  newLockManager(txnDBOpts),
+		activeTxns:   make(map[uint64]*PessimisticTransaction),
+		names:        make(map[string]uint64),
+		preparedLogs: make(map[uint64]uint64),
+		opts:         txnDBOpts,
 	}
-
-	return txnDB, nil
 }
 
-// WrapDB wraps an existing database as a TransactionDB.
-func WrapDB(database DB, txnDBOpts TransactionDBOptions) (*TransactionDB, error) {
-	dbImpl, ok := database.(*dbImpl)
-	if !ok {
-		return nil, fmt.Errorf("transactiondb: requires rockyardkv DB implementation, got %T", database)
+// restorePrepared recreates a transaction recovered prepared from the WAL.
+// It locks the keys and ranges it writes again, so that no other transaction
+// writes them before it is committed or rolled back.
+//
+// Reference: RocksDB v10.7.5 utilities/transactions/pessimistic_transaction_db.cc (PessimisticTransactionDB::Initialize)
+func (txnDB *TransactionDB) restorePrepared(name string, prep *recoveredPrepare) error {
+	opts := DefaultPessimisticTransactionOptions()
+	opts.SetSnapshot = false
+	txn := newPessimisticTransaction(txnDB, opts, DefaultWriteOptions())
+	txn.name = name
+	txn.writeBatch = prep.batch
+	txn.prepared = true
+
+	collector := &batchKeyCollector{}
+	if err := prep.batch.Iterate(collector); err != nil {
+		return fmt.Errorf("transactiondb: recovered transaction %q: %w", name, err)
 	}
-	return &TransactionDB{
-		db:          dbImpl,
-		lockManager: txn.NewLockManager(txnDBOpts.LockManagerOptions),
-		activeTxns:  make(map[uint64]*PessimisticTransaction),
-		opts:        txnDBOpts,
-	}, nil
+	for _, key := range collector.keys {
+		if err := txn.tryLock(key, LockTypeExclusive); err != nil {
+			return fmt.Errorf("transactiondb: recovered transaction %q: %w", name, err)
+		}
+	}
+	for _, r := range collector.ranges {
+		if _, err := txnDB.lockRange(txn.id, r[0], r[1], txn.opts.LockTimeout); err != nil {
+			return fmt.Errorf("transactiondb: recovered transaction %q: %w", name, err)
+		}
+	}
+
+	txnDB.mu.Lock()
+	txnDB.activeTxns[txn.id] = txn
+	txnDB.mu.Unlock()
+	txnDB.prepMu.Lock()
+	txnDB.names[name] = txn.id
+	txnDB.preparedLogs[txn.id] = prep.logNumber
+	txnDB.prepMu.Unlock()
+	return nil
+}
+
+// newLockManager creates the lock manager of a TransactionDB.
+func newLockManager(txnDBOpts TransactionDBOptions) *txn.LockManager {
+	lmOpts := txnDBOpts.LockManagerOptions
+	lmOpts.NumStripes = txnDBOpts.NumStripes
+	lmOpts.MaxNumLocks = txnDBOpts.MaxNumLocks
+	return txn.NewLockManager(lmOpts)
 }
 
 // Close closes the TransactionDB and the underlying database.
 func (txnDB *TransactionDB) Close() error {
-	// Rollback all active transactions
+	// Rollback all active transactions. Prepared ones stay prepared in the
+	// WAL, to be restored by the next OpenTransactionDB.
 	txnDB.mu.Lock()
 	for _, txn := range txnDB.activeTxns {
-		_ = txn.Rollback() // Best-effort rollback during close
+		_ = txn.rollback(false) // Best-effort rollback during close
 	}
 	txnDB.activeTxns = nil
 	txnDB.mu.Unlock()
@@ -146,18 +250,87 @@ func (txnDB *TransactionDB) GetTransactionByID(txnID uint64) *PessimisticTransac
 	return txnDB.activeTxns[txnID]
 }
 
-// GetAllPreparedTransactions returns all transactions (for recovery).
+// GetAllPreparedTransactions returns the transactions that are prepared but
+// neither committed nor rolled back, including those restored by
+// OpenTransactionDB, in the order they were begun or restored. Transactions
+// that are not prepared are not returned; earlier versions returned every
+// active transaction.
+//
+// Reference: RocksDB v10.7.5 utilities/transactions/pessimistic_transaction_db.cc (GetAllPreparedTransactions)
 func (txnDB *TransactionDB) GetAllPreparedTransactions() []*PessimisticTransaction {
+	txnDB.prepMu.Lock()
+	ids := slices.Sorted(maps.Keys(txnDB.preparedLogs))
+	txnDB.prepMu.Unlock()
+
 	txnDB.mu.RLock()
 	defer txnDB.mu.RUnlock()
-
-	txns := make([]*PessimisticTransaction, 0, len(txnDB.activeTxns))
-	for _, txn := range txnDB.activeTxns {
-		txns = append(txns, txn)
+	txns := make([]*PessimisticTransaction, 0, len(ids))
+	for _, id := range ids {
+		if txn := txnDB.activeTxns[id]; txn != nil {
+			txns = append(txns, txn)
+		}
 	}
 	return txns
 }
 
+// GetTransactionByName returns the unfinished transaction named name, or nil
+// if there is none.
+func (txnDB *TransactionDB) GetTransactionByName(name string) *PessimisticTransaction {
+	txnDB.prepMu.Lock()
+	id, ok := txnDB.names[name]
+	txnDB.prepMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return txnDB.GetTransactionByID(id)
+}
+
+// minLogWithPrep returns the oldest WAL file holding the prepare section of
+// a transaction not committed or rolled back yet, or 0 if there is none.
+func (txnDB *TransactionDB) minLogWithPrep() uint64 {
+	txnDB.prepMu.Lock()
+	defer txnDB.prepMu.Unlock()
+
+	var minLog uint64
+	for _, logNum := range txnDB.preparedLogs {
+		if minLog == 0 || logNum < minLog {
+			minLog = logNum
+		}
+	}
+	return minLog
+}
+
+// setName registers name for the transaction txnID; it fails if another
+// unfinished transaction has it.
+func (txnDB *TransactionDB) setName(txnID uint64, name string) error {
+	txnDB.prepMu.Lock()
+	defer txnDB.prepMu.Unlock()
+	if id, ok := txnDB.names[name]; ok && id != txnID {
+		return fmt.Errorf("%w: %q", ErrTransactionNameExists, name)
+	}
+	txnDB.names[name] = txnID
+	return nil
+}
+
+// setPrepared records that the prepare section of txnID is in WAL file
+// logNum.
+func (txnDB *TransactionDB) setPrepared(txnID, logNum uint64) {
+	txnDB.prepMu.Lock()
+	txnDB.preparedLogs[txnID] = logNum
+	txnDB.prepMu.Unlock()
+}
+
+// finishNamed forgets the name and the prepare of a transaction that is
+// committed or rolled back.
+func (txnDB *TransactionDB) finishNamed(txnID uint64, name string) {
+	txnDB.prepMu.Lock()
+	if txnDB.names[name] == txnID {
+		delete(txnDB.names, name)
+	}
+	delete(txnDB.preparedLogs, txnID)
+	txnDB.prepMu.Unlock()
+}
+
 // nextTxnID generates the next transaction ID.
 func (txnDB *TransactionDB) nextTxnID() uint64 {
 	return atomic.AddUint64(&txnDB.txnIDCounter, 1)
@@ -185,6 +358,148 @@ func (txnDB *TransactionDB) getLockManager() *txn.LockManager {
 	return txnDB.lockManager
 }
 
+// rangeLock is a key range locked by a transaction for a range deletion.
+// Keys are ordered by the database comparator, as DeleteRange deletes them.
+type rangeLock struct {
+	txnID      uint64
+	begin, end []byte
+
+	// released is closed when the range is unlocked
+	released chan struct{}
+}
+
+// overlappingRangeLock returns a range locked by a transaction other than
+// txnID that overlaps [begin, end), or nil.
+// REQUIRES: txnDB.rangeMu is held.
+func (txnDB *TransactionDB) overlappingRangeLock(txnID uint64, begin, end []byte) *rangeLock {
+	cmp := txnDB.db.cmp.Compare
+	for _, r := range txnDB.rangeLocks {
+		if r.txnID != txnID && cmp(begin, r.end) < 0 && cmp(r.begin, end) < 0 {
+			return r
+		}
+	}
+	return nil
+}
+
+// rangeLockOnKey returns a range locked by a transaction other than txnID
+// that holds key, or nil.
+// REQUIRES: txnDB.rangeMu is held.
+func (txnDB *TransactionDB) rangeLockOnKey(txnID uint64, key []byte) *rangeLock {
+	cmp := txnDB.db.cmp.Compare
+	for _, r := range txnDB.rangeLocks {
+		if r.txnID != txnID && cmp(r.begin, key) <= 0 && cmp(key, r.end) < 0 {
+			return r
+		}
+	}
+	return nil
+}
+
+// lockDeadline returns when a lock request with timeout expires; a timeout
+// that is not positive is the lock manager's default.
+func (txnDB *TransactionDB) lockDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		timeout = txnDB.lockManager.DefaultTimeout()
+	}
+	return time.Now().Add(timeout)
+}
+
+// waitForRangeLock waits until r is unlocked, or fails with ErrLockTimeout
+// at deadline.
+func waitForRangeLock(r *rangeLock, deadline time.Time) error {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-r.released:
+		return nil
+	case <-timer.C:
+		return ErrLockTimeout
+	}
+}
+
+// lockKey locks key for txnID like LockManager.Lock, first waiting for the
+// ranges that other transactions locked over the key.
+func (txnDB *TransactionDB) lockKey(txnID uint64, key []byte, lockType LockType, timeout time.Duration) error {
+	deadline := txnDB.lockDeadline(timeout)
+	held := txnDB.lockManager.GetLockInfo(key)
+	alreadyHeld := held != nil && held.IsHeldBy(txnID)
+	for {
+		txnDB.rangeMu.Lock()
+		if r := txnDB.rangeLockOnKey(txnID, key); r != nil {
+			txnDB.rangeMu.Unlock()
+			if err := waitForRangeLock(r, deadline); err != nil {
+				return err
+			}
+			continue
+		}
+		locked := txnDB.lockManager.TryLock(txnID, key, lockType)
+		txnDB.rangeMu.Unlock()
+		if locked {
+			return nil
+		}
+
+		if err := txnDB.lockManager.Lock(txnID, key, lockType, max(time.Until(deadline), time.Nanosecond)); err != nil {
+			return err
+		}
+		// A range locked while waiting did not see this lock, so it is
+		// given up until the range is unlocked
+		txnDB.rangeMu.Lock()
+		r := txnDB.rangeLockOnKey(txnID, key)
+		txnDB.rangeMu.Unlock()
+		if r == nil || alreadyHeld {
+			return nil
+		}
+		_ = txnDB.lockManager.Unlock(txnID, key)
+		if err := waitForRangeLock(r, deadline); err != nil {
+			return err
+		}
+	}
+}
+
+// lockRange locks [begin, end) for txnID: until it is unlocked, other
+// transactions wait to lock keys in the range. The keys in the range that
+// other transactions already hold are locked too, waiting for them.
+func (txnDB *TransactionDB) lockRange(txnID uint64, begin, end []byte, timeout time.Duration) (*rangeLock, error) {
+	deadline := txnDB.lockDeadline(timeout)
+	var r *rangeLock
+	var heldKeys [][]byte
+	for r == nil {
+		txnDB.rangeMu.Lock()
+		if other := txnDB.overlappingRangeLock(txnID, begin, end); other != nil {
+			txnDB.rangeMu.Unlock()
+			if err := waitForRangeLock(other, deadline); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		r = &rangeLock{txnID: txnID, begin: bytes.Clone(begin), end: bytes.Clone(end), released: make(chan struct{})}
+		txnDB.rangeLocks = append(txnDB.rangeLocks, r)
+		heldKeys = txnDB.lockManager.KeysLockedInRange(txnID, begin, end, txnDB.db.cmp.Compare)
+		txnDB.rangeMu.Unlock()
+	}
+
+	slices.SortFunc(heldKeys, txnDB.db.cmp.Compare)
+	for _, key := range heldKeys {
+		if err := txnDB.lockManager.Lock(txnID, key, LockTypeExclusive, max(time.Until(deadline), time.Nanosecond)); err != nil {
+			txnDB.unlockRanges(func(l *rangeLock) bool { return l == r })
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// unlockRanges unlocks the ranges for which match returns true.
+func (txnDB *TransactionDB) unlockRanges(match func(*rangeLock) bool) {
+	txnDB.rangeMu.Lock()
+	defer txnDB.rangeMu.Unlock()
+	txnDB.rangeLocks = slices.DeleteFunc(txnDB.rangeLocks, func(r *rangeLock) bool {
+		if match(r) {
+			close(r.released)
+			return true
+		}
+		return false
+	})
+}
+
 // ----- Pass-through methods for convenience -----
 
 // Get retrieves a value from the database.
@@ -202,6 +517,69 @@ func (txnDB *TransactionDB) Put(key, value []byte) error {
 	return txnDB.db.Put(nil, key, value)
 }
 
+// Write applies batch atomically while holding the locks of every key it
+// writes, so it cannot interleave with a transaction writing the same keys.
+// The locks are taken in sorted key order and released once the batch is
+// written. A key locked by an active transaction that does not release it
+// within DefaultWriteTimeout fails the write with ErrLockTimeout, leaving
+// the database unchanged.
+//
+// Reference: RocksDB v10.7.5 utilities/transactions/pessimistic_transaction_db.cc (WriteCommittedTxnDB::Write)
+func (txnDB *TransactionDB) Write(writeOpts *WriteOptions, batch *WriteBatch) error {
+	collector := &batchKeyCollector{}
+	if err := batch.internalBatch().Iterate(collector); err != nil {
+		return err
+	}
+	if len(collector.ranges) > 0 {
+		return ErrTransactionDBDeleteRange
+	}
+	keys := collector.keys
+	slices.SortFunc(keys, bytes.Compare)
+	keys = slices.CompactFunc(keys, bytes.Equal)
+
+	id := txnDB.nextTxnID()
+	defer txnDB.lockManager.UnlockAll(id)
+	timeout := time.Duration(txnDB.opts.DefaultWriteTimeout) * time.Millisecond
+	for _, key := range keys {
+		if err := txnDB.lockKey(id, key, LockTypeExclusive, max(timeout, 0)); err != nil {
+			return err
+		}
+	}
+	return txnDB.db.Write(writeOpts, batch)
+}
+
+// batchKeyCollector is a batch handler that gathers the keys and the deleted
+// ranges a batch writes. Keys are locked without their column family, like
+// the keys of transactions.
+type batchKeyCollector struct {
+	keys   [][]byte
+	ranges [][2][]byte // Begin and end of each deleted range
+}
+
+func (c *batchKeyCollector) add(key []byte) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func (c *batchKeyCollector) Put(key, _ []byte) error                   { return c.add(key) }
+func (c *batchKeyCollector) Delete(key []byte) error                   { return c.add(key) }
+func (c *batchKeyCollector) SingleDelete(key []byte) error             { return c.add(key) }
+func (c *batchKeyCollector) Merge(key, _ []byte) error                 { return c.add(key) }
+func (c *batchKeyCollector) LogData(_ []byte)                          {}
+func (c *batchKeyCollector) PutEntity(key, _ []byte) error             { return c.add(key) }
+func (c *batchKeyCollector) PutCF(_ uint32, key, _ []byte) error       { return c.add(key) }
+func (c *batchKeyCollector) DeleteCF(_ uint32, key []byte) error       { return c.add(key) }
+func (c *batchKeyCollector) SingleDeleteCF(_ uint32, key []byte) error { return c.add(key) }
+func (c *batchKeyCollector) MergeCF(_ uint32, key, _ []byte) error     { return c.add(key) }
+func (c *batchKeyCollector) PutEntityCF(_ uint32, key, _ []byte) error { return c.add(key) }
+func (c *batchKeyCollector) DeleteRange(begin, end []byte) error {
+	c.ranges = append(c.ranges, [2][]byte{begin, end})
+	return nil
+}
+func (c *batchKeyCollector) DeleteRangeCF(_ uint32, begin, end []byte) error {
+	return c.DeleteRange(begin, end)
+}
+
 // Delete removes a key from the database (outside of a transaction).
 func (txnDB *TransactionDB) Delete(key []byte) error {
 	return txnDB.db.Delete(nil, key)
@@ -293,13 +293,36 @@ func (iter *TransactionLogIterator) readBatchFromReader() (*BatchResult, error)
 	}, nil
 }
 
-// getSortedWalFiles returns a list of WAL files sorted by log number.
+// getSortedWalFiles returns a list of WAL files sorted by log number,
+// including the archived ones.
 func (db *dbImpl) getSortedWalFiles() ([]WalFile, error) {
-	entries, err := db.fs.ListDir(db.name)
+	walFiles, err := db.listWalFiles(db.name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list database directory: %w", err)
 	}
 
+	if db.walArchivalEnabled() {
+		// The archive does not exist until a WAL file is archived
+		if archived, err := db.listWalFiles(db.archiveDirPath()); err == nil {
+			walFiles = append(walFiles, archived...)
+		}
+	}
+
+	// Sort by log number
+	sort.Slice(walFiles, func(i, j int) bool {
+		return walFiles[i].LogNumber < walFiles[j].LogNumber
+	})
+
+	return walFiles, nil
+}
+
+// listWalFiles returns the WAL files in dir sorted by log number.
+func (db *dbImpl) listWalFiles(dir string) ([]WalFile, error) {
+	entries, err := db.fs.ListDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	var walFiles []WalFile
 	for _, entry := range entries {
 		if !strings.HasSuffix(entry, ".log") {
@@ -312,7 +335,7 @@ func (db *dbImpl) getSortedWalFiles() ([]WalFile, error) {
 			continue
 		}
 
-		fullPath := db.name + "/" + entry
+		fullPath := dir + "/" + entry
 		info, err := db.fs.Stat(fullPath)
 		if err != nil {
 			continue
@@ -251,6 +251,57 @@ func TestTransactionSnapshot(t *testing.T) {
 	txn.Rollback()
 }
 
+func TestTransactionSnapshotReadConflict(t *testing.T) {
+	for _, flush := range []bool{false, true} {
+		t.Run(map[bool]string{false: "memtable", true: "flushed"}[flush], func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			database, err := Open(filepath.Join(t.TempDir(), "testdb"), opts)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			defer database.Close()
+
+			database.Put(nil, []byte("key1"), []byte("initial"))
+
+			// Another writer modifies the key after the snapshot, but before
+			// the transaction reads it
+			txn := database.BeginTransaction(TransactionOptions{SetSnapshot: true}, nil)
+			database.Put(nil, []byte("key1"), []byte("concurrent"))
+			if flush {
+				if err := database.Flush(nil); err != nil {
+					t.Fatalf("Flush failed: %v", err)
+				}
+			}
+
+			// The read sees the snapshot, and the commit fails since the key
+			// changed since
+			value, err := txn.Get([]byte("key1"))
+			if err != nil || string(value) != "initial" {
+				t.Fatalf("txn Get = (%q, %v), want (\"initial\", nil)", value, err)
+			}
+			txn.Put([]byte("key2"), []byte("value2"))
+			if err := txn.Commit(); !errors.Is(err, ErrTransactionConflict) {
+				t.Fatalf("Commit = %v, want ErrTransactionConflict", err)
+			}
+			if _, err := database.Get(nil, []byte("key2")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get of the aborted write = %v, want ErrNotFound", err)
+			}
+
+			// Without a snapshot only changes after the read conflict
+			txn = database.BeginTransaction(TransactionOptions{SetSnapshot: false}, nil)
+			database.Put(nil, []byte("key1"), []byte("concurrent2"))
+			if _, err := txn.Get([]byte("key1")); err != nil {
+				t.Fatalf("txn Get failed: %v", err)
+			}
+			txn.Put([]byte("key2"), []byte("value2"))
+			if err := txn.Commit(); err != nil {
+				t.Fatalf("Commit without a snapshot failed: %v", err)
+			}
+		})
+	}
+}
+
 func TestTransactionNoSnapshot(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "testdb")
@@ -282,6 +333,174 @@ func TestTransactionNoSnapshot(t *testing.T) {
 	}
 }
 
+func TestTransactionConsistentReads(t *testing.T) {
+	type reader interface {
+		Get(key []byte) ([]byte, error)
+		GetForUpdate(key []byte, exclusive bool) ([]byte, error)
+		Put(key, value []byte) error
+		GetSnapshot() *Snapshot
+		Commit() error
+		Rollback() error
+	}
+	for _, tc := range []struct {
+		name string
+		open func(t *testing.T) (func() reader, func(key, value []byte) error)
+	}{
+		{"optimistic", func(t *testing.T) (func() reader, func(key, value []byte) error) {
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			database, err := Open(filepath.Join(t.TempDir(), "testdb"), opts)
+			if err != nil {
+				t.Fatalf("Failed to open database: %v", err)
+			}
+			t.Cleanup(func() { database.Close() })
+			begin := func() reader { return database.BeginTransaction(TransactionOptions{SetSnapshot: false}, nil) }
+			put := func(key, value []byte) error { return database.Put(nil, key, value) }
+			return begin, put
+		}},
+		{"pessimistic", func(t *testing.T) (func() reader, func(key, value []byte) error) {
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			txnDB, err := OpenTransactionDB(filepath.Join(t.TempDir(), "testdb"), opts, DefaultTransactionDBOptions())
+			if err != nil {
+				t.Fatalf("Failed to open TransactionDB: %v", err)
+			}
+			t.Cleanup(func() { txnDB.Close() })
+			txnOpts := DefaultPessimisticTransactionOptions()
+			txnOpts.SetSnapshot = false
+			begin := func() reader { return txnDB.BeginTransaction(txnOpts, nil) }
+			return begin, txnDB.Put
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			begin, put := tc.open(t)
+			if err := put([]byte("key1"), []byte("v1")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			txn := begin()
+			if txn.GetSnapshot() != nil {
+				t.Fatal("snapshot set before the first read")
+			}
+
+			first, err := txn.Get([]byte("key1"))
+			if err != nil {
+				t.Fatalf("first Get failed: %v", err)
+			}
+			snap := txn.GetSnapshot()
+			if snap == nil {
+				t.Fatal("first read did not pin a snapshot")
+			}
+
+			// A committed write between the two reads
+			if err := put([]byte("key1"), []byte("v2")); err != nil {
+				t.Fatalf("concurrent Put failed: %v", err)
+			}
+			second, err := txn.Get([]byte("key1"))
+			if err != nil {
+				t.Fatalf("second Get failed: %v", err)
+			}
+			if string(first) != "v1" || string(second) != string(first) {
+				t.Errorf("reads = %q then %q, want %q twice", first, second, "v1")
+			}
+			if txn.GetSnapshot() != snap {
+				t.Error("snapshot changed between reads")
+			}
+
+			// Reads for update see the latest value, not the pinned snapshot
+			if got, err := txn.GetForUpdate([]byte("key1"), true); err != nil || string(got) != "v2" {
+				t.Errorf("GetForUpdate = %q, %v; want v2", got, err)
+			}
+			if err := txn.Rollback(); err != nil {
+				t.Fatalf("Rollback failed: %v", err)
+			}
+
+			// Writes are not validated against the pinned snapshot
+			txn = begin()
+			if _, err := txn.Get([]byte("key1")); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if err := put([]byte("key2"), []byte("outside")); err != nil {
+				t.Fatalf("concurrent Put failed: %v", err)
+			}
+			if err := txn.Put([]byte("key2"), []byte("txn")); err != nil {
+				t.Errorf("Put after a concurrent write = %v, want nil", err)
+			}
+			if err := txn.Commit(); err != nil {
+				t.Errorf("Commit after a concurrent write = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestTransactionDeleteRange(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	database, err := Open(filepath.Join(t.TempDir(), "testdb"), opts)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := database.Put(nil, []byte(key), []byte("v")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// The range deletion applies at commit, together with the other writes
+	txn := database.BeginTransaction(TransactionOptions{SetSnapshot: false}, nil)
+	if err := txn.DeleteRange(nil, []byte("d"), []byte("b")); !errors.Is(err, ErrTransactionInvalidRange) {
+		t.Errorf("DeleteRange with end before begin = %v, want ErrTransactionInvalidRange", err)
+	}
+	if err := txn.DeleteRange(nil, []byte("b"), []byte("d")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	txn.Put([]byte("x"), []byte("v"))
+	if _, err := txn.Get([]byte("c")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("txn Get of a deleted key = %v, want ErrNotFound", err)
+	}
+	if _, err := database.Get(nil, []byte("c")); err != nil {
+		t.Errorf("Get before Commit failed: %v", err)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	for key, wantFound := range map[string]bool{"a": true, "b": false, "c": false, "d": true, "x": true} {
+		if _, err := database.Get(nil, []byte(key)); (err == nil) != wantFound {
+			t.Errorf("Get(%s) after Commit = %v, want found %v", key, err, wantFound)
+		}
+	}
+
+	// A write into the range after the snapshot conflicts
+	txn = database.BeginTransaction(DefaultTransactionOptions(), nil)
+	if err := txn.DeleteRange(nil, []byte("a"), []byte("e")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	database.Put(nil, []byte("c"), []byte("concurrent"))
+	if err := txn.Commit(); !errors.Is(err, ErrTransactionConflict) {
+		t.Fatalf("Commit after a write into the range = %v, want ErrTransactionConflict", err)
+	}
+	if _, err := database.Get(nil, []byte("a")); err != nil {
+		t.Errorf("Get of a key of the aborted range deletion failed: %v", err)
+	}
+
+	// A write outside the range does not
+	txn = database.BeginTransaction(DefaultTransactionOptions(), nil)
+	if err := txn.DeleteRange(nil, []byte("a"), []byte("e")); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	database.Put(nil, []byte("y"), []byte("concurrent"))
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit after a write outside the range failed: %v", err)
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, err := database.Get(nil, []byte(key)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%s) after the range deletion = %v, want ErrNotFound", key, err)
+		}
+	}
+}
+
 func TestTransactionClosed(t *testing.T) {
 	dir := t.TempDir()
 	dbPath := filepath.Join(dir, "testdb")
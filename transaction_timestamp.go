@@ -0,0 +1,207 @@
+package rockyardkv
+
+// transaction_timestamp.go implements user-defined timestamp support for
+// transactions.
+//
+// Versioned writes are buffered in the transaction's write batch as
+// key+timestamp and become visible at that timestamp on commit. Pessimistic
+// transactions lock the user key without its timestamp, so writers of
+// different versions of the same key still serialize. Optimistic transactions
+// detect conflicts on the exact key version.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/utilities/transaction.h (Put/GetForUpdate with timestamps)
+//   - utilities/transactions/write_committed_txn.cc
+
+import (
+	"errors"
+
+	"github.com/aalhour/rockyardkv/internal/batch"
+)
+
+// PutWithTimestamp sets the value for key at timestamp ts in the specified
+// column family.
+func (txn *optimisticTransaction) PutWithTimestamp(cf ColumnFamilyHandle, key, value, ts []byte) error {
+	if _, err := checkTimestamp(txn.db, cf, ts); err != nil {
+		return err
+	}
+	return txn.PutCF(cf, AppendTimestampToKey(key, ts), value)
+}
+
+// GetForUpdateWithTimestamp returns the newest version of key at or below
+// timestamp ts and tracks the version at ts for conflict detection.
+func (txn *optimisticTransaction) GetForUpdateWithTimestamp(cf ColumnFamilyHandle, key, ts []byte) ([]byte, error) {
+	cmp, err := checkTimestamp(txn.db, cf, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if txn.closed {
+		return nil, ErrTransactionClosed
+	}
+
+	cfID := uint32(0)
+	if cf != nil {
+		cfID = cf.ID()
+	}
+	txn.trackKey(cfID, AppendTimestampToKey(key, ts), true /* read-only */)
+
+	return getTxnAtTimestamp(txn.db, txn.writeBatch, txn.snapshot, cmp, cf, key, ts)
+}
+
+// PutWithTimestamp acquires an exclusive lock on key and sets its value at
+// timestamp ts in the specified column family.
+func (txn *PessimisticTransaction) PutWithTimestamp(cf ColumnFamilyHandle, key, value, ts []byte) error {
+	if _, err := checkTimestamp(txn.txnDB.db, cf, ts); err != nil {
+		return err
+	}
+	return txn.put(cf, key, AppendTimestampToKey(key, ts), value)
+}
+
+// GetForUpdateWithTimestamp acquires an exclusive lock on key and returns its
+// newest version at or below timestamp ts.
+func (txn *PessimisticTransaction) GetForUpdateWithTimestamp(cf ColumnFamilyHandle, key, ts []byte) ([]byte, error) {
+	cmp, err := checkTimestamp(txn.txnDB.db, cf, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+
+	if err := txn.checkState(); err != nil {
+		return nil, err
+	}
+
+	if err := txn.tryLock(key, LockTypeExclusive); err != nil {
+		return nil, err
+	}
+
+	// Validate that the version at ts hasn't been written since our snapshot
+	if err := txn.validateSnapshot(AppendTimestampToKey(key, ts)); err != nil {
+		_ = txn.txnDB.lockManager.Unlock(txn.id, key)
+		delete(txn.lockedKeys, string(key))
+		return nil, err
+	}
+
+	return getTxnAtTimestamp(txn.txnDB.db, txn.writeBatch, txn.snapshot, cmp, cf, key, ts)
+}
+
+// checkTimestamp verifies that cf stores timestamps of the size of ts.
+func checkTimestamp(db *dbImpl, cf ColumnFamilyHandle, ts []byte) (TimestampedComparator, error) {
+	cmp, err := db.timestampComparator(cf)
+	if err != nil {
+		return nil, err
+	}
+	if len(ts) != cmp.TimestampSize() {
+		return nil, ErrInvalidTimestampSize
+	}
+	return cmp, nil
+}
+
+// getTxnAtTimestamp returns the newest version of key at or below ts, taking
+// the transaction's pending writes over those in the database at snapshot.
+func getTxnAtTimestamp(db *dbImpl, wb *batch.WriteBatch, snapshot *Snapshot,
+	cmp TimestampedComparator, cf ColumnFamilyHandle, key, ts []byte) ([]byte, error) {
+	cfID := uint32(0)
+	if cf != nil {
+		cfID = cf.ID()
+	}
+
+	pending := &timestampBatchReader{
+		targetCFID: cfID,
+		targetKey:  key,
+		readTS:     ts,
+		cmp:        cmp,
+	}
+	_ = wb.Iterate(pending)
+
+	readOpts := DefaultReadOptions()
+	readOpts.Snapshot = snapshot
+	value, foundTS, err := getAtTimestamp(db, cmp, readOpts, cf, key, ts)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	// Pending writes shadow database versions with the same or older timestamp
+	if pending.found && (err != nil || cmp.CompareTimestamp(pending.foundTS, foundTS) >= 0) {
+		if pending.deleted {
+			return nil, ErrNotFound
+		}
+		return pending.value, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// timestampBatchReader finds the newest version of a key at or below a read
+// timestamp in a write batch.
+type timestampBatchReader struct {
+	targetCFID uint32
+	targetKey  []byte
+	readTS     []byte
+	cmp        TimestampedComparator
+
+	found   bool
+	deleted bool
+	value   []byte
+	foundTS []byte
+}
+
+// match records an entry for the target key if its timestamp is visible and
+// not older than the best found so far; later entries win ties.
+func (r *timestampBatchReader) match(cfID uint32, key, value []byte, deleted bool) {
+	if cfID != r.targetCFID || len(key) < r.cmp.TimestampSize() {
+		return
+	}
+	userKey, ts := StripTimestampFromKey(key, r.cmp.TimestampSize())
+	if !bytesEqual(userKey, r.targetKey) || r.cmp.CompareTimestamp(ts, r.readTS) > 0 {
+		return
+	}
+	if r.found && r.cmp.CompareTimestamp(ts, r.foundTS) < 0 {
+		return
+	}
+	r.found = true
+	r.deleted = deleted
+	r.value = copySlice(value)
+	r.foundTS = copySlice(ts)
+}
+
+func (r *timestampBatchReader) Put(key, value []byte) error {
+	r.match(0, key, value, false)
+	return nil
+}
+
+func (r *timestampBatchReader) PutCF(cfID uint32, key, value []byte) error {
+	r.match(cfID, key, value, false)
+	return nil
+}
+
+func (r *timestampBatchReader) Delete(key []byte) error {
+	r.match(0, key, nil, true)
+	return nil
+}
+
+func (r *timestampBatchReader) DeleteCF(cfID uint32, key []byte) error {
+	r.match(cfID, key, nil, true)
+	return nil
+}
+
+func (r *timestampBatchReader) SingleDelete(key []byte) error {
+	return r.Delete(key)
+}
+
+func (r *timestampBatchReader) SingleDeleteCF(cfID uint32, key []byte) error {
+	return r.DeleteCF(cfID, key)
+}
+
+func (r *timestampBatchReader) Merge(key, value []byte) error                      { return nil }
+func (r *timestampBatchReader) MergeCF(cfID uint32, key, value []byte) error       { return nil }
+func (r *timestampBatchReader) DeleteRange(start, end []byte) error                { return nil }
+func (r *timestampBatchReader) DeleteRangeCF(cfID uint32, start, end []byte) error { return nil }
+func (r *timestampBatchReader) LogData(blob []byte)                                {}
@@ -31,6 +31,10 @@ var (
 type TTLCompactionFilter struct {
 	BaseCompactionFilter
 	TTL time.Duration
+
+	// Clock is the time source for expiry checks.
+	// If nil, the wall clock is used.
+	Clock SystemClock
 }
 
 // NewTTLCompactionFilter creates a new TTL compaction filter.
@@ -54,7 +58,7 @@ func (f *TTLCompactionFilter) Filter(level int, key, oldValue []byte) (Compactio
 	timestamp := extractTTLTimestamp(oldValue)
 
 	// Check if expired
-	if isExpired(timestamp, f.TTL) {
+	if isExpiredAt(timestamp, f.TTL, clockNow(f.Clock)) {
 		return FilterRemove, nil
 	}
 
@@ -64,6 +68,9 @@ func (f *TTLCompactionFilter) Filter(level int, key, oldValue []byte) (Compactio
 // TTLCompactionFilterFactory creates TTL compaction filters.
 type TTLCompactionFilterFactory struct {
 	TTL time.Duration
+
+	// Clock is passed to each filter created by the factory.
+	Clock SystemClock
 }
 
 // Name returns the factory name.
@@ -73,15 +80,16 @@ func (f *TTLCompactionFilterFactory) Name() string {
 
 // CreateCompactionFilter creates a new TTL compaction filter.
 func (f *TTLCompactionFilterFactory) CreateCompactionFilter(context CompactionFilterContext) CompactionFilter {
-	return NewTTLCompactionFilter(f.TTL)
+	return &TTLCompactionFilter{TTL: f.TTL, Clock: f.Clock}
 }
 
 // TTLDB wraps a database with TTL support.
 // Values are automatically timestamped on write and expired entries are
 // filtered on read and removed during compaction.
 type TTLDB struct {
-	db  DB
-	ttl time.Duration
+	db    DB
+	ttl   time.Duration
+	clock SystemClock
 }
 
 // OpenWithTTL opens a database with TTL support.
@@ -91,8 +99,14 @@ func OpenWithTTL(path string, opts *Options, ttl time.Duration) (*TTLDB, error)
 		opts = DefaultOptions()
 	}
 
+	// Timestamps and expiry checks follow the configured Env
+	var clock SystemClock = DefaultEnv()
+	if opts.Env != nil {
+		clock = opts.Env
+	}
+
 	// Set up TTL compaction filter
-	opts.CompactionFilter = NewTTLCompactionFilter(ttl)
+	opts.CompactionFilter = &TTLCompactionFilter{TTL: ttl, Clock: clock}
 
 	// Open the database
 	database, err := Open(path, opts)
@@ -101,14 +115,15 @@ func OpenWithTTL(path string, opts *Options, ttl time.Duration) (*TTLDB, error)
 	}
 
 	return &TTLDB{
-		db:  database,
-		ttl: ttl,
+		db:    database,
+		ttl:   ttl,
+		clock: clock,
 	}, nil
 }
 
 // Put stores a key-value pair with TTL timestamp.
 func (t *TTLDB) Put(opts *WriteOptions, key, value []byte) error {
-	return t.PutWithExpiry(opts, key, value, time.Now())
+	return t.PutWithExpiry(opts, key, value, clockNow(t.clock))
 }
 
 // PutWithExpiry stores a key-value pair with a specific creation time.
@@ -132,7 +147,7 @@ func (t *TTLDB) Get(opts *ReadOptions, key []byte) ([]byte, error) {
 
 	// Check if expired
 	timestamp := extractTTLTimestamp(value)
-	if isExpired(timestamp, t.ttl) {
+	if isExpiredAt(timestamp, t.ttl, clockNow(t.clock)) {
 		return nil, ErrNotFound
 	}
 
@@ -168,8 +183,9 @@ func (t *TTLDB) ReleaseSnapshot(snapshot *Snapshot) {
 // NewIterator returns a TTL-aware iterator.
 func (t *TTLDB) NewIterator(opts *ReadOptions) Iterator {
 	return &ttlIterator{
-		iter: t.db.NewIterator(opts),
-		ttl:  t.ttl,
+		iter:  t.db.NewIterator(opts),
+		ttl:   t.ttl,
+		clock: t.clock,
 	}
 }
 
@@ -185,8 +201,9 @@ func (t *TTLDB) SetTTL(ttl time.Duration) {
 
 // ttlIterator wraps an iterator to skip expired entries.
 type ttlIterator struct {
-	iter Iterator
-	ttl  time.Duration
+	iter  Iterator
+	ttl   time.Duration
+	clock SystemClock
 }
 
 func (i *ttlIterator) Valid() bool {
@@ -232,8 +249,16 @@ func (i *ttlIterator) Value() []byte {
 	return stripTTLTimestamp(value)
 }
 
+func (i *ttlIterator) Columns() []WideColumn {
+	return []WideColumn{{Name: DefaultWideColumnName, Value: i.Value()}}
+}
+
+func (i *ttlIterator) Status() error {
+	return i.iter.Status()
+}
+
 func (i *ttlIterator) Error() error {
-	return i.iter.Error()
+	return i.iter.Status()
 }
 
 func (i *ttlIterator) Close() error {
@@ -246,7 +271,7 @@ func (i *ttlIterator) skipExpired() {
 		value := i.iter.Value()
 		if len(value) >= TTLTimestampSize {
 			timestamp := extractTTLTimestamp(value)
-			if isExpired(timestamp, i.ttl) {
+			if isExpiredAt(timestamp, i.ttl, clockNow(i.clock)) {
 				i.iter.Next()
 				continue
 			}
@@ -261,7 +286,7 @@ func (i *ttlIterator) skipExpiredBackward() {
 		value := i.iter.Value()
 		if len(value) >= TTLTimestampSize {
 			timestamp := extractTTLTimestamp(value)
-			if isExpired(timestamp, i.ttl) {
+			if isExpiredAt(timestamp, i.ttl, clockNow(i.clock)) {
 				i.iter.Prev()
 				continue
 			}
@@ -298,9 +323,14 @@ func stripTTLTimestamp(value []byte) []byte {
 
 // isExpired checks if a timestamp has expired given the TTL.
 func isExpired(timestamp int64, ttl time.Duration) bool {
+	return isExpiredAt(timestamp, ttl, time.Now())
+}
+
+// isExpiredAt checks if a timestamp has expired at the given time.
+func isExpiredAt(timestamp int64, ttl time.Duration, now time.Time) bool {
 	if ttl <= 0 {
 		return false // No TTL = never expires
 	}
 	expiryTime := time.Unix(timestamp, 0).Add(ttl)
-	return time.Now().After(expiryTime)
+	return now.After(expiryTime)
 }
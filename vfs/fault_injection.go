@@ -242,6 +242,46 @@ func (fs *FaultInjectionFS) Create(name string) (WritableFile, error) {
 	}, nil
 }
 
+// ReuseWritableFile renames a file and opens it for overwriting with fault
+// injection. Only the rewritten bytes are tracked, so a crash drops the
+// file back to its synced prefix.
+func (fs *FaultInjectionFS) ReuseWritableFile(oldname, newname string) (WritableFile, error) {
+	fs.mu.RLock()
+	if !fs.filesystemActive {
+		fs.mu.RUnlock()
+		return nil, ErrInjectedWriteError
+	}
+	if fs.injectWriteError && (fs.writeErrorPath == "" || fs.writeErrorPath == newname) {
+		fs.mu.RUnlock()
+		return nil, ErrInjectedWriteError
+	}
+	fs.mu.RUnlock()
+
+	baseFile, err := fs.base.ReuseWritableFile(oldname, newname)
+	if err != nil {
+		return nil, err
+	}
+
+	absOld, _ := filepath.Abs(oldname)
+	absNew, _ := filepath.Abs(newname)
+
+	fs.mu.Lock()
+	delete(fs.fileState, absOld)
+	fs.fileState[absNew] = &fileState{
+		pos:       0,
+		syncedPos: 0,
+		dirSynced: false,
+	}
+	fs.trackPendingRename(absOld, absNew)
+	fs.mu.Unlock()
+
+	return &faultWritableFile{
+		base: baseFile,
+		fs:   fs,
+		path: absNew,
+	}, nil
+}
+
 // Open opens an existing file for sequential reading.
 func (fs *FaultInjectionFS) Open(name string) (SequentialFile, error) {
 	fs.mu.RLock()
@@ -312,6 +312,14 @@ func (fs *GoroutineLocalFaultInjectionFS) Create(name string) (WritableFile, err
 	return fs.FaultInjectionFS.Create(name)
 }
 
+// ReuseWritableFile reuses a file with goroutine-local fault injection.
+func (fs *GoroutineLocalFaultInjectionFS) ReuseWritableFile(oldname, newname string) (WritableFile, error) {
+	if fs.faultManager.ShouldInjectWriteError() {
+		return nil, ErrInjectedWriteError
+	}
+	return fs.FaultInjectionFS.ReuseWritableFile(oldname, newname)
+}
+
 // Open opens a file with goroutine-local fault injection.
 func (fs *GoroutineLocalFaultInjectionFS) Open(name string) (SequentialFile, error) {
 	if fs.faultManager.ShouldInjectReadError() {
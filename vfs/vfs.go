@@ -19,6 +19,12 @@ type FS interface {
 	// If the file already exists, it is truncated.
 	Create(name string) (WritableFile, error)
 
+	// ReuseWritableFile renames oldname to newname and opens it for writing
+	// from the start. Unlike Create, the file is not truncated: data past
+	// the written end is left in place.
+	// Reference: RocksDB v10.7.5 include/rocksdb/file_system.h (ReuseWritableFile)
+	ReuseWritableFile(oldname, newname string) (WritableFile, error)
+
 	// Open opens an existing file for reading.
 	Open(name string) (SequentialFile, error)
 
@@ -109,6 +115,17 @@ func (fs *osFS) Create(name string) (WritableFile, error) {
 	return &osWritableFile{f: f}, nil
 }
 
+func (fs *osFS) ReuseWritableFile(oldname, newname string) (WritableFile, error) {
+	if err := os.Rename(oldname, newname); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(newname, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &osWritableFile{f: f}, nil
+}
+
 func (fs *osFS) Open(name string) (SequentialFile, error) {
 	f, err := os.Open(name)
 	if err != nil {
@@ -129,6 +129,38 @@ func TestOSFS_Rename(t *testing.T) {
 	}
 }
 
+func TestOSFS_ReuseWritableFile(t *testing.T) {
+	fs := Default()
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.txt")
+	newPath := filepath.Join(dir, "new.txt")
+
+	if err := os.WriteFile(oldPath, []byte("old content"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	f, err := fs.ReuseWritableFile(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("ReuseWritableFile failed: %v", err)
+	}
+	if _, err := f.Write([]byte("new")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if fs.Exists(oldPath) {
+		t.Error("Old file should not exist after reuse")
+	}
+
+	// The write starts at the beginning and leaves the rest in place
+	data, _ := os.ReadFile(newPath)
+	if string(data) != "new content" {
+		t.Errorf("Content = %q, want 'new content'", data)
+	}
+}
+
 func TestOSFS_Remove(t *testing.T) {
 	fs := Default()
 	dir := t.TempDir()
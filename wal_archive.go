@@ -0,0 +1,154 @@
+package rockyardkv
+
+// wal_archive.go implements WAL archival (Options.WALTTLSeconds and
+// Options.WALSizeLimitMB).
+//
+// With archival enabled, obsolete WAL files are moved into the archive/
+// subdirectory instead of being deleted, so that GetUpdatesSince can still
+// serve the writes they hold. Archived files are then deleted once they are
+// older than WALTTLSeconds, or oldest first while the archive is larger than
+// WALSizeLimitMB. Both steps run on open and whenever PurgeObsoleteWALFiles
+// is called. Archival disables WAL recycling.
+//
+// Reference: RocksDB v10.7.5
+//   - db/db_impl/db_impl_files.cc (DBImpl::PurgeObsoleteFiles, ArchiveWAL)
+//   - db/wal_manager.cc (WalManager::PurgeObsoleteWALFiles)
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// walArchiveDir is the name of the archive subdirectory of the DB directory.
+const walArchiveDir = "archive"
+
+// walArchivalEnabled reports whether obsolete WAL files are archived.
+func (db *dbImpl) walArchivalEnabled() bool {
+	return db.options.WALTTLSeconds > 0 || db.options.WALSizeLimitMB > 0
+}
+
+// archiveDirPath returns the path of the WAL archive directory.
+func (db *dbImpl) archiveDirPath() string {
+	return filepath.Join(db.name, walArchiveDir)
+}
+
+// archivedLogFilePath returns the path of WAL file number in the archive.
+func (db *dbImpl) archivedLogFilePath(number uint64) string {
+	return filepath.Join(db.archiveDirPath(), logFileName(number))
+}
+
+// archiveObsoleteLogs moves the obsolete WAL files into the archive. The
+// current WAL is never obsolete, so it is never moved.
+//
+// Archival is best-effort like collectObsoleteLogs: an obsolete WAL that
+// cannot be moved is never replayed again.
+func (db *dbImpl) archiveObsoleteLogs() {
+	db.walArchiveMu.Lock()
+	defer db.walArchiveMu.Unlock()
+
+	logFiles, err := db.findLogFiles()
+	if err != nil {
+		db.logger.Warnf("[wal] failed to list obsolete WAL files: %v", err)
+		return
+	}
+
+	minLogNumber := db.versions.LogNumber()
+	created := false
+	for _, num := range logFiles {
+		if num >= minLogNumber {
+			continue
+		}
+		if !created {
+			if err := db.fs.MkdirAll(db.archiveDirPath(), 0755); err != nil {
+				db.logger.Warnf("[wal] failed to create WAL archive directory: %v", err)
+				return
+			}
+			created = true
+		}
+		if err := db.fs.Rename(db.logFilePath(num), db.archivedLogFilePath(num)); err != nil {
+			db.logger.Warnf("[wal] failed to archive obsolete WAL file %d: %v (continuing best-effort)", num, err)
+		}
+	}
+}
+
+// PurgeObsoleteWALFiles archives the WAL files that became obsolete since
+// open, then deletes the archived WAL files that are past
+// Options.WALTTLSeconds or beyond Options.WALSizeLimitMB, instead of waiting
+// for the next open. WAL files that are still live are never touched. It
+// does nothing when WAL archival is disabled.
+//
+// Reference: RocksDB v10.7.5 db/wal_manager.cc (WalManager::PurgeObsoleteWALFiles)
+func (db *dbImpl) PurgeObsoleteWALFiles() error {
+	db.mu.RLock()
+	closed := db.closed
+	db.mu.RUnlock()
+	if closed {
+		return ErrDBClosed
+	}
+
+	if !db.walArchivalEnabled() {
+		return nil
+	}
+	db.archiveObsoleteLogs()
+	db.purgeArchivedLogs()
+	return nil
+}
+
+// purgeArchivedLogs deletes the archived WAL files that have expired, then
+// the oldest remaining ones until the archive fits the size limit.
+// Deletion is best-effort; a file that cannot be deleted is retried on the
+// next purge.
+func (db *dbImpl) purgeArchivedLogs() {
+	if !db.walArchivalEnabled() {
+		return
+	}
+	db.walArchiveMu.Lock()
+	defer db.walArchiveMu.Unlock()
+
+	walFiles, err := db.listWalFiles(db.archiveDirPath())
+	if err != nil {
+		// No archive yet
+		return
+	}
+
+	now := clockNow(db.env)
+	ttl := time.Duration(db.options.WALTTLSeconds) * time.Second
+	var kept []WalFile
+	var totalSize uint64
+	for _, wf := range walFiles {
+		if ttl > 0 {
+			info, err := db.fs.Stat(wf.PathName)
+			if err == nil && now.Sub(info.ModTime()) > ttl {
+				db.removeArchivedLog(wf)
+				continue
+			}
+		}
+		kept = append(kept, wf)
+		totalSize += wf.SizeBytes
+	}
+
+	if db.options.WALSizeLimitMB == 0 {
+		return
+	}
+	// kept is sorted by log number, so the oldest files go first
+	sizeLimit := db.options.WALSizeLimitMB * 1024 * 1024
+	for _, wf := range kept {
+		if totalSize <= sizeLimit {
+			break
+		}
+		if db.removeArchivedLog(wf) {
+			totalSize -= wf.SizeBytes
+		}
+	}
+}
+
+// removeArchivedLog deletes an archived WAL file and reports whether it was
+// deleted.
+func (db *dbImpl) removeArchivedLog(wf WalFile) bool {
+	if err := db.fs.Remove(wf.PathName); err != nil {
+		db.logger.Warnf("[wal] failed to purge archived WAL file %d: %v (continuing best-effort)", wf.LogNumber, err)
+		return false
+	}
+	db.logger.Debugf("[wal] purged archived WAL file %d", wf.LogNumber)
+	return true
+}
@@ -0,0 +1,137 @@
+package rockyardkv
+
+// wal_archive_test.go implements tests for WAL archival.
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPurgeObsoleteWALFiles(t *testing.T) {
+	value := make([]byte, 1000)
+	putKeys := func(t *testing.T, database DB, prefix string) {
+		t.Helper()
+		for i := range 600 {
+			if err := database.Put(nil, fmt.Appendf(nil, "%s%03d", prefix, i), value); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+	}
+	open := func(t *testing.T, dir string, opts *Options) *dbImpl {
+		t.Helper()
+		database, err := Open(dir, opts)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return database.(*dbImpl)
+	}
+	archived := func(t *testing.T, dir string) map[uint64]int64 {
+		t.Helper()
+		return walFileSizes(t, filepath.Join(dir, walArchiveDir))
+	}
+
+	t.Run("SizeLimit", func(t *testing.T) {
+		dir := t.TempDir()
+		opts := DefaultOptions()
+		opts.CreateIfMissing = true
+		opts.WALSizeLimitMB = 1
+
+		// Two WAL files of about 600 KB each, neither flushed
+		database := open(t, dir, opts)
+		oldest := database.logFileNumber
+		putKeys(t, database, "a")
+		database.Close()
+		database = open(t, dir, opts)
+		older := database.logFileNumber
+		putKeys(t, database, "b")
+		database.Close()
+
+		// Flushing the replayed records makes both WAL files obsolete
+		database = open(t, dir, opts)
+		defer database.Close()
+		live := database.logFileNumber
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if _, ok := walFileSizes(t, dir)[oldest]; !ok {
+			t.Fatalf("WAL %d was removed before PurgeObsoleteWALFiles", oldest)
+		}
+		if err := database.PurgeObsoleteWALFiles(); err != nil {
+			t.Fatalf("PurgeObsoleteWALFiles failed: %v", err)
+		}
+
+		// The oldest archived WAL is deleted to fit the archive in 1 MB
+		inArchive := archived(t, dir)
+		if _, ok := inArchive[oldest]; ok {
+			t.Errorf("archived WAL %d still exists, want it purged", oldest)
+		}
+		if _, ok := inArchive[older]; !ok {
+			t.Errorf("archived WAL %d was purged, want it kept within the size limit", older)
+		}
+		if _, ok := walFileSizes(t, dir)[live]; !ok {
+			t.Errorf("live WAL %d was removed", live)
+		}
+
+		walFiles, err := database.GetSortedWalFiles()
+		if err != nil {
+			t.Fatalf("GetSortedWalFiles failed: %v", err)
+		}
+		if len(walFiles) != 2 || walFiles[0].LogNumber != older || walFiles[1].LogNumber != live {
+			t.Errorf("GetSortedWalFiles = %+v, want WAL %d archived and WAL %d live", walFiles, older, live)
+		}
+		if walFiles[0].Type != WalFileTypeArchived || walFiles[1].Type != WalFileTypeLive {
+			t.Errorf("GetSortedWalFiles types = %v, %v, want archived, live", walFiles[0].Type, walFiles[1].Type)
+		}
+
+		for _, key := range []string{"a000", "b599"} {
+			if _, err := database.Get(nil, []byte(key)); err != nil {
+				t.Errorf("Get(%s) failed: %v", key, err)
+			}
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		dir := t.TempDir()
+		env := NewMockEnv(time.Now())
+		opts := DefaultOptions()
+		opts.CreateIfMissing = true
+		opts.Env = env
+		opts.WALTTLSeconds = 3600
+
+		database := open(t, dir, opts)
+		oldest := database.logFileNumber
+		putKeys(t, database, "a")
+		database.Close()
+
+		database = open(t, dir, opts)
+		defer database.Close()
+		live := database.logFileNumber
+		if err := database.Flush(nil); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+
+		// The archived WAL is kept until it expires
+		if err := database.PurgeObsoleteWALFiles(); err != nil {
+			t.Fatalf("PurgeObsoleteWALFiles failed: %v", err)
+		}
+		if _, ok := archived(t, dir)[oldest]; !ok {
+			t.Fatalf("WAL %d was not archived", oldest)
+		}
+
+		env.Advance(2 * time.Hour)
+		if err := database.PurgeObsoleteWALFiles(); err != nil {
+			t.Fatalf("PurgeObsoleteWALFiles failed: %v", err)
+		}
+		if n := len(archived(t, dir)); n != 0 {
+			t.Errorf("archive holds %d WAL files after the TTL, want 0", n)
+		}
+		if _, ok := walFileSizes(t, dir)[live]; !ok {
+			t.Errorf("live WAL %d was removed", live)
+		}
+		if _, err := database.Get(nil, []byte("a599")); err != nil {
+			t.Errorf("Get(a599) failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package rockyardkv
+
+// wal_buffer.go implements the in-memory WAL buffer used by
+// Options.ManualWalFlush.
+//
+// Reference: RocksDB v10.7.5
+//   - file/writable_file_writer.h (WritableFileWriter buffer)
+//   - db/db_impl/db_impl.cc (DBImpl::FlushWAL)
+
+import (
+	"sync"
+
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+// walBuffer holds WAL records in memory until they are flushed to the WAL
+// file. Writes append to the buffer under db.mu, while FlushWAL and SyncWAL
+// flush it without holding db.mu, so the buffer has its own lock.
+type walBuffer struct {
+	mu   sync.Mutex
+	file vfs.WritableFile
+	buf  []byte
+}
+
+// Write appends p to the buffer.
+func (b *walBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// Flush writes the buffered records to the WAL file.
+func (b *walBuffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.buf) == 0 {
+		return nil
+	}
+	n, err := b.file.Write(b.buf)
+	// Keep what was not written for the next flush
+	b.buf = b.buf[:copy(b.buf, b.buf[n:])]
+	return err
+}
+
+// Sync writes the buffered records to the WAL file and syncs it.
+func (b *walBuffer) Sync() error {
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return b.file.Sync()
+}
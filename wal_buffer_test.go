@@ -0,0 +1,127 @@
+package rockyardkv
+
+// wal_buffer_test.go implements tests for Options.ManualWalFlush.
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+// crashAndReopen simulates a process crash: nothing more reaches the files,
+// but whatever was written to them survives. It then reopens the database.
+func crashAndReopen(t *testing.T, database DB, faultFS *vfs.FaultInjectionFS, dir string, opts *Options) DB {
+	t.Helper()
+	faultFS.SetFilesystemActive(false)
+	_ = database.Close()
+	faultFS.SetFilesystemActive(true)
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	return database
+}
+
+func TestManualWalFlush(t *testing.T) {
+	tests := []struct {
+		name  string
+		flush func(DB) error
+	}{
+		{"FlushWAL", func(database DB) error { return database.FlushWAL(false) }},
+		{"SyncWAL", func(database DB) error { return database.SyncWAL() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			faultFS := vfs.NewFaultInjectionFS(vfs.Default())
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			opts.FS = faultFS
+			opts.ManualWalFlush = true
+
+			database, err := Open(dir, opts)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			if err := database.Put(nil, []byte("flushed"), []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := tt.flush(database); err != nil {
+				t.Fatalf("%s failed: %v", tt.name, err)
+			}
+			if err := database.Put(nil, []byte("buffered"), []byte("value")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			database = crashAndReopen(t, database, faultFS, dir, opts)
+			defer database.Close()
+
+			if _, err := database.Get(nil, []byte("flushed")); err != nil {
+				t.Errorf("Get(flushed) failed: %v", err)
+			}
+			if _, err := database.Get(nil, []byte("buffered")); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(buffered) = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestManualWalFlushSyncWrite(t *testing.T) {
+	dir := t.TempDir()
+	faultFS := vfs.NewFaultInjectionFS(vfs.Default())
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.FS = faultFS
+	opts.ManualWalFlush = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("buffered"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	// A synced write flushes the records buffered before it
+	if err := database.Put(&WriteOptions{Sync: true}, []byte("synced"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	database = crashAndReopen(t, database, faultFS, dir, opts)
+	defer database.Close()
+
+	for _, key := range []string{"buffered", "synced"} {
+		if _, err := database.Get(nil, []byte(key)); err != nil {
+			t.Errorf("Get(%s) failed: %v", key, err)
+		}
+	}
+}
+
+func TestManualWalFlushClose(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.ManualWalFlush = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A clean close writes the buffered records
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer database.Close()
+	if _, err := database.Get(nil, []byte("key")); err != nil {
+		t.Errorf("Get(key) failed: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package rockyardkv
+
+// wal_recycle.go implements WAL file recycling (Options.RecycleLogFileNum).
+//
+// A WAL file is obsolete once all of its records have been flushed, i.e. its
+// number is below the MANIFEST's log number. Since the WAL is only switched
+// on open, the WAL files replayed at open become obsolete when the memtable
+// they were replayed into is flushed. On the next open, up to
+// RecycleLogFileNum obsolete files are kept and the oldest one is renamed and
+// overwritten to become the new WAL; the others are deleted.
+//
+// A reused file still holds the records of its previous use past the new
+// data. WAL files are therefore written in the recyclable record format,
+// whose headers carry the log number, and the reader ends the log at the
+// first record that belongs to another log.
+//
+// Reference: RocksDB v10.7.5
+//   - db/db_impl/db_impl_files.cc (log_recycle_files_)
+//   - db/db_impl/db_impl_open.cc (DBImpl::CreateWAL)
+//   - db/log_reader.cc (kOldRecord)
+
+import (
+	"io"
+	"slices"
+
+	"github.com/aalhour/rockyardkv/internal/manifest"
+	"github.com/aalhour/rockyardkv/internal/wal"
+)
+
+// recycleLogs reports whether obsolete WAL files are recycled. WAL archival
+// takes precedence, since an archived file must keep its records.
+//
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_open.cc (SanitizeOptions)
+func (db *dbImpl) recycleLogs() bool {
+	return db.options.RecycleLogFileNum > 0 && !db.walArchivalEnabled()
+}
+
+// collectObsoleteLogs returns up to RecycleLogFileNum obsolete WAL files to
+// reuse, oldest first, and deletes the other obsolete WAL files.
+//
+// Deletion is best-effort like deleteOrphanedSSTFiles: an obsolete WAL that
+// cannot be deleted is never replayed again.
+func (db *dbImpl) collectObsoleteLogs() []uint64 {
+	logFiles, err := db.findLogFiles()
+	if err != nil {
+		db.logger.Warnf("[wal] failed to list obsolete WAL files: %v", err)
+		return nil
+	}
+	slices.Sort(logFiles)
+
+	minLogNumber := db.versions.LogNumber()
+	var recycle []uint64
+	for _, num := range logFiles {
+		if num >= minLogNumber {
+			break
+		}
+		if len(recycle) < db.options.RecycleLogFileNum && db.isRecyclableLogFile(num) {
+			recycle = append(recycle, num)
+			continue
+		}
+		if err := db.fs.Remove(db.logFilePath(num)); err != nil {
+			db.logger.Warnf("[wal] failed to delete obsolete WAL file %d: %v (continuing best-effort)", num, err)
+		}
+	}
+	return recycle
+}
+
+// isRecyclableLogFile reports whether the WAL file was written in the
+// recyclable format. Stale records in the legacy format carry no log number,
+// so a file that starts with one cannot be reused safely.
+func (db *dbImpl) isRecyclableLogFile(logNum uint64) bool {
+	file, err := db.fs.Open(db.logFilePath(logNum))
+	if err != nil {
+		return false
+	}
+	defer func() { _ = file.Close() }()
+
+	var header [wal.HeaderSize]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		// Too short to hold a record
+		return err == io.EOF || err == io.ErrUnexpectedEOF
+	}
+	return wal.IsRecyclableType(wal.RecordType(header[6]))
+}
+
+// trackRecoveredLogs arranges for the WAL files replayed at open to become
+// obsolete: right away if they held no records, or once db.recoveredMem is
+// flushed. Records replayed into other column families keep the files live,
+// since their memtables are never flushed.
+func (db *dbImpl) trackRecoveredLogs(edit *manifest.VersionEdit, logNumber uint64) {
+	cfRecords := false
+	db.columnFamilies.forEach(func(cfd *columnFamilyData) {
+		if cfd.id != DefaultColumnFamilyID && cfd.mem != nil && !cfd.mem.Empty() {
+			cfRecords = true
+		}
+	})
+
+	switch {
+	case cfRecords:
+	case db.mem.Empty():
+		edit.HasLogNumber = true
+		edit.LogNumber = logNumber
+	default:
+		db.recoveredMem = db.mem
+	}
+}
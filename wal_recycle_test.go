@@ -0,0 +1,112 @@
+package rockyardkv
+
+// wal_recycle_test.go implements tests for WAL file recycling.
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// walFileSizes returns the sizes of the WAL files in dir by log number.
+func walFileSizes(t *testing.T, dir string) map[uint64]int64 {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	sizes := make(map[uint64]int64)
+	for _, e := range entries {
+		var num uint64
+		if _, err := fmt.Sscanf(e.Name(), "%d.log", &num); err != nil || filepath.Ext(e.Name()) != ".log" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+		sizes[num] = info.Size()
+	}
+	return sizes
+}
+
+func TestRecycleLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.RecycleLogFileNum = 1
+
+	reopen := func() DB {
+		t.Helper()
+		database, err := Open(dir, opts)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return database
+	}
+	value := make([]byte, 1000)
+
+	// The first WAL holds many large records
+	database := reopen()
+	for i := range 200 {
+		if err := database.Put(nil, fmt.Appendf(nil, "old%03d", i), value); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	database.Close()
+
+	// Delete the keys and drop them from the SST files. Flushing the
+	// memtable replayed from the first WAL makes that WAL obsolete.
+	database = reopen()
+	for i := range 200 {
+		if err := database.Delete(nil, fmt.Appendf(nil, "old%03d", i)); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := database.CompactRange(nil, nil, nil); err != nil {
+		t.Fatalf("CompactRange failed: %v", err)
+	}
+	database.Close()
+
+	before := walFileSizes(t, dir)
+	oldest := slices.Sorted(maps.Keys(before))[0]
+
+	// The obsolete WAL is reused for the new WAL, stale records and all
+	database = reopen()
+	newLog := database.(*dbImpl).logFileNumber
+	after := walFileSizes(t, dir)
+	if _, ok := after[oldest]; ok {
+		t.Errorf("WAL %d still exists, want it reused", oldest)
+	}
+	if after[newLog] != before[oldest] {
+		t.Errorf("new WAL %d has %d bytes, want the %d bytes of reused WAL %d",
+			newLog, after[newLog], before[oldest], oldest)
+	}
+	for i := range 3 {
+		if err := database.Put(nil, fmt.Appendf(nil, "new%d", i), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	database.Close()
+
+	// Recovery stops at the end of the new records
+	database = reopen()
+	defer database.Close()
+	for i := range 3 {
+		if _, err := database.Get(nil, fmt.Appendf(nil, "new%d", i)); err != nil {
+			t.Errorf("Get(new%d) failed: %v", i, err)
+		}
+	}
+	for i := range 200 {
+		if _, err := database.Get(nil, fmt.Appendf(nil, "old%03d", i)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(old%03d) = %v, want ErrNotFound", i, err)
+		}
+	}
+}
@@ -20,8 +20,13 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"sort"
+
+	"github.com/aalhour/rockyardkv/internal/batch"
+	"github.com/aalhour/rockyardkv/internal/dbformat"
+	"github.com/aalhour/rockyardkv/internal/wide"
 )
 
 // WideColumn represents a named column with a value.
@@ -81,8 +86,120 @@ func (p *PinnableWideColumns) Release() {
 var (
 	ErrWideColumnTooShort = errors.New("wide_column: data too short")
 	ErrWideColumnCorrupt  = errors.New("wide_column: corrupt data")
+
+	// ErrWideColumnDuplicate is returned by PutEntity when two columns have
+	// the same name.
+	ErrWideColumnDuplicate = errors.New("wide_column: duplicate column name")
 )
 
+// DefaultWideColumnName is the name of the default column of an entity,
+// whose value plain reads return.
+//
+// Reference: RocksDB v10.7.5 include/rocksdb/wide_columns.h (kDefaultWideColumnName)
+var DefaultWideColumnName = []byte{}
+
+// defaultColumnValue returns the value of the default column of a
+// serialized entity, which plain reads and iterators return.
+func defaultColumnValue(entity []byte) ([]byte, error) {
+	value, err := wide.DefaultColumnValue(entity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruption, err)
+	}
+	return value, nil
+}
+
+// PutEntity stores a wide-column entity as a TypeWideColumnEntity entry.
+//
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl_write.cc (DBImpl::PutEntity)
+func (db *dbImpl) PutEntity(opts *WriteOptions, cf ColumnFamilyHandle, key []byte, columns []WideColumn) error {
+	cfd, err := db.getColumnFamilyData(cf)
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]wide.Column, len(columns))
+	for i, col := range columns {
+		sorted[i] = wide.Column{Name: col.Name, Value: col.Value}
+	}
+	wide.SortColumns(sorted)
+	entity, err := wide.Serialize(sorted)
+	if errors.Is(err, wide.ErrColumnsOutOfOrder) {
+		return ErrWideColumnDuplicate
+	}
+	if err != nil {
+		return err
+	}
+
+	internal := batch.New()
+	internal.PutEntityCF(cfd.id, key, entity)
+	return db.Write(opts, newWriteBatchFromInternal(internal))
+}
+
+// GetEntity retrieves the columns of a key. A plain value is returned as
+// the default column.
+//
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc (DBImpl::GetEntity)
+func (db *dbImpl) GetEntity(opts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([]WideColumn, error) {
+	value, valueType, err := db.getImpl(opts, cf, key)
+	if err != nil {
+		return nil, err
+	}
+	if valueType != dbformat.TypeWideColumnEntity {
+		return []WideColumn{{Name: DefaultWideColumnName, Value: value}}, nil
+	}
+
+	return decodeEntity(value)
+}
+
+// MultiGetEntity retrieves the columns of several keys. Unless opts carries
+// a snapshot, all keys are read at the sequence number current at the call.
+//
+// Reference: RocksDB v10.7.5 db/db_impl/db_impl.cc (DBImpl::MultiGetEntity)
+func (db *dbImpl) MultiGetEntity(opts *ReadOptions, cf ColumnFamilyHandle, keys [][]byte) ([][]WideColumn, []error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if opts == nil {
+		opts = DefaultReadOptions()
+	}
+	if opts.Snapshot == nil {
+		snapshot := db.GetSnapshot()
+		defer db.ReleaseSnapshot(snapshot)
+		pinned := *opts
+		pinned.Snapshot = snapshot
+		opts = &pinned
+	}
+
+	results := make([][]WideColumn, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		results[i], errs[i] = db.GetEntity(opts, cf, key)
+	}
+	return results, errs
+}
+
+// decodeEntity decodes a serialized entity. The columns alias entity.
+func decodeEntity(entity []byte) ([]WideColumn, error) {
+	columns, err := wide.Deserialize(entity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruption, err)
+	}
+	result := make([]WideColumn, len(columns))
+	for i, col := range columns {
+		result[i] = WideColumn{Name: col.Name, Value: col.Value}
+	}
+	return result, nil
+}
+
+// defaultColumn returns the value of the default column among columns
+// ordered by name, or an empty value if there is none.
+func defaultColumn(columns []WideColumn) []byte {
+	if len(columns) > 0 && len(columns[0].Name) == 0 {
+		return columns[0].Value
+	}
+	return []byte{}
+}
+
 // EncodeWideColumns encodes wide columns to bytes.
 func EncodeWideColumns(columns WideColumns) ([]byte, error) {
 	// Sort columns by name for consistent encoding
@@ -5,7 +5,9 @@ package rockyardkv
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -298,6 +300,266 @@ func TestDecodeWideColumnsErrors(t *testing.T) {
 	}
 }
 
+func TestPutGetEntity(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	columns := []WideColumn{
+		{Name: []byte("name"), Value: []byte("Alice")},
+		{Name: DefaultWideColumnName, Value: []byte("user")},
+		{Name: []byte("email"), Value: []byte("alice@example.com")},
+	}
+	if err := database.PutEntity(nil, nil, []byte("user:1"), columns); err != nil {
+		t.Fatalf("PutEntity failed: %v", err)
+	}
+	if err := database.Put(nil, []byte("plain"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	dup := []WideColumn{{Name: []byte("a")}, {Name: []byte("a")}}
+	if err := database.PutEntity(nil, nil, []byte("dup"), dup); !errors.Is(err, ErrWideColumnDuplicate) {
+		t.Errorf("PutEntity with duplicate columns = %v, want ErrWideColumnDuplicate", err)
+	}
+
+	verify := func(db DB) {
+		t.Helper()
+		got, err := db.GetEntity(nil, nil, []byte("user:1"))
+		if err != nil {
+			t.Fatalf("GetEntity failed: %v", err)
+		}
+		want := []WideColumn{
+			{Name: DefaultWideColumnName, Value: []byte("user")},
+			{Name: []byte("email"), Value: []byte("alice@example.com")},
+			{Name: []byte("name"), Value: []byte("Alice")},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("GetEntity returned %d columns, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if !bytes.Equal(got[i].Name, want[i].Name) || !bytes.Equal(got[i].Value, want[i].Value) {
+				t.Errorf("Column %d = %q:%q, want %q:%q", i, got[i].Name, got[i].Value, want[i].Name, want[i].Value)
+			}
+		}
+
+		// Plain reads see the default column
+		value, err := db.Get(nil, []byte("user:1"))
+		if err != nil || string(value) != "user" {
+			t.Errorf("Get(user:1) = %q, %v; want user", value, err)
+		}
+		it := db.NewIterator(nil)
+		it.Seek([]byte("user:1"))
+		if !it.Valid() || string(it.Value()) != "user" {
+			t.Errorf("Iterator value at user:1 = %q, want user", it.Value())
+		}
+		_ = it.Close()
+
+		// Plain values read as a single default column
+		got, err = db.GetEntity(nil, nil, []byte("plain"))
+		if err != nil || len(got) != 1 || len(got[0].Name) != 0 || string(got[0].Value) != "value" {
+			t.Errorf("GetEntity(plain) = %q, %v; want the default column", got, err)
+		}
+
+		if _, err := db.GetEntity(nil, nil, []byte("missing")); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetEntity(missing) = %v, want ErrNotFound", err)
+		}
+	}
+
+	// From the memtable, then the WAL, then an SST
+	verify(database)
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	database, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer database.Close()
+	verify(database)
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	verify(database)
+
+	// Merge operands apply to the default column, keeping the others
+	if err := database.Merge(nil, []byte("user:1"), []byte("admin")); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	value, err := database.Get(nil, []byte("user:1"))
+	if err != nil || string(value) != "user,admin" {
+		t.Errorf("Get after Merge = %q, %v; want user,admin", value, err)
+	}
+	got, err := database.GetEntity(nil, nil, []byte("user:1"))
+	if err != nil || len(got) != 3 || string(got[2].Value) != "Alice" {
+		t.Errorf("GetEntity after Merge = %q, %v; want the named columns kept", got, err)
+	}
+}
+
+func TestMultiGetEntity(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	cf, err := database.CreateColumnFamily(DefaultColumnFamilyOptions(), "rows")
+	if err != nil {
+		t.Fatalf("CreateColumnFamily failed: %v", err)
+	}
+
+	row := func(i int) []WideColumn {
+		return []WideColumn{
+			{Name: []byte("id"), Value: fmt.Appendf(nil, "%d", i)},
+			{Name: []byte("score"), Value: fmt.Appendf(nil, "%d", i*10)},
+		}
+	}
+	for i := range 3 {
+		if err := database.PutEntity(nil, cf, fmt.Appendf(nil, "row%d", i), row(i)); err != nil {
+			t.Fatalf("PutEntity failed: %v", err)
+		}
+	}
+	if err := database.PutCF(nil, cf, []byte("plain"), []byte("value")); err != nil {
+		t.Fatalf("PutCF failed: %v", err)
+	}
+
+	keys := [][]byte{[]byte("row2"), []byte("missing"), []byte("row0"), []byte("plain"), []byte("row1")}
+	results, errs := database.MultiGetEntity(nil, cf, keys)
+	if len(results) != len(keys) || len(errs) != len(keys) {
+		t.Fatalf("MultiGetEntity returned %d results and %d errors, want %d", len(results), len(errs), len(keys))
+	}
+
+	want := map[string][]WideColumn{
+		"row0":  row(0),
+		"row1":  row(1),
+		"row2":  row(2),
+		"plain": {{Name: DefaultWideColumnName, Value: []byte("value")}},
+	}
+	for i, key := range keys {
+		wantColumns, ok := want[string(key)]
+		if !ok {
+			if !errors.Is(errs[i], ErrNotFound) || results[i] != nil {
+				t.Errorf("MultiGetEntity(%s) = %q, %v; want ErrNotFound", key, results[i], errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("MultiGetEntity(%s) failed: %v", key, errs[i])
+		}
+		if len(results[i]) != len(wantColumns) {
+			t.Fatalf("MultiGetEntity(%s) = %q, want %q", key, results[i], wantColumns)
+		}
+		for j, col := range wantColumns {
+			if !bytes.Equal(results[i][j].Name, col.Name) || !bytes.Equal(results[i][j].Value, col.Value) {
+				t.Errorf("MultiGetEntity(%s)[%d] = %q:%q, want %q:%q", key, j, results[i][j].Name, results[i][j].Value, col.Name, col.Value)
+			}
+		}
+	}
+
+	// Entities in one column family are invisible in another
+	if _, errs := database.MultiGetEntity(nil, nil, keys[:1]); !errors.Is(errs[0], ErrNotFound) {
+		t.Errorf("MultiGetEntity in default CF = %v, want ErrNotFound", errs[0])
+	}
+}
+
+func TestIteratorColumns(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	// Entities and plain values interleaved in one column family
+	want := map[string][]WideColumn{
+		"a": {{Name: DefaultWideColumnName, Value: []byte("plain-a")}},
+		"b": {
+			{Name: DefaultWideColumnName, Value: []byte("default-b")},
+			{Name: []byte("city"), Value: []byte("Berlin")},
+			{Name: []byte("name"), Value: []byte("Bob")},
+		},
+		"c": {{Name: DefaultWideColumnName, Value: []byte("plain-c")}},
+		"d": {
+			{Name: []byte("city"), Value: []byte("Dublin")},
+			{Name: []byte("name"), Value: []byte("Dana")},
+		},
+	}
+	for _, key := range []string{"a", "c"} {
+		if err := database.Put(nil, []byte(key), want[key][0].Value); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	for _, key := range []string{"b", "d"} {
+		if err := database.PutEntity(nil, nil, []byte(key), want[key]); err != nil {
+			t.Fatalf("PutEntity failed: %v", err)
+		}
+	}
+
+	check := func(it Iterator) {
+		t.Helper()
+		key := string(it.Key())
+		got := it.Columns()
+		if len(got) != len(want[key]) {
+			t.Fatalf("Columns(%s) = %q, want %q", key, got, want[key])
+		}
+		for i, col := range want[key] {
+			if !bytes.Equal(got[i].Name, col.Name) || !bytes.Equal(got[i].Value, col.Value) {
+				t.Errorf("Columns(%s)[%d] = %q:%q, want %q:%q", key, i, got[i].Name, got[i].Value, col.Name, col.Value)
+			}
+		}
+		wantValue := []byte{}
+		if len(want[key][0].Name) == 0 {
+			wantValue = want[key][0].Value
+		}
+		if !bytes.Equal(it.Value(), wantValue) {
+			t.Errorf("Value(%s) = %q, want %q", key, it.Value(), wantValue)
+		}
+	}
+
+	scan := func() {
+		t.Helper()
+		it := database.NewIterator(nil)
+		defer it.Close()
+
+		var forward, backward []string
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			check(it)
+			forward = append(forward, string(it.Key()))
+		}
+		for it.SeekToLast(); it.Valid(); it.Prev() {
+			check(it)
+			backward = append(backward, string(it.Key()))
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("Iterator error: %v", err)
+		}
+		if got := strings.Join(forward, ","); got != "a,b,c,d" {
+			t.Errorf("Forward scan = %s, want a,b,c,d", got)
+		}
+		if got := strings.Join(backward, ","); got != "d,c,b,a" {
+			t.Errorf("Backward scan = %s, want d,c,b,a", got)
+		}
+	}
+
+	scan()
+	if err := database.Flush(nil); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	scan()
+}
+
 func BenchmarkEncodeWideColumns(b *testing.B) {
 	columns := WideColumns{
 		{Name: []byte("field1"), Value: []byte("value1")},
@@ -5,6 +5,8 @@ package rockyardkv
 // Reference: RocksDB v10.7.5 include/rocksdb/write_batch.h
 
 import (
+	"fmt"
+
 	"github.com/aalhour/rockyardkv/internal/batch"
 )
 
@@ -13,6 +15,11 @@ import (
 //
 // A WriteBatch can be reused by calling Clear() after Write().
 //
+// The *CF methods take the ID of a column family (ColumnFamilyHandle.ID), so
+// one batch can update several column families atomically. Write fails with
+// ErrColumnFamilyNotFound, applying none of the batch, if one of them does
+// not exist.
+//
 // Example:
 //
 //	wb := db.NewWriteBatch()
@@ -32,6 +39,36 @@ func NewWriteBatch() *WriteBatch {
 	}
 }
 
+// NewWriteBatchWithProtection creates a new empty WriteBatch whose entries
+// carry protectionBytesPerKey bytes of protection info, computed as each entry
+// is added. Corruption of the batch's contents before Write is then detected
+// and fails the write. Supported values are 0, 1, 2, 4, and 8.
+// Reference: RocksDB v10.7.5 include/rocksdb/write_batch.h (protection_bytes_per_key)
+func NewWriteBatchWithProtection(protectionBytesPerKey int) (*WriteBatch, error) {
+	internal := batch.New()
+	if err := internal.UpdateProtectionInfo(protectionBytesPerKey); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+	}
+	return &WriteBatch{internal: internal}, nil
+}
+
+// NewWriteBatchFromData creates a WriteBatch from data returned by Data,
+// e.g. to replay a batch that was stored or sent elsewhere. The data is
+// copied and checked: it must decode into whole records whose number matches
+// the header's count, or ErrCorruption is returned. Protection info is not
+// part of the data, so the new batch is unprotected.
+// Reference: RocksDB v10.7.5 include/rocksdb/write_batch.h (WriteBatch(const std::string& rep))
+func NewWriteBatchFromData(data []byte) (*WriteBatch, error) {
+	internal, err := batch.NewFromData(append([]byte(nil), data...))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruption, err)
+	}
+	if err := internal.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruption, err)
+	}
+	return &WriteBatch{internal: internal}, nil
+}
+
 // Put adds a key-value pair to the batch.
 func (wb *WriteBatch) Put(key, value []byte) {
 	wb.internal.Put(key, value)
@@ -53,6 +90,9 @@ func (wb *WriteBatch) DeleteCF(cfID uint32, key []byte) {
 }
 
 // DeleteRange adds a range deletion [startKey, endKey) to the batch.
+// Entries take effect in batch order: the range deletion removes keys
+// written before it, including by earlier entries of the batch, but not
+// keys written by later entries.
 func (wb *WriteBatch) DeleteRange(startKey, endKey []byte) {
 	wb.internal.DeleteRange(startKey, endKey)
 }
@@ -93,7 +133,30 @@ func (wb *WriteBatch) Count() uint32 {
 	return wb.internal.Count()
 }
 
-// Data returns the raw batch data (for advanced use only).
+// DataSize returns the size in bytes of the serialized batch, including its
+// header and the per-record tags and lengths. This is the number of bytes
+// the batch adds to the WAL.
+// Reference: RocksDB v10.7.5 include/rocksdb/write_batch.h (GetDataSize)
+func (wb *WriteBatch) DataSize() int {
+	return wb.internal.Size()
+}
+
+// ApproximateSize returns the approximate memory held by the batch: its
+// data plus the protection info of each entry of a protected batch. Use it
+// to cap the memory of a batch that is filled dynamically.
+func (wb *WriteBatch) ApproximateSize() int {
+	return wb.internal.ApproximateSize()
+}
+
+// ProtectionBytesPerKey returns the number of protection bytes each entry
+// carries, or 0 if the batch is unprotected.
+func (wb *WriteBatch) ProtectionBytesPerKey() int {
+	return wb.internal.ProtectionBytesPerKey()
+}
+
+// Data returns the serialized batch: a 12-byte header holding the sequence
+// number and the record count, followed by the records. The slice is shared
+// with the batch. NewWriteBatchFromData rebuilds a batch from it.
 func (wb *WriteBatch) Data() []byte {
 	return wb.internal.Data()
 }
@@ -3,6 +3,8 @@ package rockyardkv
 // write_batch_contract_test.go implements tests for write batch contract.
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/aalhour/rockyardkv/internal/batch"
@@ -405,6 +407,59 @@ func TestWriteBatch_CountMatchesOperations(t *testing.T) {
 	}
 }
 
+// TestWriteBatch_SizesTrackOperations verifies that DataSize and
+// ApproximateSize grow with every operation and that Clear resets them.
+func TestWriteBatch_SizesTrackOperations(t *testing.T) {
+	// A protected batch keeps a uint64 of protection info per entry
+	for _, tt := range []struct{ protection, perEntry int }{{0, 0}, {1, 8}, {8, 8}} {
+		protection := tt.protection
+		t.Run(fmt.Sprintf("protection=%d", protection), func(t *testing.T) {
+			wb, err := NewWriteBatchWithProtection(protection)
+			if err != nil {
+				t.Fatalf("NewWriteBatchWithProtection failed: %v", err)
+			}
+			emptyData, emptyApprox := wb.DataSize(), wb.ApproximateSize()
+			if emptyData != batch.HeaderSize || emptyApprox != batch.HeaderSize {
+				t.Errorf("empty batch: DataSize = %d, ApproximateSize = %d, want header size %d",
+					emptyData, emptyApprox, batch.HeaderSize)
+			}
+
+			ops := []func(){
+				func() { wb.Put([]byte("key1"), []byte("value1")) },
+				func() { wb.Delete([]byte("key2")) },
+				func() { wb.Merge([]byte("key3"), []byte("operand")) },
+			}
+			prevData, prevApprox := emptyData, emptyApprox
+			for i, op := range ops {
+				op()
+				if got := wb.Count(); got != uint32(i+1) {
+					t.Errorf("op %d: Count = %d, want %d", i, got, i+1)
+				}
+				data, approx := wb.DataSize(), wb.ApproximateSize()
+				if data <= prevData {
+					t.Errorf("op %d: DataSize = %d, want more than %d", i, data, prevData)
+				}
+				if data != len(wb.Data()) {
+					t.Errorf("op %d: DataSize = %d, want len(Data()) = %d", i, data, len(wb.Data()))
+				}
+				if want := data + tt.perEntry*(i+1); approx != want {
+					t.Errorf("op %d: ApproximateSize = %d, want %d", i, approx, want)
+				}
+				prevData, prevApprox = data, approx
+			}
+			if prevApprox < prevData {
+				t.Errorf("ApproximateSize = %d, want at least DataSize = %d", prevApprox, prevData)
+			}
+
+			wb.Clear()
+			if wb.Count() != 0 || wb.DataSize() != emptyData || wb.ApproximateSize() != emptyApprox {
+				t.Errorf("after Clear: Count = %d, DataSize = %d, ApproximateSize = %d, want 0, %d, %d",
+					wb.Count(), wb.DataSize(), wb.ApproximateSize(), emptyData, emptyApprox)
+			}
+		})
+	}
+}
+
 // TestWriteBatch_DataNotEmpty verifies that Data() returns non-empty bytes
 // after operations are added (it should contain at least the header).
 func TestWriteBatch_DataNotEmpty(t *testing.T) {
@@ -452,3 +507,107 @@ func TestNewWriteBatchFromInternal_PreservesData(t *testing.T) {
 		t.Errorf("Internal batch should also have count=4, got %d", internal.Count())
 	}
 }
+
+// TestNewWriteBatchFromData_RoundTrip verifies that a batch rebuilt from
+// Data applies exactly like the original batch.
+func TestNewWriteBatchFromData_RoundTrip(t *testing.T) {
+	newDB := func() (DB, func()) {
+		opts := DefaultOptions()
+		opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+		return createTestDB(t, opts)
+	}
+	original, closeOriginal := newDB()
+	defer closeOriginal()
+	replayed, closeReplayed := newDB()
+	defer closeReplayed()
+
+	for _, database := range []DB{original, replayed} {
+		for _, key := range []string{"deleted", "merged", "range1", "range2"} {
+			if err := database.Put(nil, []byte(key), []byte("old")); err != nil {
+				t.Fatalf("Put(%s) failed: %v", key, err)
+			}
+		}
+	}
+
+	wb := NewWriteBatch()
+	wb.Put([]byte("put"), []byte("value"))
+	wb.Delete([]byte("deleted"))
+	wb.Merge([]byte("merged"), []byte("operand"))
+	wb.DeleteRange([]byte("range"), []byte("range9"))
+	wb.SingleDelete([]byte("never-written"))
+
+	rebuilt, err := NewWriteBatchFromData(wb.Data())
+	if err != nil {
+		t.Fatalf("NewWriteBatchFromData failed: %v", err)
+	}
+	if rebuilt.Count() != wb.Count() {
+		t.Errorf("rebuilt Count = %d, want %d", rebuilt.Count(), wb.Count())
+	}
+	if err := original.Write(nil, wb); err != nil {
+		t.Fatalf("Write(original) failed: %v", err)
+	}
+	if err := replayed.Write(nil, rebuilt); err != nil {
+		t.Fatalf("Write(rebuilt) failed: %v", err)
+	}
+
+	for _, key := range []string{"put", "deleted", "merged", "range1", "range2", "never-written"} {
+		want, wantErr := original.Get(nil, []byte(key))
+		got, gotErr := replayed.Get(nil, []byte(key))
+		if string(got) != string(want) || !errors.Is(gotErr, wantErr) {
+			t.Errorf("Get(%s) = %q, %v after replay, want %q, %v", key, got, gotErr, want, wantErr)
+		}
+	}
+	if v, err := original.Get(nil, []byte("merged")); err != nil || string(v) != "old,operand" {
+		t.Errorf("Get(merged) = %q, %v, want \"old,operand\"", v, err)
+	}
+}
+
+// TestNewWriteBatchFromData_Copies verifies that the rebuilt batch does not
+// share the caller's buffer.
+func TestNewWriteBatchFromData_Copies(t *testing.T) {
+	wb := NewWriteBatch()
+	wb.Put([]byte("key"), []byte("value"))
+	data := wb.Data()
+
+	rebuilt, err := NewWriteBatchFromData(data)
+	if err != nil {
+		t.Fatalf("NewWriteBatchFromData failed: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if got := rebuilt.Data()[len(data)-1]; got != 'e' {
+		t.Errorf("rebuilt batch changed with the caller's buffer: last byte = %q", got)
+	}
+}
+
+// TestNewWriteBatchFromData_RejectsCorruption verifies that malformed data is
+// rejected with ErrCorruption.
+func TestNewWriteBatchFromData_RejectsCorruption(t *testing.T) {
+	wb := NewWriteBatch()
+	wb.Put([]byte("key1"), []byte("value1"))
+	wb.Delete([]byte("key2"))
+	valid := wb.Data()
+
+	withCount := func(count byte) []byte {
+		data := append([]byte(nil), valid...)
+		data[8] = count
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", nil},
+		{"ShortHeader", valid[:batch.HeaderSize-1]},
+		{"TruncatedRecord", valid[:len(valid)-1]},
+		{"UnknownTag", append(append([]byte(nil), valid...), 0x7f)},
+		{"CountTooHigh", withCount(3)},
+		{"CountTooLow", withCount(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWriteBatchFromData(tt.data); !errors.Is(err, ErrCorruption) {
+				t.Errorf("NewWriteBatchFromData = %v, want ErrCorruption", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package rockyardkv
+
+// write_batch_protection_test.go implements tests for write batch protection info.
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/batch"
+	"github.com/aalhour/rockyardkv/internal/memtable"
+)
+
+func openProtectionTestDB(t *testing.T) DB {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// TestWriteBatchProtectionDetectsTampering verifies that corrupting a protected
+// batch after its entries were added fails the write and persists nothing.
+func TestWriteBatchProtectionDetectsTampering(t *testing.T) {
+	database := openProtectionTestDB(t)
+
+	wb, err := NewWriteBatchWithProtection(8)
+	if err != nil {
+		t.Fatalf("NewWriteBatchWithProtection failed: %v", err)
+	}
+	wb.Put([]byte("key"), []byte("value"))
+
+	// Simulate in-memory corruption of the value bytes
+	data := wb.Data()
+	data[len(data)-1] ^= 0x01
+
+	err = database.Write(&WriteOptions{ProtectionBytesPerKey: 8}, wb)
+	if !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Write err = %v, want ErrCorruption", err)
+	}
+	if _, err := database.Get(nil, []byte("key")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after failed write err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWriteBatchProtectionWrites(t *testing.T) {
+	database := openProtectionTestDB(t)
+
+	// Protected at insert time
+	wb, err := NewWriteBatchWithProtection(8)
+	if err != nil {
+		t.Fatalf("NewWriteBatchWithProtection failed: %v", err)
+	}
+	wb.Put([]byte("a"), []byte("1"))
+	wb.Delete([]byte("b"))
+	if err := database.Write(nil, wb); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Protected at write time
+	plain := NewWriteBatch()
+	plain.Put([]byte("c"), []byte("3"))
+	if err := database.Write(&WriteOptions{ProtectionBytesPerKey: 8}, plain); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got := plain.ProtectionBytesPerKey(); got != 8 {
+		t.Errorf("ProtectionBytesPerKey = %d, want 8", got)
+	}
+
+	for key, want := range map[string]string{"a": "1", "c": "3"} {
+		got, err := database.Get(nil, []byte(key))
+		if err != nil || string(got) != want {
+			t.Errorf("Get(%s) = %q, %v; want %q", key, got, err, want)
+		}
+	}
+}
+
+func TestWriteBatchProtectionInvalidBytes(t *testing.T) {
+	database := openProtectionTestDB(t)
+
+	if _, err := NewWriteBatchWithProtection(3); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("NewWriteBatchWithProtection(3) err = %v, want ErrInvalidOptions", err)
+	}
+
+	wb := NewWriteBatch()
+	wb.Put([]byte("key"), []byte("value"))
+	if err := database.Write(&WriteOptions{ProtectionBytesPerKey: 5}, wb); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Write err = %v, want ErrInvalidOptions", err)
+	}
+}
+
+// TestWriteBatchProtectionMemtableInsert verifies that the memtable inserter
+// checks every entry of a protected batch, including batches protected only
+// when they were written.
+func TestWriteBatchProtectionMemtableInsert(t *testing.T) {
+	database := openProtectionTestDB(t)
+	db := database.(*dbImpl)
+
+	wb := batch.New()
+	wb.Put([]byte("a"), []byte("1"))
+	wb.Put([]byte("b"), []byte("2"))
+	if err := protectWriteBatch(&WriteOptions{ProtectionBytesPerKey: 8}, wb); err != nil {
+		t.Fatalf("protectWriteBatch failed: %v", err)
+	}
+
+	// Corrupt the last value, as if after the batch was logged
+	data := wb.Data()
+	data[len(data)-1] ^= 0x01
+
+	mem := memtable.NewMemTable(nil)
+	inserter := &memtableInserter{db: db, sequence: 1, defaultMem: mem, prot: wb.NewProtectionVerifier()}
+	if err := wb.Iterate(inserter); !errors.Is(err, ErrCorruption) {
+		t.Fatalf("Iterate err = %v, want ErrCorruption", err)
+	}
+	if _, found, _ := mem.Get([]byte("b"), 2); found {
+		t.Error("corrupted entry was inserted into the memtable")
+	}
+}
@@ -0,0 +1,241 @@
+package rockyardkv
+
+// write_batch_with_index.go implements WriteBatchWithIndex, a write batch
+// that also indexes its entries by key so they can be read back before the
+// batch is written.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/utilities/write_batch_with_index.h
+//   - utilities/write_batch_with_index/write_batch_with_index.cc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMergeInProgress is returned by WriteBatchWithIndex.GetFromBatch when the
+// batch holds only merge operands for the key, so the result depends on the
+// base value in the database.
+var ErrMergeInProgress = errors.New("db: merge in progress")
+
+// WriteBatchWithIndex is a WriteBatch with a searchable index of its entries.
+// Reads through GetFromBatch and GetFromBatchAndDB see the batch's own writes,
+// with staged merge operands folded on top of the base value.
+//
+// Example:
+//
+//	wbwi := NewWriteBatchWithIndex()
+//	wbwi.Merge([]byte("key"), []byte("b"))
+//	value, err := wbwi.GetFromBatchAndDB(database, nil, []byte("key"))
+//	err = database.Write(writeOpts, wbwi.GetWriteBatch())
+type WriteBatchWithIndex struct {
+	batch *WriteBatch
+
+	// Entries per column family and key, in the order they were added
+	index map[string][]wbwiEntry
+}
+
+// wbwiEntry is one indexed write.
+type wbwiEntry struct {
+	kind  wbwiEntryKind
+	value []byte
+}
+
+type wbwiEntryKind uint8
+
+const (
+	wbwiPut wbwiEntryKind = iota
+	wbwiDelete
+	wbwiMerge
+)
+
+// NewWriteBatchWithIndex creates a new empty WriteBatchWithIndex.
+func NewWriteBatchWithIndex() *WriteBatchWithIndex {
+	return &WriteBatchWithIndex{
+		batch: NewWriteBatch(),
+		index: make(map[string][]wbwiEntry),
+	}
+}
+
+// Put adds a key-value pair to the batch.
+func (w *WriteBatchWithIndex) Put(key, value []byte) {
+	w.PutCF(0, key, value)
+}
+
+// PutCF adds a key-value pair to the batch for the specified column family.
+func (w *WriteBatchWithIndex) PutCF(cfID uint32, key, value []byte) {
+	w.batch.PutCF(cfID, key, value)
+	w.addEntry(cfID, key, wbwiPut, value)
+}
+
+// Delete adds a deletion for the key to the batch.
+func (w *WriteBatchWithIndex) Delete(key []byte) {
+	w.DeleteCF(0, key)
+}
+
+// DeleteCF adds a deletion for the key to the batch for the specified column family.
+func (w *WriteBatchWithIndex) DeleteCF(cfID uint32, key []byte) {
+	w.batch.DeleteCF(cfID, key)
+	w.addEntry(cfID, key, wbwiDelete, nil)
+}
+
+// Merge adds a merge operand for the key to the batch.
+func (w *WriteBatchWithIndex) Merge(key, value []byte) {
+	w.MergeCF(0, key, value)
+}
+
+// MergeCF adds a merge operand to the batch for the specified column family.
+func (w *WriteBatchWithIndex) MergeCF(cfID uint32, key, value []byte) {
+	w.batch.MergeCF(cfID, key, value)
+	w.addEntry(cfID, key, wbwiMerge, value)
+}
+
+// Clear resets the batch and its index to empty.
+func (w *WriteBatchWithIndex) Clear() {
+	w.batch.Clear()
+	w.index = make(map[string][]wbwiEntry)
+}
+
+// Count returns the number of operations in the batch.
+func (w *WriteBatchWithIndex) Count() uint32 {
+	return w.batch.Count()
+}
+
+// GetWriteBatch returns the underlying WriteBatch, to be passed to DB.Write.
+func (w *WriteBatchWithIndex) GetWriteBatch() *WriteBatch {
+	return w.batch
+}
+
+// GetFromBatch reads the key from the batch only. Merge operands are combined
+// with the merge operator from opts. Returns ErrNotFound if the batch has no
+// value for the key, and ErrMergeInProgress if it holds only merge operands.
+func (w *WriteBatchWithIndex) GetFromBatch(opts *Options, key []byte) ([]byte, error) {
+	return w.GetFromBatchCF(opts, 0, key)
+}
+
+// GetFromBatchCF reads the key of the specified column family from the batch only.
+func (w *WriteBatchWithIndex) GetFromBatchCF(opts *Options, cfID uint32, key []byte) ([]byte, error) {
+	r := w.lookup(cfID, key)
+	switch {
+	case !r.found:
+		return nil, ErrNotFound
+	case r.kind == wbwiMerge:
+		return nil, ErrMergeInProgress
+	case r.kind == wbwiDelete && len(r.operands) == 0:
+		return nil, ErrNotFound
+	case len(r.operands) == 0:
+		return r.value, nil
+	}
+
+	var mergeOp MergeOperator
+	if opts != nil {
+		mergeOp = opts.MergeOperator
+	}
+	return fullMergeOperands(mergeOp, key, r.value, r.operands)
+}
+
+// GetFromBatchAndDB reads the key as if the batch had been written to db:
+// the batch's own writes take precedence, and merge operands staged in the
+// batch are applied on top of the value read from db.
+// Reference: RocksDB v10.7.5 utilities/write_batch_with_index/write_batch_with_index.cc GetFromBatchAndDB
+func (w *WriteBatchWithIndex) GetFromBatchAndDB(db DB, readOpts *ReadOptions, key []byte) ([]byte, error) {
+	return w.GetFromBatchAndDBCF(db, readOpts, nil, key)
+}
+
+// GetFromBatchAndDBCF is GetFromBatchAndDB for the specified column family.
+// A nil cf selects the default column family.
+func (w *WriteBatchWithIndex) GetFromBatchAndDBCF(db DB, readOpts *ReadOptions, cf ColumnFamilyHandle, key []byte) ([]byte, error) {
+	cfID := uint32(0)
+	if cf != nil {
+		cfID = cf.ID()
+	}
+
+	r := w.lookup(cfID, key)
+	if r.found && r.kind != wbwiMerge {
+		// A Put or Delete in the batch hides the database's value
+		if len(r.operands) == 0 {
+			if r.kind == wbwiDelete {
+				return nil, ErrNotFound
+			}
+			return r.value, nil
+		}
+		return fullMergeOperands(db.GetOptions().MergeOperator, key, r.value, r.operands)
+	}
+
+	// Nothing in the batch, or only merge operands: read the base from db
+	var base []byte
+	var err error
+	if cf == nil {
+		base, err = db.Get(readOpts, key)
+	} else {
+		base, err = db.GetCF(readOpts, cf, key)
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if !r.found {
+		return base, err
+	}
+
+	return fullMergeOperands(db.GetOptions().MergeOperator, key, base, r.operands)
+}
+
+// addEntry indexes a write that was just appended to the batch.
+func (w *WriteBatchWithIndex) addEntry(cfID uint32, key []byte, kind wbwiEntryKind, value []byte) {
+	k := makeTrackKey(cfID, string(key))
+	w.index[k] = append(w.index[k], wbwiEntry{kind: kind, value: append([]byte{}, value...)})
+}
+
+// wbwiLookupResult is the state of a key in the batch.
+// kind is the newest Put or Delete, or wbwiMerge if the batch holds only
+// merge operands. operands are the merge operands newer than it, newest first.
+type wbwiLookupResult struct {
+	found    bool
+	kind     wbwiEntryKind
+	value    []byte
+	operands [][]byte
+}
+
+// lookup walks the key's entries from newest to oldest, collecting merge
+// operands until it reaches a Put or Delete.
+func (w *WriteBatchWithIndex) lookup(cfID uint32, key []byte) wbwiLookupResult {
+	entries := w.index[makeTrackKey(cfID, string(key))]
+	if len(entries) == 0 {
+		return wbwiLookupResult{}
+	}
+
+	r := wbwiLookupResult{found: true, kind: wbwiMerge}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.kind == wbwiMerge {
+			r.operands = append(r.operands, e.value)
+			continue
+		}
+		r.kind = e.kind
+		if e.kind == wbwiPut {
+			r.value = append([]byte{}, e.value...)
+		}
+		break
+	}
+	return r
+}
+
+// fullMergeOperands combines newest-first operands with existingValue, which
+// is nil if there is no base value.
+func fullMergeOperands(mergeOp MergeOperator, key, existingValue []byte, operands [][]byte) ([]byte, error) {
+	if mergeOp == nil {
+		return nil, ErrMergeOperatorNotSet
+	}
+
+	// Reverse operands to get oldest-first order for FullMerge
+	reversed := make([][]byte, len(operands))
+	for i, op := range operands {
+		reversed[len(operands)-1-i] = op
+	}
+
+	result, ok := mergeOp.FullMerge(key, existingValue, reversed)
+	if !ok {
+		return nil, fmt.Errorf("merge operator failed for key %q", key)
+	}
+	return result, nil
+}
@@ -0,0 +1,258 @@
+package rockyardkv
+
+// write_batch_with_index_iterator.go implements NewIteratorWithBatch, an
+// iterator over the database overlaid with the writes staged in a
+// WriteBatchWithIndex.
+//
+// Reference: RocksDB v10.7.5
+//   - include/rocksdb/utilities/write_batch_with_index.h (NewIteratorWithBase)
+//   - utilities/write_batch_with_index/write_batch_with_index_internal.cc (BaseDeltaIterator)
+
+import (
+	"bytes"
+	"slices"
+)
+
+// NewIteratorWithBatch returns an iterator over the default column family
+// as of opts.Snapshot (or the current state) with the writes staged in wbwi
+// applied on top, as if the batch had been written: staged Puts replace
+// the database's values, staged Deletes hide its keys, and staged merge
+// operands are merged onto the database's values with Options.MergeOperator.
+//
+// The iterator sees the batch as it was when the iterator was created; it
+// honors IterateLowerBound and IterateUpperBound for the staged keys too.
+func (db *dbImpl) NewIteratorWithBatch(opts *ReadOptions, wbwi *WriteBatchWithIndex) Iterator {
+	if opts == nil {
+		opts = DefaultReadOptions()
+	}
+	base := db.NewIterator(opts)
+
+	cmp := db.cmp
+	var delta []batchDeltaEntry
+	for trackKey := range wbwi.index {
+		if len(trackKey) < 4 || trackKey[:4] != makeTrackKey(DefaultColumnFamilyID, "") {
+			continue
+		}
+		key := []byte(trackKey[4:])
+		if (opts.IterateLowerBound != nil && cmp.Compare(key, opts.IterateLowerBound) < 0) ||
+			(opts.IterateUpperBound != nil && cmp.Compare(key, opts.IterateUpperBound) >= 0) {
+			continue
+		}
+		delta = append(delta, batchDeltaEntry{key: key, batchEntry: wbwi.lookup(DefaultColumnFamilyID, key)})
+	}
+	slices.SortFunc(delta, func(a, b batchDeltaEntry) int { return cmp.Compare(a.key, b.key) })
+
+	return &batchOverlayIterator{
+		base:    base,
+		delta:   delta,
+		cmp:     cmp,
+		mergeOp: db.options.MergeOperator,
+	}
+}
+
+// batchDeltaEntry is the state of one key staged in the batch.
+type batchDeltaEntry struct {
+	key        []byte
+	batchEntry wbwiLookupResult
+}
+
+// batchOverlayIterator merges a database iterator with the sorted keys of a
+// batch. Where both hold a key the batch entry wins, merged with the
+// database's value if the batch holds only merge operands for it.
+type batchOverlayIterator struct {
+	base    Iterator
+	delta   []batchDeltaEntry
+	cmp     Comparator
+	mergeOp MergeOperator
+
+	// Position in delta; -1 or len(delta) when exhausted
+	deltaPos int
+	forward  bool
+
+	valid     bool
+	fromDelta bool
+	key       []byte
+	value     []byte
+	err       error
+}
+
+func (it *batchOverlayIterator) SeekToFirst() {
+	it.forward = true
+	it.base.SeekToFirst()
+	it.deltaPos = 0
+	it.settle()
+}
+
+func (it *batchOverlayIterator) SeekToLast() {
+	it.forward = false
+	it.base.SeekToLast()
+	it.deltaPos = len(it.delta) - 1
+	it.settle()
+}
+
+func (it *batchOverlayIterator) Seek(target []byte) {
+	it.forward = true
+	it.base.Seek(target)
+	it.deltaPos, _ = slices.BinarySearchFunc(it.delta, target, func(e batchDeltaEntry, t []byte) int {
+		return it.cmp.Compare(e.key, t)
+	})
+	it.settle()
+}
+
+func (it *batchOverlayIterator) SeekForPrev(target []byte) {
+	it.forward = false
+	it.base.SeekForPrev(target)
+	pos, found := slices.BinarySearchFunc(it.delta, target, func(e batchDeltaEntry, t []byte) int {
+		return it.cmp.Compare(e.key, t)
+	})
+	if !found {
+		pos--
+	}
+	it.deltaPos = pos
+	it.settle()
+}
+
+func (it *batchOverlayIterator) Next() {
+	if !it.valid {
+		return
+	}
+	key := bytes.Clone(it.key)
+	if !it.forward {
+		// The current key is visible, so Seek lands on it
+		it.Seek(key)
+	}
+	it.advancePast(key)
+	it.settle()
+}
+
+func (it *batchOverlayIterator) Prev() {
+	if !it.valid {
+		return
+	}
+	key := bytes.Clone(it.key)
+	if it.forward {
+		it.SeekForPrev(key)
+	}
+	it.advancePast(key)
+	it.settle()
+}
+
+// advancePast moves whichever of the two inputs is at key one step in the
+// current direction.
+func (it *batchOverlayIterator) advancePast(key []byte) {
+	if it.base.Valid() && it.cmp.Compare(it.base.Key(), key) == 0 {
+		if it.forward {
+			it.base.Next()
+		} else {
+			it.base.Prev()
+		}
+	}
+	if it.deltaValid() && it.cmp.Compare(it.delta[it.deltaPos].key, key) == 0 {
+		if it.forward {
+			it.deltaPos++
+		} else {
+			it.deltaPos--
+		}
+	}
+}
+
+func (it *batchOverlayIterator) deltaValid() bool {
+	return it.deltaPos >= 0 && it.deltaPos < len(it.delta)
+}
+
+// settle positions the iterator at the entry the two inputs are at,
+// skipping keys that the batch deletes.
+func (it *batchOverlayIterator) settle() {
+	it.valid = false
+	for {
+		baseValid, deltaValid := it.base.Valid(), it.deltaValid()
+		if !baseValid && !deltaValid {
+			return
+		}
+
+		c := 0
+		switch {
+		case !deltaValid:
+			c = -1
+		case !baseValid:
+			c = 1
+		default:
+			c = it.cmp.Compare(it.base.Key(), it.delta[it.deltaPos].key)
+			if !it.forward {
+				c = -c
+			}
+		}
+		if c < 0 {
+			it.key, it.value, it.fromDelta = it.base.Key(), it.base.Value(), false
+			it.valid = true
+			return
+		}
+
+		e := it.delta[it.deltaPos]
+		var baseValue []byte
+		if c == 0 {
+			baseValue = it.base.Value()
+		}
+		r := e.batchEntry
+		switch {
+		case r.kind == wbwiDelete && len(r.operands) == 0:
+			// Staged deletion hides the key
+			it.advancePast(e.key)
+			continue
+		case r.kind == wbwiPut && len(r.operands) == 0:
+			it.value = r.value
+		default:
+			if r.kind == wbwiPut {
+				baseValue = r.value
+			} else if r.kind == wbwiDelete {
+				baseValue = nil
+			}
+			value, err := fullMergeOperands(it.mergeOp, e.key, baseValue, r.operands)
+			if err != nil {
+				it.err = err
+				return
+			}
+			it.value = value
+		}
+		it.key, it.fromDelta = e.key, true
+		it.valid = true
+		return
+	}
+}
+
+func (it *batchOverlayIterator) Valid() bool {
+	return it.valid && it.err == nil
+}
+
+func (it *batchOverlayIterator) Key() []byte {
+	return it.key
+}
+
+func (it *batchOverlayIterator) Value() []byte {
+	return it.value
+}
+
+func (it *batchOverlayIterator) Columns() []WideColumn {
+	if !it.Valid() {
+		return nil
+	}
+	if !it.fromDelta {
+		return it.base.Columns()
+	}
+	return []WideColumn{{Name: DefaultWideColumnName, Value: it.value}}
+}
+
+func (it *batchOverlayIterator) Status() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.base.Status()
+}
+
+func (it *batchOverlayIterator) Error() error {
+	return it.Status()
+}
+
+func (it *batchOverlayIterator) Close() error {
+	return it.base.Close()
+}
@@ -0,0 +1,235 @@
+package rockyardkv
+
+// write_batch_with_index_test.go implements tests for WriteBatchWithIndex.
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestWriteBatchWithIndexGetFromBatch(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+
+	wbwi := NewWriteBatchWithIndex()
+	wbwi.Put([]byte("a"), []byte("1"))
+	wbwi.Put([]byte("b"), []byte("2"))
+	wbwi.Delete([]byte("b"))
+	wbwi.Merge([]byte("c"), []byte("x"))
+	wbwi.Put([]byte("d"), []byte("base"))
+	wbwi.Merge([]byte("d"), []byte("y"))
+
+	if wbwi.Count() != 6 {
+		t.Errorf("Count() = %d, want 6", wbwi.Count())
+	}
+
+	if v, err := wbwi.GetFromBatch(opts, []byte("a")); err != nil || string(v) != "1" {
+		t.Errorf("GetFromBatch(a) = %q, %v, want 1", v, err)
+	}
+	if _, err := wbwi.GetFromBatch(opts, []byte("b")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFromBatch(b) error = %v, want ErrNotFound", err)
+	}
+	if _, err := wbwi.GetFromBatch(opts, []byte("c")); !errors.Is(err, ErrMergeInProgress) {
+		t.Errorf("GetFromBatch(c) error = %v, want ErrMergeInProgress", err)
+	}
+	if v, err := wbwi.GetFromBatch(opts, []byte("d")); err != nil || string(v) != "base,y" {
+		t.Errorf("GetFromBatch(d) = %q, %v, want base,y", v, err)
+	}
+	if _, err := wbwi.GetFromBatch(opts, []byte("missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFromBatch(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := wbwi.GetFromBatch(nil, []byte("d")); !errors.Is(err, ErrMergeOperatorNotSet) {
+		t.Errorf("GetFromBatch(d) without operator error = %v, want ErrMergeOperatorNotSet", err)
+	}
+
+	wbwi.Clear()
+	if _, err := wbwi.GetFromBatch(opts, []byte("a")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFromBatch(a) after Clear error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWriteBatchWithIndexGetFromBatchAndDBMerge(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Put(nil, []byte("key"), []byte("a")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := db.Put(nil, []byte("hidden"), []byte("old")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	wbwi := NewWriteBatchWithIndex()
+	wbwi.Merge([]byte("key"), []byte("b"))
+	wbwi.Merge([]byte("new"), []byte("x"))
+	wbwi.Delete([]byte("hidden"))
+	wbwi.Merge([]byte("hidden"), []byte("y"))
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"key", "a,b"},  // batch operand on top of the DB base
+		{"new", "x"},    // no base anywhere
+		{"hidden", "y"}, // batch Delete hides the DB value
+	}
+	for _, tt := range tests {
+		v, err := wbwi.GetFromBatchAndDB(db, nil, []byte(tt.key))
+		if err != nil {
+			t.Fatalf("GetFromBatchAndDB(%s) error = %v", tt.key, err)
+		}
+		if string(v) != tt.want {
+			t.Errorf("GetFromBatchAndDB(%s) = %q, want %q", tt.key, v, tt.want)
+		}
+	}
+
+	// Keys untouched by the batch come from the DB
+	if _, err := wbwi.GetFromBatchAndDB(db, nil, []byte("absent")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFromBatchAndDB(absent) error = %v, want ErrNotFound", err)
+	}
+
+	// Writing the batch yields the same result as reading through it
+	if err := db.Write(nil, wbwi.GetWriteBatch()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, tt := range tests {
+		v, err := db.Get(nil, []byte(tt.key))
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", tt.key, err)
+		}
+		if string(v) != tt.want {
+			t.Errorf("Get(%s) = %q, want %q", tt.key, v, tt.want)
+		}
+	}
+}
+
+func TestNewIteratorWithBatch(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.MergeOperator = &StringAppendOperator{Delimiter: ","}
+
+	db, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	for _, k := range []string{"a", "c", "e", "g"} {
+		if err := db.Put(nil, []byte(k), []byte("db-"+k)); err != nil {
+			t.Fatalf("Put(%s) error = %v", k, err)
+		}
+	}
+	// Part of the base data comes from an SST
+	if err := db.Flush(nil); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if err := db.Put(nil, []byte("i"), []byte("db-i")); err != nil {
+		t.Fatalf("Put(i) error = %v", err)
+	}
+
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	// Not visible at the snapshot
+	if err := db.Put(nil, []byte("h"), []byte("db-h")); err != nil {
+		t.Fatalf("Put(h) error = %v", err)
+	}
+
+	wbwi := NewWriteBatchWithIndex()
+	wbwi.Put([]byte("b"), []byte("batch-b")) // new key
+	wbwi.Put([]byte("c"), []byte("batch-c")) // overwrites a DB key
+	wbwi.Delete([]byte("e"))                 // hides a DB key
+	wbwi.Delete([]byte("f"))                 // deletes a key the DB lacks
+	wbwi.Merge([]byte("g"), []byte("m"))     // merges onto a DB value
+	wbwi.Put([]byte("z"), []byte("batch-z"))
+	wbwi.Delete([]byte("z")) // staged Put then Delete
+
+	want := []string{"a=db-a", "b=batch-b", "c=batch-c", "g=db-g,m", "i=db-i"}
+
+	readOpts := DefaultReadOptions()
+	readOpts.Snapshot = snap
+	iter := db.NewIteratorWithBatch(readOpts, wbwi)
+	defer iter.Close()
+
+	var got []string
+	for iter.SeekToFirst(); iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key())+"="+string(iter.Value()))
+	}
+	if err := iter.Status(); err != nil {
+		t.Fatalf("forward scan error = %v", err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("forward scan = %v, want %v", got, want)
+	}
+
+	got = got[:0]
+	for iter.SeekToLast(); iter.Valid(); iter.Prev() {
+		got = append(got, string(iter.Key())+"="+string(iter.Value()))
+	}
+	reversed := slices.Clone(want)
+	slices.Reverse(reversed)
+	if !slices.Equal(got, reversed) {
+		t.Errorf("reverse scan = %v, want %v", got, reversed)
+	}
+
+	seeks := []struct {
+		target, want string
+		forPrev      bool
+	}{
+		{target: "b", want: "b"},
+		{target: "d", want: "g"}, // skips the deleted e and f
+		{target: "e", want: "g"},
+		{target: "f", want: "c", forPrev: true},
+		{target: "h", want: "g", forPrev: true},
+	}
+	for _, s := range seeks {
+		if s.forPrev {
+			iter.SeekForPrev([]byte(s.target))
+		} else {
+			iter.Seek([]byte(s.target))
+		}
+		if !iter.Valid() || string(iter.Key()) != s.want {
+			t.Errorf("seek (forPrev %v) to %q = %q (valid %v), want %q", s.forPrev, s.target, iter.Key(), iter.Valid(), s.want)
+		}
+	}
+
+	// Changing direction in the middle of the overlay
+	iter.Seek([]byte("c"))
+	iter.Next()
+	iter.Prev()
+	if !iter.Valid() || string(iter.Key()) != "c" {
+		t.Errorf("Seek(c), Next, Prev = %q, want c", iter.Key())
+	}
+	iter.Prev()
+	if !iter.Valid() || string(iter.Key()) != "b" {
+		t.Errorf("Prev = %q, want b", iter.Key())
+	}
+	iter.Next()
+	iter.Next()
+	if !iter.Valid() || string(iter.Key()) != "g" {
+		t.Errorf("Next, Next = %q, want g", iter.Key())
+	}
+
+	// Bounds apply to staged keys too
+	bounded := DefaultReadOptions()
+	bounded.IterateLowerBound = []byte("b")
+	bounded.IterateUpperBound = []byte("g")
+	biter := db.NewIteratorWithBatch(bounded, wbwi)
+	defer biter.Close()
+	got = got[:0]
+	for biter.SeekToFirst(); biter.Valid(); biter.Next() {
+		got = append(got, string(biter.Key()))
+	}
+	if want := []string{"b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("bounded scan = %v, want %v", got, want)
+	}
+}
@@ -43,6 +43,9 @@ type writeController struct {
 	// Delayed write rate (bytes/sec), 0 means use default
 	delayedWriteRate uint64
 
+	// Clock used to delay writes; nil means the wall clock
+	clock SystemClock
+
 	// closed indicates shutdown has been requested.
 	// When true, MaybeStallWrite returns immediately instead of blocking.
 	closed bool
@@ -53,11 +56,13 @@ type writeController struct {
 }
 
 // newWriteController creates a new write controller.
-func newWriteController() *writeController {
+// Delayed writes sleep on clock; a nil clock uses the wall clock.
+func newWriteController(clock SystemClock) *writeController {
 	wc := &writeController{
 		condition:        WriteStallConditionNormal,
 		cause:            WriteStallCauseNone,
 		delayedWriteRate: 16 * 1024 * 1024, // 16 MB/s default
+		clock:            clock,
 	}
 	wc.stallCond = sync.NewCond(&wc.mu)
 	return wc
@@ -93,20 +98,22 @@ func (wc *writeController) setStallCondition(condition WriteStallCondition, caus
 	}
 }
 
-// maybeStallWrite checks the stall condition and blocks or delays if needed.
-// If the controller is closed (via releaseWriteStall), returns immediately.
-func (wc *writeController) maybeStallWrite(writeSize int) {
+// maybeStallWrite checks the stall condition and blocks or delays if needed,
+// reporting whether it did. If the controller is closed (via
+// releaseWriteStall), returns immediately.
+func (wc *writeController) maybeStallWrite(writeSize int) (stalled bool) {
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
 
 	// Handle stopped condition - block until released or closed
 	for wc.condition == WriteStallConditionStopped && !wc.closed {
+		stalled = true
 		wc.stallCond.Wait()
 	}
 
 	// If closed, return immediately without delay
 	if wc.closed {
-		return
+		return stalled
 	}
 
 	// Handle delayed condition - sleep based on write rate
@@ -114,12 +121,14 @@ func (wc *writeController) maybeStallWrite(writeSize int) {
 		// Calculate delay: (writeSize / rate) seconds
 		delayNs := int64(writeSize) * int64(time.Second) / int64(wc.delayedWriteRate)
 		if delayNs > 0 {
+			stalled = true
 			// Release lock during sleep to not block other operations
 			wc.mu.Unlock()
-			time.Sleep(time.Duration(delayNs))
+			clockSleep(wc.clock, time.Duration(delayNs))
 			wc.mu.Lock()
 		}
 	}
+	return stalled
 }
 
 // SetDelayedWriteRate sets the delayed write rate.
@@ -9,7 +9,7 @@ import (
 )
 
 func TestWriteControllerBasic(t *testing.T) {
-	wc := newWriteController()
+	wc := newWriteController(nil)
 
 	// Initial state should be normal
 	condition, cause := wc.getStallCondition()
@@ -22,7 +22,7 @@ func TestWriteControllerBasic(t *testing.T) {
 }
 
 func TestWriteControllerSetCondition(t *testing.T) {
-	wc := newWriteController()
+	wc := newWriteController(nil)
 
 	// Set to delayed
 	wc.setStallCondition(WriteStallConditionDelayed, WriteStallCauseL0FileCountLimit)
@@ -53,7 +53,7 @@ func TestWriteControllerSetCondition(t *testing.T) {
 }
 
 func TestWriteControllerStoppedWakesUp(t *testing.T) {
-	wc := newWriteController()
+	wc := newWriteController(nil)
 
 	// Set to stopped
 	wc.setStallCondition(WriteStallConditionStopped, WriteStallCauseL0FileCountLimit)
@@ -88,7 +88,7 @@ func TestWriteControllerStoppedWakesUp(t *testing.T) {
 }
 
 func TestWriteControllerDelayedSlowsDown(t *testing.T) {
-	wc := newWriteController()
+	wc := newWriteController(nil)
 
 	// Set a high write rate for testing
 	wc.setDelayedWriteRate(1024 * 1024) // 1 MB/s
@@ -212,7 +212,7 @@ func TestRecalculateWriteStallCondition(t *testing.T) {
 }
 
 func TestWriteControllerStats(t *testing.T) {
-	wc := newWriteController()
+	wc := newWriteController(nil)
 
 	// Initially zero
 	stopped, delayed := wc.getStats()
@@ -256,7 +256,7 @@ func TestWriteStallCauseString(t *testing.T) {
 // that ReleaseWriteStall unblocks goroutines waiting in MaybeStallWrite
 // even when the stall condition is still Stopped.
 func TestWriteControllerReleaseWriteStall(t *testing.T) {
-	wc := newWriteController()
+	wc := newWriteController(nil)
 
 	// Set to stopped condition
 	wc.setStallCondition(WriteStallConditionStopped, WriteStallCauseMemtableLimit)
@@ -220,6 +220,8 @@ type RecoveredPreparedTxn struct {
 	Name       string
 	PrepareSeq uint64
 	Keys       [][]byte // Keys that were written in this transaction
+
+	logNumber uint64 // WAL file holding the prepare
 }
 
 // recoveredPreparedTxns stores prepared transactions that survived a crash
@@ -231,7 +233,7 @@ type recoveredPreparedTxns struct {
 
 // OpenWritePreparedTxnDB opens a database with write-prepared transaction support.
 func OpenWritePreparedTxnDB(path string, opts *Options, txnOpts TransactionDBOptions) (*WritePreparedTxnDB, error) {
-	txnDB, err := OpenTransactionDB(path, opts, txnOpts)
+	txnDB, err := openTransactionDB(path, opts, txnOpts, prepareApply)
 	if err != nil {
 		return nil, err
 	}
@@ -253,6 +255,9 @@ func OpenWritePreparedTxnDB(path string, opts *Options, txnOpts TransactionDBOpt
 		_ = txnDB.Close()
 		return nil, fmt.Errorf("2PC recovery failed: %w", err)
 	}
+	txnDB.db.mu.Lock()
+	txnDB.db.minLogWithPrep = wpDB.minLogWithPrep
+	txnDB.db.mu.Unlock()
 
 	return wpDB, nil
 }
@@ -303,6 +308,22 @@ func (db *WritePreparedTxnDB) recover2PC() error {
 	return nil
 }
 
+// minLogWithPrep returns the oldest WAL file holding a recovered prepared
+// transaction that is not resolved yet, or 0. Transactions prepared since
+// open are all in the current WAL file, which is live anyway.
+func (db *WritePreparedTxnDB) minLogWithPrep() uint64 {
+	db.recovered.mu.RLock()
+	defer db.recovered.mu.RUnlock()
+
+	var minLog uint64
+	for _, txn := range db.recovered.txns {
+		if minLog == 0 || txn.logNumber < minLog {
+			minLog = txn.logNumber
+		}
+	}
+	return minLog
+}
+
 // GetAllPreparedTransactions returns all prepared transactions that were
 // recovered after a crash and need to be resolved (committed or rolled back).
 func (db *WritePreparedTxnDB) GetAllPreparedTransactions() []*RecoveredPreparedTxn {
@@ -429,6 +450,7 @@ func (db *WritePreparedTxnDB) scanLogFileFor2PC(
 		preparedTxns:   preparedTxns,
 		committedTxns:  committedTxns,
 		rolledBackTxns: rolledBackTxns,
+		logNumber:      logNum,
 	}
 
 	for {
@@ -460,6 +482,7 @@ type wal2PCScanner struct {
 	committedTxns  map[string]bool
 	rolledBackTxns map[string]bool
 	currentSeq     uint64
+	logNumber      uint64
 	inPrepare      bool
 	currentPrepare *RecoveredPreparedTxn
 }
@@ -487,12 +510,15 @@ func (h *wal2PCScanner) DeleteCF(cfID uint32, key []byte) error
 func (h *wal2PCScanner) SingleDeleteCF(cfID uint32, key []byte) error             { return nil }
 func (h *wal2PCScanner) MergeCF(cfID uint32, key, value []byte) error             { return nil }
 func (h *wal2PCScanner) DeleteRangeCF(cfID uint32, startKey, endKey []byte) error { return nil }
+func (h *wal2PCScanner) PutEntity(key, entity []byte) error                       { return nil }
+func (h *wal2PCScanner) PutEntityCF(cfID uint32, key, entity []byte) error        { return nil }
 
 func (h *wal2PCScanner) MarkBeginPrepare(unprepared bool) error {
 	h.inPrepare = true
 	h.currentPrepare = &RecoveredPreparedTxn{
 		PrepareSeq: h.currentSeq,
 		Keys:       make([][]byte, 0),
+		logNumber:  h.logNumber,
 	}
 	return nil
 }
@@ -608,11 +634,15 @@ func (txn *WritePreparedTxn) Prepare() error {
 		// Order must be: BeginPrepare, <data>, EndPrepare
 		prepareBatch := batch.New()
 
-		// Add BeginPrepare marker first
-		prepareBatch.MarkBeginPrepare()
+		// Add BeginPrepare marker first, telling recovery that the data is
+		// applied at prepare. Older WALs hold the plain marker here, which
+		// OpenWritePreparedTxnDB still replays.
+		prepareBatch.MarkBeginPersistedPrepare()
 
 		// Append the transaction's data
-		prepareBatch.Append(txn.writeBatch)
+		if err := prepareBatch.Append(txn.writeBatch); err != nil {
+			return err
+		}
 
 		// Add EndPrepare marker with transaction name at the end
 		xid := []byte(txn.name)
@@ -650,7 +680,12 @@ func (txn *WritePreparedTxn) Commit() error {
 	// unless there are no writes
 	if txn.state == TxnStateStarted {
 		if txn.writeBatch.Count() == 0 {
-			// Empty transaction, just cleanup
+			// Empty transaction, just write the commit-time batch and cleanup
+			if txn.commitTimeBatch != nil && txn.commitTimeBatch.Count() > 0 {
+				if err := txn.wpDB.db.Write(txn.writeOpts, txn.commitTimeBatch); err != nil {
+					return err
+				}
+			}
 			txn.releaseLocks()
 			txn.close()
 			txn.state = TxnStateCommitted
@@ -669,18 +704,22 @@ func (txn *WritePreparedTxn) Commit() error {
 		return ErrTxnNotPrepared
 	}
 
-	// Get commit sequence
-	txn.wpDB.db.mu.Lock()
-	txn.commitSeq = txn.wpDB.db.seq
-	txn.wpDB.db.mu.Unlock()
-
-	// Write commit marker to WAL
+	// Write commit marker to WAL, together with the commit-time batch
 	commitBatch := batch.New()
+	if txn.commitTimeBatch != nil {
+		if err := commitBatch.Append(txn.commitTimeBatch.internal); err != nil {
+			return err
+		}
+	}
 	commitBatch.MarkCommit([]byte(txn.name))
 	if err := txn.wpDB.db.Write(txn.writeOpts, newWriteBatchFromInternal(commitBatch)); err != nil {
 		return err
 	}
 
+	// The commit sequence is the last one the commit write was assigned, so
+	// that it covers the commit-time writes
+	txn.commitSeq = commitBatch.Sequence() + uint64(commitBatch.Count()) - 1
+
 	// Add to commit cache
 	txn.wpDB.commitCache.Add(txn.prepareSeq, txn.commitSeq)
 
@@ -3,9 +3,13 @@ package rockyardkv
 // write_prepared_txn_recovery_test.go implements tests for write prepared txn recovery.
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/aalhour/rockyardkv/internal/batch"
+	"github.com/aalhour/rockyardkv/vfs"
 )
 
 // TestWritePrepared2PCBasic tests basic prepare/commit functionality.
@@ -159,6 +163,61 @@ func TestWritePrepared2PCRecoveryCommitted(t *testing.T) {
 	}
 }
 
+// TestWritePreparedCommitTimeWriteBatch tests that the commit-time batch is
+// written with the commit marker, not by Prepare.
+func TestWritePreparedCommitTimeWriteBatch(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_2pc_commit_time_batch")
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	wpDB, err := OpenWritePreparedTxnDB(dbPath, opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("Failed to open write-prepared txn db: %v", err)
+	}
+
+	txn := wpDB.BeginWritePreparedTransaction(PessimisticTransactionOptions{}, nil)
+	if err := txn.SetName("commit_time_txn"); err != nil {
+		t.Fatalf("Failed to set txn name: %v", err)
+	}
+	if err := txn.Put([]byte("key1"), []byte("value1")); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	txn.GetCommitTimeWriteBatch().Put([]byte("commit_ts"), []byte("42"))
+
+	if err := txn.Prepare(); err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	if _, err := wpDB.Get([]byte("commit_ts")); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of the commit-time write after Prepare = %v, want ErrNotFound", err)
+	}
+
+	// The commit marker and the commit-time write are one WAL record
+	seq := wpDB.GetDB().GetLatestSequenceNumber()
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if got := wpDB.GetDB().GetLatestSequenceNumber(); got != seq+1 {
+		t.Errorf("Commit advanced the sequence number by %d, want 1", got-seq)
+	}
+	if got := txn.GetCommitSeq(); got != seq+1 {
+		t.Errorf("GetCommitSeq = %d, want %d, the sequence of the commit-time write", got, seq+1)
+	}
+	wpDB.Close()
+
+	// Both the transaction's and the commit-time writes survive a reopen
+	wpDB, err = OpenWritePreparedTxnDB(dbPath, opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("Failed to reopen write-prepared txn db: %v", err)
+	}
+	defer wpDB.Close()
+	for key, want := range map[string]string{"key1": "value1", "commit_ts": "42"} {
+		val, err := wpDB.Get([]byte(key))
+		if err != nil || string(val) != want {
+			t.Errorf("Get(%q) = (%q, %v), want (%q, nil)", key, val, err, want)
+		}
+	}
+}
+
 // TestWritePrepared2PCRecoveryRolledBack tests recovery of rolled back transactions.
 // NOTE: This test currently demonstrates a known limitation - rolled back data
 // is still visible after recovery because the 2PC recovery handler is not yet
@@ -412,3 +471,209 @@ func TestWritePreparedMarkers(t *testing.T) {
 		t.Log("No WAL files found - data was likely flushed to SST")
 	}
 }
+
+// TestWritePrepared2PCRecoveryAfterCrash tests that a transaction prepared
+// before a crash is recovered as prepared and can still be committed, also
+// when the memtable holding it was flushed before it was resolved.
+func TestWritePrepared2PCRecoveryAfterCrash(t *testing.T) {
+	tests := []struct {
+		name  string
+		flush bool
+	}{
+		{"memtable", false},
+		{"flushed", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			faultFS := vfs.NewFaultInjectionFS(vfs.Default())
+			opts := DefaultOptions()
+			opts.CreateIfMissing = true
+			opts.FS = faultFS
+			opts.RecycleLogFileNum = 1
+
+			crash := func(wpDB *WritePreparedTxnDB) {
+				faultFS.SetFilesystemActive(false)
+				_ = wpDB.Close()
+				faultFS.SetFilesystemActive(true)
+			}
+			reopen := func() *WritePreparedTxnDB {
+				wpDB, err := OpenWritePreparedTxnDB(dir, opts, TransactionDBOptions{})
+				if err != nil {
+					t.Fatalf("Failed to open write-prepared txn db: %v", err)
+				}
+				return wpDB
+			}
+
+			wpDB := reopen()
+			txn := wpDB.BeginWritePreparedTransaction(PessimisticTransactionOptions{}, &WriteOptions{Sync: true})
+			if err := txn.SetName("crashed_txn"); err != nil {
+				t.Fatalf("Failed to set txn name: %v", err)
+			}
+			if err := txn.Put([]byte("crash_key"), []byte("crash_value")); err != nil {
+				t.Fatalf("Failed to put: %v", err)
+			}
+			if err := txn.Prepare(); err != nil {
+				t.Fatalf("Failed to prepare: %v", err)
+			}
+			crash(wpDB)
+
+			wpDB = reopen()
+			if tt.flush {
+				// Make the WAL holding the prepare obsolete as far as the
+				// flushed data is concerned
+				if err := wpDB.Put([]byte("other_key"), []byte("other_value")); err != nil {
+					t.Fatalf("Failed to put: %v", err)
+				}
+				if err := wpDB.Flush(nil); err != nil {
+					t.Fatalf("Failed to flush: %v", err)
+				}
+				crash(wpDB)
+				wpDB = reopen()
+			}
+			defer wpDB.Close()
+
+			recovered := wpDB.GetAllPreparedTransactions()
+			if len(recovered) != 1 || recovered[0].Name != "crashed_txn" {
+				t.Fatalf("Expected crashed_txn to be recovered as prepared, got %v", recovered)
+			}
+			if err := wpDB.CommitPreparedTransaction("crashed_txn"); err != nil {
+				t.Fatalf("Failed to commit recovered transaction: %v", err)
+			}
+			val, err := wpDB.Get([]byte("crash_key"))
+			if err != nil {
+				t.Fatalf("Failed to get crash_key: %v", err)
+			}
+			if string(val) != "crash_value" {
+				t.Fatalf("Expected crash_value, got %s", val)
+			}
+			if recovered := wpDB.GetAllPreparedTransactions(); len(recovered) != 0 {
+				t.Fatalf("Expected no prepared transactions after commit, got %d", len(recovered))
+			}
+		})
+	}
+}
+
+// TestWritePrepared2PCOtherOpens tests that read-only instances and RepairDB
+// apply the writes of write-prepared transactions once committed, and that
+// Open refuses their WAL.
+func TestWritePrepared2PCOtherOpens(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "testdb")
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	wpDB, err := OpenWritePreparedTxnDB(dbPath, opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("Failed to open write-prepared txn db: %v", err)
+	}
+	prepare := func(name string) *WritePreparedTxn {
+		t.Helper()
+		txn := wpDB.BeginWritePreparedTransaction(PessimisticTransactionOptions{}, nil)
+		if err := txn.SetName(name); err != nil {
+			t.Fatalf("Failed to set txn name: %v", err)
+		}
+		if err := txn.Put([]byte(name), []byte("value")); err != nil {
+			t.Fatalf("Failed to put: %v", err)
+		}
+		if err := txn.Prepare(); err != nil {
+			t.Fatalf("Failed to prepare: %v", err)
+		}
+		return txn
+	}
+	if err := prepare("committed").Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if err := prepare("rolledback").Rollback(); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	prepare("prepared")
+	wpDB.Close()
+
+	if db, err := Open(dbPath, opts); !errors.Is(err, ErrNotSupported) {
+		if err == nil {
+			db.Close()
+		}
+		t.Fatalf("Open: got %v, want ErrNotSupported", err)
+	}
+
+	check := func(name string, get func(key []byte) ([]byte, error)) {
+		t.Helper()
+		if _, err := get([]byte("committed")); err != nil {
+			t.Errorf("%s: Get(committed) failed: %v", name, err)
+		}
+		for _, key := range []string{"rolledback", "prepared"} {
+			if _, err := get([]byte(key)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: Get(%s): got %v, want ErrNotFound", name, key, err)
+			}
+		}
+	}
+
+	roDB, err := OpenForReadOnly(dbPath, opts, false)
+	if err != nil {
+		t.Fatalf("OpenForReadOnly failed: %v", err)
+	}
+	check("read-only", func(key []byte) ([]byte, error) { return roDB.Get(nil, key) })
+	roDB.Close()
+
+	if err := RepairDB(dbPath, opts); err != nil {
+		t.Fatalf("RepairDB failed: %v", err)
+	}
+	database, err := Open(dbPath, opts)
+	if err != nil {
+		t.Fatalf("Open after RepairDB failed: %v", err)
+	}
+	defer database.Close()
+	check("repaired", func(key []byte) ([]byte, error) { return database.Get(nil, key) })
+}
+
+// TestWritePrepared2PCRecoveryLegacyMarker tests that a WAL written before
+// WritePreparedTxnDB had its own begin marker, with prepare sections opened
+// by the plain one, is still replayed.
+func TestWritePrepared2PCRecoveryLegacyMarker(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "testdb")
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	wpDB, err := OpenWritePreparedTxnDB(dbPath, opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("Failed to open write-prepared txn db: %v", err)
+	}
+	// Write the batches the way Prepare and Commit used to.
+	write := func(b *batch.WriteBatch) {
+		t.Helper()
+		if err := wpDB.db.Write(DefaultWriteOptions(), newWriteBatchFromInternal(b)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	for _, name := range []string{"committed", "prepared"} {
+		prepareBatch := batch.New()
+		prepareBatch.MarkBeginPrepare()
+		prepareBatch.Put([]byte(name), []byte("value"))
+		prepareBatch.MarkEndPrepare([]byte(name))
+		write(prepareBatch)
+	}
+	commitBatch := batch.New()
+	commitBatch.MarkCommit([]byte("committed"))
+	write(commitBatch)
+	wpDB.Close()
+
+	wpDB, err = OpenWritePreparedTxnDB(dbPath, opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("Failed to reopen write-prepared txn db: %v", err)
+	}
+	defer wpDB.Close()
+
+	val, err := wpDB.Get([]byte("committed"))
+	if err != nil {
+		t.Fatalf("Failed to get committed: %v", err)
+	}
+	if string(val) != "value" {
+		t.Fatalf("Expected value, got %s", val)
+	}
+	recovered := wpDB.GetAllPreparedTransactions()
+	if len(recovered) != 1 || recovered[0].Name != "prepared" {
+		t.Fatalf("Expected only prepared to be recovered, got %v", recovered)
+	}
+}
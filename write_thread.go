@@ -0,0 +1,181 @@
+package rockyardkv
+
+// write_thread.go implements group commit for writes.
+//
+// Concurrent writers join a queue. The writer at the head of the queue leads
+// a write group made of itself and the compatible writers queued behind it:
+// it assigns their sequence numbers, appends their batches to the WAL with a
+// single sync, and inserts them into the memtable. The followers wait until
+// the leader has written their batches, then the next writer in the queue
+// leads the following group.
+//
+// Reference: RocksDB v10.7.5
+//   - db/write_thread.h
+//   - db/write_thread.cc
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/aalhour/rockyardkv/internal/batch"
+)
+
+// maxWriteGroupBytes bounds the batch data of a write group. A small leader
+// only takes maxWriteGroupBytes/8 more, so that it is not delayed much.
+// Reference: RocksDB v10.7.5 include/rocksdb/options.h (max_write_batch_group_size_bytes)
+const maxWriteGroupBytes = 1 << 20
+
+// WriteStats holds cumulative statistics of the write path.
+type WriteStats struct {
+	// WALBytes is the number of batch bytes appended to the WAL.
+	WALBytes uint64
+
+	// MemtableBytes is the number of batch bytes inserted into memtables.
+	MemtableBytes uint64
+
+	// NumWrites is the number of batches written.
+	NumWrites uint64
+
+	// NumWriteGroups is the number of write groups the batches were written in.
+	NumWriteGroups uint64
+
+	// AvgWriteGroupSize is the average number of batches per write group.
+	AvgWriteGroupSize float64
+
+	// NumStalledWrites is the number of writes delayed or stopped by a
+	// write stall.
+	NumStalledWrites uint64
+}
+
+// writeStats holds the counters behind WriteStats.
+type writeStats struct {
+	walBytes      atomic.Uint64
+	memtableBytes atomic.Uint64
+	writes        atomic.Uint64
+	groups        atomic.Uint64
+	stalledWrites atomic.Uint64
+}
+
+// GetWriteStats returns cumulative statistics of the write path since the
+// database was opened.
+func (db *dbImpl) GetWriteStats() WriteStats {
+	stats := WriteStats{
+		WALBytes:         db.writeStats.walBytes.Load(),
+		MemtableBytes:    db.writeStats.memtableBytes.Load(),
+		NumWrites:        db.writeStats.writes.Load(),
+		NumWriteGroups:   db.writeStats.groups.Load(),
+		NumStalledWrites: db.writeStats.stalledWrites.Load(),
+	}
+	if stats.NumWriteGroups > 0 {
+		stats.AvgWriteGroupSize = float64(stats.NumWrites) / float64(stats.NumWriteGroups)
+	}
+	return stats
+}
+
+// writer is a batch waiting to be written.
+type writer struct {
+	batch    *batch.WriteBatch
+	opts     *WriteOptions
+	callback func() error
+
+	// Set by the leader that wrote the batch
+	err  error
+	done bool
+
+	// Signaled when the writer becomes a leader or its batch is written
+	ready chan struct{}
+}
+
+// newWriter creates a writer for b.
+func newWriter(b *batch.WriteBatch, opts *WriteOptions, callback func() error) *writer {
+	return &writer{
+		batch:    b,
+		opts:     opts,
+		callback: callback,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// writeThread queues concurrent writers and groups them.
+type writeThread struct {
+	mu    sync.Mutex
+	queue []*writer
+}
+
+// write queues w and returns once it has been written: by writeGroup, if w
+// leads a write group, or by the leader of its group.
+func (wt *writeThread) write(w *writer, writeGroup func(group []*writer)) {
+	if wt.joinBatchGroup(w) {
+		group := wt.enterAsBatchGroupLeader(w)
+		writeGroup(group)
+		wt.exitAsBatchGroupLeader(group)
+	}
+}
+
+// joinBatchGroup queues w and blocks until w leads a write group, in which
+// case it returns true, or until another leader has written w.
+func (wt *writeThread) joinBatchGroup(w *writer) bool {
+	wt.mu.Lock()
+	wt.queue = append(wt.queue, w)
+	leader := len(wt.queue) == 1
+	wt.mu.Unlock()
+
+	if leader {
+		return true
+	}
+	<-w.ready
+	return !w.done
+}
+
+// enterAsBatchGroupLeader returns the write group of leader: the leader and
+// the writers queued directly behind it that can share its WAL write.
+// Writers with a callback are written alone.
+func (wt *writeThread) enterAsBatchGroupLeader(leader *writer) []*writer {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+
+	group := []*writer{leader}
+	if leader.callback != nil {
+		return group
+	}
+
+	size := leader.batch.Size()
+	maxSize := maxWriteGroupBytes
+	if size <= maxWriteGroupBytes/8 {
+		maxSize = size + maxWriteGroupBytes/8
+	}
+	for _, w := range wt.queue[1:] {
+		// A sync write cannot ride on a leader that does not sync
+		if w.callback != nil || w.opts.DisableWAL != leader.opts.DisableWAL || (w.opts.Sync && !leader.opts.Sync) {
+			break
+		}
+		size += w.batch.Size()
+		if size > maxSize {
+			break
+		}
+		group = append(group, w)
+	}
+	return group
+}
+
+// exitAsBatchGroupLeader completes the followers of group and wakes the next
+// queued writer to lead the following group.
+func (wt *writeThread) exitAsBatchGroupLeader(group []*writer) {
+	wt.mu.Lock()
+	wt.queue = wt.queue[len(group):]
+	var next *writer
+	if len(wt.queue) > 0 {
+		next = wt.queue[0]
+	} else {
+		wt.queue = nil
+	}
+	wt.mu.Unlock()
+
+	for _, w := range group[1:] {
+		w.done = true
+		w.ready <- struct{}{}
+	}
+	if next != nil {
+		next.ready <- struct{}{}
+	}
+}
@@ -0,0 +1,254 @@
+package rockyardkv
+
+// write_thread_test.go implements tests for write groups and write stats.
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aalhour/rockyardkv/vfs"
+)
+
+// slowSyncFS makes every WAL sync take a millisecond, as on a disk.
+type slowSyncFS struct {
+	vfs.FS
+}
+
+func (fs *slowSyncFS) Create(name string) (vfs.WritableFile, error) {
+	f, err := fs.FS.Create(name)
+	if err != nil || !strings.HasSuffix(name, ".log") {
+		return f, err
+	}
+	return &slowSyncFile{WritableFile: f}, nil
+}
+
+type slowSyncFile struct {
+	vfs.WritableFile
+}
+
+func (f *slowSyncFile) Sync() error {
+	time.Sleep(time.Millisecond)
+	return f.WritableFile.Sync()
+}
+
+func TestWriteStatsSingleWriter(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	const numWrites = 10
+	for i := range numWrites {
+		if err := database.Put(nil, fmt.Appendf(nil, "key%d", i), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := database.Put(&WriteOptions{DisableWAL: true}, []byte("nowal"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	stats := database.GetWriteStats()
+	if stats.NumWrites != numWrites+1 || stats.NumWriteGroups != numWrites+1 {
+		t.Errorf("NumWrites = %d, NumWriteGroups = %d, want %d each",
+			stats.NumWrites, stats.NumWriteGroups, numWrites+1)
+	}
+	if stats.AvgWriteGroupSize != 1 {
+		t.Errorf("AvgWriteGroupSize = %v, want 1", stats.AvgWriteGroupSize)
+	}
+	if stats.WALBytes == 0 || stats.MemtableBytes <= stats.WALBytes {
+		t.Errorf("WALBytes = %d, MemtableBytes = %d, want 0 < WALBytes < MemtableBytes",
+			stats.WALBytes, stats.MemtableBytes)
+	}
+	if stats.NumStalledWrites != 0 {
+		t.Errorf("NumStalledWrites = %d, want 0", stats.NumStalledWrites)
+	}
+}
+
+func TestWriteGroupsConcurrentWriters(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.FS = &slowSyncFS{FS: vfs.Default()}
+
+	database, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer database.Close()
+
+	// Synced writes keep the leader busy while followers queue up
+	const numWriters = 16
+	const writesPerWriter = 20
+	writeOpts := &WriteOptions{Sync: true}
+	var wg sync.WaitGroup
+	errs := make(chan error, numWriters)
+	for w := range numWriters {
+		wg.Go(func() {
+			for i := range writesPerWriter {
+				if err := database.Put(writeOpts, fmt.Appendf(nil, "w%02d-%03d", w, i), []byte("value")); err != nil {
+					errs <- err
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	stats := database.GetWriteStats()
+	if stats.NumWrites != numWriters*writesPerWriter {
+		t.Errorf("NumWrites = %d, want %d", stats.NumWrites, numWriters*writesPerWriter)
+	}
+	if stats.AvgWriteGroupSize <= 1 {
+		t.Errorf("AvgWriteGroupSize = %v (%d writes in %d groups), want more than 1",
+			stats.AvgWriteGroupSize, stats.NumWrites, stats.NumWriteGroups)
+	}
+
+	// Every write landed with its own sequence number
+	if got := database.GetLatestSequenceNumber(); got != numWriters*writesPerWriter {
+		t.Errorf("GetLatestSequenceNumber = %d, want %d", got, numWriters*writesPerWriter)
+	}
+	for w := range numWriters {
+		for i := range writesPerWriter {
+			key := fmt.Appendf(nil, "w%02d-%03d", w, i)
+			if _, err := database.Get(nil, key); err != nil {
+				t.Fatalf("Get(%s) failed: %v", key, err)
+			}
+		}
+	}
+}
+
+func TestTwoWriteQueuesConcurrentPrepares(t *testing.T) {
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.TwoWriteQueues = true
+
+	wpDB, err := OpenWritePreparedTxnDB(t.TempDir(), opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("OpenWritePreparedTxnDB failed: %v", err)
+	}
+	defer wpDB.Close()
+
+	// Transactions prepare and commit while regular writes go on
+	const numWriters = 8
+	const writesPerWriter = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*numWriters)
+	for w := range numWriters {
+		wg.Go(func() {
+			for i := range writesPerWriter {
+				txn := wpDB.BeginWritePreparedTransaction(PessimisticTransactionOptions{}, nil)
+				if err := txn.SetName(fmt.Sprintf("txn%02d-%03d", w, i)); err != nil {
+					errs <- err
+					return
+				}
+				if err := txn.Put(fmt.Appendf(nil, "txn%02d-%03d", w, i), []byte("value")); err != nil {
+					errs <- err
+					return
+				}
+				if err := txn.Prepare(); err != nil {
+					errs <- err
+					return
+				}
+				if err := txn.Commit(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		})
+		wg.Go(func() {
+			for i := range writesPerWriter {
+				if err := wpDB.Put(fmt.Appendf(nil, "put%02d-%03d", w, i), []byte("value")); err != nil {
+					errs <- err
+					return
+				}
+			}
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("writes did not complete")
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	for w := range numWriters {
+		for i := range writesPerWriter {
+			for _, key := range []string{fmt.Sprintf("txn%02d-%03d", w, i), fmt.Sprintf("put%02d-%03d", w, i)} {
+				if _, err := wpDB.Get([]byte(key)); err != nil {
+					t.Fatalf("Get(%s) failed: %v", key, err)
+				}
+			}
+		}
+	}
+}
+
+func TestTwoWriteQueues2PCRecovery(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.CreateIfMissing = true
+	opts.TwoWriteQueues = true
+
+	wpDB, err := OpenWritePreparedTxnDB(dir, opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("OpenWritePreparedTxnDB failed: %v", err)
+	}
+	prepare := func(name string) *WritePreparedTxn {
+		t.Helper()
+		txn := wpDB.BeginWritePreparedTransaction(PessimisticTransactionOptions{}, nil)
+		if err := txn.SetName(name); err != nil {
+			t.Fatalf("SetName failed: %v", err)
+		}
+		if err := txn.Put([]byte(name), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if err := txn.Prepare(); err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		return txn
+	}
+	if err := prepare("committed").Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := prepare("rolledback").Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	prepare("prepared")
+	wpDB.Close()
+
+	// The commit and rollback markers written by the second queue are
+	// replayed like those of the main queue
+	wpDB, err = OpenWritePreparedTxnDB(dir, opts, TransactionDBOptions{})
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer wpDB.Close()
+
+	if _, err := wpDB.Get([]byte("committed")); err != nil {
+		t.Errorf("Get(committed) failed: %v", err)
+	}
+	if _, err := wpDB.Get([]byte("rolledback")); err == nil {
+		t.Error("Get(rolledback) succeeded, want the rolled back write gone")
+	}
+	recovered := wpDB.GetAllPreparedTransactions()
+	if len(recovered) != 1 || recovered[0].Name != "prepared" {
+		t.Fatalf("recovered %d prepared transactions, want only \"prepared\"", len(recovered))
+	}
+}